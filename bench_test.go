@@ -0,0 +1,152 @@
+package gocrdt
+
+import (
+	"fmt"
+	"testing"
+)
+
+var benchSizes = []int{1_000, 10_000, 100_000}
+
+// benchDoc returns a document of n nodes typed sequentially, as a user
+// would, and the ID of its last node.
+func benchDoc(n int) (*RGA, ID) {
+	doc := NewRGA("bench")
+	last := ID{0, "root"}
+	for i := range n {
+		last = doc.Insert(rune('a'+i%26), last)
+	}
+	return doc, last
+}
+
+func BenchmarkRGA_InsertAppend(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			doc, last := benchDoc(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				last = doc.Insert('x', last)
+			}
+		})
+	}
+}
+
+func BenchmarkRGA_InsertMiddle(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			doc, _ := benchDoc(size)
+			middle := doc.Nodes()[size/2].ID
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				doc.Insert('x', middle)
+			}
+		})
+	}
+}
+
+func BenchmarkRGA_Value(b *testing.B) {
+	for _, size := range benchSizes {
+		doc, _ := benchDoc(size)
+		b.Run(fmt.Sprintf("cached/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = doc.Value()
+			}
+		})
+		b.Run(fmt.Sprintf("render/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = doc.doc.Value()
+			}
+		})
+	}
+}
+
+func BenchmarkRGA_MergeState(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			doc, _ := benchDoc(size)
+			state, err := doc.MarshalState()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(state)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := NewRGA("peer").MergeState(state); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRGA_MergeDuplicates(b *testing.B) {
+	doc, _ := benchDoc(10_000)
+	nodes := doc.Nodes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		doc.Merge(nodes)
+	}
+}
+
+func BenchmarkGCounter_Contention(b *testing.B) {
+	c := NewGCounter("bench")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Increment()
+		}
+	})
+}
+
+func BenchmarkPNCounter_Contention(b *testing.B) {
+	c := NewPNCounter("bench")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if i%4 == 0 {
+				_ = c.Value()
+			} else {
+				c.Increment()
+			}
+		}
+	})
+}
+
+// benchReplicas is the number of replicas in the slot vectors of the
+// counter merge benchmarks.
+const benchReplicas = 64
+
+func BenchmarkGCounter_Merge(b *testing.B) {
+	local, remote := NewGCounter("local"), NewGCounter("remote")
+	slots := make(map[string]int, benchReplicas)
+	for i := range benchReplicas {
+		slots[fmt.Sprintf("replica-%d", i)] = i + 1
+	}
+	remote.MergeSlots(slots)
+	local.Merge(remote)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		local.Merge(remote)
+	}
+}
+
+func BenchmarkPNCounter_Merge(b *testing.B) {
+	local, remote := NewPNCounter("local"), NewPNCounter("remote")
+	slots := make(map[string]int, benchReplicas)
+	for i := range benchReplicas {
+		slots[fmt.Sprintf("replica-%d", i)] = i + 1
+	}
+	remote.MergeSlots(slots, slots)
+	local.Merge(remote)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		local.Merge(remote)
+	}
+}
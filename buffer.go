@@ -0,0 +1,184 @@
+package gocrdt
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+var (
+	// ErrOffset is returned by TextBuffer.Seek for an offset outside the
+	// text.
+	ErrOffset = errors.New("gocrdt: offset out of range")
+
+	// ErrRuneBoundary is returned by the writes of a TextBuffer whose
+	// offset is inside a multi-byte character.
+	ErrRuneBoundary = errors.New("gocrdt: offset inside a character")
+)
+
+// rootID identifies the root of every RGA.
+var rootID = ID{0, "root"}
+
+// TextBuffer is an io.ReadWriteSeeker over the visible text of an RGA,
+// UTF-8 encoded, for code written against Go text buffers, such as editors
+// or text/template:
+//
+//	buf := NewTextBuffer(doc)
+//	_, _ = buf.Seek(0, io.SeekEnd)
+//	_ = tmpl.Execute(buf, data)
+//
+// Unlike a file, writes insert at the offset rather than overwrite, as an
+// editor would; Delete removes text. The offset is kept as the node before
+// it, so edits merged from other replicas move it with the text around it
+// rather than leaving it at a stale byte count. A TextBuffer must be used
+// by one goroutine at a time; the document may be shared.
+type TextBuffer struct {
+	doc     *RGA
+	anchor  ID     // Node before the offset, or the root
+	skip    int    // Bytes of the character after anchor before the offset
+	off     int64  // Offset when last seen, should anchor be compacted away
+	partial []byte // Incomplete character at the end of the last Write
+}
+
+// NewTextBuffer creates a buffer over doc, at offset 0.
+func NewTextBuffer(doc *RGA) *TextBuffer {
+	return &TextBuffer{doc: doc, anchor: rootID}
+}
+
+// text returns the visible text of the document with the IDs and byte
+// offsets of its characters, and the current offset.
+func (b *TextBuffer) text() (text []byte, runes []visibleRune, starts []int, off int) {
+	found := b.anchor == rootID
+	for _, n := range b.doc.Nodes() {
+		if !n.Deleted {
+			runes = append(runes, visibleRune{n.ID, n.Value})
+			starts = append(starts, len(text))
+			text = utf8.AppendRune(text, n.Value)
+		}
+		if n.ID == b.anchor {
+			found, off = true, len(text)
+		}
+	}
+	if found {
+		off += b.skip
+	} else {
+		off = int(min(b.off, int64(len(text))))
+	}
+	if off > len(text) {
+		// The character the offset was inside was deleted.
+		off = len(text)
+	}
+	return text, runes, starts, off
+}
+
+// moveTo sets the offset to off, within the text of runes at starts.
+func (b *TextBuffer) moveTo(runes []visibleRune, starts []int, off int) {
+	b.anchor, b.skip, b.off = rootID, 0, int64(off)
+	for i, start := range starts {
+		if start >= off {
+			break
+		}
+		if end := start + utf8.RuneLen(runes[i].r); end <= off {
+			b.anchor = runes[i].id
+		} else {
+			b.skip = off - start
+		}
+	}
+}
+
+// Len returns the length of the text in bytes.
+func (b *TextBuffer) Len() int {
+	text, _, _, _ := b.text()
+	return len(text)
+}
+
+// String returns the text.
+func (b *TextBuffer) String() string {
+	text, _, _, _ := b.text()
+	return string(text)
+}
+
+// Read reads the text from the offset, returning io.EOF at its end.
+func (b *TextBuffer) Read(p []byte) (int, error) {
+	text, runes, starts, off := b.text()
+	if off >= len(text) {
+		b.moveTo(runes, starts, off)
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, text[off:])
+	b.moveTo(runes, starts, off+n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read, Write or Delete, in bytes, as
+// io.Seeker does. Offsets past the end of the text are refused with
+// ErrOffset.
+func (b *TextBuffer) Seek(offset int64, whence int) (int64, error) {
+	text, runes, starts, off := b.text()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += int64(off)
+	case io.SeekEnd:
+		offset += int64(len(text))
+	default:
+		return 0, errors.New("gocrdt: invalid whence")
+	}
+	if offset < 0 || offset > int64(len(text)) {
+		return 0, ErrOffset
+	}
+	b.moveTo(runes, starts, int(offset))
+	return offset, nil
+}
+
+// Write inserts p at the offset and moves the offset past it. A character
+// split between two writes is inserted by the second; invalid UTF-8 is
+// inserted as utf8.RuneError. It returns ErrRuneBoundary, writing nothing,
+// when the offset is inside a character.
+func (b *TextBuffer) Write(p []byte) (int, error) {
+	_, runes, starts, off := b.text()
+	b.moveTo(runes, starts, off)
+	if b.skip != 0 {
+		return 0, ErrRuneBoundary
+	}
+	data := append(b.partial, p...)
+	b.partial = nil
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			b.partial = append([]byte(nil), data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		b.anchor = b.doc.Insert(r, b.anchor)
+		b.off += int64(utf8.RuneLen(r))
+		data = data[size:]
+	}
+	return len(p), nil
+}
+
+// WriteString is Write for a string.
+func (b *TextBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// Delete deletes the characters starting in the n bytes after the offset,
+// fewer at the end of the text, and returns the bytes deleted. It returns
+// ErrRuneBoundary when the offset is inside a character.
+func (b *TextBuffer) Delete(n int) (int, error) {
+	_, runes, starts, off := b.text()
+	b.moveTo(runes, starts, off)
+	if b.skip != 0 {
+		return 0, ErrRuneBoundary
+	}
+	deleted := 0
+	for i, start := range starts {
+		if start >= off && start < off+n {
+			b.doc.Delete(runes[i].id)
+			deleted += utf8.RuneLen(runes[i].r)
+		}
+	}
+	return deleted, nil
+}
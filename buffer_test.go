@@ -0,0 +1,86 @@
+package gocrdt
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTextBuffer(t *testing.T) {
+	doc := NewRGA("a")
+	buf := NewTextBuffer(doc)
+	if _, err := io.WriteString(buf, "héllo"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Value() != "héllo" || buf.Len() != 6 {
+		t.Fatalf("Expected héllo in 6 bytes, got %q in %d", doc.Value(), buf.Len())
+	}
+
+	// Writes insert at the offset.
+	if _, err := buf.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = buf.WriteString("¡")
+	if off, _ := buf.Seek(0, io.SeekCurrent); off != 2 || doc.Value() != "¡héllo" {
+		t.Errorf("Expected ¡héllo at offset 2, got %q at %d", doc.Value(), off)
+	}
+
+	// Reads by byte, through characters.
+	got, err := io.ReadAll(buf)
+	if err != nil || string(got) != "héllo" {
+		t.Errorf("Expected to read héllo, got %q, %v", got, err)
+	}
+	if _, err := buf.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buf.WriteString("x"); !errors.Is(err, ErrRuneBoundary) {
+		t.Errorf("Expected ErrRuneBoundary inside ¡, got %v", err)
+	}
+	if _, err := buf.Seek(1, io.SeekEnd); !errors.Is(err, ErrOffset) {
+		t.Errorf("Expected ErrOffset past the end, got %v", err)
+	}
+
+	// Deletes remove whole characters.
+	_, _ = buf.Seek(2, io.SeekStart)
+	if n, err := buf.Delete(3); err != nil || n != 3 || doc.Value() != "¡llo" {
+		t.Errorf("Expected hé deleted, got %q, %d, %v", doc.Value(), n, err)
+	}
+}
+
+func TestTextBufferFollowsRemoteEdits(t *testing.T) {
+	doc := NewRGA("a")
+	buf := NewTextBuffer(doc)
+	_, _ = buf.WriteString("world")
+	_, _ = buf.Seek(1, io.SeekStart)
+
+	other := NewRGA("b")
+	other.Merge(doc.Nodes())
+	otherBuf := NewTextBuffer(other)
+	_, _ = otherBuf.WriteString("hello ")
+	doc.Merge(other.Nodes())
+
+	// The offset stays after the "w", past the text merged before it.
+	if off, _ := buf.Seek(0, io.SeekCurrent); off != 7 {
+		t.Errorf("Expected the offset to follow the merged text, got %d", off)
+	}
+	_, _ = buf.Seek(0, io.SeekEnd)
+	_, _ = buf.Write([]byte("!\xe2\x82"))
+	_, _ = buf.Write([]byte("\xac"))
+	if doc.Value() != "hello world!€" {
+		t.Errorf("Expected a split character written whole, got %q", doc.Value())
+	}
+}
+
+func TestTextBufferTemplate(t *testing.T) {
+	doc := NewRGA("a")
+	tmpl := template.Must(template.New("t").Parse("Dear {{.}},"))
+	if err := tmpl.Execute(NewTextBuffer(doc), "Ada"); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, NewTextBuffer(doc)); err != nil || sb.String() != "Dear Ada," {
+		t.Errorf("Expected the rendered template, got %q, %v", sb.String(), err)
+	}
+}
@@ -0,0 +1,46 @@
+package gocrdt
+
+// NewUnsyncRGAFromString creates an UnsyncRGA holding text. See
+// NewRGAFromString.
+func NewUnsyncRGAFromString(nodeID, text string, opts ...Option) *UnsyncRGA {
+	r := NewUnsyncRGA(nodeID, opts...)
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return r
+	}
+
+	nodes := make([]Node, len(runes)) // one allocation for the whole run
+	r.registry = make(map[ID]*Node, len(runes)+1)
+	r.registry[r.root.ID] = r.root
+	r.changes = make([]ID, 0, len(runes))
+	prev := r.root
+	for i, val := range runes {
+		r.clock++
+		n := &nodes[i]
+		*n = Node{ID: ID{r.clock, nodeID}, ParentID: prev.ID, Value: val}
+		prev.Next = n
+		r.registry[n.ID] = n
+		r.changes = append(r.changes, n.ID)
+		prev = n
+	}
+	return r
+}
+
+// NewRGAFromString creates an RGA holding text, such as an existing file
+// being brought under collaborative editing, in O(len(text)).
+//
+// Inserting the runes one by one takes the lock and searches the siblings
+// of the parent for each of them. Here every rune is inserted after the
+// previous one, with consecutive timestamps, in a single pass: the result
+// is the document Insert would have built, one causal run that other
+// replicas merge like any payload, without orphans. Other replicas should
+// start from this state rather than import the same text themselves: two
+// imports are distinct insertions and would duplicate the text. See Option
+// for the settings opts can tune; WithClock shifts the timestamps.
+func NewRGAFromString(nodeID, text string, opts ...Option) *RGA {
+	return &RGA{
+		mu:     newLocker(newOptions(opts).locking),
+		doc:    *NewUnsyncRGAFromString(nodeID, text, opts...),
+		notify: make(chan struct{}),
+	}
+}
@@ -0,0 +1,49 @@
+package gocrdt
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewRGAFromString(t *testing.T) {
+	text := "héllo\nwörld"
+	doc := NewRGAFromString("a", text)
+	if doc.Value() != text {
+		t.Fatalf("Expected %q, got %q", text, doc.Value())
+	}
+
+	// The document is the one Insert builds, so it merges and edits alike.
+	typed := NewRGA("a")
+	parent := ID{NodeID: "root"}
+	for _, r := range text {
+		parent = typed.Insert(r, parent)
+	}
+	if !slices.Equal(doc.Nodes(), typed.Nodes()) {
+		t.Error("Expected the nodes of per-rune inserts")
+	}
+	if id := doc.Insert('!', parent); id.Timestamp != int64(len([]rune(text))+1) {
+		t.Errorf("Expected the clock to follow the text, got %v", id)
+	}
+	peer := NewRGA("b")
+	if result := peer.Merge(doc.Nodes()); result.Orphaned != 0 || peer.Value() != text+"!" {
+		t.Errorf("Expected a clean merge, got %+v and %q", result, peer.Value())
+	}
+	if nodes, _ := doc.Changes(0); len(nodes) != len([]rune(text))+1 {
+		t.Errorf("Expected every rune in the change log, got %d", len(nodes))
+	}
+
+	if shifted := NewUnsyncRGAFromString("a", "xy", WithClock(10)); shifted.Nodes()[0].ID.Timestamp != 11 {
+		t.Errorf("Expected WithClock to shift the timestamps, got %v", shifted.Nodes()[0].ID)
+	}
+	if NewRGAFromString("a", "").Value() != "" {
+		t.Error("Expected an empty document")
+	}
+}
+
+func BenchmarkNewRGAFromString(b *testing.B) {
+	text := strings.Repeat("lorem ipsum dolor sit amet\n", 1<<15)
+	for b.Loop() {
+		NewRGAFromString("a", text)
+	}
+}
@@ -0,0 +1,99 @@
+package gocrdt
+
+import "fmt"
+
+// ChangeRecord is a change of an RGA as a flat record for a message broker
+// or a transactional outbox table, such as to mirror documents into a
+// warehouse. It carries no CRDT metadata beyond the node IDs.
+type ChangeRecord struct {
+	// Document identifies the document. Use it as the message key, so the
+	// records of a document stay in one partition, in order.
+	Document string `json:"document"`
+
+	// Key identifies the change itself: a node is inserted once and
+	// deleted at most once, so consumers drop records with a Key they
+	// have seen, whichever replica or feed published them.
+	Key string `json:"key"`
+
+	// Kind is OpInsert or OpDelete.
+	Kind OpKind `json:"kind"`
+
+	// Node and Parent identify the node changed and the node it was
+	// inserted after; Value is its character.
+	Node   ID     `json:"node"`
+	Parent ID     `json:"parent"`
+	Value  string `json:"value"`
+
+	// Version is the version of the document once the records of the same
+	// call to ChangeFeed.Next are applied, from which the feed resumes.
+	Version Version `json:"version"`
+}
+
+// ChangeFeed turns the changes of an RGA, local and merged, into
+// ChangeRecords, in the order the document integrated them:
+//
+//	feed := NewChangeFeed("doc-42", doc, checkpoint)
+//	for {
+//		changed := doc.ChangeNotify()
+//		records, err := feed.Next()
+//		... publish records, then store feed.Version() as checkpoint
+//		<-changed
+//	}
+//
+// A feed resumes from a Version, such as the last one published. A version
+// of another instance of the document, such as before a restart, restarts
+// the feed from the whole document; the Keys of the records already
+// published let consumers drop them. A ChangeFeed must be used by one
+// goroutine at a time.
+type ChangeFeed struct {
+	document string
+	doc      *RGA
+	version  Version
+}
+
+// NewChangeFeed creates a feed of the changes of doc, identified as
+// document, after the version from (the whole document for "").
+func NewChangeFeed(document string, doc *RGA, from Version) *ChangeFeed {
+	return &ChangeFeed{document: document, doc: doc, version: from}
+}
+
+// Next returns the records of the changes since the previous call, none
+// when nothing changed. A node changed several times in between yields its
+// insert and, if deleted, its delete.
+func (f *ChangeFeed) Next() ([]ChangeRecord, error) {
+	nodes, version, err := f.doc.Since(f.version)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ChangeRecord, 0, len(nodes))
+	for _, n := range nodes {
+		record := ChangeRecord{
+			Document: f.document,
+			Kind:     OpInsert,
+			Node:     n.ID,
+			Parent:   n.ParentID,
+			Value:    string(n.Value),
+			Version:  version,
+		}
+		record.Key = f.key(record)
+		records = append(records, record)
+		if n.Deleted {
+			record.Kind = OpDelete
+			record.Key = f.key(record)
+			records = append(records, record)
+		}
+	}
+	f.version = version
+	return records, nil
+}
+
+// key returns the idempotency key of record.
+func (f *ChangeFeed) key(record ChangeRecord) string {
+	return fmt.Sprintf("%s/%d@%s/%s", f.document, record.Node.Timestamp, record.Node.NodeID, record.Kind)
+}
+
+// Version returns the version the feed has reached: the checkpoint to
+// resume from once the records returned so far are published.
+func (f *ChangeFeed) Version() Version {
+	return f.version
+}
@@ -0,0 +1,42 @@
+package gocrdt
+
+import "testing"
+
+func TestChangeFeed(t *testing.T) {
+	doc := NewRGA("a")
+	feed := NewChangeFeed("doc", doc, "")
+	h := doc.Insert('h', ID{0, "root"})
+	doc.Insert('i', h)
+
+	records, err := feed.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Value != "h" || records[1].Value != "i" || records[0].Kind != OpInsert {
+		t.Fatalf("Expected the two inserts in order, got %+v", records)
+	}
+	if records[0].Key != "doc/1@a/insert" || records[0].Version != feed.Version() {
+		t.Errorf("Expected a keyed record at the feed's version, got %+v", records[0])
+	}
+	if records, _ := feed.Next(); len(records) != 0 {
+		t.Errorf("Expected no records without changes, got %+v", records)
+	}
+
+	// Merged changes are fed too.
+	other := NewRGA("b")
+	other.Merge(doc.Nodes())
+	other.Delete(h)
+	doc.Merge(other.Nodes())
+	records, _ = feed.Next()
+	if len(records) != 2 || records[1].Kind != OpDelete || records[1].Key != "doc/1@a/delete" {
+		t.Errorf("Expected the insert and delete of h, got %+v", records)
+	}
+
+	// A feed resumed from a checkpoint picks up where it stopped.
+	checkpoint := feed.Version()
+	doc.Insert('!', h)
+	resumed := NewChangeFeed("doc", doc, checkpoint)
+	if records, _ := resumed.Next(); len(records) != 1 || records[0].Value != "!" {
+		t.Errorf("Expected the change after the checkpoint, got %+v", records)
+	}
+}
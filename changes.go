@@ -0,0 +1,81 @@
+package gocrdt
+
+// Changes returns every node that was inserted or tombstoned on this
+// replica (locally or through a merge) after the change cursor since, and
+// the cursor to pass to the next call. A cursor of 0 returns the whole
+// document.
+//
+// The nodes are returned in the order they were integrated, so parents
+// precede their children and the result can be merged by a peer without
+// producing orphans. A node changed several times is returned once, with
+// its current state.
+//
+// Cursors are local to this replica: they count changes applied here and
+// must not be exchanged with other replicas.
+func (r *UnsyncRGA) Changes(since uint64) ([]Node, uint64) {
+	next := uint64(len(r.changes))
+	if since >= next {
+		return nil, next
+	}
+
+	seen := make(map[ID]bool)
+	var nodes []Node
+	for _, id := range r.changes[since:] {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		node, ok := r.registry[id]
+		if !ok {
+			continue // collected by CompactStable
+		}
+		n := *node
+		n.Next = nil
+		nodes = append(nodes, n)
+	}
+	return nodes, next
+}
+
+// MarshalChanges encodes Changes(since) as a payload accepted by MergeState.
+// An empty payload (nil) is returned when nothing changed.
+func (r *UnsyncRGA) MarshalChanges(since uint64) ([]byte, uint64, error) {
+	nodes, next := r.Changes(since)
+	if len(nodes) == 0 {
+		return nil, next, nil
+	}
+	data, err := r.encodeNodes(nodes, r.tieBreak)
+	return data, next, err
+}
+
+// Changes returns every node changed on this replica after the cursor
+// since, and the next cursor. See UnsyncRGA.Changes.
+func (r *RGA) Changes(since uint64) ([]Node, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Changes(since)
+}
+
+// MarshalChanges encodes Changes(since) as a payload accepted by MergeState.
+// See UnsyncRGA.MarshalChanges.
+func (r *RGA) MarshalChanges(since uint64) ([]byte, uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MarshalChanges(since)
+}
+
+// ChangeNotify returns a channel that is closed at the next mutation of the
+// document. Streaming sync loops wait on it between calls to Changes:
+//
+//	for {
+//		nodes, cursor = doc.Changes(cursor)
+//		send(nodes)
+//		<-doc.ChangeNotify()
+//	}
+//
+// Fetch the channel before calling Changes to avoid missing a mutation that
+// happens in between.
+func (r *RGA) ChangeNotify() <-chan struct{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.notify
+}
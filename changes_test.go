@@ -0,0 +1,73 @@
+package gocrdt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRGA_Changes(t *testing.T) {
+	doc := NewRGA("a")
+	doc.Insert('A', ID{NodeID: "root"})
+	first := doc.Nodes()[0].ID
+	doc.Insert('B', first)
+
+	nodes, cursor := doc.Changes(0)
+	if len(nodes) != 2 || cursor != 2 {
+		t.Fatalf("Expected 2 changes and cursor 2, got %d and %d", len(nodes), cursor)
+	}
+
+	// A node changed twice since the cursor is reported once, as it is now.
+	doc.Insert('C', first)
+	doc.Delete(first)
+	nodes, cursor = doc.Changes(cursor)
+	if len(nodes) != 2 || cursor != 4 {
+		t.Fatalf("Expected 2 changes and cursor 4, got %d and %d", len(nodes), cursor)
+	}
+	if nodes[1].ID != first || !nodes[1].Deleted {
+		t.Errorf("Expected the tombstoned node last, got %+v", nodes[1])
+	}
+
+	if nodes, next := doc.Changes(cursor); nodes != nil || next != cursor {
+		t.Errorf("Expected no changes, got %d (cursor %d)", len(nodes), next)
+	}
+
+	// Merged changes are logged too, and duplicates are not.
+	peer := NewRGA("b")
+	payload, _, err := doc.MarshalChanges(0)
+	if err != nil {
+		t.Fatalf("MarshalChanges failed: %v", err)
+	}
+	if _, err := peer.MergeState(payload); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	_, peerCursor := peer.Changes(0)
+	if _, err := peer.MergeState(payload); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if _, again := peer.Changes(0); again != peerCursor {
+		t.Errorf("Duplicate merge grew the change log from %d to %d", peerCursor, again)
+	}
+	if peer.Value() != doc.Value() {
+		t.Errorf("Expected %q, got %q", doc.Value(), peer.Value())
+	}
+}
+
+func TestRGA_ChangeNotify(t *testing.T) {
+	doc := NewRGA("a")
+	notify := doc.ChangeNotify()
+	select {
+	case <-notify:
+		t.Fatal("Notified before any change")
+	default:
+	}
+
+	doc.Insert('A', ID{NodeID: "root"})
+	select {
+	case <-notify:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a notification after Insert")
+	}
+	if doc.ChangeNotify() == notify {
+		t.Error("Expected a fresh channel after the notification")
+	}
+}
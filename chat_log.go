@@ -0,0 +1,266 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// markerRune is the value of the RGA nodes ordering the items of composite
+// types such as ChatLog, whose content is kept apart.
+const markerRune = '\uFFFC'
+
+// ChatMessage is a message of a ChatLog, as returned by Messages.
+type ChatMessage struct {
+	ID     ID
+	Author string
+	Text   string
+	Sent   time.Time // Wall-clock time of the Post, on the posting replica
+	Edited bool
+
+	// Reactions counts the replicas reacting with each emoji. Emojis
+	// nobody reacts with are absent.
+	Reactions map[string]int
+}
+
+// chatEntry is the replicated content of a message.
+type chatEntry struct {
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	Sent   time.Time `json:"sent"`
+	Edited bool      `json:"edited,omitempty"`
+}
+
+// chatLogState is the wire representation of a ChatLog.
+type chatLogState struct {
+	V         int             `json:"v"`
+	Order     json.RawMessage `json:"order"`
+	Messages  json.RawMessage `json:"messages"`
+	Reactions json.RawMessage `json:"reactions"`
+}
+
+// ChatLog is the message log of a chat session: an ordered list of
+// messages, each with emoji reactions, built from the types of this
+// package:
+//
+//	chat := NewChatLog("alice")
+//	id := chat.Post("alice", "lunch?")
+//	chat.React(id, "👍")
+//
+// The order of the messages is an RGA, with one node per message appended
+// after the last one, so messages posted concurrently stay together in the
+// same order on every replica. Their content is a ConfigMap keyed by
+// message ID, where the last Edit wins, and their reactions an ORMap of
+// PNCounters, one per message and emoji, to which every replica
+// contributes at most 1.
+//
+// Deleted messages leave a tombstone in the order. Payloads are JSON.
+// ChatLog is safe for concurrent use; it guards its parts with a
+// read/write mutex, or the lock chosen with WithLocking.
+type ChatLog struct {
+	nodeID string
+	now    func() time.Time
+
+	mu        rwLocker // See WithLocking
+	order     UnsyncRGA
+	messages  UnsyncConfigMap[chatEntry]
+	reactions UnsyncORMap[string, *UnsyncPNCounter]
+}
+
+// NewChatLog creates an empty ChatLog for the replica nodeID, which must
+// be unique. Of the options, WithLocking, WithClock, WithMaxOrphans and
+// WithConflictJournal apply.
+func NewChatLog(nodeID string, opts ...Option) *ChatLog {
+	o := newOptions(opts)
+	return &ChatLog{
+		nodeID:   nodeID,
+		now:      time.Now,
+		mu:       newLocker(o.locking),
+		order:    *NewUnsyncRGA(nodeID, WithClock(o.clock), WithMaxOrphans(o.maxOrphans), WithConflictJournal(o.journal)),
+		messages: *NewUnsyncConfigMap[chatEntry](nodeID, nil, WithConflictJournal(o.journal)),
+		reactions: *NewUnsyncORMap[string](nodeID, func(id string) *UnsyncPNCounter {
+			return NewUnsyncPNCounter(id)
+		}),
+	}
+}
+
+// idKey returns the key of the node id in the maps of composite types.
+func idKey(id ID) string {
+	return fmt.Sprintf("%d@%s", id.Timestamp, id.NodeID)
+}
+
+// reactionKey returns the key of the reactions to the message id with
+// emoji.
+func reactionKey(id ID, emoji string) string {
+	return idKey(id) + "/" + emoji
+}
+
+// Post appends a message by author and returns its ID.
+func (c *ChatLog) Post(author, text string) ID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.order.Insert(markerRune, c.order.last().ID)
+	_ = c.messages.Set(idKey(id), chatEntry{Author: author, Text: text, Sent: c.now()})
+	return id
+}
+
+// entry returns the content of the message id, failing with ErrUnknownNode
+// for a message not in the log. It must be called with mu held.
+func (c *ChatLog) entry(id ID) (chatEntry, error) {
+	if n, ok := c.order.registry[id]; ok && !n.Deleted && id != c.order.root.ID {
+		if e, ok := c.messages.Get(idKey(id)); ok {
+			return e, nil
+		}
+	}
+	return chatEntry{}, fmt.Errorf("%w: message %v", ErrUnknownNode, id)
+}
+
+// Edit replaces the text of the message id. Concurrent edits resolve to
+// the last one.
+func (c *ChatLog) Edit(id ID, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, err := c.entry(id)
+	if err != nil {
+		return err
+	}
+	e.Text, e.Edited = text, true
+	return c.messages.Set(idKey(id), e)
+}
+
+// Delete removes the message id, which wins over concurrent edits.
+func (c *ChatLog) Delete(id ID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.entry(id); err != nil {
+		return err
+	}
+	c.order.Delete(id)
+	c.messages.Delete(idKey(id))
+	return nil
+}
+
+// React adds the reaction of this replica with emoji to the message id. A
+// replica reacts at most once per emoji; reacting again does nothing.
+func (c *ChatLog) React(id ID, emoji string) error {
+	return c.react(id, emoji, 1)
+}
+
+// Unreact withdraws the reaction of this replica with emoji to the message
+// id, if any.
+func (c *ChatLog) Unreact(id ID, emoji string) error {
+	return c.react(id, emoji, 0)
+}
+
+// react sets the contribution of this replica to the reactions with emoji
+// to want, 0 or 1.
+func (c *ChatLog) react(id ID, emoji string, want int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.entry(id); err != nil {
+		return err
+	}
+	c.reactions.Update(reactionKey(id, emoji), func(counter *UnsyncPNCounter) {
+		own := counter.pCounter.slots[c.nodeID] - counter.nCounter.slots[c.nodeID]
+		switch {
+		case own < want:
+			counter.Increment()
+		case own > want:
+			counter.Decrement()
+		}
+	})
+	return nil
+}
+
+// Messages returns the messages of the log, in order.
+func (c *ChatLog) Messages() []ChatMessage {
+	c.mu.Lock() // Reading reactions may create their values
+	defer c.mu.Unlock()
+
+	reactions := make(map[string]map[string]int)
+	for key, counter := range c.reactions.Entries() {
+		if n := counter.Value(); n > 0 {
+			message, emoji, _ := strings.Cut(key, "/")
+			if reactions[message] == nil {
+				reactions[message] = make(map[string]int)
+			}
+			reactions[message][emoji] = n
+		}
+	}
+
+	var messages []ChatMessage
+	for n := c.order.root.Next; n != nil; n = n.Next {
+		if n.Deleted {
+			continue
+		}
+		key := idKey(n.ID)
+		e, ok := c.messages.Get(key)
+		if !ok {
+			continue
+		}
+		messages = append(messages, ChatMessage{
+			ID:        n.ID,
+			Author:    e.Author,
+			Text:      e.Text,
+			Sent:      e.Sent,
+			Edited:    e.Edited,
+			Reactions: reactions[key],
+		})
+	}
+	return messages
+}
+
+// Value returns the messages of the log as a []ChatMessage.
+func (c *ChatLog) Value() any {
+	return c.Messages()
+}
+
+// MarshalState encodes the order, content and reactions of the messages.
+func (c *ChatLog) MarshalState() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state := chatLogState{V: WireVersion}
+	var err error
+	if state.Order, err = c.order.MarshalState(); err != nil {
+		return nil, err
+	}
+	if state.Messages, err = c.messages.MarshalState(); err != nil {
+		return nil, err
+	}
+	if state.Reactions, err = c.reactions.MarshalState(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// the order, the content and the reactions. The result sums all three.
+func (c *ChatLog) MergeState(data []byte) (MergeResult, error) {
+	var state chatLogState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result MergeResult
+	for _, part := range []struct {
+		data  []byte
+		merge func([]byte) (MergeResult, error)
+	}{
+		{state.Order, c.order.MergeState},
+		{state.Messages, c.messages.MergeState},
+		{state.Reactions, c.reactions.MergeState},
+	} {
+		merged, err := part.merge(part.data)
+		result.Add(merged)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
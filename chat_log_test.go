@@ -0,0 +1,89 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+// syncChats merges the state of from into to.
+func syncChats(t *testing.T, from, to *ChatLog) {
+	t.Helper()
+	state, err := from.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chatTexts returns the texts of the messages of c.
+func chatTexts(c *ChatLog) []string {
+	var texts []string
+	for _, m := range c.Messages() {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestChatLog(t *testing.T) {
+	alice, bob := NewChatLog("alice"), NewChatLog("bob")
+	hi := alice.Post("alice", "hi")
+	syncChats(t, alice, bob)
+
+	// Concurrent posts both follow hi, in the same order everywhere.
+	alice.Post("alice", "lunch?")
+	bob.Post("bob", "hello")
+	syncChats(t, alice, bob)
+	syncChats(t, bob, alice)
+	a, b := chatTexts(alice), chatTexts(bob)
+	if len(a) != 3 || a[0] != "hi" || a[1] != b[1] || a[2] != b[2] {
+		t.Fatalf("Expected the same three messages, got %v and %v", a, b)
+	}
+
+	// Every replica reacts at most once per emoji.
+	for range 2 {
+		if err := alice.React(hi, "👍"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bob.React(hi, "👍"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.React(hi, "🎉"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Unreact(hi, "🎉"); err != nil {
+		t.Fatal(err)
+	}
+	syncChats(t, alice, bob)
+	syncChats(t, bob, alice)
+	for _, c := range []*ChatLog{alice, bob} {
+		reactions := c.Messages()[0].Reactions
+		if len(reactions) != 1 || reactions["👍"] != 2 {
+			t.Errorf("Expected two thumbs up, got %v", reactions)
+		}
+	}
+
+	// A deletion wins over a concurrent edit.
+	if err := alice.Edit(hi, "hi all"); err != nil {
+		t.Fatal(err)
+	}
+	if m := alice.Messages()[0]; m.Text != "hi all" || !m.Edited || m.Author != "alice" {
+		t.Errorf("Expected the edited message, got %+v", m)
+	}
+	if err := bob.Delete(hi); err != nil {
+		t.Fatal(err)
+	}
+	syncChats(t, alice, bob)
+	syncChats(t, bob, alice)
+	if len(alice.Messages()) != 2 || len(bob.Messages()) != 2 {
+		t.Errorf("Expected the deleted message gone, got %v and %v", chatTexts(alice), chatTexts(bob))
+	}
+	if err := alice.Edit(hi, "again"); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("Expected ErrUnknownNode, got %v", err)
+	}
+	if err := alice.React(ID{9, "z"}, "👍"); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("Expected ErrUnknownNode, got %v", err)
+	}
+}
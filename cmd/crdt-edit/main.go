@@ -0,0 +1,254 @@
+// Command crdt-edit is a collaborative line editor for one shared RGA
+// document, and a tour of the sync stack.
+//
+// One instance serves the document over WebSocket, the others connect to
+// it; every edit is replicated live to all of them, and instances that lose
+// the connection catch up when it comes back:
+//
+//	crdt-edit -id alice -listen :8080
+//	crdt-edit -id bob -connect ws://localhost:8080/doc
+//
+// Edits are typed as commands, with positions counted in visible
+// characters from 0:
+//
+//	a TEXT       append TEXT
+//	i POS TEXT   insert TEXT before position POS
+//	d POS [N]    delete N characters (default 1) from position POS
+//	p            print the document
+//	dump         print the document's internal structure
+//	q            quit
+//
+// The document is printed again whenever it changes, locally or remotely.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator/wsync"
+)
+
+// errQuit is returned by Exec for the quit command.
+var errQuit = errors.New("quit")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "crdt-edit:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the flags, starts the server or client and edits the document
+// with the commands read from in until quit, EOF or ctx is done.
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("crdt-edit", flag.ContinueOnError)
+	id := flags.String("id", "", "replica ID, unique among the editors (required)")
+	listen := flags.String("listen", "", "serve the document on this address, e.g. :8080")
+	connect := flags.String("connect", "", "connect to the editor serving the document, e.g. ws://localhost:8080/doc")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" || (*listen == "") == (*connect == "") {
+		return errors.New("-id and exactly one of -listen or -connect are required")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	doc := gocrdt.NewRGA(*id)
+	e := &editor{doc: doc, out: out}
+	config := wsync.Config{OnError: func(err error) { e.printf("sync: %v\n", err) }}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if *listen != "" {
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+		server := wsync.NewServer(doc, config)
+		mux := http.NewServeMux()
+		mux.Handle("/doc", server)
+		httpServer := &http.Server{Handler: mux}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = httpServer.Serve(ln)
+		}()
+		defer server.Close()
+		defer httpServer.Close()
+		e.printf("serving ws://%s/doc\n", ln.Addr())
+	} else {
+		client := wsync.NewClient(*connect, doc, config)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = client.Run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.watch(ctx)
+	}()
+	return e.readCommands(ctx, in, cancel)
+}
+
+// editor applies commands to a document and prints it.
+type editor struct {
+	doc *gocrdt.RGA
+
+	mu  sync.Mutex // serializes output
+	out io.Writer
+}
+
+func (e *editor) printf(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(e.out, format, args...)
+}
+
+// watch prints the document whenever it changes until ctx is done.
+func (e *editor) watch(ctx context.Context) {
+	for {
+		changed := e.doc.ChangeNotify()
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			e.printf("| %s\n", e.doc.Value())
+		}
+	}
+}
+
+// readCommands executes the lines of in until quit or EOF, then calls
+// cancel. Reading is abandoned when ctx is done.
+func (e *editor) readCommands(ctx context.Context, in io.Reader, cancel func()) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			err := e.Exec(line)
+			if errors.Is(err, errQuit) {
+				return nil
+			}
+			if err != nil {
+				e.printf("error: %v\n", err)
+			}
+		}
+	}
+}
+
+// Exec applies one command line to the document.
+func (e *editor) Exec(line string) error {
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch cmd {
+	case "":
+		return nil
+	case "a":
+		return e.insert(len(e.visible()), rest)
+	case "i":
+		posArg, text, _ := strings.Cut(rest, " ")
+		pos, err := strconv.Atoi(posArg)
+		if err != nil {
+			return fmt.Errorf("bad position %q", posArg)
+		}
+		return e.insert(pos, text)
+	case "d":
+		fields := strings.Fields(rest)
+		if len(fields) == 0 || len(fields) > 2 {
+			return errors.New("usage: d POS [N]")
+		}
+		pos, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("bad position %q", fields[0])
+		}
+		n := 1
+		if len(fields) == 2 {
+			if n, err = strconv.Atoi(fields[1]); err != nil || n < 1 {
+				return fmt.Errorf("bad count %q", fields[1])
+			}
+		}
+		return e.delete(pos, n)
+	case "p":
+		e.printf("| %s\n", e.doc.Value())
+		return nil
+	case "dump":
+		e.printf("%s", e.doc.DebugString())
+		return nil
+	case "q":
+		return errQuit
+	}
+	return fmt.Errorf("unknown command %q (a, i, d, p, dump, q)", cmd)
+}
+
+// visible returns the IDs of the visible characters, in order.
+func (e *editor) visible() []gocrdt.ID {
+	var ids []gocrdt.ID
+	for _, n := range e.doc.Nodes() {
+		if !n.Deleted {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+// insert types text before the visible position pos.
+func (e *editor) insert(pos int, text string) error {
+	ids := e.visible()
+	if pos < 0 || pos > len(ids) {
+		return fmt.Errorf("position %d out of range [0, %d]", pos, len(ids))
+	}
+	parent := gocrdt.ID{NodeID: "root"}
+	if pos > 0 {
+		parent = ids[pos-1]
+	}
+	for _, r := range text {
+		parent = e.doc.Insert(r, parent)
+	}
+	return nil
+}
+
+// delete removes n visible characters from position pos.
+func (e *editor) delete(pos, n int) error {
+	ids := e.visible()
+	if pos < 0 || pos+n > len(ids) {
+		return fmt.Errorf("range [%d, %d) out of [0, %d)", pos, pos+n, len(ids))
+	}
+	for _, id := range ids[pos : pos+n] {
+		e.doc.Delete(id)
+	}
+	return nil
+}
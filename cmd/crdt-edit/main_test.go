@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// instance is a running crdt-edit.
+type instance struct {
+	in   *io.PipeWriter
+	out  *syncBuffer
+	done chan error
+}
+
+func start(ctx context.Context, args ...string) *instance {
+	in, w := io.Pipe()
+	inst := &instance{in: w, out: &syncBuffer{}, done: make(chan error, 1)}
+	go func() { inst.done <- run(ctx, args, in, inst.out) }()
+	return inst
+}
+
+func (inst *instance) exec(t *testing.T, line string) {
+	t.Helper()
+	if _, err := io.WriteString(inst.in, line+"\n"); err != nil {
+		t.Fatalf("Writing %q: %v", line, err)
+	}
+}
+
+// waitFor polls the output of inst until it matches re.
+func (inst *instance) waitFor(t *testing.T, re *regexp.Regexp) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if m := re.FindStringSubmatch(inst.out.String()); m != nil {
+			return m
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %v in:\n%s", re, inst.out.String())
+	return nil
+}
+
+func TestCollaborativeEditing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	alice := start(ctx, "-id", "alice", "-listen", "127.0.0.1:0")
+	url := alice.waitFor(t, regexp.MustCompile(`serving (ws://\S+)`))[1]
+	bob := start(ctx, "-id", "bob", "-connect", url)
+
+	alice.exec(t, "a hello")
+	bob.waitFor(t, regexp.MustCompile(`\| hello\n`))
+	bob.exec(t, "a  world")
+	bob.exec(t, "d 0")
+	bob.exec(t, "i 0 H")
+	alice.waitFor(t, regexp.MustCompile(`\| Hello world\n`))
+
+	alice.exec(t, "d 99")
+	alice.waitFor(t, regexp.MustCompile(`error: range \[99, 100\) out of \[0, 11\)`))
+	bob.exec(t, "x")
+	bob.waitFor(t, regexp.MustCompile(`error: unknown command "x"`))
+
+	alice.exec(t, "dump")
+	alice.waitFor(t, regexp.MustCompile(`RGA alice: clock \d+, 12 nodes, 1 tombstones`))
+
+	bob.exec(t, "q")
+	alice.exec(t, "q")
+	for _, inst := range []*instance{alice, bob} {
+		if err := <-inst.done; err != nil {
+			t.Errorf("run failed: %v", err)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-listen", ":0"},
+		{"-id", "a"},
+		{"-id", "a", "-listen", ":0", "-connect", "ws://x"},
+	} {
+		err := run(context.Background(), args, strings.NewReader(""), io.Discard)
+		if err == nil {
+			t.Errorf("Expected %v to be rejected", args)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Command crdt-inspect prints what a document persisted by a
+// storage.FileStorage contains, for debugging production data without
+// starting a replica.
+//
+// Each argument is a document directory of the storage, whose snapshot,
+// delta snapshots and op log are replayed like storage.Restore would, or a
+// single file of one (a current, archived or delta snapshot, or an op
+// log). Files are only read, so torn or damaged records are reported
+// rather than repaired:
+//
+//	crdt-inspect data/ZG9j
+//	crdt-inspect -tombstones data/ZG9j/snapshot data/ZG9j/ops.log
+//	crdt-inspect -diff data/ZG9j/snapshot.00000000000000000120 data/ZG9j
+//
+// For every document it prints the records read, the type of the state
+// (RGA, GCounter or PNCounter), the text or value, its size and its
+// version vector: the latest Lamport timestamp of every replica for an
+// RGA, the slots of a counter. -tombstones lists the deleted RGA nodes and
+// -dump prints the internal structure of the document.
+//
+// With -diff, the two arguments are compared instead, and the exit status
+// is 1 when they differ.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// errDiffer is returned by run when the documents compared with -diff
+// differ.
+var errDiffer = errors.New("documents differ")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	switch {
+	case errors.Is(err, errDiffer):
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "crdt-inspect:", err)
+		os.Exit(2)
+	}
+}
+
+// run inspects, or with -diff compares, the documents named by args.
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("crdt-inspect", flag.ContinueOnError)
+	tombstones := flags.Bool("tombstones", false, "list the tombstones of RGA documents")
+	dump := flags.Bool("dump", false, "print the internal structure of RGA documents")
+	diff := flags.Bool("diff", false, "compare two documents")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	paths := flags.Args()
+	switch {
+	case *diff && len(paths) != 2:
+		return errors.New("-diff takes exactly two documents")
+	case len(paths) == 0:
+		return errors.New("no document or file given")
+	}
+
+	docs := make([]*document, len(paths))
+	for i, path := range paths {
+		doc, err := load(path)
+		if err != nil {
+			return err
+		}
+		docs[i] = doc
+	}
+	if *diff {
+		return compare(out, docs[0], docs[1])
+	}
+	for i, doc := range docs {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		doc.print(out, *tombstones, *dump)
+	}
+	return nil
+}
+
+// document is a state rebuilt from the records of one or more files.
+type document struct {
+	path     string
+	key      string // storage key, for document directories
+	files    []file
+	kind     string // "RGA", "GCounter" or "PNCounter"; empty without records
+	state    gocrdt.Replicable
+	result   gocrdt.MergeResult
+	warnings []string
+}
+
+// file summarizes the records read from one file.
+type file struct {
+	name     string
+	records  int
+	from, to uint64 // seqs of the first and last record
+}
+
+// load rebuilds the document stored at path, a document directory or a
+// single file. Applying records a later snapshot already covers is
+// harmless, merges being idempotent. Damaged records become warnings: what
+// precedes them is still inspected.
+func load(path string) (*document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{path: path}
+	files := []string{path}
+	if info.IsDir() {
+		if key, err := base64.RawURLEncoding.DecodeString(filepath.Base(path)); err == nil {
+			doc.key = string(key)
+		}
+		if files, err = storage.DocumentFiles(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range files {
+		records, err := storage.ReadFile(name)
+		if errors.Is(err, storage.ErrCorrupt) {
+			doc.warnings = append(doc.warnings, err.Error())
+		} else if err != nil {
+			return nil, err
+		}
+		f := file{name: name, records: len(records)}
+		if len(records) > 0 {
+			f.from, f.to = records[0].Seq, records[len(records)-1].Seq
+		}
+		doc.files = append(doc.files, f)
+		for _, record := range records {
+			if err := doc.merge(record.Data); err != nil {
+				return nil, fmt.Errorf("%s: record with seq %d: %w", name, record.Seq, err)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// merge applies one record, creating the state on the first one.
+func (d *document) merge(data []byte) error {
+	kind, err := detectKind(data)
+	if err != nil {
+		return err
+	}
+	switch {
+	case d.kind == "":
+		d.kind = kind
+		switch kind {
+		case "RGA":
+			d.state = gocrdt.NewRGA("crdt-inspect")
+		case "GCounter":
+			d.state = gocrdt.NewGCounter("crdt-inspect")
+		case "PNCounter":
+			d.state = gocrdt.NewPNCounter("crdt-inspect")
+		}
+	case kind != d.kind:
+		return fmt.Errorf("%s payload in a %s document", kind, d.kind)
+	}
+	result, err := d.state.MergeState(data)
+	d.result.Add(result)
+	return err
+}
+
+// detectKind returns the type whose MarshalState produced data, in any
+// format version gocrdt reads: a list of nodes for an RGA, an object of
+// slots for a GCounter, and an object of two slot objects for a PNCounter.
+// Only the fields are told apart; merging the payload checks its version.
+func detectKind(data []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		if _, err := gocrdt.DecodeNodes(data); err != nil {
+			return "", fmt.Errorf("unknown payload %.40q", data)
+		}
+		return "RGA", nil
+	}
+	_, versioned := fields["v"]
+	_, hasNodes := fields["nodes"]
+	_, hasSlots := fields["slots"]
+	_, hasP := fields["p"]
+	_, hasN := fields["n"]
+	var slots map[string]int
+	switch {
+	case hasP && hasN:
+		return "PNCounter", nil
+	case versioned && hasNodes:
+		return "RGA", nil
+	case versioned && hasSlots:
+		return "GCounter", nil
+	case json.Unmarshal(data, &slots) == nil:
+		return "GCounter", nil
+	}
+	return "", fmt.Errorf("unknown payload %.40q", data)
+}
+
+func (d *document) print(out io.Writer, tombstones, dump bool) {
+	fmt.Fprintf(out, "%s\n", d.path)
+	if d.key != "" {
+		fmt.Fprintf(out, "key:      %q\n", d.key)
+	}
+	for _, f := range d.files {
+		fmt.Fprintf(out, "file:     %s, %s\n", filepath.Base(f.name), f.summary())
+	}
+	for _, w := range d.warnings {
+		fmt.Fprintf(out, "warning:  %s\n", w)
+	}
+	if d.result.Rejected > 0 {
+		fmt.Fprintf(out, "warning:  %d malformed entries rejected\n", d.result.Rejected)
+	}
+	if d.kind == "" {
+		fmt.Fprintln(out, "empty")
+		return
+	}
+	fmt.Fprintf(out, "type:     %s\n", d.kind)
+
+	switch state := d.state.(type) {
+	case *gocrdt.RGA:
+		stats := state.Stats()
+		fmt.Fprintf(out, "text:     %q\n", state.Value())
+		fmt.Fprintf(out, "nodes:    %d (%d tombstones, %.0f%%), %d orphans\n",
+			stats.Nodes, stats.Tombstones, 100*stats.TombstoneRatio(), stats.Orphans)
+		fmt.Fprintf(out, "vector:   %s\n", formatVector(lamportVector(state.Nodes())))
+		if tombstones {
+			for _, n := range state.Nodes() {
+				if n.Deleted {
+					fmt.Fprintf(out, "deleted:  %s %q after %s\n", formatID(n.ID), n.Value, formatID(n.ParentID))
+				}
+			}
+		}
+		if dump {
+			fmt.Fprint(out, state.DebugString())
+		}
+	case *gocrdt.GCounter:
+		fmt.Fprintf(out, "value:    %d\n", state.Value())
+		fmt.Fprintf(out, "vector:   %s\n", formatVector(state.Slots()))
+	case *gocrdt.PNCounter:
+		p, n := state.Slots()
+		fmt.Fprintf(out, "value:    %d\n", state.Value())
+		fmt.Fprintf(out, "vector:   +%s, -%s\n", formatVector(p), formatVector(n))
+	}
+}
+
+func (f file) summary() string {
+	switch f.records {
+	case 0:
+		return "no records"
+	case 1:
+		return fmt.Sprintf("1 record, seq %d", f.from)
+	}
+	return fmt.Sprintf("%d records, seq %d-%d", f.records, f.from, f.to)
+}
+
+// lamportVector returns the latest timestamp of every replica that
+// inserted nodes.
+func lamportVector(nodes []gocrdt.Node) map[string]int {
+	vector := make(map[string]int)
+	for _, n := range nodes {
+		vector[n.ID.NodeID] = max(vector[n.ID.NodeID], int(n.ID.Timestamp))
+	}
+	return vector
+}
+
+// formatVector renders a vector as "a:1 b:2", sorted by replica.
+func formatVector(vector map[string]int) string {
+	if len(vector) == 0 {
+		return "{}"
+	}
+	parts := make([]string, 0, len(vector))
+	for _, id := range slices.Sorted(maps.Keys(vector)) {
+		parts = append(parts, fmt.Sprintf("%s:%d", id, vector[id]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatID renders an ID as "ts@node", like RGA.DebugString.
+func formatID(id gocrdt.ID) string {
+	if id == (gocrdt.ID{NodeID: "root"}) {
+		return "root"
+	}
+	return fmt.Sprintf("%d@%s", id.Timestamp, id.NodeID)
+}
+
+// compare prints the differences from a to b and returns errDiffer if
+// there are any. RGA nodes only in a are marked "-", only in b "+", and
+// nodes deleted on one side only "~".
+func compare(out io.Writer, a, b *document) error {
+	if a.kind != b.kind {
+		fmt.Fprintf(out, "type: %s vs %s\n", orEmpty(a.kind), orEmpty(b.kind))
+		return errDiffer
+	}
+	var lines []string
+	switch state := a.state.(type) {
+	case *gocrdt.RGA:
+		lines = diffNodes(state, b.state.(*gocrdt.RGA))
+	case *gocrdt.GCounter:
+		lines = diffSlots("", state.Slots(), b.state.(*gocrdt.GCounter).Slots())
+	case *gocrdt.PNCounter:
+		pa, na := state.Slots()
+		pb, nb := b.state.(*gocrdt.PNCounter).Slots()
+		lines = append(diffSlots("+", pa, pb), diffSlots("-", na, nb)...)
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(out, "identical")
+		return nil
+	}
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+	return errDiffer
+}
+
+func orEmpty(kind string) string {
+	if kind == "" {
+		return "empty"
+	}
+	return kind
+}
+
+// diffNodes lists the node differences from a to b in document order, then
+// the texts if they differ.
+func diffNodes(a, b *gocrdt.RGA) []string {
+	var lines []string
+	for _, n := range a.Nodes() {
+		other, ok := b.Lookup(n.ID)
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("- %s %q after %s", formatID(n.ID), n.Value, formatID(n.ParentID)))
+		case n.Deleted && !other.Deleted:
+			lines = append(lines, fmt.Sprintf("~ %s %q deleted in a only", formatID(n.ID), n.Value))
+		case !n.Deleted && other.Deleted:
+			lines = append(lines, fmt.Sprintf("~ %s %q deleted in b only", formatID(n.ID), n.Value))
+		}
+	}
+	for _, n := range b.Nodes() {
+		if _, ok := a.Lookup(n.ID); !ok {
+			lines = append(lines, fmt.Sprintf("+ %s %q after %s", formatID(n.ID), n.Value, formatID(n.ParentID)))
+		}
+	}
+	if textA, textB := a.Value(), b.Value(); textA != textB {
+		lines = append(lines, fmt.Sprintf("text a: %q", textA), fmt.Sprintf("text b: %q", textB))
+	}
+	return lines
+}
+
+// diffSlots lists the counter slots that differ, prefixed with sign.
+func diffSlots(sign string, a, b map[string]int) []string {
+	var lines []string
+	ids := maps.Clone(a)
+	maps.Copy(ids, b)
+	for _, id := range slices.Sorted(maps.Keys(ids)) {
+		if a[id] != b[id] {
+			lines = append(lines, fmt.Sprintf("%s%s: %d vs %d", sign, id, a[id], b[id]))
+		}
+	}
+	return lines
+}
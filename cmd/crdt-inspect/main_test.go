@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// inspect runs crdt-inspect and returns its output.
+func inspect(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := run(args, &out)
+	return out.String(), err
+}
+
+func expectLines(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, out)
+		}
+	}
+}
+
+// writeDoc persists an RGA "hey" (with a deleted "x") under key "doc": a
+// snapshot of "he", archived at seq 1, and an op log with the rest.
+func writeDoc(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	doc := gocrdt.NewRGA("alice")
+	wal, err := storage.OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots := storage.NewSnapshotter(wal, storage.SnapshotConfig{EveryOps: 1000, Retain: 1})
+
+	h := doc.Insert('h', gocrdt.ID{NodeID: "root"})
+	e := doc.Insert('e', h)
+	if _, err := snapshots.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	x := doc.Insert('x', e)
+	doc.Delete(x)
+	doc.Merge([]gocrdt.Node{{ID: gocrdt.ID{Timestamp: 7, NodeID: "bob"}, ParentID: x, Value: 'y'}})
+	if _, err := wal.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "ZG9j") // base64 of "doc"
+}
+
+func TestInspect_Document(t *testing.T) {
+	dir := writeDoc(t)
+	out, err := inspect(t, "-tombstones", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, out,
+		`key:      "doc"`,
+		"file:     snapshot, 1 record, seq 1",
+		"file:     ops.log, 1 record, seq 2",
+		"type:     RGA",
+		`text:     "hey"`,
+		"nodes:    4 (1 tombstones, 25%), 0 orphans",
+		"vector:   alice:3 bob:7",
+		`deleted:  3@alice 'x' after 2@alice`,
+	)
+
+	// A single file only holds part of the document.
+	out, err = inspect(t, filepath.Join(dir, "snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, out, `text:     "he"`, "vector:   alice:2")
+}
+
+func TestInspect_TornLog(t *testing.T) {
+	dir := writeDoc(t)
+	log := filepath.Join(dir, "ops.log")
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(log, data[:len(data)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := inspect(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, out, "file:     ops.log, no records", `text:     "he"`)
+	if !strings.Contains(out, "warning:  storage: corrupt data: "+log+": record 1") {
+		t.Errorf("Expected a warning about the torn record in:\n%s", out)
+	}
+	if after, _ := os.ReadFile(log); len(after) != len(data)-3 {
+		t.Errorf("Expected the log to be left as is, got %d bytes", len(after))
+	}
+}
+
+func TestInspect_Diff(t *testing.T) {
+	dir := writeDoc(t)
+	archived := filepath.Join(dir, "snapshot.00000000000000000001")
+	out, err := inspect(t, "-diff", archived, dir)
+	if !errors.Is(err, errDiffer) {
+		t.Fatalf("Expected errDiffer, got %v", err)
+	}
+	expectLines(t, out,
+		`+ 3@alice 'x' after 2@alice`,
+		`+ 7@bob 'y' after 3@alice`,
+		`text a: "he"`,
+		`text b: "hey"`,
+	)
+
+	if out, err := inspect(t, "-diff", dir, dir); err != nil || out != "identical\n" {
+		t.Errorf("Expected identical documents, got %q, %v", out, err)
+	}
+}
+
+func TestInspect_Counters(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	a, b := gocrdt.NewPNCounter("a"), gocrdt.NewPNCounter("b")
+	a.Increment()
+	a.Increment()
+	b.Decrement()
+	stateA, _ := a.MarshalState()
+	stateB, _ := b.MarshalState()
+	if err := s.SaveSnapshot("a", 0, stateA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AppendOps("b", stateA, stateB); err != nil {
+		t.Fatal(err)
+	}
+
+	docA, docB := filepath.Join(dir, "YQ"), filepath.Join(dir, "Yg")
+	out, err := inspect(t, docB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, out, "type:     PNCounter", "value:    1", "vector:   +a:2, -b:1")
+
+	out, err = inspect(t, "-diff", docA, docB)
+	if !errors.Is(err, errDiffer) || out != "-b: 0 vs 1\n" {
+		t.Errorf("Unexpected diff %q, %v", out, err)
+	}
+}
+
+func TestInspect_Errors(t *testing.T) {
+	junk := filepath.Join(t.TempDir(), "junk")
+	if err := os.WriteFile(junk, []byte("not a record"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{},
+		{"-diff", junk},
+		{"-unknown", junk},
+		{filepath.Join(t.TempDir(), "missing")},
+		{t.TempDir()},
+	} {
+		if _, err := inspect(t, args...); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Command crdt-replay replays the op logs of two replicas of an RGA
+// document step by step, to find out how their texts came to differ, e.g.
+// after a "my text got scrambled" report.
+//
+// Each argument is a document directory of a storage.FileStorage, whose
+// snapshot, delta snapshots and op log are replayed in that order, or a
+// single file of one. Every record is one step; the steps of both replicas
+// are interleaved and the text after each one is printed:
+//
+//	crdt-replay data-alice/ZG9j data-bob/ZG9j
+//	crdt-replay -step alice/ops.log bob/ops.log
+//
+// With -step, the replay pauses after every step until Enter is pressed
+// (q quits).
+//
+// Replicas log what they merge from each other, so whenever one replica
+// holds exactly the nodes the other held at some step, both must show the
+// same text. The first step where they do not is the point of divergence,
+// and the replay stops there with the positions that differ. Otherwise,
+// once both logs are replayed, the final states are merged into each other
+// to check that the replicas converge.
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/crdttest"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// errDiverged is returned by run when the replicas diverged.
+var errDiverged = errors.New("replicas diverged")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	switch {
+	case errors.Is(err, errDiverged):
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "crdt-replay:", err)
+		os.Exit(2)
+	}
+}
+
+// run replays the logs named by args, reading the keystrokes of -step
+// from in.
+func run(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("crdt-replay", flag.ContinueOnError)
+	step := flags.Bool("step", false, "pause after every step until Enter is pressed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("two op logs are required")
+	}
+
+	replicas := make([]*replica, 2)
+	for i, path := range flags.Args() {
+		records, err := readLog(path)
+		if err != nil {
+			return err
+		}
+		replicas[i] = &replica{name: string(rune('a' + i)), path: path, records: records, doc: gocrdt.NewRGA("crdt-replay")}
+		fmt.Fprintf(out, "%s: %s, %d steps\n", replicas[i].name, path, len(records))
+	}
+
+	keys := bufio.NewScanner(in)
+	for turn := 0; !replicas[0].done() || !replicas[1].done(); turn++ {
+		r, other := replicas[turn%2], replicas[(turn+1)%2]
+		if r.done() {
+			continue
+		}
+		if err := r.step(out); err != nil {
+			return err
+		}
+		if err := r.checkAgainst(other, out); err != nil {
+			return err
+		}
+		if *step && (!keys.Scan() || strings.TrimSpace(keys.Text()) == "q") {
+			return nil
+		}
+	}
+	return converge(replicas[0], replicas[1], out)
+}
+
+// readLog returns the records of a document directory or a single file.
+func readLog(path string) ([]storage.Op, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = storage.DocumentFiles(path); err != nil {
+			return nil, err
+		}
+	}
+	var records []storage.Op
+	for _, name := range files {
+		ops, err := storage.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, ops...)
+	}
+	return records, nil
+}
+
+// replica replays the log of one replica.
+type replica struct {
+	name    string
+	path    string
+	records []storage.Op
+	doc     *gocrdt.RGA
+	steps   int
+
+	// seen holds the linearized order of every node set this replica
+	// went through, by the fingerprint of the set.
+	seen map[[sha256.Size]byte]snapshot
+}
+
+// snapshot is the state of a replica after a step.
+type snapshot struct {
+	step  int
+	order []gocrdt.ID
+}
+
+func (r *replica) done() bool {
+	return r.steps == len(r.records)
+}
+
+// step applies the next record and prints the resulting text.
+func (r *replica) step(out io.Writer) error {
+	record := r.records[r.steps]
+	r.steps++
+	result, err := r.doc.MergeState(record.Data)
+	if err != nil {
+		return fmt.Errorf("%s: step %d (seq %d): %w", r.path, r.steps, record.Seq, err)
+	}
+	fmt.Fprintf(out, "%s#%-4d seq %-6d +%d ~%d", r.name, r.steps, record.Seq, result.Applied, result.Deleted)
+	if result.Orphaned > 0 {
+		fmt.Fprintf(out, " (%d orphans)", result.Orphaned)
+	}
+	if result.Rejected > 0 {
+		fmt.Fprintf(out, " (%d rejected)", result.Rejected)
+	}
+	fmt.Fprintf(out, "  %q\n", r.doc.Value())
+
+	if r.seen == nil {
+		r.seen = make(map[[sha256.Size]byte]snapshot)
+	}
+	sum, order := fingerprint(r.doc.Nodes())
+	if _, ok := r.seen[sum]; !ok {
+		r.seen[sum] = snapshot{step: r.steps, order: order}
+	}
+	return nil
+}
+
+// checkAgainst compares the current state of r with the states other went
+// through holding the same nodes. It reports a divergence when their
+// orders differ.
+func (r *replica) checkAgainst(other *replica, out io.Writer) error {
+	sum, order := fingerprint(r.doc.Nodes())
+	then, ok := other.seen[sum]
+	if !ok {
+		return nil
+	}
+	pos := 0
+	for pos < len(order) && order[pos] == then.order[pos] {
+		pos++
+	}
+	if pos == len(order) {
+		return nil
+	}
+	fmt.Fprintf(out, "\ndiverged: %s after step %d and %s after step %d hold the same %d nodes in different orders\n",
+		r.name, r.steps, other.name, then.step, len(order))
+	fmt.Fprintf(out, "first difference at position %d: %s has %s, %s has %s\n",
+		pos, r.name, formatID(order[pos]), other.name, formatID(then.order[pos]))
+	return errDiverged
+}
+
+// fingerprint returns a digest of the set of nodes, with their tombstones,
+// and their IDs in document order.
+func fingerprint(nodes []gocrdt.Node) ([sha256.Size]byte, []gocrdt.ID) {
+	order := make([]gocrdt.ID, len(nodes))
+	keys := make([]string, len(nodes))
+	for i, n := range nodes {
+		order[i] = n.ID
+		keys[i] = fmt.Sprintf("%s %t", formatID(n.ID), n.Deleted)
+	}
+	slices.Sort(keys)
+	return sha256.Sum256([]byte(strings.Join(keys, "\n"))), order
+}
+
+// formatID renders an ID as "ts@node", like RGA.DebugString.
+func formatID(id gocrdt.ID) string {
+	return fmt.Sprintf("%d@%s", id.Timestamp, id.NodeID)
+}
+
+// converge merges the final states of a and b into each other and reports
+// whether they end up identical.
+func converge(a, b *replica, out io.Writer) error {
+	missing := func(from, in *replica) int {
+		count := 0
+		for _, n := range from.doc.Nodes() {
+			if _, ok := in.doc.Lookup(n.ID); !ok {
+				count++
+			}
+		}
+		return count
+	}
+	fmt.Fprintf(out, "\nreplayed: %s lacks %d nodes of %s, %s lacks %d nodes of %s\n",
+		a.name, missing(b, a), b.name, b.name, missing(a, b), a.name)
+
+	err := crdttest.CheckConvergence(a.doc, b.doc)
+	var divergence *crdttest.DivergenceError
+	if errors.As(err, &divergence) {
+		fmt.Fprintf(out, "diverged after merging: %v\n", err)
+		return errDiverged
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "converged after merging: %q\n", a.doc.Value())
+	return nil
+}
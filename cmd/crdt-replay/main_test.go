@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// logged is a replica whose changes are logged in its own FileStorage.
+type logged struct {
+	doc *gocrdt.RGA
+	wal *storage.WAL
+	dir string
+}
+
+func newLogged(t *testing.T, id string) *logged {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	doc := gocrdt.NewRGA(id)
+	wal, err := storage.OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &logged{doc: doc, wal: wal, dir: filepath.Join(dir, "ZG9j")}
+}
+
+func (l *logged) commit(t *testing.T) {
+	t.Helper()
+	if _, err := l.wal.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// insert inserts val after parent and logs it.
+func (l *logged) insert(t *testing.T, val rune, parent gocrdt.ID) {
+	t.Helper()
+	l.doc.Insert(val, parent)
+	l.commit(t)
+}
+
+// syncFrom merges the state of other and logs it.
+func (l *logged) syncFrom(t *testing.T, other *logged) {
+	t.Helper()
+	state, _ := other.doc.MarshalState()
+	if _, err := l.doc.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	l.commit(t)
+}
+
+func replay(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := run(args, strings.NewReader(input), &out)
+	return out.String(), err
+}
+
+func TestReplay_Converged(t *testing.T) {
+	alice, bob := newLogged(t, "alice"), newLogged(t, "bob")
+	h := alice.doc.Insert('h', gocrdt.ID{NodeID: "root"})
+	alice.commit(t)
+	bob.syncFrom(t, alice)
+	bob.insert(t, 'i', h)
+	alice.insert(t, 'o', h)
+	alice.syncFrom(t, bob)
+	bob.syncFrom(t, alice)
+
+	out, err := replay(t, "", alice.dir, bob.dir)
+	if err != nil {
+		t.Fatalf("Expected no divergence, got %v:\n%s", err, out)
+	}
+	for _, line := range []string{
+		"a: " + alice.dir + ", 3 steps",
+		`a#1    seq 1      +1 ~0  "h"`,
+		`b#3    seq 3      +1 ~0  "hio"`,
+		"replayed: a lacks 0 nodes of b, b lacks 0 nodes of a",
+		`converged after merging: "hio"`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestReplay_Diverged(t *testing.T) {
+	// bob's log claims alice's 'e' was typed at the start of the document:
+	// same nodes, different texts.
+	alice, bob := newLogged(t, "alice"), newLogged(t, "bob")
+	h := alice.doc.Insert('h', gocrdt.ID{NodeID: "root"})
+	alice.doc.Insert('e', h)
+	alice.commit(t)
+	bob.doc.Merge([]gocrdt.Node{
+		{ID: h, ParentID: gocrdt.ID{NodeID: "root"}, Value: 'h'},
+		{ID: gocrdt.ID{Timestamp: 2, NodeID: "alice"}, ParentID: gocrdt.ID{NodeID: "root"}, Value: 'e'},
+	})
+	bob.commit(t)
+
+	out, err := replay(t, "", alice.dir, bob.dir)
+	if !errors.Is(err, errDiverged) {
+		t.Fatalf("Expected errDiverged, got %v:\n%s", err, out)
+	}
+	if !strings.Contains(out, "diverged: b after step 1 and a after step 1 hold the same 2 nodes in different orders\n"+
+		"first difference at position 0: b has 2@alice, a has 1@alice\n") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+}
+
+func TestReplay_Step(t *testing.T) {
+	alice, bob := newLogged(t, "alice"), newLogged(t, "bob")
+	root := gocrdt.ID{NodeID: "root"}
+	for _, c := range "abc" {
+		alice.insert(t, c, root)
+		bob.insert(t, c, root)
+	}
+
+	out, err := replay(t, "\n\nq\n", "-step", alice.dir, bob.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps := strings.Count(out, " seq "); steps != 3 {
+		t.Errorf("Expected the replay to quit after 3 steps, got %d:\n%s", steps, out)
+	}
+
+	if _, err := replay(t, "", alice.dir); err == nil {
+		t.Error("Expected a single log to be rejected")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build js && wasm
+
+// Command crdt-wasm is the WebAssembly module of the bindings of package
+// wasm, registered as the global object gocrdt:
+//
+//	GOOS=js GOARCH=wasm go build -o crdt.wasm ./cmd/crdt-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// and in the page, after loading wasm_exec.js:
+//
+//	const go = new Go();
+//	const { instance } = await WebAssembly.instantiateStreaming(fetch("crdt.wasm"), go.importObject);
+//	go.run(instance);
+//	const doc = gocrdt.newDocument("alice");
+package main
+
+import "github.com/cshekharsharma/go-crdt/wasm"
+
+func main() {
+	wasm.Register("gocrdt")
+	select {}
+}
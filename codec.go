@@ -0,0 +1,112 @@
+package gocrdt
+
+// pnCounterState is the wire representation of a PNCounter.
+type pnCounterState struct {
+	V int            `json:"v"`
+	P map[string]int `json:"p"`
+	N map[string]int `json:"n"`
+}
+
+// MarshalState encodes the full slot vector of the counter so it can be
+// shipped to another replica and applied with MergeState.
+func (c *UnsyncGCounter) MarshalState() ([]byte, error) {
+	return encodeSlots(c.codec, c.slots)
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter.
+func (c *UnsyncGCounter) MergeState(data []byte) (MergeResult, error) {
+	slots, err := decodeSlots(c.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(slots), nil
+}
+
+// MarshalState encodes the full slot vector of the counter so it can be
+// shipped to another replica and applied with MergeState.
+func (c *GCounter) MarshalState() ([]byte, error) {
+	return encodeSlots(c.codec, c.Slots())
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter.
+func (c *GCounter) MergeState(data []byte) (MergeResult, error) {
+	slots, err := decodeSlots(c.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(slots), nil
+}
+
+// MarshalState encodes both slot vectors of the counter.
+func (c *UnsyncPNCounter) MarshalState() ([]byte, error) {
+	return c.pCounter.codec.Marshal(pnCounterState{V: WireVersion, P: c.pCounter.slots, N: c.nCounter.slots})
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter.
+func (c *UnsyncPNCounter) MergeState(data []byte) (MergeResult, error) {
+	state, err := decodePN(c.pCounter.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(state.P, state.N), nil
+}
+
+// MarshalState encodes both slot vectors of the counter.
+func (c *PNCounter) MarshalState() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.MarshalState()
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter.
+func (c *PNCounter) MergeState(data []byte) (MergeResult, error) {
+	state, err := decodePN(c.counter.pCounter.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(state.P, state.N), nil
+}
+
+// MarshalState encodes every node of the document (including tombstones)
+// in causal order.
+func (r *UnsyncRGA) MarshalState() ([]byte, error) {
+	return r.encodeNodes(r.Nodes(), r.tieBreak)
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this document.
+func (r *UnsyncRGA) MergeState(data []byte) (MergeResult, error) {
+	nodes, tieBreak, err := r.decodeNodes(data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return r.mergePayload(nodes, tieBreak)
+}
+
+// MarshalState encodes every node of the document (including tombstones)
+// in causal order.
+func (r *RGA) MarshalState() ([]byte, error) {
+	r.mu.RLock()
+	nodes, tieBreak := r.doc.Nodes(), r.doc.tieBreak
+	r.mu.RUnlock()
+	return r.doc.encodeNodes(nodes, tieBreak)
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this document. Decoding happens before the write lock
+// is taken. A payload of a document with another TieBreak is rejected with
+// ErrTieBreakMismatch, unless this document is empty and adopts it; the
+// check and the merge happen under the same write lock.
+func (r *RGA) MergeState(data []byte) (MergeResult, error) {
+	nodes, tieBreak, err := r.doc.decodeNodes(data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return r.merge(len(nodes), func() (MergeResult, error) {
+		return r.doc.mergePayload(nodes, tieBreak)
+	})
+}
@@ -0,0 +1,94 @@
+package gocrdt
+
+import "testing"
+
+func TestCodec_GCounterRoundTrip(t *testing.T) {
+	nodeA := NewGCounter("node-a")
+	nodeB := NewGCounter("node-b")
+	nodeA.Increment()
+	nodeA.Increment()
+
+	data, err := nodeA.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	result, err := nodeB.MergeState(data)
+	if err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if nodeB.Value() != 2 || result.Applied != 1 {
+		t.Errorf("Expected value 2 with 1 applied slot, got %d (%+v)", nodeB.Value(), result)
+	}
+}
+
+func TestCodec_PNCounterRoundTrip(t *testing.T) {
+	nodeA := NewPNCounter("node-a")
+	nodeB := NewPNCounter("node-b")
+	nodeA.Increment()
+	nodeA.Decrement()
+	nodeA.Decrement()
+
+	data, err := nodeA.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	if _, err := nodeB.MergeState(data); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if nodeB.Value() != -1 {
+		t.Errorf("Expected -1, got %d", nodeB.Value())
+	}
+}
+
+func TestCodec_RGARoundTrip(t *testing.T) {
+	alice := NewRGA("alice")
+	bob := NewRGA("bob")
+	idH := alice.Insert('H', ID{0, "root"})
+	idX := alice.Insert('x', idH)
+	alice.Insert('i', idX)
+	alice.Delete(idX)
+
+	data, err := alice.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	result, err := bob.MergeState(data)
+	if err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if bob.Value() != "Hi" {
+		t.Errorf("Expected Hi, got %s", bob.Value())
+	}
+	if result.Orphaned != 0 {
+		t.Errorf("Causally ordered state should not produce orphans, got %+v", result)
+	}
+}
+
+func TestCodec_MalformedPayload(t *testing.T) {
+	if _, err := NewGCounter("a").MergeState([]byte("{")); err == nil {
+		t.Error("Expected error for malformed GCounter payload")
+	}
+	if _, err := NewRGA("a").MergeState([]byte("not json")); err == nil {
+		t.Error("Expected error for malformed RGA payload")
+	}
+}
+
+func TestCodec_MixedFlavours(t *testing.T) {
+	local := NewUnsyncRGA("alice")
+	remote := NewRGA("bob")
+	local.Insert('A', ID{0, "root"})
+
+	var _ Replicable = local
+	var _ Replicable = remote
+
+	data, err := local.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	if _, err := remote.MergeState(data); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if remote.Value() != "A" {
+		t.Errorf("Expected A, got %s", remote.Value())
+	}
+}
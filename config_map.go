@@ -0,0 +1,397 @@
+package gocrdt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ConfigValidator checks a value written to the key of a ConfigMap. A
+// non-nil error refuses it.
+type ConfigValidator[V any] func(key string, value V) error
+
+// Quarantined is a remote value a ConfigMap refused to apply because its
+// ConfigValidator failed. It is kept, and reported, until a newer value of
+// the key is applied.
+type Quarantined[V any] struct {
+	Key    string
+	Value  V
+	Clock  int64
+	NodeID string
+	Err    error
+}
+
+// configEntry is the wire representation of the last write of a key.
+type configEntry[V any] struct {
+	Key     string `json:"key"`
+	Value   V      `json:"value"`
+	Clock   int64  `json:"t"`
+	NodeID  string `json:"node"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// newer reports whether e was written after other: with a later clock, or
+// the same clock on a greater NodeID.
+func (e configEntry[V]) newer(other configEntry[V]) bool {
+	if e.Clock != other.Clock {
+		return e.Clock > other.Clock
+	}
+	return e.NodeID > other.NodeID
+}
+
+// configMapState is the wire representation of a ConfigMap.
+type configMapState[V any] struct {
+	V        int              `json:"v"`
+	Resolver string           `json:"resolver,omitempty"`
+	Entries  []configEntry[V] `json:"entries"`
+}
+
+// UnsyncConfigMap is the unsynchronized core of a ConfigMap.
+//
+// Like the other Unsync types it performs no locking and must be owned by a
+// single goroutine; use ConfigMap when the map is shared between goroutines.
+type UnsyncConfigMap[V any] struct {
+	nodeID       string
+	clock        int64 // Lamport clock of the writes
+	entries      map[string]configEntry[V]
+	validate     ConfigValidator[V]
+	quarantine   map[string]Quarantined[V]
+	onQuarantine func(Quarantined[V])
+	codec        Codec            // See WithCodec
+	journal      *ConflictJournal // See WithConflictJournal
+	limit        valueLimit       // See WithMaxValueSize
+
+	resolver configResolver[V] // See WithResolver
+}
+
+// NewUnsyncConfigMap creates an empty UnsyncConfigMap for the replica
+// nodeID, checking every value with validate (nil accepts all). Of the
+// options, WithClock, WithCodec, WithConflictJournal, WithMaxValueSize and
+// WithResolver apply.
+func NewUnsyncConfigMap[V any](nodeID string, validate ConfigValidator[V], opts ...Option) *UnsyncConfigMap[V] {
+	o := newOptions(opts)
+	resolver, _ := o.resolver.(configResolver[V])
+	return &UnsyncConfigMap[V]{
+		nodeID:     nodeID,
+		clock:      o.clock,
+		entries:    make(map[string]configEntry[V]),
+		validate:   validate,
+		quarantine: make(map[string]Quarantined[V]),
+		codec:      o.codec,
+		journal:    o.journal,
+		limit:      newValueLimit(o),
+		resolver:   resolver,
+	}
+}
+
+// Set writes value to key. See ConfigMap.Set.
+func (m *UnsyncConfigMap[V]) Set(key string, value V) error {
+	value, err := m.check(key, value)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrRejected, key, err)
+	}
+	e := configEntry[V]{Key: key, Value: value}
+	if m.superseded(e) {
+		return fmt.Errorf("%w: %q", ErrSuperseded, key)
+	}
+	m.write(e)
+	return nil
+}
+
+// check returns value as written to key, truncated under SizeTruncate, or
+// the error refusing it: over WithMaxValueSize or failing the validator.
+func (m *UnsyncConfigMap[V]) check(key string, value V) (V, error) {
+	value, err := fitValue(m.limit, value)
+	if err == nil && m.validate != nil {
+		err = m.validate(key, value)
+	}
+	return value, err
+}
+
+// Delete removes key. See ConfigMap.Delete.
+func (m *UnsyncConfigMap[V]) Delete(key string) {
+	e := configEntry[V]{Key: key, Deleted: true}
+	if _, ok := m.Get(key); ok && !m.superseded(e) {
+		m.write(e)
+	}
+}
+
+// superseded reports whether the write e, stamped now, would lose to the
+// current value of its key. Only a resolver can make it lose.
+func (m *UnsyncConfigMap[V]) superseded(e configEntry[V]) bool {
+	local, ok := m.entries[e.Key]
+	if !ok || m.resolver.compare == nil {
+		return false
+	}
+	e.Clock, e.NodeID = m.clock+1, m.nodeID
+	return m.newer(local, e)
+}
+
+// write stamps e as the newest write of its key.
+func (m *UnsyncConfigMap[V]) write(e configEntry[V]) {
+	m.clock++
+	e.Clock, e.NodeID = m.clock, m.nodeID
+	m.entries[e.Key] = e
+	delete(m.quarantine, e.Key)
+}
+
+// Get returns the value of key, if set.
+func (m *UnsyncConfigMap[V]) Get(key string) (V, bool) {
+	e, ok := m.entries[key]
+	if !ok || e.Deleted {
+		var zero V
+		return zero, false
+	}
+	return e.Value, true
+}
+
+// Keys returns the keys set, sorted.
+func (m *UnsyncConfigMap[V]) Keys() []string {
+	keys := make([]string, 0, len(m.entries))
+	for key, e := range m.entries {
+		if !e.Deleted {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Value returns the map as a map[string]V.
+func (m *UnsyncConfigMap[V]) Value() any {
+	values := make(map[string]V, len(m.entries))
+	for key, e := range m.entries {
+		if !e.Deleted {
+			values[key] = e.Value
+		}
+	}
+	return values
+}
+
+// Quarantined returns the remote values refused by the validator that are
+// newer than the value of their key, sorted by key.
+func (m *UnsyncConfigMap[V]) Quarantined() []Quarantined[V] {
+	q := make([]Quarantined[V], 0, len(m.quarantine))
+	for _, v := range m.quarantine {
+		q = append(q, v)
+	}
+	sort.Slice(q, func(i, j int) bool { return q[i].Key < q[j].Key })
+	return q
+}
+
+// OnQuarantine sets fn to be called for every remote value the validator
+// refuses; nil removes it. fn runs during the merge and must not call back
+// into the map.
+func (m *UnsyncConfigMap[V]) OnQuarantine(fn func(Quarantined[V])) {
+	m.onQuarantine = fn
+}
+
+// Merge combines the state of another UnsyncConfigMap into this one. See
+// ConfigMap.Merge.
+func (m *UnsyncConfigMap[V]) Merge(other *UnsyncConfigMap[V]) MergeResult {
+	if m.useResolver(other.resolver.name, len(other.entries)) != nil {
+		return MergeResult{Rejected: len(other.entries)}
+	}
+	entries := make([]configEntry[V], 0, len(other.entries))
+	for _, e := range other.entries {
+		entries = append(entries, e)
+	}
+	return m.merge(entries)
+}
+
+// merge applies the remote writes newer than the local ones and
+// quarantines those the validator or WithMaxValueSize refuses.
+func (m *UnsyncConfigMap[V]) merge(entries []configEntry[V]) MergeResult {
+	var result MergeResult
+	for _, e := range entries {
+		local, ok := m.entries[e.Key]
+		if ok && !m.newer(e, local) {
+			m.configConflict(local, e)
+			result.Duplicates++
+			continue
+		}
+		if !e.Deleted {
+			value, err := m.check(e.Key, e.Value)
+			if err != nil {
+				if q, held := m.quarantine[e.Key]; held && q.Clock == e.Clock && q.NodeID == e.NodeID {
+					result.Duplicates++ // already reported
+					continue
+				}
+				q := Quarantined[V]{Key: e.Key, Value: e.Value, Clock: e.Clock, NodeID: e.NodeID, Err: err}
+				m.quarantine[e.Key] = q
+				if m.onQuarantine != nil {
+					m.onQuarantine(q)
+				}
+				result.Rejected++
+				continue
+			}
+			e.Value = value
+		}
+		if ok {
+			m.configConflict(local, e)
+		}
+		m.entries[e.Key] = e
+		m.clock = max(m.clock, e.Clock)
+		if q, held := m.quarantine[e.Key]; held && !m.newer(configEntry[V]{Key: q.Key, Value: q.Value, Clock: q.Clock, NodeID: q.NodeID}, e) {
+			delete(m.quarantine, e.Key)
+		}
+		if e.Deleted {
+			result.Deleted++
+		} else {
+			result.Applied++
+		}
+	}
+	return result
+}
+
+// MarshalState encodes the last write of every key, deletions included.
+// Quarantined values are not part of it.
+func (m *UnsyncConfigMap[V]) MarshalState() ([]byte, error) {
+	state := configMapState[V]{V: WireVersion, Resolver: m.resolver.name, Entries: make([]configEntry[V], 0, len(m.entries))}
+	for _, e := range m.entries {
+		state.Entries = append(state.Entries, e)
+	}
+	return m.codec.Marshal(state)
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this map.
+func (m *UnsyncConfigMap[V]) MergeState(data []byte) (MergeResult, error) {
+	var state configMapState[V]
+	if err := m.codec.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(m.codec, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+	if err := m.useResolver(state.Resolver, len(state.Entries)); err != nil {
+		return MergeResult{}, err
+	}
+	return m.merge(state.Entries), nil
+}
+
+// ConfigMap is a last-writer-wins map for replicating configuration: each
+// key holds the value of its latest write, ordered by a Lamport clock with
+// ties broken by NodeID, and deletions are writes too.
+//
+// Every value goes through a ConfigValidator: a local Set of an invalid
+// value fails, and an invalid remote value is quarantined instead of
+// applied. The key keeps its previous value, the remote value is reported
+// through OnQuarantine and Quarantined and counted as Rejected in the
+// MergeResult, and it is never sent on to other replicas. Replicas with
+// the same validator thus agree on the last valid value of every key.
+// Values over WithMaxValueSize are refused the same way, or truncated.
+// WithResolver orders the writes of a key by their values first.
+//
+// ConfigMap is safe for concurrent use; it wraps an UnsyncConfigMap with a
+// read/write mutex, or the lock chosen with WithLocking.
+type ConfigMap[V any] struct {
+	mu rwLocker // See WithLocking
+	m  UnsyncConfigMap[V]
+}
+
+// NewConfigMap creates an empty ConfigMap for the replica nodeID, which
+// must be unique, checking every value with validate (nil accepts all). Of
+// the options, WithClock, WithLocking, WithCodec, WithConflictJournal,
+// WithMaxValueSize and WithResolver apply.
+func NewConfigMap[V any](nodeID string, validate ConfigValidator[V], opts ...Option) *ConfigMap[V] {
+	return &ConfigMap[V]{
+		mu: newLocker(newOptions(opts).locking),
+		m:  *NewUnsyncConfigMap(nodeID, validate, opts...),
+	}
+}
+
+// Set writes value to key, after every write this replica has seen. An
+// invalid value is not written and the error wraps ErrRejected and the
+// validator's error, or ErrValueTooLarge for a value over
+// WithMaxValueSize under SizeReject. A value losing to the current one
+// under WithResolver is not written either, and the error wraps
+// ErrSuperseded.
+func (m *ConfigMap[V]) Set(key string, value V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Set(key, value)
+}
+
+// Delete removes key, as a write that wins over the writes this replica
+// has seen. Deleting a key not set, or whose value wins over the zero
+// value under WithResolver, does nothing.
+func (m *ConfigMap[V]) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Delete(key)
+}
+
+// Get returns the value of key, if set.
+func (m *ConfigMap[V]) Get(key string) (V, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Get(key)
+}
+
+// Keys returns the keys set, sorted.
+func (m *ConfigMap[V]) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Keys()
+}
+
+// Value returns the map as a map[string]V.
+func (m *ConfigMap[V]) Value() any {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Value()
+}
+
+// Quarantined returns the remote values refused by the validator that are
+// newer than the value of their key, sorted by key.
+func (m *ConfigMap[V]) Quarantined() []Quarantined[V] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Quarantined()
+}
+
+// OnQuarantine sets fn to be called for every remote value the validator
+// refuses, such as to alert an operator; nil removes it. fn runs under the
+// map's lock and must not call back into it.
+func (m *ConfigMap[V]) OnQuarantine(fn func(Quarantined[V])) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.OnQuarantine(fn)
+}
+
+// Merge combines the state of another replica of the map into this one.
+// The returned MergeResult counts the values taken over as Applied, the
+// deletions as Deleted and the values quarantined as Rejected.
+//
+// The remote state is copied before the local lock is taken, so two maps
+// merging into each other concurrently cannot deadlock.
+func (m *ConfigMap[V]) Merge(other *ConfigMap[V]) MergeResult {
+	other.mu.RLock()
+	entries := make([]configEntry[V], 0, len(other.m.entries))
+	for _, e := range other.m.entries {
+		entries = append(entries, e)
+	}
+	other.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.m.useResolver(other.m.resolver.name, len(entries)) != nil {
+		return MergeResult{Rejected: len(entries)}
+	}
+	return m.m.merge(entries)
+}
+
+// MarshalState encodes the last write of every key, deletions included.
+func (m *ConfigMap[V]) MarshalState() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.MarshalState()
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this map, quarantining invalid values.
+func (m *ConfigMap[V]) MergeState(data []byte) (MergeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.MergeState(data)
+}
@@ -0,0 +1,88 @@
+package gocrdt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// positive refuses negative limits.
+func positive(_ string, v int) error {
+	if v < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
+
+func TestConfigMap_LastWriterWins(t *testing.T) {
+	a, b := NewConfigMap[int]("a", positive), NewConfigMap[int]("b", positive)
+	if err := a.Set("workers", 4); err != nil {
+		t.Fatal(err)
+	}
+	_ = a.Set("timeout", 30)
+	b.Merge(a)
+
+	// Concurrent writes of the same clock: the greater NodeID wins.
+	_ = a.Set("workers", 8)
+	_ = b.Set("workers", 16)
+	b.Delete("timeout")
+	a.Merge(b)
+	b.Merge(a)
+	for _, m := range []*ConfigMap[int]{a, b} {
+		if v, _ := m.Get("workers"); v != 16 || !slices.Equal(m.Keys(), []string{"workers"}) {
+			t.Errorf("Expected workers=16 only, got %v", m.Value())
+		}
+	}
+
+	state, _ := a.MarshalState()
+	c := NewConfigMap[int]("c", positive)
+	if _, err := c.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Set("workers", 2) // after every write c has seen
+	a.Merge(c)
+	if v, _ := a.Get("workers"); v != 2 {
+		t.Errorf("Expected the later write to win, got %d", v)
+	}
+}
+
+func TestConfigMap_Quarantine(t *testing.T) {
+	if err := NewConfigMap[int]("a", positive).Set("workers", -1); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected ErrRejected, got %v", err)
+	}
+
+	lax := NewConfigMap[int]("lax", nil)
+	strict := NewConfigMap[int]("strict", positive)
+	_ = strict.Set("workers", 4)
+	lax.Merge(strict)
+	_ = lax.Set("workers", -1)
+
+	var reported []Quarantined[int]
+	strict.OnQuarantine(func(q Quarantined[int]) { reported = append(reported, q) })
+	for range 2 {
+		if result := strict.Merge(lax); result.Applied != 0 {
+			t.Errorf("Expected nothing applied, got %+v", result)
+		}
+	}
+	if v, _ := strict.Get("workers"); v != 4 {
+		t.Errorf("Expected the previous value to stay, got %d", v)
+	}
+	if len(reported) != 1 || reported[0].Value != -1 || reported[0].Err == nil {
+		t.Errorf("Expected one report, got %+v", reported)
+	}
+	if q := strict.Quarantined(); len(q) != 1 || q[0].NodeID != "lax" {
+		t.Errorf("Expected the value to be held, got %+v", q)
+	}
+
+	// The quarantined value is not sent on, and a newer valid write
+	// releases the quarantine.
+	other := NewConfigMap[int]("other", nil)
+	other.Merge(strict)
+	if v, _ := other.Get("workers"); v != 4 {
+		t.Errorf("Expected the quarantined value to stay local, got %d", v)
+	}
+	_ = lax.Set("workers", 6)
+	if result := strict.Merge(lax); result.Applied != 1 || len(strict.Quarantined()) != 0 {
+		t.Errorf("Expected the valid write to apply, got %+v and %v", result, strict.Quarantined())
+	}
+}
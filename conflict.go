@@ -0,0 +1,155 @@
+package gocrdt
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultJournalSize is the number of conflicts a ConflictJournal keeps by
+// default.
+const DefaultJournalSize = 1024
+
+// ConflictKind identifies how a merge resolved a conflict.
+type ConflictKind string
+
+const (
+	// ConflictOverwrite is a ConfigMap key written on two replicas with
+	// different values: the write with the later clock won.
+	ConflictOverwrite ConflictKind = "overwrite"
+
+	// ConflictOrder is two RGA nodes inserted concurrently after the same
+	// parent: the greater ID was ordered first.
+	ConflictOrder ConflictKind = "order"
+)
+
+// ConflictSide is one side of a Conflict: a ConfigMap write, or an RGA
+// node. ID is the Lamport clock and replica of the write, or the node's ID.
+type ConflictSide struct {
+	ID      ID   `json:"id"`
+	Value   any  `json:"value,omitempty"` // The value, or the node's character as a string
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Conflict is a resolution a merge made without asking anyone.
+type Conflict struct {
+	Kind    ConflictKind `json:"kind"`
+	Replica string       `json:"replica"`       // The replica that merged
+	Key     string       `json:"key,omitempty"` // The ConfigMap key
+	At      time.Time    `json:"at"`
+
+	// Winner is the write kept, or the node ordered first; Loser the write
+	// overwritten or discarded, or the node ordered after it.
+	Winner ConflictSide `json:"winner"`
+	Loser  ConflictSide `json:"loser"`
+
+	// Remote reports whether the winner is the side the merge brought.
+	Remote bool `json:"remote"`
+}
+
+// ConflictJournal records the conflicts merges resolve, for after-the-fact
+// review: where last-writer-wins overwrote a value, where concurrent
+// inserts were ordered. Pass it to the CRDTs to watch with
+// WithConflictJournal; one journal may serve several of them. It keeps the
+// latest conflicts up to its size and is safe for concurrent use.
+//
+// A conflict is journaled by the replicas that see both sides, so every
+// replica that merged them may report it. ConfigMap clocks cannot tell
+// concurrent writes from a write made after seeing the other, so every
+// merge that chooses between two different values of a key is journaled.
+// RGA nodes are only journaled when their order proves they were
+// concurrent: a node ordered after a sibling at least as new, which its
+// replica had thus not seen.
+type ConflictJournal struct {
+	mu      sync.Mutex
+	size    int
+	entries []Conflict
+	dropped int
+}
+
+// NewConflictJournal creates a journal keeping the last size conflicts
+// (DefaultJournalSize if size is not positive).
+func NewConflictJournal(size int) *ConflictJournal {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	return &ConflictJournal{size: size}
+}
+
+// WithConflictJournal records the conflicts the merges of an RGA or a
+// ConfigMap resolve, and of the ChatLog and TaskList built from them, in
+// j. Counters have no conflicts and ignore it.
+func WithConflictJournal(j *ConflictJournal) Option {
+	return func(o *options) { o.journal = j }
+}
+
+// record adds c, dropping the oldest conflict if the journal is full. A
+// nil journal records nothing.
+func (j *ConflictJournal) record(c Conflict) {
+	if j == nil {
+		return
+	}
+	c.At = time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.entries) == j.size {
+		j.entries = append(j.entries[:0], j.entries[1:]...)
+		j.dropped++
+	}
+	j.entries = append(j.entries, c)
+}
+
+// Entries returns the conflicts recorded, oldest first.
+func (j *ConflictJournal) Entries() []Conflict {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Conflict(nil), j.entries...)
+}
+
+// Dropped returns the number of conflicts dropped to keep the journal
+// within its size.
+func (j *ConflictJournal) Dropped() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.dropped
+}
+
+// Reset empties the journal, such as once its conflicts were reviewed.
+func (j *ConflictJournal) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries, j.dropped = nil, 0
+}
+
+// configConflict journals the merge of remote over local, writes of the
+// same key, unless they are the same write or hold the same value.
+func (m *UnsyncConfigMap[V]) configConflict(local, remote configEntry[V]) {
+	if m.journal == nil || (local.Clock == remote.Clock && local.NodeID == remote.NodeID) {
+		return
+	}
+	if local.Deleted == remote.Deleted && reflect.DeepEqual(local.Value, remote.Value) {
+		return
+	}
+	side := func(e configEntry[V]) ConflictSide {
+		s := ConflictSide{ID: ID{e.Clock, e.NodeID}, Deleted: e.Deleted}
+		if !e.Deleted {
+			s.Value = e.Value
+		}
+		return s
+	}
+	c := Conflict{Kind: ConflictOverwrite, Replica: m.nodeID, Key: local.Key, Winner: side(local), Loser: side(remote)}
+	if m.newer(remote, local) {
+		c.Winner, c.Loser, c.Remote = c.Loser, c.Winner, true
+	}
+	m.journal.record(c)
+}
+
+// orderConflict journals the remote node n ordered after its sibling.
+func (r *UnsyncRGA) orderConflict(sibling, n *Node) {
+	r.journal.record(Conflict{
+		Kind:    ConflictOrder,
+		Replica: r.nodeID,
+		Winner:  ConflictSide{ID: sibling.ID, Value: string(sibling.Value), Deleted: sibling.Deleted},
+		Loser:   ConflictSide{ID: n.ID, Value: string(n.Value), Deleted: n.Deleted},
+	})
+}
@@ -0,0 +1,86 @@
+package gocrdt
+
+import (
+	"testing"
+)
+
+func TestConflictJournal_ConfigMap(t *testing.T) {
+	journal := NewConflictJournal(0)
+	a := NewConfigMap[string]("a", nil, WithConflictJournal(journal))
+	b := NewConfigMap[string]("b", nil)
+	_ = a.Set("color", "red")
+	_ = b.Set("color", "blue")
+	_ = b.Set("size", "L")
+	a.Merge(b)
+
+	entries := journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one conflict, got %+v", entries)
+	}
+	c := entries[0]
+	if c.Kind != ConflictOverwrite || c.Key != "color" || c.Replica != "a" || !c.Remote {
+		t.Errorf("Expected b's write to win on color, got %+v", c)
+	}
+	if c.Winner.Value != "blue" || c.Loser.Value != "red" || c.Loser.ID != (ID{1, "a"}) || c.At.IsZero() {
+		t.Errorf("Expected blue over red, got %+v", c)
+	}
+
+	// Merging the same state again, or a write with the same value,
+	// journals nothing.
+	a.Merge(b)
+	c2 := NewConfigMap[string]("c", nil, WithClock(5))
+	_ = c2.Set("color", "blue")
+	a.Merge(c2)
+	if len(journal.Entries()) != 1 {
+		t.Errorf("Expected no more conflicts, got %+v", journal.Entries())
+	}
+
+	// A stale write loses locally.
+	stale := NewConfigMap[string]("0", nil)
+	_ = stale.Set("size", "S")
+	a.Merge(stale)
+	if c := journal.Entries()[1]; c.Remote || c.Winner.Value != "L" || c.Loser.Value != "S" {
+		t.Errorf("Expected the local L to win over S, got %+v", c)
+	}
+}
+
+func TestConflictJournal_RGA(t *testing.T) {
+	journal := NewConflictJournal(0)
+	root := ID{0, "root"}
+	a := NewRGA("y", WithConflictJournal(journal))
+	b := NewRGA("x")
+	a.Insert('x', a.Insert('a', root))
+	b.Insert('b', root)
+
+	// x's node, as new as y's, had not seen it: it is ordered after it.
+	a.Merge(b.Nodes())
+	entries := journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one conflict, got %+v", entries)
+	}
+	if c := entries[0]; c.Kind != ConflictOrder || c.Winner.Value != "a" || c.Loser.Value != "b" || c.Remote {
+		t.Errorf("Expected a ordered before b, got %+v", c)
+	}
+
+	// A node inserted after seeing its siblings is no conflict.
+	b.Merge(a.Nodes())
+	b.Insert('!', root)
+	a.Merge(b.Nodes())
+	if len(journal.Entries()) != 1 {
+		t.Errorf("Expected no more conflicts, got %+v", journal.Entries())
+	}
+}
+
+func TestConflictJournal_Size(t *testing.T) {
+	journal := NewConflictJournal(2)
+	for i := range 3 {
+		journal.record(Conflict{Key: string(rune('a' + i))})
+	}
+	if entries := journal.Entries(); len(entries) != 2 || entries[0].Key != "b" || journal.Dropped() != 1 {
+		t.Errorf("Expected the last two conflicts kept, got %+v, %d dropped", entries, journal.Dropped())
+	}
+	journal.Reset()
+	if len(journal.Entries()) != 0 || journal.Dropped() != 0 {
+		t.Error("Expected an empty journal after Reset")
+	}
+}
@@ -4,8 +4,9 @@
 // replicas receive the same set of updates, they will eventually reach the
 // same state regardless of the order in which updates were processed.
 //
-// This package implements State-based CRDTs (CvRDTs) including Counters (G, PN)
-// and Sequences (RGA).
+// This package implements State-based CRDTs (CvRDTs) including Counters (G, PN),
+// Sequences (RGA), Sets (OR-Set), Maps (OR-Map, LWW ConfigMap) and
+// composites of them (ChatLog, TaskList).
 package gocrdt
 
 // CRDT is the base interface that defines the behavior for all convergent
@@ -44,3 +45,51 @@ type CRDT interface {
 	// to merge a GCounter into an RGA).
 	Merge(other CRDT) error
 }
+
+// MergeResult summarises the effect of a single Merge call.
+//
+// Merges are otherwise silent, so sync layers can use these figures to log
+// replication progress and to spot stuck replication (e.g. a steadily
+// growing Orphaned count means parents are never arriving).
+type MergeResult struct {
+	// Applied is the number of remote entries that changed local state
+	// (integrated RGA nodes, or counter slots that advanced).
+	Applied int
+
+	// Duplicates is the number of remote entries that had no effect: RGA
+	// nodes that were already known, or counter slots whose remote value
+	// was equal to or behind the local one.
+	Duplicates int
+
+	// Orphaned is the number of RGA nodes buffered because their parent
+	// has not been received yet.
+	Orphaned int
+
+	// Deleted is the number of tombstones applied to existing nodes.
+	Deleted int
+
+	// Rejected is the number of remote entries a Validator refused, or
+	// that were malformed (RGA nodes claiming the root's ID, or whose
+	// timestamp does not exceed their parent's) or from an earlier epoch
+	// of an Epoched document, and were dropped.
+	Rejected int
+}
+
+// Add accumulates the figures of another result into r.
+func (r *MergeResult) Add(other MergeResult) {
+	r.Applied += other.Applied
+	r.Duplicates += other.Duplicates
+	r.Orphaned += other.Orphaned
+	r.Deleted += other.Deleted
+	r.Rejected += other.Rejected
+}
+
+// Replicable is implemented by every CRDT in this package whose state can be
+// exchanged between replicas as an opaque byte payload.
+//
+// MergeState must accept any payload produced by MarshalState on a replica
+// of the same type and obey the same semilattice laws as Merge.
+type Replicable interface {
+	MarshalState() ([]byte, error)
+	MergeState(data []byte) (MergeResult, error)
+}
@@ -0,0 +1,142 @@
+package crdttest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// LawViolation is reported by a Checked replica for a merge breaking a
+// semilattice law.
+type LawViolation struct {
+	// Law is "commutativity", "idempotency" or "conformance" (the merged
+	// replica differs from a reference merge of the same states).
+	Law string
+
+	// State is the state of the replica before the merge, Payload the
+	// merged payload: merging them into empty replicas reproduces the
+	// violation.
+	State, Payload []byte
+
+	// Err is a *DivergenceError, or the error of a reference merge.
+	Err error
+}
+
+func (v *LawViolation) Error() string {
+	return fmt.Sprintf("crdttest: %s violated by a merge: %v", v.Law, v.Err)
+}
+
+func (v *LawViolation) Unwrap() error {
+	return v.Err
+}
+
+// Checked shadows a replica with reference replicas to verify every merge
+// at runtime, in development builds and integration tests:
+//
+//	doc := gocrdt.NewRGA("alice")
+//	checked := crdttest.NewChecked(doc, func(id string) gocrdt.Replicable {
+//		return gocrdt.NewRGA(id)
+//	}, nil)
+//	replica := replicator.NewReplica("alice", checked, transport, config)
+//
+// For every successful MergeState it merges the state the replica had and
+// the payload into empty replicas created by New, in both orders and
+// twice, and checks that the orders agree (commutativity), that merging
+// the payload again changes nothing (idempotency) and that the replica
+// ended up like the reference (conformance). That catches integration
+// bugs such as an application mutating state it got from the replica, or
+// a custom type whose merge depends on the delivery order.
+//
+// States are compared as in CheckConvergence. Each merge costs several
+// full-state merges, so Checked is not meant for production. Local
+// operations go to the wrapped replica, see State; a Checked is safe for
+// concurrent use if the replica is, and serializes its merges.
+type Checked struct {
+	state       gocrdt.Replicable
+	newReplica  func(id string) gocrdt.Replicable
+	onViolation func(*LawViolation)
+
+	mu sync.Mutex
+}
+
+// NewChecked wraps state, a replica created by newReplica. onViolation
+// receives every violation; nil panics with the *LawViolation, to stop at
+// the first one under a debugger or a test.
+func NewChecked(state gocrdt.Replicable, newReplica func(id string) gocrdt.Replicable, onViolation func(*LawViolation)) *Checked {
+	if onViolation == nil {
+		onViolation = func(v *LawViolation) { panic(v) }
+	}
+	return &Checked{state: state, newReplica: newReplica, onViolation: onViolation}
+}
+
+// State returns the wrapped replica.
+func (c *Checked) State() gocrdt.Replicable {
+	return c.state
+}
+
+// MarshalState encodes the state of the wrapped replica.
+func (c *Checked) MarshalState() ([]byte, error) {
+	return c.state.MarshalState()
+}
+
+// MergeState merges data into the wrapped replica and checks the merge.
+// Failed merges are returned as is, without checks.
+func (c *Checked) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	before, err := c.state.MarshalState()
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	payload := bytes.Clone(data) // the caller may reuse data
+	result, err := c.state.MergeState(data)
+	if err != nil {
+		return result, err
+	}
+	if v := c.check(before, payload); v != nil {
+		c.onViolation(v)
+	}
+	return result, nil
+}
+
+// check merges before and payload into reference replicas and returns the
+// first law they break.
+func (c *Checked) check(before, payload []byte) *LawViolation {
+	violation := func(law string, err error) *LawViolation {
+		return &LawViolation{Law: law, State: before, Payload: payload, Err: err}
+	}
+	merged := func(states ...[]byte) (gocrdt.Replicable, error) {
+		r := c.newReplica("check")
+		for _, state := range states {
+			if _, err := r.MergeState(state); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	}
+
+	ab, err := merged(before, payload)
+	if err != nil {
+		return violation("conformance", err)
+	}
+	ba, err := merged(payload, before)
+	if err != nil {
+		return violation("commutativity", err)
+	}
+	if diffs := compare(1, ab, ba); len(diffs) > 0 {
+		return violation("commutativity", &DivergenceError{Differences: diffs})
+	}
+	abb, err := merged(before, payload, payload)
+	if err != nil {
+		return violation("idempotency", err)
+	}
+	if diffs := compare(1, ab, abb); len(diffs) > 0 {
+		return violation("idempotency", &DivergenceError{Differences: diffs})
+	}
+	if diffs := compare(1, ab, c.state); len(diffs) > 0 {
+		return violation("conformance", &DivergenceError{Differences: diffs})
+	}
+	return nil
+}
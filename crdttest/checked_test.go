@@ -0,0 +1,64 @@
+package crdttest
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestChecked(t *testing.T) {
+	alice := gocrdt.NewRGA("alice")
+	bob := gocrdt.NewRGA("bob")
+	alice.Insert('a', gocrdt.ID{NodeID: "root"})
+	bob.Insert('b', gocrdt.ID{NodeID: "root"})
+
+	checked := NewChecked(alice, func(id string) gocrdt.Replicable { return gocrdt.NewRGA(id) }, nil)
+	state, _ := bob.MarshalState()
+	if _, err := checked.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checked.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	if got := checked.State().(*gocrdt.RGA).Value(); got != "ba" && got != "ab" {
+		t.Errorf("Expected both inserts, got %q", got)
+	}
+	if _, err := checked.MergeState([]byte("not json")); err == nil {
+		t.Error("Expected a failed merge to be returned")
+	}
+}
+
+func TestChecked_DetectsViolations(t *testing.T) {
+	counter := &brokenCounter{gocrdt.NewGCounter("alice")}
+	counter.Increment()
+	other := gocrdt.NewGCounter("bob")
+	other.Increment()
+
+	var violations []*LawViolation
+	checked := NewChecked(counter, func(id string) gocrdt.Replicable {
+		return &brokenCounter{gocrdt.NewGCounter(id)}
+	}, func(v *LawViolation) { violations = append(violations, v) })
+	state, _ := other.MarshalState()
+	if _, err := checked.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Law != "commutativity" {
+		t.Fatalf("Expected a commutativity violation, got %v", violations)
+	}
+	var divergence *DivergenceError
+	if !errors.As(violations[0], &divergence) {
+		t.Errorf("Expected a DivergenceError, got %v", violations[0].Err)
+	}
+
+	defer func() {
+		if _, ok := recover().(*LawViolation); !ok {
+			t.Error("Expected the default handler to panic with the violation")
+		}
+	}()
+	checked = NewChecked(&brokenCounter{gocrdt.NewGCounter("carol")}, func(id string) gocrdt.Replicable {
+		return &brokenCounter{gocrdt.NewGCounter(id)}
+	}, nil)
+	checked.State().(*brokenCounter).Increment()
+	_, _ = checked.MergeState(state)
+}
@@ -0,0 +1,155 @@
+// Package crdttest provides helpers for testing code built on gocrdt
+// types, and for testing new CRDT implementations.
+package crdttest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// Difference is one way in which a replica differs from the first one.
+type Difference struct {
+	// Replica is the index of the differing replica, as passed to
+	// CheckConvergence. Differences are reported against replica 0.
+	Replica int
+
+	// ID is the RGA node concerned, or the zero ID for differences of the
+	// state as a whole.
+	ID gocrdt.ID
+
+	// Problem describes the difference, e.g. "missing" or "extra".
+	Problem string
+}
+
+func (d Difference) String() string {
+	if d.ID == (gocrdt.ID{}) {
+		return fmt.Sprintf("replica %d: %s", d.Replica, d.Problem)
+	}
+	return fmt.Sprintf("replica %d: node %d@%s %s", d.Replica, d.ID.Timestamp, d.ID.NodeID, d.Problem)
+}
+
+// DivergenceError is returned by CheckConvergence for replicas that still
+// differ after exchanging their states.
+type DivergenceError struct {
+	Differences []Difference
+}
+
+func (e *DivergenceError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "crdttest: replicas diverged (%d differences)", len(e.Differences))
+	for _, d := range e.Differences {
+		b.WriteString("\n\t")
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+// nodeState is implemented by RGAs, whose differences are reported per node.
+type nodeState interface {
+	Nodes() []gocrdt.Node
+}
+
+// CheckConvergence cross-merges the states of all replicas, as one round of
+// full-state anti-entropy would, and checks that they end up identical. It
+// returns a *DivergenceError listing where they differ from replica 0:
+// for RGAs the nodes present on one side only, with another value, parent
+// or tombstone, or in another position; for other types the encoded state.
+//
+// The replicas are modified: afterwards each has merged every other one.
+func CheckConvergence(replicas ...gocrdt.Replicable) error {
+	states := make([][]byte, len(replicas))
+	for i, r := range replicas {
+		state, err := r.MarshalState()
+		if err != nil {
+			return fmt.Errorf("crdttest: marshal replica %d: %w", i, err)
+		}
+		states[i] = state
+	}
+	for i, r := range replicas {
+		for j, state := range states {
+			if i == j {
+				continue
+			}
+			if _, err := r.MergeState(state); err != nil {
+				return fmt.Errorf("crdttest: merge replica %d into %d: %w", j, i, err)
+			}
+		}
+	}
+
+	var diffs []Difference
+	for i := 1; i < len(replicas); i++ {
+		diffs = append(diffs, compare(i, replicas[0], replicas[i])...)
+	}
+	if len(diffs) > 0 {
+		return &DivergenceError{Differences: diffs}
+	}
+	return nil
+}
+
+// compare reports the differences of replica i, other, from want.
+func compare(i int, want, other gocrdt.Replicable) []Difference {
+	wantNodes, ok := want.(nodeState)
+	otherNodes, ok2 := other.(nodeState)
+	if ok && ok2 {
+		return compareNodes(i, wantNodes.Nodes(), otherNodes.Nodes())
+	}
+
+	wantHash, ok := want.(gocrdt.Hasher)
+	otherHash, ok2 := other.(gocrdt.Hasher)
+	if ok && ok2 && wantHash.Hash() == otherHash.Hash() {
+		// Equal states may encode their entries in different orders.
+		return nil
+	}
+
+	a, errA := want.MarshalState()
+	b, errB := other.MarshalState()
+	switch {
+	case errA != nil || errB != nil:
+		return []Difference{{Replica: i, Problem: fmt.Sprintf("cannot marshal state: %v", errors.Join(errA, errB))}}
+	case !bytes.Equal(a, b):
+		return []Difference{{Replica: i, Problem: fmt.Sprintf("state %s, want %s", b, a)}}
+	}
+	return nil
+}
+
+// compareNodes reports nodes missing from, extra in or different in got,
+// then, if the node sets agree, the first position where the orders differ.
+func compareNodes(i int, want, got []gocrdt.Node) []Difference {
+	index := make(map[gocrdt.ID]gocrdt.Node, len(got))
+	for _, n := range got {
+		index[n.ID] = n
+	}
+	var diffs []Difference
+	for _, w := range want {
+		g, ok := index[w.ID]
+		delete(index, w.ID)
+		switch {
+		case !ok:
+			diffs = append(diffs, Difference{Replica: i, ID: w.ID, Problem: "missing"})
+		case g.ParentID != w.ParentID:
+			diffs = append(diffs, Difference{Replica: i, ID: w.ID, Problem: fmt.Sprintf("has parent %v, want %v", g.ParentID, w.ParentID)})
+		case g.Value != w.Value:
+			diffs = append(diffs, Difference{Replica: i, ID: w.ID, Problem: fmt.Sprintf("has value %q, want %q", g.Value, w.Value)})
+		case g.Deleted != w.Deleted:
+			diffs = append(diffs, Difference{Replica: i, ID: w.ID, Problem: fmt.Sprintf("has deleted=%t, want %t", g.Deleted, w.Deleted)})
+		}
+	}
+	for _, g := range got {
+		if _, extra := index[g.ID]; extra {
+			diffs = append(diffs, Difference{Replica: i, ID: g.ID, Problem: "extra"})
+		}
+	}
+	if len(diffs) > 0 {
+		return diffs
+	}
+	for pos := range want {
+		if want[pos].ID != got[pos].ID {
+			return []Difference{{Replica: i, ID: got[pos].ID, Problem: fmt.Sprintf("at position %d, want %d@%s", pos, want[pos].ID.Timestamp, want[pos].ID.NodeID)}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package crdttest
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// deaf is a replica that ignores everything it is sent.
+type deaf struct{ *gocrdt.RGA }
+
+func (deaf) MergeState([]byte) (gocrdt.MergeResult, error) { return gocrdt.MergeResult{}, nil }
+
+func TestCheckConvergence(t *testing.T) {
+	root := gocrdt.ID{NodeID: "root"}
+	a, b, c := gocrdt.NewRGA("a"), gocrdt.NewRGA("b"), gocrdt.NewPNCounter("c")
+	a.Insert('a', root)
+	b.Delete(b.Insert('b', root))
+	if err := CheckConvergence(a, b); err != nil {
+		t.Fatalf("Expected RGAs to converge, got %v", err)
+	}
+
+	// a learns x from d, d learns nothing.
+	d := deaf{gocrdt.NewRGA("d")}
+	x := d.Insert('x', root)
+	var div *DivergenceError
+	if err := CheckConvergence(a, d); !errors.As(err, &div) || len(div.Differences) != 2 {
+		t.Fatalf("Expected a's and b's nodes to be missing from d, got %v", err)
+	}
+	for _, diff := range div.Differences {
+		if diff.Replica != 1 || diff.Problem != "missing" || diff.ID == x {
+			t.Errorf("Unexpected difference: %v", diff)
+		}
+	}
+	if err := CheckConvergence(d, a); !errors.As(err, &div) || len(div.Differences) != 2 || div.Differences[0].Problem != "extra" {
+		t.Errorf("Expected a to have 2 extra nodes, got %v", err)
+	}
+
+	c.Increment()
+	if err := CheckConvergence(c, gocrdt.NewPNCounter("e")); err != nil {
+		t.Errorf("Expected counters to converge, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package crdttest
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// Spec describes a CRDT type to CheckLaws. Zero values select the defaults.
+type Spec struct {
+	// New creates an empty replica with the given replica ID.
+	New func(id string) gocrdt.Replicable
+
+	// Op applies one random local operation, drawn from rnd, to a replica
+	// created by New.
+	Op func(r gocrdt.Replicable, rnd *rand.Rand)
+
+	// Replicas is the number of replicas of every trial (default 3).
+	Replicas int
+
+	// Ops is the number of operations of every trial (default 30).
+	Ops int
+
+	// Trials is the number of randomized trials (default 100).
+	Trials int
+
+	// Seed seeds the first trial; trial i uses Seed+i. Failures report the
+	// seed of the failing trial, so setting Seed to it and Trials to 1
+	// replays exactly that trial.
+	Seed uint64
+}
+
+func (s Spec) withDefaults() Spec {
+	if s.Replicas <= 0 {
+		s.Replicas = 3
+	}
+	if s.Ops <= 0 {
+		s.Ops = 30
+	}
+	if s.Trials <= 0 {
+		s.Trials = 100
+	}
+	return s
+}
+
+// CheckLaws verifies mechanically that the merge of a CRDT type is a
+// semilattice join. Every trial applies random operations to random
+// replicas, interleaved with random one-way state merges between them (a
+// randomized delivery schedule), and then checks on the replicas' states:
+//
+//   - commutativity: merging a then b equals merging b then a;
+//   - associativity: merging a then the merge of b and c equals merging
+//     the merge of a and b then c;
+//   - idempotency: merging a state twice, or into a replica that already
+//     has it, equals merging it once;
+//   - convergence: replicas that exchanged their states are identical
+//     (see CheckConvergence).
+//
+// States are compared as in CheckConvergence. CheckLaws returns the first
+// violation, naming the law and the trial's seed.
+func CheckLaws(spec Spec) error {
+	spec = spec.withDefaults()
+	for trial := 0; trial < spec.Trials; trial++ {
+		seed := spec.Seed + uint64(trial)
+		if err := checkTrial(spec, rand.New(rand.NewPCG(seed, 0))); err != nil {
+			return fmt.Errorf("crdttest: seed %d: %w", seed, err)
+		}
+	}
+	return nil
+}
+
+func checkTrial(spec Spec, rnd *rand.Rand) error {
+	replicas := make([]gocrdt.Replicable, spec.Replicas)
+	for i := range replicas {
+		replicas[i] = spec.New(fmt.Sprintf("r%d", i))
+	}
+	for range spec.Ops {
+		spec.Op(replicas[rnd.IntN(len(replicas))], rnd)
+		if rnd.IntN(3) == 0 {
+			from, to := replicas[rnd.IntN(len(replicas))], replicas[rnd.IntN(len(replicas))]
+			state, err := from.MarshalState()
+			if err != nil {
+				return err
+			}
+			if _, err := to.MergeState(state); err != nil {
+				return fmt.Errorf("schedule: %w", err)
+			}
+		}
+	}
+
+	states := make([][]byte, len(replicas))
+	for i, r := range replicas {
+		state, err := r.MarshalState()
+		if err != nil {
+			return err
+		}
+		states[i] = state
+	}
+	a, b, c := states[rnd.IntN(len(states))], states[rnd.IntN(len(states))], states[rnd.IntN(len(states))]
+
+	ab, abState, err := spec.merged(a, b)
+	if err != nil {
+		return err
+	}
+	ba, _, err := spec.merged(b, a)
+	if err != nil {
+		return err
+	}
+	if err := equal("commutativity", ab, ba); err != nil {
+		return err
+	}
+
+	_, bcState, err := spec.merged(b, c)
+	if err != nil {
+		return err
+	}
+	abc1, _, err := spec.merged(a, bcState)
+	if err != nil {
+		return err
+	}
+	abc2, _, err := spec.merged(abState, c)
+	if err != nil {
+		return err
+	}
+	if err := equal("associativity", abc1, abc2); err != nil {
+		return err
+	}
+
+	once, onceState, err := spec.merged(a)
+	if err != nil {
+		return err
+	}
+	twice, _, err := spec.merged(a, a)
+	if err != nil {
+		return err
+	}
+	if err := equal("idempotency", once, twice); err != nil {
+		return err
+	}
+	if _, err := once.MergeState(onceState); err != nil {
+		return err
+	}
+	if err := equal("idempotency", once, twice); err != nil {
+		return err
+	}
+
+	if err := CheckConvergence(replicas...); err != nil {
+		return fmt.Errorf("convergence: %w", err)
+	}
+	return nil
+}
+
+// merged returns a fresh replica into which states were merged in order,
+// and its state.
+func (s Spec) merged(states ...[]byte) (gocrdt.Replicable, []byte, error) {
+	r := s.New("check")
+	for _, state := range states {
+		if _, err := r.MergeState(state); err != nil {
+			return nil, nil, err
+		}
+	}
+	state, err := r.MarshalState()
+	return r, state, err
+}
+
+// equal reports a violation of law if x and y differ.
+func equal(law string, x, y gocrdt.Replicable) error {
+	if diffs := compare(1, x, y); len(diffs) > 0 {
+		return fmt.Errorf("%s violated: %w", law, &DivergenceError{Differences: diffs})
+	}
+	return nil
+}
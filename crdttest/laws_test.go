@@ -0,0 +1,77 @@
+package crdttest
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestCheckLaws_GCounter(t *testing.T) {
+	err := CheckLaws(Spec{
+		New: func(id string) gocrdt.Replicable { return gocrdt.NewGCounter(id) },
+		Op:  func(r gocrdt.Replicable, _ *rand.Rand) { r.(*gocrdt.GCounter).Increment() },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckLaws_PNCounter(t *testing.T) {
+	err := CheckLaws(Spec{
+		New: func(id string) gocrdt.Replicable { return gocrdt.NewPNCounter(id) },
+		Op: func(r gocrdt.Replicable, rnd *rand.Rand) {
+			if rnd.IntN(2) == 0 {
+				r.(*gocrdt.PNCounter).Increment()
+			} else {
+				r.(*gocrdt.PNCounter).Decrement()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckLaws_RGA(t *testing.T) {
+	err := CheckLaws(Spec{
+		New: func(id string) gocrdt.Replicable { return gocrdt.NewRGA(id) },
+		Op: func(r gocrdt.Replicable, rnd *rand.Rand) {
+			doc := r.(*gocrdt.RGA)
+			nodes := doc.Nodes()
+			if len(nodes) > 0 && rnd.IntN(4) == 0 {
+				doc.Delete(nodes[rnd.IntN(len(nodes))].ID)
+				return
+			}
+			parent := gocrdt.ID{NodeID: "root"}
+			if i := rnd.IntN(len(nodes) + 1); i < len(nodes) {
+				parent = nodes[i].ID
+			}
+			doc.Insert(rune('a'+rnd.IntN(26)), parent)
+		},
+		Trials: 300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// brokenCounter keeps the state of the last merge instead of joining.
+type brokenCounter struct{ *gocrdt.GCounter }
+
+func (c *brokenCounter) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	fresh := gocrdt.NewGCounter("x")
+	result, err := fresh.MergeState(data)
+	c.GCounter = fresh // overwrite: not a join
+	return result, err
+}
+
+func TestCheckLaws_DetectsViolations(t *testing.T) {
+	err := CheckLaws(Spec{
+		New: func(id string) gocrdt.Replicable { return &brokenCounter{gocrdt.NewGCounter(id)} },
+		Op:  func(r gocrdt.Replicable, _ *rand.Rand) { r.(*brokenCounter).Increment() },
+	})
+	if err == nil {
+		t.Fatal("Expected a non-join merge to violate the laws")
+	}
+}
@@ -0,0 +1,361 @@
+package crdttest
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// SimConfig describes a Simulation. Zero values select the defaults.
+type SimConfig struct {
+	// Replicas is the number of replicas, named "r0", "r1", ... (default 3).
+	// They all know each other.
+	Replicas int
+
+	// New creates the state of the replica id.
+	New func(id string) gocrdt.Replicable
+
+	// Op, when set, applies one random local operation to state.
+	Op func(id string, state gocrdt.Replicable, rnd *rand.Rand)
+
+	// OpRate is the probability that a replica applies Op before each of
+	// its rounds.
+	OpRate float64
+
+	// Replica configures every replicator.Replica. Its Interval is the
+	// virtual round period (replicator.DefaultInterval when zero), and with
+	// Gossip set rounds are gossip rounds, each replica picking its peers
+	// from its own seeded source.
+	Replica replicator.Config
+
+	// Latency delays every message; Jitter adds a random extra delay in
+	// [0, Jitter), which reorders messages.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// DropRate and DuplicateRate are probabilities in [0, 1].
+	DropRate      float64
+	DuplicateRate float64
+
+	// Seed drives every random decision: round phases, operations, peer
+	// choices, delays and faults. Two simulations with the same config and
+	// script behave identically.
+	Seed uint64
+}
+
+// SimStats counts what happened during a Simulation.
+type SimStats struct {
+	Rounds     int // replication rounds run
+	Ops        int // random operations applied
+	Sent       int // messages sent
+	Delivered  int // messages handled by their receiver
+	Dropped    int // lost to DropRate or a partition
+	Duplicated int // extra copies delivered
+	Errors     int // failed rounds and Handle calls
+}
+
+// Simulation drives replicator.Replicas over a virtual network in virtual
+// time, with scripted and random operations, message delays, loss,
+// duplication and partitions, all decided by one seeded source. It runs in
+// a single goroutine as fast as it can, so hours of virtual time take
+// milliseconds and every run is reproducible:
+//
+//	sim := crdttest.NewSimulation(crdttest.SimConfig{New: newDoc, Op: edit, OpRate: 0.5, DropRate: 0.1, Seed: 42})
+//	sim.At(10*time.Second, func() { sim.Partition([]string{"r0"}, []string{"r1", "r2"}) })
+//	sim.At(time.Minute, sim.Heal)
+//	sim.Run(2 * time.Minute)
+//	took, err := sim.RunUntilConverged(time.Minute)
+type Simulation struct {
+	config   SimConfig
+	rnd      *rand.Rand
+	ids      []string
+	states   map[string]gocrdt.Replicable
+	replicas map[string]*replicator.Replica
+
+	now    time.Duration
+	seq    int
+	events eventQueue
+	group  map[string]int
+	ops    bool
+	stats  SimStats
+
+	mu     sync.Mutex // guards outbox: rounds send concurrently
+	outbox []replicator.Message
+}
+
+// NewSimulation creates the replicas of config. Nothing runs before Run.
+func NewSimulation(config SimConfig) *Simulation {
+	if config.Replicas <= 0 {
+		config.Replicas = 3
+	}
+	if config.Replica.Interval <= 0 {
+		config.Replica.Interval = replicator.DefaultInterval
+	}
+	s := &Simulation{
+		config:   config,
+		rnd:      rand.New(rand.NewPCG(config.Seed, 0)),
+		states:   make(map[string]gocrdt.Replicable),
+		replicas: make(map[string]*replicator.Replica),
+		group:    make(map[string]int),
+		ops:      true,
+	}
+	for i := range config.Replicas {
+		s.ids = append(s.ids, fmt.Sprintf("r%d", i))
+	}
+	for i, id := range s.ids {
+		rc := config.Replica
+		if rc.Gossip != nil {
+			gossip := *rc.Gossip
+			gossip.Rand = rand.New(rand.NewPCG(config.Seed, uint64(i)+1))
+			rc.Gossip = &gossip
+		}
+		s.states[id] = config.New(id)
+		r := replicator.NewReplica(id, s.states[id], simTransport{s}, rc)
+		for _, peer := range s.ids {
+			r.AddPeer(peer)
+		}
+		s.replicas[id] = r
+
+		// Rounds start with a random phase, so replicas are not in lockstep.
+		phase := time.Duration(s.rnd.Int64N(int64(config.Replica.Interval)))
+		s.schedule(phase, &event{round: id})
+	}
+	return s
+}
+
+// IDs returns the replica IDs.
+func (s *Simulation) IDs() []string {
+	return slices.Clone(s.ids)
+}
+
+// State returns the state of the replica id, for scripted operations and
+// assertions.
+func (s *Simulation) State(id string) gocrdt.Replicable {
+	return s.states[id]
+}
+
+// Replica returns the replica id.
+func (s *Simulation) Replica(id string) *replicator.Replica {
+	return s.replicas[id]
+}
+
+// Now returns the virtual time elapsed since the simulation started.
+func (s *Simulation) Now() time.Duration {
+	return s.now
+}
+
+// Stats returns the counters of the simulation so far.
+func (s *Simulation) Stats() SimStats {
+	return s.stats
+}
+
+// At schedules fn to run at the virtual time at, or immediately at the next
+// Run when at has passed. Scripts use it to apply operations, partition or
+// heal the network, or stop the random operations (StopOps).
+func (s *Simulation) At(at time.Duration, fn func()) {
+	s.schedule(max(at, s.now), &event{fn: fn})
+}
+
+// Partition splits the network: replicas in different groups lose each
+// other's messages until Heal. Replicas not listed reach everyone.
+func (s *Simulation) Partition(groups ...[]string) {
+	for i, members := range groups {
+		for _, id := range members {
+			s.group[id] = i + 1
+		}
+	}
+}
+
+// Heal removes every partition.
+func (s *Simulation) Heal() {
+	s.group = make(map[string]int)
+}
+
+// StopOps stops the random operations; StartOps resumes them.
+func (s *Simulation) StopOps()  { s.ops = false }
+func (s *Simulation) StartOps() { s.ops = true }
+
+// Run advances the simulation by d of virtual time.
+func (s *Simulation) Run(d time.Duration) {
+	s.runUntil(s.now+d, nil)
+}
+
+// RunUntilConverged stops the random operations and runs until every
+// replica has the same state, for at most limit of virtual time. It returns
+// the virtual time convergence took, or a *DivergenceError describing the
+// remaining differences once limit is reached.
+func (s *Simulation) RunUntilConverged(limit time.Duration) (time.Duration, error) {
+	s.StopOps()
+	start := s.now
+	converged := func() bool { return s.Diverged() == nil }
+	if s.runUntil(start+limit, converged) {
+		return s.now - start, nil
+	}
+	return limit, s.Diverged()
+}
+
+// Diverged compares every replica with r0, without exchanging anything, and
+// returns a *DivergenceError if any differs.
+func (s *Simulation) Diverged() error {
+	var diffs []Difference
+	for i, id := range s.ids[1:] {
+		diffs = append(diffs, compare(i+1, s.states[s.ids[0]], s.states[id])...)
+	}
+	if len(diffs) > 0 {
+		return &DivergenceError{Differences: diffs}
+	}
+	return nil
+}
+
+// runUntil processes events up to end, stopping early once done (if set)
+// reports true after an event. It reports whether done stopped it.
+func (s *Simulation) runUntil(end time.Duration, done func() bool) bool {
+	for len(s.events) > 0 && s.events[0].at <= end {
+		e := heap.Pop(&s.events).(*event)
+		s.now = e.at
+		s.process(e)
+		if done != nil && done() {
+			return true
+		}
+	}
+	s.now = end
+	return done != nil && done()
+}
+
+func (s *Simulation) process(e *event) {
+	ctx := context.Background()
+	switch {
+	case e.fn != nil:
+		e.fn()
+	case e.round != "":
+		if s.ops && s.config.Op != nil && s.rnd.Float64() < s.config.OpRate {
+			s.config.Op(e.round, s.states[e.round], s.rnd)
+			s.stats.Ops++
+		}
+		r := s.replicas[e.round]
+		var err error
+		if s.config.Replica.Gossip != nil {
+			err = r.Gossip(ctx)
+		} else {
+			err = r.Sync(ctx)
+		}
+		s.stats.Rounds++
+		s.count(err)
+		s.flush()
+		s.schedule(s.now+s.config.Replica.Interval, &event{round: e.round})
+	default:
+		s.stats.Delivered++
+		_, err := s.replicas[e.msg.To].Handle(ctx, e.msg)
+		s.count(err)
+		s.flush()
+	}
+}
+
+func (s *Simulation) count(err error) {
+	if err != nil {
+		s.stats.Errors++
+	}
+}
+
+// flush applies the network faults to the messages sent by the last round
+// or Handle call and schedules the deliveries. Concurrent sends of a round
+// reach the outbox in any order, so it is sorted by receiver first; the
+// order of messages to one receiver comes from a single goroutine.
+func (s *Simulation) flush() {
+	s.mu.Lock()
+	outbox := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+
+	slices.SortStableFunc(outbox, func(a, b replicator.Message) int {
+		switch {
+		case a.To < b.To:
+			return -1
+		case a.To > b.To:
+			return 1
+		}
+		return 0
+	})
+	for _, msg := range outbox {
+		s.stats.Sent++
+		if from, to := s.group[msg.From], s.group[msg.To]; from != 0 && to != 0 && from != to {
+			s.stats.Dropped++
+			continue
+		}
+		if s.rnd.Float64() < s.config.DropRate {
+			s.stats.Dropped++
+			continue
+		}
+		copies := 1
+		if s.rnd.Float64() < s.config.DuplicateRate {
+			copies++
+			s.stats.Duplicated++
+		}
+		for range copies {
+			delay := s.config.Latency
+			if s.config.Jitter > 0 {
+				delay += time.Duration(s.rnd.Int64N(int64(s.config.Jitter)))
+			}
+			s.schedule(s.now+delay, &event{msg: msg})
+		}
+	}
+}
+
+func (s *Simulation) schedule(at time.Duration, e *event) {
+	e.at = at
+	e.seq = s.seq
+	s.seq++
+	heap.Push(&s.events, e)
+}
+
+// simTransport queues the messages of a Simulation's replicas; deliveries
+// are made by the simulation, which calls Handle.
+type simTransport struct{ s *Simulation }
+
+func (t simTransport) Send(_ context.Context, msg replicator.Message) error {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	t.s.outbox = append(t.s.outbox, msg)
+	return nil
+}
+
+func (t simTransport) Receive(ctx context.Context) (replicator.Message, error) {
+	<-ctx.Done()
+	return replicator.Message{}, ctx.Err()
+}
+
+// event is a round of the replica round, a delivery of msg, or a scripted
+// fn, due at the virtual time at. seq orders events due at the same time.
+type event struct {
+	at    time.Duration
+	seq   int
+	round string
+	msg   replicator.Message
+	fn    func()
+}
+
+// eventQueue is a min-heap of events by (at, seq).
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,82 @@
+package crdttest
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+func editRGA(_ string, state gocrdt.Replicable, rnd *rand.Rand) {
+	doc := state.(*gocrdt.RGA)
+	nodes := doc.Nodes()
+	if len(nodes) > 0 && rnd.IntN(4) == 0 {
+		doc.Delete(nodes[rnd.IntN(len(nodes))].ID)
+		return
+	}
+	parent := gocrdt.ID{NodeID: "root"}
+	if i := rnd.IntN(len(nodes) + 1); i < len(nodes) {
+		parent = nodes[i].ID
+	}
+	doc.Insert(rune('a'+rnd.IntN(26)), parent)
+}
+
+func newSim(seed uint64, gossip bool) *Simulation {
+	config := SimConfig{
+		Replicas:      5,
+		New:           func(id string) gocrdt.Replicable { return gocrdt.NewRGA(id) },
+		Op:            editRGA,
+		OpRate:        0.7,
+		Latency:       50 * time.Millisecond,
+		Jitter:        2 * time.Second,
+		DropRate:      0.2,
+		DuplicateRate: 0.1,
+		Seed:          seed,
+	}
+	if gossip {
+		config.Replica.Gossip = &replicator.GossipConfig{Fanout: 2}
+	}
+	sim := NewSimulation(config)
+	sim.At(10*time.Second, func() { sim.Partition([]string{"r0", "r1"}, []string{"r2", "r3", "r4"}) })
+	sim.At(40*time.Second, sim.Heal)
+	return sim
+}
+
+func TestSimulation_Converges(t *testing.T) {
+	for _, gossip := range []bool{false, true} {
+		sim := newSim(7, gossip)
+		sim.Run(30 * time.Second)
+		if sim.Diverged() == nil {
+			t.Errorf("gossip=%t: expected the partition to keep replicas apart", gossip)
+		}
+		sim.Run(30 * time.Second)
+		took, err := sim.RunUntilConverged(time.Minute)
+		if err != nil {
+			t.Fatalf("gossip=%t: replicas did not converge: %v", gossip, err)
+		}
+		stats := sim.Stats()
+		if stats.Ops == 0 || stats.Dropped == 0 || stats.Duplicated == 0 || stats.Errors != 0 {
+			t.Errorf("gossip=%t: unexpected stats %+v", gossip, stats)
+		}
+		t.Logf("gossip=%t: converged %v after the ops stopped, %+v", gossip, took, stats)
+	}
+}
+
+func TestSimulation_Deterministic(t *testing.T) {
+	a, b := newSim(3, true), newSim(3, true)
+	a.Run(time.Minute)
+	b.Run(time.Minute)
+	if a.Stats() != b.Stats() {
+		t.Fatalf("Same seed, different runs: %+v vs %+v", a.Stats(), b.Stats())
+	}
+	for _, id := range a.IDs() {
+		x, _ := a.State(id).MarshalState()
+		y, _ := b.State(id).MarshalState()
+		if !bytes.Equal(x, y) {
+			t.Errorf("Same seed, different states of %s", id)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gocrdt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// debugID renders an ID compactly as "timestamp@node".
+func debugID(id ID) string {
+	if id == (ID{0, "root"}) {
+		return "root"
+	}
+	return strconv.FormatInt(id.Timestamp, 10) + "@" + id.NodeID
+}
+
+// children returns the children of every node, in sibling order.
+func (r *UnsyncRGA) children() map[ID][]*Node {
+	children := make(map[ID][]*Node)
+	for curr := r.root.Next; curr != nil; curr = curr.Next {
+		children[curr.ParentID] = append(children[curr.ParentID], curr)
+	}
+	return children
+}
+
+// orphanParents returns the missing parents of buffered orphans, sorted.
+func (r *UnsyncRGA) orphanParents() []ID {
+	parents := make([]ID, 0, len(r.pendingOrphans))
+	for parent := range r.pendingOrphans {
+		parents = append(parents, parent)
+	}
+	sortIDs(parents)
+	return parents
+}
+
+// DebugString renders the internal structure of the document for humans:
+// the tree of nodes by parent, with siblings in their integration order
+// and tombstones marked, followed by the orphan buffer. Comparing the dumps
+// of two replicas that render different text shows where they differ.
+//
+//	RGA a: clock 3, 3 nodes, 1 tombstones, 1 orphans, 0 collected
+//	root
+//	├── 3@b 'x'
+//	└── 1@a 'h'
+//	    └── 2@a 'i' deleted
+//	orphans:
+//	    9@c 'z' waiting for 8@c
+func (r *UnsyncRGA) DebugString() string {
+	stats := r.Stats()
+	var b strings.Builder
+	fmt.Fprintf(&b, "RGA %s: clock %d, %d nodes, %d tombstones, %d orphans, %d collected\n",
+		r.nodeID, r.clock, stats.Nodes, stats.Tombstones, stats.Orphans, len(r.collected))
+	b.WriteString("root\n")
+
+	children := r.children()
+	var walk func(parent ID, indent string)
+	walk = func(parent ID, indent string) {
+		kids := children[parent]
+		for i, n := range kids {
+			branch, next := "├── ", "│   "
+			if i == len(kids)-1 {
+				branch, next = "└── ", "    "
+			}
+			fmt.Fprintf(&b, "%s%s%s %q", indent, branch, debugID(n.ID), n.Value)
+			if n.Deleted {
+				b.WriteString(" deleted")
+			}
+			b.WriteByte('\n')
+			walk(n.ID, indent+next)
+		}
+	}
+	walk(r.root.ID, "")
+
+	if len(r.pendingOrphans) > 0 {
+		b.WriteString("orphans:\n")
+		for _, parent := range r.orphanParents() {
+			for _, n := range r.pendingOrphans[parent] {
+				fmt.Fprintf(&b, "    %s %q", debugID(n.ID), n.Value)
+				if n.Deleted {
+					b.WriteString(" deleted")
+				}
+				fmt.Fprintf(&b, " waiting for %s\n", debugID(parent))
+			}
+		}
+	}
+	return b.String()
+}
+
+// DOT renders the internal structure of the document as a Graphviz graph.
+// Solid edges point from parents to children, dotted edges follow the
+// linearized order, tombstones are grey and buffered orphans are red,
+// attached to a placeholder for their missing parent:
+//
+//	dot -Tsvg doc.dot > doc.svg
+func (r *UnsyncRGA) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", "rga "+r.nodeID)
+	b.WriteString("\tnode [shape=box];\n")
+	fmt.Fprintf(&b, "\t%q [shape=circle];\n", "root")
+
+	prev := r.root
+	for curr := r.root.Next; curr != nil; curr = curr.Next {
+		id := debugID(curr.ID)
+		label := fmt.Sprintf("%s\n%q", id, curr.Value)
+		if curr.Deleted {
+			fmt.Fprintf(&b, "\t%q [label=%q, style=filled, fillcolor=grey, fontcolor=grey40];\n", id, label)
+		} else {
+			fmt.Fprintf(&b, "\t%q [label=%q];\n", id, label)
+		}
+		fmt.Fprintf(&b, "\t%q -> %q;\n", debugID(curr.ParentID), id)
+		fmt.Fprintf(&b, "\t%q -> %q [style=dotted, constraint=false];\n", debugID(prev.ID), id)
+		prev = curr
+	}
+
+	for _, parent := range r.orphanParents() {
+		missing := debugID(parent)
+		fmt.Fprintf(&b, "\t%q [style=dashed, color=red];\n", missing)
+		for _, n := range r.pendingOrphans[parent] {
+			id := debugID(n.ID)
+			fmt.Fprintf(&b, "\t%q [label=%q, color=red];\n", id, fmt.Sprintf("%s\n%q", id, n.Value))
+			fmt.Fprintf(&b, "\t%q -> %q [color=red];\n", missing, id)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// DebugString renders the internal structure of the document for humans.
+// See UnsyncRGA.DebugString.
+func (r *RGA) DebugString() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.DebugString()
+}
+
+// DOT renders the internal structure of the document as a Graphviz graph.
+// See UnsyncRGA.DOT.
+func (r *RGA) DOT() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.DOT()
+}
@@ -0,0 +1,47 @@
+package gocrdt
+
+import (
+	"strings"
+	"testing"
+)
+
+func debugDoc() *RGA {
+	doc := NewRGA("a")
+	h := doc.Insert('h', ID{NodeID: "root"})
+	doc.Delete(doc.Insert('i', h))
+	doc.Merge([]Node{
+		{ID: ID{Timestamp: 3, NodeID: "b"}, ParentID: ID{NodeID: "root"}, Value: 'x'},
+		{ID: ID{Timestamp: 9, NodeID: "c"}, ParentID: ID{Timestamp: 8, NodeID: "c"}, Value: 'z'},
+	})
+	return doc
+}
+
+func TestRGA_DebugString(t *testing.T) {
+	want := `RGA a: clock 3, 3 nodes, 1 tombstones, 1 orphans, 0 collected
+root
+├── 3@b 'x'
+└── 1@a 'h'
+    └── 2@a 'i' deleted
+orphans:
+    9@c 'z' waiting for 8@c
+`
+	if got := debugDoc().DebugString(); got != want {
+		t.Errorf("Unexpected dump:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRGA_DOT(t *testing.T) {
+	dot := debugDoc().DOT()
+	for _, want := range []string{
+		`digraph "rga a" {`,
+		`"root" -> "3@b";`,
+		`"3@b" -> "1@a" [style=dotted, constraint=false];`,
+		`"2@a" [label="2@a\n'i'", style=filled, fillcolor=grey, fontcolor=grey40];`,
+		`"8@c" [style=dashed, color=red];`,
+		`"8@c" -> "9@c" [color=red];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected the graph to contain %s, got:\n%s", want, dot)
+		}
+	}
+}
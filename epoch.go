@@ -0,0 +1,171 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Epoch identifies a generation of an Epoched document. Epochs are ordered
+// by Number, then by Origin, the replica that declared it, so two resets
+// declared concurrently still agree on a winner.
+type Epoch struct {
+	Number uint64 `json:"n"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// After reports whether e is a later epoch than other.
+func (e Epoch) After(other Epoch) bool {
+	if e.Number != other.Number {
+		return e.Number > other.Number
+	}
+	return e.Origin > other.Origin
+}
+
+func (e Epoch) String() string {
+	if e.Origin == "" {
+		return fmt.Sprint(e.Number)
+	}
+	return fmt.Sprintf("%d@%s", e.Number, e.Origin)
+}
+
+// epochState is the wire representation of an Epoched document.
+type epochState struct {
+	V     int             `json:"v"`
+	Epoch Epoch           `json:"epoch"`
+	State json.RawMessage `json:"state"`
+}
+
+// Epoched wraps a Replicable document with an epoch, for recovering from
+// corrupted or split-brain replicas. Within an epoch it merges like the
+// wrapped document. An operator declares a new epoch with Reset, seeding
+// it with a known-good state; replicas receiving a later epoch discard
+// their whole state, divergent history included, and restart from the
+// payload, while payloads of earlier epochs are dropped and counted as
+// Rejected. The new epoch spreads through normal replication.
+//
+// The wrapped document must be safe for concurrent use (RGA, GCounter,
+// PNCounter). A reset replaces it, so callers must not keep the result of
+// State across resets: use Do, or rebind in OnReset. Epoched documents
+// always sync by full state.
+type Epoched struct {
+	id  string
+	new func() Replicable
+
+	mu      sync.RWMutex
+	epoch   Epoch
+	state   Replicable
+	onReset func(Epoch, Replicable)
+}
+
+// NewEpoched creates an Epoched document at epoch 0 for the replica id.
+// newState creates an empty wrapped document, such as
+// func() Replicable { return NewRGA(id) }.
+func NewEpoched(id string, newState func() Replicable) *Epoched {
+	return &Epoched{id: id, new: newState, state: newState()}
+}
+
+// OnReset registers fn to be called, with the document locked, whenever
+// the wrapped document is replaced, by Reset or by a merged later epoch.
+// fn must not call back into e.
+func (e *Epoched) OnReset(fn func(Epoch, Replicable)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onReset = fn
+}
+
+// Epoch returns the current epoch.
+func (e *Epoched) Epoch() Epoch {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.epoch
+}
+
+// State returns the wrapped document of the current epoch.
+func (e *Epoched) State() Replicable {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state
+}
+
+// Do runs fn on the wrapped document of the current epoch, which no reset
+// can replace until fn returns. fn must not call back into e.
+func (e *Epoched) Do(fn func(Replicable) error) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return fn(e.state)
+}
+
+// Reset declares a new epoch, after every epoch this replica has seen,
+// whose document is a fresh one merged with seed (a MarshalState payload of
+// the wrapped type, or nil to start empty). It returns the new epoch.
+func (e *Epoched) Reset(seed []byte) (Epoch, error) {
+	state := e.new()
+	if seed != nil {
+		if _, err := state.MergeState(seed); err != nil {
+			return Epoch{}, fmt.Errorf("gocrdt: reset seed: %w", err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.replace(Epoch{Number: e.epoch.Number + 1, Origin: e.id}, state)
+	return e.epoch, nil
+}
+
+// replace installs state as the document of epoch. It must be called with
+// e.mu held.
+func (e *Epoched) replace(epoch Epoch, state Replicable) {
+	e.epoch, e.state = epoch, state
+	if e.onReset != nil {
+		e.onReset(epoch, state)
+	}
+}
+
+// MarshalState encodes the epoch together with the wrapped document.
+func (e *Epoched) MarshalState() ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	state, err := e.state.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(epochState{V: WireVersion, Epoch: e.epoch, State: state})
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// into the document for the same epoch, in place of it for a later epoch,
+// and not at all (one Rejected entry) for an earlier one.
+func (e *Epoched) MergeState(data []byte) (MergeResult, error) {
+	var remote epochState
+	if err := json.Unmarshal(data, &remote); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(remote.V); err != nil {
+		return MergeResult{}, err
+	}
+
+	// The common case only needs the read lock: the wrapped document
+	// synchronizes itself.
+	e.mu.RLock()
+	if remote.Epoch == e.epoch {
+		defer e.mu.RUnlock()
+		return e.state.MergeState(remote.State)
+	}
+	e.mu.RUnlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case remote.Epoch == e.epoch: // installed meanwhile
+		return e.state.MergeState(remote.State)
+	case e.epoch.After(remote.Epoch):
+		return MergeResult{Rejected: 1}, nil
+	}
+	state := e.new()
+	result, err := state.MergeState(remote.State)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	e.replace(remote.Epoch, state)
+	return result, nil
+}
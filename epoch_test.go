@@ -0,0 +1,93 @@
+package gocrdt
+
+import "testing"
+
+func newEpochedRGA(id string) *Epoched {
+	return NewEpoched(id, func() Replicable { return NewRGA(id) })
+}
+
+func text(e *Epoched) any {
+	return e.State().(*RGA).Value()
+}
+
+// exchange merges the state of from into to.
+func exchange(t *testing.T, from, to *Epoched) MergeResult {
+	t.Helper()
+	state, err := from.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := to.MergeState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestEpoched_Reset(t *testing.T) {
+	root := ID{0, "root"}
+	a, b, c := newEpochedRGA("a"), newEpochedRGA("b"), newEpochedRGA("c")
+	a.State().(*RGA).Insert('o', root)
+	a.State().(*RGA).Insert('k', root)
+	exchange(t, a, b)
+	exchange(t, a, c)
+
+	// c's document gets corrupted and spreads garbage.
+	c.State().(*RGA).Insert('!', root)
+	exchange(t, c, a)
+	if text(a) != "!ko" {
+		t.Fatalf("Expected the garbage to spread, got %q", text(a))
+	}
+
+	// The operator resets b, whose copy is still good, with its own state.
+	var resets []Epoch
+	b.OnReset(func(e Epoch, _ Replicable) { resets = append(resets, e) })
+	seed, _ := b.State().MarshalState()
+	epoch, err := b.Reset(seed)
+	if err != nil || epoch != (Epoch{Number: 1, Origin: "b"}) || b.Epoch() != epoch || len(resets) != 1 {
+		t.Fatalf("Reset returned %v, %v (resets %v)", epoch, err, resets)
+	}
+
+	// Older epochs are dropped, the new one replaces them.
+	if result := exchange(t, a, b); result != (MergeResult{Rejected: 1}) || text(b) != "ko" {
+		t.Errorf("Expected the old epoch to be rejected, got %+v and %q", result, text(b))
+	}
+	exchange(t, b, a)
+	exchange(t, b, c)
+	for _, e := range []*Epoched{a, c} {
+		if e.Epoch() != epoch || text(e) != "ko" {
+			t.Errorf("Expected epoch %v with %q, got %v with %q", epoch, "ko", e.Epoch(), text(e))
+		}
+	}
+
+	// Within the epoch, documents merge as usual.
+	c.State().(*RGA).Insert('!', root)
+	if result := exchange(t, c, a); result.Applied != 1 || text(a) != "!ko" {
+		t.Errorf("Expected a normal merge, got %+v and %q", result, text(a))
+	}
+
+	if _, err := a.Reset([]byte("not a state")); err == nil {
+		t.Error("Expected a malformed seed to be rejected")
+	}
+	if _, err := a.MergeState([]byte("{")); err == nil {
+		t.Error("Expected a malformed state to be rejected")
+	}
+}
+
+func TestEpoched_ConcurrentResets(t *testing.T) {
+	a, b := NewEpoched("a", func() Replicable { return NewGCounter("a") }), NewEpoched("b", func() Replicable { return NewGCounter("b") })
+	a.Do(func(r Replicable) error { r.(*GCounter).Increment(); return nil })
+	a.Reset(nil)
+	b.Reset(nil)
+	b.State().(*GCounter).Increment()
+
+	exchange(t, a, b)
+	exchange(t, b, a)
+	want := Epoch{Number: 1, Origin: "b"}
+	if a.Epoch() != want || b.Epoch() != want {
+		t.Errorf("Expected both replicas to settle on %v, got %v and %v", want, a.Epoch(), b.Epoch())
+	}
+	if a.State().(*GCounter).Value() != 1 || want.String() != "1@b" || (Epoch{}).String() != "0" {
+		t.Errorf("Unexpected value %d or epoch rendering %v", a.State().(*GCounter).Value(), want)
+	}
+}
@@ -0,0 +1,134 @@
+package gocrdt
+
+import "unsafe"
+
+// Footprint is an estimate, in bytes, of the heap memory held by a CRDT,
+// for capacity planning. It counts the data structures the package
+// allocates (nodes, map entries, slices, strings) but not allocator
+// rounding or shared strings, and assumes typical map loads, so it is an
+// approximation to compare documents and extrapolate with, not a
+// measurement. Use pprof for exact figures.
+type Footprint struct {
+	// Content is the memory of the elements themselves: RGA nodes with
+	// their IDs, or counter slot names and values.
+	Content int
+
+	// Index is the memory of the lookup maps over the content.
+	Index int
+
+	// ChangeLog is the memory of the RGA change log, see Changes.
+	ChangeLog int
+
+	// Orphans is the memory of remote RGA nodes buffered until their
+	// parent arrives.
+	Orphans int
+
+	// Collected is the memory remembering tombstones removed by
+	// CompactStable.
+	Collected int
+}
+
+// Total returns the sum of all parts of the footprint.
+func (f Footprint) Total() int {
+	return f.Content + f.Index + f.ChangeLog + f.Orphans + f.Collected
+}
+
+// Add accumulates another footprint into f.
+func (f *Footprint) Add(other Footprint) {
+	f.Content += other.Content
+	f.Index += other.Index
+	f.ChangeLog += other.ChangeLog
+	f.Orphans += other.Orphans
+	f.Collected += other.Collected
+}
+
+const (
+	nodeSize   = int(unsafe.Sizeof(Node{}))
+	idSize     = int(unsafe.Sizeof(ID{}))
+	ptrSize    = int(unsafe.Sizeof(uintptr(0)))
+	stringSize = int(unsafe.Sizeof(""))
+	intSize    = int(unsafe.Sizeof(0))
+	sliceSize  = int(unsafe.Sizeof([]Node(nil)))
+)
+
+// mapEntrySize estimates the memory of one map entry: its key and value,
+// plus a control byte, at a typical load factor of 2/3 (maps double when
+// 7/8 full).
+func mapEntrySize(key, value int) int {
+	return (key + value + 1) * 3 / 2
+}
+
+// nodeFootprint estimates a node with its own copies of the NodeIDs, as
+// decoded from a remote payload.
+func nodeFootprint(n *Node) int {
+	return nodeSize + len(n.ID.NodeID) + len(n.ParentID.NodeID)
+}
+
+// MemoryFootprint estimates the memory held by the document. It walks the
+// whole document.
+func (r *UnsyncRGA) MemoryFootprint() Footprint {
+	var f Footprint
+	for _, n := range r.registry {
+		f.Content += nodeFootprint(n)
+	}
+	f.Index = len(r.registry) * mapEntrySize(idSize, ptrSize)
+	f.ChangeLog = cap(r.changes) * idSize
+	for _, orphans := range r.pendingOrphans {
+		f.Orphans += mapEntrySize(idSize, sliceSize)
+		for i := range orphans {
+			f.Orphans += nodeFootprint(&orphans[i])
+		}
+	}
+	for id, parent := range r.collected {
+		f.Collected += mapEntrySize(idSize, idSize) + len(id.NodeID) + len(parent.NodeID)
+	}
+	return f
+}
+
+// MemoryFootprint estimates the memory held by the document. See
+// UnsyncRGA.MemoryFootprint.
+func (r *RGA) MemoryFootprint() Footprint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MemoryFootprint()
+}
+
+// MemoryFootprint estimates the memory held by the counter: one map entry
+// per replica that ever incremented it.
+func (c *UnsyncGCounter) MemoryFootprint() Footprint {
+	var f Footprint
+	for id := range c.slots {
+		f.Content += len(id)
+	}
+	f.Index = len(c.slots) * mapEntrySize(stringSize, intSize)
+	return f
+}
+
+// MemoryFootprint estimates the memory held by the counter. See
+// UnsyncGCounter.MemoryFootprint.
+func (c *GCounter) MemoryFootprint() Footprint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var f Footprint
+	for id := range c.slots {
+		f.Content += len(id)
+	}
+	f.Index = len(c.slots) * (mapEntrySize(stringSize, ptrSize) + intSize)
+	return f
+}
+
+// MemoryFootprint estimates the memory held by both slot vectors of the
+// counter.
+func (c *UnsyncPNCounter) MemoryFootprint() Footprint {
+	f := c.pCounter.MemoryFootprint()
+	f.Add(c.nCounter.MemoryFootprint())
+	return f
+}
+
+// MemoryFootprint estimates the memory held by the counter. See
+// UnsyncPNCounter.MemoryFootprint.
+func (c *PNCounter) MemoryFootprint() Footprint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.MemoryFootprint()
+}
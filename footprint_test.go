@@ -0,0 +1,67 @@
+package gocrdt
+
+import (
+	"runtime"
+	"testing"
+)
+
+// heapInUse returns the live heap after two full collections, the second
+// settling what the first one released.
+func heapInUse() int {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return int(m.HeapAlloc)
+}
+
+func TestRGA_MemoryFootprint(t *testing.T) {
+	root := ID{0, "root"}
+	doc := NewRGA("alice")
+	empty := doc.MemoryFootprint()
+	a := doc.Insert('a', root)
+	doc.Delete(doc.Insert('b', a))
+	doc.Merge([]Node{{ID: ID{9, "bob"}, ParentID: ID{8, "bob"}, Value: 'o'}})
+	_, stable := doc.Changes(0)
+	doc.CompactStable(stable)
+
+	f := doc.MemoryFootprint()
+	if f.Content <= empty.Content || f.Index <= empty.Index || f.ChangeLog == 0 || f.Orphans == 0 || f.Collected == 0 {
+		t.Errorf("Expected every part to be accounted for, got %+v", f)
+	}
+	if f.Total() != f.Content+f.Index+f.ChangeLog+f.Orphans+f.Collected {
+		t.Errorf("Total %d does not add up %+v", f.Total(), f)
+	}
+
+	counter := NewPNCounter("alice")
+	counter.Increment()
+	counter.Decrement()
+	if g := counter.MemoryFootprint(); g.Content != 2*len("alice") || g.Index == 0 || g.ChangeLog != 0 {
+		t.Errorf("Unexpected counter footprint %+v", g)
+	}
+}
+
+func TestRGA_MemoryFootprintAccuracy(t *testing.T) {
+	// The source document must be garbage before the measurement starts,
+	// and the state alive until it ends.
+	state, err := func() ([]byte, error) {
+		src, _ := benchDoc(20_000)
+		return src.MarshalState()
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := heapInUse()
+	doc := NewRGA("bob")
+	if _, err := doc.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	measured := heapInUse() - before
+	estimated := doc.MemoryFootprint().Total()
+	runtime.KeepAlive(doc)
+	runtime.KeepAlive(state)
+	if estimated < measured/2 || estimated > measured*2 {
+		t.Errorf("Estimate %d is far off the measured %d bytes", estimated, measured)
+	}
+}
@@ -0,0 +1,86 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrForkIdentity is returned by Fork for a node ID the document already
+// holds nodes of: the nodes of the fork could collide with them.
+var ErrForkIdentity = errors.New("gocrdt: fork needs a node ID of its own")
+
+// knows reports whether the document holds nodes of nodeID, collected
+// tombstones included.
+func (r *UnsyncRGA) knows(nodeID string) bool {
+	if nodeID == r.nodeID {
+		return true
+	}
+	for id := range r.registry {
+		if id.NodeID == nodeID {
+			return true
+		}
+	}
+	for id := range r.collected {
+		if id.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Branch is a fork of an RGA: a document of its own, seeded from the state
+// of its parent when forked, which edits it as another replica would.
+// MergeBranch brings its changes back. It embeds the forked RGA, so it is
+// edited, read and replicated like any document; merging the parent into
+// it rebases it on the parent's later changes.
+type Branch struct {
+	*RGA
+
+	mu   sync.Mutex
+	base Version // Of the branch, up to which its changes were merged back
+}
+
+// Fork returns a branch of the document for drafts and reviews:
+//
+//	draft, err := doc.Fork("alice-draft")
+//	draft.Insert('x', parent) // doc is unchanged
+//	doc.MergeBranch(draft)    // once approved
+//
+// The branch edits as nodeID, which must be new to the document so its
+// nodes never collide with those of the document's replicas, and is created
+// with opts as by NewRGA. Pending orphans stay with the document.
+func (r *RGA) Fork(nodeID string, opts ...Option) (*Branch, error) {
+	r.mu.RLock()
+	known := r.doc.knows(nodeID)
+	state, err := r.doc.MarshalState()
+	r.mu.RUnlock()
+	if known {
+		return nil, fmt.Errorf("%w: %q", ErrForkIdentity, nodeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fork := NewRGA(nodeID, opts...)
+	if _, err := fork.MergeState(state); err != nil {
+		return nil, err
+	}
+	return &Branch{RGA: fork, base: fork.Version()}, nil
+}
+
+// MergeBranch merges the changes made on b since it was forked, or since
+// the previous MergeBranch of b, into the document. Merging is idempotent,
+// so the changes b got from the document, or that were already merged by
+// another replica, do no harm. Concurrent edits of the same places resolve
+// as for any other replica.
+func (r *RGA) MergeBranch(b *Branch) (MergeResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	nodes, next, err := b.Since(b.base)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	result := r.Merge(nodes)
+	b.base = next
+	return result, nil
+}
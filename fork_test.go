@@ -0,0 +1,39 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRGA_ForkAndMergeBranch(t *testing.T) {
+	doc := NewRGAFromString("a", "hello")
+	if _, err := doc.Fork("a"); !errors.Is(err, ErrForkIdentity) {
+		t.Errorf("Expected the document's own ID refused, got %v", err)
+	}
+
+	draft, err := doc.Fork("draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := draft.Nodes()
+	draft.Insert('!', nodes[len(nodes)-1].ID)
+	draft.Delete(nodes[0].ID)
+	doc.Insert('>', ID{0, "root"})
+	if doc.Value() != ">hello" || draft.Value() != "ello!" {
+		t.Fatalf("Expected independent edits, got %q and %q", doc.Value(), draft.Value())
+	}
+
+	result, err := doc.MergeBranch(draft)
+	if err != nil || result.Applied != 1 || result.Deleted != 1 || doc.Value() != ">ello!" {
+		t.Fatalf("Expected the draft merged, got %q with %+v, %v", doc.Value(), result, err)
+	}
+
+	// Only the changes made since are merged next time.
+	draft.Insert('?', draft.Nodes()[len(draft.Nodes())-1].ID)
+	if result, _ := doc.MergeBranch(draft); result.Applied != 1 || doc.Value() != ">ello!?" {
+		t.Errorf("Expected one new change, got %q with %+v", doc.Value(), result)
+	}
+	if _, err := draft.Fork("a"); !errors.Is(err, ErrForkIdentity) {
+		t.Errorf("Expected IDs of the document's nodes refused, got %v", err)
+	}
+}
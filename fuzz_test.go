@@ -0,0 +1,165 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// fuzzNodes decodes arbitrary bytes into a node list with few distinct
+// IDs, so that parents, duplicates and tombstones collide often. Every
+// node takes 4 bytes: timestamp, replica, parent index and value/deleted.
+func fuzzNodes(data []byte) []Node {
+	replicas := []string{"a", "b", "c"}
+	var nodes []Node
+	for ; len(data) >= 4; data = data[4:] {
+		n := Node{
+			ID:      ID{Timestamp: int64(data[0]%16) - 1, NodeID: replicas[int(data[1])%len(replicas)]},
+			Value:   rune('a' + data[3]%26),
+			Deleted: data[3]&0x80 != 0,
+		}
+		if i := int(data[2]); i < len(nodes) {
+			n.ParentID = nodes[i].ID
+		} else if data[2]&1 == 0 {
+			n.ParentID = ID{0, "root"}
+		} else {
+			n.ParentID = ID{int64(data[2] % 16), "b"} // possibly missing
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// checkInvariants fails t if the document is internally inconsistent.
+func checkInvariants(t *testing.T, doc *UnsyncRGA) {
+	t.Helper()
+	seen := map[ID]bool{doc.root.ID: true}
+	var visible []rune
+	for _, n := range doc.Nodes() {
+		if !seen[n.ParentID] {
+			t.Fatalf("Node %v precedes its parent %v", n.ID, n.ParentID)
+		}
+		if seen[n.ID] {
+			t.Fatalf("Node %v is linked twice", n.ID)
+		}
+		if n.ID.Timestamp <= n.ParentID.Timestamp {
+			t.Fatalf("Node %v is not newer than its parent %v", n.ID, n.ParentID)
+		}
+		seen[n.ID] = true
+		if !n.Deleted {
+			visible = append(visible, n.Value)
+		}
+	}
+	if len(seen) != len(doc.registry) {
+		t.Fatalf("Linked list has %d nodes, registry %d", len(seen), len(doc.registry))
+	}
+	if doc.root.Deleted {
+		t.Fatal("Root was deleted")
+	}
+	if doc.Value() != string(visible) {
+		t.Fatalf("Value %q does not match the nodes %q", doc.Value(), string(visible))
+	}
+}
+
+func FuzzRGAMerge(f *testing.F) {
+	f.Add([]byte{1, 0, 255, 0, 2, 0, 0, 1, 3, 1, 0, 2})
+	f.Add([]byte{2, 0, 1, 0, 1, 0, 255, 0, 0, 0, 0, 128})
+	f.Add([]byte{0, 0, 0, 128, 1, 1, 0, 1, 1, 1, 0, 129})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		nodes := fuzzNodes(data)
+
+		// Replicas must not depend on the delivery order. Equivocating
+		// nodes (one ID, different contents) are the validator's business,
+		// so each ID is kept once here.
+		unique := make([]Node, 0, len(nodes))
+		kept := make(map[ID]bool)
+		for _, n := range nodes {
+			if !kept[n.ID] {
+				kept[n.ID] = true
+				unique = append(unique, n)
+			}
+		}
+		forward, backward := NewUnsyncRGA("x"), NewUnsyncRGA("y")
+		forward.Merge(unique)
+		for _, n := range slices.Backward(unique) {
+			backward.Merge([]Node{n})
+		}
+		checkInvariants(t, forward)
+		checkInvariants(t, backward)
+		if !slices.Equal(forward.Nodes(), backward.Nodes()) {
+			t.Fatalf("Delivery order changed the document:\n%s\n%s", forward.DebugString(), backward.DebugString())
+		}
+
+		// Arbitrary lists, equivocations included, keep the document sound
+		// and its state exchangeable.
+		doc := NewUnsyncRGA("z")
+		doc.Merge(nodes)
+		checkInvariants(t, doc)
+		doc.Insert('!', ID{0, "root"})
+		doc.Delete(ID{0, "root"})
+		doc.CompactStable(uint64(len(doc.changes)))
+		checkInvariants(t, doc)
+
+		state, err := doc.MarshalState()
+		if err != nil {
+			t.Fatalf("MarshalState failed: %v", err)
+		}
+		copied := NewUnsyncRGA("w")
+		if _, err := copied.MergeState(state); err != nil {
+			t.Fatalf("MergeState of an exported state failed: %v", err)
+		}
+		if !slices.Equal(copied.Nodes(), doc.Nodes()) {
+			t.Fatalf("State did not round-trip:\n%s\n%s", doc.DebugString(), copied.DebugString())
+		}
+	})
+}
+
+func FuzzRGAMergeState(f *testing.F) {
+	doc := NewRGA("a")
+	doc.Delete(doc.Insert('x', ID{0, "root"}))
+	state, _ := doc.MarshalState()
+	f.Add(state)
+	f.Add([]byte(`[{"ID":{"Timestamp":0,"NodeID":"root"},"Deleted":true}]`))
+	f.Add([]byte(`[{"ID":{"Timestamp":1,"NodeID":"a"},"ParentID":{"Timestamp":1,"NodeID":"a"}}]`))
+	f.Add([]byte(`null`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc := NewUnsyncRGA("b")
+		if _, err := doc.MergeState(data); err != nil {
+			return
+		}
+		checkInvariants(t, doc)
+		doc.Insert('!', ID{0, "root"})
+		checkInvariants(t, doc)
+	})
+}
+
+func FuzzCounterMergeState(f *testing.F) {
+	f.Add([]byte(`{"a":1,"b":-3}`))
+	f.Add([]byte(`{"P":{"a":2},"N":{"a":1,"b":null}}`))
+	f.Add([]byte(`{"P":null}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		g := NewUnsyncGCounter("a")
+		g.Increment()
+		if _, err := g.MergeState(data); err == nil {
+			for id, v := range g.Slots() {
+				if v < 0 {
+					t.Fatalf("Slot %q went negative: %d", id, v)
+				}
+			}
+			if again := g.Slots(); g.MergeSlots(again).Applied != 0 {
+				t.Fatal("Merging a counter's own slots changed it")
+			}
+		}
+
+		pn := NewUnsyncPNCounter("a")
+		before := pn.Value()
+		result, err := pn.MergeState(data)
+		if err != nil {
+			return
+		}
+		var state pnCounterState
+		if json.Unmarshal(data, &state) == nil && result.Applied == 0 && pn.Value() != before {
+			t.Fatalf("Value changed from %d to %d without applied slots", before, pn.Value())
+		}
+	})
+}
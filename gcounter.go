@@ -1,6 +1,109 @@
 package gocrdt
 
-import "sync"
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// UnsyncGCounter is the unsynchronized core of a GCounter.
+//
+// It holds the full counter state and logic but performs no locking, which
+// makes it suitable for actor or event-loop architectures where a single
+// goroutine owns the counter. Concurrent use of an UnsyncGCounter is a data
+// race; use GCounter when the counter is shared between goroutines.
+type UnsyncGCounter struct {
+	nodeID string
+	// slots maps NodeID -> Current Count for that node
+	slots    map[string]int
+	onOp     func(Op)             // See OnOp
+	codec    Codec                // See WithCodec
+	requests requestLog[struct{}] // See IncrementOnce
+	overflow OverflowPolicy       // See WithOverflow
+
+	onAdvance func(string, int) // See OnAdvance
+}
+
+// NewUnsyncGCounter initializes an UnsyncGCounter for a specific node.
+// The same uniqueness requirements as NewGCounter apply to nodeID, and the
+// same options.
+func NewUnsyncGCounter(nodeID string, opts ...Option) *UnsyncGCounter {
+	o := newOptions(opts)
+	return &UnsyncGCounter{
+		nodeID:   nodeID,
+		slots:    make(map[string]int),
+		codec:    o.codec,
+		requests: newRequestLog[struct{}](o),
+		overflow: o.overflow,
+	}
+}
+
+// Increment adds 1 to the local node's slot in the counter.
+func (c *UnsyncGCounter) Increment() {
+	_ = c.Add(1)
+}
+
+// Add adds n to the local node's slot in the counter. See GCounter.Add.
+func (c *UnsyncGCounter) Add(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidAmount, n)
+	}
+	slot := c.slots[c.nodeID]
+	next, err := c.overflow.add(slot, n)
+	if err != nil || next == slot {
+		return err
+	}
+	c.slots[c.nodeID] = next
+	if c.onOp != nil {
+		c.onOp(Op{Kind: OpIncrement, Replica: c.nodeID, Count: next})
+	}
+	return nil
+}
+
+// Value returns the sum of all slots, representing the global total count.
+func (c *UnsyncGCounter) Value() int {
+	sum := 0
+	for _, value := range c.slots {
+		sum = saturatingAdd(sum, value)
+	}
+	return sum
+}
+
+// Slots returns a copy of the per-node slot vector.
+// It is the state exchanged with replicas of a different concurrency flavour
+// through MergeSlots.
+func (c *UnsyncGCounter) Slots() map[string]int {
+	slots := make(map[string]int, len(c.slots))
+	for id, value := range c.slots {
+		slots[id] = value
+	}
+	return slots
+}
+
+// Merge combines the state of another UnsyncGCounter into this one.
+// See GCounter.Merge for the semantics and the returned MergeResult.
+func (c *UnsyncGCounter) Merge(other *UnsyncGCounter) MergeResult {
+	return c.MergeSlots(other.slots)
+}
+
+// MergeSlots joins a remote slot vector, as returned by Slots, into the
+// local one by taking the per-node maximum.
+func (c *UnsyncGCounter) MergeSlots(slots map[string]int) MergeResult {
+	var result MergeResult
+	for id, value := range slots {
+		if value > c.slots[id] {
+			c.slots[id] = value
+			result.Applied++
+			if c.onAdvance != nil {
+				c.onAdvance(id, value)
+			}
+		} else {
+			result.Duplicates++
+		}
+	}
+	return result
+}
 
 // GCounter is a state-based Grow-only Counter CRDT.
 //
@@ -9,45 +112,123 @@ import "sync"
 // (map) of counts, where each node is responsible for updating its own slot.
 //
 // The total value is derived by summing all slots in the map.
+//
+// GCounter is safe for concurrent use without a global lock: every slot is
+// an atomic integer, so increments never contend with each other or with
+// readers, and scale with the number of cores. The slot map itself is only
+// locked to add the slot of a replica seen for the first time.
 type GCounter struct {
-	mu     sync.RWMutex
 	nodeID string
-	// slots maps NodeID -> Current Count for that node
-	slots map[string]int
+	local  *atomic.Int64 // The slot of nodeID, for lock-free increments
+
+	mu    sync.RWMutex // Guards the membership of slots
+	slots map[string]*atomic.Int64
+
+	onOp  atomic.Pointer[func(Op)] // See OnOp
+	codec Codec                    // See WithCodec
+
+	requestsMu sync.Mutex           // Guards requests
+	requests   requestLog[struct{}] // See IncrementOnce
+	overflow   OverflowPolicy       // See WithOverflow
+
+	onAdvance atomic.Pointer[func(string, int)] // See OnAdvance
 }
 
 // NewGCounter initializes a GCounter for a specific node.
 // The nodeID must be unique across the entire distributed system to ensure
 // that increments from different sources do not overwrite each other.
-func NewGCounter(nodeID string) *GCounter {
+// Of the options, WithCodec, WithRequestWindow and WithOverflow apply: a
+// GCounter is lock-free.
+func NewGCounter(nodeID string, opts ...Option) *GCounter {
+	o := newOptions(opts)
+	local := new(atomic.Int64)
 	return &GCounter{
-		nodeID: nodeID,
-		slots:  make(map[string]int),
+		nodeID:   nodeID,
+		local:    local,
+		slots:    map[string]*atomic.Int64{nodeID: local},
+		codec:    o.codec,
+		requests: newRequestLog[struct{}](o),
+		overflow: o.overflow,
 	}
 }
 
 // Increment adds 1 to the local node's slot in the counter.
-// This operation is thread-safe and affects only the entry corresponding
-// to the nodeID provided during initialization.
+// This operation is thread-safe, lock-free, and affects only the entry
+// corresponding to the nodeID provided during initialization. Past
+// math.MaxInt it follows the OverflowPolicy, without reporting errors.
 func (c *GCounter) Increment() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.slots[c.nodeID]++
+	// Far from the limit, increments racing with this one cannot take the
+	// slot past it, and a plain atomic add is enough.
+	if c.local.Load() < math.MaxInt/2 {
+		c.emit(int(c.local.Add(1)))
+		return
+	}
+	_ = c.Add(1)
+}
+
+// Add adds n to the local node's slot in the counter, for events counted
+// in batches. A negative n returns an error wrapping ErrInvalidAmount. When
+// the slot would pass math.MaxInt, it saturates, or, under the OverflowError
+// policy, stays unchanged and Add returns an error wrapping ErrOverflow.
+//
+// Merges cannot overflow a slot, which takes the largest of two ints, and
+// Value stops at math.MaxInt rather than wrapping around.
+func (c *GCounter) Add(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidAmount, n)
+	}
+	for {
+		slot := int(c.local.Load())
+		next, err := c.overflow.add(slot, n)
+		if err != nil || next == slot {
+			return err
+		}
+		if c.local.CompareAndSwap(int64(slot), int64(next)) {
+			c.emit(next)
+			return nil
+		}
+	}
+}
+
+// emit reports the local slot reaching count.
+func (c *GCounter) emit(count int) {
+	if fn := c.onOp.Load(); fn != nil {
+		(*fn)(Op{Kind: OpIncrement, Replica: c.nodeID, Count: count})
+	}
 }
 
 // Value returns the sum of all slots, representing the global total count.
 // This method satisfies the CRDT interface. Even if the network is partitioned,
 // this returns the most complete count currently known by the local node.
+//
+// Slots are read one by one while increments go on, so under concurrent
+// increments the sum is a count the counter held at some point during the
+// call.
 func (c *GCounter) Value() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	sum := 0
-	for _, value := range c.slots {
-		sum += value
+	for _, slot := range c.slots {
+		sum = saturatingAdd(sum, int(slot.Load()))
 	}
 	return sum
 }
 
+// Slots returns a copy of the per-node slot vector.
+func (c *GCounter) Slots() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	slots := make(map[string]int, len(c.slots))
+	for id, slot := range c.slots {
+		// The local slot exists from the start; like UnsyncGCounter,
+		// report it only once incremented.
+		if value := slot.Load(); value != 0 {
+			slots[id] = int(value)
+		}
+	}
+	return slots
+}
+
 // Merge combines the state of another GCounter into this one.
 //
 // It implements the Join-Semilattice "join" operation by taking the maximum
@@ -56,15 +237,104 @@ func (c *GCounter) Value() int {
 //   - Commutative: A merged with B is the same as B merged with A.
 //   - Associative: (A merged with B) merged with C is the same as A merged with (B merged with C).
 //   - Idempotent: Merging the same counter multiple times does not change the result.
-func (c *GCounter) Merge(other *GCounter) {
+//
+// The returned MergeResult counts slots that advanced as Applied and slots
+// that were already up to date as Duplicates.
+//
+// The remote slots are copied, into a recycled buffer, before the local
+// slots are touched, so two counters merging into each other concurrently
+// cannot deadlock, and merges that add no replica do not allocate.
+func (c *GCounter) Merge(other *GCounter) MergeResult {
+	buf := slotBuffers.Get().(*[]slotValue)
+	other.mu.RLock()
+	for id, slot := range other.slots {
+		if value := slot.Load(); value != 0 {
+			*buf = append(*buf, slotValue{id, value})
+		}
+	}
+	other.mu.RUnlock()
+	result := c.merge(*buf)
+	*buf = (*buf)[:0]
+	slotBuffers.Put(buf)
+	return result
+}
+
+// MergeSlots joins a remote slot vector, as returned by Slots on a GCounter
+// or an UnsyncGCounter, into this counter. Each slot advances to the remote
+// value with a compare-and-swap, concurrently with local increments.
+func (c *GCounter) MergeSlots(slots map[string]int) MergeResult {
+	buf := slotBuffers.Get().(*[]slotValue)
+	for id, value := range slots {
+		*buf = append(*buf, slotValue{id, int64(value)})
+	}
+	result := c.merge(*buf)
+	*buf = (*buf)[:0]
+	slotBuffers.Put(buf)
+	return result
+}
+
+// slotValue is one entry of a slot vector.
+type slotValue struct {
+	id    string
+	value int64
+}
+
+// slotBuffers recycles the slot vectors merges work on.
+var slotBuffers = sync.Pool{New: func() any { return new([]slotValue) }}
+
+// merge advances the local slots to values. Existing slots are advanced in
+// a single pass under the read lock; only slots of replicas seen for the
+// first time are created afterwards, under the write lock.
+func (c *GCounter) merge(values []slotValue) MergeResult {
+	var result MergeResult
+	var missing []slotValue
+	c.mu.RLock()
+	for _, v := range values {
+		slot := c.slots[v.id]
+		switch {
+		case slot == nil && v.value > 0:
+			missing = append(missing, v)
+		case slot != nil && advance(slot, v.value):
+			result.Applied++
+			c.advanced(v)
+		default:
+			result.Duplicates++
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, v := range missing {
+		if advance(c.slot(v.id), v.value) {
+			result.Applied++
+			c.advanced(v)
+		} else {
+			result.Duplicates++
+		}
+	}
+	return result
+}
+
+// slot returns the slot of id, creating it if needed.
+func (c *GCounter) slot(id string) *atomic.Int64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
+	slot := c.slots[id]
+	if slot == nil {
+		slot = new(atomic.Int64)
+		c.slots[id] = slot
+	}
+	return slot
+}
 
-	for id, value := range other.slots {
-		if value > c.slots[id] {
-			c.slots[id] = value
+// advance raises slot to value and reports whether it was behind.
+func advance(slot *atomic.Int64, value int64) bool {
+	for {
+		current := slot.Load()
+		if value <= current {
+			return false
+		}
+		if slot.CompareAndSwap(current, value) {
+			return true
 		}
 	}
 }
@@ -1,6 +1,11 @@
 package gocrdt
 
-import "testing"
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
 
 func TestGCounter_Convergence(t *testing.T) {
 	nodeA := NewGCounter("node-a")
@@ -23,3 +28,154 @@ func TestGCounter_Convergence(t *testing.T) {
 		t.Errorf("Idempotency failed: expected 3, got %d", nodeA.Value())
 	}
 }
+
+func TestGCounter_MergeResult(t *testing.T) {
+	nodeA := NewGCounter("node-a")
+	nodeB := NewGCounter("node-b")
+
+	nodeA.Increment()
+	nodeB.Increment()
+	nodeB.Increment()
+
+	result := nodeA.Merge(nodeB)
+	if result.Applied != 1 || result.Duplicates != 0 {
+		t.Errorf("Expected 1 applied slot, got %+v", result)
+	}
+
+	result = nodeA.Merge(nodeB)
+	if result.Applied != 0 || result.Duplicates != 1 {
+		t.Errorf("Expected re-merge to be a duplicate, got %+v", result)
+	}
+}
+
+func TestGCounter_Unsync(t *testing.T) {
+	local := NewUnsyncGCounter("node-a")
+	remote := NewGCounter("node-b")
+
+	local.Increment()
+	remote.Increment()
+	remote.Increment()
+
+	// Synchronized -> unsynchronized
+	if result := local.MergeSlots(remote.Slots()); result.Applied != 1 {
+		t.Errorf("Expected 1 applied slot, got %+v", result)
+	}
+	// Unsynchronized -> synchronized
+	if result := remote.MergeSlots(local.Slots()); result.Applied != 1 {
+		t.Errorf("Expected 1 applied slot, got %+v", result)
+	}
+
+	if local.Value() != 3 || remote.Value() != 3 {
+		t.Errorf("Expected convergence at 3, got local=%d, remote=%d", local.Value(), remote.Value())
+	}
+
+	other := NewUnsyncGCounter("node-c")
+	other.Increment()
+	local.Merge(other)
+	if local.Value() != 4 {
+		t.Errorf("Expected 4 after unsync merge, got %d", local.Value())
+	}
+}
+
+func TestGCounter_ConcurrentCrossMerge(t *testing.T) {
+	nodeA := NewGCounter("node-a")
+	nodeB := NewGCounter("node-b")
+	nodeA.Increment()
+	nodeB.Increment()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			nodeA.Merge(nodeB)
+		}
+		close(done)
+	}()
+	for i := 0; i < 1000; i++ {
+		nodeB.Merge(nodeA)
+	}
+	<-done
+
+	if nodeA.Value() != 2 || nodeB.Value() != 2 {
+		t.Errorf("Expected convergence at 2, got A=%d, B=%d", nodeA.Value(), nodeB.Value())
+	}
+}
+
+func TestGCounter_ConcurrentIncrements(t *testing.T) {
+	local := NewGCounter("node-a")
+	remote := NewGCounter("node-b")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 500 {
+				local.Increment()
+				remote.Increment()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				local.Merge(remote)
+				_ = local.Value()
+			}
+		}()
+	}
+	wg.Wait()
+	local.Merge(remote)
+
+	if local.Value() != 8000 || local.Slots()["node-a"] != 4000 {
+		t.Errorf("Expected 8000 with 4000 local increments, got %d and %v", local.Value(), local.Slots())
+	}
+	// A remote view of the local slot never rolls it back.
+	if result := local.MergeSlots(map[string]int{"node-a": 1, "node-c": 0}); result.Applied != 0 || local.Value() != 8000 {
+		t.Errorf("Expected stale slots to be duplicates, got %+v and %d", result, local.Value())
+	}
+	if slots := NewGCounter("fresh").Slots(); len(slots) != 0 {
+		t.Errorf("Expected a fresh counter to have no slots, got %v", slots)
+	}
+}
+
+func TestGCounter_MergeDoesNotAllocate(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop buffers")
+	}
+	local, remote := NewGCounter("node-a"), NewGCounter("node-b")
+	remote.Increment()
+	local.Merge(remote)
+	if allocs := testing.AllocsPerRun(100, func() { local.Merge(remote) }); allocs != 0 {
+		t.Errorf("Expected steady-state merges not to allocate, got %v allocations", allocs)
+	}
+
+	pLocal, pRemote := NewPNCounter("node-a"), NewPNCounter("node-b")
+	pRemote.Decrement()
+	pLocal.Merge(pRemote)
+	if allocs := testing.AllocsPerRun(100, func() { pLocal.Merge(pRemote) }); allocs != 0 {
+		t.Errorf("Expected steady-state PNCounter merges not to allocate, got %v allocations", allocs)
+	}
+	if pLocal.Value() != -1 {
+		t.Errorf("Expected -1, got %d", pLocal.Value())
+	}
+}
+
+func TestGCounter_Overflow(t *testing.T) {
+	saturating := NewGCounter("a")
+	saturating.MergeSlots(map[string]int{"a": math.MaxInt - 1, "b": 5})
+	saturating.Increment()
+	saturating.Increment()
+	if slots := saturating.Slots(); slots["a"] != math.MaxInt || saturating.Value() != math.MaxInt {
+		t.Errorf("Expected the slot and the value to saturate, got %v and %d", slots, saturating.Value())
+	}
+
+	strict := NewUnsyncGCounter("a", WithOverflow(OverflowError))
+	strict.MergeSlots(map[string]int{"a": math.MaxInt - 1})
+	if err := strict.Add(2); !errors.Is(err, ErrOverflow) || strict.Slots()["a"] != math.MaxInt-1 {
+		t.Errorf("Expected ErrOverflow and an unchanged slot, got %v and %v", err, strict.Slots())
+	}
+	if err := strict.Add(1); err != nil || strict.Value() != math.MaxInt {
+		t.Errorf("Expected the last increment to fit, got %v and %d", err, strict.Value())
+	}
+	if err := NewGCounter("a").Add(-1); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+}
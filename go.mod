@@ -1,3 +1,5 @@
 module github.com/cshekharsharma/go-crdt
 
 go 1.24.3
+
+require github.com/mattn/go-sqlite3 v1.14.33
@@ -0,0 +1,297 @@
+package gocrdt
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"slices"
+)
+
+// Hasher is implemented by CRDTs with a stable digest of their full state,
+// including tombstones and the metadata merges depend on. Replicas that
+// converged hash the same, whichever replica they are, whichever Codec they
+// use and in whichever order they received the state: sets and maps are
+// hashed independently of their iteration order, sequences in document
+// order. Local-only state, such as orphans waiting for their parent or
+// quarantined values, is left out.
+//
+// Comparing hashes is how to check that replicas converged: encoded states
+// may order their entries differently.
+type Hasher interface {
+	Hash() Digest
+}
+
+// stateHasher builds the Digest of a state from typed, length-prefixed
+// fields, so that different states cannot encode to the same bytes.
+type stateHasher struct {
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+// newStateHasher starts the digest of a state of the given type.
+func newStateHasher(kind string) *stateHasher {
+	s := &stateHasher{h: sha256.New()}
+	s.string(kind)
+	return s
+}
+
+func (s *stateHasher) int(v int64) {
+	s.h.Write(binary.AppendVarint(s.buf[:0], v))
+}
+
+func (s *stateHasher) string(v string) {
+	s.int(int64(len(v)))
+	s.h.Write([]byte(v))
+}
+
+func (s *stateHasher) bool(v bool) {
+	if v {
+		s.int(1)
+	} else {
+		s.int(0)
+	}
+}
+
+func (s *stateHasher) digest(d Digest) {
+	s.h.Write(d[:])
+}
+
+func (s *stateHasher) id(id ID) {
+	s.int(id.Timestamp)
+	s.string(id.NodeID)
+}
+
+// value writes a value of a user type, by its JSON encoding, which is
+// deterministic for the comparable and plain data types of keys and
+// values, or else its Go syntax.
+func (s *stateHasher) value(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = fmt.Appendf(nil, "%#v", v)
+	}
+	s.string(string(data))
+}
+
+// set writes digests in an order independent of the one they were built
+// in.
+func (s *stateHasher) set(digests []Digest) {
+	slices.SortFunc(digests, func(a, b Digest) int { return bytes.Compare(a[:], b[:]) })
+	s.int(int64(len(digests)))
+	for _, d := range digests {
+		s.digest(d)
+	}
+}
+
+func (s *stateHasher) sum() (d Digest) {
+	s.h.Sum(d[:0])
+	return d
+}
+
+// hashSlots writes the non-zero slots of a counter, by replica.
+func hashSlots[N int | uint64 | float64](s *stateHasher, slots map[string]N) {
+	replicas := make([]string, 0, len(slots))
+	for replica, v := range slots {
+		if v != 0 {
+			replicas = append(replicas, replica)
+		}
+	}
+	slices.Sort(replicas)
+	s.int(int64(len(replicas)))
+	for _, replica := range replicas {
+		s.string(replica)
+		switch v := any(slots[replica]).(type) {
+		case float64:
+			s.int(int64(math.Float64bits(v)))
+		case uint64:
+			s.int(int64(v))
+		case int:
+			s.int(int64(v))
+		}
+	}
+}
+
+// digestOf returns the Hash of s, or for a state that is not a Hasher the
+// SHA-256 of its encoding (of the error for a state that fails to encode).
+func digestOf(s Replicable) Digest {
+	if h, ok := s.(Hasher); ok {
+		return h.Hash()
+	}
+	data, err := s.MarshalState()
+	if err != nil {
+		data = []byte(err.Error())
+	}
+	return sha256.Sum256(data)
+}
+
+// Hash returns the digest of the slots of the counter.
+func (c *UnsyncGCounter) Hash() Digest {
+	s := newStateHasher("gcounter")
+	hashSlots(s, c.slots)
+	return s.sum()
+}
+
+// Hash returns the digest of the slots of the counter.
+func (c *GCounter) Hash() Digest {
+	s := newStateHasher("gcounter")
+	hashSlots(s, c.Slots())
+	return s.sum()
+}
+
+// Hash returns the digest of both slot vectors of the counter.
+func (c *UnsyncPNCounter) Hash() Digest {
+	s := newStateHasher("pncounter")
+	s.digest(c.pCounter.Hash())
+	s.digest(c.nCounter.Hash())
+	return s.sum()
+}
+
+// Hash returns the digest of both slot vectors of the counter.
+func (c *PNCounter) Hash() Digest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Hash()
+}
+
+// Hash returns the digest of the additions and subtractions of the counter.
+func (c *UnsyncPNFloatCounter) Hash() Digest {
+	s := newStateHasher("pnfloatcounter")
+	hashSlots(s, c.p)
+	hashSlots(s, c.n)
+	return s.sum()
+}
+
+// Hash returns the digest of the additions and subtractions of the counter.
+func (c *PNFloatCounter) Hash() Digest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Hash()
+}
+
+// Hash returns the digest of the nodes of the document, tombstones
+// included, in document order.
+func (r *UnsyncRGA) Hash() Digest {
+	s := newStateHasher("rga")
+	for n := r.root.Next; n != nil; n = n.Next {
+		s.id(n.ID)
+		s.id(n.ParentID)
+		s.int(int64(n.Value))
+		s.bool(n.Deleted)
+	}
+	return s.sum()
+}
+
+// Hash returns the digest of the nodes of the document, tombstones
+// included, in document order.
+func (r *RGA) Hash() Digest {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Hash()
+}
+
+// Hash returns the digest of the elements of the set with their adds, and
+// of the adds observed.
+func (s *UnsyncORSet[E]) Hash() Digest {
+	entries := make([]Digest, 0, len(s.entries))
+	for e, dots := range s.entries {
+		entry := newStateHasher("entry")
+		entry.value(e)
+		dots = slices.Clone(dots)
+		slices.SortFunc(dots, func(a, b dot) int {
+			return cmp.Or(cmp.Compare(a.Replica, b.Replica), cmp.Compare(a.Counter, b.Counter))
+		})
+		for _, d := range dots {
+			entry.string(d.Replica)
+			entry.int(int64(d.Counter))
+		}
+		entries = append(entries, entry.sum())
+	}
+	h := newStateHasher("orset")
+	h.set(entries)
+	hashSlots(h, s.clock)
+	return h.sum()
+}
+
+// Hash returns the digest of the elements of the set with their adds, and
+// of the adds observed.
+func (s *ORSet[E]) Hash() Digest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Hash()
+}
+
+// Hash returns the digest of the keys of the map and of the values of
+// present and deleted keys. Values that are not Hashers are hashed by
+// their encoding.
+func (m *UnsyncORMap[K, V]) Hash() Digest {
+	values := make([]Digest, 0, len(m.values))
+	for k, v := range m.values {
+		value := newStateHasher("value")
+		value.value(k)
+		value.digest(digestOf(v))
+		values = append(values, value.sum())
+	}
+	s := newStateHasher("ormap")
+	s.digest(m.keys.Hash())
+	s.set(values)
+	return s.sum()
+}
+
+// Hash returns the digest of the keys of the map and of the values of
+// present and deleted keys.
+func (m *ORMap[K, V]) Hash() Digest {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Hash()
+}
+
+// Hash returns the digest of the last write of every key, deletions
+// included.
+func (m *UnsyncConfigMap[V]) Hash() Digest {
+	entries := make([]Digest, 0, len(m.entries))
+	for _, e := range m.entries {
+		entry := newStateHasher("entry")
+		entry.string(e.Key)
+		entry.value(e.Value)
+		entry.int(e.Clock)
+		entry.string(e.NodeID)
+		entry.bool(e.Deleted)
+		entries = append(entries, entry.sum())
+	}
+	s := newStateHasher("configmap")
+	s.set(entries)
+	return s.sum()
+}
+
+// Hash returns the digest of the last write of every key, deletions
+// included.
+func (m *ConfigMap[V]) Hash() Digest {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Hash()
+}
+
+// Hash returns the digest of the order, messages and reactions of the log.
+func (c *ChatLog) Hash() Digest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := newStateHasher("chatlog")
+	s.digest(c.order.Hash())
+	s.digest(c.messages.Hash())
+	s.digest(c.reactions.Hash())
+	return s.sum()
+}
+
+// Hash returns the digest of the order and tasks of the list.
+func (l *TaskList) Hash() Digest {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := newStateHasher("tasklist")
+	s.digest(l.order.Hash())
+	s.digest(l.tasks.Hash())
+	return s.sum()
+}
@@ -0,0 +1,101 @@
+package gocrdt
+
+import (
+	"fmt"
+	"testing"
+)
+
+// exchangeStates merges the states of a and b into each other.
+func exchangeStates(t *testing.T, a, b Replicable) {
+	t.Helper()
+	sa, err := a.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, err := b.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.MergeState(sb); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.MergeState(sa); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(id string) Replicable
+		edit func(s Replicable, id string)
+	}{
+		{"GCounter", func(id string) Replicable { return NewGCounter(id) }, func(s Replicable, _ string) { s.(*GCounter).Increment() }},
+		{"PNCounter", func(id string) Replicable { return NewPNCounter(id) }, func(s Replicable, _ string) { s.(*PNCounter).Decrement() }},
+		{"PNFloatCounter", func(id string) Replicable { return NewPNFloatCounter(id) }, func(s Replicable, _ string) { _ = s.(*PNFloatCounter).Add(1.5) }},
+		{"RGA", func(id string) Replicable { return NewRGA(id) }, func(s Replicable, _ string) { s.(*RGA).Insert('x', ID{0, "root"}) }},
+		{"ORSet", func(id string) Replicable { return NewORSet[string](id) }, func(s Replicable, id string) {
+			for i := range 20 {
+				s.(*ORSet[string]).Add(fmt.Sprint(id, i))
+			}
+		}},
+		{"ConfigMap", func(id string) Replicable { return NewConfigMap[int](id, nil) }, func(s Replicable, id string) { _ = s.(*ConfigMap[int]).Set(id, 1) }},
+		{"ORMap", func(id string) Replicable {
+			return NewORMap[string](id, func(id string) *PNCounter { return NewPNCounter(id) })
+		}, func(s Replicable, id string) {
+			s.(*ORMap[string, *PNCounter]).Update(id, func(c *PNCounter) { c.Increment() })
+		}},
+		{"TaskList", func(id string) Replicable { return NewTaskList(id) }, func(s Replicable, id string) { s.(*TaskList).Add(id) }},
+		{"ChatLog", func(id string) Replicable { return NewChatLog(id) }, func(s Replicable, id string) { s.(*ChatLog).Post(id, "hi") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.new("a"), tt.new("b")
+			empty := a.(Hasher).Hash()
+			tt.edit(a, "a")
+			if a.(Hasher).Hash() == empty {
+				t.Fatal("Expected an edit to change the hash")
+			}
+			tt.edit(b, "b")
+			if a.(Hasher).Hash() == b.(Hasher).Hash() {
+				t.Fatal("Expected different states to hash differently")
+			}
+			exchangeStates(t, a, b)
+			if a.(Hasher).Hash() != b.(Hasher).Hash() {
+				t.Errorf("Expected converged replicas to hash the same")
+			}
+		})
+	}
+}
+
+func TestHashIgnoresEncodingOrder(t *testing.T) {
+	a, b := NewORSet[int]("a"), NewORSet[int]("b")
+	for i := range 50 {
+		a.Add(i)
+	}
+	exchangeStates(t, a, b)
+	// The encodings list the elements in map order, which differs.
+	if a.Hash() != b.Hash() || mustStateHash(t, a) != mustStateHash(t, b) {
+		t.Error("Expected equal sets to hash the same despite their encodings")
+	}
+}
+
+func mustStateHash(t *testing.T, s Replicable) Digest {
+	t.Helper()
+	d, err := StateHash(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestHashDistinguishesFields(t *testing.T) {
+	// Fields are length-prefixed, so moving bytes between them changes the
+	// hash.
+	a, b := NewConfigMap[string]("a", nil), NewConfigMap[string]("a", nil)
+	_ = a.Set("ab", "c")
+	_ = b.Set("a", "bc")
+	if a.Hash() == b.Hash() {
+		t.Error("Expected different entries to hash differently")
+	}
+}
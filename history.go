@@ -0,0 +1,172 @@
+package gocrdt
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter is implemented by GCounter and PNCounter.
+type Counter interface {
+	Replicable
+	Value() int
+}
+
+// CounterSample is the value of a counter at a point in time.
+type CounterSample struct {
+	Time  time.Time
+	Value int
+}
+
+// historySample is a CounterSample covering the bucket starting at start.
+type historySample struct {
+	start time.Time
+	CounterSample
+}
+
+// CounterHistory decorates a counter with a bounded history of its value,
+// from which it derives rates such as requests per minute across all
+// replicas:
+//
+//	hits := NewCounterHistory(NewGCounter("a"), 60, time.Second)
+//	hits.Increment()
+//	perSecond := hits.Rate(time.Minute)
+//
+// It samples the value of the counter, the total of every replica, after
+// each local mutation and each merge that changed it, keeping one sample
+// per resolution and at most size samples: the history covers the last
+// size*resolution of activity. Since the value is the converged state, a
+// merge delivering increments already merged counts nothing, whatever the
+// order or number of deliveries. Increments of other replicas count from
+// the time they are merged here.
+//
+// The history is local to the replica: MarshalState and MergeState ship the
+// counter only. CounterHistory is safe for concurrent use if the counter
+// is.
+type CounterHistory[C Counter] struct {
+	counter    C
+	resolution time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	samples []historySample // Ring, oldest at next once full
+	next    int
+}
+
+// NewCounterHistory decorates counter with a history of size samples, one
+// per resolution at most. A size under 2 keeps 2 samples.
+func NewCounterHistory[C Counter](counter C, size int, resolution time.Duration) *CounterHistory[C] {
+	h := &CounterHistory[C]{
+		counter:    counter,
+		resolution: resolution,
+		now:        time.Now,
+		samples:    make([]historySample, 0, max(size, 2)),
+	}
+	h.Record()
+	return h
+}
+
+// Counter returns the decorated counter. Mutations and merges made on it
+// directly are only sampled at the next Record.
+func (h *CounterHistory[C]) Counter() C {
+	return h.counter
+}
+
+// Value returns the value of the counter.
+func (h *CounterHistory[C]) Value() int {
+	return h.counter.Value()
+}
+
+// Increment increments the counter, if it has an Increment method, and
+// records the new value.
+func (h *CounterHistory[C]) Increment() {
+	h.Update(func(c C) {
+		if inc, ok := any(c).(interface{ Increment() }); ok {
+			inc.Increment()
+		}
+	})
+}
+
+// Update applies fn to the counter, such as a Decrement or an ApplyOp, and
+// records the new value.
+func (h *CounterHistory[C]) Update(fn func(C)) {
+	fn(h.counter)
+	h.Record()
+}
+
+// MarshalState encodes the state of the counter.
+func (h *CounterHistory[C]) MarshalState() ([]byte, error) {
+	return h.counter.MarshalState()
+}
+
+// MergeState merges a state into the counter and records the new value
+// when the merge changed it.
+func (h *CounterHistory[C]) MergeState(data []byte) (MergeResult, error) {
+	result, err := h.counter.MergeState(data)
+	if result.Applied > 0 {
+		h.Record()
+	}
+	return result, err
+}
+
+// Record samples the current value of the counter. Samples within the
+// resolution of the newest one replace it.
+func (h *CounterHistory[C]) Record() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	sample := historySample{start: now, CounterSample: CounterSample{Time: now, Value: h.counter.Value()}}
+	if len(h.samples) > 0 {
+		newest := &h.samples[(h.next+len(h.samples)-1)%len(h.samples)]
+		if now.Sub(newest.start) < h.resolution {
+			newest.CounterSample = sample.CounterSample
+			return
+		}
+	}
+	if len(h.samples) < cap(h.samples) {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+}
+
+// Samples returns the history, oldest first.
+func (h *CounterHistory[C]) Samples() []CounterSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := make([]CounterSample, len(h.samples))
+	for i := range h.samples {
+		samples[i] = h.samples[(h.next+i)%len(h.samples)].CounterSample
+	}
+	return samples
+}
+
+// Increase returns how much the value grew during the last window, and the
+// part of the window the history covers: window itself, or less when the
+// history does not reach back that far.
+func (h *CounterHistory[C]) Increase(window time.Duration) (int, time.Duration) {
+	now := h.now()
+	value := h.counter.Value()
+	cutoff := now.Add(-window)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.samples) - 1; i >= 0; i-- {
+		sample := h.samples[(h.next+i)%len(h.samples)]
+		if !sample.Time.After(cutoff) {
+			return value - sample.Value, window
+		}
+	}
+	oldest := h.samples[h.next%len(h.samples)]
+	return value - oldest.Value, now.Sub(oldest.Time)
+}
+
+// Rate returns the growth of the value per second over the last window, or
+// over the part of it the history covers.
+func (h *CounterHistory[C]) Rate(window time.Duration) float64 {
+	increase, covered := h.Increase(window)
+	if covered <= 0 {
+		return 0
+	}
+	return float64(increase) / covered.Seconds()
+}
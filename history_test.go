@@ -0,0 +1,54 @@
+package gocrdt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterHistory(t *testing.T) {
+	clock := time.Unix(1_000, 0)
+	h := NewCounterHistory(NewGCounter("a"), 4, time.Second)
+	h.now = func() time.Time { return clock }
+	h.samples = h.samples[:0]
+	h.Record()
+
+	// 10 increments a second for 3 seconds, sampled once a second.
+	for range 3 {
+		clock = clock.Add(time.Second)
+		for range 10 {
+			h.Increment()
+		}
+	}
+	if samples := h.Samples(); len(samples) != 4 || samples[3].Value != 30 {
+		t.Fatalf("Expected 4 samples up to 30, got %v", samples)
+	}
+	if rate := h.Rate(2 * time.Second); rate != 10 {
+		t.Errorf("Expected 10/s over 2s, got %v", rate)
+	}
+
+	// A remote replica's increments count once, however often merged.
+	remote := NewGCounter("b")
+	for range 20 {
+		remote.Increment()
+	}
+	state, _ := remote.MarshalState()
+	clock = clock.Add(time.Second)
+	h.MergeState(state)
+	h.MergeState(state)
+	if increase, covered := h.Increase(time.Second); increase != 20 || covered != time.Second {
+		t.Errorf("Expected 20 over 1s, got %d over %v", increase, covered)
+	}
+
+	// The ring keeps the last 4 samples, so a longer window is truncated.
+	if increase, covered := h.Increase(time.Hour); increase != 40 || covered != 3*time.Second {
+		t.Errorf("Expected 40 over the 3s covered, got %d over %v", increase, covered)
+	}
+}
+
+func TestCounterHistory_Update(t *testing.T) {
+	h := NewCounterHistory(NewPNCounter("a"), 8, time.Minute)
+	h.Update(func(c *PNCounter) { c.Decrement() })
+	if samples := h.Samples(); len(samples) != 1 || samples[0].Value != -1 {
+		t.Errorf("Expected one sample of -1 within the resolution, got %v", samples)
+	}
+}
@@ -0,0 +1,133 @@
+package gocrdt
+
+// DefaultRequestWindow is the number of request IDs a CRDT remembers for
+// its *Once mutations unless WithRequestWindow says otherwise.
+const DefaultRequestWindow = 1024
+
+// requestLog remembers the results of the last mutations applied for a
+// client request ID, so a retried request returns the first result instead
+// of applying again. It holds at most max IDs, forgetting the oldest first,
+// and is allocated by its first use. It does no locking.
+type requestLog[T any] struct {
+	max     int
+	results map[string]T
+	order   []string // Ring of the remembered IDs, oldest at next
+	next    int
+}
+
+func newRequestLog[T any](o options) requestLog[T] {
+	return requestLog[T]{max: o.requestWindow}
+}
+
+// do returns the result remembered for id, or applies fn, remembers its
+// result and reports that it was applied. An empty id is never remembered.
+func (l *requestLog[T]) do(id string, fn func() T) (T, bool) {
+	if id == "" || l.max <= 0 {
+		return fn(), true
+	}
+	if result, ok := l.results[id]; ok {
+		return result, false
+	}
+	if l.results == nil {
+		l.results = make(map[string]T)
+	}
+	result := fn()
+	if len(l.order) < l.max {
+		l.order = append(l.order, id)
+	} else {
+		delete(l.results, l.order[l.next])
+		l.order[l.next] = id
+		l.next = (l.next + 1) % l.max
+	}
+	l.results[id] = result
+	return result, true
+}
+
+// InsertOnce is Insert for a client request that may be retried. See
+// RGA.InsertOnce.
+func (r *UnsyncRGA) InsertOnce(requestID string, val rune, parentID ID) (ID, bool) {
+	return r.requests.do(requestID, func() ID { return r.Insert(val, parentID) })
+}
+
+// InsertOnce is Insert for a client request that may be retried, such as an
+// HTTP request handled at least once: the first call for requestID inserts,
+// and later calls return the ID of that insert without inserting again. It
+// reports whether this call inserted.
+//
+// Request IDs are remembered by this replica only, the last
+// DefaultRequestWindow of them unless WithRequestWindow says otherwise, so
+// retries must reach the same replica within that window. Delete needs no
+// such variant: deleting twice is a no-op. An empty requestID is a plain
+// Insert.
+func (r *RGA) InsertOnce(requestID string, val rune, parentID ID) (ID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, applied := r.doc.InsertOnce(requestID, val, parentID)
+	if applied {
+		r.changed()
+	}
+	return id, applied
+}
+
+// IncrementOnce is Increment for a client request that may be retried. See
+// GCounter.IncrementOnce.
+func (c *UnsyncGCounter) IncrementOnce(requestID string) bool {
+	_, applied := c.requests.do(requestID, func() struct{} {
+		c.Increment()
+		return struct{}{}
+	})
+	return applied
+}
+
+// IncrementOnce is Increment for a client request that may be retried: the
+// first call for requestID increments, later calls do nothing. It reports
+// whether this call incremented. Request IDs are remembered as by
+// RGA.InsertOnce. Unlike Increment, it locks, to check and apply a request
+// at once.
+func (c *GCounter) IncrementOnce(requestID string) bool {
+	c.requestsMu.Lock()
+	defer c.requestsMu.Unlock()
+	_, applied := c.requests.do(requestID, func() struct{} {
+		c.Increment()
+		return struct{}{}
+	})
+	return applied
+}
+
+// IncrementOnce is Increment for a client request that may be retried. See
+// PNCounter.IncrementOnce.
+func (c *UnsyncPNCounter) IncrementOnce(requestID string) bool {
+	_, applied := c.requests.do(requestID, func() struct{} {
+		c.Increment()
+		return struct{}{}
+	})
+	return applied
+}
+
+// DecrementOnce is Decrement for a client request that may be retried. See
+// PNCounter.DecrementOnce.
+func (c *UnsyncPNCounter) DecrementOnce(requestID string) bool {
+	_, applied := c.requests.do(requestID, func() struct{} {
+		c.Decrement()
+		return struct{}{}
+	})
+	return applied
+}
+
+// IncrementOnce is Increment for a client request that may be retried: the
+// first call for requestID increments, later calls do nothing. It reports
+// whether this call incremented. Request IDs are remembered as by
+// RGA.InsertOnce, and shared with DecrementOnce.
+func (c *PNCounter) IncrementOnce(requestID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.IncrementOnce(requestID)
+}
+
+// DecrementOnce is Decrement for a client request that may be retried. See
+// PNCounter.IncrementOnce.
+func (c *PNCounter) DecrementOnce(requestID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.DecrementOnce(requestID)
+}
@@ -0,0 +1,49 @@
+package gocrdt
+
+import "testing"
+
+func TestInsertOnce(t *testing.T) {
+	doc := NewRGA("a", WithRequestWindow(2))
+	first, applied := doc.InsertOnce("req-1", 'a', ID{0, "root"})
+	if !applied {
+		t.Fatal("Expected the first request to insert")
+	}
+	if id, applied := doc.InsertOnce("req-1", 'a', ID{0, "root"}); applied || id != first {
+		t.Errorf("Expected the retry to return %v without inserting, got %v (%v)", first, id, applied)
+	}
+	if doc.Value() != "a" {
+		t.Errorf("Expected a single insert, got %q", doc.Value())
+	}
+
+	// The window forgets the oldest request first.
+	doc.InsertOnce("req-2", 'b', first)
+	doc.InsertOnce("req-3", 'c', first)
+	if _, applied := doc.InsertOnce("req-1", 'a', ID{0, "root"}); !applied {
+		t.Error("Expected a request past the window to insert again")
+	}
+	if _, applied := doc.InsertOnce("req-3", 'c', first); applied {
+		t.Error("Expected a request within the window to be recognized")
+	}
+}
+
+func TestIncrementOnce(t *testing.T) {
+	g := NewGCounter("a")
+	g.IncrementOnce("req-1")
+	g.IncrementOnce("req-1")
+	g.IncrementOnce("")
+	g.IncrementOnce("")
+	if g.Value() != 3 {
+		t.Errorf("Expected 3 increments, got %d", g.Value())
+	}
+
+	pn := NewPNCounter("a")
+	pn.IncrementOnce("req-1")
+	if pn.DecrementOnce("req-1") {
+		t.Error("Expected increments and decrements to share request IDs")
+	}
+	pn.DecrementOnce("req-2")
+	pn.DecrementOnce("req-3")
+	if pn.Value() != -1 {
+		t.Errorf("Expected -1, got %d", pn.Value())
+	}
+}
@@ -0,0 +1,30 @@
+package gocrdt
+
+// Logger receives structured log records about events that do not affect
+// the outcome of an operation but explain it: buffered and dropped nodes,
+// rejected payloads, garbage collection. args are alternating keys and
+// values. *slog.Logger implements Logger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// SetLogger makes the document log its merge and garbage collection events
+// to l; nil, the default, disables logging.
+//
+// Per-node events (buffered and released orphans, dropped re-deliveries of
+// collected tombstones, resurrected tombstones) are logged at Debug level,
+// rejected payloads at Warn and garbage collection at Info.
+func (r *UnsyncRGA) SetLogger(l Logger) {
+	r.logger = l
+}
+
+// SetLogger makes the document log its merge and garbage collection events
+// to l. See UnsyncRGA.SetLogger.
+func (r *RGA) SetLogger(l Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doc.SetLogger(l)
+}
@@ -0,0 +1,38 @@
+package gocrdt
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRGA_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	doc := NewRGA("a")
+	doc.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	root := ID{NodeID: "root"}
+
+	parent := Node{ID: ID{Timestamp: 1, NodeID: "b"}, ParentID: root, Value: 'p'}
+	child := Node{ID: ID{Timestamp: 2, NodeID: "b"}, ParentID: parent.ID, Value: 'c'}
+	doc.Merge([]Node{child})
+	doc.Merge([]Node{parent})
+
+	doc.SetValidator(AnomalyDetector{MaxClockJump: 10})
+	doc.Merge([]Node{{ID: ID{Timestamp: 1000, NodeID: "b"}, ParentID: root, Value: 'j'}})
+
+	doc.Delete(child.ID)
+	_, stable := doc.Changes(0)
+	doc.CompactStable(stable)
+
+	for _, want := range []string{
+		`level=DEBUG msg="gocrdt: buffered orphan"`,
+		`level=DEBUG msg="gocrdt: released buffered orphans"`,
+		`level=WARN msg="gocrdt: rejected payload" nodes=1`,
+		`level=INFO msg="gocrdt: collected stable tombstones" count=1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the log to contain %s, got:\n%s", want, buf.String())
+		}
+	}
+}
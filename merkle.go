@@ -0,0 +1,241 @@
+package gocrdt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"sort"
+)
+
+// DefaultMerkleBuckets is a reasonable bucket count for Merkle digests: the
+// serialized tree is 8KB, while a single differing bucket of a 1M-node
+// document holds only ~4k nodes.
+const DefaultMerkleBuckets = 256
+
+// MaxMerkleBuckets is the largest bucket count a tree may have. Larger
+// counts are capped when building a tree and refused when decoding one, so
+// a peer cannot make a replica allocate an arbitrarily large tree.
+const MaxMerkleBuckets = 1 << 16
+
+// ErrMerkleShape is returned when two trees with a different number of
+// buckets are compared, or when a serialized tree is malformed.
+var ErrMerkleShape = errors.New("gocrdt: mismatched or malformed merkle tree")
+
+// Digest is a SHA-256 hash used as a Merkle tree node.
+type Digest [sha256.Size]byte
+
+// Chunked is implemented by CRDTs whose state can be partitioned into hash
+// buckets, so that replicas can compare Merkle trees and transfer only the
+// buckets that differ instead of their full state.
+//
+// Entries are assigned to buckets by a hash of their identity, which keeps
+// every bucket stable as the document grows. MarshalBuckets produces a
+// payload accepted by MergeState.
+type Chunked interface {
+	Replicable
+	MerkleTree(buckets int) *MerkleTree
+	MarshalBuckets(buckets int, indexes []int) ([]byte, error)
+}
+
+// MerkleTree is a binary hash tree over a fixed number of buckets.
+//
+// The tree is stored in heap order: nodes[1] is the root and the children
+// of nodes[i] are nodes[2i] and nodes[2i+1]. The bucket count is rounded up
+// to a power of two, with empty buckets hashing to the zero Digest.
+type MerkleTree struct {
+	nodes []Digest
+}
+
+// NewMerkleTree builds a tree over the given leaf digests.
+func NewMerkleTree(leaves []Digest) *MerkleTree {
+	size := 1
+	for size < len(leaves) {
+		size *= 2
+	}
+
+	nodes := make([]Digest, 2*size)
+	copy(nodes[size:], leaves)
+	for i := size - 1; i >= 1; i-- {
+		h := sha256.New()
+		h.Write(nodes[2*i][:])
+		h.Write(nodes[2*i+1][:])
+		h.Sum(nodes[i][:0])
+	}
+	return &MerkleTree{nodes: nodes}
+}
+
+// UnmarshalMerkleTree rebuilds a tree serialized with MarshalBinary. The
+// tree must have a power-of-two number of buckets, at most
+// MaxMerkleBuckets.
+func UnmarshalMerkleTree(data []byte) (*MerkleTree, error) {
+	if len(data) == 0 || len(data)%sha256.Size != 0 {
+		return nil, ErrMerkleShape
+	}
+	if n := len(data) / sha256.Size; n > MaxMerkleBuckets || n&(n-1) != 0 {
+		return nil, ErrMerkleShape
+	}
+	leaves := make([]Digest, len(data)/sha256.Size)
+	for i := range leaves {
+		copy(leaves[i][:], data[i*sha256.Size:])
+	}
+	return NewMerkleTree(leaves), nil
+}
+
+// Buckets returns the number of leaves of the tree.
+func (t *MerkleTree) Buckets() int {
+	return len(t.nodes) / 2
+}
+
+// Root returns the digest covering the whole state.
+func (t *MerkleTree) Root() Digest {
+	return t.nodes[1]
+}
+
+// MarshalBinary serializes the leaves of the tree; inner nodes are
+// recomputed on the receiving side.
+func (t *MerkleTree) MarshalBinary() ([]byte, error) {
+	leaves := t.nodes[t.Buckets():]
+	data := make([]byte, 0, len(leaves)*sha256.Size)
+	for _, leaf := range leaves {
+		data = append(data, leaf[:]...)
+	}
+	return data, nil
+}
+
+// Diff returns the indexes of the buckets whose content differs between
+// the two trees, in ascending order. Only subtrees with differing hashes
+// are visited, so equal states are detected in O(1).
+func (t *MerkleTree) Diff(other *MerkleTree) ([]int, error) {
+	if len(t.nodes) != len(other.nodes) {
+		return nil, ErrMerkleShape
+	}
+
+	var diff []int
+	var walk func(i int)
+	walk = func(i int) {
+		if t.nodes[i] == other.nodes[i] {
+			return
+		}
+		if i >= t.Buckets() {
+			diff = append(diff, i-t.Buckets())
+			return
+		}
+		walk(2 * i)
+		walk(2*i + 1)
+	}
+	walk(1)
+	return diff, nil
+}
+
+// merkleBuckets returns the bucket count used for a requested one: the
+// default if it is not positive, otherwise rounded up to the power of two
+// NewMerkleTree pads to and capped at MaxMerkleBuckets. Bucketing entries
+// by the padded count keeps two replicas configured with the same count
+// in agreement with the Buckets of the trees they exchange.
+func merkleBuckets(n int) int {
+	if n <= 0 {
+		return DefaultMerkleBuckets
+	}
+	size := 1
+	for size < min(n, MaxMerkleBuckets) {
+		size *= 2
+	}
+	return size
+}
+
+// bucketOf assigns an ID to one of n buckets.
+func bucketOf(id ID, n int) int {
+	h := fnv.New64a()
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(id.Timestamp))
+	h.Write(ts[:])
+	h.Write([]byte(id.NodeID))
+	return int(h.Sum64() % uint64(n))
+}
+
+// MerkleTree hashes the document's nodes into the given number of buckets
+// (DefaultMerkleBuckets if buckets is not positive), rounded up to a power
+// of two and capped at MaxMerkleBuckets. Nodes are hashed in ID order
+// within a bucket, so replicas holding the same nodes produce identical
+// trees.
+func (r *UnsyncRGA) MerkleTree(buckets int) *MerkleTree {
+	buckets = merkleBuckets(buckets)
+	grouped := make([][]*Node, buckets)
+	for id, n := range r.registry {
+		if n == r.root {
+			continue
+		}
+		b := bucketOf(id, buckets)
+		grouped[b] = append(grouped[b], n)
+	}
+
+	leaves := make([]Digest, buckets)
+	var buf bytes.Buffer
+	for b, nodes := range grouped {
+		if len(nodes) == 0 {
+			continue
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[j].ID.Greater(nodes[i].ID) })
+
+		buf.Reset()
+		for _, n := range nodes {
+			writeNodeDigest(&buf, n)
+		}
+		leaves[b] = sha256.Sum256(buf.Bytes())
+	}
+	return NewMerkleTree(leaves)
+}
+
+// writeNodeDigest appends the replicated fields of a node to buf.
+func writeNodeDigest(buf *bytes.Buffer, n *Node) {
+	var scratch [8]byte
+	for _, id := range []ID{n.ID, n.ParentID} {
+		binary.BigEndian.PutUint64(scratch[:], uint64(id.Timestamp))
+		buf.Write(scratch[:])
+		buf.WriteString(id.NodeID)
+		buf.WriteByte(0)
+	}
+	binary.BigEndian.PutUint32(scratch[:4], uint32(n.Value))
+	buf.Write(scratch[:4])
+	if n.Deleted {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+}
+
+// MarshalBuckets encodes the nodes that fall into the given buckets, in
+// causal order, as a payload for MergeState.
+func (r *UnsyncRGA) MarshalBuckets(buckets int, indexes []int) ([]byte, error) {
+	buckets = merkleBuckets(buckets)
+	wanted := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		wanted[i] = true
+	}
+
+	var nodes []Node
+	for _, n := range r.Nodes() {
+		if wanted[bucketOf(n.ID, buckets)] {
+			nodes = append(nodes, n)
+		}
+	}
+	return r.encodeNodes(nodes, r.tieBreak)
+}
+
+// MerkleTree hashes the document's nodes into the given number of buckets.
+// See UnsyncRGA.MerkleTree.
+func (r *RGA) MerkleTree(buckets int) *MerkleTree {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MerkleTree(buckets)
+}
+
+// MarshalBuckets encodes the nodes that fall into the given buckets.
+// See UnsyncRGA.MarshalBuckets.
+func (r *RGA) MarshalBuckets(buckets int, indexes []int) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MarshalBuckets(buckets, indexes)
+}
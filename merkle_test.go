@@ -0,0 +1,115 @@
+package gocrdt
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func buildDocument(nodeID string, n int) *RGA {
+	r := NewRGA(nodeID)
+	parent := ID{0, "root"}
+	for i := 0; i < n; i++ {
+		parent = r.Insert(rune('a'+i%26), parent)
+	}
+	return r
+}
+
+func TestMerkle_IdenticalStates(t *testing.T) {
+	alice := buildDocument("alice", 500)
+	bob := NewRGA("bob")
+	bob.Merge(alice.Nodes())
+
+	treeA := alice.MerkleTree(64)
+	treeB := bob.MerkleTree(64)
+	if treeA.Root() != treeB.Root() {
+		t.Fatal("Expected identical roots for identical states")
+	}
+	diff, err := treeA.Diff(treeB)
+	if err != nil || len(diff) != 0 {
+		t.Errorf("Expected no differing buckets, got %v (%v)", diff, err)
+	}
+}
+
+func TestMerkle_TransfersOnlyDifferingBuckets(t *testing.T) {
+	alice := buildDocument("alice", 500)
+	bob := NewRGA("bob")
+	bob.Merge(alice.Nodes())
+
+	idX := alice.Insert('X', ID{0, "root"})
+	alice.Delete(ID{1, "alice"})
+
+	treeA := alice.MerkleTree(64)
+	treeB := bob.MerkleTree(64)
+	diff, err := treeA.Diff(treeB)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff) == 0 || len(diff) > 2 {
+		t.Fatalf("Expected one or two differing buckets, got %v", diff)
+	}
+
+	payload, err := alice.MarshalBuckets(64, diff)
+	if err != nil {
+		t.Fatalf("MarshalBuckets failed: %v", err)
+	}
+	full, _ := alice.MarshalState()
+	if len(payload) >= len(full)/4 {
+		t.Errorf("Expected bucket payload (%d bytes) to be much smaller than full state (%d bytes)", len(payload), len(full))
+	}
+
+	if _, err := bob.MergeState(payload); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if bob.Value() != alice.Value() {
+		t.Errorf("Replicas diverged after bucket transfer")
+	}
+	if bob.MerkleTree(64).Root() != alice.MerkleTree(64).Root() {
+		t.Errorf("Expected equal roots after bucket transfer (inserted %v)", idX)
+	}
+}
+
+func TestMerkle_SerializationRoundTrip(t *testing.T) {
+	tree := buildDocument("alice", 50).MerkleTree(16)
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	decoded, err := UnmarshalMerkleTree(data)
+	if err != nil {
+		t.Fatalf("UnmarshalMerkleTree failed: %v", err)
+	}
+	if decoded.Root() != tree.Root() || decoded.Buckets() != 16 {
+		t.Error("Round trip changed the tree")
+	}
+}
+
+func TestMerkle_ShapeErrors(t *testing.T) {
+	doc := buildDocument("alice", 10)
+	if _, err := doc.MerkleTree(16).Diff(doc.MerkleTree(32)); err != ErrMerkleShape {
+		t.Errorf("Expected ErrMerkleShape for differing bucket counts, got %v", err)
+	}
+	if _, err := UnmarshalMerkleTree([]byte{1, 2, 3}); err != ErrMerkleShape {
+		t.Errorf("Expected ErrMerkleShape for malformed data, got %v", err)
+	}
+	if _, err := UnmarshalMerkleTree(make([]byte, 3*sha256.Size)); err != ErrMerkleShape {
+		t.Errorf("Expected ErrMerkleShape for an unpadded tree, got %v", err)
+	}
+	if _, err := UnmarshalMerkleTree(make([]byte, 2*MaxMerkleBuckets*sha256.Size)); err != ErrMerkleShape {
+		t.Errorf("Expected ErrMerkleShape for an oversized tree, got %v", err)
+	}
+}
+
+func TestMerkle_BucketsRoundedUp(t *testing.T) {
+	alice := buildDocument("alice", 200)
+	bob := NewRGA("bob")
+	bob.Merge(alice.Nodes())
+	bob.Insert('x', ID{NodeID: "root"})
+
+	local, remote := alice.MerkleTree(100), bob.MerkleTree(100)
+	if local.Buckets() != 128 || alice.MerkleTree(1<<20).Buckets() != MaxMerkleBuckets {
+		t.Fatalf("Expected bucket counts rounded and capped, got %d", local.Buckets())
+	}
+	if diff, err := local.Diff(remote); err != nil || len(diff) != 1 {
+		t.Errorf("Expected a single differing bucket, got %v, %v", diff, err)
+	}
+}
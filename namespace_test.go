@@ -0,0 +1,30 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	newCounter := func(id string) *GCounter { return NewGCounter(id) }
+	a, _ := NewNamespace("tenant-1", "node", newCounter)
+	b, _ := NewNamespace("tenant-1", "other", newCounter)
+	other, _ := NewNamespace("tenant-2", "node", newCounter)
+	a.State().Increment()
+	other.State().Increment()
+
+	state, _ := a.MarshalState()
+	if _, err := b.MergeState(state); err != nil || b.State().Value() != 1 {
+		t.Errorf("Expected a merge within the scope, got %d and %v", b.State().Value(), err)
+	}
+	if slots := b.State().Slots(); slots["tenant-1/node"] != 1 {
+		t.Errorf("Expected the slot of the scoped replica ID, got %v", slots)
+	}
+
+	if result, err := other.MergeState(state); !errors.Is(err, ErrScopeMismatch) || result.Rejected != 1 || other.State().Value() != 1 {
+		t.Errorf("Expected a cross-scope merge to be refused, got %+v and %v", result, err)
+	}
+	if _, err := NewNamespace("a/b", "node", newCounter); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package gocrdt
+
+const raceEnabled = false
@@ -0,0 +1,193 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedOp is returned by ApplyOp for an Op whose kind the CRDT
+// does not implement, such as an insert applied to a counter.
+var ErrUnsupportedOp = errors.New("gocrdt: unsupported op")
+
+// OpKind identifies the mutation an Op describes.
+type OpKind string
+
+const (
+	OpInsert    OpKind = "insert"    // RGA: a node inserted after Parent
+	OpDelete    OpKind = "delete"    // RGA: a node tombstoned
+	OpIncrement OpKind = "increment" // GCounter, PNCounter: a slot advanced
+	OpDecrement OpKind = "decrement" // PNCounter: a negative slot advanced
+)
+
+// Op is a single local mutation of a CRDT, as emitted to OnOp and applied
+// with ApplyOp. It is a union: the fields set depend on Kind. Its JSON
+// encoding is stable across releases, so ops can be logged, filtered,
+// transformed or shipped by generic middleware.
+//
+// Ops carry enough state to be applied idempotently and in any order.
+// Counter ops carry the replica's slot value after the mutation rather than
+// the delta, and delete ops carry the deleted node, so a delete arriving
+// before its insert still converges.
+type Op struct {
+	Kind OpKind `json:"kind"`
+
+	// ID, Parent and Value describe the node of an insert or a delete.
+	ID     ID   `json:"id,omitzero"`
+	Parent ID   `json:"parent,omitzero"`
+	Value  rune `json:"value,omitempty"`
+
+	// Replica and Count are the slot of an increment or a decrement: the
+	// replica owning it and its value after the mutation.
+	Replica string `json:"replica,omitempty"`
+	Count   int    `json:"count,omitempty"`
+}
+
+func (op Op) String() string {
+	switch op.Kind {
+	case OpInsert, OpDelete:
+		return fmt.Sprintf("%s %q %d@%s after %d@%s", op.Kind, op.Value, op.ID.Timestamp, op.ID.NodeID, op.Parent.Timestamp, op.Parent.NodeID)
+	default:
+		return fmt.Sprintf("%s %s=%d", op.Kind, op.Replica, op.Count)
+	}
+}
+
+// Operable is implemented by the CRDTs of this package, on both their
+// synchronized and Unsync flavours, so middleware can be written against
+// ops without switching on types.
+type Operable interface {
+	// ApplyOp applies an op emitted by another replica. Ops of other kinds
+	// than the CRDT's return an error wrapping ErrUnsupportedOp.
+	ApplyOp(op Op) (MergeResult, error)
+
+	// OnOp registers fn to be called with every op of a local mutation,
+	// after it is applied. Synchronized CRDTs call fn with the lock held,
+	// so ops are emitted in order; fn must not call back into the CRDT.
+	// Merges emit nothing. A nil fn stops the emission.
+	OnOp(fn func(Op))
+}
+
+func unsupported(op Op, crdt string) error {
+	return fmt.Errorf("%w: %s on %s", ErrUnsupportedOp, op.Kind, crdt)
+}
+
+// node returns the node an RGA op describes.
+func (op Op) node() (Node, error) {
+	switch op.Kind {
+	case OpInsert:
+		return Node{ID: op.ID, ParentID: op.Parent, Value: op.Value}, nil
+	case OpDelete:
+		return Node{ID: op.ID, ParentID: op.Parent, Value: op.Value, Deleted: true}, nil
+	}
+	return Node{}, unsupported(op, "rga")
+}
+
+// ApplyOp integrates an insert or delete op, like a Merge of its node.
+func (r *UnsyncRGA) ApplyOp(op Op) (MergeResult, error) {
+	n, err := op.node()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return r.MergeChecked([]Node{n})
+}
+
+// OnOp registers fn to receive the ops of Insert and Delete. Deleting a
+// node that is unknown or already deleted emits nothing.
+func (r *UnsyncRGA) OnOp(fn func(Op)) {
+	r.onOp = fn
+}
+
+func (r *UnsyncRGA) emit(op Op) {
+	if r.onOp != nil {
+		r.onOp(op)
+	}
+}
+
+// ApplyOp integrates an insert or delete op, like a Merge of its node.
+func (r *RGA) ApplyOp(op Op) (MergeResult, error) {
+	n, err := op.node()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return r.MergeChecked([]Node{n})
+}
+
+// OnOp registers fn to receive the ops of Insert and Delete. See
+// UnsyncRGA.OnOp.
+func (r *RGA) OnOp(fn func(Op)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doc.OnOp(fn)
+}
+
+// ApplyOp merges the slot of an increment op.
+func (c *UnsyncGCounter) ApplyOp(op Op) (MergeResult, error) {
+	if op.Kind != OpIncrement {
+		return MergeResult{}, unsupported(op, "gcounter")
+	}
+	return c.MergeSlots(map[string]int{op.Replica: op.Count}), nil
+}
+
+// OnOp registers fn to receive the ops of Increment.
+func (c *UnsyncGCounter) OnOp(fn func(Op)) {
+	c.onOp = fn
+}
+
+// ApplyOp merges the slot of an increment op.
+func (c *GCounter) ApplyOp(op Op) (MergeResult, error) {
+	if op.Kind != OpIncrement {
+		return MergeResult{}, unsupported(op, "gcounter")
+	}
+	return c.MergeSlots(map[string]int{op.Replica: op.Count}), nil
+}
+
+// OnOp registers fn to receive the ops of Increment. Increments take no
+// lock, so concurrent ones may reach fn out of order; their counts still
+// apply in any order.
+func (c *GCounter) OnOp(fn func(Op)) {
+	if fn == nil {
+		c.onOp.Store(nil)
+		return
+	}
+	c.onOp.Store(&fn)
+}
+
+// ApplyOp merges the positive slot of an increment op, or the negative slot
+// of a decrement op.
+func (c *UnsyncPNCounter) ApplyOp(op Op) (MergeResult, error) {
+	slot := map[string]int{op.Replica: op.Count}
+	switch op.Kind {
+	case OpIncrement:
+		return c.pCounter.MergeSlots(slot), nil
+	case OpDecrement:
+		return c.nCounter.MergeSlots(slot), nil
+	}
+	return MergeResult{}, unsupported(op, "pncounter")
+}
+
+// OnOp registers fn to receive the ops of Increment and Decrement.
+func (c *UnsyncPNCounter) OnOp(fn func(Op)) {
+	c.onOp = fn
+}
+
+// emit reports the advance of the local slot of half, the positive or the
+// negative counter.
+func (c *UnsyncPNCounter) emit(kind OpKind, half *UnsyncGCounter) {
+	if c.onOp != nil {
+		c.onOp(Op{Kind: kind, Replica: half.nodeID, Count: half.slots[half.nodeID]})
+	}
+}
+
+// ApplyOp merges the positive slot of an increment op, or the negative slot
+// of a decrement op.
+func (c *PNCounter) ApplyOp(op Op) (MergeResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.ApplyOp(op)
+}
+
+// OnOp registers fn to receive the ops of Increment and Decrement.
+func (c *PNCounter) OnOp(fn func(Op)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counter.OnOp(fn)
+}
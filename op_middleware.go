@@ -0,0 +1,89 @@
+package gocrdt
+
+// OpHandler consumes an Op: a transport sending it, or a CRDT applying it.
+type OpHandler func(op Op) error
+
+// OpMiddleware wraps an OpHandler, to inspect, rewrite, drop or refuse ops
+// on their way to it. A middleware drops an op by returning nil without
+// calling next, and refuses it by returning an error.
+type OpMiddleware func(next OpHandler) OpHandler
+
+// ChainOps returns h wrapped in mw, the first of which sees every op first.
+// The same chain type serves both paths: on the send path h ships the ops a
+// CRDT emits (see SendOps), on the receive path h applies them (see
+// ApplyOps).
+func ChainOps(h OpHandler, mw ...OpMiddleware) OpHandler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// SendOps makes o pass the op of every local mutation through mw to send.
+// Errors, which the mutation has no way to return, go to onError when it
+// is not nil. Like every OnOp callback, the chain runs under o's lock and
+// must not call back into o.
+func SendOps(o Operable, send OpHandler, onError func(Op, error), mw ...OpMiddleware) {
+	h := ChainOps(send, mw...)
+	o.OnOp(func(op Op) {
+		if err := h(op); err != nil && onError != nil {
+			onError(op, err)
+		}
+	})
+}
+
+// ApplyOps returns the handler of the receive path: ops go through mw, then
+// are applied to o.
+func ApplyOps(o Operable, mw ...OpMiddleware) OpHandler {
+	return ChainOps(func(op Op) error {
+		_, err := o.ApplyOp(op)
+		return err
+	}, mw...)
+}
+
+// FilterOps drops the ops keep returns false for.
+func FilterOps(keep func(Op) bool) OpMiddleware {
+	return func(next OpHandler) OpHandler {
+		return func(op Op) error {
+			if !keep(op) {
+				return nil
+			}
+			return next(op)
+		}
+	}
+}
+
+// MapOps replaces every op with the result of fn, for instance to redact
+// inserted values.
+func MapOps(fn func(Op) Op) OpMiddleware {
+	return func(next OpHandler) OpHandler {
+		return func(op Op) error {
+			return next(fn(op))
+		}
+	}
+}
+
+// CheckOps refuses the ops check returns an error for, such as ops over a
+// size limit, with that error.
+func CheckOps(check func(Op) error) OpMiddleware {
+	return func(next OpHandler) OpHandler {
+		return func(op Op) error {
+			if err := check(op); err != nil {
+				return err
+			}
+			return next(op)
+		}
+	}
+}
+
+// AuditOps calls record with every op and the error of the rest of the
+// chain, once it has handled the op.
+func AuditOps(record func(Op, error)) OpMiddleware {
+	return func(next OpHandler) OpHandler {
+		return func(op Op) error {
+			err := next(op)
+			record(op, err)
+			return err
+		}
+	}
+}
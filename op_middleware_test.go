@@ -0,0 +1,50 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpMiddleware(t *testing.T) {
+	a, b := NewRGA("a"), NewRGA("b")
+	tooBig := errors.New("too big")
+	var audit []string
+	var failed []Op
+
+	// The send path redacts digits and refuses non-ASCII text...
+	SendOps(a, ApplyOps(b), func(op Op, _ error) { failed = append(failed, op) },
+		MapOps(func(op Op) Op {
+			if op.Value >= '0' && op.Value <= '9' {
+				op.Value = '#'
+			}
+			return op
+		}),
+		CheckOps(func(op Op) error {
+			if op.Value > 0x7f {
+				return tooBig
+			}
+			return nil
+		}),
+	)
+	parent := ID{0, "root"}
+	for _, r := range "pin 42" {
+		parent = a.Insert(r, parent)
+	}
+	a.Insert('é', parent)
+	if b.Value() != "pin ##" || len(failed) != 1 || failed[0].Value != 'é' {
+		t.Fatalf("Expected the redacted text and one refused op, got %q and %v", b.Value(), failed)
+	}
+
+	// ...and the receive path drops deletes and audits the rest.
+	c := NewRGA("c")
+	receive := ApplyOps(c,
+		AuditOps(func(op Op, err error) { audit = append(audit, op.String()) }),
+		FilterOps(func(op Op) bool { return op.Kind != OpDelete }),
+	)
+	SendOps(b, receive, nil)
+	first := b.Insert('>', ID{0, "root"})
+	b.Delete(first)
+	if c.Value() != ">" || len(audit) != 2 {
+		t.Errorf("Expected the delete to be dropped after its audit, got %q and %v", c.Value(), audit)
+	}
+}
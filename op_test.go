@@ -0,0 +1,90 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestOp_Encoding(t *testing.T) {
+	for _, tc := range []struct {
+		op   Op
+		want string
+	}{
+		{Op{Kind: OpInsert, ID: ID{2, "a"}, Parent: ID{0, "root"}, Value: 'x'},
+			`{"kind":"insert","id":{"Timestamp":2,"NodeID":"a"},"parent":{"Timestamp":0,"NodeID":"root"},"value":120}`},
+		{Op{Kind: OpIncrement, Replica: "a", Count: 3}, `{"kind":"increment","replica":"a","count":3}`},
+	} {
+		data, err := json.Marshal(tc.op)
+		if err != nil || string(data) != tc.want {
+			t.Errorf("Expected %s, got %s (%v)", tc.want, data, err)
+		}
+		var back Op
+		if err := json.Unmarshal(data, &back); err != nil || back != tc.op {
+			t.Errorf("Expected %+v to round-trip, got %+v (%v)", tc.op, back, err)
+		}
+	}
+}
+
+func TestOp_RGA(t *testing.T) {
+	a, b := NewRGA("a"), NewRGA("b")
+	var ops []Op
+	a.OnOp(func(op Op) { ops = append(ops, op) })
+	h := a.Insert('h', ID{0, "root"})
+	i := a.Insert('i', h)
+	a.Delete(h)
+	a.Delete(h)
+	a.Delete(ID{9, "nobody"})
+	if len(ops) != 3 || ops[2].Kind != OpDelete || ops[2].Value != 'h' {
+		t.Fatalf("Expected insert, insert and delete ops, got %v", ops)
+	}
+
+	// Out of order, the delete still converges: it carries its node.
+	for _, k := range []int{2, 1, 0} {
+		if _, err := b.ApplyOp(ops[k]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.Value() != "i" || b.Stats().Orphans != 0 {
+		t.Errorf("Expected %q, got %q with %+v", "i", b.Value(), b.Stats())
+	}
+	if result, _ := b.ApplyOp(ops[1]); result.Duplicates != 1 {
+		t.Errorf("Expected a re-applied op to be a duplicate, got %+v", result)
+	}
+	if _, err := b.ApplyOp(Op{Kind: OpIncrement, ID: i}); !errors.Is(err, ErrUnsupportedOp) {
+		t.Errorf("Expected ErrUnsupportedOp, got %v", err)
+	}
+}
+
+func TestOp_Counters(t *testing.T) {
+	a, b := NewPNCounter("a"), NewUnsyncPNCounter("b")
+	var ops []Op
+	a.OnOp(func(op Op) { ops = append(ops, op) })
+	a.Increment()
+	a.Increment()
+	a.Decrement()
+	for _, op := range ops {
+		if _, err := b.ApplyOp(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Re-applying an older slot is harmless.
+	b.ApplyOp(ops[0])
+	if b.Value() != 1 || ops[1] != (Op{Kind: OpIncrement, Replica: "a", Count: 2}) {
+		t.Errorf("Expected 1 from %v, got %d", ops, b.Value())
+	}
+
+	g := NewGCounter("g")
+	if _, err := g.ApplyOp(ops[2]); !errors.Is(err, ErrUnsupportedOp) {
+		t.Errorf("Expected a decrement on a GCounter to be unsupported, got %v", err)
+	}
+	var got []Op
+	g.OnOp(func(op Op) { got = append(got, op) })
+	g.Increment()
+	if len(got) != 1 || got[0].String() != "increment g=1" {
+		t.Errorf("Unexpected GCounter ops %v", got)
+	}
+
+	var _ Operable = NewUnsyncRGA("x")
+	var _ Operable = NewUnsyncGCounter("x")
+}
@@ -0,0 +1,150 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Option tunes a CRDT at construction, as in NewRGA("alice",
+// WithMaxOrphans(10_000)). Every constructor, NewUnsync* included, accepts
+// the same options; an option that does not apply to a type is ignored by
+// its constructor, as documented on each option.
+type Option func(*options)
+
+// options is the configuration the Options of a constructor build.
+type options struct {
+	clock      int64
+	locking    LockingMode
+	maxOrphans int
+	codec      Codec
+	logger     Logger
+
+	requestWindow int
+	overflow      OverflowPolicy
+	tieBreak      TieBreak
+	journal       *ConflictJournal
+	maxValue      int
+	sizePolicy    SizePolicy
+	resolver      any // a configResolver, see WithResolver
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) options {
+	o := options{codec: JSONCodec{}, requestWindow: DefaultRequestWindow}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock starts the Lamport clock of an RGA at clock instead of 0, for
+// instance at the Clock of the state a replica is restored from, so its new
+// nodes never reuse the IDs of nodes it created before. Counters ignore it.
+func WithClock(clock int64) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+// LockingMode selects how a synchronized CRDT guards its state.
+type LockingMode int
+
+const (
+	// LockReadWrite guards the state with a read/write mutex, letting
+	// readers proceed together. It is the default.
+	LockReadWrite LockingMode = iota
+
+	// LockExclusive guards the state with a plain mutex, which is cheaper
+	// when writes dominate and readers gain nothing from sharing.
+	LockExclusive
+
+	// LockNone does not lock at all, for a document owned by a single
+	// goroutine that needs the synchronized type's API (change
+	// notifications, cached rendering). Like the Unsync types, it must not
+	// be shared between goroutines.
+	LockNone
+)
+
+// WithLocking selects the LockingMode of an RGA or a PNCounter. GCounter is
+// lock-free and the Unsync types never lock, so they ignore it: NewUnsyncRGA
+// remains the way to get the bare, lock-free core of a document.
+func WithLocking(mode LockingMode) Option {
+	return func(o *options) { o.locking = mode }
+}
+
+// WithMaxOrphans bounds the number of orphans an RGA buffers while waiting
+// for their parents. Orphans past the bound are dropped and counted as
+// Rejected, so a peer sending nodes whose parents never come cannot grow
+// the buffer without limit; the dropped nodes are merged again when a
+// later sync resends them. 0, the default, means no bound. Counters ignore
+// it.
+func WithMaxOrphans(n int) Option {
+	return func(o *options) { o.maxOrphans = n }
+}
+
+// WithCodec sets the Codec of MarshalState, MergeState and the other
+// payloads of a CRDT (JSONCodec by default). Replicas exchanging payloads
+// must use the same codec. The streaming WriteTo and MergeFrom always use
+// JSON.
+func WithCodec(c Codec) Option {
+	return func(o *options) { o.codec = c }
+}
+
+// WithLogger sets the Logger of an RGA, as SetLogger does. Counters log
+// nothing and ignore it.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithRequestWindow sets how many client request IDs the *Once mutations,
+// such as RGA.InsertOnce, remember to recognize retries (DefaultRequestWindow
+// by default). A retry arriving after n later requests applies again; 0
+// remembers none.
+func WithRequestWindow(n int) Option {
+	return func(o *options) { o.requestWindow = n }
+}
+
+// Codec encodes and decodes the payloads of the CRDTs.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec, encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// rwLocker is the lock of a synchronized CRDT, see LockingMode.
+type rwLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// newLocker returns the lock for mode.
+func newLocker(mode LockingMode) rwLocker {
+	switch mode {
+	case LockExclusive:
+		return new(exclusiveLocker)
+	case LockNone:
+		return noLocker{}
+	}
+	return new(sync.RWMutex)
+}
+
+// exclusiveLocker takes its mutex for readers too.
+type exclusiveLocker struct {
+	sync.Mutex
+}
+
+func (l *exclusiveLocker) RLock()   { l.Lock() }
+func (l *exclusiveLocker) RUnlock() { l.Unlock() }
+
+// noLocker does not lock.
+type noLocker struct{}
+
+func (noLocker) Lock()    {}
+func (noLocker) Unlock()  {}
+func (noLocker) RLock()   {}
+func (noLocker) RUnlock() {}
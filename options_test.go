@@ -0,0 +1,97 @@
+package gocrdt
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gobCodec is a Codec other than JSON.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestOptions(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	doc := NewRGA("a", WithClock(41), WithMaxOrphans(1), WithLogger(logger))
+	if id := doc.Insert('x', ID{0, "root"}); id.Timestamp != 42 {
+		t.Errorf("Expected the clock to start at 41, got %v", id)
+	}
+
+	missing := ID{50, "b"}
+	orphans := []Node{{ID: ID{51, "b"}, ParentID: missing}, {ID: ID{52, "b"}, ParentID: missing}}
+	if result := doc.Merge(orphans); result.Orphaned != 1 || result.Rejected != 1 {
+		t.Errorf("Expected one buffered and one dropped orphan, got %+v", result)
+	}
+	if !strings.Contains(buf.String(), "dropped orphan past the buffer bound") {
+		t.Errorf("Expected the drop to be logged, got %q", buf.String())
+	}
+	// Releasing the buffer makes room again.
+	doc.Merge([]Node{{ID: missing, ParentID: ID{0, "root"}}, orphans[1]})
+	if doc.Stats().Orphans != 0 || len(doc.Nodes()) != 4 {
+		t.Errorf("Expected the orphans to be released, got %+v", doc.Stats())
+	}
+
+	// Replicas sharing a codec exchange payloads in it.
+	a, b := NewPNCounter("a", WithCodec(gobCodec{})), NewUnsyncPNCounter("b", WithCodec(gobCodec{}))
+	a.Decrement()
+	state, _ := a.MarshalState()
+	if _, err := b.MergeState(state); err != nil || b.Value() != -1 {
+		t.Errorf("Expected the gob payload to merge, got %d and %v", b.Value(), err)
+	}
+	if _, err := NewPNCounter("c").MergeState(state); err == nil {
+		t.Error("Expected a JSON replica to refuse a gob payload")
+	}
+	text := NewUnsyncRGA("a", WithCodec(gobCodec{}))
+	text.Insert('g', ID{0, "root"})
+	state, _ = text.MarshalState()
+	copied := NewRGA("b", WithCodec(gobCodec{}))
+	if _, err := copied.MergeState(state); err != nil || copied.Value() != "g" {
+		t.Errorf("Expected the gob document to merge, got %q and %v", copied.Value(), err)
+	}
+}
+
+func TestOptions_Locking(t *testing.T) {
+	for _, mode := range []LockingMode{LockReadWrite, LockExclusive} {
+		doc, counter := NewRGA("a", WithLocking(mode)), NewPNCounter("a", WithLocking(mode))
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					doc.Insert('x', ID{0, "root"})
+					counter.Increment()
+					_ = doc.Nodes()
+					_ = counter.Value()
+				}
+			}()
+		}
+		wg.Wait()
+		if len(doc.Nodes()) != 400 || counter.Value() != 400 {
+			t.Errorf("Mode %d: expected 400 edits, got %d and %d", mode, len(doc.Nodes()), counter.Value())
+		}
+	}
+
+	// Without locks, a single owner still gets change notifications.
+	doc := NewRGA("a", WithLocking(LockNone))
+	notify := doc.ChangeNotify()
+	doc.Insert('x', ID{0, "root"})
+	select {
+	case <-notify:
+	default:
+		t.Error("Expected a change notification")
+	}
+}
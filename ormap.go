@@ -0,0 +1,250 @@
+package gocrdt
+
+import (
+	"encoding/json"
+)
+
+// orMapValue is the wire representation of a value of an ORMap.
+type orMapValue[K comparable] struct {
+	Key   K               `json:"k"`
+	State json.RawMessage `json:"state"`
+}
+
+// orMapState is the wire representation of an ORMap.
+type orMapState[K comparable] struct {
+	V      int             `json:"v"`
+	Keys   json.RawMessage `json:"keys"`
+	Values []orMapValue[K] `json:"values"`
+}
+
+// UnsyncORMap is the unsynchronized core of an ORMap.
+//
+// Like the other Unsync types it performs no locking and must be owned by a
+// single goroutine; use ORMap when the map is shared between goroutines.
+type UnsyncORMap[K comparable, V Replicable] struct {
+	nodeID   string
+	keys     UnsyncORSet[K]
+	values   map[K]V // Values of present and deleted keys
+	newValue func(replicaID string) V
+}
+
+// NewUnsyncORMap creates an empty UnsyncORMap for the replica nodeID. See
+// NewORMap.
+func NewUnsyncORMap[K comparable, V Replicable](nodeID string, newValue func(replicaID string) V) *UnsyncORMap[K, V] {
+	return &UnsyncORMap[K, V]{
+		nodeID:   nodeID,
+		keys:     *NewUnsyncORSet[K](nodeID),
+		values:   make(map[K]V),
+		newValue: newValue,
+	}
+}
+
+// value returns the value of k, creating it if needed.
+func (m *UnsyncORMap[K, V]) value(k K) V {
+	v, ok := m.values[k]
+	if !ok {
+		v = m.newValue(m.nodeID)
+		m.values[k] = v
+	}
+	return v
+}
+
+// Update adds k to the map and applies fn to its value. See ORMap.Update.
+func (m *UnsyncORMap[K, V]) Update(k K, fn func(V)) {
+	m.keys.Add(k)
+	fn(m.value(k))
+}
+
+// Get returns the value of k, if k is in the map.
+func (m *UnsyncORMap[K, V]) Get(k K) (V, bool) {
+	if !m.keys.Contains(k) {
+		var zero V
+		return zero, false
+	}
+	return m.value(k), true
+}
+
+// Delete removes k from the map. See ORMap.Delete.
+func (m *UnsyncORMap[K, V]) Delete(k K) {
+	m.keys.Remove(k)
+}
+
+// Keys returns the keys of the map, in no particular order.
+func (m *UnsyncORMap[K, V]) Keys() []K {
+	return m.keys.Elements()
+}
+
+// Len returns the number of keys in the map.
+func (m *UnsyncORMap[K, V]) Len() int {
+	return m.keys.Len()
+}
+
+// Entries returns the values of the keys in the map.
+func (m *UnsyncORMap[K, V]) Entries() map[K]V {
+	entries := make(map[K]V, m.keys.Len())
+	for _, k := range m.keys.Elements() {
+		entries[k] = m.value(k)
+	}
+	return entries
+}
+
+// MarshalState encodes the keys and the state of every value, including
+// those of deleted keys.
+func (m *UnsyncORMap[K, V]) MarshalState() ([]byte, error) {
+	keys, err := m.keys.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	state := orMapState[K]{V: WireVersion, Keys: keys, Values: make([]orMapValue[K], 0, len(m.values))}
+	for k, v := range m.values {
+		data, err := v.MarshalState()
+		if err != nil {
+			return nil, err
+		}
+		state.Values = append(state.Values, orMapValue[K]{k, data})
+	}
+	return json.Marshal(state)
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// the keys, then every value. The result sums all merges.
+func (m *UnsyncORMap[K, V]) MergeState(data []byte) (MergeResult, error) {
+	var state orMapState[K]
+	if err := json.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+	result, err := m.keys.MergeState(state.Keys)
+	if err != nil {
+		return result, err
+	}
+	for _, value := range state.Values {
+		merged, err := m.value(value.Key).MergeState(value.State)
+		result.Add(merged)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// ORMap is a map whose keys form an OR-Set and whose values are CRDTs of a
+// single type V, such as a map of counters or of documents:
+//
+//	votes := NewORMap[string]("a", func(id string) *GCounter {
+//		return NewGCounter(id)
+//	})
+//	votes.Update("option-1", (*GCounter).Increment)
+//	count, ok := votes.Get("option-1") // count is a *GCounter
+//
+// Keys are added and deleted like the elements of an ORSet: an update of a
+// key concurrent with its deletion wins. Values merge as their own type
+// does, so concurrent updates of a key combine instead of one overwriting
+// the other.
+//
+// Deleting a key hides it but keeps the state of its value, which other
+// replicas may still be merging into: a key updated again continues from
+// that state rather than from an empty value.
+//
+// Payloads are JSON, with the states of the values embedded, so V must
+// encode its states with JSONCodec. ORMap is safe for concurrent use; it
+// wraps an UnsyncORMap with a read/write mutex, or the lock chosen with
+// WithLocking.
+type ORMap[K comparable, V Replicable] struct {
+	mu rwLocker // See WithLocking
+	m  UnsyncORMap[K, V]
+}
+
+// NewORMap creates an empty ORMap for the replica nodeID. newValue creates
+// the empty value of a key on its first update or merge, for the replica
+// ID it is given. Of the options, only WithLocking applies.
+func NewORMap[K comparable, V Replicable](nodeID string, newValue func(replicaID string) V, opts ...Option) *ORMap[K, V] {
+	return &ORMap[K, V]{
+		mu: newLocker(newOptions(opts).locking),
+		m:  *NewUnsyncORMap[K](nodeID, newValue),
+	}
+}
+
+// Update adds k to the map, if it is not already in it, and applies fn to
+// its value, such as an Increment or an Insert, with the map locked. fn
+// must not call back into the map.
+func (m *ORMap[K, V]) Update(k K, fn func(V)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Update(k, fn)
+}
+
+// Get returns the value of k, if k is in the map. The value may be
+// mutated directly when it is safe for concurrent use, as the synchronized
+// CRDTs of this package are; Update also applies to keys not in the map.
+func (m *ORMap[K, V]) Get(k K) (V, bool) {
+	m.mu.RLock()
+	v, ok := m.m.values[k]
+	present := m.m.keys.Contains(k)
+	m.mu.RUnlock()
+	if ok || !present {
+		return v, present
+	}
+
+	// Creating the missing value writes.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Get(k)
+}
+
+// Delete removes k from the map, undoing the updates of k this replica has
+// observed.
+func (m *ORMap[K, V]) Delete(k K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Delete(k)
+}
+
+// Keys returns the keys of the map, in no particular order.
+func (m *ORMap[K, V]) Keys() []K {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Keys()
+}
+
+// Len returns the number of keys in the map.
+func (m *ORMap[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Len()
+}
+
+// Entries returns the values of the keys in the map.
+func (m *ORMap[K, V]) Entries() map[K]V {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Entries()
+}
+
+// MarshalState encodes the keys and the state of every value, including
+// those of deleted keys.
+func (m *ORMap[K, V]) MarshalState() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.MarshalState()
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// the keys, then every value. The result sums all merges.
+func (m *ORMap[K, V]) MergeState(data []byte) (MergeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.MergeState(data)
+}
+
+// Merge combines the state of another replica of the map into this one,
+// through its payload.
+func (m *ORMap[K, V]) Merge(other *ORMap[K, V]) (MergeResult, error) {
+	data, err := other.MarshalState()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return m.MergeState(data)
+}
@@ -0,0 +1,41 @@
+package gocrdt
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestORMap(t *testing.T) {
+	newCounter := func(id string) *GCounter { return NewGCounter(id) }
+	a, b := NewORMap[string]("a", newCounter), NewORMap[string]("b", newCounter)
+	a.Update("yes", (*GCounter).Increment)
+	b.Update("yes", (*GCounter).Increment)
+	b.Update("no", (*GCounter).Increment)
+
+	a.Merge(b)
+	b.Merge(a)
+	for _, m := range []*ORMap[string, *GCounter]{a, b} {
+		yes, ok := m.Get("yes")
+		if !ok || yes.Value() != 2 || m.Len() != 2 {
+			t.Errorf("Expected concurrent updates of a key to combine, got %v", m.Entries())
+		}
+	}
+
+	// An update concurrent with a deletion wins.
+	a.Delete("no")
+	b.Update("no", (*GCounter).Increment)
+	a.Merge(b)
+	if no, ok := a.Get("no"); !ok || no.Value() != 2 {
+		t.Errorf("Expected the concurrent update to keep the key, got %v", a.Keys())
+	}
+
+	// A deletion observed by every update sticks.
+	b.Delete("yes")
+	state, _ := b.MarshalState()
+	if _, err := a.MergeState(state); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if _, ok := a.Get("yes"); ok || !slices.Equal(a.Keys(), []string{"no"}) {
+		t.Errorf("Expected only the key no, got %v", a.Keys())
+	}
+}
@@ -0,0 +1,188 @@
+package gocrdt
+
+import "sort"
+
+// sortIDs sorts ids in ascending order.
+func sortIDs(ids []ID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[j].Greater(ids[i]) })
+}
+
+// PendingOrphans returns the IDs of the buffered orphans, sorted. See
+// RGA.PendingOrphans.
+func (r *UnsyncRGA) PendingOrphans() []ID {
+	ids := make([]ID, 0, r.orphans)
+	for _, orphans := range r.pendingOrphans {
+		for _, n := range orphans {
+			ids = append(ids, n.ID)
+		}
+	}
+	sortIDs(ids)
+	return ids
+}
+
+// MissingParents returns the nodes the buffered orphans wait for, sorted.
+// See RGA.MissingParents.
+func (r *UnsyncRGA) MissingParents() []ID {
+	buffered := make(map[ID]bool, r.orphans)
+	for _, orphans := range r.pendingOrphans {
+		for _, n := range orphans {
+			buffered[n.ID] = true
+		}
+	}
+	missing := make([]ID, 0, len(r.pendingOrphans))
+	for parent := range r.pendingOrphans {
+		if !buffered[parent] {
+			missing = append(missing, parent)
+		}
+	}
+	sortIDs(missing)
+	return missing
+}
+
+// OnOrphanResolved registers fn to be called for every buffered orphan
+// whose parent arrives, with the orphan's ID and its parent's; nil removes
+// it. See RGA.OnOrphanResolved.
+func (r *UnsyncRGA) OnOrphanResolved(fn func(id, parent ID)) {
+	r.onResolved = fn
+}
+
+// OnOrphanStuck registers fn to be called at the end of a merge that left
+// orphans waiting, with the missing parents; nil removes it. See
+// RGA.OnOrphanStuck.
+func (r *UnsyncRGA) OnOrphanStuck(fn func(missing []ID)) {
+	r.onStuck = fn
+}
+
+// reportStuck calls the OnOrphanStuck callback if the merge ending buffered
+// orphans that are still waiting.
+func (r *UnsyncRGA) reportStuck() {
+	buffered := r.buffered
+	r.buffered = false
+	if !buffered || r.onStuck == nil {
+		return
+	}
+	if missing := r.MissingParents(); len(missing) > 0 {
+		r.onStuck(missing)
+	}
+}
+
+// DropOrphans removes the buffered orphans ids from the buffer and returns
+// the number removed. See RGA.DropOrphans.
+func (r *UnsyncRGA) DropOrphans(ids ...ID) int {
+	drop := make(map[ID]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	dropped := 0
+	for parent, orphans := range r.pendingOrphans {
+		kept := orphans[:0]
+		for _, n := range orphans {
+			if drop[n.ID] {
+				dropped++
+			} else {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.pendingOrphans, parent)
+		} else {
+			r.pendingOrphans[parent] = kept
+		}
+	}
+	r.orphans -= dropped
+	if dropped > 0 && r.logger != nil {
+		r.logger.Info("gocrdt: dropped buffered orphans", "count", dropped)
+	}
+	return dropped
+}
+
+// MarshalAncestry encodes the nodes ids and their ancestors as a payload
+// for MergeState. See RGA.MarshalAncestry.
+func (r *UnsyncRGA) MarshalAncestry(ids []ID) ([]byte, error) {
+	included := make(map[ID]bool)
+	var nodes []Node
+	for _, id := range ids {
+		for id != r.root.ID && !included[id] {
+			var n Node
+			if node, ok := r.registry[id]; ok {
+				n = Node{ID: id, ParentID: node.ParentID, Value: node.Value, Deleted: node.Deleted}
+			} else if parent, ok := r.collected[id]; ok {
+				n = Node{ID: id, ParentID: parent, Deleted: true}
+			} else {
+				break
+			}
+			included[id] = true
+			nodes = append(nodes, n)
+			id = n.ParentID
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	// Timestamps grow from parent to child, so this is a causal order.
+	sort.Slice(nodes, func(i, j int) bool { return nodes[j].ID.Greater(nodes[i].ID) })
+	return r.encodeNodes(nodes, r.tieBreak)
+}
+
+// PendingOrphans returns the IDs of the remote nodes buffered until their
+// parent arrives, sorted.
+func (r *RGA) PendingOrphans() []ID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.PendingOrphans()
+}
+
+// MissingParents returns the nodes the buffered orphans wait for, sorted:
+// the parents that are neither part of the document nor buffered
+// themselves. A sync layer can ask its peers for them, such as with a
+// state from which they are reachable, instead of waiting for the next
+// full-state exchange.
+func (r *RGA) MissingParents() []ID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MissingParents()
+}
+
+// OnOrphanResolved registers fn to be called for every buffered orphan
+// whose parent arrives, with the orphan's ID and its parent's, just before
+// the orphan is merged; nil removes it. fn runs under the document's lock
+// and must not call back into it.
+func (r *RGA) OnOrphanResolved(fn func(id, parent ID)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doc.OnOrphanResolved(fn)
+}
+
+// OnOrphanStuck registers fn to be called at the end of every merge that
+// buffered orphans whose parent it did not bring, with MissingParents; nil
+// removes it. A stream merged with MergeFrom ends a merge with every
+// chunk. fn runs under the document's lock and must not call back into it,
+// but may hand the IDs to a goroutine requesting them from peers.
+func (r *RGA) OnOrphanStuck(fn func(missing []ID)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doc.OnOrphanStuck(fn)
+}
+
+// DropOrphans removes the buffered orphans ids from the buffer, such as
+// those waiting too long for a parent that may never come, and returns the
+// number removed. Like the orphans WithMaxOrphans drops, they are merged
+// again when a later sync resends them with their parent.
+func (r *RGA) DropOrphans(ids ...ID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doc.DropOrphans(ids...)
+}
+
+// MarshalAncestry encodes the nodes ids and their ancestors, tombstones
+// collected by CompactStable included, as a payload for MergeState: the
+// answer to a peer whose MissingParents are ids, which that peer merges
+// without new orphans. IDs the document has never seen are skipped; it
+// returns nil when it knows none of them. The ancestors of a node typed at
+// the end of a text are the text before it, so the payload can come close
+// to the whole state, but never exceeds it.
+func (r *RGA) MarshalAncestry(ids []ID) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MarshalAncestry(ids)
+}
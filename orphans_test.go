@@ -0,0 +1,86 @@
+package gocrdt
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRGA_PendingOrphans(t *testing.T) {
+	source := NewRGA("a")
+	a := source.Insert('a', ID{0, "root"})
+	b := source.Insert('b', a)
+	c := source.Insert('c', b)
+	nodes := source.Nodes()
+
+	doc := NewRGA("b")
+	var stuck [][]ID
+	var resolved []ID
+	doc.OnOrphanStuck(func(missing []ID) { stuck = append(stuck, missing) })
+	doc.OnOrphanResolved(func(id, parent ID) { resolved = append(resolved, id) })
+
+	// c then b arrive before a: both wait, for a only.
+	doc.Merge([]Node{nodes[2]})
+	doc.Merge([]Node{nodes[1]})
+	if got := doc.PendingOrphans(); !slices.Equal(got, []ID{b, c}) {
+		t.Errorf("Expected orphans %v, got %v", []ID{b, c}, got)
+	}
+	if got := doc.MissingParents(); !slices.Equal(got, []ID{a}) {
+		t.Errorf("Expected %v missing, got %v", a, got)
+	}
+	if len(stuck) != 2 || !slices.Equal(stuck[0], []ID{b}) || !slices.Equal(stuck[1], []ID{a}) {
+		t.Errorf("Expected a stuck report per merge, got %v", stuck)
+	}
+
+	doc.Merge([]Node{nodes[0]})
+	if !slices.Equal(resolved, []ID{b, c}) {
+		t.Errorf("Expected b then c to be resolved, got %v", resolved)
+	}
+	if len(stuck) != 2 {
+		t.Errorf("Expected no stuck report for a merge resolving every orphan, got %v", stuck)
+	}
+	if len(doc.PendingOrphans()) != 0 || len(doc.MissingParents()) != 0 || doc.Value() != "abc" {
+		t.Errorf("Expected every orphan merged, got %q", doc.Value())
+	}
+}
+
+func TestRGA_DropOrphans(t *testing.T) {
+	source := NewRGA("a")
+	a := source.Insert('a', ID{0, "root"})
+	b := source.Insert('b', a)
+	c := source.Insert('c', b)
+	nodes := source.Nodes()
+
+	doc := NewRGA("b")
+	doc.Merge(nodes[1:])
+	if n := doc.DropOrphans(c, ID{9, "x"}); n != 1 || !slices.Equal(doc.PendingOrphans(), []ID{b}) {
+		t.Fatalf("Expected c dropped, got %d and %v", n, doc.PendingOrphans())
+	}
+
+	// A resend brings the dropped orphan back.
+	doc.Merge(nodes)
+	if doc.Value() != "abc" || len(doc.PendingOrphans()) != 0 {
+		t.Errorf("Expected abc after the resend, got %q", doc.Value())
+	}
+}
+
+func TestRGA_MarshalAncestry(t *testing.T) {
+	source := NewRGA("a")
+	a := source.Insert('a', ID{0, "root"})
+	b := source.Insert('b', a)
+	c := source.Insert('c', b)
+	source.Insert('d', a)
+
+	doc := NewRGA("b")
+	doc.Merge([]Node{{ID: c, ParentID: b, Value: 'c'}})
+	payload, err := source.MarshalAncestry(doc.MissingParents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := doc.MergeState(payload)
+	if err != nil || result.Orphaned != 0 || doc.Value() != "abc" {
+		t.Errorf("Expected the ancestry of c to close the gap, got %q, %+v, %v", doc.Value(), result, err)
+	}
+	if payload, err := source.MarshalAncestry([]ID{{9, "z"}}); payload != nil || err != nil {
+		t.Errorf("Expected nil for unknown IDs, got %s, %v", payload, err)
+	}
+}
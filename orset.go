@@ -0,0 +1,441 @@
+package gocrdt
+
+import "slices"
+
+// dot identifies one add of an element: the replica that added it and the
+// value of that replica's clock for the add.
+type dot struct {
+	Replica string `json:"r"`
+	Counter uint64 `json:"n"`
+}
+
+// orSetEntry is the wire representation of an element of an ORSet.
+type orSetEntry[E comparable] struct {
+	Element E     `json:"e"`
+	Dots    []dot `json:"dots"`
+}
+
+// orSetState is the wire representation of an ORSet.
+type orSetState[E comparable] struct {
+	V       int               `json:"v"`
+	Clock   map[string]uint64 `json:"clock"`
+	Entries []orSetEntry[E]   `json:"entries"`
+}
+
+// UnsyncORSet is the unsynchronized core of an ORSet.
+//
+// Like the other Unsync types it performs no locking and must be owned by a
+// single goroutine; use ORSet when the set is shared between goroutines.
+type UnsyncORSet[E comparable] struct {
+	nodeID  string
+	entries map[E][]dot       // Live elements, with the adds that support them
+	clock   map[string]uint64 // Adds observed, by replica
+	codec   Codec             // See WithCodec
+	limit   valueLimit        // See WithMaxValueSize
+}
+
+// NewUnsyncORSet creates an empty UnsyncORSet for the replica nodeID. Of
+// the options, WithCodec and WithMaxValueSize apply.
+func NewUnsyncORSet[E comparable](nodeID string, opts ...Option) *UnsyncORSet[E] {
+	o := newOptions(opts)
+	return &UnsyncORSet[E]{
+		nodeID:  nodeID,
+		entries: make(map[E][]dot),
+		clock:   make(map[string]uint64),
+		codec:   o.codec,
+		limit:   newValueLimit(o),
+	}
+}
+
+// Add adds e to the set. See ORSet.Add.
+func (s *UnsyncORSet[E]) Add(e E) {
+	_ = s.AddE(e)
+}
+
+// AddE is Add reporting an element refused. See ORSet.AddE.
+func (s *UnsyncORSet[E]) AddE(e E) error {
+	e, err := fitValue(s.limit, e)
+	if err != nil {
+		return err
+	}
+	s.clock[s.nodeID]++
+	s.entries[e] = []dot{{s.nodeID, s.clock[s.nodeID]}}
+	return nil
+}
+
+// AddAll adds every element of es to the set.
+func (s *UnsyncORSet[E]) AddAll(es ...E) {
+	for _, e := range es {
+		s.Add(e)
+	}
+}
+
+// Remove removes e from the set. See ORSet.Remove.
+func (s *UnsyncORSet[E]) Remove(e E) {
+	delete(s.entries, e)
+}
+
+// RemoveAll removes every element of es from the set.
+func (s *UnsyncORSet[E]) RemoveAll(es ...E) {
+	for _, e := range es {
+		delete(s.entries, e)
+	}
+}
+
+// Contains reports whether e is in the set.
+func (s *UnsyncORSet[E]) Contains(e E) bool {
+	_, ok := s.entries[e]
+	return ok
+}
+
+// Len returns the number of elements in the set.
+func (s *UnsyncORSet[E]) Len() int {
+	return len(s.entries)
+}
+
+// Elements returns the elements of the set, in no particular order.
+func (s *UnsyncORSet[E]) Elements() []E {
+	elements := make([]E, 0, len(s.entries))
+	for e := range s.entries {
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// Value returns the elements of the set, as Elements.
+func (s *UnsyncORSet[E]) Value() any {
+	return s.Elements()
+}
+
+// Union returns the elements in this set or in others. See ORSet.Union.
+func (s *UnsyncORSet[E]) Union(others ...[]E) []E {
+	return union(s.Elements(), others)
+}
+
+// Intersect returns the elements in this set and in every one of others.
+func (s *UnsyncORSet[E]) Intersect(others ...[]E) []E {
+	return intersect(s.Elements(), others)
+}
+
+// Difference returns the elements in this set and in none of others.
+func (s *UnsyncORSet[E]) Difference(others ...[]E) []E {
+	return difference(s.Elements(), others)
+}
+
+// Merge combines the state of another UnsyncORSet into this one. See
+// ORSet.Merge.
+func (s *UnsyncORSet[E]) Merge(other *UnsyncORSet[E]) MergeResult {
+	return s.mergeEntries(other.entries, other.clock)
+}
+
+// mergeEntries merges remote entries and clock within WithMaxValueSize.
+func (s *UnsyncORSet[E]) mergeEntries(entries map[E][]dot, clock map[string]uint64) MergeResult {
+	entries, rejected := s.fit(entries)
+	result := s.merge(entries, clock)
+	result.Rejected += rejected
+	return result
+}
+
+// fit applies WithMaxValueSize to remote entries: elements over it are
+// dropped, or renamed to their truncated form, with the adds of both. It
+// returns entries itself when nothing changes, and the number of adds
+// dropped. Since the remote clock still covers dropped adds, merging the
+// set back into replicas that kept them removes them there too.
+func (s *UnsyncORSet[E]) fit(entries map[E][]dot) (map[E][]dot, int) {
+	if s.limit.max <= 0 {
+		return entries, 0
+	}
+	fitted := make(map[E][]dot, len(entries))
+	rejected := 0
+	for e, dots := range entries {
+		e, err := fitValue(s.limit, e)
+		if err != nil {
+			rejected += len(dots)
+			continue
+		}
+		for _, d := range dots {
+			if !slices.Contains(fitted[e], d) {
+				fitted[e] = append(fitted[e], d)
+			}
+		}
+	}
+	return fitted, rejected
+}
+
+// merge joins remote entries and clock into the set. An add survives when
+// both replicas have it, or when the replica lacking it has not observed
+// it yet: the other way round, it was removed there.
+func (s *UnsyncORSet[E]) merge(entries map[E][]dot, clock map[string]uint64) MergeResult {
+	var result MergeResult
+	for e, local := range s.entries {
+		remote := entries[e]
+		kept := local[:0]
+		for _, d := range local {
+			if slices.Contains(remote, d) || d.Counter > clock[d.Replica] {
+				kept = append(kept, d)
+			} else {
+				result.Deleted++
+			}
+		}
+		s.entries[e] = kept
+	}
+	for e, remote := range entries {
+		local := s.entries[e]
+		for _, d := range remote {
+			switch {
+			case slices.Contains(local, d):
+				result.Duplicates++
+			case d.Counter > s.clock[d.Replica]:
+				local = append(local, d)
+				result.Applied++
+			default:
+				result.Duplicates++ // Removed here
+			}
+		}
+		s.entries[e] = local
+	}
+	for e, dots := range s.entries {
+		if len(dots) == 0 {
+			delete(s.entries, e)
+		}
+	}
+	for replica, counter := range clock {
+		s.clock[replica] = max(s.clock[replica], counter)
+	}
+	return result
+}
+
+// MarshalState encodes the elements of the set with their adds and the
+// clock of the adds observed.
+func (s *UnsyncORSet[E]) MarshalState() ([]byte, error) {
+	state := orSetState[E]{V: WireVersion, Clock: s.clock, Entries: make([]orSetEntry[E], 0, len(s.entries))}
+	for e, dots := range s.entries {
+		state.Entries = append(state.Entries, orSetEntry[E]{e, dots})
+	}
+	return s.codec.Marshal(state)
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this set.
+func (s *UnsyncORSet[E]) MergeState(data []byte) (MergeResult, error) {
+	entries, clock, err := decodeORSet[E](s.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return s.mergeEntries(entries, clock), nil
+}
+
+func decodeORSet[E comparable](c Codec, data []byte) (map[E][]dot, map[string]uint64, error) {
+	var state orSetState[E]
+	if err := c.Unmarshal(data, &state); err != nil {
+		return nil, nil, versionError(c, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return nil, nil, err
+	}
+	entries := make(map[E][]dot, len(state.Entries))
+	for _, entry := range state.Entries {
+		entries[entry.Element] = append(entries[entry.Element], entry.Dots...)
+	}
+	return entries, state.Clock, nil
+}
+
+// ORSet is an observed-remove set: elements can be added and removed on
+// any replica, and an add concurrent with a remove of the same element
+// wins, since the remove only covers the adds it observed.
+//
+// Every add is tagged with a dot, the replica and its add counter. The set
+// keeps the dots of its live elements and a clock of the adds observed
+// from every replica, and no tombstones: an add missing from a merged
+// state was removed there if the remote clock covers it, and is unknown
+// there otherwise. Elements are encoded in payloads as they are by the
+// codec, JSON by default.
+//
+// ORSet is safe for concurrent use; it wraps an UnsyncORSet with a
+// read/write mutex, or the lock chosen with WithLocking.
+type ORSet[E comparable] struct {
+	mu  rwLocker // See WithLocking
+	set UnsyncORSet[E]
+}
+
+// NewORSet creates an empty ORSet for the replica nodeID, which must be
+// unique like that of a counter. Of the options, WithLocking, WithCodec and
+// WithMaxValueSize apply.
+func NewORSet[E comparable](nodeID string, opts ...Option) *ORSet[E] {
+	return &ORSet[E]{
+		mu:  newLocker(newOptions(opts).locking),
+		set: *NewUnsyncORSet[E](nodeID, opts...),
+	}
+}
+
+// Add adds e to the set. Adding an element already in the set replaces the
+// adds it observed with a new one, which a concurrent remove of the
+// element does not cover. An element over WithMaxValueSize is truncated
+// under SizeTruncate, and not added otherwise; AddE reports it.
+func (s *ORSet[E]) Add(e E) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Add(e)
+}
+
+// AddE is Add returning an error wrapping ErrValueTooLarge for an element
+// refused by WithMaxValueSize.
+func (s *ORSet[E]) AddE(e E) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.AddE(e)
+}
+
+// AddAll adds every element of es to the set, under a single lock.
+func (s *ORSet[E]) AddAll(es ...E) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.AddAll(es...)
+}
+
+// Remove removes e from the set, undoing the adds of e this replica has
+// observed. Removing an element not in the set does nothing.
+func (s *ORSet[E]) Remove(e E) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Remove(e)
+}
+
+// RemoveAll removes every element of es from the set, under a single lock.
+func (s *ORSet[E]) RemoveAll(es ...E) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.RemoveAll(es...)
+}
+
+// Contains reports whether e is in the set.
+func (s *ORSet[E]) Contains(e E) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Contains(e)
+}
+
+// Len returns the number of elements in the set.
+func (s *ORSet[E]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Len()
+}
+
+// Elements returns the elements of the set, in no particular order.
+func (s *ORSet[E]) Elements() []E {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Elements()
+}
+
+// Value returns the elements of the set, as Elements.
+func (s *ORSet[E]) Value() any {
+	return s.Elements()
+}
+
+// Union returns the elements in this set or in any of others, such as the
+// Elements of other sets, each once and in no particular order. Like
+// Intersect and Difference, it only reads: merging replicas of the same
+// set is Merge.
+func (s *ORSet[E]) Union(others ...[]E) []E {
+	return union(s.Elements(), others)
+}
+
+// Intersect returns the elements in this set and in every one of others.
+func (s *ORSet[E]) Intersect(others ...[]E) []E {
+	return intersect(s.Elements(), others)
+}
+
+// Difference returns the elements in this set and in none of others.
+func (s *ORSet[E]) Difference(others ...[]E) []E {
+	return difference(s.Elements(), others)
+}
+
+// Merge combines the state of another replica of the set into this one.
+// Adds both replicas have, or that this one has not observed, are kept;
+// adds the other replica observed and no longer has were removed there and
+// are dropped here. The returned MergeResult counts the adds integrated as
+// Applied and those dropped as Deleted. Remote elements over
+// WithMaxValueSize are truncated under SizeTruncate, and their adds are
+// counted as Rejected otherwise.
+//
+// The remote state is copied before the local lock is taken, so two sets
+// merging into each other concurrently cannot deadlock.
+func (s *ORSet[E]) Merge(other *ORSet[E]) MergeResult {
+	other.mu.RLock()
+	entries := make(map[E][]dot, len(other.set.entries))
+	for e, dots := range other.set.entries {
+		entries[e] = slices.Clone(dots)
+	}
+	clock := make(map[string]uint64, len(other.set.clock))
+	for replica, counter := range other.set.clock {
+		clock[replica] = counter
+	}
+	other.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.mergeEntries(entries, clock)
+}
+
+// MarshalState encodes the elements of the set with their adds and the
+// clock of the adds observed.
+func (s *ORSet[E]) MarshalState() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.MarshalState()
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this set. Decoding happens before the lock is taken.
+func (s *ORSet[E]) MergeState(data []byte) (MergeResult, error) {
+	entries, clock, err := decodeORSet[E](s.set.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.mergeEntries(entries, clock), nil
+}
+
+// union returns the elements of set and of others, each once.
+func union[E comparable](set []E, others [][]E) []E {
+	seen := make(map[E]bool, len(set))
+	var out []E
+	for _, list := range append([][]E{set}, others...) {
+		for _, e := range list {
+			if !seen[e] {
+				seen[e] = true
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+// intersect returns the elements of set found in every one of others.
+func intersect[E comparable](set []E, others [][]E) []E {
+	out := set
+	for _, list := range others {
+		in := make(map[E]bool, len(list))
+		for _, e := range list {
+			in[e] = true
+		}
+		out = slices.DeleteFunc(out, func(e E) bool { return !in[e] })
+	}
+	return out
+}
+
+// difference returns the elements of set found in none of others.
+func difference[E comparable](set []E, others [][]E) []E {
+	out := set
+	for _, list := range others {
+		in := make(map[E]bool, len(list))
+		for _, e := range list {
+			in[e] = true
+		}
+		out = slices.DeleteFunc(out, func(e E) bool { return in[e] })
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package gocrdt
+
+import (
+	"slices"
+	"testing"
+)
+
+func sorted(es []string) []string {
+	slices.Sort(es)
+	return es
+}
+
+func TestORSet_AddWins(t *testing.T) {
+	a, b := NewORSet[string]("a"), NewORSet[string]("b")
+	a.AddAll("go", "rust", "zig")
+	b.Merge(a)
+
+	// b removes "go" while a adds it again: the add b did not observe wins.
+	b.Remove("go")
+	a.Add("go")
+	b.RemoveAll("zig")
+	a.Merge(b)
+	b.Merge(a)
+	for _, s := range []*ORSet[string]{a, b} {
+		if got := sorted(s.Elements()); !slices.Equal(got, []string{"go", "rust"}) || s.Len() != 2 {
+			t.Errorf("Expected [go rust], got %v", got)
+		}
+	}
+
+	// A remove observed everywhere sticks, whatever the merge order.
+	a.Remove("rust")
+	state, _ := a.MarshalState()
+	b.MergeState(state)
+	if result := a.Merge(b); result.Applied != 0 || b.Contains("rust") {
+		t.Errorf("Expected the removal to stick, got %v and %+v", b.Elements(), result)
+	}
+}
+
+func TestORSet_SetAlgebra(t *testing.T) {
+	s := NewUnsyncORSet[int]("a")
+	s.AddAll(1, 2, 3)
+	if got := s.Union([]int{3, 4}); len(got) != 4 {
+		t.Errorf("Expected 4 elements in the union, got %v", got)
+	}
+	if got := s.Intersect([]int{2, 3, 4}, []int{3}); !slices.Equal(got, []int{3}) {
+		t.Errorf("Expected [3], got %v", got)
+	}
+	if got := s.Difference([]int{1}, []int{2}); !slices.Equal(got, []int{3}) {
+		t.Errorf("Expected [3], got %v", got)
+	}
+}
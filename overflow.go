@@ -0,0 +1,52 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrOverflow is returned by the Add method of a counter whose slot would
+// exceed math.MaxInt under the OverflowError policy.
+var ErrOverflow = errors.New("gocrdt: counter overflow")
+
+// OverflowPolicy selects what a counter does when an increment would take
+// its slot past math.MaxInt. Counters never wrap around.
+type OverflowPolicy int
+
+const (
+	// OverflowSaturate stops the slot at math.MaxInt: increments past it
+	// are lost. It is the default.
+	OverflowSaturate OverflowPolicy = iota
+
+	// OverflowError leaves the slot unchanged and makes Add return an
+	// error wrapping ErrOverflow. Increment, which returns nothing, is a
+	// no-op past the limit.
+	OverflowError
+)
+
+// WithOverflow sets the OverflowPolicy of a GCounter or a PNCounter. RGAs
+// ignore it.
+func WithOverflow(policy OverflowPolicy) Option {
+	return func(o *options) { o.overflow = policy }
+}
+
+// add returns slot+n, or what the policy makes of a sum past math.MaxInt.
+func (p OverflowPolicy) add(slot, n int) (int, error) {
+	if n <= math.MaxInt-slot {
+		return slot + n, nil
+	}
+	if p == OverflowError {
+		return slot, fmt.Errorf("%w: %d + %d", ErrOverflow, slot, n)
+	}
+	return math.MaxInt, nil
+}
+
+// saturatingAdd returns sum+v for a non-negative v, stopping at
+// math.MaxInt.
+func saturatingAdd(sum, v int) int {
+	if v > math.MaxInt-sum {
+		return math.MaxInt
+	}
+	return sum + v
+}
@@ -0,0 +1,201 @@
+package gocrdt
+
+import (
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelMergeMin is the payload size below which MergeParallel merges
+// sequentially: smaller payloads do not repay starting the workers.
+const parallelMergeMin = 4096
+
+// MergeParallel is MergeChecked for bulk payloads, such as the first sync
+// of a large document, using up to workers goroutines (GOMAXPROCS when
+// workers < 1).
+//
+// The new nodes of the payload form subtrees under nodes the document
+// already has. Workers build each subtree independently, ordering siblings
+// and linking the subtree into a chain; a final sequential phase splices
+// every chain into the sequence and registers its nodes. Everything else in
+// the payload (known nodes, orphans, malformed nodes) then goes through the
+// usual merge. Payloads under parallelMergeMin nodes, or repeating a new
+// node, are merged by MergeChecked.
+//
+// The document ends up exactly as after MergeChecked. Only the result may
+// differ: nodes preceding their parent in the payload are not buffered, so
+// they count as Applied without also counting as Orphaned.
+func (r *UnsyncRGA) MergeParallel(remoteNodes []Node, workers int) (MergeResult, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || len(remoteNodes) < parallelMergeMin {
+		return r.MergeChecked(remoteNodes)
+	}
+	if result, err := r.validate(remoteNodes); err != nil {
+		return result, err
+	}
+
+	// Index the new nodes, reviving collected parents as processNode would.
+	fresh := make(map[ID]int, len(remoteNodes))
+	for i, n := range remoteNodes {
+		if _, collected := r.collected[n.ID]; collected || n.ID == r.root.ID {
+			continue
+		}
+		r.resurrect(n.ParentID)
+		if _, known := r.registry[n.ID]; known {
+			continue
+		}
+		if _, dup := fresh[n.ID]; dup {
+			return r.mergeNodes(remoteNodes), nil
+		}
+		fresh[n.ID] = i
+	}
+
+	// Subtrees hang under known nodes. Every other new node is a child of
+	// a new node; children are kept in one flat slice, by parent.
+	parents := make([]int, len(remoteNodes))
+	offsets := make([]int, len(remoteNodes)+1)
+	var roots []int
+	for i, n := range remoteNodes {
+		parents[i] = -1
+		if j, ok := fresh[n.ID]; !ok || j != i {
+			continue
+		}
+		if p, ok := fresh[n.ParentID]; ok {
+			parents[i] = p
+			offsets[p+1]++
+		} else if parent, ok := r.registry[n.ParentID]; ok && n.ID.Timestamp > parent.ID.Timestamp {
+			roots = append(roots, i)
+		}
+	}
+	for i := range remoteNodes {
+		offsets[i+1] += offsets[i]
+	}
+	children := make([]int, offsets[len(remoteNodes)])
+	filled := slices.Clone(offsets[:len(remoteNodes)])
+	for i, p := range parents {
+		if p >= 0 {
+			children[filled[p]] = i
+			filled[p]++
+		}
+	}
+	tree := subtrees{payload: remoteNodes, offsets: offsets, children: children, linked: make([]bool, len(remoteNodes)), greater: r.greater}
+
+	// Registering the nodes one by one would grow the registry many times.
+	if len(fresh) > len(r.registry) {
+		registry := make(map[ID]*Node, len(r.registry)+len(fresh))
+		maps.Copy(registry, r.registry)
+		r.registry = registry
+	}
+
+	chains := make([][]*Node, len(roots))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(workers, len(roots)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := int(next.Add(1) - 1); k < len(roots); k = int(next.Add(1) - 1) {
+				chains[k] = tree.chain(roots[k])
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result MergeResult
+	for _, chain := range chains {
+		r.splice(chain)
+		result.Applied += len(chain)
+	}
+	for _, chain := range chains {
+		for _, n := range chain {
+			for _, child := range r.release(n.ID) {
+				r.processNode(child, &result)
+			}
+		}
+	}
+	for i, n := range remoteNodes {
+		if !tree.linked[i] {
+			r.processNode(n, &result)
+		}
+	}
+	r.reportStuck()
+	return result, nil
+}
+
+// subtrees holds the new nodes of a payload as a forest: the children of
+// payload[i] are children[offsets[i]:offsets[i+1]], as payload indexes.
+type subtrees struct {
+	payload  []Node
+	offsets  []int
+	children []int
+	linked   []bool             // Nodes built into a chain
+	greater  func(a, b ID) bool // Sibling order of the document
+}
+
+// chain returns the subtree of payload[root] in sequence order, linked
+// through Next, and marks its nodes as linked. Children that are not newer
+// than their parent are left out, with their subtrees, for processNode to
+// reject. chain only writes to the children and linked entries of its own
+// subtree, so subtrees can be built concurrently.
+func (t subtrees) chain(root int) []*Node {
+	var chain []*Node
+	stack := []int{root}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := t.payload[i]
+		node := &Node{ID: n.ID, ParentID: n.ParentID, Value: n.Value, Deleted: n.Deleted}
+		if len(chain) > 0 {
+			chain[len(chain)-1].Next = node
+		}
+		chain = append(chain, node)
+		t.linked[i] = true
+
+		// Siblings are ordered by descending ID; push the smallest first
+		// so the greatest is linked next.
+		kids := t.children[t.offsets[i]:t.offsets[i+1]]
+		slices.SortFunc(kids, func(a, b int) int {
+			switch {
+			case t.greater(t.payload[a].ID, t.payload[b].ID):
+				return 1
+			case t.greater(t.payload[b].ID, t.payload[a].ID):
+				return -1
+			}
+			return 0
+		})
+		for _, k := range kids {
+			if t.payload[k].ID.Timestamp > n.ID.Timestamp {
+				stack = append(stack, k)
+			}
+		}
+	}
+	return chain
+}
+
+// splice integrates the head of chain like any new node, then links the
+// rest of the chain, its subtree, right after it.
+func (r *UnsyncRGA) splice(chain []*Node) {
+	head, tail := chain[0], chain[len(chain)-1]
+	r.integrate(head)
+	if len(chain) == 1 {
+		return
+	}
+	head.Next, tail.Next = chain[1], head.Next
+	for _, n := range chain[1:] {
+		r.registry[n.ID] = n
+		r.changes = append(r.changes, n.ID)
+		r.clock = max(r.clock, n.ID.Timestamp)
+	}
+}
+
+// MergeParallel is MergeChecked for bulk payloads, using up to workers
+// goroutines. See UnsyncRGA.MergeParallel.
+func (r *RGA) MergeParallel(remoteNodes []Node, workers int) (MergeResult, error) {
+	return r.merge(len(remoteNodes), func() (MergeResult, error) {
+		return r.doc.MergeParallel(remoteNodes, workers)
+	})
+}
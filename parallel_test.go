@@ -0,0 +1,87 @@
+package gocrdt
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// forest returns a document of n nodes typed by three replicas at random
+// positions, so that its nodes form many independent subtrees.
+func forest(rng *rand.Rand, n int) *RGA {
+	docs := []*RGA{NewRGA("a"), NewRGA("b"), NewRGA("c")}
+	known := make([][]ID, len(docs))
+	for i := range n {
+		d := rng.Intn(len(docs))
+		if i%500 == 0 {
+			for _, other := range docs {
+				docs[d].Merge(other.Nodes())
+			}
+			known[d] = known[d][:0]
+			for _, node := range docs[d].Nodes() {
+				known[d] = append(known[d], node.ID)
+			}
+		}
+		parent := ID{0, "root"}
+		if len(known[d]) > 0 && rng.Intn(100) > 0 {
+			parent = known[d][rng.Intn(len(known[d]))]
+		}
+		id := docs[d].Insert(rune('a'+i%26), parent)
+		known[d] = append(known[d], id)
+		if rng.Intn(10) == 0 {
+			docs[d].Delete(id)
+		}
+	}
+	for _, other := range docs[1:] {
+		docs[0].Merge(other.Nodes())
+	}
+	return docs[0]
+}
+
+func TestRGA_MergeParallel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	source := forest(rng, 3*parallelMergeMin)
+	nodes := source.Nodes()
+
+	// A peer knowing part of the document, receiving it all shuffled, with
+	// a malformed node.
+	shuffled := slices.Clone(nodes)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	shuffled = append(shuffled, Node{ID: ID{1, "z"}, ParentID: nodes[len(nodes)-1].ID, Value: '!'})
+	for name, payload := range map[string][]Node{"ordered": nodes, "shuffled": shuffled} {
+		want, got := NewRGA("peer"), NewRGA("peer")
+		want.Merge(nodes[:len(nodes)/3])
+		got.Merge(nodes[:len(nodes)/3])
+		wantResult, _ := want.MergeChecked(payload)
+		gotResult, err := got.MergeParallel(payload, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value() != source.Value() || !slices.Equal(got.Nodes(), want.Nodes()) {
+			t.Errorf("%s: expected the parallel merge to match the sequential one", name)
+		}
+		if gotResult.Applied != wantResult.Applied || gotResult.Rejected != wantResult.Rejected {
+			t.Errorf("%s: expected %+v, got %+v", name, wantResult, gotResult)
+		}
+		// The change log stays causal: a peer replaying it gets no orphans.
+		changes, _ := got.Changes(0)
+		if result := NewRGA("next").Merge(changes); result.Orphaned != 0 {
+			t.Errorf("%s: expected a causal change log, got %+v", name, result)
+		}
+	}
+}
+
+func BenchmarkRGA_MergeParallel(b *testing.B) {
+	nodes := forest(rand.New(rand.NewSource(1)), 100_000).Nodes()
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := NewRGA("peer").MergeParallel(nodes, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
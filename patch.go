@@ -0,0 +1,261 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextEdit replaces the text Deleted at Pos, in runes, with Inserted. The
+// edits of a TextPatch apply in order, each to the text left by the
+// previous ones.
+type TextEdit struct {
+	Pos      int
+	Deleted  string
+	Inserted string
+}
+
+// TextPatch is the change of an RGA between two calls to
+// PatchExporter.Next, for consumers that do not understand CRDTs, such as
+// search indexers or webhooks: as rune edits, a unified diff or a JSON
+// Patch.
+type TextPatch struct {
+	Old, New string
+	Edits    []TextEdit
+}
+
+// visibleRune is a visible node of an exported document.
+type visibleRune struct {
+	id ID
+	r  rune
+}
+
+// PatchExporter turns the changes of an RGA, local or merged, into
+// TextPatches:
+//
+//	exporter := NewPatchExporter(doc)
+//	for {
+//		<-doc.ChangeNotify()
+//		patch := exporter.Next()
+//		webhook.Post(patch.Unified("doc.txt"))
+//	}
+//
+// Since nodes never move, the edits are found by comparing the visible
+// nodes of the document with those of the previous call, in one pass over
+// the document. A PatchExporter must be used by one goroutine at a time.
+type PatchExporter struct {
+	doc     *RGA
+	cursor  uint64
+	visible []visibleRune
+}
+
+// NewPatchExporter creates an exporter of the changes of doc made from now
+// on.
+func NewPatchExporter(doc *RGA) *PatchExporter {
+	p := &PatchExporter{doc: doc}
+	_, p.cursor = doc.Changes(math.MaxUint64)
+	p.visible = visibleRunes(doc.Nodes())
+	return p
+}
+
+// visibleRunes returns the visible nodes of nodes, in order.
+func visibleRunes(nodes []Node) []visibleRune {
+	visible := make([]visibleRune, 0, len(nodes))
+	for _, n := range nodes {
+		if !n.Deleted {
+			visible = append(visible, visibleRune{n.ID, n.Value})
+		}
+	}
+	return visible
+}
+
+// Next returns the changes of the document since the previous call, or
+// since the exporter was created. The patch has no Edits when the document
+// did not change.
+func (p *PatchExporter) Next() TextPatch {
+	old := p.visible
+	if _, cursor := p.doc.Changes(math.MaxUint64); cursor != p.cursor {
+		p.cursor = cursor
+		p.visible = visibleRunes(p.doc.Nodes())
+	}
+	patch := TextPatch{Old: runesText(old), New: runesText(p.visible)}
+	patch.Edits = diffRunes(old, p.visible)
+	return patch
+}
+
+// runesText returns the text of visible.
+func runesText(visible []visibleRune) string {
+	var b strings.Builder
+	for _, v := range visible {
+		b.WriteRune(v.r)
+	}
+	return b.String()
+}
+
+// diffRunes returns the edits turning old into visible. Nodes that
+// survive keep their order, so nodes of old missing from visible were
+// deleted and nodes of visible missing from old inserted.
+func diffRunes(old, visible []visibleRune) []TextEdit {
+	kept := make(map[ID]bool, len(visible))
+	for _, v := range visible {
+		kept[v.id] = true
+	}
+	var edits []TextEdit
+	var edit *TextEdit
+	var deleted, inserted strings.Builder
+	flush := func() {
+		if edit != nil {
+			edit.Deleted, edit.Inserted = deleted.String(), inserted.String()
+			edits = append(edits, *edit)
+			edit = nil
+			deleted.Reset()
+			inserted.Reset()
+		}
+	}
+	i, j := 0, 0
+	for i < len(old) || j < len(visible) {
+		switch {
+		case i < len(old) && j < len(visible) && old[i].id == visible[j].id:
+			flush()
+			i, j = i+1, j+1
+			continue
+		case edit == nil:
+			edit = &TextEdit{Pos: j}
+		}
+		if i < len(old) && !kept[old[i].id] {
+			deleted.WriteRune(old[i].r)
+			i++
+		} else {
+			inserted.WriteRune(visible[j].r)
+			j++
+		}
+	}
+	flush()
+	return edits
+}
+
+// hunk is a range of lines [oldStart, oldEnd) of the old text replaced by
+// the lines [newStart, newEnd) of the new one.
+type hunk struct {
+	oldStart, oldEnd int
+	newStart, newEnd int
+}
+
+// splitLines splits text after every newline; a final newline starts no
+// line.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOf returns the line holding the rune at pos of runes.
+func lineOf(runes []rune, pos int) int {
+	line := 0
+	for _, r := range runes[:pos] {
+		if r == '\n' {
+			line++
+		}
+	}
+	return line
+}
+
+// hunks returns the lines the edits touch, merged where they overlap.
+func (p TextPatch) hunks() (oldLines, newLines []string, hunks []hunk) {
+	oldLines, newLines = splitLines(p.Old), splitLines(p.New)
+	oldRunes, newRunes := []rune(p.Old), []rune(p.New)
+	shift := 0 // runes inserted minus deleted by the previous edits
+	for _, e := range p.Edits {
+		oldPos, del, ins := e.Pos-shift, len([]rune(e.Deleted)), len([]rune(e.Inserted))
+		shift += ins - del
+		// An edit of whole lines ends before the line following it; any
+		// other edit also changes the line it ends in.
+		whole := (oldPos == 0 || oldRunes[oldPos-1] == '\n') &&
+			(del == 0 || strings.HasSuffix(e.Deleted, "\n")) &&
+			(ins == 0 || strings.HasSuffix(e.Inserted, "\n"))
+		partial := 1
+		if whole {
+			partial = 0
+		}
+		h := hunk{
+			oldStart: lineOf(oldRunes, oldPos),
+			oldEnd:   min(lineOf(oldRunes, oldPos+del)+partial, len(oldLines)),
+			newStart: lineOf(newRunes, e.Pos),
+			newEnd:   min(lineOf(newRunes, e.Pos+ins)+partial, len(newLines)),
+		}
+		if n := len(hunks); n > 0 && h.oldStart < hunks[n-1].oldEnd {
+			hunks[n-1].oldEnd = max(hunks[n-1].oldEnd, h.oldEnd)
+			hunks[n-1].newEnd = max(hunks[n-1].newEnd, h.newEnd)
+			continue
+		}
+		hunks = append(hunks, h)
+	}
+	return oldLines, newLines, hunks
+}
+
+// Unified returns the patch as a unified diff of the file name without
+// context lines, as produced by diff -U0, for patch and other tools. It is
+// empty when nothing changed.
+func (p TextPatch) Unified(name string) string {
+	oldLines, newLines, hunks := p.hunks()
+	if len(hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	writeLines := func(prefix string, lines []string) {
+		for _, line := range lines {
+			b.WriteString(prefix + line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	for _, h := range hunks {
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(h.oldStart, h.oldEnd), hunkRange(h.newStart, h.newEnd))
+		writeLines("-", oldLines[h.oldStart:h.oldEnd])
+		writeLines("+", newLines[h.newStart:h.newEnd])
+	}
+	return b.String()
+}
+
+// hunkRange formats lines [start, end) for a hunk header. An empty range
+// names the line before it.
+func hunkRange(start, end int) string {
+	if end == start {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if end-start == 1 {
+		return strconv.Itoa(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// jsonPatchOp is an operation of an RFC 6902 JSON Patch.
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"` // Set for adds, even to ""
+}
+
+// JSONPatch returns the patch as an RFC 6902 JSON Patch of the document
+// seen as an array of its lines, without their newlines: each changed line
+// is removed and the new ones added. A final newline is not represented.
+func (p TextPatch) JSONPatch() ([]byte, error) {
+	oldLines, newLines, hunks := p.hunks()
+	ops := make([]jsonPatchOp, 0)
+	for _, h := range hunks {
+		path := "/" + strconv.Itoa(h.newStart)
+		for range oldLines[h.oldStart:h.oldEnd] {
+			ops = append(ops, jsonPatchOp{Op: "remove", Path: path})
+		}
+		for i, line := range newLines[h.newStart:h.newEnd] {
+			ops = append(ops, jsonPatchOp{Op: "add", Path: "/" + strconv.Itoa(h.newStart+i), Value: strings.TrimSuffix(line, "\n")})
+		}
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,173 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// typeText appends text to doc.
+func typeText(doc *RGA, text string) {
+	parent := ID{NodeID: "root"}
+	if nodes := doc.Nodes(); len(nodes) > 0 {
+		parent = nodes[len(nodes)-1].ID
+	}
+	for _, r := range text {
+		parent = doc.Insert(r, parent)
+	}
+}
+
+// applyEdits applies the edits of a patch to text.
+func applyEdits(text string, edits []TextEdit) string {
+	runes := []rune(text)
+	for _, e := range edits {
+		tail := slices.Clone(runes[e.Pos+len([]rune(e.Deleted)):])
+		runes = append(append(runes[:e.Pos], []rune(e.Inserted)...), tail...)
+	}
+	return string(runes)
+}
+
+func TestPatchExporter_Edits(t *testing.T) {
+	doc := NewRGA("a")
+	typeText(doc, "one\ntwo\nthree\n")
+	exporter := NewPatchExporter(doc)
+	if patch := exporter.Next(); len(patch.Edits) != 0 {
+		t.Errorf("Expected no edits, got %+v", patch.Edits)
+	}
+
+	// Delete "two", then a remote replica appends a line.
+	nodes := doc.Nodes()
+	for _, n := range nodes[4:8] {
+		doc.Delete(n.ID)
+	}
+	remote := NewRGA("b")
+	remote.Merge(doc.Nodes())
+	typeText(remote, "four\n")
+	doc.Merge(remote.Nodes())
+
+	patch := exporter.Next()
+	if patch.Old != "one\ntwo\nthree\n" || patch.New != "one\nthree\nfour\n" {
+		t.Fatalf("Unexpected texts %q and %q", patch.Old, patch.New)
+	}
+	want := []TextEdit{{Pos: 4, Deleted: "two\n"}, {Pos: 10, Inserted: "four\n"}}
+	if !slices.Equal(patch.Edits, want) {
+		t.Errorf("Expected %+v, got %+v", want, patch.Edits)
+	}
+	if got := applyEdits(patch.Old, patch.Edits); got != patch.New {
+		t.Errorf("Applying the edits gave %q", got)
+	}
+
+	unified := "--- a/doc.txt\n+++ b/doc.txt\n@@ -2 +1,0 @@\n-two\n@@ -3,0 +3 @@\n+four\n"
+	if got := patch.Unified("doc.txt"); got != unified {
+		t.Errorf("Unexpected unified diff:\n%s", got)
+	}
+
+	data, err := patch.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(data, &ops); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(patch.Old, "\n"), "\n")
+	for _, op := range ops {
+		i, _ := strconv.Atoi(op.Path[1:])
+		if op.Op == "remove" {
+			lines = slices.Delete(lines, i, i+1)
+		} else {
+			lines = slices.Insert(lines, i, op.Value.(string))
+		}
+	}
+	if !slices.Equal(lines, []string{"one", "three", "four"}) {
+		t.Errorf("Applying the JSON Patch %s gave %v", data, lines)
+	}
+}
+
+func TestTextPatch_NoNewlineAtEnd(t *testing.T) {
+	patch := TextPatch{Old: "a", New: "ab", Edits: []TextEdit{{Pos: 1, Inserted: "b"}}}
+	want := "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+ab\n\\ No newline at end of file\n"
+	if got := patch.Unified("f"); got != want {
+		t.Errorf("Unexpected unified diff:\n%s", got)
+	}
+	if got := (TextPatch{Old: "a", New: "a"}).Unified("f"); got != "" {
+		t.Errorf("Expected an empty diff, got %q", got)
+	}
+}
+
+// applyUnified applies a diff -U0 style patch to text.
+func applyUnified(t *testing.T, text, diff string) string {
+	t.Helper()
+	lines := splitLines(text)
+	var out []string
+	next := 0 // next old line to copy
+	body := strings.Split(diff, "\n")[2:]
+	for i := 0; i < len(body); i++ {
+		line := body[i]
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			var oldStart, oldCount int
+			spec := strings.Fields(line)[1][1:]
+			start, count, ok := strings.Cut(spec, ",")
+			oldStart, _ = strconv.Atoi(start)
+			oldCount = 1
+			if ok {
+				oldCount, _ = strconv.Atoi(count)
+			}
+			if oldCount > 0 {
+				oldStart--
+			}
+			out = append(out, lines[next:oldStart]...)
+			next = oldStart + oldCount
+		case strings.HasPrefix(line, "+"):
+			added := line[1:] + "\n"
+			if i+1 < len(body) && strings.HasPrefix(body[i+1], `\`) {
+				added = line[1:]
+				i++
+			}
+			out = append(out, added)
+		case strings.HasPrefix(line, "-"):
+			if i+1 < len(body) && strings.HasPrefix(body[i+1], `\`) {
+				i++
+			}
+		}
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, "")
+}
+
+func TestPatchExporter_RandomEdits(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	doc := NewRGA("a")
+	exporter := NewPatchExporter(doc)
+	alphabet := []rune("ab\n")
+	for range 300 {
+		for range rng.IntN(4) + 1 {
+			nodes := doc.Nodes()
+			parent := ID{NodeID: "root"}
+			if len(nodes) > 0 && rng.IntN(5) > 0 {
+				parent = nodes[rng.IntN(len(nodes))].ID
+			}
+			if len(nodes) > 0 && rng.IntN(3) == 0 {
+				doc.Delete(nodes[rng.IntN(len(nodes))].ID)
+			} else {
+				doc.Insert(alphabet[rng.IntN(len(alphabet))], parent)
+			}
+		}
+		patch := exporter.Next()
+		if patch.New != doc.Value() {
+			t.Fatalf("Expected the new text %q, got %q", doc.Value(), patch.New)
+		}
+		if got := applyEdits(patch.Old, patch.Edits); got != patch.New {
+			t.Fatalf("Edits of %q gave %q, want %q", patch.Old, got, patch.New)
+		}
+		if diff := patch.Unified("f"); diff != "" || patch.Old != patch.New {
+			if got := applyUnified(t, patch.Old, diff); got != patch.New {
+				t.Fatalf("Diff of %q gave %q, want %q:\n%s", patch.Old, got, patch.New, diff)
+			}
+		}
+	}
+}
@@ -1,5 +1,86 @@
 package gocrdt
 
+import (
+	"maps"
+	"sync"
+)
+
+// UnsyncPNCounter is the unsynchronized core of a PNCounter.
+//
+// Like UnsyncGCounter it performs no locking and must be owned by a single
+// goroutine; use PNCounter when the counter is shared between goroutines.
+type UnsyncPNCounter struct {
+	pCounter UnsyncGCounter       // Increments
+	nCounter UnsyncGCounter       // Decrements
+	onOp     func(Op)             // See OnOp
+	requests requestLog[struct{}] // See IncrementOnce
+
+	onAdvance func(string, int) // See OnAdvance
+}
+
+// NewUnsyncPNCounter initializes an UnsyncPNCounter for a specific node.
+// Of the options, WithCodec, WithRequestWindow and WithOverflow apply.
+func NewUnsyncPNCounter(nodeID string, opts ...Option) *UnsyncPNCounter {
+	return &UnsyncPNCounter{
+		pCounter: *NewUnsyncGCounter(nodeID, opts...),
+		nCounter: *NewUnsyncGCounter(nodeID, opts...),
+		requests: newRequestLog[struct{}](newOptions(opts)),
+	}
+}
+
+// Increment adds 1 to the counter.
+func (c *UnsyncPNCounter) Increment() {
+	c.pCounter.Increment()
+	c.emit(OpIncrement, &c.pCounter)
+}
+
+// Decrement subtracts 1 from the counter.
+func (c *UnsyncPNCounter) Decrement() {
+	c.nCounter.Increment()
+	c.emit(OpDecrement, &c.nCounter)
+}
+
+// Value returns the sum of increments minus the sum of decrements.
+func (c *UnsyncPNCounter) Value() int {
+	return c.pCounter.Value() - c.nCounter.Value()
+}
+
+// Slots returns copies of the positive and negative slot vectors.
+func (c *UnsyncPNCounter) Slots() (p, n map[string]int) {
+	return c.pCounter.Slots(), c.nCounter.Slots()
+}
+
+// Merge combines the state of another UnsyncPNCounter into this one.
+// See PNCounter.Merge for the semantics and the returned MergeResult.
+func (c *UnsyncPNCounter) Merge(other *UnsyncPNCounter) MergeResult {
+	return c.MergeSlots(other.pCounter.slots, other.nCounter.slots)
+}
+
+// MergeSlots joins remote positive and negative slot vectors, as returned
+// by Slots, into this counter.
+func (c *UnsyncPNCounter) MergeSlots(p, n map[string]int) MergeResult {
+	if c.onAdvance == nil {
+		result := c.pCounter.MergeSlots(p)
+		result.Add(c.nCounter.MergeSlots(n))
+		return result
+	}
+
+	seen := make(map[string]int, len(p)+len(n))
+	for _, slots := range []map[string]int{p, n} {
+		for id := range slots {
+			seen[id] = c.Seen(id)
+		}
+	}
+	result := c.pCounter.MergeSlots(p)
+	result.Add(c.nCounter.MergeSlots(n))
+	for id, before := range seen {
+		if now := c.Seen(id); now > before {
+			c.onAdvance(id, now)
+		}
+	}
+	return result
+}
+
 // PNCounter is a Positive-Negative Counter CRDT.
 //
 // Unlike a GCounter, which is increment-only, a PNCounter allows for both
@@ -11,25 +92,33 @@ package gocrdt
 // This structure ensures that even when nodes decrement values, the underlying
 // state remains monotonic (always growing), which is a requirement for
 // successful merging in distributed systems.
+//
+// PNCounter is safe for concurrent use; it wraps an UnsyncPNCounter with a
+// read/write mutex, or the lock chosen with WithLocking.
 type PNCounter struct {
-	pCounter *GCounter // Increments
-	nCounter *GCounter // Decrements
+	mu      rwLocker // See WithLocking
+	counter UnsyncPNCounter
 }
 
 // NewPNCounter initializes a PNCounter for a specific node.
 // It creates two underlying GCounters, both sharing the same nodeID to
 // track that node's specific contribution to the global sum and delta.
-func NewPNCounter(nodeID string) *PNCounter {
+//
+// Of the options, WithLocking, WithCodec, WithRequestWindow and
+// WithOverflow apply.
+func NewPNCounter(nodeID string, opts ...Option) *PNCounter {
 	return &PNCounter{
-		pCounter: NewGCounter(nodeID),
-		nCounter: NewGCounter(nodeID),
+		mu:      newLocker(newOptions(opts).locking),
+		counter: *NewUnsyncPNCounter(nodeID, opts...),
 	}
 }
 
 // Increment adds 1 to the counter.
 // Internally, this increases the value in the positive GCounter.
 func (c *PNCounter) Increment() {
-	c.pCounter.Increment()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counter.Increment()
 }
 
 // Decrement subtracts 1 from the counter.
@@ -37,7 +126,9 @@ func (c *PNCounter) Increment() {
 // Note: We "increment" the negative state to represent a "decrement"
 // of the total value.
 func (c *PNCounter) Decrement() {
-	c.nCounter.Increment()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counter.Decrement()
 }
 
 // Value calculates the current total by subtracting the negative GCounter sum
@@ -46,7 +137,16 @@ func (c *PNCounter) Decrement() {
 // This represents the "drift" between all additions and all subtractions
 // known by the node. This method satisfies the CRDT interface.
 func (c *PNCounter) Value() int {
-	return c.pCounter.Value() - c.nCounter.Value()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Value()
+}
+
+// Slots returns copies of the positive and negative slot vectors.
+func (c *PNCounter) Slots() (p, n map[string]int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Slots()
 }
 
 // Merge combines the state of another PNCounter into this one.
@@ -55,7 +155,38 @@ func (c *PNCounter) Value() int {
 // and negative GCounters. Since both underlying counters satisfy the
 // properties of a Join-Semilattice, the PNCounter merge is also commutative,
 // associative, and idempotent.
-func (c *PNCounter) Merge(other *PNCounter) {
-	c.pCounter.Merge(other.pCounter)
-	c.nCounter.Merge(other.nCounter)
+//
+// The returned MergeResult is the sum of both underlying GCounter merges.
+//
+// The remote slots are copied into recycled maps before the local lock is
+// taken, so two counters merging into each other concurrently cannot
+// deadlock, and merges that add no replica do not allocate.
+func (c *PNCounter) Merge(other *PNCounter) MergeResult {
+	p, n := slotMaps.Get().(*map[string]int), slotMaps.Get().(*map[string]int)
+	other.mu.RLock()
+	maps.Copy(*p, other.counter.pCounter.slots)
+	maps.Copy(*n, other.counter.nCounter.slots)
+	other.mu.RUnlock()
+
+	result := c.MergeSlots(*p, *n)
+	clear(*p)
+	clear(*n)
+	slotMaps.Put(p)
+	slotMaps.Put(n)
+	return result
+}
+
+// slotMaps recycles the slot vectors PNCounter merges copy. Cleared maps
+// keep their buckets, so refilling them does not allocate.
+var slotMaps = sync.Pool{New: func() any {
+	m := make(map[string]int)
+	return &m
+}}
+
+// MergeSlots joins remote positive and negative slot vectors, as returned
+// by Slots on a PNCounter or an UnsyncPNCounter, into this counter.
+func (c *PNCounter) MergeSlots(p, n map[string]int) MergeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.MergeSlots(p, n)
 }
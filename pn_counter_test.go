@@ -28,3 +28,35 @@ func TestPNCounter_Merge(t *testing.T) {
 		t.Errorf("Expected convergence at 0, got A=%d, B=%d", nodeA.Value(), nodeB.Value())
 	}
 }
+
+func TestPNCounter_MergeResult(t *testing.T) {
+	nodeA := NewPNCounter("node-a")
+	nodeB := NewPNCounter("node-b")
+
+	nodeB.Increment()
+	nodeB.Decrement()
+
+	result := nodeA.Merge(nodeB)
+	if result.Applied != 2 {
+		t.Errorf("Expected both P and N slots to be applied, got %+v", result)
+	}
+}
+
+func TestPNCounter_Unsync(t *testing.T) {
+	local := NewUnsyncPNCounter("node-a")
+	remote := NewPNCounter("node-b")
+
+	local.Increment()
+	local.Decrement()
+	local.Decrement()
+	remote.Increment()
+
+	// Synchronized -> unsynchronized
+	local.MergeSlots(remote.Slots())
+	// Unsynchronized -> synchronized
+	remote.MergeSlots(local.Slots())
+
+	if local.Value() != 0 || remote.Value() != 0 {
+		t.Errorf("Expected convergence at 0, got local=%d, remote=%d", local.Value(), remote.Value())
+	}
+}
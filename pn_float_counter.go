@@ -0,0 +1,233 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// ErrInvalidAmount is returned by PNFloatCounter.Add for a NaN or infinite
+// amount, which would poison the sum on every replica.
+var ErrInvalidAmount = errors.New("gocrdt: invalid amount")
+
+// pnFloatState is the wire representation of a PNFloatCounter.
+type pnFloatState struct {
+	V int                `json:"v"`
+	P map[string]float64 `json:"p"`
+	N map[string]float64 `json:"n"`
+}
+
+// UnsyncPNFloatCounter is the unsynchronized core of a PNFloatCounter.
+//
+// Like UnsyncPNCounter it performs no locking and must be owned by a single
+// goroutine; use PNFloatCounter when the counter is shared between
+// goroutines.
+type UnsyncPNFloatCounter struct {
+	nodeID string
+	p, n   map[string]float64 // Additions and subtractions, by replica
+	codec  Codec              // See WithCodec
+}
+
+// NewUnsyncPNFloatCounter initializes an UnsyncPNFloatCounter for a
+// specific node. Of the options, only WithCodec applies.
+func NewUnsyncPNFloatCounter(nodeID string, opts ...Option) *UnsyncPNFloatCounter {
+	return &UnsyncPNFloatCounter{
+		nodeID: nodeID,
+		p:      make(map[string]float64),
+		n:      make(map[string]float64),
+		codec:  newOptions(opts).codec,
+	}
+}
+
+// Add adds x, which may be negative, to the counter. See
+// PNFloatCounter.Add.
+func (c *UnsyncPNFloatCounter) Add(x float64) error {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidAmount, x)
+	}
+	if x >= 0 {
+		c.p[c.nodeID] += x
+	} else {
+		c.n[c.nodeID] -= x
+	}
+	return nil
+}
+
+// Value returns the sum of additions minus the sum of subtractions. See
+// PNFloatCounter.Value.
+func (c *UnsyncPNFloatCounter) Value() float64 {
+	return sumSlots(c.p) - sumSlots(c.n)
+}
+
+// sumSlots adds the slots up in the order of their replica IDs, so every
+// replica holding the same slots computes the same float.
+func sumSlots(slots map[string]float64) float64 {
+	ids := make([]string, 0, len(slots))
+	for id := range slots {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	sum := 0.0
+	for _, id := range ids {
+		sum += slots[id]
+	}
+	return sum
+}
+
+// Slots returns copies of the addition and subtraction slot vectors.
+func (c *UnsyncPNFloatCounter) Slots() (p, n map[string]float64) {
+	return copySlots(c.p), copySlots(c.n)
+}
+
+func copySlots(slots map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(slots))
+	for id, value := range slots {
+		out[id] = value
+	}
+	return out
+}
+
+// Merge combines the state of another UnsyncPNFloatCounter into this one.
+func (c *UnsyncPNFloatCounter) Merge(other *UnsyncPNFloatCounter) MergeResult {
+	return c.MergeSlots(other.p, other.n)
+}
+
+// MergeSlots joins remote slot vectors, as returned by Slots, into this
+// counter by taking the per-replica maximum. Negative, NaN or infinite
+// remote slots cannot come from Add and are counted as Rejected.
+func (c *UnsyncPNFloatCounter) MergeSlots(p, n map[string]float64) MergeResult {
+	result := mergeFloatSlots(c.p, p)
+	result.Add(mergeFloatSlots(c.n, n))
+	return result
+}
+
+func mergeFloatSlots(local, remote map[string]float64) MergeResult {
+	var result MergeResult
+	for id, value := range remote {
+		switch {
+		case value < 0 || math.IsNaN(value) || math.IsInf(value, 0):
+			result.Rejected++
+		case value > local[id]:
+			local[id] = value
+			result.Applied++
+		default:
+			result.Duplicates++
+		}
+	}
+	return result
+}
+
+// MarshalState encodes both slot vectors of the counter.
+func (c *UnsyncPNFloatCounter) MarshalState() ([]byte, error) {
+	return c.codec.Marshal(pnFloatState{V: WireVersion, P: c.p, N: c.n})
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter.
+func (c *UnsyncPNFloatCounter) MergeState(data []byte) (MergeResult, error) {
+	state, err := decodePNFloat(c.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(state.P, state.N), nil
+}
+
+func decodePNFloat(c Codec, data []byte) (pnFloatState, error) {
+	var state pnFloatState
+	if err := c.Unmarshal(data, &state); err != nil {
+		return state, versionError(c, data, err)
+	}
+	return state, checkVersion(state.V)
+}
+
+// PNFloatCounter is a PNCounter of float64 contributions, for replicated
+// gauges and balances that are not whole numbers, such as amounts of money
+// or measured quantities, without scaling them into an int counter.
+//
+// Every replica keeps the running totals of its additions and of its
+// subtractions, which only grow; merges take the per-replica maximum of
+// both, so they are commutative, associative and idempotent like those of
+// PNCounter.
+//
+// Float addition is not associative, so Value sums the slots in a fixed
+// order: replicas holding the same state report the same float, bit for
+// bit. The usual float64 rounding still applies, to the running totals and
+// to their sum; amounts that must be exact, like cents, are better counted
+// in a PNCounter.
+//
+// PNFloatCounter is safe for concurrent use; it wraps an
+// UnsyncPNFloatCounter with a read/write mutex, or the lock chosen with
+// WithLocking.
+type PNFloatCounter struct {
+	mu      rwLocker // See WithLocking
+	counter UnsyncPNFloatCounter
+}
+
+// NewPNFloatCounter initializes a PNFloatCounter for a specific node, which
+// must be unique like that of a PNCounter. Of the options, WithLocking and
+// WithCodec apply.
+func NewPNFloatCounter(nodeID string, opts ...Option) *PNFloatCounter {
+	return &PNFloatCounter{
+		mu:      newLocker(newOptions(opts).locking),
+		counter: *NewUnsyncPNFloatCounter(nodeID, opts...),
+	}
+}
+
+// Add adds x to the counter: a positive x to the running total of this
+// replica's additions, a negative one to that of its subtractions. NaN and
+// infinite amounts return an error wrapping ErrInvalidAmount and change
+// nothing.
+func (c *PNFloatCounter) Add(x float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.Add(x)
+}
+
+// Value returns the sum of additions minus the sum of subtractions known to
+// this replica.
+func (c *PNFloatCounter) Value() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Value()
+}
+
+// Slots returns copies of the addition and subtraction slot vectors.
+func (c *PNFloatCounter) Slots() (p, n map[string]float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Slots()
+}
+
+// Merge combines the state of another PNFloatCounter into this one. The
+// remote slots are copied before the local lock is taken, so two counters
+// merging into each other concurrently cannot deadlock.
+func (c *PNFloatCounter) Merge(other *PNFloatCounter) MergeResult {
+	return c.MergeSlots(other.Slots())
+}
+
+// MergeSlots joins remote slot vectors, as returned by Slots, into this
+// counter. See UnsyncPNFloatCounter.MergeSlots.
+func (c *PNFloatCounter) MergeSlots(p, n map[string]float64) MergeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter.MergeSlots(p, n)
+}
+
+// MarshalState encodes both slot vectors of the counter.
+func (c *PNFloatCounter) MarshalState() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.MarshalState()
+}
+
+// MergeState decodes a state produced by MarshalState on another replica
+// and merges it into this counter. Decoding happens before the lock is
+// taken.
+func (c *PNFloatCounter) MergeState(data []byte) (MergeResult, error) {
+	state, err := decodePNFloat(c.counter.codec, data)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return c.MergeSlots(state.P, state.N), nil
+}
@@ -0,0 +1,37 @@
+package gocrdt
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPNFloatCounter(t *testing.T) {
+	a, b := NewPNFloatCounter("a"), NewPNFloatCounter("b")
+	a.Add(10.25)
+	a.Add(-0.5)
+	b.Add(0.1)
+	b.Add(0.2)
+
+	a.Merge(b)
+	state, _ := a.MarshalState()
+	if _, err := b.MergeState(state); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if a.Value() != b.Value() {
+		t.Errorf("Expected identical floats on both replicas, got %v and %v", a.Value(), b.Value())
+	}
+	if math.Abs(a.Value()-10.05) > 1e-9 {
+		t.Errorf("Expected 10.05, got %v", a.Value())
+	}
+	if result := b.Merge(a); result.Applied != 0 {
+		t.Errorf("Expected a repeated merge to apply nothing, got %+v", result)
+	}
+
+	if err := a.Add(math.NaN()); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount for NaN, got %v", err)
+	}
+	if result := a.MergeSlots(map[string]float64{"c": math.Inf(1)}, map[string]float64{"c": -1}); result.Rejected != 2 {
+		t.Errorf("Expected malformed slots to be rejected, got %+v", result)
+	}
+}
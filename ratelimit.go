@@ -0,0 +1,165 @@
+package gocrdt
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiterState is the wire representation of a RateLimiter.
+type rateLimiterState struct {
+	V     int            `json:"v"`
+	Epoch int64          `json:"epoch"`
+	Slots map[string]int `json:"slots"`
+}
+
+// RateLimiter admits at most limit requests per window across every
+// replica, without a central store: each replica admits requests locally
+// against the total it knows of and merges the usage of the others, like
+// a GCounter of the requests admitted in the current window.
+//
+//	limiter := NewRateLimiter("a", 1000, time.Minute)
+//	if !limiter.Allow() {
+//		return errTooManyRequests
+//	}
+//
+// Windows are aligned on the wall clock (Unix time divided by window), so
+// replicas with roughly synchronized clocks share them; usage of an
+// earlier window is dropped, and a merged state of a later window resets
+// the local usage. Limiting is approximate: requests admitted concurrently
+// on different replicas before their usage is merged can exceed the limit,
+// by up to limit per replica in the worst case, so merge at an interval
+// small compared with the window.
+//
+// RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	nodeID string
+	limit  int
+	window time.Duration
+	now    func() time.Time
+	codec  Codec // See WithCodec
+
+	mu    sync.Mutex
+	epoch int64          // Current window, in windows since the Unix epoch
+	slots map[string]int // Requests admitted in the window, by replica
+}
+
+// NewRateLimiter creates a limiter admitting limit requests per window for
+// the replica nodeID, which must be unique like that of a counter. Of the
+// options, only WithCodec applies.
+func NewRateLimiter(nodeID string, limit int, window time.Duration, opts ...Option) *RateLimiter {
+	return &RateLimiter{
+		nodeID: nodeID,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		codec:  newOptions(opts).codec,
+		slots:  make(map[string]int),
+	}
+}
+
+// roll moves to the current window, dropping the usage of an earlier one.
+// It must be called with mu held.
+func (l *RateLimiter) roll() {
+	if epoch := l.now().UnixNano() / int64(l.window); epoch > l.epoch {
+		l.epoch = epoch
+		clear(l.slots)
+	}
+}
+
+// used returns the requests admitted in the window. It must be called with
+// mu held.
+func (l *RateLimiter) used() int {
+	total := 0
+	for _, n := range l.slots {
+		total += n
+	}
+	return total
+}
+
+// Allow reports whether a request may proceed, and counts it if so.
+func (l *RateLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n requests may proceed, and counts them if so.
+// They are admitted all or none.
+func (l *RateLimiter) AllowN(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	if n < 0 || l.used()+n > l.limit {
+		return false
+	}
+	l.slots[l.nodeID] += n
+	return true
+}
+
+// Remaining returns the requests that may still be admitted in the current
+// window, as far as this replica knows.
+func (l *RateLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	return max(l.limit-l.used(), 0)
+}
+
+// Reset returns the end of the current window, when the limit is
+// replenished.
+func (l *RateLimiter) Reset() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	return time.Unix(0, (l.epoch+1)*int64(l.window))
+}
+
+// Value returns the requests admitted in the current window by every
+// replica, as far as this replica knows.
+func (l *RateLimiter) Value() any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	return l.used()
+}
+
+// MarshalState encodes the usage of the current window.
+func (l *RateLimiter) MarshalState() ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	return l.codec.Marshal(rateLimiterState{V: WireVersion, Epoch: l.epoch, Slots: l.slots})
+}
+
+// MergeState merges the usage of another replica. A state of an earlier
+// window is ignored, its slots counted as Duplicates; one of a later window
+// replaces the local usage.
+func (l *RateLimiter) MergeState(data []byte) (MergeResult, error) {
+	var state rateLimiterState
+	if err := l.codec.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(l.codec, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll()
+	var result MergeResult
+	switch {
+	case state.Epoch < l.epoch:
+		result.Duplicates = len(state.Slots)
+		return result, nil
+	case state.Epoch > l.epoch:
+		l.epoch = state.Epoch
+		clear(l.slots)
+	}
+	for replica, n := range state.Slots {
+		if n > l.slots[replica] {
+			l.slots[replica] = n
+			result.Applied++
+		} else {
+			result.Duplicates++
+		}
+	}
+	return result, nil
+}
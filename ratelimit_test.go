@@ -0,0 +1,47 @@
+package gocrdt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_GlobalLimit(t *testing.T) {
+	now := time.Unix(600, 0)
+	clock := func() time.Time { return now }
+	a, b := NewRateLimiter("a", 5, time.Minute), NewRateLimiter("b", 5, time.Minute)
+	a.now, b.now = clock, clock
+
+	if !a.AllowN(3) || a.AllowN(3) {
+		t.Fatal("Expected 3 requests admitted and 3 more refused")
+	}
+	state, _ := a.MarshalState()
+	if _, err := b.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	if !b.AllowN(2) || b.Allow() || b.Remaining() != 0 {
+		t.Errorf("Expected b to admit the 2 requests left, got %d remaining", b.Remaining())
+	}
+
+	// The usage of b reaches a, and merging twice changes nothing.
+	state, _ = b.MarshalState()
+	for range 2 {
+		if _, err := a.MergeState(state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.Allow() || a.Value() != 5 {
+		t.Errorf("Expected the global limit to be reached, got %v", a.Value())
+	}
+
+	// The next window replenishes the limit, and stale usage is ignored.
+	now = now.Add(time.Minute)
+	if !a.Allow() || a.Remaining() != 4 {
+		t.Errorf("Expected a new window, got %d remaining", a.Remaining())
+	}
+	if result, _ := a.MergeState(state); result.Applied != 0 || a.Remaining() != 4 {
+		t.Errorf("Expected the earlier window to be ignored, got %+v", result)
+	}
+	if want := time.Unix(720, 0); !a.Reset().Equal(want) {
+		t.Errorf("Expected the window to end at %v, got %v", want, a.Reset())
+	}
+}
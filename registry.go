@@ -0,0 +1,142 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownType is returned by a Registry for tags never registered.
+	ErrUnknownType = errors.New("gocrdt: unknown type")
+
+	// ErrDuplicateType is returned by Registry.Register for a tag, or a Go
+	// type, registered twice.
+	ErrDuplicateType = errors.New("gocrdt: type already registered")
+)
+
+// Factory creates an empty CRDT for the replica replicaID.
+type Factory func(replicaID string) Replicable
+
+// TaggedState is a state payload labelled with the tag of its type, as
+// written by Registry.Marshal, so receivers can decode payloads of mixed
+// types.
+type TaggedState struct {
+	Type  string          `json:"type"`
+	State json.RawMessage `json:"state"`
+}
+
+// Registry maps type tags such as "rga" to the factories of their CRDTs,
+// and the Go types back to the tags, so payloads of different types can be
+// created, tagged and decoded without switching on types in user code.
+// Custom CRDTs are registered like the built-in ones. A Registry is safe
+// for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]Factory
+	tags  map[reflect.Type]string
+}
+
+// NewRegistry creates an empty Registry. See DefaultRegistry for one with
+// the CRDTs of this package.
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]Factory), tags: make(map[reflect.Type]string)}
+}
+
+// DefaultRegistry returns a new Registry with the CRDTs of this package:
+// "gcounter", "pncounter" and "rga".
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	_ = r.Register("gcounter", func(id string) Replicable { return NewGCounter(id) })
+	_ = r.Register("pncounter", func(id string) Replicable { return NewPNCounter(id) })
+	_ = r.Register("rga", func(id string) Replicable { return NewRGA(id) })
+	return r
+}
+
+// Register makes the type tag available, created by factory. The Go type
+// factory returns is bound to tag, for Marshal and TypeOf.
+func (r *Registry) Register(tag string, factory Factory) error {
+	typ := reflect.TypeOf(factory(""))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[tag]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateType, tag)
+	}
+	if other, ok := r.tags[typ]; ok {
+		return fmt.Errorf("%w: %v is registered as %q", ErrDuplicateType, typ, other)
+	}
+	r.types[tag] = factory
+	r.tags[typ] = tag
+	return nil
+}
+
+// Tags returns the registered tags, sorted.
+func (r *Registry) Tags() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tags := make([]string, 0, len(r.types))
+	for tag := range r.types {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// New creates an empty CRDT of type tag for the replica replicaID.
+func (r *Registry) New(tag, replicaID string) (Replicable, error) {
+	r.mu.RLock()
+	factory, ok := r.types[tag]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, tag)
+	}
+	return factory(replicaID), nil
+}
+
+// TypeOf returns the tag the type of state is registered with.
+func (r *Registry) TypeOf(state Replicable) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tag, ok := r.tags[reflect.TypeOf(state)]
+	return tag, ok
+}
+
+// Decode creates a CRDT of type tag for replicaID and merges data, a
+// payload of MarshalState, into it.
+func (r *Registry) Decode(tag, replicaID string, data []byte) (Replicable, error) {
+	state, err := r.New(tag, replicaID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := state.MergeState(data); err != nil {
+		return nil, fmt.Errorf("gocrdt: decode %q: %w", tag, err)
+	}
+	return state, nil
+}
+
+// Marshal encodes the state of a registered type as a TaggedState.
+func (r *Registry) Marshal(state Replicable) ([]byte, error) {
+	tag, ok := r.TypeOf(state)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", ErrUnknownType, state)
+	}
+	data, err := state.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(TaggedState{Type: tag, State: data})
+}
+
+// Unmarshal decodes a TaggedState written by Marshal into a new CRDT for
+// replicaID, and returns it with its tag.
+func (r *Registry) Unmarshal(replicaID string, data []byte) (string, Replicable, error) {
+	var tagged TaggedState
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return "", nil, err
+	}
+	state, err := r.Decode(tagged.Type, replicaID, tagged.State)
+	return tagged.Type, state, err
+}
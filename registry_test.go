@@ -0,0 +1,49 @@
+package gocrdt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := DefaultRegistry()
+	if tags := reg.Tags(); !slices.Equal(tags, []string{"gcounter", "pncounter", "rga"}) {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+	if err := reg.Register("text", func(id string) Replicable { return NewRGA(id) }); !errors.Is(err, ErrDuplicateType) {
+		t.Errorf("Expected a second tag for RGA to be refused, got %v", err)
+	}
+	if _, err := reg.New("orset", "a"); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected an unknown type, got %v", err)
+	}
+
+	// Payloads of mixed types decode without knowing their types.
+	doc := NewRGA("alice")
+	doc.Insert('x', ID{0, "root"})
+	likes := NewPNCounter("alice")
+	likes.Decrement()
+	for _, state := range []Replicable{doc, likes} {
+		data, err := reg.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag, decoded, err := reg.Unmarshal("bob", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := reg.TypeOf(state)
+		a, _ := state.MarshalState()
+		b, _ := decoded.MarshalState()
+		if tag != want || string(a) != string(b) {
+			t.Errorf("Decoded %s %s, want %s %s", tag, b, want, a)
+		}
+	}
+
+	if _, err := reg.Marshal(NewUnsyncRGA("a")); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected unregistered types to be refused, got %v", err)
+	}
+	if _, _, err := reg.Unmarshal("a", []byte(`{"type":"rga","state":{}}`)); err == nil {
+		t.Error("Expected a malformed state to be refused")
+	}
+}
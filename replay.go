@@ -0,0 +1,87 @@
+package gocrdt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrCheckpointMismatch is returned by Replay when the state rebuilt from
+// an op log does not hash to a recorded checkpoint: the log, or the
+// checkpoint, was altered after it was recorded.
+var ErrCheckpointMismatch = errors.New("gocrdt: state does not match the checkpoint")
+
+// Replayable is implemented by the CRDTs an op log can rebuild.
+type Replayable interface {
+	Replicable
+	Operable
+}
+
+// Checkpoint records the hash of a state after the first Ops ops of its
+// log, to audit the log later with Replay. Store checkpoints apart from the
+// log they cover, such as signed or in an append-only store.
+type Checkpoint struct {
+	Ops  int    `json:"ops"`
+	Hash Digest `json:"hash"`
+}
+
+// StateHash returns the Hash of s, or for a state that is not a Hasher the
+// SHA-256 of its state as encoded by MarshalState, which only matches
+// between replicas using the same Codec and encoding their entries in the
+// same order.
+func StateHash(s Replicable) (Digest, error) {
+	if h, ok := s.(Hasher); ok {
+		return h.Hash(), nil
+	}
+	data, err := s.MarshalState()
+	if err != nil {
+		return Digest{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// NewCheckpoint returns the checkpoint of s once ops ops of its log are
+// applied.
+func NewCheckpoint(s Replicable, ops int) (Checkpoint, error) {
+	hash, err := StateHash(s)
+	return Checkpoint{Ops: ops, Hash: hash}, err
+}
+
+// Replay rebuilds a state from nothing but its op log: it applies ops, in
+// order, to state, which must be new (such as NewRGA("audit")), and checks
+// the state against each checkpoint once its Ops ops are applied:
+//
+//	doc := NewRGA("audit")
+//	if err := Replay(doc, ops, checkpoints...); err != nil { ... }
+//
+// The same log always rebuilds the same state, so a mismatch, returned as
+// an error wrapping ErrCheckpointMismatch, means the log was tampered with
+// before the first checkpoint that fails. Replay also fails on ops the
+// state does not support, and on a checkpoint past the end of the log.
+func Replay(state Replayable, ops []Op, checkpoints ...Checkpoint) error {
+	applied := 0
+	advance := func(to int) error {
+		for ; applied < to; applied++ {
+			if _, err := state.ApplyOp(ops[applied]); err != nil {
+				return fmt.Errorf("gocrdt: replay op %d: %w", applied, err)
+			}
+		}
+		return nil
+	}
+	for _, cp := range checkpoints {
+		if cp.Ops < applied || cp.Ops > len(ops) {
+			return fmt.Errorf("gocrdt: checkpoint after %d ops out of order or past the %d ops of the log", cp.Ops, len(ops))
+		}
+		if err := advance(cp.Ops); err != nil {
+			return err
+		}
+		hash, err := StateHash(state)
+		if err != nil {
+			return err
+		}
+		if hash != cp.Hash {
+			return fmt.Errorf("%w after %d ops", ErrCheckpointMismatch, cp.Ops)
+		}
+	}
+	return advance(len(ops))
+}
@@ -0,0 +1,61 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	var ops []Op
+	doc := NewRGA("a")
+	doc.OnOp(func(op Op) { ops = append(ops, op) })
+	h := doc.Insert('h', ID{0, "root"})
+	i := doc.Insert('i', h)
+	first, err := NewCheckpoint(doc, len(ops))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Delete(i)
+	last, _ := NewCheckpoint(doc, len(ops))
+
+	audit := NewRGA("audit")
+	if err := Replay(audit, ops, first, last); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Value() != "h" {
+		t.Errorf("Expected h, got %q", audit.Value())
+	}
+
+	// An altered op fails the first checkpoint after it.
+	tampered := append([]Op(nil), ops...)
+	tampered[2].Kind = OpInsert
+	err = Replay(NewRGA("audit"), tampered, first, last)
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("Expected ErrCheckpointMismatch, got %v", err)
+	}
+	if err.Error() != ErrCheckpointMismatch.Error()+" after 3 ops" {
+		t.Errorf("Expected the mismatch after 3 ops, got %v", err)
+	}
+
+	if err := Replay(NewRGA("audit"), ops[:2], last); err == nil {
+		t.Error("Expected an error for a checkpoint past the log")
+	}
+	if err := Replay(NewPNCounter("audit"), ops); !errors.Is(err, ErrUnsupportedOp) {
+		t.Errorf("Expected ErrUnsupportedOp, got %v", err)
+	}
+}
+
+func TestReplayCounter(t *testing.T) {
+	var ops []Op
+	c := NewPNCounter("a")
+	c.OnOp(func(op Op) { ops = append(ops, op) })
+	c.Increment()
+	c.Increment()
+	c.Decrement()
+	cp, _ := NewCheckpoint(c, len(ops))
+
+	audit := NewPNCounter("audit")
+	if err := Replay(audit, ops, cp); err != nil || audit.Value() != 1 {
+		t.Errorf("Expected 1, got %d, %v", audit.Value(), err)
+	}
+}
@@ -0,0 +1,32 @@
+package replicator
+
+import gocrdt "github.com/cshekharsharma/go-crdt"
+
+// PeerAware is implemented by states that control what each peer may read
+// and write, such as a store.Store with an Authorizer. Replicas and
+// sessions then merge what a peer sends with MergeStateFrom and send it
+// MarshalStateFor instead of the full state.
+//
+// Gossip digests of PeerAware states are computed over the view of the
+// receiving peer, so peers with different views keep exchanging states;
+// prefer Sync or sessions for them.
+type PeerAware interface {
+	MergeStateFrom(peer string, data []byte) (gocrdt.MergeResult, error)
+	MarshalStateFor(peer string) ([]byte, error)
+}
+
+// mergeFrom merges a payload received from peer into state.
+func mergeFrom(state gocrdt.Replicable, peer string, payload []byte) (gocrdt.MergeResult, error) {
+	if aware, ok := state.(PeerAware); ok {
+		return aware.MergeStateFrom(peer, payload)
+	}
+	return state.MergeState(payload)
+}
+
+// marshalFor encodes the part of state peer may read.
+func marshalFor(state gocrdt.Replicable, peer string) ([]byte, error) {
+	if aware, ok := state.(PeerAware); ok {
+		return aware.MarshalStateFor(peer)
+	}
+	return state.MarshalState()
+}
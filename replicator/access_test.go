@@ -0,0 +1,62 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/store"
+)
+
+func TestReplica_PeerAware(t *testing.T) {
+	// alice's store lets bob read her document but not change it.
+	readOnly := func(peer, key, typ string, op store.Operation) error {
+		if op == store.OpWrite {
+			return errors.New("read-only collaborator")
+		}
+		return nil
+	}
+	newStore := func(id string, config store.Config) *store.Store {
+		s := store.New(config)
+		s.Register("rga", func(string) gocrdt.Replicable { return gocrdt.NewRGA(id) })
+		return s
+	}
+	alice, bob := newStore("alice", store.Config{Authorize: readOnly}), newStore("bob", store.Config{})
+	for _, s := range []*store.Store{alice, bob} {
+		_ = s.Do("doc", "rga", func(state gocrdt.Replicable) error {
+			state.(*gocrdt.RGA).Insert('x', gocrdt.ID{NodeID: "root"})
+			return nil
+		})
+	}
+
+	network := NewNetwork(NetworkConfig{})
+	ra := NewReplica("alice", alice, network.Transport("alice"), Config{})
+	rb := NewReplica("bob", bob, network.Transport("bob"), Config{})
+	ra.AddPeer("bob")
+	rb.AddPeer("alice")
+	ctx := context.Background()
+	for _, step := range []struct {
+		from, to *Replica
+	}{{ra, rb}, {rb, ra}} {
+		if err := step.from.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := network.Transport(step.to.ID()).Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := step.to.Handle(ctx, msg)
+		if step.to == ra && (!errors.Is(err, store.ErrForbidden) || result.Rejected != 1) {
+			t.Errorf("Expected bob's write to be rejected, got %+v, %v", result, err)
+		}
+	}
+
+	text := func(s *store.Store) any {
+		doc, _ := s.Open("doc", "rga")
+		return doc.(*gocrdt.RGA).Value()
+	}
+	if text(alice) != "x" || text(bob) != "xx" {
+		t.Errorf("Expected bob to read alice's edit and alice to ignore bob's, got %q and %q", text(alice), text(bob))
+	}
+}
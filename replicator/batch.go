@@ -0,0 +1,398 @@
+package replicator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// KindBatch carries several messages for the same peer, packed by a
+// BatchingTransport.
+const KindBatch Kind = "batch"
+
+// Defaults used when the corresponding BatchConfig field is zero.
+const (
+	DefaultMaxBatch      = 64
+	DefaultMaxBatchBytes = 1 << 20
+	DefaultFlushInterval = 10 * time.Millisecond
+	DefaultQueueCapacity = 256
+	DefaultIdleTimeout   = time.Minute
+)
+
+// Coalescer combines two messages queued for the same peer into one, or
+// reports false if they cannot be combined. older was queued first.
+type Coalescer func(older, newer Message) (Message, bool)
+
+// BatchConfig tunes a BatchingTransport. The zero value uses the defaults.
+type BatchConfig struct {
+	// MaxBatch and MaxBatchBytes bound the messages and payload bytes sent
+	// in one batch. A batch is flushed as soon as either is reached.
+	MaxBatch      int
+	MaxBatchBytes int
+
+	// FlushInterval is the longest a message waits for a batch to fill.
+	FlushInterval time.Duration
+
+	// QueueCapacity bounds the messages queued per peer. When a slow peer's
+	// queue is full, a new message is first coalesced with a queued one;
+	// if that fails, the oldest queued message is dropped. Replicas recover
+	// dropped state in later rounds.
+	QueueCapacity int
+
+	// IdleTimeout is how long a peer's queue stays empty before its worker
+	// stops and the queue is released; the next Send to the peer starts a
+	// new one. It keeps peer churn from accumulating workers.
+	IdleTimeout time.Duration
+
+	// Coalesce combines queued messages when a queue is full. Nil disables
+	// coalescing. See CoalesceLatest and CoalesceNodes.
+	Coalesce Coalescer
+
+	// OnError, when set, receives errors from sending batches, which are
+	// otherwise dropped.
+	OnError func(error)
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.MaxBatch <= 0 {
+		c.MaxBatch = DefaultMaxBatch
+	}
+	if c.MaxBatchBytes <= 0 {
+		c.MaxBatchBytes = DefaultMaxBatchBytes
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	if c.QueueCapacity <= 0 {
+		c.QueueCapacity = DefaultQueueCapacity
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	return c
+}
+
+// BatchStats counts what a BatchingTransport did with outgoing messages.
+type BatchStats struct {
+	Queued    int // messages accepted by Send
+	Batches   int // sends to the underlying transport
+	Coalesced int // messages combined into a queued one
+	Dropped   int // messages dropped from a full queue
+}
+
+// CoalesceLatest returns a Coalescer that replaces a queued message with a
+// newer one of the same kind, for the given kinds. It suits kinds whose
+// payload supersedes earlier ones, such as full states and digests.
+func CoalesceLatest(kinds ...Kind) Coalescer {
+	return func(older, newer Message) (Message, bool) {
+		if older.Kind != newer.Kind {
+			return Message{}, false
+		}
+		for _, kind := range kinds {
+			if newer.Kind == kind {
+				return newer, true
+			}
+		}
+		return Message{}, false
+	}
+}
+
+// CoalesceNodes is a Coalescer for RGA payloads (full states, buckets or
+// deltas): it concatenates the node lists of two KindState messages,
+// keeping each node once and any tombstone. The order of both lists is
+// preserved, so the combined payload stays causal. Payloads of any format
+// version the gocrdt package reads are accepted; the combined one is written
+// in the current version.
+func CoalesceNodes(older, newer Message) (Message, bool) {
+	if older.Kind != KindState || newer.Kind != KindState {
+		return Message{}, false
+	}
+	a, err := gocrdt.DecodeNodes(older.Payload)
+	if err != nil {
+		return Message{}, false
+	}
+	b, err := gocrdt.DecodeNodes(newer.Payload)
+	if err != nil {
+		return Message{}, false
+	}
+
+	index := make(map[gocrdt.ID]int, len(a)+len(b))
+	nodes := make([]gocrdt.Node, 0, len(a)+len(b))
+	for _, n := range append(a, b...) {
+		if i, ok := index[n.ID]; ok {
+			nodes[i].Deleted = nodes[i].Deleted || n.Deleted
+			continue
+		}
+		index[n.ID] = len(nodes)
+		nodes = append(nodes, n)
+	}
+	payload, err := gocrdt.EncodeNodes(nodes)
+	if err != nil {
+		return Message{}, false
+	}
+	newer.Payload = payload
+	return newer, true
+}
+
+// BatchingTransport wraps a Transport with per-peer send queues. Send only
+// enqueues; a worker per peer packs queued messages into KindBatch
+// messages, flushed by size or after FlushInterval. A slow peer only backs
+// up its own bounded queue and never blocks sends to others. Workers of
+// peers that go quiet for IdleTimeout exit and release their queues.
+//
+// Both ends must use a BatchingTransport: Receive unpacks the batches.
+type BatchingTransport struct {
+	inner  Transport
+	config BatchConfig
+
+	mu     sync.Mutex
+	queues map[string]*peerQueue
+	stats  BatchStats
+	inbox  []Message // unpacked messages not yet returned by Receive
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// peerQueue holds the messages waiting for one peer; guarded by the
+// transport's mu.
+type peerQueue struct {
+	msgs    []Message
+	firstAt time.Time // when the oldest queued message arrived
+	lastAt  time.Time // when the newest message arrived
+	wake    chan struct{}
+}
+
+// NewBatchingTransport wraps inner. Close stops the per-peer workers.
+func NewBatchingTransport(inner Transport, config BatchConfig) *BatchingTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BatchingTransport{
+		inner:  inner,
+		config: config.withDefaults(),
+		queues: make(map[string]*peerQueue),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Send queues msg for msg.To. It never blocks; see BatchConfig for what
+// happens when the peer's queue is full.
+func (t *BatchingTransport) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx.Err() != nil {
+		return ErrClosed
+	}
+	q, ok := t.queues[msg.To]
+	if !ok {
+		q = &peerQueue{wake: make(chan struct{}, 1)}
+		t.queues[msg.To] = q
+		t.wg.Add(1)
+		go t.run(msg.To, q)
+	}
+
+	t.stats.Queued++
+	q.lastAt = time.Now()
+	if len(q.msgs) >= t.config.QueueCapacity && !t.coalesce(q, msg) {
+		q.msgs = q.msgs[1:]
+		t.stats.Dropped++
+	}
+	if len(q.msgs) < t.config.QueueCapacity {
+		if len(q.msgs) == 0 {
+			q.firstAt = time.Now()
+		}
+		q.msgs = append(q.msgs, msg)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// coalesce tries to fold msg into a queued message, newest first. It must
+// be called with t.mu held.
+func (t *BatchingTransport) coalesce(q *peerQueue, msg Message) bool {
+	if t.config.Coalesce == nil {
+		return false
+	}
+	for i := len(q.msgs) - 1; i >= 0; i-- {
+		if combined, ok := t.config.Coalesce(q.msgs[i], msg); ok {
+			// Move the combined message to the tail so it keeps the
+			// position of the newest of the two.
+			q.msgs = append(q.msgs[:i], q.msgs[i+1:]...)
+			q.msgs = append(q.msgs, combined)
+			t.stats.Coalesced++
+			return true
+		}
+	}
+	return false
+}
+
+// run is the flush loop of one peer.
+func (t *BatchingTransport) run(peer string, q *peerQueue) {
+	defer t.wg.Done()
+	timer := time.NewTimer(t.config.FlushInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-q.wake:
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return
+		}
+
+		batch, wait := t.takeBatch(q)
+		if batch == nil {
+			if wait == 0 {
+				idle, done := t.retire(peer, q)
+				if done {
+					return
+				}
+				wait = idle
+			}
+			if wait > 0 {
+				timer.Reset(wait)
+			}
+			continue
+		}
+		if err := t.sendBatch(peer, batch); err != nil && t.config.OnError != nil {
+			t.config.OnError(err)
+		}
+		// Check back once the queue may have drained, to notice idleness.
+		timer.Reset(t.config.FlushInterval)
+	}
+}
+
+// retire removes the queue of peer and reports true if it has been empty
+// for IdleTimeout. Otherwise it returns how long until it would be, or 0
+// if messages arrived in the meantime.
+func (t *BatchingTransport) retire(peer string, q *peerQueue) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(q.msgs) > 0 {
+		return 0, false // Send has woken the worker
+	}
+	if idle := t.config.IdleTimeout - time.Since(q.lastAt); idle > 0 {
+		return idle, false
+	}
+	delete(t.queues, peer)
+	return 0, true
+}
+
+// takeBatch removes the next batch from q if it is full or due. Otherwise
+// it returns how long to wait before the queue is due (0 when empty).
+func (t *BatchingTransport) takeBatch(q *peerQueue) ([]Message, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(q.msgs) == 0 {
+		return nil, 0
+	}
+
+	n, size := 0, 0
+	for n < len(q.msgs) && n < t.config.MaxBatch {
+		if n > 0 && size+len(q.msgs[n].Payload) > t.config.MaxBatchBytes {
+			break
+		}
+		size += len(q.msgs[n].Payload)
+		n++
+	}
+	full := n < len(q.msgs) || n == t.config.MaxBatch
+	if wait := t.config.FlushInterval - time.Since(q.firstAt); !full && wait > 0 {
+		return nil, wait
+	}
+
+	batch := make([]Message, n)
+	copy(batch, q.msgs)
+	q.msgs = q.msgs[n:]
+	q.firstAt = time.Now()
+	if len(q.msgs) > 0 {
+		// More is queued: come back without waiting for the next Send.
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	t.stats.Batches++
+	return batch, 0
+}
+
+// sendBatch sends one message as is, or several packed in a KindBatch.
+func (t *BatchingTransport) sendBatch(peer string, batch []Message) error {
+	if len(batch) == 1 {
+		return t.inner.Send(t.ctx, batch[0])
+	}
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return t.inner.Send(t.ctx, Message{From: batch[0].From, To: peer, Kind: KindBatch, Payload: payload})
+}
+
+// Receive returns the next message, unpacking batches.
+func (t *BatchingTransport) Receive(ctx context.Context) (Message, error) {
+	for {
+		t.mu.Lock()
+		if len(t.inbox) > 0 {
+			msg := t.inbox[0]
+			t.inbox = t.inbox[1:]
+			t.mu.Unlock()
+			return msg, nil
+		}
+		t.mu.Unlock()
+
+		msg, err := t.inner.Receive(ctx)
+		if err != nil || msg.Kind != KindBatch {
+			return msg, err
+		}
+		var batch []Message
+		if err := json.Unmarshal(msg.Payload, &batch); err != nil {
+			return Message{}, errors.Join(errors.New("replicator: undecodable batch"), err)
+		}
+		t.mu.Lock()
+		for _, packed := range batch {
+			// Trust the sender identity of the envelope, as for single messages.
+			packed.From = msg.From
+			t.inbox = append(t.inbox, packed)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Pending returns the number of messages queued for peer.
+func (t *BatchingTransport) Pending(peer string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if q, ok := t.queues[peer]; ok {
+		return len(q.msgs)
+	}
+	return 0
+}
+
+// Stats returns a snapshot of the batching counters.
+func (t *BatchingTransport) Stats() BatchStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Close stops the workers, dropping queued messages, and waits for
+// in-flight batches. It does not close the wrapped transport.
+func (t *BatchingTransport) Close() error {
+	t.mu.Lock()
+	t.cancel()
+	t.mu.Unlock()
+	t.wg.Wait()
+	return nil
+}
@@ -0,0 +1,237 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// stalledTransport blocks every send to the peer "slow" until ctx ends.
+type stalledTransport struct {
+	Transport
+}
+
+func (t stalledTransport) Send(ctx context.Context, msg Message) error {
+	if msg.To == "slow" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return t.Transport.Send(ctx, msg)
+}
+
+func TestBatchingTransport_PacksMessages(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	network.Transport("b")
+	a := NewBatchingTransport(network.Transport("a"), BatchConfig{FlushInterval: 20 * time.Millisecond})
+	b := NewBatchingTransport(network.Transport("b"), BatchConfig{})
+	defer a.Close()
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		if err := a.Send(ctx, Message{To: "b", Payload: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		msg, err := b.Receive(ctx)
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if msg.From != "a" || msg.Payload[0] != byte(i) {
+			t.Errorf("Message %d out of order: %+v", i, msg)
+		}
+	}
+	if sent := network.Stats().Sent; sent >= 10 {
+		t.Errorf("Expected batching to use fewer than 10 sends, got %d", sent)
+	}
+}
+
+func TestBatchingTransport_FlushesBySize(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	network.Transport("b")
+	a := NewBatchingTransport(network.Transport("a"), BatchConfig{MaxBatch: 3, FlushInterval: time.Hour})
+	defer a.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		_ = a.Send(ctx, Message{To: "b"})
+	}
+	// Two full batches go out at once; the last message waits for the hour.
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Stats().Batches < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := a.Stats(); stats.Batches != 2 || a.Pending("b") != 1 {
+		t.Errorf("Expected 2 batches and 1 pending message, got %+v with %d pending", stats, a.Pending("b"))
+	}
+}
+
+func TestBatchingTransport_SlowPeerIsBounded(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	network.Transport("fast")
+	a := NewBatchingTransport(stalledTransport{network.Transport("a")}, BatchConfig{
+		MaxBatch:      2,
+		QueueCapacity: 4,
+		FlushInterval: time.Millisecond,
+	})
+	defer a.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		_ = a.Send(ctx, Message{To: "slow", Kind: KindDigest})
+		_ = a.Send(ctx, Message{To: "fast", Kind: KindDigest})
+	}
+	if pending := a.Pending("slow"); pending > 4 {
+		t.Errorf("Expected at most 4 queued messages for the slow peer, got %d", pending)
+	}
+	if a.Stats().Dropped == 0 {
+		t.Error("Expected drops for the slow peer")
+	}
+
+	// The stalled peer does not hold back the others.
+	fast := network.Transport("fast")
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Pending("fast") > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fast.Pending() == 0 {
+		t.Error("Expected messages to reach the fast peer")
+	}
+}
+
+func TestBatchingTransport_IdleWorkersExit(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	a := NewBatchingTransport(network.Transport("a"), BatchConfig{
+		FlushInterval: time.Millisecond,
+		IdleTimeout:   10 * time.Millisecond,
+	})
+	defer a.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		network.Transport(fmt.Sprint("peer", i))
+		_ = a.Send(ctx, Message{To: fmt.Sprint("peer", i)})
+	}
+	queues := func() int {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return len(a.queues)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for queues() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := queues(); n != 0 {
+		t.Fatalf("Expected idle queues released, %d left", n)
+	}
+
+	// A peer that comes back gets a new worker.
+	_ = a.Send(ctx, Message{To: "peer0"})
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, err := network.Transport("peer0").Receive(rctx); err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+	}
+}
+
+func TestBatchingTransport_CoalesceLatest(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	a := NewBatchingTransport(stalledTransport{network.Transport("a")}, BatchConfig{
+		QueueCapacity: 2,
+		FlushInterval: time.Hour,
+		Coalesce:      CoalesceLatest(KindState),
+	})
+	defer a.Close()
+
+	ctx := context.Background()
+	_ = a.Send(ctx, Message{To: "slow", Kind: KindDigest})
+	for i := 0; i < 5; i++ {
+		_ = a.Send(ctx, Message{To: "slow", Kind: KindState, Payload: []byte{byte(i)}})
+	}
+	stats := a.Stats()
+	if stats.Coalesced != 4 || stats.Dropped != 0 || a.Pending("slow") != 2 {
+		t.Errorf("Expected 4 coalesced states and no drops, got %+v with %d pending", stats, a.Pending("slow"))
+	}
+}
+
+func TestCoalesceNodes(t *testing.T) {
+	doc := gocrdt.NewRGA("a")
+	first := doc.Insert('A', gocrdt.ID{NodeID: "root"})
+	older, cursor, _ := doc.MarshalChanges(0)
+	doc.Insert('B', first)
+	doc.Delete(first)
+	newer, _, _ := doc.MarshalChanges(cursor)
+
+	combined, ok := CoalesceNodes(Message{Kind: KindState, Payload: older}, Message{Kind: KindState, Payload: newer})
+	if !ok {
+		t.Fatal("Expected RGA payloads to coalesce")
+	}
+	nodes, err := gocrdt.DecodeNodes(combined.Payload)
+	if err != nil || len(nodes) != 2 {
+		t.Fatalf("Expected 2 distinct nodes, got %d (%v)", len(nodes), err)
+	}
+
+	peer := gocrdt.NewRGA("b")
+	if _, err := peer.MergeState(combined.Payload); err != nil {
+		t.Fatalf("MergeState failed: %v", err)
+	}
+	if peer.Value() != "B" {
+		t.Errorf("Expected the tombstone to survive coalescing, got %q", peer.Value())
+	}
+
+	if _, ok := CoalesceNodes(Message{Kind: KindDigest}, Message{Kind: KindState}); ok {
+		t.Error("Expected other kinds not to coalesce")
+	}
+}
+
+func TestBatchingTransport_ReplicasConverge(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	docB.Insert('B', gocrdt.ID{NodeID: "root"})
+
+	batch := BatchConfig{Coalesce: CoalesceNodes}
+	ta := NewBatchingTransport(network.Transport("a"), batch)
+	tb := NewBatchingTransport(network.Transport("b"), batch)
+	defer ta.Close()
+	defer tb.Close()
+	config := Config{Interval: 5 * time.Millisecond}
+	a := NewReplica("a", docA, ta, config)
+	b := NewReplica("b", docB, tb, config)
+	a.AddPeer("b")
+	b.AddPeer("a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := make(chan error, 2)
+	go func() { errs <- a.Run(ctx) }()
+	go func() { errs <- b.Run(ctx) }()
+	for ctx.Err() == nil && (docA.Value() != docB.Value() || len(docA.Value().(string)) != 2) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if docA.Value() != docB.Value() {
+		t.Errorf("Replicas did not converge: %s / %s", docA.Value(), docB.Value())
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+	if err := ta.Send(context.Background(), Message{To: "b"}); err != nil {
+		t.Errorf("Send before Close failed: %v", err)
+	}
+	ta.Close()
+	if err := ta.Send(context.Background(), Message{To: "b"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
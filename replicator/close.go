@@ -0,0 +1,50 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// KindLeave tells a peer that the sender is shutting down for good. The
+// receiver removes it from its peers (PeerLeft), so it no longer ships
+// state to it nor waits on it, for stability for instance. It has no
+// payload.
+const KindLeave Kind = "leave"
+
+// ErrReplicaClosed is returned by a Replica once it has been closed.
+var ErrReplicaClosed = errors.New("replicator: replica closed")
+
+// Close shuts the replica down for a planned stop: it sends its full
+// state to every peer, so no local change is left only here, then tells
+// them it leaves (KindLeave). Sends are retried as by Sync until ctx is
+// done, and the errors of the peers not reached are joined; those peers
+// see the replica go stale instead.
+//
+// After Close, Handle rejects messages with ErrReplicaClosed and Run
+// returns it at its next round. Persist the state once Close returns;
+// the transport stays open, closing it is up to the caller. Closing twice
+// is a no-op.
+func (r *Replica) Close(ctx context.Context) error {
+	if r.closed.Swap(true) {
+		return nil
+	}
+	err := r.Sync(ctx)
+	peers := r.peerIDs()
+	errs := make([]error, len(peers))
+	for i, peer := range peers {
+		if err := r.send(ctx, Message{From: r.id, To: peer, Kind: KindLeave}); err != nil {
+			errs[i] = fmt.Errorf("replicator: leave %s: %w", peer, err)
+		}
+	}
+	r.config.Logger.Info("replicator: closed", "peers", len(peers))
+	return errors.Join(append(errs, err)...)
+}
+
+// handleLeave removes the peer leaving.
+func (r *Replica) handleLeave(msg Message) (gocrdt.MergeResult, error) {
+	r.RemovePeer(msg.From)
+	return gocrdt.MergeResult{}, nil
+}
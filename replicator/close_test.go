@@ -0,0 +1,48 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestReplica_Close(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	hub := newTestHub()
+	counterA, counterB := gocrdt.NewGCounter("a"), gocrdt.NewGCounter("b")
+	var events []MembershipEvent
+	a := NewReplica("a", counterA, hub.transport("a"), Config{})
+	b := NewReplica("b", counterB, hub.transport("b"), Config{
+		OnPeerEvent: func(e MembershipEvent) { events = append(events, e) },
+	})
+	a.AddPeer("b")
+	b.AddPeer("a")
+
+	// The last change made before Close reaches b, which then drops a.
+	counterA.Increment()
+	if err := a.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	hub.pump(t, b)
+	if counterB.Value() != 1 {
+		t.Errorf("Expected the final state delivered, got %d", counterB.Value())
+	}
+	if len(b.Peers()) != 0 || len(events) != 2 || events[1].Type != PeerLeft {
+		t.Errorf("Expected a to have left, got %v and %+v", b.Peers(), events)
+	}
+
+	if err := a.Close(ctx); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+	if _, err := a.Handle(ctx, Message{From: "b", Kind: KindState}); !errors.Is(err, ErrReplicaClosed) {
+		t.Errorf("Expected a closed replica to reject messages, got %v", err)
+	}
+	if err := a.Run(ctx); !errors.Is(err, ErrReplicaClosed) {
+		t.Errorf("Expected Run to stop, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// DefaultFanout is the number of peers contacted per gossip round when
+// GossipConfig.Fanout is zero.
+const DefaultFanout = 3
+
+// GossipConfig enables epidemic anti-entropy on a Replica.
+//
+// Every round (Config.Interval) the replica picks Fanout random peers and
+// sends them a digest of its state. Peers whose digest differs respond by
+// pushing their state and pulling ours, so two replicas that disagree
+// exchange full state once and agree afterwards. States implementing
+// gocrdt.Chunked exchange Merkle trees instead and only transfer the buckets
+// that differ. Updates therefore spread
+// through the cluster in O(log N) rounds without any replica talking to
+// every other one.
+type GossipConfig struct {
+	// Fanout is the number of random peers contacted per round.
+	Fanout int
+
+	// Buckets is the number of Merkle buckets used for gocrdt.Chunked
+	// states (gocrdt.DefaultMerkleBuckets when zero), rounded up to a
+	// power of two and capped at gocrdt.MaxMerkleBuckets. All replicas of a
+	// cluster must use the same value.
+	Buckets int
+
+	// Rand is the source used to pick peers. Tests pass a seeded source to
+	// make rounds reproducible; nil uses the global source.
+	Rand *rand.Rand
+}
+
+// Gossip runs one anti-entropy round: a digest of the local state is sent
+// to up to Fanout randomly chosen peers. The exchange is completed by the
+// peers' replies, which are applied by Handle.
+//
+// Gossip works without a GossipConfig too, in which case DefaultFanout
+// peers are chosen from the global random source.
+func (r *Replica) Gossip(ctx context.Context) (err error) {
+	targets := r.gossipTargets()
+	ctx, span := startSpan(r.config.Tracer, ctx, "gocrdt.gossip", Attribute{"peers", len(targets)})
+	defer func() { endSpan(span, err) }()
+
+	_, perPeer := r.state.(PeerAware)
+	var sum [sha256.Size]byte
+	if !perPeer {
+		if sum, err = stateDigest(r.state, ""); err != nil {
+			return fmt.Errorf("replicator: marshal state: %w", err)
+		}
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, peer := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sum := sum
+			if perPeer {
+				var err error
+				if sum, err = stateDigest(r.state, peer); err != nil {
+					errs[i] = fmt.Errorf("replicator: marshal state for %s: %w", peer, err)
+					return
+				}
+			}
+			msg := Message{From: r.id, To: peer, Kind: KindDigest, Payload: sum[:]}
+			if err := r.send(ctx, msg); err != nil {
+				errs[i] = fmt.Errorf("replicator: gossip to %s: %w", peer, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// gossipTargets picks the random subset of peers for one round.
+func (r *Replica) gossipTargets() []string {
+	fanout := DefaultFanout
+	var source *rand.Rand
+	if r.config.Gossip != nil {
+		if r.config.Gossip.Fanout > 0 {
+			fanout = r.config.Gossip.Fanout
+		}
+		source = r.config.Gossip.Rand
+	}
+
+	peers := r.peerIDs()
+	swap := func(i, j int) { peers[i], peers[j] = peers[j], peers[i] }
+	if source != nil {
+		r.gossipMu.Lock()
+		source.Shuffle(len(peers), swap)
+		r.gossipMu.Unlock()
+	} else {
+		rand.Shuffle(len(peers), swap)
+	}
+	return peers[:min(fanout, len(peers))]
+}
+
+// handleDigest compares a peer's digest with the local state and starts a
+// push-pull exchange when they differ.
+func (r *Replica) handleDigest(ctx context.Context, msg Message) error {
+	sum, err := stateDigest(r.state, msg.From)
+	if err != nil {
+		return fmt.Errorf("replicator: marshal state: %w", err)
+	}
+	if bytes.Equal(sum[:], msg.Payload) {
+		return nil
+	}
+
+	if chunked, ok := r.state.(gocrdt.Chunked); ok {
+		tree, err := chunked.MerkleTree(r.buckets()).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindTree, Payload: tree})
+	}
+	payload, err := marshalFor(r.state, msg.From)
+	if err != nil {
+		return fmt.Errorf("replicator: marshal state: %w", err)
+	}
+	return r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindPull, Payload: payload})
+}
+
+// handleTree compares a peer's Merkle tree with the local one and sends the
+// local content of every differing bucket. A KindTree is answered with the
+// local tree as well, so the peer can send its side of the difference.
+func (r *Replica) handleTree(ctx context.Context, msg Message) error {
+	chunked, ok := r.state.(gocrdt.Chunked)
+	if !ok {
+		return fmt.Errorf("replicator: %s sent a merkle tree but the local state is not chunked", msg.From)
+	}
+
+	remote, err := gocrdt.UnmarshalMerkleTree(msg.Payload)
+	if err != nil {
+		return err
+	}
+	local := chunked.MerkleTree(remote.Buckets())
+	diff, err := local.Diff(remote)
+	if err != nil || len(diff) == 0 {
+		return err
+	}
+
+	if msg.Kind == KindTree {
+		tree, err := local.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if err := r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindTreeReply, Payload: tree}); err != nil {
+			return err
+		}
+	}
+
+	payload, err := chunked.MarshalBuckets(remote.Buckets(), diff)
+	if err != nil {
+		return err
+	}
+	return r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindState, Payload: payload})
+}
+
+// buckets returns the configured Merkle bucket count.
+func (r *Replica) buckets() int {
+	if r.config.Gossip != nil && r.config.Gossip.Buckets > 0 {
+		return r.config.Gossip.Buckets
+	}
+	return gocrdt.DefaultMerkleBuckets
+}
+
+// handlePull merges the state pushed by a peer and answers with the local
+// state so the peer catches up as well.
+func (r *Replica) handlePull(ctx context.Context, msg Message) (gocrdt.MergeResult, error) {
+	result, err := mergeFrom(r.state, msg.From, msg.Payload)
+	if err != nil {
+		return result, err
+	}
+
+	payload, err := marshalFor(r.state, msg.From)
+	if err != nil {
+		return result, fmt.Errorf("replicator: marshal state: %w", err)
+	}
+	return result, r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindState, Payload: payload})
+}
+
+// stateDigest returns the digest of the state as peer sees it: its Hash
+// for a gocrdt.Hasher, which does not depend on the order of its entries,
+// or else the SHA-256 of the payload peer would receive.
+func stateDigest(state gocrdt.Replicable, peer string) ([sha256.Size]byte, error) {
+	if h, ok := state.(gocrdt.Hasher); ok {
+		if _, perPeer := state.(PeerAware); !perPeer {
+			return h.Hash(), nil
+		}
+	}
+	payload, err := marshalFor(state, peer)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(payload), nil
+}
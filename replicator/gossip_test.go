@@ -0,0 +1,213 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// newGossipCluster builds n fully meshed replicas gossiping GCounters.
+func newGossipCluster(hub *testHub, n, fanout int) ([]*Replica, []*gocrdt.GCounter) {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("r%d", i)
+	}
+
+	replicas := make([]*Replica, n)
+	counters := make([]*gocrdt.GCounter, n)
+	for i, id := range ids {
+		counters[i] = gocrdt.NewGCounter(id)
+		config := Config{Gossip: &GossipConfig{Fanout: fanout, Rand: rand.New(rand.NewPCG(uint64(i), 42))}}
+		replicas[i] = NewReplica(id, counters[i], hub.transport(id), config)
+		for _, peer := range ids {
+			replicas[i].AddPeer(peer)
+		}
+	}
+	return replicas, counters
+}
+
+// gossipUntilConverged runs gossip rounds until every counter reports want.
+func gossipUntilConverged(t *testing.T, hub *testHub, replicas []*Replica, counters []*gocrdt.GCounter, want, maxRounds int) int {
+	t.Helper()
+	for round := 1; round <= maxRounds; round++ {
+		for _, r := range replicas {
+			if err := r.Gossip(context.Background()); err != nil {
+				t.Fatalf("Gossip failed: %v", err)
+			}
+		}
+		hub.pump(t, replicas...)
+
+		converged := true
+		for _, c := range counters {
+			converged = converged && c.Value() == want
+		}
+		if converged {
+			return round
+		}
+	}
+	t.Fatalf("Cluster did not converge to %d within %d rounds", want, maxRounds)
+	return 0
+}
+
+func TestGossip_Converges(t *testing.T) {
+	hub := newTestHub()
+	replicas, counters := newGossipCluster(hub, 8, 2)
+	for _, c := range counters {
+		c.Increment()
+	}
+
+	rounds := gossipUntilConverged(t, hub, replicas, counters, 8, 10)
+	t.Logf("Converged after %d rounds", rounds)
+}
+
+func TestGossip_FanoutLimitsTargets(t *testing.T) {
+	hub := newTestHub()
+	replicas, _ := newGossipCluster(hub, 5, 2)
+	if got := len(replicas[0].gossipTargets()); got != 2 {
+		t.Errorf("Expected 2 targets, got %d", got)
+	}
+}
+
+func TestGossip_IdenticalStatesExchangeNothing(t *testing.T) {
+	hub := newTestHub()
+	replicas, _ := newGossipCluster(hub, 2, 1)
+
+	if err := replicas[0].Gossip(context.Background()); err != nil {
+		t.Fatalf("Gossip failed: %v", err)
+	}
+	msg := <-hub.inboxes["r1"]
+	if _, err := replicas[1].Handle(context.Background(), msg); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if len(hub.inboxes["r0"]) != 0 {
+		t.Error("Expected no pull when digests match")
+	}
+}
+
+func TestGossip_ConvergesAfterPartitionHeals(t *testing.T) {
+	hub := newTestHub()
+	replicas, counters := newGossipCluster(hub, 6, 2)
+	hub.partition([]string{"r0", "r1", "r2"}, []string{"r3", "r4", "r5"})
+
+	for i, c := range counters {
+		for j := 0; j <= i; j++ {
+			c.Increment()
+		}
+	}
+
+	// Each side converges on its own total while partitioned.
+	for round := 0; round < 10; round++ {
+		for _, r := range replicas {
+			if err := r.Gossip(context.Background()); err != nil {
+				t.Fatalf("Gossip failed: %v", err)
+			}
+		}
+		hub.pump(t, replicas...)
+	}
+	for i := 0; i < 3; i++ {
+		if counters[i].Value() != 1+2+3 {
+			t.Errorf("Left side r%d: expected 6, got %d", i, counters[i].Value())
+		}
+		if counters[i+3].Value() != 4+5+6 {
+			t.Errorf("Right side r%d: expected 15, got %d", i+3, counters[i+3].Value())
+		}
+	}
+
+	hub.heal()
+	gossipUntilConverged(t, hub, replicas, counters, 21, 20)
+}
+
+func TestGossip_RunMode(t *testing.T) {
+	hub := newTestHub()
+	docA := gocrdt.NewRGA("a")
+	docB := gocrdt.NewRGA("b")
+	config := Config{Interval: 5 * time.Millisecond, Gossip: &GossipConfig{Fanout: 1}}
+	a := NewReplica("a", docA, hub.transport("a"), config)
+	b := NewReplica("b", docB, hub.transport("b"), config)
+	a.AddPeer("b")
+	b.AddPeer("a")
+
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errs := make(chan error, 2)
+	go func() { errs <- a.Run(ctx) }()
+	go func() { errs <- b.Run(ctx) }()
+
+	for ctx.Err() == nil && docB.Value() != "A" {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if docB.Value() != "A" {
+		t.Errorf("Expected gossip to deliver A, got %s", docB.Value())
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestGossip_ChunkedStatesTransferDifferingBuckets(t *testing.T) {
+	for _, buckets := range []int{64, 100} {
+		testChunkedGossip(t, buckets)
+	}
+}
+
+func testChunkedGossip(t *testing.T, buckets int) {
+	t.Helper()
+	hub := newTestHub()
+	docA := gocrdt.NewRGA("a")
+	docB := gocrdt.NewRGA("b")
+	parent := gocrdt.ID{NodeID: "root"}
+	for i := 0; i < 1000; i++ {
+		parent = docA.Insert('x', parent)
+	}
+	docB.Merge(docA.Nodes())
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	docB.Insert('B', gocrdt.ID{NodeID: "root"})
+
+	config := Config{Gossip: &GossipConfig{Fanout: 1, Buckets: buckets}}
+	a := NewReplica("a", docA, hub.transport("a"), config)
+	b := NewReplica("b", docB, hub.transport("b"), config)
+	a.AddPeer("b")
+	b.AddPeer("a")
+
+	full, _ := docA.MarshalState()
+	largest := 0
+	if err := a.Gossip(context.Background()); err != nil {
+		t.Fatalf("Gossip failed: %v", err)
+	}
+	for delivered := true; delivered; {
+		delivered = false
+		for _, r := range []*Replica{a, b} {
+			select {
+			case msg := <-hub.inboxes[r.ID()]:
+				delivered = true
+				if msg.Kind == KindPull {
+					t.Fatal("Chunked states must not fall back to full-state pull")
+				}
+				if msg.Kind == KindState {
+					largest = max(largest, len(msg.Payload))
+				}
+				if _, err := r.Handle(context.Background(), msg); err != nil {
+					t.Fatalf("Handle failed: %v", err)
+				}
+			default:
+			}
+		}
+	}
+
+	if docA.Value() != docB.Value() {
+		t.Fatalf("Replicas diverged: A=%d chars, B=%d chars", len(docA.Value().(string)), len(docB.Value().(string)))
+	}
+	if largest == 0 || largest >= len(full)/4 {
+		t.Errorf("Expected small bucket transfers, largest was %d bytes (full state %d)", largest, len(full))
+	}
+}
@@ -0,0 +1,428 @@
+// Package grpcsync carries replicator traffic over the gRPC Sync service
+// described in sync.proto.
+//
+// It implements the gRPC wire protocol (HTTP/2, length-prefixed protobuf
+// messages, grpc-status trailers) directly on top of net/http, so it needs
+// no generated code or third-party dependencies and interoperates with
+// standard gRPC stacks. A server replica serves the Transport as an
+// http.Handler, client replicas Connect to it:
+//
+//	// server
+//	t := grpcsync.NewTransport("server", doc, nil)
+//	srv := &http.Server{Addr: ":7000", Handler: t, Protocols: grpcsync.Protocols()}
+//	go srv.ListenAndServe()
+//	go replicator.NewReplica("server", doc, t, replicator.Config{}).Run(ctx)
+//
+//	// client
+//	t := grpcsync.NewTransport("alice", doc, nil)
+//	t.Snapshot(ctx, "http://server:7000")           // bootstrap
+//	peer, _ := t.Connect(ctx, "http://server:7000") // live stream
+//	r := replicator.NewReplica("alice", doc, t, replicator.Config{})
+//	r.AddPeer(peer)
+//	go r.Run(ctx)
+package grpcsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+const (
+	syncPath     = "/gocrdt.sync.v1.Sync/Sync"
+	snapshotPath = "/gocrdt.sync.v1.Sync/Snapshot"
+
+	// peerHeader is the gRPC metadata key identifying each end of a stream.
+	peerHeader = "X-Gocrdt-Peer"
+
+	contentType = "application/grpc"
+)
+
+// gRPC status codes used by the service.
+const (
+	statusOK              = "0"
+	statusCancelled       = "1"
+	statusInvalidArgument = "3"
+	statusInternal        = "13"
+	statusUnimplemented   = "12"
+)
+
+// ErrUnknownPeer is returned by Send when no stream to the addressed peer
+// is currently open.
+var ErrUnknownPeer = errors.New("grpcsync: no open stream to peer")
+
+// Protocols returns the HTTP protocols a server must enable to accept gRPC
+// traffic: HTTP/2 over TLS and cleartext HTTP/2 (h2c).
+func Protocols() *http.Protocols {
+	var p http.Protocols
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return &p
+}
+
+// Transport is a replicator.Transport backed by gRPC Sync streams.
+//
+// The same Transport can accept streams (as an http.Handler) and open them
+// (Connect); messages are routed to whichever stream belongs to msg.To.
+type Transport struct {
+	id     string
+	state  gocrdt.Replicable
+	client *http.Client
+	inbox  chan replicator.Message
+
+	mu      sync.Mutex
+	streams map[string]*stream
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// stream is the sending half of one Sync stream.
+type stream struct {
+	mu     sync.Mutex
+	w      io.Writer
+	flush  func()
+	closed bool
+}
+
+// write sends one message on the stream.
+func (s *stream) write(msg replicator.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrUnknownPeer
+	}
+	if err := writeFrame(s.w, marshalEnvelope(msg)); err != nil {
+		return err
+	}
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// close marks the stream unusable; it waits for an in-flight write.
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+// NewTransport creates a transport for the replica id. The state is served
+// to Snapshot callers and receives bootstrap snapshots. A nil client uses an
+// HTTP/2-only client that speaks h2c for http:// URLs.
+func NewTransport(id string, state gocrdt.Replicable, client *http.Client) *Transport {
+	if client == nil {
+		client = &http.Client{Transport: &http.Transport{Protocols: Protocols()}}
+	}
+	return &Transport{
+		id:      id,
+		state:   state,
+		client:  client,
+		inbox:   make(chan replicator.Message, 256),
+		streams: make(map[string]*stream),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Send writes msg to the stream of msg.To.
+func (t *Transport) Send(_ context.Context, msg replicator.Message) error {
+	select {
+	case <-t.closed:
+		return replicator.ErrClosed
+	default:
+	}
+
+	t.mu.Lock()
+	s, ok := t.streams[msg.To]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownPeer, msg.To)
+	}
+	return s.write(msg)
+}
+
+// Receive returns the next message received on any stream.
+func (t *Transport) Receive(ctx context.Context) (replicator.Message, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return replicator.Message{}, ctx.Err()
+	case <-t.closed:
+		return replicator.Message{}, replicator.ErrClosed
+	}
+}
+
+// Close ends every stream. Pending Receive calls return ErrClosed.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for peer, s := range t.streams {
+			s.close()
+			if c, ok := s.w.(io.Closer); ok {
+				c.Close()
+			}
+			delete(t.streams, peer)
+		}
+	})
+	return nil
+}
+
+// Peers returns the IDs of the peers with an open stream.
+func (t *Transport) Peers() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	peers := make([]string, 0, len(t.streams))
+	for peer := range t.streams {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// register makes s the stream used for peer, replacing a previous one.
+func (t *Transport) register(peer string, s *stream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.streams[peer]; ok {
+		old.close()
+	}
+	t.streams[peer] = s
+}
+
+// unregister forgets s if it is still the stream used for peer.
+func (t *Transport) unregister(peer string, s *stream) {
+	s.close()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.streams[peer] == s {
+		delete(t.streams, peer)
+	}
+}
+
+// readLoop feeds messages from one stream into the inbox until the stream
+// fails. The sender's identity is taken from the stream, not the message.
+func (t *Transport) readLoop(peer string, body io.Reader) error {
+	for {
+		frame, err := readFrame(body)
+		if err != nil {
+			return err
+		}
+		msg, err := unmarshalEnvelope(frame)
+		if err != nil {
+			return err
+		}
+		msg.From, msg.To = peer, t.id
+
+		select {
+		case t.inbox <- msg:
+		case <-t.closed:
+			return replicator.ErrClosed
+		}
+	}
+}
+
+// ServeHTTP implements the server side of the Sync service.
+func (t *Transport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), contentType) {
+		http.Error(w, "grpcsync: expected a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.Header().Set(peerHeader, t.id)
+
+	switch r.URL.Path {
+	case syncPath:
+		t.serveSync(w, r)
+	case snapshotPath:
+		t.serveSnapshot(w, r)
+	default:
+		setStatus(w, statusUnimplemented, "unknown method "+r.URL.Path)
+	}
+}
+
+// serveSync registers the caller's stream and pumps its messages into the
+// inbox until the caller hangs up or the transport is closed.
+func (t *Transport) serveSync(w http.ResponseWriter, r *http.Request) {
+	peer := r.Header.Get(peerHeader)
+	if peer == "" {
+		setStatus(w, statusInvalidArgument, "missing "+peerHeader+" metadata")
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		setStatus(w, statusInternal, err.Error())
+		return
+	}
+
+	s := &stream{w: w, flush: func() { _ = controller.Flush() }}
+	t.register(peer, s)
+	defer t.unregister(peer, s)
+
+	done := make(chan error, 1)
+	go func() { done <- t.readLoop(peer, r.Body) }()
+
+	select {
+	case err := <-done:
+		if err == nil || errors.Is(err, io.EOF) {
+			setStatus(w, statusOK, "")
+		} else {
+			setStatus(w, statusInternal, err.Error())
+		}
+	case <-t.closed:
+		setStatus(w, statusCancelled, "transport closed")
+	case <-r.Context().Done():
+	}
+}
+
+// serveSnapshot answers a Snapshot call with the full local state.
+func (t *Transport) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	frame, err := readFrame(r.Body)
+	if err != nil {
+		setStatus(w, statusInvalidArgument, err.Error())
+		return
+	}
+	request, err := unmarshalEnvelope(frame)
+	if err != nil {
+		setStatus(w, statusInvalidArgument, err.Error())
+		return
+	}
+
+	payload, err := t.state.MarshalState()
+	if err != nil {
+		setStatus(w, statusInternal, err.Error())
+		return
+	}
+	msg := replicator.Message{From: t.id, To: request.From, Kind: replicator.KindState, Payload: payload}
+	if err := writeFrame(w, marshalEnvelope(msg)); err != nil {
+		return
+	}
+	setStatus(w, statusOK, "")
+}
+
+// setStatus reports the gRPC status in the response trailers.
+func setStatus(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Grpc-Status", code)
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+}
+
+// Connect opens a Sync stream to the server at baseURL and returns the
+// server's peer ID, under which the stream is registered. The stream stays
+// open until ctx is cancelled, either side hangs up, or Close is called.
+func (t *Transport) Connect(ctx context.Context, baseURL string) (string, error) {
+	reader, writer := io.Pipe()
+	req, err := t.newRequest(ctx, baseURL+syncPath, reader)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		writer.Close()
+		return "", err
+	}
+	peer, err := checkResponse(resp)
+	if err != nil {
+		writer.Close()
+		resp.Body.Close()
+		return "", err
+	}
+
+	s := &stream{w: writer}
+	t.register(peer, s)
+	go func() {
+		defer resp.Body.Close()
+		defer writer.Close()
+		defer t.unregister(peer, s)
+		_ = t.readLoop(peer, resp.Body)
+	}()
+	return peer, nil
+}
+
+// Snapshot calls the server's Snapshot method and merges the returned
+// state into the local CRDT, bootstrapping a fresh replica in one call.
+func (t *Transport) Snapshot(ctx context.Context, baseURL string) (gocrdt.MergeResult, error) {
+	var body strings.Builder
+	request := replicator.Message{From: t.id}
+	if err := writeFrame(&body, marshalEnvelope(request)); err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+
+	req, err := t.newRequest(ctx, baseURL+snapshotPath, strings.NewReader(body.String()))
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	defer resp.Body.Close()
+	if _, err := checkResponse(resp); err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+
+	frame, err := readFrame(resp.Body)
+	if err != nil {
+		// An error status is only visible in the trailers.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if statusErr := trailerStatus(resp); statusErr != nil {
+			return gocrdt.MergeResult{}, statusErr
+		}
+		return gocrdt.MergeResult{}, err
+	}
+	msg, err := unmarshalEnvelope(frame)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	return t.state.MergeState(msg.Payload)
+}
+
+// newRequest builds a gRPC POST request carrying the local peer ID.
+func (t *Transport) newRequest(ctx context.Context, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Te", "trailers")
+	req.Header.Set(peerHeader, t.id)
+	return req, nil
+}
+
+// checkResponse validates the response headers and returns the server's
+// peer ID.
+func checkResponse(resp *http.Response) (string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("grpcsync: unexpected HTTP status %s", resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), contentType) {
+		return "", fmt.Errorf("grpcsync: unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	if status := resp.Header.Get("Grpc-Status"); status != "" && status != statusOK {
+		return "", fmt.Errorf("grpcsync: status %s: %s", status, resp.Header.Get("Grpc-Message"))
+	}
+	return resp.Header.Get(peerHeader), nil
+}
+
+// trailerStatus converts a non-OK grpc-status trailer into an error.
+func trailerStatus(resp *http.Response) error {
+	if status := resp.Trailer.Get("Grpc-Status"); status != "" && status != statusOK {
+		return fmt.Errorf("grpcsync: status %s: %s", status, resp.Trailer.Get("Grpc-Message"))
+	}
+	return nil
+}
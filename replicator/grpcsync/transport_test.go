@@ -0,0 +1,160 @@
+package grpcsync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// newServer serves the transport over cleartext HTTP/2.
+func newServer(t *testing.T, transport *Transport) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(transport)
+	srv.Config.Protocols = Protocols()
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTransport_Snapshot(t *testing.T) {
+	serverDoc := gocrdt.NewRGA("server")
+	idH := serverDoc.Insert('H', gocrdt.ID{NodeID: "root"})
+	serverDoc.Insert('i', idH)
+	server := NewTransport("server", serverDoc, nil)
+	srv := newServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clientDoc := gocrdt.NewRGA("alice")
+	client := NewTransport("alice", clientDoc, nil)
+	result, err := client.Snapshot(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if clientDoc.Value() != "Hi" || result.Applied != 2 {
+		t.Errorf("Expected Hi with 2 applied nodes, got %s (%+v)", clientDoc.Value(), result)
+	}
+}
+
+func TestTransport_StreamBothDirections(t *testing.T) {
+	server := NewTransport("server", gocrdt.NewGCounter("server"), nil)
+	srv := newServer(t, server)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewTransport("alice", gocrdt.NewGCounter("alice"), nil)
+	defer client.Close()
+	peer, err := client.Connect(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if peer != "server" {
+		t.Fatalf("Expected server peer ID, got %q", peer)
+	}
+
+	up := replicator.Message{To: "server", Kind: replicator.KindDigest, Payload: []byte("up")}
+	if err := client.Send(ctx, up); err != nil {
+		t.Fatalf("Client send failed: %v", err)
+	}
+	received, err := server.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Server receive failed: %v", err)
+	}
+	if received.From != "alice" || string(received.Payload) != "up" {
+		t.Errorf("Unexpected message at server: %+v", received)
+	}
+
+	down := replicator.Message{From: "server", To: "alice", Kind: replicator.KindState, Payload: []byte("down")}
+	if err := server.Send(ctx, down); err != nil {
+		t.Fatalf("Server send failed: %v", err)
+	}
+	received, err = client.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Client receive failed: %v", err)
+	}
+	if received.From != "server" || string(received.Payload) != "down" {
+		t.Errorf("Unexpected message at client: %+v", received)
+	}
+
+	if err := server.Send(ctx, replicator.Message{To: "nobody"}); !errors.Is(err, ErrUnknownPeer) {
+		t.Errorf("Expected ErrUnknownPeer, got %v", err)
+	}
+}
+
+func TestTransport_ReplicasConverge(t *testing.T) {
+	serverDoc := gocrdt.NewRGA("server")
+	serverDoc.Insert('S', gocrdt.ID{NodeID: "root"})
+	serverTransport := NewTransport("server", serverDoc, nil)
+	srv := newServer(t, serverTransport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clientDoc := gocrdt.NewRGA("alice")
+	clientDoc.Insert('A', gocrdt.ID{NodeID: "root"})
+	clientTransport := NewTransport("alice", clientDoc, nil)
+	peer, err := clientTransport.Connect(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	config := replicator.Config{Interval: 10 * time.Millisecond}
+	serverReplica := replicator.NewReplica("server", serverDoc, serverTransport, config)
+	serverReplica.AddPeer("alice")
+	clientReplica := replicator.NewReplica("alice", clientDoc, clientTransport, config)
+	clientReplica.AddPeer(peer)
+
+	errs := make(chan error, 2)
+	go func() { errs <- serverReplica.Run(ctx) }()
+	go func() { errs <- clientReplica.Run(ctx) }()
+
+	for ctx.Err() == nil && (serverDoc.Value() != clientDoc.Value() || len(serverDoc.Value().(string)) != 2) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if serverDoc.Value() != clientDoc.Value() {
+		t.Errorf("Replicas did not converge: server=%s, client=%s", serverDoc.Value(), clientDoc.Value())
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+	serverTransport.Close()
+	clientTransport.Close()
+}
+
+func TestTransport_RejectsNonGRPCRequests(t *testing.T) {
+	transport := NewTransport("server", gocrdt.NewGCounter("server"), nil)
+	srv := newServer(t, transport)
+	resp, err := transport.client.Post(srv.URL+syncPath, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415, got %d", resp.StatusCode)
+	}
+}
+
+func TestTransport_ClosedTransport(t *testing.T) {
+	transport := NewTransport("alice", gocrdt.NewGCounter("alice"), nil)
+	transport.Close()
+	if _, err := transport.Receive(context.Background()); !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Receive, got %v", err)
+	}
+	if err := transport.Send(context.Background(), replicator.Message{To: "bob"}); !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Send, got %v", err)
+	}
+}
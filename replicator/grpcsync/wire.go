@@ -0,0 +1,137 @@
+package grpcsync
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// MaxMessageSize bounds a single gRPC message. Larger frames are rejected
+// before any allocation so a misbehaving peer cannot exhaust memory.
+const MaxMessageSize = 64 << 20
+
+// Protobuf field numbers, see sync.proto.
+const (
+	fieldFrom    = 1
+	fieldTo      = 2
+	fieldKind    = 3
+	fieldPayload = 4
+)
+
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+var (
+	errCompressed = errors.New("grpcsync: compressed messages are not supported")
+	errTruncated  = errors.New("grpcsync: truncated protobuf message")
+)
+
+// marshalEnvelope encodes a message as a sync.proto Envelope.
+func marshalEnvelope(msg replicator.Message) []byte {
+	var buf []byte
+	buf = appendBytesField(buf, fieldFrom, []byte(msg.From))
+	buf = appendBytesField(buf, fieldTo, []byte(msg.To))
+	buf = appendBytesField(buf, fieldKind, []byte(msg.Kind))
+	buf = appendBytesField(buf, fieldPayload, msg.Payload)
+	return buf
+}
+
+// unmarshalEnvelope decodes a sync.proto Envelope (or a SnapshotRequest,
+// whose only field shares the number of Envelope.from). Unknown fields are
+// skipped for forward compatibility.
+func unmarshalEnvelope(data []byte) (replicator.Message, error) {
+	var msg replicator.Message
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return msg, errTruncated
+		}
+		data = data[n:]
+
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case wireVarint:
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return msg, errTruncated
+			}
+			data = data[n:]
+		case wireI64, wireI32:
+			size := 8
+			if wireType == wireI32 {
+				size = 4
+			}
+			if len(data) < size {
+				return msg, errTruncated
+			}
+			data = data[size:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return msg, errTruncated
+			}
+			value := data[n : n+int(length)]
+			data = data[n+int(length):]
+
+			switch field {
+			case fieldFrom:
+				msg.From = string(value)
+			case fieldTo:
+				msg.To = string(value)
+			case fieldKind:
+				msg.Kind = replicator.Kind(value)
+			case fieldPayload:
+				msg.Payload = append([]byte(nil), value...)
+			}
+		default:
+			return msg, fmt.Errorf("grpcsync: unsupported wire type %d", wireType)
+		}
+	}
+	return msg, nil
+}
+
+// appendBytesField appends a length-delimited field, omitting empty values
+// as proto3 does.
+func appendBytesField(buf []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// writeFrame writes one length-prefixed gRPC message.
+func writeFrame(w io.Writer, message []byte) error {
+	header := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+	_, err := w.Write(append(header, message...))
+	return err
+}
+
+// readFrame reads one length-prefixed gRPC message.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, errCompressed
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > MaxMessageSize {
+		return nil, fmt.Errorf("grpcsync: message of %d bytes exceeds limit", length)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,57 @@
+package grpcsync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+func TestWire_EnvelopeRoundTrip(t *testing.T) {
+	msg := replicator.Message{From: "alice", To: "bob", Kind: replicator.KindState, Payload: []byte{0, 1, 2}}
+	decoded, err := unmarshalEnvelope(marshalEnvelope(msg))
+	if err != nil {
+		t.Fatalf("unmarshalEnvelope failed: %v", err)
+	}
+	if decoded.From != msg.From || decoded.To != msg.To || decoded.Kind != msg.Kind || !bytes.Equal(decoded.Payload, msg.Payload) {
+		t.Errorf("Round trip mismatch: %+v", decoded)
+	}
+}
+
+func TestWire_SkipsUnknownFields(t *testing.T) {
+	data := marshalEnvelope(replicator.Message{From: "alice"})
+	data = binary.AppendUvarint(data, 9<<3|wireVarint)
+	data = binary.AppendUvarint(data, 300)
+	data = appendBytesField(data, 10, []byte("future"))
+
+	decoded, err := unmarshalEnvelope(data)
+	if err != nil || decoded.From != "alice" {
+		t.Errorf("Expected unknown fields to be skipped, got %+v (%v)", decoded, err)
+	}
+}
+
+func TestWire_RejectsTruncatedMessages(t *testing.T) {
+	data := marshalEnvelope(replicator.Message{From: "alice", Payload: []byte("payload")})
+	if _, err := unmarshalEnvelope(data[:len(data)-2]); err == nil {
+		t.Error("Expected error for truncated message")
+	}
+}
+
+func TestWire_Frames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, []byte("hello")); err != nil {
+		t.Fatalf("writeFrame failed: %v", err)
+	}
+	frame, err := readFrame(&buf)
+	if err != nil || string(frame) != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", frame, err)
+	}
+
+	if _, err := readFrame(bytes.NewReader([]byte{1, 0, 0, 0, 0})); err != errCompressed {
+		t.Errorf("Expected errCompressed, got %v", err)
+	}
+	if _, err := readFrame(bytes.NewReader([]byte{0, 0xff, 0xff, 0xff, 0xff})); err == nil {
+		t.Error("Expected error for oversized frame")
+	}
+}
@@ -0,0 +1,177 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionBehind is returned by ClientSession.Await when no replica
+// caught up with the session before the context was done.
+var ErrSessionBehind = errors.New("replicator: replica has not caught up with the session")
+
+// Guarantee selects the session guarantees a ClientSession enforces on top
+// of eventual consistency. Guarantees combine with |.
+type Guarantee uint8
+
+const (
+	// ReadYourWrites makes reads see every write of the session.
+	ReadYourWrites Guarantee = 1 << iota
+
+	// MonotonicReads makes reads never see less than an earlier read of
+	// the session did.
+	MonotonicReads
+)
+
+// ClientSession tracks what one client wrote and read across the replicas
+// of a state, as version vectors of their change logs, and routes its
+// reads to replicas that have caught up with it. A read goes:
+//
+//	log, err := session.Await(ctx, nearest, others...)
+//	// read the state behind log
+//	session.Observed(log)
+//
+// and a write, once applied on the replica of log:
+//
+//	session.Wrote(log)
+//
+// Only states with deltas (DeltaState) have change logs to compare; over
+// other states the session guarantees nothing. A replica that restarted
+// with a fresh epoch never covers what the session saw of its old log, so
+// reads are redirected elsewhere until the session is dropped.
+type ClientSession struct {
+	guarantees Guarantee
+
+	mu     sync.Mutex
+	writes VersionVector
+	reads  VersionVector
+}
+
+// NewClientSession creates a session enforcing guarantees.
+func NewClientSession(guarantees Guarantee) *ClientSession {
+	return &ClientSession{guarantees: guarantees, writes: make(VersionVector), reads: make(VersionVector)}
+}
+
+// Required returns the version vector a replica must cover to serve the
+// next read of the session.
+func (s *ClientSession) Required() VersionVector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	required := make(VersionVector)
+	if s.guarantees&ReadYourWrites != 0 {
+		advance(required, s.writes)
+	}
+	if s.guarantees&MonotonicReads != 0 {
+		advance(required, s.reads)
+	}
+	return required
+}
+
+// Wrote records a write of the session applied on the replica of log: its
+// own change log up to the current head.
+func (s *ClientSession) Wrote(log *SessionLog) {
+	head, ok := log.head()
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	advance(s.writes, VersionVector{log.id: head})
+}
+
+// Observed records a read of the session served by the replica of log.
+// Call it after the read, so that it covers everything the read saw. The
+// read then requires every log that replica had received from, at least
+// as far: comparing per origin cannot tell that another path delivered the
+// same changes.
+func (s *ClientSession) Observed(log *SessionLog) {
+	head := log.Head()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	advance(s.reads, head)
+}
+
+// Ready reports whether the replica of log can serve the next read.
+func (s *ClientSession) Ready(log *SessionLog) bool {
+	return log.Head().Covers(s.Required())
+}
+
+// Await returns the first of preferred and others whose replica can serve
+// the next read, redirecting away from replicas that lag behind. When none
+// can, it waits for preferred to receive what it misses, and returns an
+// error wrapping ErrSessionBehind and the context's error if ctx is done
+// first.
+func (s *ClientSession) Await(ctx context.Context, preferred *SessionLog, others ...*SessionLog) (*SessionLog, error) {
+	required := s.Required()
+	for _, log := range append([]*SessionLog{preferred}, others...) {
+		if log.Head().Covers(required) {
+			return log, nil
+		}
+	}
+	for {
+		// Fetch the channel first so an update in between is not missed.
+		changed := preferred.changed()
+		if preferred.Head().Covers(required) {
+			return preferred, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrSessionBehind, ctx.Err())
+		}
+	}
+}
+
+// Head returns the version vector of everything the replica has: what it
+// received from each peer, and its own change log up to the current head.
+func (l *SessionLog) Head() VersionVector {
+	vector := l.Vector()
+	if head, ok := l.head(); ok {
+		vector[l.id] = head
+	}
+	return vector
+}
+
+// head returns the position of the local change log, if the state has one.
+func (l *SessionLog) head() (Version, bool) {
+	delta, ok := l.state.(DeltaState)
+	if !ok || l.epoch == "" {
+		return Version{}, false
+	}
+	_, cursor, err := delta.MarshalChanges(^uint64(0))
+	if err != nil {
+		return Version{}, false
+	}
+	return Version{Epoch: l.epoch, Cursor: cursor}, true
+}
+
+func (l *SessionLog) changed() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.notify
+}
+
+// Covers reports whether v has reached every version of other: the same
+// epoch of each origin, at the same or a later cursor.
+func (v VersionVector) Covers(other VersionVector) bool {
+	for origin, want := range other {
+		have, ok := v[origin]
+		if !ok || have.Epoch != want.Epoch || have.Cursor < want.Cursor {
+			return false
+		}
+	}
+	return true
+}
+
+// advance moves the entries of into forward to those of from. An entry of
+// another epoch replaces the old one: sessions only ever see a replica's
+// logs in the order it started them.
+func advance(into, from VersionVector) {
+	for origin, v := range from {
+		if old, ok := into[origin]; ok && old.Epoch == v.Epoch && old.Cursor >= v.Cursor {
+			continue
+		}
+		into[origin] = v
+	}
+}
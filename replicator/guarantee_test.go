@@ -0,0 +1,101 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestClientSession_ReadYourWrites(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+
+	session := NewClientSession(ReadYourWrites)
+	docA.Insert('x', gocrdt.ID{NodeID: "root"})
+	session.Wrote(logA)
+
+	// b has not received the write: the read is redirected to a.
+	if session.Ready(logB) || !session.Ready(logA) {
+		t.Fatalf("Expected only a to be ready, required %v", session.Required())
+	}
+	if log, err := session.Await(context.Background(), logB, logA); err != nil || log != logA {
+		t.Errorf("Expected a redirect to a, got %v, %v", log, err)
+	}
+
+	// Without a fallback the read times out on b...
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := session.Await(ctx, logB); !errors.Is(err, ErrSessionBehind) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected ErrSessionBehind, got %v", err)
+	}
+
+	// ...and is unblocked once b catches up.
+	done := make(chan error)
+	go func() {
+		_, err := session.Await(context.Background(), logB)
+		done <- err
+	}()
+	p.poll()
+	if err := <-done; err != nil || docB.Value() != "x" {
+		t.Errorf("Expected b to serve the write, got %q, %v", docB.Value(), err)
+	}
+}
+
+func TestClientSession_MonotonicReads(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docC := gocrdt.NewRGA("c")
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	logC := NewSessionLog("c", docC)
+	newSessionPair(t, logA, logC).handshake(nil)
+	docC.Insert('c', gocrdt.ID{NodeID: "root"})
+	p := newSessionPair(t, logA, logC)
+	p.handshake(nil)
+
+	// Reading a, which has c's write, forbids later reads on b.
+	session := NewClientSession(MonotonicReads)
+	if !session.Ready(logB) {
+		t.Fatal("Expected a fresh session to read anywhere")
+	}
+	session.Observed(logA)
+	if session.Ready(logB) {
+		t.Errorf("Expected b to lag behind the read, required %v", session.Required())
+	}
+	newSessionPair(t, logB, logC).handshake(nil)
+	if session.Ready(logB) {
+		t.Errorf("Expected b to lag behind a's log, have %v", logB.Head())
+	}
+	newSessionPair(t, logA, logB).handshake(nil)
+	if !session.Ready(logB) {
+		t.Errorf("Expected b to serve once it received both logs, have %v", logB.Head())
+	}
+
+	// Writes alone do not constrain monotonic reads.
+	docA.Insert('a', gocrdt.ID{NodeID: "root"})
+	session.Wrote(logA)
+	if !session.Ready(logB) {
+		t.Error("Expected writes to be ignored without ReadYourWrites")
+	}
+}
+
+func TestVersionVector_Covers(t *testing.T) {
+	v := VersionVector{"a": {Epoch: "1", Cursor: 5}}
+	for _, tt := range []struct {
+		other VersionVector
+		want  bool
+	}{
+		{VersionVector{}, true},
+		{VersionVector{"a": {Epoch: "1", Cursor: 5}}, true},
+		{VersionVector{"a": {Epoch: "1", Cursor: 6}}, false},
+		{VersionVector{"a": {Epoch: "2", Cursor: 1}}, false},
+		{VersionVector{"b": {Epoch: "1", Cursor: 1}}, false},
+	} {
+		if got := v.Covers(tt.other); got != tt.want {
+			t.Errorf("Covers(%v) = %v, want %v", tt.other, got, tt.want)
+		}
+	}
+}
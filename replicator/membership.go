@@ -0,0 +1,198 @@
+package replicator
+
+import (
+	"sort"
+	"time"
+)
+
+// PeerStatus is the liveness of a peer as seen by the local replica.
+type PeerStatus int
+
+const (
+	// PeerAlive peers were heard from within Config.StaleAfter.
+	PeerAlive PeerStatus = iota
+
+	// PeerStale peers have been silent for longer than Config.StaleAfter.
+	// They stay members and keep receiving state; they are reported so
+	// that callers can stop waiting on them (e.g. for stability) or remove
+	// them.
+	PeerStale
+)
+
+func (s PeerStatus) String() string {
+	switch s {
+	case PeerAlive:
+		return "alive"
+	case PeerStale:
+		return "stale"
+	}
+	return "unknown"
+}
+
+// PeerInfo describes a member of the replica's peer set.
+type PeerInfo struct {
+	ID     string
+	Status PeerStatus
+
+	// Joined is when the peer was added, LastSeen when a message from it
+	// was last handled (zero if never).
+	Joined   time.Time
+	LastSeen time.Time
+
+	// Acked is the highest version vector the peer is known to have
+	// received, as reported through RecordAck.
+	Acked VersionVector
+}
+
+// MembershipEventType identifies a change in the peer set.
+type MembershipEventType int
+
+const (
+	// PeerJoined fires when AddPeer adds a peer that was not a member.
+	PeerJoined MembershipEventType = iota
+
+	// PeerLeft fires when a peer is removed or announces that it leaves.
+	PeerLeft
+
+	// PeerWentStale fires when a peer is silent for over Config.StaleAfter.
+	PeerWentStale
+
+	// PeerRecovered fires when a message arrives from a stale peer.
+	PeerRecovered
+)
+
+func (t MembershipEventType) String() string {
+	switch t {
+	case PeerJoined:
+		return "joined"
+	case PeerLeft:
+		return "left"
+	case PeerWentStale:
+		return "stale"
+	case PeerRecovered:
+		return "recovered"
+	}
+	return "unknown"
+}
+
+// MembershipEvent is passed to Config.OnPeerEvent.
+type MembershipEvent struct {
+	Type MembershipEventType
+	Peer PeerInfo
+}
+
+// member is the membership bookkeeping of one peer; guarded by Replica.mu.
+type member struct {
+	joined   time.Time
+	lastSeen time.Time
+	stale    bool
+	acked    VersionVector
+}
+
+func (m *member) info(id string) PeerInfo {
+	info := PeerInfo{ID: id, Joined: m.joined, LastSeen: m.lastSeen}
+	if m.stale {
+		info.Status = PeerStale
+	}
+	if m.acked != nil {
+		info.Acked = make(VersionVector, len(m.acked))
+		for peer, v := range m.acked {
+			info.Acked[peer] = v
+		}
+	}
+	return info
+}
+
+// Peers returns the membership view, sorted by peer ID.
+func (r *Replica) Peers() []PeerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	peers := make([]PeerInfo, 0, len(r.peers))
+	for id, m := range r.peers {
+		peers = append(peers, m.info(id))
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	return peers
+}
+
+// peerIDs returns the IDs of the known peers in sorted order.
+func (r *Replica) peerIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.peers))
+	for id := range r.peers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RecordAck records that peer has received everything up to vector, for
+// example the vector of a SyncSession (SyncSession.PeerVector). Entries only
+// move forward within an epoch; unknown peers are ignored.
+func (r *Replica) RecordAck(peer string, vector VersionVector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.peers[peer]
+	if !ok {
+		return
+	}
+	if m.acked == nil {
+		m.acked = make(VersionVector, len(vector))
+	}
+	for origin, v := range vector {
+		if old, ok := m.acked[origin]; !ok || old.Epoch != v.Epoch || old.Cursor < v.Cursor {
+			m.acked[origin] = v
+		}
+	}
+}
+
+// touch marks peer as heard from at now.
+func (r *Replica) touch(peer string, now time.Time) {
+	r.mu.Lock()
+	m, ok := r.peers[peer]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	m.lastSeen = now
+	recovered := m.stale
+	m.stale = false
+	info := m.info(peer)
+	r.mu.Unlock()
+
+	if recovered {
+		r.emit(MembershipEvent{Type: PeerRecovered, Peer: info})
+	}
+}
+
+// checkLiveness marks peers silent for longer than Config.StaleAfter as
+// stale. It runs once per round.
+func (r *Replica) checkLiveness(now time.Time) {
+	var events []MembershipEvent
+	r.mu.Lock()
+	for id, m := range r.peers {
+		last := m.lastSeen
+		if last.IsZero() {
+			last = m.joined
+		}
+		if !m.stale && now.Sub(last) > r.config.StaleAfter {
+			m.stale = true
+			events = append(events, MembershipEvent{Type: PeerWentStale, Peer: m.info(id)})
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Peer.ID < events[j].Peer.ID })
+	for _, event := range events {
+		r.emit(event)
+	}
+}
+
+// emit delivers a membership event. It must be called without r.mu held.
+func (r *Replica) emit(event MembershipEvent) {
+	r.config.Logger.Info("replicator: peer "+event.Type.String(), "peer", event.Peer.ID)
+	if r.config.OnPeerEvent != nil {
+		r.config.OnPeerEvent(event)
+	}
+}
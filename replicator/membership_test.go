@@ -0,0 +1,139 @@
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestMembership_Events(t *testing.T) {
+	var events []MembershipEvent
+	r := NewReplica("a", gocrdt.NewGCounter("a"), NewNetwork(NetworkConfig{}).Transport("a"), Config{
+		StaleAfter:  time.Minute,
+		OnPeerEvent: func(e MembershipEvent) { events = append(events, e) },
+	})
+
+	r.AddPeer("b")
+	r.AddPeer("b") // already a member
+	r.AddPeer("c")
+	joined := r.Peers()[0].Joined
+
+	// b is heard from, c stays silent past StaleAfter.
+	if _, err := r.Handle(context.Background(), Message{From: "b", Kind: KindState, Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	r.checkLiveness(joined.Add(30 * time.Second))
+	r.checkLiveness(joined.Add(2 * time.Minute))
+	r.checkLiveness(joined.Add(3 * time.Minute)) // no repeated event
+
+	peers := r.Peers()
+	if peers[0].LastSeen.IsZero() || peers[1].Status != PeerStale {
+		t.Errorf("Unexpected membership view: %+v", peers)
+	}
+
+	// c comes back, then b leaves.
+	if _, err := r.Handle(context.Background(), Message{From: "c", Kind: KindState, Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	r.RemovePeer("b")
+	r.RemovePeer("b") // no longer a member
+
+	want := []struct {
+		typ  MembershipEventType
+		peer string
+	}{
+		{PeerJoined, "b"}, {PeerJoined, "c"},
+		{PeerWentStale, "b"}, {PeerWentStale, "c"},
+		{PeerRecovered, "c"}, {PeerLeft, "b"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Peer.ID != w.peer {
+			t.Errorf("Event %d: expected %v %s, got %v %s", i, w.typ, w.peer, events[i].Type, events[i].Peer.ID)
+		}
+	}
+}
+
+func TestMembership_StaleDuringRun(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	network.Transport("b") // b exists but never answers
+	stale := make(chan MembershipEvent, 1)
+	r := NewReplica("a", gocrdt.NewGCounter("a"), network.Transport("a"), Config{
+		Interval: 5 * time.Millisecond,
+		OnPeerEvent: func(e MembershipEvent) {
+			if e.Type == PeerWentStale {
+				stale <- e
+			}
+		},
+	})
+	r.AddPeer("b")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = r.Run(ctx) }()
+
+	select {
+	case e := <-stale:
+		if e.Peer.ID != "b" || e.Peer.Status != PeerStale {
+			t.Errorf("Unexpected event: %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the silent peer to go stale")
+	}
+}
+
+func TestMembership_RecordAckFromSession(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+
+	r := NewReplica("a", docA, NewNetwork(NetworkConfig{}).Transport("a"), Config{})
+	r.AddPeer("b")
+	r.RecordAck("b", p.a.PeerVector())
+	r.RecordAck("b", VersionVector{"a": {Epoch: logA.Epoch(), Cursor: 0}}) // never moves back
+	r.RecordAck("nobody", p.a.PeerVector())
+
+	acked := r.Peers()[0].Acked["a"]
+	if acked.Epoch != logA.Epoch() || acked.Cursor != 1 {
+		t.Errorf("Expected b to have acked a's log up to 1, got %+v", acked)
+	}
+	if len(r.Peers()) != 1 {
+		t.Errorf("RecordAck must not add members, got %+v", r.Peers())
+	}
+}
+
+func TestReplica_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewReplica("a", gocrdt.NewGCounter("a"), NewNetwork(NetworkConfig{}).Transport("a"), Config{
+		Logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	r.AddPeer("b")
+	remote := gocrdt.NewGCounter("b")
+	remote.Increment()
+	payload, _ := remote.MarshalState()
+	if _, err := r.Handle(context.Background(), Message{From: "b", Kind: KindState, Payload: payload}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if _, err := r.Handle(context.Background(), Message{From: "b", Kind: "bogus"}); err == nil {
+		t.Fatal("Expected an unknown kind to fail")
+	}
+
+	for _, want := range []string{
+		`level=INFO msg="replicator: peer joined" peer=b`,
+		`level=DEBUG msg="replicator: merged" peer=b kind=state applied=1`,
+		`level=WARN msg="replicator: handle failed" peer=b kind=bogus`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the log to contain %s, got:\n%s", want, buf.String())
+		}
+	}
+}
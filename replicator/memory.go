@@ -0,0 +1,350 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// DefaultNetworkInboxSize is the per-endpoint queue length used when
+// NetworkConfig.InboxSize is zero.
+const DefaultNetworkInboxSize = 1024
+
+// ErrUnknownPeer is returned by a MemoryTransport sending to an endpoint
+// that was never created on its Network.
+var ErrUnknownPeer = errors.New("replicator: unknown peer")
+
+// NetworkConfig describes the faults a Network injects. The zero value is
+// a perfect network: no delay, loss, duplication or reordering.
+type NetworkConfig struct {
+	// Latency delays every message; Jitter adds a random extra delay in
+	// [0, Jitter). Jitter alone is enough to reorder messages.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// DropRate, DuplicateRate and ReorderRate are probabilities in [0, 1].
+	// A reordered message jumps ahead of a random number of the messages
+	// already queued for the same receiver.
+	DropRate      float64
+	DuplicateRate float64
+	ReorderRate   float64
+
+	// InboxSize bounds the messages queued per endpoint; messages sent to
+	// a full inbox are dropped.
+	InboxSize int
+
+	// Rand drives every fault decision. Tests pass a seeded source to make
+	// runs reproducible; nil uses the global source.
+	Rand *rand.Rand
+}
+
+// NetworkStats counts what a Network did with the messages sent through it.
+type NetworkStats struct {
+	Sent       int // Send calls that reached the network
+	Delivered  int // messages returned by Receive
+	Dropped    int // lost to DropRate, a partition, a full or closed inbox
+	Duplicated int // extra copies queued by DuplicateRate
+	Reordered  int // messages queued ahead of earlier ones by ReorderRate
+}
+
+// Network is an in-process network connecting MemoryTransports. It lets
+// tests exercise a replication topology, including lossy and partitioned
+// links, without sockets.
+type Network struct {
+	config NetworkConfig
+
+	mu        sync.Mutex
+	endpoints map[string]*MemoryTransport
+	group     map[string]int // partition group per endpoint; 0 = reachable by all
+	stats     NetworkStats
+}
+
+// NewNetwork creates an empty network injecting the faults in config.
+func NewNetwork(config NetworkConfig) *Network {
+	if config.InboxSize <= 0 {
+		config.InboxSize = DefaultNetworkInboxSize
+	}
+	return &Network{
+		config:    config,
+		endpoints: make(map[string]*MemoryTransport),
+		group:     make(map[string]int),
+	}
+}
+
+// Transport returns the endpoint of the replica id, creating it on first
+// use. Messages sent to id before the endpoint exists fail with
+// ErrUnknownPeer.
+func (n *Network) Transport(id string) *MemoryTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if t, ok := n.endpoints[id]; ok {
+		return t
+	}
+	t := &MemoryTransport{
+		network: n,
+		id:      id,
+		wake:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	n.endpoints[id] = t
+	return t
+}
+
+// Partition splits the network: endpoints in different groups silently
+// lose each other's messages until Heal is called. Endpoints not listed
+// can still reach everyone.
+func (n *Network) Partition(groups ...[]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, members := range groups {
+		for _, id := range members {
+			n.group[id] = i + 1
+		}
+	}
+}
+
+// Heal removes every partition.
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.group = make(map[string]int)
+}
+
+// Stats returns a snapshot of the network counters.
+func (n *Network) Stats() NetworkStats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.stats
+}
+
+// chance reports whether an event of probability p happens. It must be
+// called with n.mu held, which also serializes access to config.Rand.
+func (n *Network) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	return n.float64() < p
+}
+
+func (n *Network) float64() float64 {
+	if n.config.Rand != nil {
+		return n.config.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+func (n *Network) intN(max int) int {
+	if n.config.Rand != nil {
+		return n.config.Rand.IntN(max)
+	}
+	return rand.IntN(max)
+}
+
+// delay returns the delivery delay of one message copy.
+func (n *Network) delay() time.Duration {
+	d := n.config.Latency
+	if n.config.Jitter > 0 {
+		d += time.Duration(n.float64() * float64(n.config.Jitter))
+	}
+	return d
+}
+
+// send applies the configured faults to msg and queues the surviving
+// copies at the receiver.
+func (n *Network) send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	to, ok := n.endpoints[msg.To]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownPeer, msg.To)
+	}
+	n.stats.Sent++
+
+	if from, dest := n.group[msg.From], n.group[msg.To]; from != 0 && dest != 0 && from != dest {
+		n.stats.Dropped++
+		return nil
+	}
+	if to.isClosed() || n.chance(n.config.DropRate) {
+		n.stats.Dropped++
+		return nil
+	}
+
+	copies := 1
+	if n.chance(n.config.DuplicateRate) {
+		copies++
+		n.stats.Duplicated++
+	}
+	now := time.Now()
+	for range copies {
+		if len(to.queue) >= n.config.InboxSize {
+			n.stats.Dropped++
+			continue
+		}
+		item := queued{msg: msg, at: now.Add(n.delay())}
+		reorder := len(to.queue) > 0 && n.chance(n.config.ReorderRate)
+		to.enqueue(item, reorder, n.intN)
+		if reorder {
+			n.stats.Reordered++
+		}
+	}
+	return nil
+}
+
+// queued is a message waiting for its delivery time.
+type queued struct {
+	msg Message
+	at  time.Time
+}
+
+// MemoryTransport is one endpoint of a Network.
+type MemoryTransport struct {
+	network *Network
+	id      string
+
+	queue     []queued      // sorted by delivery time; guarded by network.mu
+	wake      chan struct{} // signalled when the queue head may have changed
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// enqueue inserts item in delivery-time order or, when reorder is set,
+// ahead of a random number of the queued messages, taking over the delivery
+// time of the one it displaces. It must be called with network.mu held.
+func (t *MemoryTransport) enqueue(item queued, reorder bool, intN func(int) int) {
+	pos := len(t.queue)
+	if reorder {
+		pos = intN(len(t.queue))
+		item.at = t.queue[pos].at
+	} else {
+		for pos > 0 && t.queue[pos-1].at.After(item.at) {
+			pos--
+		}
+	}
+	t.queue = append(t.queue, queued{})
+	copy(t.queue[pos+1:], t.queue[pos:])
+	t.queue[pos] = item
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ID returns the replica ID of the endpoint.
+func (t *MemoryTransport) ID() string {
+	return t.id
+}
+
+// Send hands msg to the network. Faults are silent, as on a real network;
+// only unknown receivers and a closed endpoint are reported.
+func (t *MemoryTransport) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if t.isClosed() {
+		return ErrClosed
+	}
+	msg.From = t.id
+	return t.network.send(msg)
+}
+
+// Receive returns the next message whose delivery time has come.
+func (t *MemoryTransport) Receive(ctx context.Context) (Message, error) {
+	for {
+		if t.isClosed() {
+			return Message{}, ErrClosed
+		}
+		n := t.network
+		n.mu.Lock()
+		wait := time.Duration(-1)
+		if len(t.queue) > 0 {
+			head := t.queue[0]
+			if wait = time.Until(head.at); wait <= 0 {
+				t.queue = t.queue[1:]
+				n.stats.Delivered++
+				n.mu.Unlock()
+				return head.msg, nil
+			}
+		}
+		n.mu.Unlock()
+
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-timeout:
+		case <-t.wake:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-t.closed:
+			return Message{}, ErrClosed
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Pending returns the number of messages queued for the endpoint,
+// including those whose delivery time has not come yet.
+func (t *MemoryTransport) Pending() int {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	return len(t.queue)
+}
+
+// isClosed reports whether Close was called.
+func (t *MemoryTransport) isClosed() bool {
+	select {
+	case <-t.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close shuts the endpoint down. Pending Receive calls and later sends
+// from it return ErrClosed; messages sent to it are dropped.
+func (t *MemoryTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return nil
+}
+
+// ChanTransport adapts a pair of channels to a Transport: Send writes to
+// Out and Receive reads from In. Tests use it to route, inspect or hold
+// back every message by hand.
+type ChanTransport struct {
+	In  <-chan Message
+	Out chan<- Message
+}
+
+// Send blocks until Out accepts msg or ctx is done.
+func (t ChanTransport) Send(ctx context.Context, msg Message) error {
+	select {
+	case t.Out <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Receive returns the next message from In. A closed In channel reports
+// ErrClosed.
+func (t ChanTransport) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg, ok := <-t.In:
+		if !ok {
+			return Message{}, ErrClosed
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
@@ -0,0 +1,211 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// receiveAll drains the endpoint until no message arrives for a while.
+func receiveAll(t *MemoryTransport) []Message {
+	var msgs []Message
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		msg, err := t.Receive(ctx)
+		cancel()
+		if err != nil {
+			return msgs
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func sendN(t *testing.T, from *MemoryTransport, to string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := from.Send(context.Background(), Message{To: to, Payload: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+}
+
+func TestNetwork_PerfectDeliveryInOrder(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	a, b := network.Transport("a"), network.Transport("b")
+	if network.Transport("a") != a {
+		t.Fatal("Expected Transport to return the existing endpoint")
+	}
+
+	sendN(t, a, "b", 10)
+	msgs := receiveAll(b)
+	if len(msgs) != 10 {
+		t.Fatalf("Expected 10 messages, got %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		if msg.From != "a" || msg.Payload[0] != byte(i) {
+			t.Errorf("Message %d out of order or misattributed: %+v", i, msg)
+		}
+	}
+
+	if err := a.Send(context.Background(), Message{To: "nobody"}); !errors.Is(err, ErrUnknownPeer) {
+		t.Errorf("Expected ErrUnknownPeer, got %v", err)
+	}
+}
+
+func TestNetwork_SeededFaultsAreReproducible(t *testing.T) {
+	run := func() (NetworkStats, []byte) {
+		network := NewNetwork(NetworkConfig{
+			DropRate:      0.2,
+			DuplicateRate: 0.2,
+			ReorderRate:   0.2,
+			Rand:          rand.New(rand.NewPCG(1, 2)),
+		})
+		a, b := network.Transport("a"), network.Transport("b")
+		sendN(t, a, "b", 200)
+		var order []byte
+		for _, msg := range receiveAll(b) {
+			order = append(order, msg.Payload[0])
+		}
+		return network.Stats(), order
+	}
+
+	stats, order := run()
+	if stats.Dropped == 0 || stats.Duplicated == 0 || stats.Reordered == 0 {
+		t.Fatalf("Expected every fault to occur, got %+v", stats)
+	}
+	if want := stats.Sent - stats.Dropped + stats.Duplicated; stats.Delivered != want || len(order) != want {
+		t.Errorf("Expected %d deliveries, got %d (%+v)", want, len(order), stats)
+	}
+
+	again, orderAgain := run()
+	if again != stats || string(order) != string(orderAgain) {
+		t.Errorf("Same seed gave different runs: %+v vs %+v", stats, again)
+	}
+}
+
+func TestNetwork_Latency(t *testing.T) {
+	network := NewNetwork(NetworkConfig{Latency: 50 * time.Millisecond})
+	a, b := network.Transport("a"), network.Transport("b")
+
+	start := time.Now()
+	sendN(t, a, "b", 1)
+	if b.Pending() != 1 {
+		t.Fatalf("Expected 1 pending message, got %d", b.Pending())
+	}
+	if _, err := b.Receive(context.Background()); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Message delivered after %v, before the 50ms latency", elapsed)
+	}
+}
+
+func TestNetwork_PartitionAndClose(t *testing.T) {
+	network := NewNetwork(NetworkConfig{})
+	a, b := network.Transport("a"), network.Transport("b")
+
+	network.Partition([]string{"a"}, []string{"b"})
+	sendN(t, a, "b", 3)
+	if b.Pending() != 0 || network.Stats().Dropped != 3 {
+		t.Errorf("Expected the partition to drop 3 messages, got %+v", network.Stats())
+	}
+
+	network.Heal()
+	sendN(t, a, "b", 1)
+	if b.Pending() != 1 {
+		t.Errorf("Expected delivery after Heal, got %d pending", b.Pending())
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := b.Receive(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from Receive, got %v", err)
+	}
+	if err := b.Send(context.Background(), Message{To: "a"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from Send, got %v", err)
+	}
+}
+
+func TestNetwork_ReplicasConvergeOverLossyLinks(t *testing.T) {
+	network := NewNetwork(NetworkConfig{
+		Jitter:        5 * time.Millisecond,
+		DropRate:      0.3,
+		DuplicateRate: 0.3,
+		ReorderRate:   0.3,
+		Rand:          rand.New(rand.NewPCG(7, 7)),
+	})
+	ids := []string{"a", "b", "c", "d"}
+	docs := make(map[string]*gocrdt.RGA)
+	var replicas []*Replica
+	for _, id := range ids {
+		docs[id] = gocrdt.NewRGA(id)
+		docs[id].Insert(rune(id[0]), gocrdt.ID{NodeID: "root"})
+		r := NewReplica(id, docs[id], network.Transport(id), Config{
+			Interval: 5 * time.Millisecond,
+			Gossip:   &GossipConfig{Fanout: 2},
+		})
+		for _, peer := range ids {
+			r.AddPeer(peer)
+		}
+		replicas = append(replicas, r)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errs := make(chan error, len(replicas))
+	for _, r := range replicas {
+		go func() { errs <- r.Run(ctx) }()
+	}
+
+	converged := func() bool {
+		v := docs["a"].Value()
+		for _, id := range ids {
+			if docs[id].Value() != v {
+				return false
+			}
+		}
+		return len(v.(string)) == len(ids)
+	}
+	for ctx.Err() == nil && !converged() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !converged() {
+		t.Errorf("Replicas did not converge over a lossy network: %+v", network.Stats())
+	}
+
+	cancel()
+	for range replicas {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestChanTransport(t *testing.T) {
+	in := make(chan Message, 1)
+	out := make(chan Message, 1)
+	transport := ChanTransport{In: in, Out: out}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := transport.Send(ctx, Message{To: "b"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if msg := <-out; msg.To != "b" {
+		t.Errorf("Unexpected message on Out: %+v", msg)
+	}
+
+	in <- Message{From: "b"}
+	if msg, err := transport.Receive(ctx); err != nil || msg.From != "b" {
+		t.Errorf("Unexpected Receive result: %+v (%v)", msg, err)
+	}
+	close(in)
+	if _, err := transport.Receive(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed on a closed In channel, got %v", err)
+	}
+}
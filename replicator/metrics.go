@@ -0,0 +1,43 @@
+package replicator
+
+import (
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// Metrics receives the measurements of a Replica, for export to a
+// monitoring system (see the prom package for Prometheus). Implementations
+// must be safe for concurrent use and must not block.
+type Metrics interface {
+	// ObserveHandle reports a message handled from peer: its kind, what
+	// merging it did, how long it took and the error, if any.
+	ObserveHandle(peer string, kind Kind, result gocrdt.MergeResult, latency time.Duration, err error)
+
+	// ObserveSent and ObserveReceived report payload bytes exchanged with
+	// peer.
+	ObserveSent(peer string, bytes int)
+	ObserveReceived(peer string, bytes int)
+
+	// ObserveDocument reports the size of the local document after a merge
+	// changed it, for states that report one (gocrdt.RGA).
+	ObserveDocument(stats gocrdt.DocStats)
+}
+
+// statsState is implemented by states that report their size.
+type statsState interface {
+	Stats() gocrdt.DocStats
+}
+
+// observeHandle reports a handled message to the configured Metrics.
+func (r *Replica) observeHandle(msg Message, result gocrdt.MergeResult, start time.Time, err error) {
+	m := r.config.Metrics
+	if m == nil {
+		return
+	}
+	m.ObserveReceived(msg.From, len(msg.Payload))
+	m.ObserveHandle(msg.From, msg.Kind, result, time.Since(start), err)
+	if s, ok := r.state.(statsState); ok && (result.Applied > 0 || result.Deleted > 0) {
+		m.ObserveDocument(s.Stats())
+	}
+}
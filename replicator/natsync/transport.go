@@ -0,0 +1,231 @@
+// Package natsync carries replicator traffic over NATS subjects.
+//
+// Every replica subscribes to <prefix>.<id> for messages addressed to it
+// and answers full-state bootstrap requests on <prefix>.<id>.snapshot, so
+// a fleet that already runs NATS needs no extra infrastructure.
+//
+// The package does not import a NATS client. It talks to the broker through
+// the small Conn interface, which a few lines adapt from *nats.Conn:
+//
+//	type natsConn struct{ *nats.Conn }
+//
+//	func (c natsConn) Subscribe(subject string, h natsync.Handler) (natsync.Subscription, error) {
+//		return c.Conn.Subscribe(subject, func(m *nats.Msg) { h(m.Subject, m.Reply, m.Data) })
+//	}
+//
+//	func (c natsConn) Request(ctx context.Context, subject string, data []byte) ([]byte, error) {
+//		m, err := c.Conn.RequestWithContext(ctx, subject, data)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return m.Data, nil
+//	}
+//
+// and then:
+//
+//	t, err := natsync.NewTransport(natsConn{nc}, "alice", doc, natsync.Config{})
+//	t.Snapshot(ctx, "bob") // bootstrap from any live replica
+//	r := replicator.NewReplica("alice", doc, t, replicator.Config{})
+package natsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// Default configuration values.
+const (
+	DefaultPrefix    = "gocrdt"
+	DefaultInboxSize = 256
+)
+
+// ErrInvalidID is returned for replica IDs that are not a single NATS
+// subject token.
+var ErrInvalidID = errors.New("natsync: replica ID must be a non-empty subject token without '.', '*', '>' or whitespace")
+
+// Handler receives a message published on a subscribed subject. Reply is
+// the subject a response must be published to, empty if none is expected.
+type Handler func(subject, reply string, data []byte)
+
+// Subscription is an active subscription; *nats.Subscription satisfies it.
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Conn is the subset of a NATS connection used by the transport.
+type Conn interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler Handler) (Subscription, error)
+	Request(ctx context.Context, subject string, data []byte) ([]byte, error)
+}
+
+// Config tunes a Transport. The zero value uses the defaults.
+type Config struct {
+	// Prefix is prepended to every subject, separating independent
+	// replica groups on the same broker.
+	Prefix string
+
+	// InboxSize bounds the messages buffered for Receive. NATS delivers at
+	// most once, so messages arriving while the inbox is full are dropped
+	// and recovered by the next anti-entropy round.
+	InboxSize int
+}
+
+// envelope is the wire form of a replicator.Message.
+type envelope struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Kind    replicator.Kind `json:"kind"`
+	Payload []byte          `json:"payload,omitempty"`
+}
+
+// Transport is a replicator.Transport backed by NATS subjects.
+type Transport struct {
+	conn   Conn
+	id     string
+	state  gocrdt.Replicable
+	prefix string
+	inbox  chan replicator.Message
+
+	subs      []Subscription
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTransport subscribes the replica id to its inbox and snapshot
+// subjects. The state is served to Snapshot callers and receives the
+// snapshots fetched by Snapshot.
+func NewTransport(conn Conn, id string, state gocrdt.Replicable, config Config) (*Transport, error) {
+	if !validToken(id) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrefix
+	}
+	if config.InboxSize <= 0 {
+		config.InboxSize = DefaultInboxSize
+	}
+
+	t := &Transport{
+		conn:   conn,
+		id:     id,
+		state:  state,
+		prefix: config.Prefix,
+		inbox:  make(chan replicator.Message, config.InboxSize),
+		closed: make(chan struct{}),
+	}
+
+	inbox, err := conn.Subscribe(t.subject(id), t.handleMessage)
+	if err != nil {
+		return nil, err
+	}
+	t.subs = append(t.subs, inbox)
+
+	snapshot, err := conn.Subscribe(t.subject(id)+".snapshot", t.handleSnapshot)
+	if err != nil {
+		_ = inbox.Unsubscribe()
+		return nil, err
+	}
+	t.subs = append(t.subs, snapshot)
+	return t, nil
+}
+
+// validToken reports whether id can be used as one subject token.
+func validToken(id string) bool {
+	return id != "" && !strings.ContainsAny(id, ".*> \t\r\n")
+}
+
+// subject returns the inbox subject of the replica id.
+func (t *Transport) subject(id string) string {
+	return t.prefix + "." + id
+}
+
+// Send publishes msg on the inbox subject of msg.To.
+func (t *Transport) Send(_ context.Context, msg replicator.Message) error {
+	select {
+	case <-t.closed:
+		return replicator.ErrClosed
+	default:
+	}
+	if !validToken(msg.To) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, msg.To)
+	}
+
+	data, err := json.Marshal(envelope{From: t.id, To: msg.To, Kind: msg.Kind, Payload: msg.Payload})
+	if err != nil {
+		return err
+	}
+	return t.conn.Publish(t.subject(msg.To), data)
+}
+
+// Receive returns the next message published to this replica.
+func (t *Transport) Receive(ctx context.Context) (replicator.Message, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return replicator.Message{}, ctx.Err()
+	case <-t.closed:
+		return replicator.Message{}, replicator.ErrClosed
+	}
+}
+
+// handleMessage queues an incoming message, dropping it if the inbox is
+// full so that the NATS dispatcher is never blocked.
+func (t *Transport) handleMessage(_, _ string, data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return // not ours; nothing to reply to
+	}
+	msg := replicator.Message{From: env.From, To: env.To, Kind: env.Kind, Payload: env.Payload}
+	select {
+	case t.inbox <- msg:
+	default:
+	}
+}
+
+// handleSnapshot answers a bootstrap request with the full local state.
+func (t *Transport) handleSnapshot(_, reply string, _ []byte) {
+	if reply == "" {
+		return
+	}
+	data, err := t.state.MarshalState()
+	if err != nil {
+		return // the requester times out and can try another replica
+	}
+	_ = t.conn.Publish(reply, data)
+}
+
+// Snapshot requests the full state of the replica peer and merges it into
+// the local CRDT, bootstrapping a fresh replica in one call. The request
+// fails when ctx expires before peer answers.
+func (t *Transport) Snapshot(ctx context.Context, peer string) (gocrdt.MergeResult, error) {
+	if !validToken(peer) {
+		return gocrdt.MergeResult{}, fmt.Errorf("%w: %q", ErrInvalidID, peer)
+	}
+	data, err := t.conn.Request(ctx, t.subject(peer)+".snapshot", nil)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	return t.state.MergeState(data)
+}
+
+// Close unsubscribes from both subjects. Pending Receive calls return
+// ErrClosed.
+func (t *Transport) Close() error {
+	var errs []error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		for _, sub := range t.subs {
+			errs = append(errs, sub.Unsubscribe())
+		}
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,224 @@
+package natsync
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// testBus is an in-process stand-in for a NATS server with exact-match
+// subjects and asynchronous delivery.
+type testBus struct {
+	mu      sync.Mutex
+	subs    map[string]map[int]Handler
+	nextID  int
+	replies int
+}
+
+func newTestBus() *testBus {
+	return &testBus{subs: make(map[string]map[int]Handler)}
+}
+
+func (b *testBus) Publish(subject string, data []byte) error {
+	return b.publish(subject, "", data)
+}
+
+func (b *testBus) publish(subject, reply string, data []byte) error {
+	b.mu.Lock()
+	var handlers []Handler
+	for _, h := range b.subs[subject] {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+	for _, h := range handlers {
+		go h(subject, reply, data)
+	}
+	return nil
+}
+
+func (b *testBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[subject] == nil {
+		b.subs[subject] = make(map[int]Handler)
+	}
+	b.nextID++
+	id := b.nextID
+	b.subs[subject][id] = handler
+	return testSubscription(func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[subject], id)
+		return nil
+	}), nil
+}
+
+func (b *testBus) Request(ctx context.Context, subject string, data []byte) ([]byte, error) {
+	b.mu.Lock()
+	b.replies++
+	reply := "_INBOX." + strconv.Itoa(b.replies)
+	b.mu.Unlock()
+
+	answer := make(chan []byte, 1)
+	sub, _ := b.Subscribe(reply, func(_, _ string, data []byte) { answer <- data })
+	defer sub.Unsubscribe()
+
+	if err := b.publish(subject, reply, data); err != nil {
+		return nil, err
+	}
+	select {
+	case data := <-answer:
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type testSubscription func() error
+
+func (s testSubscription) Unsubscribe() error { return s() }
+
+func TestTransport_SendReceive(t *testing.T) {
+	bus := newTestBus()
+	a, err := NewTransport(bus, "a", gocrdt.NewGCounter("a"), Config{})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	b, err := NewTransport(bus, "b", gocrdt.NewGCounter("b"), Config{})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	defer a.Close()
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg := replicator.Message{From: "a", To: "b", Kind: replicator.KindState, Payload: []byte("hello")}
+	if err := a.Send(ctx, msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	received, err := b.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if received.From != "a" || received.Kind != replicator.KindState || string(received.Payload) != "hello" {
+		t.Errorf("Unexpected message: %+v", received)
+	}
+
+	if err := a.Send(ctx, replicator.Message{To: "b.*"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for a wildcard peer, got %v", err)
+	}
+	if _, err := NewTransport(bus, "x.y", gocrdt.NewGCounter("x"), Config{}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for a dotted ID, got %v", err)
+	}
+}
+
+func TestTransport_Snapshot(t *testing.T) {
+	bus := newTestBus()
+	docBob := gocrdt.NewRGA("bob")
+	id := docBob.Insert('H', gocrdt.ID{NodeID: "root"})
+	docBob.Insert('i', id)
+	bob, err := NewTransport(bus, "bob", docBob, Config{Prefix: "docs"})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	defer bob.Close()
+
+	docAlice := gocrdt.NewRGA("alice")
+	alice, err := NewTransport(bus, "alice", docAlice, Config{Prefix: "docs"})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	defer alice.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := alice.Snapshot(ctx, "bob")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if docAlice.Value() != "Hi" || result.Applied != 2 {
+		t.Errorf("Expected Hi with 2 applied nodes, got %s (%+v)", docAlice.Value(), result)
+	}
+
+	// Nobody answers for an unknown replica.
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if _, err := alice.Snapshot(short, "carol"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTransport_ReplicasConverge(t *testing.T) {
+	bus := newTestBus()
+	config := replicator.Config{Interval: 10 * time.Millisecond}
+	docs := make(map[string]*gocrdt.RGA)
+	var replicas []*replicator.Replica
+	for _, id := range []string{"a", "b", "c"} {
+		docs[id] = gocrdt.NewRGA(id)
+		docs[id].Insert(rune(id[0]), gocrdt.ID{NodeID: "root"})
+		transport, err := NewTransport(bus, id, docs[id], Config{})
+		if err != nil {
+			t.Fatalf("NewTransport failed: %v", err)
+		}
+		defer transport.Close()
+		replicas = append(replicas, replicator.NewReplica(id, docs[id], transport, config))
+	}
+	for _, r := range replicas {
+		for _, peer := range []string{"a", "b", "c"} {
+			r.AddPeer(peer)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := make(chan error, len(replicas))
+	for _, r := range replicas {
+		go func() { errs <- r.Run(ctx) }()
+	}
+
+	converged := func() bool {
+		v := docs["a"].Value()
+		return len(v.(string)) == 3 && docs["b"].Value() == v && docs["c"].Value() == v
+	}
+	for ctx.Err() == nil && !converged() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !converged() {
+		t.Errorf("Replicas did not converge: %s %s %s", docs["a"].Value(), docs["b"].Value(), docs["c"].Value())
+	}
+
+	cancel()
+	for range replicas {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestTransport_Close(t *testing.T) {
+	bus := newTestBus()
+	transport, err := NewTransport(bus, "a", gocrdt.NewGCounter("a"), Config{})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := transport.Receive(context.Background()); !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Receive, got %v", err)
+	}
+	if err := transport.Send(context.Background(), replicator.Message{To: "b"}); !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Send, got %v", err)
+	}
+	if n := len(bus.subs["gocrdt.a"]) + len(bus.subs["gocrdt.a.snapshot"]); n != 0 {
+		t.Errorf("Expected no subscriptions after Close, got %d", n)
+	}
+}
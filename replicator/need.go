@@ -0,0 +1,85 @@
+package replicator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// KindNeed asks a peer for the causal dependencies a merge found missing.
+// Its payload lists the IDs; the receiver answers with them and their
+// ancestors (KindState), or not at all when it has none of them.
+const KindNeed Kind = "need"
+
+// GapFiller is implemented by states that buffer remote updates until
+// their causal dependencies arrive, such as gocrdt.RGA (orphans), and can
+// ship those dependencies on request.
+type GapFiller interface {
+	// MissingParents returns the dependencies the buffered updates wait
+	// for.
+	MissingParents() []gocrdt.ID
+
+	// MarshalAncestry encodes ids and their own dependencies as a payload
+	// for MergeState, or returns nil when it knows none of them.
+	MarshalAncestry(ids []gocrdt.ID) ([]byte, error)
+}
+
+// needPayload is the wire form of a KindNeed message.
+type needPayload struct {
+	V    int         `json:"v"`
+	Need []gocrdt.ID `json:"need"`
+}
+
+// RequestMissing asks peer for the dependencies the local state is missing
+// (KindNeed), closing a causal gap without waiting for the next full-state
+// round. It does nothing when the state is not a GapFiller or misses
+// nothing. With Config.RequestMissing set, Handle calls it for every merge
+// that buffered updates.
+func (r *Replica) RequestMissing(ctx context.Context, peer string) error {
+	filler, ok := r.state.(GapFiller)
+	if !ok {
+		return nil
+	}
+	missing := filler.MissingParents()
+	if len(missing) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(needPayload{V: gocrdt.WireVersion, Need: missing})
+	if err != nil {
+		return err
+	}
+	r.config.Logger.Debug("replicator: requesting missing dependencies", "peer", peer, "count", len(missing))
+	return r.send(ctx, Message{From: r.id, To: peer, Kind: KindNeed, Payload: payload})
+}
+
+// requestGaps asks the sender of a merged message for the dependencies the
+// merge found missing, when Config.RequestMissing is set.
+func (r *Replica) requestGaps(ctx context.Context, peer string, result gocrdt.MergeResult) error {
+	if !r.config.RequestMissing || result.Orphaned == 0 {
+		return nil
+	}
+	return r.RequestMissing(ctx, peer)
+}
+
+// handleNeed answers a KindNeed with the requested dependencies the local
+// state has.
+func (r *Replica) handleNeed(ctx context.Context, msg Message) error {
+	filler, ok := r.state.(GapFiller)
+	if !ok {
+		return fmt.Errorf("replicator: %s asked for dependencies but the local state cannot ship them", msg.From)
+	}
+	var need needPayload
+	if err := json.Unmarshal(msg.Payload, &need); err != nil {
+		return fmt.Errorf("replicator: need from %s: %w", msg.From, err)
+	}
+	if need.V > gocrdt.WireVersion {
+		return fmt.Errorf("%w %d", gocrdt.ErrUnsupportedVersion, need.V)
+	}
+	payload, err := filler.MarshalAncestry(need.Need)
+	if err != nil || payload == nil {
+		return err
+	}
+	return r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindState, Payload: payload})
+}
@@ -0,0 +1,45 @@
+package replicator
+
+import (
+	"context"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var _ GapFiller = (*gocrdt.RGA)(nil)
+
+func TestReplica_RequestMissing(t *testing.T) {
+	hub := newTestHub()
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	a := NewReplica("a", docA, hub.transport("a"), Config{})
+	b := NewReplica("b", docB, hub.transport("b"), Config{RequestMissing: true})
+
+	first := docA.Insert('a', gocrdt.ID{NodeID: "root"})
+	second := docA.Insert('b', first)
+	_, cursor := docA.Changes(0)
+	docA.Insert('c', second)
+	delta, _, err := docA.MarshalChanges(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b gets c alone, asks a for its ancestors and merges them.
+	result, err := b.Handle(context.Background(), Message{From: "a", To: "b", Kind: KindState, Payload: delta})
+	if err != nil || result.Orphaned != 1 {
+		t.Fatalf("Expected c to be buffered, got %+v, %v", result, err)
+	}
+	hub.pump(t, a, b)
+	if docB.Value() != "abc" || len(docB.MissingParents()) != 0 {
+		t.Errorf("Expected the gap to be closed, got %q missing %v", docB.Value(), docB.MissingParents())
+	}
+
+	// A request for nodes the peer does not have goes unanswered.
+	need := []byte(`{"v":2,"need":[{"Timestamp":9,"NodeID":"z"}]}`)
+	if _, err := a.Handle(context.Background(), Message{From: "b", To: "a", Kind: KindNeed, Payload: need}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(hub.inboxes["b"]); n != 0 {
+		t.Errorf("Expected no answer, got %d messages", n)
+	}
+}
@@ -0,0 +1,393 @@
+package replicator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+const (
+	// KindOps carries local ops queued by an Outbox, with the version of
+	// the receiver's state the sender has. The receiver applies them and
+	// answers with KindOpsAck.
+	KindOps Kind = "ops"
+
+	// KindOpsAck acknowledges a KindOps: the ops up to its sequence number
+	// are applied, and it carries what changed on the sender since the
+	// version of the KindOps.
+	KindOpsAck Kind = "ops-ack"
+)
+
+// OperableState is a state whose local mutations can be queued as ops,
+// such as gocrdt.RGA or the counters.
+type OperableState interface {
+	gocrdt.Replicable
+	gocrdt.Operable
+}
+
+// Resumable is implemented by states that can ship what changed after a
+// gocrdt.Version, such as gocrdt.RGA. Answers to an Outbox are then deltas
+// instead of full states.
+type Resumable interface {
+	MarshalSince(v gocrdt.Version) ([]byte, gocrdt.Version, error)
+}
+
+// opsPayload is the wire form of a KindOps message.
+type opsPayload struct {
+	V     int            `json:"v"`
+	Seq   uint64         `json:"seq"`
+	Ops   []gocrdt.Op    `json:"ops"`
+	Since gocrdt.Version `json:"since,omitempty"`
+}
+
+// opsAckPayload is the wire form of a KindOpsAck message.
+type opsAckPayload struct {
+	V       int            `json:"v"`
+	Seq     uint64         `json:"seq"`
+	Version gocrdt.Version `json:"version,omitempty"`
+	State   []byte         `json:"state,omitempty"`
+}
+
+// outboxMeta is the persisted progress of an Outbox.
+type outboxMeta struct {
+	Acked   uint64         `json:"acked"`
+	Version gocrdt.Version `json:"version,omitempty"`
+}
+
+// queuedOp is an op of an Outbox with its sequence number.
+type queuedOp struct {
+	seq uint64
+	op  gocrdt.Op
+}
+
+// OutboxConfig tunes an Outbox. Zero values select the defaults.
+type OutboxConfig struct {
+	// Storage persists the queued ops under Key, so they survive a restart
+	// of the client while it is offline. Nil keeps them in memory.
+	Storage storage.Storage
+	Key     string
+
+	// Interval is the period at which Run sends the queued ops until they
+	// are acknowledged (DefaultInterval when zero).
+	Interval time.Duration
+
+	// OnError, when set, receives the errors of Run and of queuing ops,
+	// which would otherwise be dropped.
+	OnError func(error)
+
+	// Logger receives queued and acknowledged ops (Debug) and failures
+	// (Warn). It defaults to discarding everything.
+	Logger gocrdt.Logger
+}
+
+// Outbox is the client side of an offline-first application: it queues
+// the ops of the local mutations of a state, persisted if configured,
+// sends them to a server replica when it can be reached, and merges what
+// changed on the server in return:
+//
+//	outbox, err := replicator.NewOutbox("phone", "server", doc, transport,
+//		replicator.OutboxConfig{Storage: store, Key: "doc/outbox"})
+//	go outbox.Run(ctx)
+//	doc.Insert('x', parent) // queued, sent when online
+//
+// The server is a Replica, which applies KindOps and answers with a
+// KindOpsAck carrying the changes made since the version the client last
+// got, a delta for Resumable states. Acknowledged ops are dropped from the
+// queue, and from the storage when it is a storage.Truncater; ops are
+// idempotent, so ops sent again after a lost acknowledgement do no harm.
+//
+// NewOutbox applies the ops still queued to the state, so local changes
+// made offline survive a restart; persist the state itself, with a
+// storage.WAL under another key, to keep the rest of it. The Outbox takes
+// over the state's OnOp.
+type Outbox struct {
+	id        string
+	server    string
+	state     OperableState
+	transport Transport
+	config    OutboxConfig
+
+	mu      sync.Mutex
+	pending []queuedOp
+	seq     uint64 // Sequence number of the last queued op
+	meta    outboxMeta
+}
+
+// NewOutbox creates the outbox of the client id, sending the ops of state
+// to the replica server over transport. It restores and applies the ops
+// the storage still holds.
+func NewOutbox(id, server string, state OperableState, transport Transport, config OutboxConfig) (*Outbox, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+	o := &Outbox{id: id, server: server, state: state, transport: transport, config: config}
+	if err := o.restore(); err != nil {
+		return nil, err
+	}
+	gocrdt.SendOps(state, o.queue, func(op gocrdt.Op, err error) { o.reportError(err) })
+	return o, nil
+}
+
+// restore loads the progress and the queued ops from the storage and
+// applies the ops to the state.
+func (o *Outbox) restore() error {
+	s := o.config.Storage
+	if s == nil {
+		return nil
+	}
+	data, _, err := s.LoadSnapshot(o.config.Key)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &o.meta); err != nil {
+			return fmt.Errorf("replicator: outbox progress of %q: %w", o.config.Key, err)
+		}
+	}
+	o.seq = o.meta.Acked
+	ops, err := s.ReadOpsSince(o.config.Key, o.meta.Acked)
+	if err != nil {
+		return err
+	}
+	for _, stored := range ops {
+		var op gocrdt.Op
+		if err := json.Unmarshal(stored.Data, &op); err != nil {
+			return fmt.Errorf("replicator: outbox op %d of %q: %w", stored.Seq, o.config.Key, err)
+		}
+		if _, err := o.state.ApplyOp(op); err != nil {
+			return fmt.Errorf("replicator: outbox op %d of %q: %w", stored.Seq, o.config.Key, err)
+		}
+		o.pending = append(o.pending, queuedOp{stored.Seq, op})
+		o.seq = stored.Seq
+	}
+	return nil
+}
+
+// queue appends op to the outbox, and to the storage if any. An op the
+// storage fails to take is still queued in memory: only a restart before
+// it is sent loses it.
+func (o *Outbox) queue(op gocrdt.Op) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	seq := o.seq + 1
+	var err error
+	if s := o.config.Storage; s != nil {
+		var data []byte
+		if data, err = json.Marshal(op); err == nil {
+			var stored uint64
+			if stored, err = s.AppendOps(o.config.Key, data); err == nil {
+				seq = stored
+			}
+		}
+		if err != nil {
+			o.config.Logger.Warn("replicator: outbox op not persisted", "key", o.config.Key, "err", err)
+			err = fmt.Errorf("replicator: persist outbox op: %w", err)
+		}
+	}
+	o.pending = append(o.pending, queuedOp{seq, op})
+	o.seq = seq
+	return err
+}
+
+// Pending returns the number of ops not acknowledged by the server yet.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Version returns the version of the server's state the client has
+// merged, empty before the first acknowledgement or for states that are
+// not Resumable on the server.
+func (o *Outbox) Version() gocrdt.Version {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.meta.Version
+}
+
+// Flush sends the queued ops to the server, asking for what changed there
+// since the last acknowledgement, also when nothing is queued. The ops
+// stay queued until Handle gets the acknowledgement; a failed send, such
+// as while offline, leaves them for the next Flush.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	payload := opsPayload{V: gocrdt.WireVersion, Seq: o.seq, Since: o.meta.Version, Ops: make([]gocrdt.Op, 0, len(o.pending))}
+	for _, q := range o.pending {
+		payload.Ops = append(payload.Ops, q.op)
+	}
+	o.mu.Unlock()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := o.transport.Send(ctx, Message{From: o.id, To: o.server, Kind: KindOps, Payload: data}); err != nil {
+		return fmt.Errorf("replicator: flush outbox: %w", err)
+	}
+	return nil
+}
+
+// Handle applies a message from the server: a KindOpsAck drops the
+// acknowledged ops and merges the server's changes, and a KindState is
+// merged. Other kinds are rejected.
+func (o *Outbox) Handle(msg Message) (gocrdt.MergeResult, error) {
+	switch msg.Kind {
+	case KindState:
+		return o.state.MergeState(msg.Payload)
+	case KindOpsAck:
+	default:
+		return gocrdt.MergeResult{}, fmt.Errorf("replicator: unknown message kind %q from %s", msg.Kind, msg.From)
+	}
+
+	var ack opsAckPayload
+	if err := json.Unmarshal(msg.Payload, &ack); err != nil {
+		return gocrdt.MergeResult{}, fmt.Errorf("replicator: ops ack from %s: %w", msg.From, err)
+	}
+	if ack.V > gocrdt.WireVersion {
+		return gocrdt.MergeResult{}, fmt.Errorf("%w %d", gocrdt.ErrUnsupportedVersion, ack.V)
+	}
+	var result gocrdt.MergeResult
+	if ack.State != nil {
+		var err error
+		if result, err = o.state.MergeState(ack.State); err != nil {
+			return result, err
+		}
+	}
+	return result, o.acknowledge(ack)
+}
+
+// acknowledge drops the ops up to ack.Seq and records the progress.
+func (o *Outbox) acknowledge(ack opsAckPayload) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ack.Seq < o.meta.Acked {
+		return nil // a late acknowledgement
+	}
+	kept := o.pending[:0]
+	for _, q := range o.pending {
+		if q.seq > ack.Seq {
+			kept = append(kept, q)
+		}
+	}
+	o.config.Logger.Debug("replicator: outbox acknowledged", "seq", ack.Seq, "dropped", len(o.pending)-len(kept))
+	o.pending = kept
+	o.meta = outboxMeta{Acked: ack.Seq, Version: ack.Version}
+
+	s := o.config.Storage
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(o.meta)
+	if err != nil {
+		return err
+	}
+	if err := s.SaveSnapshot(o.config.Key, ack.Seq, data); err != nil {
+		return err
+	}
+	if t, ok := s.(storage.Truncater); ok {
+		return t.TruncateOps(o.config.Key, ack.Seq)
+	}
+	return nil
+}
+
+// Run receives and handles the server's messages and flushes the outbox
+// every Config.Interval while ops are queued, resuming by itself once the
+// server is reachable again, until ctx is done. A closed transport stops
+// Run with ErrClosed.
+func (o *Outbox) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	received := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := o.transport.Receive(ctx)
+			if err != nil {
+				received <- err
+				return
+			}
+			if _, err := o.Handle(msg); err != nil {
+				o.reportError(err)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(o.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			<-received
+			return ctx.Err()
+		case err := <-received:
+			return err
+		case <-ticker.C:
+			if o.Pending() == 0 {
+				continue
+			}
+			if err := o.Flush(ctx); err != nil && ctx.Err() == nil {
+				o.reportError(err)
+			}
+		}
+	}
+}
+
+// reportError forwards background errors to the configured handler.
+func (o *Outbox) reportError(err error) {
+	if o.config.OnError != nil {
+		o.config.OnError(err)
+	}
+}
+
+// handleOps applies the ops of an Outbox and acknowledges them with what
+// changed since the version the client has.
+func (r *Replica) handleOps(ctx context.Context, msg Message) (gocrdt.MergeResult, error) {
+	operable, ok := r.state.(gocrdt.Operable)
+	if _, aware := r.state.(PeerAware); !ok || aware {
+		// Ops would bypass the write checks of PeerAware states.
+		return gocrdt.MergeResult{}, fmt.Errorf("replicator: %s sent ops but the local state does not take them", msg.From)
+	}
+	var payload opsPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return gocrdt.MergeResult{}, fmt.Errorf("replicator: ops from %s: %w", msg.From, err)
+	}
+	if payload.V > gocrdt.WireVersion {
+		return gocrdt.MergeResult{}, fmt.Errorf("%w %d", gocrdt.ErrUnsupportedVersion, payload.V)
+	}
+
+	var result gocrdt.MergeResult
+	for _, op := range payload.Ops {
+		merged, err := operable.ApplyOp(op)
+		result.Add(merged)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	ack := opsAckPayload{V: gocrdt.WireVersion, Seq: payload.Seq}
+	var err error
+	if resumable, ok := r.state.(Resumable); ok {
+		ack.State, ack.Version, err = resumable.MarshalSince(payload.Since)
+	} else {
+		ack.State, err = r.state.MarshalState()
+	}
+	if err != nil {
+		return result, fmt.Errorf("replicator: marshal state: %w", err)
+	}
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return result, err
+	}
+	return result, r.send(ctx, Message{From: r.id, To: msg.From, Kind: KindOpsAck, Payload: data})
+}
@@ -0,0 +1,90 @@
+package replicator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// receiveAck hands the next message for the outbox to it.
+func receiveAck(t *testing.T, hub *testHub, o *Outbox) gocrdt.MergeResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, err := hub.transport(o.id).Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := o.Handle(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestOutbox(t *testing.T) {
+	hub := newTestHub()
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+	config := OutboxConfig{Storage: store, Key: "doc/outbox"}
+
+	serverDoc := gocrdt.NewRGA("server")
+	server := NewReplica("server", serverDoc, hub.transport("server"), Config{})
+	serverDoc.Insert('x', gocrdt.ID{NodeID: "root"})
+
+	// Edits made offline survive a restart of the client.
+	doc := gocrdt.NewRGA("phone")
+	outbox, err := NewOutbox("phone", "server", doc, hub.transport("phone"), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	doc.Insert('b', a)
+	if outbox.Pending() != 2 {
+		t.Fatalf("Expected two queued ops, got %d", outbox.Pending())
+	}
+	doc = gocrdt.NewRGA("phone")
+	if outbox, err = NewOutbox("phone", "server", doc, hub.transport("phone"), config); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Value() != "ab" || outbox.Pending() != 2 {
+		t.Fatalf("Expected the queued ops restored, got %q and %d ops", doc.Value(), outbox.Pending())
+	}
+
+	// Back online, the ops reach the server and its changes the client.
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	hub.pump(t, server)
+	receiveAck(t, hub, outbox)
+	if doc.Value() != serverDoc.Value() || len(serverDoc.Value().(string)) != 3 {
+		t.Errorf("Expected both sides to converge, got %q and %q", doc.Value(), serverDoc.Value())
+	}
+	if outbox.Pending() != 0 || outbox.Version() == "" {
+		t.Errorf("Expected the ops acknowledged with a version, got %d ops", outbox.Pending())
+	}
+	if ops, err := store.ReadOpsSince("doc/outbox", 0); err != nil || len(ops) != 0 {
+		t.Errorf("Expected the acknowledged ops truncated, got %d, %v", len(ops), err)
+	}
+
+	// Later answers carry deltas since the version: the new node, and the
+	// deletion the server got from the client.
+	doc.Delete(a)
+	serverDoc.Insert('y', a)
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	hub.pump(t, server)
+	if result := receiveAck(t, hub, outbox); result.Applied != 1 || result.Duplicates != 1 {
+		t.Errorf("Expected a delta of the new node alone, got %+v", result)
+	}
+	if doc.Value() != serverDoc.Value() {
+		t.Errorf("Expected both sides to converge, got %q and %q", doc.Value(), serverDoc.Value())
+	}
+}
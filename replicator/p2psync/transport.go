@@ -0,0 +1,298 @@
+// Package p2psync carries replicator traffic over a peer-to-peer network
+// such as libp2p: one pubsub topic per document for replication messages,
+// and direct streams for full-state snapshots.
+//
+// The package does not import libp2p. It uses the small Topic and Host
+// interfaces, which adapt from a *pubsub.Topic / *pubsub.Subscription pair
+// and a host.Host. Discovery services (mDNS, DHT rendezvous, ...) report
+// peers through HandlePeerFound, and the transport also learns peers from
+// the traffic on the topic; Config.OnPeerFound is called once for each, so
+// the application can register them with its Replica:
+//
+//	t := p2psync.NewTransport(host, topic, doc, p2psync.Config{
+//		OnPeerFound: func(peer string) { replica.AddPeer(peer) },
+//	})
+//	go t.Run(ctx)
+//	defer t.Close()
+package p2psync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// DefaultInboxSize is the number of messages buffered for Receive.
+const DefaultInboxSize = 256
+
+// MaxSnapshotSize bounds a snapshot read from a stream.
+const MaxSnapshotSize = 64 << 20
+
+// ErrSnapshotTooLarge is returned by Snapshot when the peer sends more than
+// MaxSnapshotSize bytes.
+var ErrSnapshotTooLarge = errors.New("p2psync: snapshot exceeds MaxSnapshotSize")
+
+// Topic is a joined pubsub topic together with its subscription.
+type Topic interface {
+	// Publish broadcasts data to every subscriber of the topic.
+	Publish(ctx context.Context, data []byte) error
+
+	// Next blocks until the next message arrives and returns it with the
+	// ID of the peer that published it. Own messages may be returned too.
+	Next(ctx context.Context) (from string, data []byte, err error)
+}
+
+// Host is the local peer of the network.
+type Host interface {
+	// ID returns the local peer ID.
+	ID() string
+
+	// NewStream opens a snapshot stream to peer.
+	NewStream(ctx context.Context, peer string) (io.ReadWriteCloser, error)
+
+	// SetStreamHandler registers the handler for incoming snapshot
+	// streams, replacing any previous one.
+	SetStreamHandler(handler func(peer string, stream io.ReadWriteCloser))
+}
+
+// Config tunes a Transport. The zero value uses the defaults.
+type Config struct {
+	// InboxSize bounds the messages buffered for Receive. Messages arriving
+	// while the inbox is full are dropped, as pubsub does for slow readers.
+	InboxSize int
+
+	// OnPeerFound, if set, is called once for every peer first seen through
+	// discovery or on the topic.
+	OnPeerFound func(peer string)
+}
+
+// envelope is the wire form of a replicator.Message. The sender is not
+// part of it: it is taken from the pubsub metadata.
+type envelope struct {
+	To      string          `json:"to"`
+	Kind    replicator.Kind `json:"kind"`
+	Payload []byte          `json:"payload,omitempty"`
+}
+
+// Transport is a replicator.Transport over a pubsub topic and snapshot
+// streams.
+type Transport struct {
+	host   Host
+	topic  Topic
+	state  gocrdt.Replicable
+	config Config
+	inbox  chan replicator.Message
+
+	mu      sync.Mutex
+	peers   map[string]bool
+	streams map[int]io.Closer // snapshot streams being served
+	nextID  int
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTransport creates a transport for the local host, replicating state
+// over topic, and starts serving snapshot streams. Run must be called to
+// receive topic messages.
+func NewTransport(host Host, topic Topic, state gocrdt.Replicable, config Config) *Transport {
+	if config.InboxSize <= 0 {
+		config.InboxSize = DefaultInboxSize
+	}
+	t := &Transport{
+		host:    host,
+		topic:   topic,
+		state:   state,
+		config:  config,
+		inbox:   make(chan replicator.Message, config.InboxSize),
+		peers:   make(map[string]bool),
+		streams: make(map[int]io.Closer),
+		closed:  make(chan struct{}),
+	}
+	host.SetStreamHandler(t.serveSnapshot)
+	return t
+}
+
+// Send publishes msg on the topic. Every subscriber receives it, but only
+// msg.To delivers it to its replica.
+func (t *Transport) Send(ctx context.Context, msg replicator.Message) error {
+	select {
+	case <-t.closed:
+		return replicator.ErrClosed
+	default:
+	}
+	data, err := json.Marshal(envelope{To: msg.To, Kind: msg.Kind, Payload: msg.Payload})
+	if err != nil {
+		return err
+	}
+	return t.topic.Publish(ctx, data)
+}
+
+// Receive returns the next message addressed to the local peer. It only
+// returns messages while Run is running, and ErrClosed after Close.
+func (t *Transport) Receive(ctx context.Context) (replicator.Message, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return replicator.Message{}, ctx.Err()
+	case <-t.closed:
+		return replicator.Message{}, replicator.ErrClosed
+	}
+}
+
+// Run reads the topic until ctx is cancelled, the transport is closed or
+// the subscription fails, queuing the messages addressed to the local peer
+// for Receive and reporting unknown senders as found peers. After Close it
+// returns ErrClosed.
+func (t *Transport) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	self := t.host.ID()
+	for {
+		from, data, err := t.topic.Next(ctx)
+		if err != nil {
+			select {
+			case <-t.closed:
+				return replicator.ErrClosed
+			default:
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if from == self {
+			continue
+		}
+		t.HandlePeerFound(from)
+
+		var env envelope
+		if err := json.Unmarshal(data, &env); err != nil || env.To != self {
+			continue
+		}
+		msg := replicator.Message{From: from, To: env.To, Kind: env.Kind, Payload: env.Payload}
+		select {
+		case t.inbox <- msg:
+		default:
+		}
+	}
+}
+
+// HandlePeerFound records peer as known and calls Config.OnPeerFound the
+// first time it is seen. Discovery services call it for every peer they
+// find; repeated and self reports are ignored.
+func (t *Transport) HandlePeerFound(peer string) {
+	if peer == "" || peer == t.host.ID() {
+		return
+	}
+	t.mu.Lock()
+	known := t.peers[peer]
+	t.peers[peer] = true
+	t.mu.Unlock()
+
+	if !known && t.config.OnPeerFound != nil {
+		t.config.OnPeerFound(peer)
+	}
+}
+
+// Peers returns the known peers, sorted.
+func (t *Transport) Peers() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	peers := make([]string, 0, len(t.peers))
+	for peer := range t.peers {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// serveSnapshot writes the full local state to a snapshot stream. Close
+// ends the streams still being served.
+func (t *Transport) serveSnapshot(peer string, stream io.ReadWriteCloser) {
+	defer stream.Close()
+	t.mu.Lock()
+	select {
+	case <-t.closed:
+		t.mu.Unlock()
+		return
+	default:
+	}
+	id := t.nextID
+	t.nextID++
+	t.streams[id] = stream
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.streams, id)
+		t.mu.Unlock()
+	}()
+
+	t.HandlePeerFound(peer)
+	data, err := t.state.MarshalState()
+	if err != nil {
+		return // the requester sees an empty stream and fails
+	}
+	_, _ = stream.Write(data)
+}
+
+// Snapshot opens a stream to peer, reads its full state and merges it into
+// the local CRDT, bootstrapping a fresh replica in one call.
+func (t *Transport) Snapshot(ctx context.Context, peer string) (gocrdt.MergeResult, error) {
+	select {
+	case <-t.closed:
+		return gocrdt.MergeResult{}, replicator.ErrClosed
+	default:
+	}
+	stream, err := t.host.NewStream(ctx, peer)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.Close() })
+	defer stop()
+
+	data, err := io.ReadAll(io.LimitReader(stream, MaxSnapshotSize+1))
+	if ctx.Err() != nil {
+		return gocrdt.MergeResult{}, ctx.Err()
+	}
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	if len(data) > MaxSnapshotSize {
+		return gocrdt.MergeResult{}, ErrSnapshotTooLarge
+	}
+	return t.state.MergeState(data)
+}
+
+// Close stops Run, refuses new snapshot streams and ends those being
+// served. Pending Receive calls return ErrClosed. The host and topic belong
+// to the caller and are left open.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() {
+		t.mu.Lock()
+		close(t.closed)
+		for id, stream := range t.streams {
+			stream.Close()
+			delete(t.streams, id)
+		}
+		t.mu.Unlock()
+		t.host.SetStreamHandler(func(_ string, stream io.ReadWriteCloser) { stream.Close() })
+	})
+	return nil
+}
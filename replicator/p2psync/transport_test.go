@@ -0,0 +1,289 @@
+package p2psync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// testNetwork is an in-process pubsub topic plus stream dialer.
+type testNetwork struct {
+	mu       sync.Mutex
+	subs     map[string]chan topicMessage
+	handlers map[string]func(string, io.ReadWriteCloser)
+}
+
+type topicMessage struct {
+	from string
+	data []byte
+}
+
+func newTestNetwork() *testNetwork {
+	return &testNetwork{
+		subs:     make(map[string]chan topicMessage),
+		handlers: make(map[string]func(string, io.ReadWriteCloser)),
+	}
+}
+
+// join returns the host and topic of the peer id.
+func (n *testNetwork) join(id string) (Host, Topic) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subs[id] = make(chan topicMessage, 64)
+	return &testHost{net: n, id: id}, &testTopic{net: n, id: id}
+}
+
+type testTopic struct {
+	net *testNetwork
+	id  string
+}
+
+func (t *testTopic) Publish(_ context.Context, data []byte) error {
+	t.net.mu.Lock()
+	defer t.net.mu.Unlock()
+	for _, sub := range t.net.subs {
+		select {
+		case sub <- topicMessage{from: t.id, data: data}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (t *testTopic) Next(ctx context.Context) (string, []byte, error) {
+	t.net.mu.Lock()
+	sub := t.net.subs[t.id]
+	t.net.mu.Unlock()
+	select {
+	case msg := <-sub:
+		return msg.from, msg.data, nil
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+type testHost struct {
+	net *testNetwork
+	id  string
+}
+
+func (h *testHost) ID() string { return h.id }
+
+func (h *testHost) NewStream(_ context.Context, peer string) (io.ReadWriteCloser, error) {
+	h.net.mu.Lock()
+	handler, ok := h.net.handlers[peer]
+	h.net.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no route to %s", peer)
+	}
+	local, remote := net.Pipe()
+	go handler(h.id, remote)
+	return local, nil
+}
+
+func (h *testHost) SetStreamHandler(handler func(string, io.ReadWriteCloser)) {
+	h.net.mu.Lock()
+	defer h.net.mu.Unlock()
+	h.net.handlers[h.id] = handler
+}
+
+func TestTransport_TopicRouting(t *testing.T) {
+	network := newTestNetwork()
+	hostA, topicA := network.join("a")
+	hostB, topicB := network.join("b")
+	hostC, topicC := network.join("c")
+
+	var found []string
+	a := NewTransport(hostA, topicA, gocrdt.NewGCounter("a"), Config{})
+	b := NewTransport(hostB, topicB, gocrdt.NewGCounter("b"), Config{
+		OnPeerFound: func(peer string) { found = append(found, peer) },
+	})
+	c := NewTransport(hostC, topicC, gocrdt.NewGCounter("c"), Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, transport := range []*Transport{a, b, c} {
+		go func() { _ = transport.Run(ctx) }()
+	}
+
+	if err := a.Send(ctx, replicator.Message{To: "b", Kind: replicator.KindState, Payload: []byte("x")}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	msg, err := b.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if msg.From != "a" || msg.To != "b" || string(msg.Payload) != "x" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+
+	// c saw the broadcast but must not deliver a message addressed to b.
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if msg, err := c.Receive(short); err == nil {
+		t.Errorf("c received a message for b: %+v", msg)
+	}
+	if peers := c.Peers(); len(peers) != 1 || peers[0] != "a" {
+		t.Errorf("Expected c to learn about a from the topic, got %v", peers)
+	}
+
+	b.HandlePeerFound("a")     // already known from the topic
+	b.HandlePeerFound("b")     // self
+	b.HandlePeerFound("mdns1") // discovery
+	if len(found) != 2 || found[0] != "a" || found[1] != "mdns1" {
+		t.Errorf("Expected OnPeerFound for a and mdns1 once each, got %v", found)
+	}
+}
+
+func TestTransport_Snapshot(t *testing.T) {
+	network := newTestNetwork()
+	hostA, topicA := network.join("a")
+	hostB, topicB := network.join("b")
+
+	docA := gocrdt.NewRGA("a")
+	id := docA.Insert('H', gocrdt.ID{NodeID: "root"})
+	docA.Insert('i', id)
+	NewTransport(hostA, topicA, docA, Config{})
+	docB := gocrdt.NewRGA("b")
+	b := NewTransport(hostB, topicB, docB, Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := b.Snapshot(ctx, "a")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if docB.Value() != "Hi" || result.Applied != 2 {
+		t.Errorf("Expected Hi with 2 applied nodes, got %s (%+v)", docB.Value(), result)
+	}
+
+	if _, err := b.Snapshot(ctx, "nobody"); err == nil {
+		t.Error("Expected an error for an unreachable peer")
+	}
+}
+
+func TestTransport_Close(t *testing.T) {
+	network := newTestNetwork()
+	hostA, topicA := network.join("a")
+	hostB, _ := network.join("b")
+	a := NewTransport(hostA, topicA, gocrdt.NewGCounter("a"), Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	run := make(chan error, 1)
+	go func() { run <- a.Run(ctx) }()
+	received := make(chan error, 1)
+	go func() {
+		_, err := a.Receive(ctx)
+		received <- err
+	}()
+
+	// A snapshot stream nobody reads blocks its handler until Close.
+	stream, err := hostB.NewStream(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := <-received; !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected Receive to return ErrClosed, got %v", err)
+	}
+	if err := <-run; !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected Run to return ErrClosed, got %v", err)
+	}
+	if err := a.Send(ctx, replicator.Message{To: "b"}); !errors.Is(err, replicator.ErrClosed) {
+		t.Errorf("Expected Send to return ErrClosed, got %v", err)
+	}
+	if data, _ := io.ReadAll(stream); len(data) != 0 {
+		t.Errorf("Expected the served stream ended, read %d bytes", len(data))
+	}
+	late, err := hostB.NewStream(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(late); len(data) != 0 {
+		t.Errorf("Expected new streams refused, read %d bytes", len(data))
+	}
+}
+
+func TestTransport_ReplicasConverge(t *testing.T) {
+	network := newTestNetwork()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config := replicator.Config{Interval: 10 * time.Millisecond}
+	docs := make(map[string]*gocrdt.RGA)
+	var replicas []*replicator.Replica
+	for _, id := range []string{"a", "b", "c"} {
+		docs[id] = gocrdt.NewRGA(id)
+		docs[id].Insert(rune(id[0]), gocrdt.ID{NodeID: "root"})
+		host, topic := network.join(id)
+
+		var replica *replicator.Replica
+		transport := NewTransport(host, topic, docs[id], Config{
+			OnPeerFound: func(peer string) { replica.AddPeer(peer) },
+		})
+		replica = replicator.NewReplica(id, docs[id], transport, config)
+		replicas = append(replicas, replica)
+		go func() { _ = transport.Run(ctx) }()
+	}
+
+	// Only a is discovered up front; the others learn peers from traffic.
+	for _, r := range replicas[1:] {
+		r.AddPeer("a")
+	}
+	errs := make(chan error, len(replicas))
+	for _, r := range replicas {
+		go func() { errs <- r.Run(ctx) }()
+	}
+
+	converged := func() bool {
+		v := docs["a"].Value()
+		return len(v.(string)) == 3 && docs["b"].Value() == v && docs["c"].Value() == v
+	}
+	for ctx.Err() == nil && !converged() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !converged() {
+		t.Errorf("Replicas did not converge: %s %s %s", docs["a"].Value(), docs["b"].Value(), docs["c"].Value())
+	}
+
+	cancel()
+	for range replicas {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestTransport_IgnoresMalformedMessages(t *testing.T) {
+	network := newTestNetwork()
+	hostA, topicA := network.join("a")
+	_, topicB := network.join("b")
+	a := NewTransport(hostA, topicA, gocrdt.NewGCounter("a"), Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = a.Run(ctx) }()
+
+	_ = topicB.Publish(ctx, []byte("not json"))
+	valid, _ := json.Marshal(envelope{To: "a", Kind: replicator.KindState})
+	_ = topicB.Publish(ctx, valid)
+
+	msg, err := a.Receive(ctx)
+	if err != nil || msg.From != "b" || msg.Kind != replicator.KindState {
+		t.Errorf("Expected the valid message after the malformed one, got %+v (%v)", msg, err)
+	}
+}
@@ -0,0 +1,89 @@
+package replicator
+
+// PeerProgress is how far the local replica and one peer are from each
+// other, according to the version vectors they exchanged.
+type PeerProgress struct {
+	ID     string
+	Status PeerStatus
+
+	// Known is false until the peer acknowledged a version of the current
+	// local log (see Replica.RecordAck); Behind is then unknown.
+	Known bool
+
+	// Behind is the number of changes of the local log the peer has not
+	// acknowledged.
+	Behind uint64
+
+	// Ahead is the number of changes the peer acknowledged having, from the
+	// log of any other replica, that the local replica has not received.
+	Ahead uint64
+}
+
+// Progress is the convergence of the local replica with its peers, see
+// Stability.Progress.
+type Progress struct {
+	// Peers is the progress of every member, sorted by ID.
+	Peers []PeerProgress
+
+	// Quiescent reports whether the local replica and every alive member
+	// have exchanged all the changes they know of. Stale members do not
+	// count, so one unreachable peer does not fail a readiness probe.
+	Quiescent bool
+}
+
+// Lag returns the largest Behind plus Ahead of an alive member: an
+// estimate of the changes still to exchange before quiescence.
+func (p Progress) Lag() uint64 {
+	var lag uint64
+	for _, peer := range p.Peers {
+		if peer.Status == PeerAlive {
+			lag = max(lag, peer.Behind+peer.Ahead)
+		}
+	}
+	return lag
+}
+
+// Progress compares what the local replica has, and has received, with
+// what every member acknowledged, for health checks and readiness probes.
+// A member's own latest changes count once it sent them, since only what
+// was exchanged is known; keep sessions streaming for Progress to follow
+// the peers. States without deltas have no change log to compare, so
+// their members are never Known and the replica is quiescent only
+// without alive members.
+func (s *Stability) Progress() Progress {
+	var head uint64
+	if delta, ok := s.log.state.(DeltaState); ok && s.log.epoch != "" {
+		if _, cursor, err := delta.MarshalChanges(^uint64(0)); err == nil {
+			head = cursor
+		}
+	}
+	received := s.log.Vector()
+
+	progress := Progress{Quiescent: true}
+	for _, peer := range s.replica.Peers() {
+		p := PeerProgress{ID: peer.ID, Status: peer.Status}
+		if acked, ok := peer.Acked[s.log.id]; ok && s.log.epoch != "" && acked.Epoch == s.log.epoch {
+			p.Known = true
+			p.Behind = head - min(acked.Cursor, head)
+		}
+		for origin, acked := range peer.Acked {
+			if origin == s.log.id {
+				continue
+			}
+			have, ok := received[origin]
+			switch {
+			case !ok || have.Epoch != acked.Epoch:
+				// Another epoch may be older or newer; count it until
+				// both sides meet on one.
+				p.Ahead += acked.Cursor
+			case have.Cursor < acked.Cursor:
+				p.Ahead += acked.Cursor - have.Cursor
+			}
+		}
+		if peer.Status == PeerAlive && (!p.Known || p.Behind > 0 || p.Ahead > 0) {
+			progress.Quiescent = false
+		}
+		progress.Peers = append(progress.Peers, p)
+	}
+	return progress
+}
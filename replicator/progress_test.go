@@ -0,0 +1,71 @@
+package replicator
+
+import (
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStability_Progress(t *testing.T) {
+	root := gocrdt.ID{NodeID: "root"}
+	docs := map[string]*gocrdt.RGA{"a": gocrdt.NewRGA("a"), "b": gocrdt.NewRGA("b"), "c": gocrdt.NewRGA("c")}
+	logs := map[string]*SessionLog{}
+	for id, doc := range docs {
+		logs[id] = NewSessionLog(id, doc)
+	}
+	replica := NewReplica("a", docs["a"], NewNetwork(NetworkConfig{}).Transport("a"), Config{})
+	replica.AddPeer("b")
+	replica.AddPeer("c")
+	stability := NewStability(replica, logs["a"])
+
+	if p := stability.Progress(); p.Quiescent || p.Peers[0].Known {
+		t.Fatalf("Expected unknown peers before any ack, got %+v", p)
+	}
+
+	// a syncs with b, then b with c, which wrote something a lacks.
+	docs["a"].Insert('a', root)
+	docs["c"].Insert('c', root)
+	ab := newSessionPair(t, logs["a"], logs["b"])
+	ab.handshake(nil)
+	bc := newSessionPair(t, logs["b"], logs["c"])
+	bc.handshake(nil)
+	replica.RecordAck("b", ab.a.PeerVector())
+	replica.RecordAck("c", logs["c"].Vector())
+
+	p := stability.Progress()
+	if p.Quiescent {
+		t.Fatalf("Expected no quiescence, got %+v", p)
+	}
+	if b := p.Peers[0]; !b.Known || b.Behind != 0 || b.Ahead != 0 {
+		t.Errorf("Expected b caught up with a, got %+v", b)
+	}
+	if c := p.Peers[1]; c.Known || c.Ahead == 0 {
+		t.Errorf("Expected c ahead through b's log and unknown to a's, got %+v", c)
+	}
+
+	// Once the sessions exchanged everything, a is quiescent.
+	ac := newSessionPair(t, logs["a"], logs["c"])
+	ac.handshake(nil)
+	ab.poll()
+	ac.poll()
+	replica.RecordAck("b", ab.a.PeerVector())
+	replica.RecordAck("c", ac.a.PeerVector())
+	if p := stability.Progress(); !p.Quiescent || p.Lag() != 0 {
+		t.Errorf("Expected quiescence once everything was exchanged, got %+v", p)
+	}
+
+	docs["a"].Insert('!', root)
+	if p := stability.Progress(); p.Quiescent || p.Lag() != 1 || p.Peers[0].Behind != 1 {
+		t.Errorf("Expected a lag of 1 after a local change, got %+v", p)
+	}
+
+	// A stale peer does not hold quiescence back.
+	ab.poll()
+	replica.RecordAck("b", ab.a.PeerVector())
+	replica.mu.Lock()
+	replica.peers["c"].stale = true
+	replica.mu.Unlock()
+	if p := stability.Progress(); !p.Quiescent || p.Peers[1].Behind != 1 {
+		t.Errorf("Expected quiescence with c stale, got %+v", p)
+	}
+}
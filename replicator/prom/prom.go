@@ -0,0 +1,258 @@
+// Package prom exports replicator metrics in the Prometheus text format.
+//
+// Metrics implements replicator.Metrics and http.Handler, so it is both
+// the replica's metrics sink and the scrape endpoint:
+//
+//	m := prom.NewMetrics()
+//	r := replicator.NewReplica("alice", doc, transport, replicator.Config{Metrics: m})
+//	http.Handle("/metrics", m)
+//
+// It does not depend on the Prometheus client library; to add the figures
+// to an existing registry, serve WriteTo's output from a collector.
+//
+// Exported series, all prefixed gocrdt_:
+//
+//	handled_total{peer,kind}        messages handled
+//	handle_errors_total{peer,kind}  messages that failed or were rejected
+//	handle_duration_seconds{kind}   histogram of handling latency
+//	nodes_applied_total{peer}       entries integrated (MergeResult.Applied)
+//	duplicates_total{peer}          entries already known
+//	orphans_buffered_total{peer}    nodes buffered for a missing parent
+//	tombstones_applied_total{peer}  deletions applied
+//	rejected_total{peer}            entries refused by a validator
+//	bytes_sent_total{peer}          payload bytes sent
+//	bytes_received_total{peer}      payload bytes received
+//	document_nodes, document_tombstones, document_orphans, tombstone_ratio
+//
+// A growing orphans_buffered_total or a peer whose bytes_received_total
+// stalls are the usual replication-lag alerts.
+package prom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histogram.
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// family is one metric name with its samples by label set.
+type family struct {
+	name, help, typ string
+	labels          []string
+	values          map[string]float64 // by rendered label set
+}
+
+func newFamily(name, typ, help string, labels ...string) *family {
+	return &family{name: "gocrdt_" + name, help: help, typ: typ, labels: labels, values: make(map[string]float64)}
+}
+
+// key renders label values as a Prometheus label set.
+func (f *family) key(values ...string) string {
+	if len(f.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(f.labels))
+	for i, label := range f.labels {
+		pairs[i] = label + `="` + escape(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// histogram is a latency histogram with one series per label set.
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Metrics collects replicator measurements. The zero value is not usable;
+// call NewMetrics.
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	families  []*family
+	handled   *family
+	errors    *family
+	applied   *family
+	dups      *family
+	orphans   *family
+	deleted   *family
+	rejected  *family
+	sent      *family
+	received  *family
+	docNodes  *family
+	docTombs  *family
+	docOrphan *family
+	ratio     *family
+	latency   map[string]*histogram // by kind
+}
+
+// NewMetrics creates an empty collector using DefaultBuckets.
+func NewMetrics() *Metrics {
+	m := &Metrics{buckets: DefaultBuckets, latency: make(map[string]*histogram)}
+	m.handled = m.add(newFamily("handled_total", "counter", "Messages handled.", "peer", "kind"))
+	m.errors = m.add(newFamily("handle_errors_total", "counter", "Messages that failed or were rejected.", "peer", "kind"))
+	m.applied = m.add(newFamily("nodes_applied_total", "counter", "Remote entries integrated.", "peer"))
+	m.dups = m.add(newFamily("duplicates_total", "counter", "Remote entries already known.", "peer"))
+	m.orphans = m.add(newFamily("orphans_buffered_total", "counter", "Remote nodes buffered for a missing parent.", "peer"))
+	m.deleted = m.add(newFamily("tombstones_applied_total", "counter", "Remote deletions applied.", "peer"))
+	m.rejected = m.add(newFamily("rejected_total", "counter", "Remote entries refused by a validator.", "peer"))
+	m.sent = m.add(newFamily("bytes_sent_total", "counter", "Payload bytes sent.", "peer"))
+	m.received = m.add(newFamily("bytes_received_total", "counter", "Payload bytes received.", "peer"))
+	m.docNodes = m.add(newFamily("document_nodes", "gauge", "Nodes of the local document, tombstones included."))
+	m.docTombs = m.add(newFamily("document_tombstones", "gauge", "Tombstones of the local document."))
+	m.docOrphan = m.add(newFamily("document_orphans", "gauge", "Nodes buffered for a missing parent."))
+	m.ratio = m.add(newFamily("tombstone_ratio", "gauge", "Share of the local document's nodes that are tombstones."))
+	return m
+}
+
+func (m *Metrics) add(f *family) *family {
+	m.families = append(m.families, f)
+	return f
+}
+
+// ObserveHandle implements replicator.Metrics.
+func (m *Metrics) ObserveHandle(peer string, kind replicator.Kind, result gocrdt.MergeResult, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handled.values[m.handled.key(peer, string(kind))]++
+	if err != nil {
+		m.errors.values[m.errors.key(peer, string(kind))]++
+	}
+	m.applied.values[m.applied.key(peer)] += float64(result.Applied)
+	m.dups.values[m.dups.key(peer)] += float64(result.Duplicates)
+	m.orphans.values[m.orphans.key(peer)] += float64(result.Orphaned)
+	m.deleted.values[m.deleted.key(peer)] += float64(result.Deleted)
+	m.rejected.values[m.rejected.key(peer)] += float64(result.Rejected)
+
+	h, ok := m.latency[string(kind)]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latency[string(kind)] = h
+	}
+	seconds := latency.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ObserveSent implements replicator.Metrics.
+func (m *Metrics) ObserveSent(peer string, bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent.values[m.sent.key(peer)] += float64(bytes)
+}
+
+// ObserveReceived implements replicator.Metrics.
+func (m *Metrics) ObserveReceived(peer string, bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received.values[m.received.key(peer)] += float64(bytes)
+}
+
+// ObserveDocument implements replicator.Metrics.
+func (m *Metrics) ObserveDocument(stats gocrdt.DocStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docNodes.values[""] = float64(stats.Nodes)
+	m.docTombs.values[""] = float64(stats.Tombstones)
+	m.docOrphan.values[""] = float64(stats.Orphans)
+	m.ratio.values[""] = stats.TombstoneRatio()
+}
+
+// WriteTo writes every series in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	for _, f := range m.families {
+		if len(f.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		keys := make([]string, 0, len(f.values))
+		for key := range f.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(cw, "%s%s %s\n", f.name, key, formatFloat(f.values[key]))
+		}
+	}
+
+	if len(m.latency) > 0 {
+		const name = "gocrdt_handle_duration_seconds"
+		fmt.Fprintf(cw, "# HELP %s Message handling latency.\n# TYPE %s histogram\n", name, name)
+		kinds := make([]string, 0, len(m.latency))
+		for kind := range m.latency {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			h, label := m.latency[kind], `kind="`+escape(kind)+`"`
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(cw, "%s_bucket{%s,le=\"%s\"} %d\n", name, label, formatFloat(bound), cumulative)
+			}
+			fmt.Fprintf(cw, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, label, h.count)
+			fmt.Fprintf(cw, "%s_sum{%s} %s\n%s_count{%s} %d\n", name, label, formatFloat(h.sum), name, label, h.count)
+		}
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts bytes and keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
@@ -0,0 +1,72 @@
+package prom
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+)
+
+func TestMetrics_ReplicaMeasurements(t *testing.T) {
+	net := replicator.NewNetwork(replicator.NetworkConfig{})
+	metrics := NewMetrics()
+	alice := gocrdt.NewRGA("alice")
+	a := alice.Insert('a', gocrdt.ID{NodeID: "root"})
+	alice.Insert('b', a)
+	alice.Delete(a)
+
+	sender := replicator.NewReplica("alice", alice, net.Transport("alice"), replicator.Config{Metrics: metrics})
+	sender.AddPeer("bob")
+	receiver := replicator.NewReplica("bob", gocrdt.NewRGA("bob"), net.Transport("bob"), replicator.Config{Metrics: metrics})
+
+	ctx := context.Background()
+	if err := sender.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := net.Transport("bob").Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receiver.Handle(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	receiver.Handle(ctx, replicator.Message{From: "mallory", Kind: "bogus"})
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE gocrdt_handled_total counter",
+		`gocrdt_handled_total{peer="alice",kind="state"} 1`,
+		`gocrdt_handle_errors_total{peer="mallory",kind="bogus"} 1`,
+		`gocrdt_nodes_applied_total{peer="alice"} 2`,
+		`gocrdt_bytes_sent_total{peer="bob"} ` + formatFloat(float64(len(msg.Payload))),
+		`gocrdt_bytes_received_total{peer="alice"} ` + formatFloat(float64(len(msg.Payload))),
+		"gocrdt_document_tombstones 1",
+		"gocrdt_tombstone_ratio 0.5",
+		`gocrdt_handle_duration_seconds_bucket{kind="state",le="+Inf"} 1`,
+		`gocrdt_handle_duration_seconds_count{kind="bogus"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Missing %q in:\n%s", want, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+}
+
+func TestMetrics_EscapesLabels(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveSent("a\"b\\c\nd", 3)
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `gocrdt_bytes_sent_total{peer="a\"b\\c\nd"} 3`) {
+		t.Errorf("Label not escaped:\n%s", b.String())
+	}
+}
@@ -0,0 +1,161 @@
+package replicator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// DefaultQuotaInterval is the accounting window used when
+// QuotaConfig.Interval is zero.
+const DefaultQuotaInterval = time.Second
+
+// ErrQuotaExceeded is matched (with errors.Is) by the errors Handle returns
+// for messages rejected by a Limiter. Rejected messages are not merged; the
+// data they carried comes back in a later round.
+var ErrQuotaExceeded = errors.New("replicator: peer quota exceeded")
+
+// QuotaError reports a message rejected by a Quota.
+type QuotaError struct {
+	Peer string
+
+	// RetryAfter is how long until the peer's quota is replenished. A
+	// caller that deferred the message can apply it then.
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s (retry after %v)", ErrQuotaExceeded, e.Peer, e.RetryAfter)
+}
+
+// Unwrap returns ErrQuotaExceeded.
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Limiter throttles what peers may push into a Replica. Handle calls Admit
+// before merging a message and Merged afterwards, so a limiter can account
+// for both the bytes received and the operations they applied.
+// Implementations must be safe for concurrent use.
+type Limiter interface {
+	// Admit returns a non-nil error to reject msg from peer.
+	Admit(peer string, msg Message) error
+
+	// Merged reports the result of merging an admitted message.
+	Merged(peer string, result gocrdt.MergeResult)
+}
+
+// QuotaLimits bounds what one peer may push in a window. Zero fields are
+// unlimited.
+type QuotaLimits struct {
+	// MaxBytes bounds the payload bytes received.
+	MaxBytes int
+
+	// MaxOps bounds the operations merged (applied or deleted nodes,
+	// updated counter slots). Operations are only known after a merge, so
+	// the message exceeding MaxOps is still merged; the following ones are
+	// rejected until the window ends.
+	MaxOps int
+}
+
+// QuotaConfig configures a Quota.
+type QuotaConfig struct {
+	// Interval is the length of the accounting window.
+	Interval time.Duration
+
+	// Default applies to peers without an entry in PerPeer.
+	Default QuotaLimits
+
+	// PerPeer overrides Default for individual peers.
+	PerPeer map[string]QuotaLimits
+}
+
+// Quota is a Limiter enforcing per-peer byte and operation budgets over
+// fixed windows.
+type Quota struct {
+	config QuotaConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+// quotaWindow is the usage of one peer in the current window.
+type quotaWindow struct {
+	start time.Time
+	bytes int
+	ops   int
+}
+
+// NewQuota creates a Quota; set it as Config.Limiter.
+func NewQuota(config QuotaConfig) *Quota {
+	if config.Interval <= 0 {
+		config.Interval = DefaultQuotaInterval
+	}
+	return &Quota{config: config, now: time.Now, windows: make(map[string]*quotaWindow)}
+}
+
+func (q *Quota) limits(peer string) QuotaLimits {
+	if limits, ok := q.config.PerPeer[peer]; ok {
+		return limits
+	}
+	return q.config.Default
+}
+
+// window returns the current window of peer, starting a new one when the
+// previous has ended. It must be called with q.mu held.
+func (q *Quota) window(peer string, now time.Time) *quotaWindow {
+	w, ok := q.windows[peer]
+	if !ok || now.Sub(w.start) >= q.config.Interval {
+		w = &quotaWindow{start: now}
+		q.windows[peer] = w
+	}
+	return w
+}
+
+// Admit rejects msg with a *QuotaError if it would take peer over its byte
+// budget or the peer already used up its operation budget.
+func (q *Quota) Admit(peer string, msg Message) error {
+	limits := q.limits(peer)
+	now := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w := q.window(peer, now)
+	overBytes := limits.MaxBytes > 0 && w.bytes+len(msg.Payload) > limits.MaxBytes
+	overOps := limits.MaxOps > 0 && w.ops >= limits.MaxOps
+	if overBytes || overOps {
+		return &QuotaError{Peer: peer, RetryAfter: w.start.Add(q.config.Interval).Sub(now)}
+	}
+	w.bytes += len(msg.Payload)
+	return nil
+}
+
+// Merged charges the operations of result to peer.
+func (q *Quota) Merged(peer string, result gocrdt.MergeResult) {
+	ops := result.Applied + result.Deleted
+	if ops == 0 {
+		return
+	}
+	now := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.window(peer, now).ops += ops
+}
+
+// Usage returns the bytes and operations peer pushed in the current window.
+func (q *Quota) Usage(peer string) (bytes, ops int) {
+	now := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w, ok := q.windows[peer]
+	if !ok || now.Sub(w.start) >= q.config.Interval {
+		return 0, 0
+	}
+	return w.bytes, w.ops
+}
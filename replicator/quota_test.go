@@ -0,0 +1,79 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestQuota_RejectsOverBudgetPeers(t *testing.T) {
+	root := gocrdt.ID{NodeID: "root"}
+	quota := NewQuota(QuotaConfig{
+		Interval: time.Minute,
+		Default:  QuotaLimits{MaxOps: 2},
+		PerPeer:  map[string]QuotaLimits{"trusted": {}},
+	})
+	now := time.Unix(0, 0)
+	quota.now = func() time.Time { return now }
+
+	doc := gocrdt.NewRGA("server")
+	r := NewReplica("server", doc, NewNetwork(NetworkConfig{}).Transport("server"), Config{Limiter: quota})
+
+	client := gocrdt.NewRGA("client")
+	push := func(from string) error {
+		payload, err := client.MarshalState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.Handle(context.Background(), Message{From: from, Kind: KindState, Payload: payload})
+		return err
+	}
+
+	last := client.Insert('a', root)
+	last = client.Insert('b', last)
+	last = client.Insert('c', last) // exceeds MaxOps, but ops are charged after the merge
+	if err := push("client"); err != nil {
+		t.Fatalf("First push rejected: %v", err)
+	}
+	if _, ops := quota.Usage("client"); ops != 3 {
+		t.Errorf("Expected 3 ops charged, got %d", ops)
+	}
+
+	client.Insert('d', last)
+	err := push("client")
+	var quotaErr *QuotaError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.RetryAfter != time.Minute {
+		t.Fatalf("Expected a quota error retrying after a minute, got %v", err)
+	}
+	if doc.Value() != "abc" {
+		t.Errorf("Rejected push was merged: %q", doc.Value())
+	}
+	if err := push("trusted"); err != nil {
+		t.Errorf("Unlimited peer rejected: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := push("client"); err != nil {
+		t.Errorf("Push rejected in a new window: %v", err)
+	}
+}
+
+func TestQuota_MaxBytes(t *testing.T) {
+	quota := NewQuota(QuotaConfig{Default: QuotaLimits{MaxBytes: 10}})
+	msg := Message{From: "a", Kind: KindState, Payload: make([]byte, 6)}
+	if err := quota.Admit("a", msg); err != nil {
+		t.Fatalf("First message rejected: %v", err)
+	}
+	if err := quota.Admit("a", msg); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the byte budget to be enforced, got %v", err)
+	}
+	if err := quota.Admit("b", msg); err != nil {
+		t.Errorf("Budgets must be per peer, got %v", err)
+	}
+	if bytes, _ := quota.Usage("a"); bytes != 6 {
+		t.Errorf("Rejected bytes must not be charged, got %d", bytes)
+	}
+}
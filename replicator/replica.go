@@ -0,0 +1,402 @@
+// Package replicator keeps CRDT replicas in sync over a pluggable Transport.
+//
+// A Replica wraps a single gocrdt.Replicable, tracks the peers it knows
+// about, and periodically ships its state to them while merging whatever
+// they send back. Because CRDT merges are commutative, associative and
+// idempotent, lost, duplicated or reordered messages only delay
+// convergence; they never corrupt it.
+//
+// Replica rounds exchange full states (KindState) or, with gossip, the
+// differing Merkle buckets. Connection-oriented deployments can use a
+// SyncSession instead, which tracks what each peer has seen in a version
+// vector and ships deltas.
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+const (
+	// DefaultInterval is the sync period used when Config.Interval is zero.
+	DefaultInterval = time.Second
+
+	// DefaultMaxRetries is the number of send retries used when
+	// Config.MaxRetries is zero.
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the initial delay between send retries used
+	// when Config.RetryBackoff is zero. It doubles after every attempt.
+	DefaultRetryBackoff = 100 * time.Millisecond
+)
+
+// Config tunes the behaviour of a Replica. Zero values select the defaults.
+type Config struct {
+	// Interval is the period between two state exchanges with all peers.
+	Interval time.Duration
+
+	// MaxRetries is how many times a failed send is retried before the
+	// peer is skipped for the current round.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry.
+	RetryBackoff time.Duration
+
+	// Gossip switches Run from broadcasting the full state to every peer
+	// to epidemic anti-entropy rounds with a few random peers. See Gossip.
+	Gossip *GossipConfig
+
+	// OnError, when set, receives errors raised by the background loops of
+	// Run (failed sends, undecodable payloads) which would otherwise be
+	// dropped.
+	OnError func(error)
+
+	// StaleAfter is how long a peer may stay silent before Run reports it
+	// as stale. It defaults to three intervals.
+	StaleAfter time.Duration
+
+	// OnPeerEvent, when set, is called when a peer joins, leaves, goes
+	// stale or recovers. It must not block.
+	OnPeerEvent func(MembershipEvent)
+
+	// RequestMissing makes Handle answer a merge that leaves updates
+	// waiting for their dependencies, such as RGA orphans, with a KindNeed
+	// for them to the sender. Every peer must understand KindNeed. See
+	// Replica.RequestMissing.
+	RequestMissing bool
+
+	// Limiter, when set, throttles what each peer may push: Handle rejects
+	// the messages it refuses, without merging them. See Quota.
+	Limiter Limiter
+
+	// Metrics, when set, receives measurements of merges and traffic.
+	Metrics Metrics
+
+	// Tracer, when set, creates spans around rounds and handled messages.
+	Tracer Tracer
+
+	// Logger receives merge figures (Debug), membership changes (Info),
+	// rejected messages and failed sends (Warn). It defaults to discarding
+	// everything.
+	Logger gocrdt.Logger
+}
+
+// withDefaults returns a copy of the config with zero values replaced.
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultRetryBackoff
+	}
+	if c.StaleAfter <= 0 {
+		c.StaleAfter = 3 * c.Interval
+	}
+	if c.Logger == nil {
+		c.Logger = slog.New(slog.DiscardHandler)
+	}
+	return c
+}
+
+// Replica binds a local CRDT to a Transport and a set of peers.
+type Replica struct {
+	id        string
+	state     gocrdt.Replicable
+	transport Transport
+	config    Config
+
+	mu    sync.RWMutex
+	peers map[string]*member
+
+	gossipMu sync.Mutex // guards the gossip RNG
+
+	closed atomic.Bool
+}
+
+// NewReplica creates a replica identified by id that replicates state over
+// transport. The id is used as the From address of every outgoing message.
+func NewReplica(id string, state gocrdt.Replicable, transport Transport, config Config) *Replica {
+	return &Replica{
+		id:        id,
+		state:     state,
+		transport: transport,
+		config:    config.withDefaults(),
+		peers:     make(map[string]*member),
+	}
+}
+
+// ID returns the address of the local replica.
+func (r *Replica) ID() string {
+	return r.id
+}
+
+// AddPeer registers a peer that will receive the local state on every sync.
+// Adding a new peer emits a PeerJoined event.
+func (r *Replica) AddPeer(id string) {
+	if id == r.id {
+		return
+	}
+	r.mu.Lock()
+	if _, ok := r.peers[id]; ok {
+		r.mu.Unlock()
+		return
+	}
+	m := &member{joined: time.Now()}
+	r.peers[id] = m
+	info := m.info(id)
+	r.mu.Unlock()
+
+	r.emit(MembershipEvent{Type: PeerJoined, Peer: info})
+}
+
+// RemovePeer stops replicating to the given peer and emits a PeerLeft
+// event if it was known.
+func (r *Replica) RemovePeer(id string) {
+	r.mu.Lock()
+	m, ok := r.peers[id]
+	delete(r.peers, id)
+	r.mu.Unlock()
+
+	if ok {
+		r.emit(MembershipEvent{Type: PeerLeft, Peer: m.info(id)})
+	}
+}
+
+// Sync performs one replication round: the current state is sent to every
+// known peer concurrently, retrying failed sends, so a slow or unreachable
+// peer does not hold up the others. Errors for individual peers are joined
+// and returned once every send has finished or ctx is done.
+func (r *Replica) Sync(ctx context.Context) (err error) {
+	peers := r.peerIDs()
+	ctx, span := startSpan(r.config.Tracer, ctx, "gocrdt.sync", Attribute{"peers", len(peers)})
+	defer func() { endSpan(span, err) }()
+
+	// States that are not PeerAware send every peer the same payload.
+	_, perPeer := r.state.(PeerAware)
+	var payload []byte
+	if !perPeer {
+		if payload, err = r.state.MarshalState(); err != nil {
+			return fmt.Errorf("replicator: marshal state: %w", err)
+		}
+		span.SetAttributes(Attribute{"bytes", len(payload)})
+	}
+
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := payload
+			if perPeer {
+				var err error
+				if payload, err = marshalFor(r.state, peer); err != nil {
+					errs[i] = fmt.Errorf("replicator: marshal state for %s: %w", peer, err)
+					return
+				}
+			}
+			msg := Message{From: r.id, To: peer, Kind: KindState, Payload: payload}
+			if err := r.send(ctx, msg); err != nil {
+				errs[i] = fmt.Errorf("replicator: send to %s: %w", peer, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Handle applies a message received from a peer and records the sender as
+// alive. Messages of unknown kinds are rejected so that newer peers cannot
+// silently be misinterpreted, and nothing is merged once ctx is done or the
+// replica closed. With Config.Limiter set, messages over the sender's quota
+// are rejected too.
+func (r *Replica) Handle(ctx context.Context, msg Message) (gocrdt.MergeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	if r.closed.Load() {
+		return gocrdt.MergeResult{}, ErrReplicaClosed
+	}
+	start := time.Now()
+	r.touch(msg.From, start)
+	ctx, span := startSpan(r.config.Tracer, ctx, "gocrdt.handle",
+		Attribute{"peer", msg.From}, Attribute{"kind", string(msg.Kind)}, Attribute{"bytes", len(msg.Payload)})
+
+	limiter := r.config.Limiter
+	if limiter != nil {
+		if err := limiter.Admit(msg.From, msg); err != nil {
+			r.config.Logger.Warn("replicator: message rejected", "peer", msg.From, "kind", msg.Kind, "err", err)
+			r.observeHandle(msg, gocrdt.MergeResult{}, start, err)
+			endSpan(span, err)
+			return gocrdt.MergeResult{}, err
+		}
+	}
+	result, err := r.handle(ctx, msg)
+	if limiter != nil {
+		limiter.Merged(msg.From, result)
+	}
+	if err != nil {
+		r.config.Logger.Warn("replicator: handle failed", "peer", msg.From, "kind", msg.Kind, "err", err)
+	} else if result != (gocrdt.MergeResult{}) {
+		r.config.Logger.Debug("replicator: merged", "peer", msg.From, "kind", msg.Kind,
+			"applied", result.Applied, "duplicates", result.Duplicates, "orphaned", result.Orphaned, "deleted", result.Deleted)
+	}
+	r.observeHandle(msg, result, start, err)
+	span.SetAttributes(mergeAttributes(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+// mergeAttributes describes a merge result as span attributes.
+func mergeAttributes(result gocrdt.MergeResult) []Attribute {
+	return []Attribute{
+		{"applied", result.Applied},
+		{"duplicates", result.Duplicates},
+		{"orphaned", result.Orphaned},
+		{"deleted", result.Deleted},
+		{"rejected", result.Rejected},
+	}
+}
+
+// handle dispatches msg on its kind.
+func (r *Replica) handle(ctx context.Context, msg Message) (gocrdt.MergeResult, error) {
+	switch msg.Kind {
+	case KindState:
+		result, err := mergeFrom(r.state, msg.From, msg.Payload)
+		if err != nil {
+			return result, err
+		}
+		return result, r.requestGaps(ctx, msg.From, result)
+	case KindDigest:
+		return gocrdt.MergeResult{}, r.handleDigest(ctx, msg)
+	case KindPull:
+		result, err := r.handlePull(ctx, msg)
+		if err != nil {
+			return result, err
+		}
+		return result, r.requestGaps(ctx, msg.From, result)
+	case KindTree, KindTreeReply:
+		return gocrdt.MergeResult{}, r.handleTree(ctx, msg)
+	case KindNeed:
+		return gocrdt.MergeResult{}, r.handleNeed(ctx, msg)
+	case KindOps:
+		return r.handleOps(ctx, msg)
+	case KindLeave:
+		return r.handleLeave(msg)
+	default:
+		return gocrdt.MergeResult{}, fmt.Errorf("replicator: unknown message kind %q from %s", msg.Kind, msg.From)
+	}
+}
+
+// Run receives and applies incoming messages and syncs with all peers every
+// Config.Interval until ctx is cancelled, at which point ctx.Err() is
+// returned. A closed transport stops Run with ErrClosed, and a closed
+// replica with ErrReplicaClosed, at the next round.
+//
+// With Config.Gossip set, every round is a Gossip round instead of a
+// broadcast Sync.
+//
+// Each round is bounded by Config.Interval: sends (including retries) still
+// in flight when the next round is due are abandoned, so rounds never pile
+// up behind an unreachable peer.
+func (r *Replica) Run(ctx context.Context) error {
+	if r.closed.Load() {
+		return ErrReplicaClosed
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	received := make(chan error, 1)
+	go func() {
+		received <- r.receiveLoop(ctx)
+	}()
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-received
+			return ctx.Err()
+		case err := <-received:
+			return err
+		case <-ticker.C:
+			if r.closed.Load() {
+				cancel()
+				<-received
+				return ErrReplicaClosed
+			}
+			roundCtx, cancelRound := context.WithTimeout(ctx, r.config.Interval)
+			err := r.round(roundCtx)
+			cancelRound()
+			if err != nil && ctx.Err() == nil {
+				r.reportError(err)
+			}
+			r.checkLiveness(time.Now())
+		}
+	}
+}
+
+// round runs a single replication round in the configured mode.
+func (r *Replica) round(ctx context.Context) error {
+	if r.config.Gossip != nil {
+		return r.Gossip(ctx)
+	}
+	return r.Sync(ctx)
+}
+
+// receiveLoop feeds incoming messages to Handle until the transport fails.
+func (r *Replica) receiveLoop(ctx context.Context) error {
+	for {
+		msg, err := r.transport.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := r.Handle(ctx, msg); err != nil {
+			r.reportError(err)
+		}
+	}
+}
+
+// send delivers msg, retrying with exponential backoff.
+func (r *Replica) send(ctx context.Context, msg Message) error {
+	backoff := r.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err = r.transport.Send(ctx, msg); err == nil || errors.Is(err, ErrClosed) {
+			if err == nil && r.config.Metrics != nil {
+				r.config.Metrics.ObserveSent(msg.To, len(msg.Payload))
+			}
+			return err
+		}
+	}
+	r.config.Logger.Warn("replicator: send failed", "peer", msg.To, "kind", msg.Kind, "attempts", r.config.MaxRetries+1, "err", err)
+	return err
+}
+
+// reportError forwards background errors to the configured handler.
+func (r *Replica) reportError(err error) {
+	if r.config.OnError != nil {
+		r.config.OnError(err)
+	}
+}
@@ -0,0 +1,240 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// testHub is a minimal in-process network used to exercise the Replica.
+type testHub struct {
+	mu       sync.Mutex
+	inboxes  map[string]chan Message
+	failures int             // number of upcoming sends that fail
+	dead     map[string]bool // peers whose sends always fail
+	group    map[string]int  // partition group per replica; 0 = reachable by all
+}
+
+func newTestHub() *testHub {
+	return &testHub{
+		inboxes: make(map[string]chan Message),
+		dead:    make(map[string]bool),
+		group:   make(map[string]int),
+	}
+}
+
+// partition splits the network: replicas in different groups silently lose
+// each other's messages until heal is called.
+func (h *testHub) partition(groups ...[]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, members := range groups {
+		for _, id := range members {
+			h.group[id] = i + 1
+		}
+	}
+}
+
+func (h *testHub) heal() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.group = make(map[string]int)
+}
+
+// pump delivers queued messages to the replicas until the network is idle.
+func (h *testHub) pump(t *testing.T, replicas ...*Replica) {
+	t.Helper()
+	for delivered := true; delivered; {
+		delivered = false
+		for _, r := range replicas {
+			h.mu.Lock()
+			inbox := h.inboxes[r.ID()]
+			h.mu.Unlock()
+			select {
+			case msg := <-inbox:
+				delivered = true
+				if _, err := r.Handle(context.Background(), msg); err != nil {
+					t.Fatalf("Handle on %s failed: %v", r.ID(), err)
+				}
+			default:
+			}
+		}
+	}
+}
+
+// transport returns the endpoint for id, creating its inbox on first use.
+func (h *testHub) transport(id string) Transport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.inboxes[id]; !ok {
+		h.inboxes[id] = make(chan Message, 64)
+	}
+	return &testTransport{hub: h, id: id}
+}
+
+type testTransport struct {
+	hub *testHub
+	id  string
+}
+
+func (t *testTransport) Send(_ context.Context, msg Message) error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	if t.hub.dead[msg.To] {
+		return errors.New("peer unreachable")
+	}
+	if from, to := t.hub.group[msg.From], t.hub.group[msg.To]; from != 0 && to != 0 && from != to {
+		return nil // lost in the partition
+	}
+	if t.hub.failures > 0 {
+		t.hub.failures--
+		return errors.New("transient failure")
+	}
+	select {
+	case t.hub.inboxes[msg.To] <- msg:
+	default: // inbox full: drop, like a lossy network
+	}
+	return nil
+}
+
+func (t *testTransport) Receive(ctx context.Context) (Message, error) {
+	t.hub.mu.Lock()
+	inbox := t.hub.inboxes[t.id]
+	t.hub.mu.Unlock()
+	select {
+	case msg := <-inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+func TestReplica_Peers(t *testing.T) {
+	r := NewReplica("a", gocrdt.NewGCounter("a"), newTestHub().transport("a"), Config{})
+	r.AddPeer("c")
+	r.AddPeer("b")
+	r.AddPeer("a") // self is ignored
+	r.RemovePeer("c")
+
+	peers := r.Peers()
+	if len(peers) != 1 || peers[0].ID != "b" {
+		t.Errorf("Expected [b], got %v", peers)
+	}
+}
+
+func TestReplica_SyncAndHandle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	hub := newTestHub()
+	counterA := gocrdt.NewGCounter("a")
+	counterB := gocrdt.NewGCounter("b")
+	transportB := hub.transport("b")
+	a := NewReplica("a", counterA, hub.transport("a"), Config{})
+	b := NewReplica("b", counterB, transportB, Config{})
+	a.AddPeer("b")
+
+	counterA.Increment()
+	counterA.Increment()
+	if err := a.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	msg, err := transportB.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if _, err := b.Handle(ctx, msg); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if counterB.Value() != 2 {
+		t.Errorf("Expected 2, got %d", counterB.Value())
+	}
+
+	if _, err := b.Handle(ctx, Message{Kind: "bogus"}); err == nil {
+		t.Error("Expected error for unknown message kind")
+	}
+
+	cancel()
+	if _, err := b.Handle(ctx, msg); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled after cancellation, got %v", err)
+	}
+}
+
+func TestReplica_SyncRetries(t *testing.T) {
+	hub := newTestHub()
+	hub.transport("b")
+	a := NewReplica("a", gocrdt.NewGCounter("a"), hub.transport("a"), Config{RetryBackoff: time.Millisecond})
+	a.AddPeer("b")
+
+	hub.failures = 2
+	if err := a.Sync(context.Background()); err != nil {
+		t.Errorf("Expected retries to recover from transient failures, got %v", err)
+	}
+
+	hub.failures = DefaultMaxRetries + 1
+	if err := a.Sync(context.Background()); err == nil {
+		t.Error("Expected error once retries are exhausted")
+	}
+}
+
+func TestReplica_UnreachablePeerDoesNotBlockOthers(t *testing.T) {
+	hub := newTestHub()
+	hub.dead["dead"] = true
+	transportB := hub.transport("b")
+	a := NewReplica("a", gocrdt.NewGCounter("a"), hub.transport("a"), Config{RetryBackoff: time.Hour})
+	a.AddPeer("b")
+	a.AddPeer("dead")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := a.Sync(ctx); err == nil {
+		t.Error("Expected an error for the unreachable peer")
+	}
+
+	msg, err := transportB.Receive(context.Background())
+	if err != nil || msg.From != "a" {
+		t.Errorf("Expected b to receive the state despite the dead peer, got %+v (%v)", msg, err)
+	}
+}
+
+func TestReplica_RunConverges(t *testing.T) {
+	hub := newTestHub()
+	docA := gocrdt.NewRGA("a")
+	docB := gocrdt.NewRGA("b")
+	config := Config{Interval: 5 * time.Millisecond}
+	a := NewReplica("a", docA, hub.transport("a"), config)
+	b := NewReplica("b", docB, hub.transport("b"), config)
+	a.AddPeer("b")
+	b.AddPeer("a")
+
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	docB.Insert('B', gocrdt.ID{NodeID: "root"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errs := make(chan error, 2)
+	go func() { errs <- a.Run(ctx) }()
+	go func() { errs <- b.Run(ctx) }()
+
+	for ctx.Err() == nil {
+		if docA.Value() == docB.Value() && len(docA.Value().(string)) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if docA.Value() != docB.Value() {
+		t.Errorf("Replicas did not converge: A=%s, B=%s", docA.Value(), docB.Value())
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected Run to stop with context.Canceled, got %v", err)
+		}
+	}
+}
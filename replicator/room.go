@@ -0,0 +1,188 @@
+package replicator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// ErrNotJoined is returned by Room.Deliver for a client that has not
+// joined the room, or was dropped from it.
+var ErrNotJoined = errors.New("replicator: client not in the room")
+
+// roomClient is a client connected to a Room.
+type roomClient struct {
+	session *SyncSession
+	send    func(SessionMessage) error
+}
+
+// Room is the server side of a collaborative document: one state, many
+// connected clients, each running a sync session with the room.
+//
+//	room := replicator.NewRoom("server", doc)
+//	// On connect:
+//	room.Join(clientID, func(m replicator.SessionMessage) error { return conn.Queue(m) })
+//	// For every message read from the connection:
+//	room.Deliver(clientID, msg)
+//	// On disconnect:
+//	room.Leave(clientID)
+//
+// A client joining late gets a snapshot from the session handshake, or a
+// delta when it reconnects with what it already has. Changes a client
+// sends are merged and broadcast to the other clients, as deltas for
+// DeltaState states; they are not echoed back to their sender. The server
+// changes the state through Update, which broadcasts the change too: a
+// change made outside the room while Deliver merges could be taken for the
+// sender's and never reach it.
+//
+// The room serializes its work under a mutex and calls send with it held,
+// so send must not block: queue the message for the connection's writer.
+// A client whose send fails, or whose session fails, is dropped from the
+// room and must join again.
+type Room struct {
+	log   *SessionLog
+	state gocrdt.Replicable
+
+	mu      sync.Mutex
+	clients map[string]*roomClient
+}
+
+// NewRoom creates a room for state, which the room's sessions identify as
+// the replica id.
+func NewRoom(id string, state gocrdt.Replicable) *Room {
+	return &Room{log: NewSessionLog(id, state), state: state, clients: make(map[string]*roomClient)}
+}
+
+// Log returns the session bookkeeping of the room, such as for a
+// Stability.
+func (r *Room) Log() *SessionLog {
+	return r.log
+}
+
+// Join connects client, whose messages send delivers, and sends it the
+// hello of a new session. A client already in the room is reconnected,
+// its previous session discarded.
+func (r *Room) Join(client string, send func(SessionMessage) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &roomClient{session: r.log.NewSession(client), send: send}
+	r.clients[client] = c
+	return r.sendTo(client, c, c.session.Start())
+}
+
+// Leave disconnects client. What it acknowledged is remembered, so a later
+// Join resumes with a delta.
+func (r *Room) Leave(client string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, client)
+}
+
+// Clients returns the clients in the room, sorted.
+func (r *Room) Clients() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]string, 0, len(r.clients))
+	for client := range r.clients {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+	return clients
+}
+
+// Deliver applies a message from client to its session, answers it and
+// broadcasts the changes it brought to the other clients. It returns the
+// result of the merge; on a session error the client is dropped.
+func (r *Room) Deliver(client string, msg SessionMessage) (gocrdt.MergeResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.clients[client]
+	if !ok {
+		return gocrdt.MergeResult{}, fmt.Errorf("%w: %q", ErrNotJoined, client)
+	}
+
+	// Send the sender what it has not got yet, so that skipping its own
+	// changes below skips nothing else.
+	delta, isDelta := r.state.(DeltaState)
+	data := msg.Type == SessionSnapshot || msg.Type == SessionDelta
+	if data && isDelta {
+		if err := r.poll(client, c); err != nil {
+			return gocrdt.MergeResult{}, err
+		}
+	}
+
+	replies, result, err := c.session.Step(msg)
+	if err != nil {
+		delete(r.clients, client)
+		return result, err
+	}
+	for _, reply := range replies {
+		if err := r.sendTo(client, c, reply); err != nil {
+			return result, err
+		}
+	}
+	if result.Applied == 0 && result.Deleted == 0 {
+		return result, nil
+	}
+
+	if isDelta && c.session.phase == PhaseStreaming {
+		// The changes just merged came from the client: do not echo them.
+		_, cursor, err := delta.MarshalChanges(math.MaxUint64)
+		if err != nil {
+			return result, err
+		}
+		c.session.sent = max(c.session.sent, cursor)
+	}
+	return result, r.broadcast()
+}
+
+// Update runs fn, a change of the state by the server, and broadcasts it
+// to every client. fn runs under the room's lock and must not call back
+// into the room.
+func (r *Room) Update(fn func()) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn()
+	return r.broadcast()
+}
+
+// broadcast polls every session. It must be called with mu held.
+func (r *Room) broadcast() error {
+	var errs []error
+	for client, c := range r.clients {
+		if err := r.poll(client, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// poll sends client the changes its session has not sent yet. It must be
+// called with mu held.
+func (r *Room) poll(client string, c *roomClient) error {
+	msgs, err := c.session.Poll()
+	if err != nil {
+		delete(r.clients, client)
+		return fmt.Errorf("replicator: room client %s: %w", client, err)
+	}
+	for _, msg := range msgs {
+		if err := r.sendTo(client, c, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendTo sends msg to client, dropping the client when it fails. It must
+// be called with mu held.
+func (r *Room) sendTo(client string, c *roomClient, msg SessionMessage) error {
+	if err := c.send(msg); err != nil {
+		delete(r.clients, client)
+		return fmt.Errorf("replicator: room client %s: %w", client, err)
+	}
+	return nil
+}
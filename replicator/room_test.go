@@ -0,0 +1,118 @@
+package replicator
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// roomPeer is a client of a Room in memory.
+type roomPeer struct {
+	id      string
+	doc     *gocrdt.RGA
+	session *SyncSession
+	inbox   []SessionMessage
+	data    []SessionMessageType // Data messages received
+}
+
+// joinRoom connects a new client with an empty document to room.
+func joinRoom(t *testing.T, room *Room, id string) *roomPeer {
+	t.Helper()
+	p := &roomPeer{id: id, doc: gocrdt.NewRGA(id)}
+	p.session = NewSessionLog(id, p.doc).NewSession("server")
+	if err := room.Join(id, func(m SessionMessage) error {
+		p.inbox = append(p.inbox, m)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := room.Deliver(id, p.session.Start()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// pumpRoom exchanges messages until every client is quiet.
+func pumpRoom(t *testing.T, room *Room, peers ...*roomPeer) {
+	t.Helper()
+	for busy := true; busy; {
+		busy = false
+		for _, p := range peers {
+			msgs := p.inbox
+			p.inbox = nil
+			for _, msg := range msgs {
+				busy = true
+				if msg.Type == SessionSnapshot || msg.Type == SessionDelta {
+					p.data = append(p.data, msg.Type)
+				}
+				replies, _, err := p.session.Step(msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, reply := range replies {
+					if _, err := room.Deliver(p.id, reply); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// push sends the local changes of p to the room.
+func (p *roomPeer) push(t *testing.T, room *Room) {
+	t.Helper()
+	msgs, err := p.session.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if _, err := room.Deliver(p.id, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRoom(t *testing.T) {
+	doc := gocrdt.NewRGA("server")
+	room := NewRoom("server", doc)
+	alice, bob := joinRoom(t, room, "alice"), joinRoom(t, room, "bob")
+	pumpRoom(t, room, alice, bob)
+
+	// A change of alice reaches bob and is not echoed to alice.
+	alice.data = nil
+	alice.doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	alice.push(t, room)
+	pumpRoom(t, room, alice, bob)
+	if bob.doc.Value() != "a" || doc.Value() != "a" {
+		t.Errorf("Expected the change broadcast, got %q on bob", bob.doc.Value())
+	}
+	if len(alice.data) != 0 {
+		t.Errorf("Expected no echo to alice, got %v", alice.data)
+	}
+
+	// A late joiner starts from a snapshot, and server changes reach all.
+	carol := joinRoom(t, room, "carol")
+	pumpRoom(t, room, alice, bob, carol)
+	if carol.doc.Value() != "a" || carol.data[0] != SessionSnapshot {
+		t.Errorf("Expected carol bootstrapped by a snapshot, got %q, %v", carol.doc.Value(), carol.data)
+	}
+	if err := room.Update(func() { doc.Insert('s', gocrdt.ID{NodeID: "root"}) }); err != nil {
+		t.Fatal(err)
+	}
+	pumpRoom(t, room, alice, bob, carol)
+	for _, p := range []*roomPeer{alice, bob, carol} {
+		if p.doc.Value() != doc.Value() {
+			t.Errorf("Expected %s to have %q, got %q", p.id, doc.Value(), p.doc.Value())
+		}
+	}
+
+	room.Leave("bob")
+	if clients := room.Clients(); len(clients) != 2 {
+		t.Errorf("Expected two clients, got %v", clients)
+	}
+	if _, err := room.Deliver("bob", SessionMessage{Type: SessionAck}); !errors.Is(err, ErrNotJoined) {
+		t.Errorf("Expected ErrNotJoined, got %v", err)
+	}
+}
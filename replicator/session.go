@@ -0,0 +1,374 @@
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// KindSession carries an encoded SessionMessage, for transports that route
+// sync sessions alongside the other replicator messages.
+const KindSession Kind = "session"
+
+// ErrSessionProtocol is returned by SyncSession.Step for messages that
+// violate the session protocol, such as data before the handshake.
+var ErrSessionProtocol = errors.New("replicator: session protocol violation")
+
+// DeltaState is a Replicable that can ship only what changed after a
+// cursor into its local change log, such as gocrdt.RGA. Sync sessions use
+// it to replace full snapshots by deltas once two replicas have met.
+type DeltaState interface {
+	gocrdt.Replicable
+	MarshalChanges(since uint64) ([]byte, uint64, error)
+}
+
+// Version is a position in the change log of one replica. Epoch identifies
+// the log itself: a replica that restarts with a fresh log gets a new epoch,
+// which invalidates every cursor peers remember for it.
+type Version struct {
+	Epoch  string `json:"epoch,omitempty"`
+	Cursor uint64 `json:"cursor,omitempty"`
+}
+
+// VersionVector maps peer IDs to the position in their change log up to
+// which the local replica has received and merged everything.
+type VersionVector map[string]Version
+
+// SessionLog is the per-replica bookkeeping shared by all sync sessions of
+// one state: the epoch of the local change log and the version vector of
+// what was received from each peer. It outlives individual sessions, which
+// is what lets a reconnecting session resume with a delta.
+type SessionLog struct {
+	id    string
+	state gocrdt.Replicable
+	epoch string
+
+	mu       sync.Mutex
+	received VersionVector
+	tracer   Tracer
+
+	// notify is closed and replaced whenever received advances, see
+	// ClientSession.Await.
+	notify chan struct{}
+}
+
+// NewSessionLog creates the session bookkeeping of the replica id for
+// state. States implementing DeltaState get a fresh random epoch; others
+// always sync by snapshot.
+func NewSessionLog(id string, state gocrdt.Replicable) *SessionLog {
+	l := &SessionLog{id: id, state: state, received: make(VersionVector), notify: make(chan struct{})}
+	if _, ok := state.(DeltaState); ok {
+		var b [8]byte
+		_, _ = rand.Read(b[:])
+		l.epoch = hex.EncodeToString(b[:])
+	}
+	return l
+}
+
+// SetTracer makes the sessions of l trace their steps and delta
+// generation with t. Sessions have no context, so their spans are roots.
+func (l *SessionLog) SetTracer(t Tracer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tracer = t
+}
+
+func (l *SessionLog) startSpan(name string, attrs ...Attribute) Span {
+	l.mu.Lock()
+	t := l.tracer
+	l.mu.Unlock()
+	_, span := startSpan(t, context.Background(), name, attrs...)
+	return span
+}
+
+// Epoch returns the epoch of the local change log, empty for states
+// without deltas.
+func (l *SessionLog) Epoch() string {
+	return l.epoch
+}
+
+// Vector returns a copy of the version vector of received changes.
+func (l *SessionLog) Vector() VersionVector {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	vector := make(VersionVector, len(l.received))
+	for peer, v := range l.received {
+		vector[peer] = v
+	}
+	return vector
+}
+
+func (l *SessionLog) receivedFrom(peer string) Version {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.received[peer]
+}
+
+func (l *SessionLog) setReceived(peer string, v Version) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if old, ok := l.received[peer]; !ok || old.Epoch != v.Epoch || old.Cursor < v.Cursor {
+		l.received[peer] = v
+		close(l.notify)
+		l.notify = make(chan struct{})
+	}
+}
+
+// SessionMessageType identifies the step of the session protocol a
+// SessionMessage belongs to.
+type SessionMessageType string
+
+const (
+	// SessionHello opens a session: it carries the sender's log epoch, the
+	// version it has received from the receiver (Have) and its whole
+	// version vector (Vector).
+	SessionHello SessionMessageType = "hello"
+
+	// SessionSnapshot carries the sender's full state. Cursor is the
+	// sender's log position it covers.
+	SessionSnapshot SessionMessageType = "snapshot"
+
+	// SessionDelta carries the sender's changes after the receiver's Have
+	// cursor, up to Cursor.
+	SessionDelta SessionMessageType = "delta"
+
+	// SessionAck confirms that the data up to Cursor was merged.
+	SessionAck SessionMessageType = "ack"
+)
+
+// SessionMessage is one message of the session protocol.
+type SessionMessage struct {
+	Type    SessionMessageType `json:"type"`
+	Epoch   string             `json:"epoch,omitempty"`
+	Have    Version            `json:"have,omitzero"`
+	Vector  VersionVector      `json:"vector,omitempty"`
+	Cursor  uint64             `json:"cursor,omitempty"`
+	Payload []byte             `json:"payload,omitempty"`
+}
+
+// MarshalBinary encodes the message for a transport.
+func (m SessionMessage) MarshalBinary() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// UnmarshalBinary decodes a message encoded by MarshalBinary.
+func (m *SessionMessage) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, m)
+}
+
+// SessionPhase is the state of a SyncSession.
+type SessionPhase int
+
+const (
+	// PhaseHandshake waits for the peer's hello.
+	PhaseHandshake SessionPhase = iota
+
+	// PhaseStreaming exchanges data: the initial snapshot or delta, then
+	// the changes produced by Poll, each acknowledged by the peer.
+	PhaseStreaming
+
+	// PhaseFailed is entered on a protocol or merge error. The session
+	// must be discarded; a new one resumes from the last merged data.
+	PhaseFailed
+)
+
+// SyncSession is the state machine of one sync session with a peer. It
+// does no I/O: the caller sends the messages returned by Start, Step and
+// Poll over any transport that preserves their order, and feeds the peer's
+// messages to Step.
+//
+// The protocol is symmetric. Both sides send a hello announcing their log
+// epoch and what they have already received from the other. Each side then
+// decides on its own whether the peer needs a full snapshot (first contact,
+// or its cursor refers to another epoch) or only a delta, sends it, and
+// from then on streams further changes. Every data message is
+// acknowledged; only merged data advances the version vector, so a session
+// interrupted at any point is resumed by the next one without losing or
+// re-sending more than the unacknowledged tail.
+type SyncSession struct {
+	log  *SessionLog
+	peer string
+
+	phase     SessionPhase
+	peerEpoch string
+	sent      uint64 // log cursor (or snapshot count) covered by sent data
+	acked     uint64
+	lastSum   [sha256.Size]byte // last snapshot sent, for states without deltas
+	peerVec   VersionVector     // the peer's vector, advanced by its acks
+}
+
+// NewSession starts the bookkeeping of a session with peer. Only one
+// session per peer should be active at a time.
+func (l *SessionLog) NewSession(peer string) *SyncSession {
+	return &SyncSession{log: l, peer: peer}
+}
+
+// Phase returns the current phase.
+func (s *SyncSession) Phase() SessionPhase {
+	return s.phase
+}
+
+// InSync reports whether the handshake completed and the peer
+// acknowledged everything sent so far.
+func (s *SyncSession) InSync() bool {
+	return s.phase == PhaseStreaming && s.acked >= s.sent
+}
+
+// Start returns the hello message that opens the session.
+func (s *SyncSession) Start() SessionMessage {
+	return SessionMessage{
+		Type:   SessionHello,
+		Epoch:  s.log.epoch,
+		Have:   s.log.receivedFrom(s.peer),
+		Vector: s.log.Vector(),
+	}
+}
+
+// PeerVector returns what the peer has received: the version vector from
+// its hello, with the entry for the local log advanced by its acks. Feed it
+// to Replica.RecordAck to drive membership and stability tracking.
+func (s *SyncSession) PeerVector() VersionVector {
+	vector := make(VersionVector, len(s.peerVec))
+	for origin, v := range s.peerVec {
+		vector[origin] = v
+	}
+	return vector
+}
+
+// Step applies a message from the peer and returns the messages to send
+// back, together with the result of merging any data it carried.
+func (s *SyncSession) Step(msg SessionMessage) ([]SessionMessage, gocrdt.MergeResult, error) {
+	if s.phase == PhaseFailed {
+		return nil, gocrdt.MergeResult{}, fmt.Errorf("%w: session already failed", ErrSessionProtocol)
+	}
+	span := s.log.startSpan("gocrdt.session.step",
+		Attribute{"peer", s.peer}, Attribute{"type", string(msg.Type)}, Attribute{"bytes", len(msg.Payload)})
+	replies, result, err := s.step(msg)
+	if err != nil {
+		s.phase = PhaseFailed
+	}
+	span.SetAttributes(mergeAttributes(result)...)
+	endSpan(span, err)
+	return replies, result, err
+}
+
+func (s *SyncSession) step(msg SessionMessage) ([]SessionMessage, gocrdt.MergeResult, error) {
+	switch msg.Type {
+	case SessionHello:
+		if s.phase != PhaseHandshake {
+			return nil, gocrdt.MergeResult{}, fmt.Errorf("%w: duplicate hello", ErrSessionProtocol)
+		}
+		s.peerEpoch = msg.Epoch
+		s.peerVec = make(VersionVector, len(msg.Vector)+1)
+		for origin, v := range msg.Vector {
+			s.peerVec[origin] = v
+		}
+		if msg.Have.Epoch != "" && msg.Have.Epoch == s.log.epoch {
+			s.peerVec[s.log.id] = msg.Have
+		}
+		s.phase = PhaseStreaming
+		data, err := s.initialData(msg.Have)
+		return data, gocrdt.MergeResult{}, err
+
+	case SessionSnapshot, SessionDelta:
+		if s.phase != PhaseStreaming {
+			return nil, gocrdt.MergeResult{}, fmt.Errorf("%w: %s before hello", ErrSessionProtocol, msg.Type)
+		}
+		var result gocrdt.MergeResult
+		if len(msg.Payload) > 0 {
+			var err error
+			if result, err = mergeFrom(s.log.state, s.peer, msg.Payload); err != nil {
+				return nil, result, err
+			}
+		}
+		if s.peerEpoch != "" {
+			s.log.setReceived(s.peer, Version{Epoch: s.peerEpoch, Cursor: msg.Cursor})
+		}
+		return []SessionMessage{{Type: SessionAck, Cursor: msg.Cursor}}, result, nil
+
+	case SessionAck:
+		if s.phase != PhaseStreaming {
+			return nil, gocrdt.MergeResult{}, fmt.Errorf("%w: ack before hello", ErrSessionProtocol)
+		}
+		s.acked = max(s.acked, msg.Cursor)
+		if s.log.epoch != "" {
+			s.peerVec[s.log.id] = Version{Epoch: s.log.epoch, Cursor: s.acked}
+		}
+		return nil, gocrdt.MergeResult{}, nil
+	}
+	return nil, gocrdt.MergeResult{}, fmt.Errorf("%w: unknown message type %q", ErrSessionProtocol, msg.Type)
+}
+
+// initialData answers the peer's hello with a delta when its Have cursor
+// belongs to the current local log, and with a snapshot otherwise.
+func (s *SyncSession) initialData(have Version) ([]SessionMessage, error) {
+	delta, ok := s.log.state.(DeltaState)
+	if !ok {
+		return s.snapshot()
+	}
+	if have.Epoch != s.log.epoch {
+		have.Cursor = 0
+	}
+	payload, next, err := s.marshalChanges(delta, have.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	s.sent = next
+	msgType := SessionDelta
+	if have.Cursor == 0 {
+		msgType = SessionSnapshot
+	}
+	// An empty delta is still sent so the peer learns the cursor.
+	return []SessionMessage{{Type: msgType, Cursor: next, Payload: payload}}, nil
+}
+
+// snapshot sends the full state of a state without deltas, unless it is
+// identical to the last one sent.
+func (s *SyncSession) snapshot() ([]SessionMessage, error) {
+	payload, err := marshalFor(s.log.state, s.peer)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	if s.sent > 0 && bytes.Equal(sum[:], s.lastSum[:]) {
+		return nil, nil
+	}
+	s.lastSum = sum
+	s.sent++
+	return []SessionMessage{{Type: SessionSnapshot, Cursor: s.sent, Payload: payload}}, nil
+}
+
+// Poll returns the data to send for local changes made since the last
+// message, if any. It returns nothing before the handshake completes.
+func (s *SyncSession) Poll() ([]SessionMessage, error) {
+	if s.phase != PhaseStreaming {
+		return nil, nil
+	}
+	delta, ok := s.log.state.(DeltaState)
+	if !ok {
+		return s.snapshot()
+	}
+	payload, next, err := s.marshalChanges(delta, s.sent)
+	if err != nil || payload == nil {
+		return nil, err
+	}
+	s.sent = next
+	return []SessionMessage{{Type: SessionDelta, Cursor: next, Payload: payload}}, nil
+}
+
+// marshalChanges generates the delta of the local log after since.
+func (s *SyncSession) marshalChanges(delta DeltaState, since uint64) ([]byte, uint64, error) {
+	span := s.log.startSpan("gocrdt.session.delta", Attribute{"peer", s.peer}, Attribute{"since", since})
+	payload, next, err := delta.MarshalChanges(since)
+	span.SetAttributes(Attribute{"cursor", next}, Attribute{"bytes", len(payload)})
+	endSpan(span, err)
+	return payload, next, err
+}
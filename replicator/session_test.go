@@ -0,0 +1,228 @@
+package replicator
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// sessionPair drives two sessions against each other in memory.
+type sessionPair struct {
+	t    *testing.T
+	a, b *SyncSession
+	// types records the data message types sent, in order.
+	types []SessionMessageType
+}
+
+func newSessionPair(t *testing.T, logA, logB *SessionLog) *sessionPair {
+	return &sessionPair{t: t, a: logA.NewSession(logB.id), b: logB.NewSession(logA.id)}
+}
+
+// deliver feeds msgs to the receiving session and keeps exchanging the
+// replies until both sides are quiet. drop, if set, loses matching messages.
+func (p *sessionPair) deliver(to *SyncSession, msgs []SessionMessage, drop func(SessionMessage) bool) {
+	p.t.Helper()
+	for _, msg := range msgs {
+		if msg.Type == SessionSnapshot || msg.Type == SessionDelta {
+			p.types = append(p.types, msg.Type)
+		}
+		if drop != nil && drop(msg) {
+			continue
+		}
+		// Round-trip through the wire encoding.
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			p.t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		var decoded SessionMessage
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			p.t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+
+		replies, _, err := to.Step(decoded)
+		if err != nil {
+			p.t.Fatalf("Step failed: %v", err)
+		}
+		p.deliver(p.other(to), replies, drop)
+	}
+}
+
+func (p *sessionPair) other(s *SyncSession) *SyncSession {
+	if s == p.a {
+		return p.b
+	}
+	return p.a
+}
+
+// handshake exchanges the hellos and everything that follows. Like an
+// ordered stream, each side's hello arrives before its data.
+func (p *sessionPair) handshake(drop func(SessionMessage) bool) {
+	p.t.Helper()
+	repliesB, _, err := p.b.Step(p.a.Start())
+	if err != nil {
+		p.t.Fatalf("Hello failed: %v", err)
+	}
+	repliesA, _, err := p.a.Step(p.b.Start())
+	if err != nil {
+		p.t.Fatalf("Hello failed: %v", err)
+	}
+	p.deliver(p.a, repliesB, drop)
+	p.deliver(p.b, repliesA, drop)
+}
+
+func (p *sessionPair) poll() {
+	p.t.Helper()
+	for _, s := range []*SyncSession{p.a, p.b} {
+		msgs, err := s.Poll()
+		if err != nil {
+			p.t.Fatalf("Poll failed: %v", err)
+		}
+		p.deliver(p.other(s), msgs, nil)
+	}
+}
+
+func TestSyncSession_SnapshotThenDelta(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	idA := docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	docB.Insert('B', gocrdt.ID{NodeID: "root"})
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+
+	// First contact: both sides need a snapshot.
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+	if docA.Value() != docB.Value() || !p.a.InSync() || !p.b.InSync() {
+		t.Fatalf("Expected convergence after the handshake: %s / %s", docA.Value(), docB.Value())
+	}
+	if len(p.types) != 2 || p.types[0] != SessionSnapshot || p.types[1] != SessionSnapshot {
+		t.Errorf("Expected two snapshots, got %v", p.types)
+	}
+
+	// Live changes are streamed by Poll.
+	docA.Insert('!', idA)
+	p.poll()
+	if docA.Value() != docB.Value() {
+		t.Errorf("Poll did not stream the change: %s / %s", docA.Value(), docB.Value())
+	}
+
+	// Reconnect: only deltas are needed.
+	docB.Insert('?', gocrdt.ID{NodeID: "root"})
+	p = newSessionPair(t, logA, logB)
+	p.handshake(nil)
+	if docA.Value() != docB.Value() {
+		t.Errorf("Reconnect did not converge: %s / %s", docA.Value(), docB.Value())
+	}
+	for _, typ := range p.types {
+		if typ != SessionDelta {
+			t.Errorf("Expected only deltas on reconnect, got %v", p.types)
+			break
+		}
+	}
+	if v := logA.Vector()["b"]; v.Epoch != logB.Epoch() || v.Cursor == 0 {
+		t.Errorf("Expected a's vector to track b's log, got %+v", v)
+	}
+}
+
+func TestSyncSession_InterruptedSessionResumes(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	newSessionPair(t, logA, logB).handshake(nil)
+
+	// The connection drops with a's delta in flight.
+	first := docA.Nodes()[0].ID
+	docA.Insert('1', first)
+	p := newSessionPair(t, logA, logB)
+	p.handshake(func(msg SessionMessage) bool { return msg.Type == SessionDelta && len(msg.Payload) > 0 })
+	if docB.Value() == docA.Value() || p.a.InSync() {
+		t.Fatal("Expected the lost delta to leave b behind and a unacknowledged")
+	}
+
+	// The next session resends the lost tail as a delta.
+	docA.Insert('2', first)
+	p = newSessionPair(t, logA, logB)
+	p.handshake(nil)
+	if docA.Value() != docB.Value() {
+		t.Errorf("Resumed session did not converge: %s / %s", docA.Value(), docB.Value())
+	}
+	if len(p.types) == 0 || p.types[0] != SessionDelta {
+		t.Errorf("Expected a delta after the interruption, got %v", p.types)
+	}
+}
+
+func TestSyncSession_NewEpochForcesSnapshot(t *testing.T) {
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	newSessionPair(t, logA, logB).handshake(nil)
+
+	// a restarts: same content, new change log.
+	restarted := gocrdt.NewRGA("a")
+	restarted.Merge(docA.Nodes())
+	logA = NewSessionLog("a", restarted)
+
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+	if p.types[0] != SessionSnapshot && p.types[1] != SessionSnapshot {
+		t.Errorf("Expected b to get a snapshot from the restarted a, got %v", p.types)
+	}
+}
+
+func TestSyncSession_CountersUseSnapshots(t *testing.T) {
+	counterA, counterB := gocrdt.NewPNCounter("a"), gocrdt.NewPNCounter("b")
+	counterA.Increment()
+	counterB.Decrement()
+	logA, logB := NewSessionLog("a", counterA), NewSessionLog("b", counterB)
+	if logA.Epoch() != "" {
+		t.Errorf("Expected no epoch for a state without deltas, got %q", logA.Epoch())
+	}
+
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+	if counterA.Value() != 0 || counterB.Value() != 0 || !p.a.InSync() {
+		t.Fatalf("Expected counters to converge to 0, got %d / %d", counterA.Value(), counterB.Value())
+	}
+
+	// Poll only sends when the state changed since the last snapshot.
+	p.poll()
+	if msgs, _ := p.a.Poll(); len(msgs) != 0 {
+		t.Errorf("Expected no data for an unchanged counter, got %d messages", len(msgs))
+	}
+	counterA.Increment()
+	p.poll()
+	if counterB.Value() != 1 {
+		t.Errorf("Expected the increment to be streamed, got %d", counterB.Value())
+	}
+}
+
+func TestSyncSession_ProtocolViolations(t *testing.T) {
+	log := NewSessionLog("a", gocrdt.NewRGA("a"))
+
+	s := log.NewSession("b")
+	if _, _, err := s.Step(SessionMessage{Type: SessionDelta}); !errors.Is(err, ErrSessionProtocol) {
+		t.Errorf("Expected ErrSessionProtocol for data before hello, got %v", err)
+	}
+	if s.Phase() != PhaseFailed {
+		t.Errorf("Expected PhaseFailed, got %v", s.Phase())
+	}
+	if _, _, err := s.Step(SessionMessage{Type: SessionHello}); !errors.Is(err, ErrSessionProtocol) {
+		t.Errorf("Expected a failed session to reject further messages, got %v", err)
+	}
+
+	s = log.NewSession("b")
+	if _, _, err := s.Step(SessionMessage{Type: SessionHello}); err != nil {
+		t.Fatalf("Hello failed: %v", err)
+	}
+	if _, _, err := s.Step(SessionMessage{Type: SessionHello}); !errors.Is(err, ErrSessionProtocol) {
+		t.Errorf("Expected ErrSessionProtocol for a duplicate hello, got %v", err)
+	}
+
+	s = log.NewSession("b")
+	_, _, _ = s.Step(SessionMessage{Type: SessionHello})
+	if _, _, err := s.Step(SessionMessage{Type: SessionDelta, Payload: []byte("garbage")}); err == nil {
+		t.Error("Expected an undecodable payload to fail the session")
+	}
+	if len(log.Vector()) != 0 {
+		t.Errorf("Failed merge must not advance the vector, got %v", log.Vector())
+	}
+}
@@ -0,0 +1,140 @@
+package replicator
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrStaleVector is returned by SessionLog.Compact for a frontier that does
+// not cover the current local change log.
+var ErrStaleVector = errors.New("replicator: version vector does not cover the local log")
+
+// Compactor is implemented by states that can discard data every replica
+// has seen, such as gocrdt.RGA (tombstone garbage collection). cursor is
+// the stable frontier of the state's local change log.
+type Compactor interface {
+	CompactStable(cursor uint64) int
+}
+
+// Stability computes the causal stability frontier of a replica from the
+// version vectors its peers acknowledged (Replica.RecordAck) and lets the
+// state compact what lies below it.
+//
+// Every member counts, stale ones included: a peer that may still be
+// holding back old operations blocks stability until it catches up or is
+// removed with RemovePeer.
+type Stability struct {
+	replica *Replica
+	log     *SessionLog
+
+	mu      sync.Mutex
+	applied uint64 // last cursor handed to the Compactor
+}
+
+// NewStability tracks the stability of the state behind log, whose peers
+// are the members of replica.
+func NewStability(replica *Replica, log *SessionLog) *Stability {
+	return &Stability{replica: replica, log: log}
+}
+
+// Frontier returns, for every origin log, the version that the local
+// replica and all members have received. Origins some member has not
+// acknowledged (in the same epoch) are absent, as is the local log when
+// there are no members.
+func (s *Stability) Frontier() VersionVector {
+	peers := s.replica.Peers()
+	self := s.log.id
+
+	// Candidates: the local log, and every log we received from.
+	frontier := s.log.Vector()
+	if s.log.epoch != "" && len(peers) > 0 {
+		frontier[self] = Version{Epoch: s.log.epoch, Cursor: ^uint64(0)}
+	}
+
+	for origin, v := range frontier {
+		for _, peer := range peers {
+			if peer.ID == origin {
+				continue // a replica has its own log
+			}
+			acked, ok := peer.Acked[origin]
+			if !ok || acked.Epoch != v.Epoch {
+				delete(frontier, origin)
+				break
+			}
+			v.Cursor = min(v.Cursor, acked.Cursor)
+			frontier[origin] = v
+		}
+	}
+	return frontier
+}
+
+// Advance hands the stable frontier of the local log to the state if it
+// moved since the last call and the state is a Compactor. It returns the
+// number of items the state discarded.
+func (s *Stability) Advance() int {
+	compactor, ok := s.log.state.(Compactor)
+	if !ok {
+		return 0
+	}
+	stable, ok := s.Frontier()[s.log.id]
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stable.Cursor <= s.applied {
+		return 0
+	}
+	s.applied = stable.Cursor
+	return compactor.CompactStable(stable.Cursor)
+}
+
+// MeetVectors returns the versions all vectors have reached: for every
+// origin present in each of them with the same epoch, the smallest cursor.
+// Given the vectors of every other replica (their SessionLog.Vector), its
+// entry for a replica is what all of them received from it.
+func MeetVectors(vectors ...VersionVector) VersionVector {
+	if len(vectors) == 0 {
+		return VersionVector{}
+	}
+	meet := make(VersionVector, len(vectors[0]))
+	for origin, v := range vectors[0] {
+		meet[origin] = v
+	}
+	for _, vector := range vectors[1:] {
+		for origin, v := range meet {
+			other, ok := vector[origin]
+			if !ok || other.Epoch != v.Epoch {
+				delete(meet, origin)
+				continue
+			}
+			v.Cursor = min(v.Cursor, other.Cursor)
+			meet[origin] = v
+		}
+	}
+	return meet
+}
+
+// Compact lets the state discard what every replica has received, on the
+// operator's schedule rather than from acknowledgments (see Stability).
+// It suits deployments with a fixed, known replica set: the operator
+// gathers the vectors of all other replicas, e.g. from an admin endpoint,
+// and passes their MeetVectors as before. Only the entry of the local log
+// is used; a missing one, or one from an earlier epoch of the log, returns
+// ErrStaleVector.
+//
+// Compact trusts before: a replica left out of it that has not seen a
+// deletion will never receive it. It returns the number of items the
+// state discarded, 0 for states that are not Compactors.
+func (l *SessionLog) Compact(before VersionVector) (int, error) {
+	compactor, ok := l.state.(Compactor)
+	if !ok || l.epoch == "" {
+		return 0, nil
+	}
+	stable, ok := before[l.id]
+	if !ok || stable.Epoch != l.epoch {
+		return 0, ErrStaleVector
+	}
+	return compactor.CompactStable(stable.Cursor), nil
+}
@@ -0,0 +1,105 @@
+package replicator
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStability_FrontierAndCompaction(t *testing.T) {
+	root := gocrdt.ID{NodeID: "root"}
+	docs := map[string]*gocrdt.RGA{"a": gocrdt.NewRGA("a"), "b": gocrdt.NewRGA("b"), "c": gocrdt.NewRGA("c")}
+	logs := map[string]*SessionLog{}
+	for id, doc := range docs {
+		logs[id] = NewSessionLog(id, doc)
+	}
+	keep := docs["a"].Insert('K', root)
+	gone := docs["a"].Insert('X', keep)
+	docs["a"].Delete(gone)
+
+	replica := NewReplica("a", docs["a"], NewNetwork(NetworkConfig{}).Transport("a"), Config{})
+	replica.AddPeer("b")
+	replica.AddPeer("c")
+	stability := NewStability(replica, logs["a"])
+
+	// a syncs with b only: c has not seen the deletion yet.
+	ab := newSessionPair(t, logs["a"], logs["b"])
+	ab.handshake(nil)
+	replica.RecordAck("b", ab.a.PeerVector())
+	if _, ok := stability.Frontier()["a"]; ok {
+		t.Fatal("Expected no stable frontier for a before c acknowledges")
+	}
+	if n := stability.Advance(); n != 0 {
+		t.Fatalf("Compacted %d nodes before the deletion was stable", n)
+	}
+
+	ac := newSessionPair(t, logs["a"], logs["c"])
+	ac.handshake(nil)
+	replica.RecordAck("c", ac.a.PeerVector())
+	if v := stability.Frontier()["a"]; v.Cursor != 3 {
+		t.Errorf("Expected a's log to be stable up to 3, got %+v", v)
+	}
+	if n := stability.Advance(); n != 1 {
+		t.Errorf("Expected the stable tombstone to be collected, got %d", n)
+	}
+	if n := stability.Advance(); n != 0 {
+		t.Errorf("Expected no work without a new frontier, got %d", n)
+	}
+
+	if docs["a"].Value() != "K" || len(docs["a"].Nodes()) != 1 {
+		t.Errorf("Expected only K to remain, got %q with %d nodes", docs["a"].Value(), len(docs["a"].Nodes()))
+	}
+}
+
+func TestSessionLog_Compact(t *testing.T) {
+	root := gocrdt.ID{NodeID: "root"}
+	docs := map[string]*gocrdt.RGA{"a": gocrdt.NewRGA("a"), "b": gocrdt.NewRGA("b"), "c": gocrdt.NewRGA("c")}
+	logs := map[string]*SessionLog{}
+	for id, doc := range docs {
+		logs[id] = NewSessionLog(id, doc)
+	}
+	keep := docs["a"].Insert('K', root)
+	gone := docs["a"].Insert('X', keep)
+	docs["a"].Delete(gone)
+
+	newSessionPair(t, logs["a"], logs["b"]).handshake(nil)
+	before := MeetVectors(logs["b"].Vector(), logs["c"].Vector())
+	if _, err := logs["a"].Compact(before); !errors.Is(err, ErrStaleVector) {
+		t.Fatalf("Expected ErrStaleVector while c has not synced, got %v", err)
+	}
+
+	newSessionPair(t, logs["a"], logs["c"]).handshake(nil)
+	before = MeetVectors(logs["b"].Vector(), logs["c"].Vector())
+	if v := before["a"]; v.Epoch != logs["a"].Epoch() || v.Cursor != 3 {
+		t.Fatalf("Expected a's log to be received up to 3 by both, got %+v", v)
+	}
+	if n, err := logs["a"].Compact(before); err != nil || n != 1 {
+		t.Errorf("Expected the tombstone to be collected, got %d, %v", n, err)
+	}
+	if docs["a"].Value() != "K" || len(docs["a"].Nodes()) != 1 {
+		t.Errorf("Expected only K to remain, got %q with %d nodes", docs["a"].Value(), len(docs["a"].Nodes()))
+	}
+
+	// After a restart the log has a new epoch the vector does not cover.
+	restarted := NewSessionLog("a", docs["a"])
+	if _, err := restarted.Compact(before); !errors.Is(err, ErrStaleVector) {
+		t.Errorf("Expected ErrStaleVector for another epoch, got %v", err)
+	}
+	if n, err := NewSessionLog("a", gocrdt.NewGCounter("a")).Compact(before); n != 0 || err != nil {
+		t.Errorf("Expected counters to compact nothing, got %d, %v", n, err)
+	}
+}
+
+func TestMeetVectors(t *testing.T) {
+	meet := MeetVectors(
+		VersionVector{"a": {Epoch: "1", Cursor: 5}, "b": {Epoch: "1", Cursor: 2}, "c": {Epoch: "1", Cursor: 9}},
+		VersionVector{"a": {Epoch: "1", Cursor: 3}, "b": {Epoch: "2", Cursor: 7}},
+	)
+	if len(meet) != 1 || meet["a"] != (Version{Epoch: "1", Cursor: 3}) {
+		t.Errorf("Unexpected meet %+v", meet)
+	}
+	if len(MeetVectors()) != 0 {
+		t.Error("Expected an empty meet of no vectors")
+	}
+}
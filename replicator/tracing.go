@@ -0,0 +1,77 @@
+package replicator
+
+import "context"
+
+// Attribute is a key-value pair attached to a Span.
+type Attribute struct {
+	Key   string
+	Value any // string, int, int64, uint64, bool or float64
+}
+
+// Tracer creates spans around replication work, for distributed tracing
+// backends such as OpenTelemetry. The interface is a subset of
+// go.opentelemetry.io/otel/trace.Tracer and adapts in a few lines:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, replicator.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttributes(attrs ...replicator.Attribute) {
+//		for _, a := range attrs {
+//			switch v := a.Value.(type) {
+//			case string:
+//				s.Span.SetAttributes(attribute.String(a.Key, v))
+//			case int:
+//				s.Span.SetAttributes(attribute.Int(a.Key, v))
+//			// ...
+//			}
+//		}
+//	}
+//
+//	func (s otelSpan) RecordError(err error) { s.Span.RecordError(err) }
+//	func (s otelSpan) End()                  { s.Span.End() }
+//
+// Spans: gocrdt.sync and gocrdt.gossip for replication rounds,
+// gocrdt.handle for every handled message (peer, kind and merge figures),
+// and, for sync sessions, gocrdt.session.step and gocrdt.session.delta
+// (delta generation: peer, bytes, cursor).
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// noopSpan is used when no Tracer is configured.
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+// startSpan starts a span with t, or a no-op span when t is nil.
+func startSpan(t Tracer, ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := t.Start(ctx, name)
+	span.SetAttributes(attrs...)
+	return ctx, span
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,139 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &recordedSpan{name: name, attrs: make(map[string]any)}
+	t.spans = append(t.spans, span)
+	return ctx, &recordingSpan{t, span}
+}
+
+// named returns the spans called name.
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var spans []*recordedSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+type recordingSpan struct {
+	t    *recordingTracer
+	span *recordedSpan
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...Attribute) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	for _, a := range attrs {
+		s.span.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.span.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.span.ended = true
+}
+
+func TestTracing_ReplicaSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	network := NewNetwork(NetworkConfig{})
+	network.Transport("b")
+	docA := gocrdt.NewRGA("a")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	r := NewReplica("a", docA, network.Transport("a"), Config{Tracer: tracer})
+	r.AddPeer("b")
+
+	if err := r.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	docB := gocrdt.NewRGA("b")
+	docB.Insert('B', gocrdt.ID{NodeID: "root"})
+	payload, _ := docB.MarshalState()
+	if _, err := r.Handle(context.Background(), Message{From: "b", Kind: KindState, Payload: payload}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if _, err := r.Handle(context.Background(), Message{From: "b", Kind: KindState, Payload: []byte("{")}); err == nil {
+		t.Fatal("Expected a malformed payload to fail")
+	}
+
+	syncs := tracer.named("gocrdt.sync")
+	if len(syncs) != 1 || syncs[0].attrs["peers"] != 1 || !syncs[0].ended {
+		t.Errorf("Unexpected sync spans: %+v", syncs)
+	}
+	handles := tracer.named("gocrdt.handle")
+	if len(handles) != 2 {
+		t.Fatalf("Expected 2 handle spans, got %d", len(handles))
+	}
+	if h := handles[0]; h.attrs["peer"] != "b" || h.attrs["kind"] != string(KindState) || h.attrs["applied"] != 1 || h.err != nil || !h.ended {
+		t.Errorf("Unexpected handle span: %+v", h)
+	}
+	if h := handles[1]; h.err == nil || !h.ended {
+		t.Errorf("Expected the failed handle to be recorded, got %+v", h)
+	}
+}
+
+func TestTracing_SessionSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	docA, docB := gocrdt.NewRGA("a"), gocrdt.NewRGA("b")
+	docA.Insert('A', gocrdt.ID{NodeID: "root"})
+	logA, logB := NewSessionLog("a", docA), NewSessionLog("b", docB)
+	logA.SetTracer(tracer)
+	p := newSessionPair(t, logA, logB)
+	p.handshake(nil)
+
+	deltas := tracer.named("gocrdt.session.delta")
+	if len(deltas) != 1 || deltas[0].attrs["peer"] != "b" || deltas[0].attrs["cursor"] != uint64(1) {
+		t.Errorf("Unexpected delta spans: %+v", deltas)
+	}
+	steps := tracer.named("gocrdt.session.step")
+	if len(steps) == 0 {
+		t.Fatal("Expected step spans")
+	}
+	for _, step := range steps {
+		if step.attrs["peer"] != "b" || !step.ended {
+			t.Errorf("Unexpected step span: %+v", step)
+		}
+	}
+
+	s := logA.NewSession("c")
+	if _, _, err := s.Step(SessionMessage{Type: SessionAck}); !errors.Is(err, ErrSessionProtocol) {
+		t.Fatalf("Expected a protocol error, got %v", err)
+	}
+	steps = tracer.named("gocrdt.session.step")
+	if last := steps[len(steps)-1]; last.attrs["peer"] != "c" || last.err == nil {
+		t.Errorf("Expected the failed step to be recorded, got %+v", last)
+	}
+}
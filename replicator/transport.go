@@ -0,0 +1,58 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by a Transport once it has been shut down.
+var ErrClosed = errors.New("replicator: transport closed")
+
+// Kind identifies the purpose of a Message.
+type Kind string
+
+const (
+	// KindState carries the full serialized state of the sender's CRDT.
+	KindState Kind = "state"
+
+	// KindDigest carries a hash of the sender's state. A receiver whose own
+	// digest differs answers with KindPull.
+	KindDigest Kind = "digest"
+
+	// KindPull carries the sender's full state and asks the receiver to
+	// answer with its own (KindState), completing a push-pull exchange.
+	KindPull Kind = "pull"
+
+	// KindTree carries the Merkle tree leaves of a gocrdt.Chunked state. It
+	// replaces KindPull for chunked states: the receiver answers with the
+	// buckets that differ (KindState) and its own tree (KindTreeReply).
+	KindTree Kind = "tree"
+
+	// KindTreeReply closes a Merkle exchange: the receiver answers with
+	// the buckets that differ (KindState) and does not reply further.
+	KindTreeReply Kind = "tree-reply"
+)
+
+// Message is the unit exchanged between replicas through a Transport.
+// The Payload is opaque to the transport; its meaning depends on Kind.
+type Message struct {
+	From    string
+	To      string
+	Kind    Kind
+	Payload []byte
+}
+
+// Transport moves messages between replicas. Implementations decide how
+// peers are addressed and reached (in-process channels, TCP, brokers, ...),
+// the replicator only relies on these two calls.
+//
+// Implementations must be safe for concurrent use: the Replica sends from
+// its sync loop while another goroutine is blocked in Receive.
+type Transport interface {
+	// Send delivers msg to the peer identified by msg.To.
+	Send(ctx context.Context, msg Message) error
+
+	// Receive blocks until a message addressed to the local replica arrives,
+	// ctx is cancelled, or the transport is closed (ErrClosed).
+	Receive(ctx context.Context) (Message, error)
+}
@@ -0,0 +1,388 @@
+package wsync
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxMessageSize bounds a single WebSocket message. Larger frames are
+// rejected before any allocation so a misbehaving peer cannot exhaust memory.
+const MaxMessageSize = 64 << 20
+
+// websocketGUID is the fixed key suffix of the RFC 6455 opening handshake.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes, see RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+var (
+	errTooLarge  = errors.New("wsync: message exceeds MaxMessageSize")
+	errMasking   = errors.New("wsync: frame masking violates the protocol")
+	errFragment  = errors.New("wsync: invalid frame fragmentation")
+	errHandshake = errors.New("wsync: websocket handshake failed")
+)
+
+// conn is one end of a WebSocket connection. Reads must come from a single
+// goroutine; writes may be concurrent.
+type conn struct {
+	nc     net.Conn
+	br     *bufio.Reader
+	client bool // clients mask their frames, servers must not
+
+	// readTimeout, if set, fails a read that sees no frame at all (data or
+	// control) for that long, so a silently dropped peer is detected.
+	readTimeout time.Duration
+
+	wmu sync.Mutex
+}
+
+// acceptKey computes Sec-WebSocket-Accept for a client key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether a comma-separated header contains token,
+// ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgrade performs the server side of the opening handshake and takes over
+// the underlying connection. On failure an HTTP error has been written.
+func upgrade(w http.ResponseWriter, r *http.Request) (*conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") ||
+		key == "" {
+		http.Error(w, "wsync: expected a websocket upgrade", http.StatusBadRequest)
+		return nil, errHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "wsync: unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errHandshake
+	}
+
+	nc, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	// Clear any deadline the HTTP server set for the request.
+	if err := nc.SetDeadline(time.Time{}); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &conn{nc: nc, br: rw.Reader}, nil
+}
+
+// dial opens a client connection to a ws://, wss://, http:// or https://
+// URL and performs the opening handshake.
+func dial(ctx context.Context, rawURL string, header http.Header, tlsConfig *tls.Config) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("wsync: unsupported URL scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var nc net.Conn
+	if secure {
+		config := tlsConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		nc, err = (&tls.Dialer{Config: config}).DialContext(ctx, "tcp", host)
+	} else {
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := handshake(ctx, nc, u, header)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake sends the opening request on nc and validates the response.
+func handshake(ctx context.Context, nc net.Conn, u *url.URL, header http.Header) (*conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := nc.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	// Abort a stalled handshake when ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() { _ = nc.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Host:       u.Host,
+		Header:     make(http.Header),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(nc); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errHandshake, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("%w: bad Sec-WebSocket-Accept", errHandshake)
+	}
+
+	if !stop() || ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err := nc.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, br: br, client: true}, nil
+}
+
+// writeFrame sends one unfragmented frame.
+func (c *conn) writeFrame(op byte, payload []byte) error {
+	return c.writeFragment(op, true, payload)
+}
+
+// writeFragment sends one frame, setting FIN if it ends the message.
+func (c *conn) writeFragment(op byte, fin bool, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = op
+	if fin {
+		header[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header[1] |= 0x80
+		header = append(header, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	buffers := net.Buffers{header}
+	if len(payload) > 0 {
+		buffers = append(buffers, payload)
+	}
+	_, err := buffers.WriteTo(c.nc)
+	return err
+}
+
+// readFrame reads one frame and returns its FIN bit, opcode and unmasked
+// payload.
+func (c *conn) readFrame() (bool, byte, []byte, error) {
+	if c.readTimeout > 0 {
+		if err := c.nc.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op := head[0]&0x80 != 0, head[0]&0x0F
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, errMasking
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, errTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// readMessage returns the next data message, reassembling fragments and
+// answering control frames on the way. A close frame from the peer is
+// acknowledged and reported as io.EOF.
+func (c *conn) readMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		case opText, opBinary:
+			if fragmented {
+				return nil, errFragment
+			}
+			message = payload
+		case opContinuation:
+			if !fragmented {
+				return nil, errFragment
+			}
+			if len(message)+len(payload) > MaxMessageSize {
+				return nil, errTooLarge
+			}
+			message = append(message, payload...)
+		default:
+			return nil, fmt.Errorf("wsync: unknown opcode %#x", op)
+		}
+
+		if fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+// close sends a close frame (best effort) and closes the connection.
+func (c *conn) close() error {
+	_ = c.nc.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeFrame(opClose, nil)
+	return c.nc.Close()
+}
@@ -0,0 +1,89 @@
+package wsync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %q", got)
+	}
+}
+
+func TestUpgrade_RejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := upgrade(rec, httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Fatal("Expected plain GET to be rejected")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestConn_Frames(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	client := &conn{nc: clientSide, br: bufio.NewReader(clientSide), client: true}
+	server := &conn{nc: serverSide, br: bufio.NewReader(serverSide)}
+	defer client.nc.Close()
+	defer server.nc.Close()
+
+	large := bytes.Repeat([]byte("x"), 70000) // 64-bit length encoding
+	go func() {
+		_ = client.writeFrame(opPing, []byte("hi"))
+		// A message split into three fragments.
+		_ = client.writeFragment(opBinary, false, []byte("ab"))
+		_ = client.writeFragment(opContinuation, false, []byte("cd"))
+		_ = client.writeFragment(opContinuation, true, []byte("ef"))
+		_ = client.writeFrame(opBinary, large)
+		_ = client.writeFrame(opClose, nil)
+	}()
+
+	// The ping must be answered while the server reads.
+	pong := make(chan []byte, 1)
+	go func() {
+		_, op, payload, err := client.readFrame()
+		if err == nil && op == opPong {
+			pong <- payload
+		}
+		close(pong)
+	}()
+
+	msg, err := server.readMessage()
+	if err != nil || string(msg) != "abcdef" {
+		t.Fatalf("Expected reassembled \"abcdef\", got %q (%v)", msg, err)
+	}
+	if p := <-pong; string(p) != "hi" {
+		t.Errorf("Expected pong echoing \"hi\", got %q", p)
+	}
+
+	msg, err = server.readMessage()
+	if err != nil || !bytes.Equal(msg, large) {
+		t.Fatalf("Expected %d byte message, got %d (%v)", len(large), len(msg), err)
+	}
+
+	go func() { _, _, _, _ = client.readFrame() }() // drain the close reply
+	if _, err := server.readMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF on close frame, got %v", err)
+	}
+}
+
+func TestConn_RejectsUnmaskedClientFrames(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	fakeClient := &conn{nc: a, br: bufio.NewReader(a)} // does not mask
+	server := &conn{nc: b, br: bufio.NewReader(b)}
+
+	go func() { _ = fakeClient.writeFrame(opBinary, []byte("x")) }()
+	if _, err := server.readMessage(); !errors.Is(err, errMasking) {
+		t.Errorf("Expected errMasking, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+// Package wsync streams RGA changes between replicas in real time over
+// WebSocket connections.
+//
+// Both ends of a connection run the same session: they first send their
+// whole document (so a fresh or reconnecting replica resynchronizes in one
+// round trip), then push every subsequent local or merged change as soon as
+// it happens. Each message is a binary frame holding a MergeState payload,
+// so any RGA can apply it and duplicates are harmless.
+//
+// A Server is an http.Handler that accepts connections for one document.
+// A Client dials a Server and reconnects with backoff whenever the
+// connection drops:
+//
+//	// server
+//	http.Handle("/doc", wsync.NewServer(doc, wsync.Config{}))
+//
+//	// client
+//	c := wsync.NewClient("ws://server:8080/doc", doc, wsync.Config{})
+//	go c.Run(ctx)
+//
+// The WebSocket protocol (RFC 6455) is implemented directly on net/http;
+// extensions and subprotocols are not negotiated.
+package wsync
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// Default configuration values.
+const (
+	DefaultPingInterval        = 15 * time.Second
+	DefaultReconnectBackoff    = 100 * time.Millisecond
+	DefaultMaxReconnectBackoff = 10 * time.Second
+)
+
+// Config tunes a Server or Client. The zero value uses the defaults.
+type Config struct {
+	// PingInterval is how often an idle connection is pinged. A connection
+	// that receives nothing for two intervals is considered dead.
+	PingInterval time.Duration
+
+	// ReconnectBackoff is the delay before the first reconnect attempt; it
+	// doubles after each failed attempt, up to MaxReconnectBackoff.
+	// Client only.
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+
+	// Header is sent with the opening handshake, e.g. for authentication.
+	// Client only.
+	Header http.Header
+
+	// TLSConfig is used for wss:// URLs. Client only.
+	TLSConfig *tls.Config
+
+	// OnError, if set, is called for every connection that ends with an
+	// error, including failed dial attempts.
+	OnError func(error)
+}
+
+func (c Config) withDefaults() Config {
+	if c.PingInterval <= 0 {
+		c.PingInterval = DefaultPingInterval
+	}
+	if c.ReconnectBackoff <= 0 {
+		c.ReconnectBackoff = DefaultReconnectBackoff
+	}
+	if c.MaxReconnectBackoff <= 0 {
+		c.MaxReconnectBackoff = DefaultMaxReconnectBackoff
+	}
+	return c
+}
+
+func (c Config) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// session syncs doc over c until ctx is cancelled or the connection fails.
+// It always closes c.
+func session(ctx context.Context, c *conn, doc *gocrdt.RGA, pingInterval time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.readTimeout = 2 * pingInterval
+
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- push(ctx, c, doc, pingInterval)
+		// Unblock the read loop below.
+		c.close()
+	}()
+
+	var readErr error
+	for {
+		var payload []byte
+		if payload, readErr = c.readMessage(); readErr != nil {
+			break
+		}
+		if _, readErr = doc.MergeState(payload); readErr != nil {
+			break
+		}
+	}
+	cancel()
+	pushErr := <-pushed
+
+	// A clean close by either side, or our own shutdown, is not an error.
+	if errors.Is(readErr, io.EOF) || errors.Is(readErr, net.ErrClosed) {
+		readErr = nil
+	}
+	if errors.Is(pushErr, context.Canceled) || errors.Is(pushErr, net.ErrClosed) {
+		pushErr = nil
+	}
+	return errors.Join(readErr, pushErr)
+}
+
+// push sends the whole document, then every change as it happens, and
+// pings the peer while idle.
+func push(ctx context.Context, c *conn, doc *gocrdt.RGA, pingInterval time.Duration) error {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	var cursor uint64
+	for {
+		// Fetch the channel first so a change racing with Changes is not lost.
+		notify := doc.ChangeNotify()
+		payload, next, err := doc.MarshalChanges(cursor)
+		if err != nil {
+			return err
+		}
+		if payload != nil {
+			if err := c.writeFrame(opBinary, payload); err != nil {
+				return err
+			}
+			ping.Reset(pingInterval)
+		}
+		cursor = next
+
+		select {
+		case <-notify:
+		case <-ping.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Server accepts WebSocket connections and keeps each one in sync with a
+// document.
+type Server struct {
+	doc    *gocrdt.RGA
+	config Config
+
+	mu     sync.Mutex
+	conns  map[*conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer creates a handler that syncs doc with every connecting client.
+func NewServer(doc *gocrdt.RGA, config Config) *Server {
+	return &Server{
+		doc:    doc,
+		config: config.withDefaults(),
+		conns:  make(map[*conn]struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request and runs a sync session on it until the
+// client disconnects or the server is closed.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := upgrade(w, r)
+	if err != nil {
+		s.config.reportError(err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		c.close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	// The request context ends once the connection is hijacked, so the
+	// session is bounded by the connection itself and by Close.
+	if err := session(context.Background(), c, s.doc, s.config.PingInterval); err != nil {
+		s.config.reportError(err)
+	}
+}
+
+// Connections returns the number of open client connections.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Close disconnects every client, waits for their sessions to end and
+// rejects new connections. Clients will keep trying to reconnect.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Client keeps a document in sync with a Server, reconnecting on failure.
+type Client struct {
+	url    string
+	doc    *gocrdt.RGA
+	config Config
+
+	mu        sync.Mutex
+	connected bool
+}
+
+// NewClient creates a client that syncs doc with the server at url
+// (ws://, wss://, http:// or https://).
+func NewClient(url string, doc *gocrdt.RGA, config Config) *Client {
+	return &Client{url: url, doc: doc, config: config.withDefaults()}
+}
+
+// Connected reports whether a session with the server is currently open.
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *Client) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = connected
+}
+
+// Run connects to the server and syncs until ctx is cancelled, which is the
+// only way it returns. Every reconnect starts with a full state exchange,
+// so changes made on either side while disconnected are not lost.
+func (c *Client) Run(ctx context.Context) error {
+	backoff := c.config.ReconnectBackoff
+	for {
+		conn, err := dial(ctx, c.url, c.config.Header, c.config.TLSConfig)
+		if err == nil {
+			backoff = c.config.ReconnectBackoff
+			c.setConnected(true)
+			err = session(ctx, conn, c.doc, c.config.PingInterval)
+			c.setConnected(false)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			c.config.reportError(err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, c.config.MaxReconnectBackoff)
+	}
+}
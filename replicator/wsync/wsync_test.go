@@ -0,0 +1,141 @@
+package wsync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var root = gocrdt.ID{NodeID: "root"}
+
+// waitFor polls cond until it holds or the test deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runClient starts c and returns a function that stops it and checks that
+// Run returned because of the cancellation.
+func runClient(t *testing.T, c *Client) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	return func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected Run to stop with context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestSync_RealTime(t *testing.T) {
+	docServer := gocrdt.NewRGA("server")
+	server := NewServer(docServer, Config{})
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	defer server.Close()
+
+	// Content written before anyone connects is sent as the initial snapshot.
+	docServer.Insert('H', root)
+
+	docA := gocrdt.NewRGA("a")
+	docB := gocrdt.NewRGA("b")
+	stopA := runClient(t, NewClient(srv.URL, docA, Config{}))
+	defer stopA()
+	stopB := runClient(t, NewClient(strings.Replace(srv.URL, "http", "ws", 1), docB, Config{}))
+	defer stopB()
+
+	waitFor(t, "initial snapshot", func() bool { return docA.Value() == "H" && docB.Value() == "H" })
+	waitFor(t, "both sessions", func() bool { return server.Connections() == 2 })
+
+	// An edit on one client reaches the other through the server.
+	nodes := docA.Nodes()
+	docA.Insert('i', nodes[len(nodes)-1].ID)
+	waitFor(t, "edit relay", func() bool { return docB.Value() == "Hi" })
+
+	nodes = docB.Nodes()
+	docB.Delete(nodes[0].ID)
+	waitFor(t, "delete relay", func() bool { return docA.Value() == "i" && docServer.Value() == "i" })
+}
+
+func TestSync_ReconnectResyncs(t *testing.T) {
+	docServer := gocrdt.NewRGA("server")
+	var mu sync.Mutex
+	server := NewServer(docServer, Config{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s := server
+		mu.Unlock()
+		s.ServeHTTP(w, r)
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	docClient := gocrdt.NewRGA("client")
+	client := NewClient(srv.URL, docClient, Config{
+		ReconnectBackoff:    time.Millisecond,
+		MaxReconnectBackoff: 10 * time.Millisecond,
+	})
+	stop := runClient(t, client)
+	defer stop()
+
+	docClient.Insert('A', root)
+	waitFor(t, "first sync", func() bool { return docServer.Value() == "A" })
+
+	// Take the server down; both sides keep editing while disconnected.
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	waitFor(t, "disconnect", func() bool { return !client.Connected() })
+	docClient.Insert('B', docClient.Nodes()[0].ID)
+	docServer.Insert('S', root)
+
+	mu.Lock()
+	server = NewServer(docServer, Config{})
+	mu.Unlock()
+	defer server.Close()
+
+	waitFor(t, "resync after reconnect", func() bool {
+		return docClient.Value() == docServer.Value() && len(docServer.Value().(string)) == 3
+	})
+}
+
+func TestClient_ReportsDialErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	reported := make(chan error, 16)
+	client := NewClient(srv.URL, gocrdt.NewRGA("a"), Config{
+		ReconnectBackoff: time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case reported <- err:
+			default:
+			}
+		},
+	})
+	stop := runClient(t, client)
+	defer stop()
+
+	select {
+	case err := <-reported:
+		if !errors.Is(err, errHandshake) {
+			t.Errorf("Expected a handshake error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the failed handshake to be reported")
+	}
+}
@@ -0,0 +1,82 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResolverMismatch is returned when merging a payload of a ConfigMap
+// resolving writes with another resolver: merged as is, it would make the
+// replicas diverge.
+var ErrResolverMismatch = errors.New("gocrdt: resolver mismatch")
+
+// ErrSuperseded is wrapped by the error of a ConfigMap write that loses to
+// the current value of its key under the map's resolver.
+var ErrSuperseded = errors.New("gocrdt: superseded by the current value")
+
+// configResolver is the resolver set with WithResolver.
+type configResolver[V any] struct {
+	name    string
+	compare func(key string, a, b V) int
+}
+
+// WithResolver makes a ConfigMap resolve the writes of a key by compare
+// before their clocks, as in "the higher semantic version wins" or "the
+// longer string wins". compare returns a positive number when a wins over
+// b, a negative one when b wins, and 0 to fall back to the latest write.
+// It must be deterministic and a total preorder, since every replica runs
+// it on the writes it sees in its own order; a deletion is compared as the
+// zero value.
+//
+// The writes of a key then form a max-register rather than a timeline: a
+// Set that loses to the current value fails with ErrSuperseded, and a
+// Delete that loses does nothing.
+//
+// Every replica must use the same resolver. name identifies it in
+// payloads, and merging a payload of another resolver fails with
+// ErrResolverMismatch. A ConfigMap of another value type than V, and every
+// other type, ignores it.
+func WithResolver[V any](name string, compare func(key string, a, b V) int) Option {
+	return func(o *options) { o.resolver = configResolver[V]{name: name, compare: compare} }
+}
+
+// newer reports whether the write a wins over b: under the resolver, then
+// with a later clock, then the same clock on a greater NodeID.
+func (m *UnsyncConfigMap[V]) newer(a, b configEntry[V]) bool {
+	if m.resolver.compare != nil {
+		if c := m.resolver.compare(a.Key, a.value(), b.value()); c != 0 {
+			return c > 0
+		}
+	}
+	return a.newer(b)
+}
+
+// value returns the value of e as seen by a resolver.
+func (e configEntry[V]) value() V {
+	if e.Deleted {
+		var zero V
+		return zero
+	}
+	return e.Value
+}
+
+// Resolver returns the name of the resolver set with WithResolver, empty
+// for the latest write.
+func (m *UnsyncConfigMap[V]) Resolver() string {
+	return m.resolver.name
+}
+
+// Resolver returns the name of the resolver set with WithResolver, empty
+// for the latest write.
+func (m *ConfigMap[V]) Resolver() string {
+	return m.m.Resolver()
+}
+
+// useResolver checks the resolver of a payload of n entries against the
+// map's.
+func (m *UnsyncConfigMap[V]) useResolver(name string, n int) error {
+	if name == m.resolver.name || n == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: the map resolves writes by %q, the payload by %q", ErrResolverMismatch, m.resolver.name, name)
+}
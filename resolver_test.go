@@ -0,0 +1,73 @@
+package gocrdt
+
+import (
+	"cmp"
+	"errors"
+	"testing"
+)
+
+// longest makes the longer string win.
+func longest(_ string, a, b string) int {
+	return cmp.Compare(len(a), len(b))
+}
+
+func TestConfigMap_Resolver(t *testing.T) {
+	newMap := func(id string) *ConfigMap[string] {
+		return NewConfigMap[string](id, nil, WithResolver("longest", longest))
+	}
+	a, b, c := newMap("a"), newMap("b"), newMap("c")
+
+	// b writes last, but a's longer value wins on every replica whatever
+	// the order of the merges.
+	_ = a.Set("motd", "hello world")
+	_ = c.Set("motd", "hi")
+	_ = b.Set("motd", "hey")
+	_ = b.Set("motd", "hey!")
+	a.Merge(b)
+	a.Merge(c)
+	c.Merge(b)
+	state, _ := a.MarshalState()
+	if _, err := c.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	b.Merge(c)
+	for _, m := range []*ConfigMap[string]{a, b, c} {
+		if v, _ := m.Get("motd"); v != "hello world" {
+			t.Errorf("Expected the longest value to win, got %q", v)
+		}
+	}
+
+	// Equal lengths fall back to the latest write.
+	_ = b.Set("motd", "HELLO WORLD")
+	a.Merge(b)
+	if v, _ := a.Get("motd"); v != "HELLO WORLD" {
+		t.Errorf("Expected the latest write to win a tie, got %q", v)
+	}
+
+	// Losing writes are refused locally too.
+	if err := a.Set("motd", "bye"); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Expected ErrSuperseded, got %v", err)
+	}
+	a.Delete("motd")
+	if v, ok := a.Get("motd"); !ok || v != "HELLO WORLD" {
+		t.Errorf("Expected the delete to lose, got %q, %v", v, ok)
+	}
+}
+
+func TestConfigMap_ResolverMismatch(t *testing.T) {
+	a := NewConfigMap[string]("a", nil, WithResolver("longest", longest))
+	b := NewConfigMap[string]("b", nil)
+	_ = b.Set("motd", "hi")
+	state, _ := b.MarshalState()
+	if _, err := a.MergeState(state); !errors.Is(err, ErrResolverMismatch) {
+		t.Errorf("Expected ErrResolverMismatch, got %v", err)
+	}
+	if result := a.Merge(b); result.Rejected != 1 || len(a.Keys()) != 0 {
+		t.Errorf("Expected the merge rejected, got %+v", result)
+	}
+
+	// A resolver of another value type does not apply.
+	if m := NewConfigMap[int]("c", nil, WithResolver("longest", longest)); m.Resolver() != "" {
+		t.Errorf("Expected no resolver, got %q", m.Resolver())
+	}
+}
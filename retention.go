@@ -0,0 +1,151 @@
+package gocrdt
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy bounds the history a CRDT keeps, such as the tombstones
+// of an RGA, which time travel (Since, MarshalSince, history browsing)
+// needs but which otherwise grow without bound. History is kept while it
+// is younger than MaxAge or among the last MaxVersions changes (inserts
+// and deletions): it is discarded once past every bound set. A zero bound
+// is unset, so the zero policy keeps everything.
+type RetentionPolicy struct {
+	MaxAge      time.Duration
+	MaxVersions uint64
+}
+
+// Compactable is implemented by the CRDTs a Retention compacts: RGA and
+// UnsyncRGA, whose Changes cursor counts versions.
+type Compactable interface {
+	Changes(since uint64) ([]Node, uint64)
+	CompactStable(cursor uint64) int
+}
+
+// retentionMark is the version a document had reached at a time.
+type retentionMark struct {
+	at     time.Time
+	cursor uint64
+}
+
+// retained is a document under a RetentionPolicy.
+type retained struct {
+	doc     Compactable
+	policy  RetentionPolicy
+	marks   []retentionMark // Ascending
+	applied uint64          // Last cursor compacted to
+}
+
+// Retention enforces RetentionPolicies on documents, each with its own
+// policy, from a background routine:
+//
+//	retention := NewRetention()
+//	retention.Add("notes", notes, RetentionPolicy{MaxAge: 7 * 24 * time.Hour})
+//	retention.Add("chat", chat, RetentionPolicy{MaxVersions: 10_000})
+//	go retention.Run(ctx, time.Minute)
+//
+// Ages are measured from the rounds of Enforce: a change counts as older
+// than MaxAge once a round older than MaxAge saw it, so they are accurate
+// to the interval of Run.
+//
+// Unlike Stability in package replicator, a policy does not wait for peers
+// to acknowledge what it discards: a replica that has not received a
+// deletion before it is compacted away never will, and keeps the node. Pick
+// bounds every replica syncs within, or use a stability frontier where the
+// replica set is known. Retention is safe for concurrent use.
+type Retention struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	docs map[string]*retained
+}
+
+// NewRetention creates a Retention without documents.
+func NewRetention() *Retention {
+	return &Retention{now: time.Now, docs: make(map[string]*retained)}
+}
+
+// Add puts doc under policy, as name, replacing the document or policy
+// previously added as name.
+func (r *Retention) Add(name string, doc Compactable, policy RetentionPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.docs[name]; ok && old.doc == doc {
+		old.policy = policy
+		return
+	}
+	r.docs[name] = &retained{doc: doc, policy: policy}
+}
+
+// Remove stops enforcing the policy of name.
+func (r *Retention) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.docs, name)
+}
+
+// Enforce runs one round: it compacts the history of every document past
+// its policy, and returns the number of items discarded by name.
+func (r *Retention) Enforce() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	discarded := make(map[string]int)
+	for name, d := range r.docs {
+		if n := d.enforce(now); n > 0 {
+			discarded[name] = n
+		}
+	}
+	return discarded
+}
+
+// enforce records the version d has reached at now and compacts it to the
+// cursor its policy allows.
+func (d *retained) enforce(now time.Time) int {
+	_, head := d.doc.Changes(math.MaxUint64)
+	if n := len(d.marks); n == 0 || d.marks[n-1].cursor != head {
+		d.marks = append(d.marks, retentionMark{now, head})
+	}
+	if d.policy.MaxAge <= 0 && d.policy.MaxVersions == 0 {
+		return 0
+	}
+
+	cursor := uint64(math.MaxUint64)
+	if d.policy.MaxVersions > 0 {
+		cursor = head - min(head, d.policy.MaxVersions)
+	}
+	if d.policy.MaxAge > 0 {
+		// The last mark older than MaxAge: everything logged before it is.
+		i := sort.Search(len(d.marks), func(i int) bool { return now.Sub(d.marks[i].at) < d.policy.MaxAge })
+		aged := uint64(0)
+		if i > 0 {
+			aged = d.marks[i-1].cursor
+			d.marks = d.marks[i-1:]
+		}
+		cursor = min(cursor, aged)
+	}
+	if cursor <= d.applied {
+		return 0
+	}
+	d.applied = cursor
+	return d.doc.CompactStable(cursor)
+}
+
+// Run calls Enforce every interval until ctx is done, then returns
+// ctx.Err().
+func (r *Retention) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			r.Enforce()
+		}
+	}
+}
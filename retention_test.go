@@ -0,0 +1,75 @@
+package gocrdt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetention_MaxVersions(t *testing.T) {
+	doc := NewRGA("a")
+	root := ID{0, "root"}
+	x := doc.Insert('x', root)
+	doc.Delete(x)
+	retention := NewRetention()
+	retention.Add("doc", doc, RetentionPolicy{MaxVersions: 2})
+
+	// The deletion is among the last 2 changes.
+	if n := retention.Enforce()["doc"]; n != 0 {
+		t.Fatalf("Expected nothing discarded, got %d", n)
+	}
+	y := doc.Insert('y', root)
+	doc.Insert('z', y)
+	if n := retention.Enforce()["doc"]; n != 1 || len(doc.Nodes()) != 2 {
+		t.Errorf("Expected the tombstone discarded, got %d and %d nodes", n, len(doc.Nodes()))
+	}
+}
+
+func TestRetention_MaxAge(t *testing.T) {
+	now := time.Unix(0, 0)
+	doc := NewRGA("a")
+	root := ID{0, "root"}
+	retention := NewRetention()
+	retention.now = func() time.Time { return now }
+	retention.Add("doc", doc, RetentionPolicy{MaxAge: time.Hour, MaxVersions: 1})
+
+	doc.Delete(doc.Insert('x', root))
+	retention.Enforce()
+	doc.Delete(doc.Insert('y', root))
+	now = now.Add(30 * time.Minute)
+	if n := retention.Enforce()["doc"]; n != 0 {
+		t.Fatalf("Expected young tombstones kept, got %d discarded", n)
+	}
+
+	// x was seen by a round an hour ago, y only by one 30 minutes ago.
+	now = now.Add(30 * time.Minute)
+	if n := retention.Enforce()["doc"]; n != 1 || doc.Nodes()[0].Value != 'y' {
+		t.Errorf("Expected x discarded, got %d with %+v", n, doc.Nodes())
+	}
+	// y's deletion, the last version, is kept until another change.
+	now = now.Add(time.Hour)
+	if n := retention.Enforce()["doc"]; n != 0 {
+		t.Errorf("Expected the last version kept, got %d discarded", n)
+	}
+	doc.Insert('w', root)
+	if n := retention.Enforce()["doc"]; n != 1 || len(doc.Nodes()) != 1 {
+		t.Errorf("Expected y discarded, got %d with %+v", n, doc.Nodes())
+	}
+
+	retention.Remove("doc")
+	doc.Delete(doc.Insert('z', root))
+	now = now.Add(24 * time.Hour)
+	retention.Enforce()
+	if len(doc.Nodes()) != 2 {
+		t.Error("Expected a removed document left alone")
+	}
+}
+
+func TestRetention_ZeroPolicy(t *testing.T) {
+	doc := NewRGA("a")
+	doc.Delete(doc.Insert('x', ID{0, "root"}))
+	retention := NewRetention()
+	retention.Add("doc", doc, RetentionPolicy{})
+	if n := retention.Enforce()["doc"]; n != 0 || len(doc.Nodes()) != 1 {
+		t.Errorf("Expected the zero policy to keep everything, got %d", n)
+	}
+}
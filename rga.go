@@ -1,6 +1,17 @@
 package gocrdt
 
-import "sync"
+import (
+	"context"
+	"sync/atomic"
+)
+
+// mergeChunkSize is the number of remote nodes MergeContext integrates
+// while holding the write lock before yielding to other goroutines.
+const mergeChunkSize = 512
+
+// snapshotMergeSize is the payload size from which merges publish the
+// rendering of the document before and after they run, see RGA.MergeChecked.
+const snapshotMergeSize = 64
 
 // ID represents a unique identifier for an element in the RGA.
 // It uses a Lamport Timestamp combined with a unique NodeID to establish
@@ -30,46 +41,67 @@ type Node struct {
 	ParentID ID    // The ID of the node this element was inserted after
 	Value    rune  // The actual character or data value
 	Deleted  bool  // Tombstone flag to mark logical deletion
-	Next     *Node // Pointer to the next node in the linearized view
+	Next     *Node `json:"-"` // Pointer to the next node in the linearized view
 }
 
-// RGA is a Replicated Growable Array CRDT designed for collaborative
-// sequence editing.
+// UnsyncRGA is the unsynchronized core of an RGA.
 //
-// RGA uses a Linked-List structure to represent the document and a
-// Hash Map (registry) to provide O(1) random access to any node by its ID.
-// This hybrid approach allows for high-performance insertions and
-// deletions in large documents.
-type RGA struct {
-	mu             sync.RWMutex
+// It holds the full document state and merge logic but performs no
+// locking, which makes it suitable for actor or event-loop architectures
+// where a single goroutine owns the document. Concurrent use of an UnsyncRGA
+// is a data race; use RGA when the document is shared between goroutines.
+type UnsyncRGA struct {
 	nodeID         string
 	clock          int64
 	registry       map[ID]*Node
 	root           *Node
-	pendingOrphans map[ID][]Node // Buffer for causal consistency
+	pendingOrphans map[ID][]Node  // Buffer for causal consistency
+	changes        []ID           // Change log, see Changes
+	collected      map[ID]ID      // Tombstones removed by CompactStable, to their parent
+	validator      Validator      // Checks remote payloads, see SetValidator
+	logger         Logger         // See SetLogger
+	onOp           func(Op)       // See OnOp
+	codec          Codec          // See WithCodec
+	maxOrphans     int            // See WithMaxOrphans
+	orphans        int            // Nodes in pendingOrphans
+	log            string         // Identifies the change log, see Version
+	requests       requestLog[ID] // See InsertOnce
+	tieBreak       TieBreak       // See WithTieBreak
+	onResolved     func(ID, ID)   // See OnOrphanResolved
+	onStuck        func([]ID)     // See OnOrphanStuck
+	buffered       bool           // The current merge buffered orphans
+
+	journal *ConflictJournal // See WithConflictJournal
 }
 
-// NewRGA initializes a new RGA instance for a given node.
-// It creates a sentinel "root" node which serves as the anchor
-// for the beginning of the sequence.
-func NewRGA(nodeID string) *RGA {
+// NewUnsyncRGA initializes a new UnsyncRGA instance for a given node.
+// Like NewRGA, it creates the sentinel "root" node anchoring the sequence.
+// It accepts the options of NewRGA but WithLocking: an UnsyncRGA never
+// locks.
+func NewUnsyncRGA(nodeID string, opts ...Option) *UnsyncRGA {
+	o := newOptions(opts)
 	rootID := ID{0, "root"}
 	rootNode := &Node{ID: rootID}
-	return &RGA{
+	return &UnsyncRGA{
 		nodeID:         nodeID,
+		clock:          o.clock,
 		registry:       map[ID]*Node{rootID: rootNode},
 		root:           rootNode,
 		pendingOrphans: make(map[ID][]Node),
+		codec:          o.codec,
+		maxOrphans:     o.maxOrphans,
+		logger:         o.logger,
+		log:            newLogID(),
+		requests:       newRequestLog[ID](o),
+		tieBreak:       o.tieBreak,
+		journal:        o.journal,
 	}
 }
 
 // Insert creates a new element in the sequence after the specified
-// parentID. It increments the local logical clock and integrates
-// the new node into the local state.
-func (r *RGA) Insert(val rune, parentID ID) ID {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
+// parentID. See RGA.Insert.
+func (r *UnsyncRGA) Insert(val rune, parentID ID) ID {
+	r.resurrect(parentID)
 	r.clock++
 	newID := ID{r.clock, r.nodeID}
 	newNode := &Node{
@@ -79,46 +111,78 @@ func (r *RGA) Insert(val rune, parentID ID) ID {
 	}
 
 	r.integrate(newNode)
+	r.emit(Op{Kind: OpInsert, ID: newID, Parent: parentID, Value: val})
 	return newID
 }
 
 // Delete marks a node as logically deleted (a "Tombstone").
-// Nodes are not physically removed from the registry or linked-list
-// to ensure that concurrent operations referencing this node can
-// still be resolved correctly.
-func (r *RGA) Delete(id ID) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if node, exists := r.registry[id]; exists {
+// See RGA.Delete.
+func (r *UnsyncRGA) Delete(id ID) {
+	r.delete(id)
+}
+
+// delete tombstones the node and emits the delete op when this changed it.
+func (r *UnsyncRGA) delete(id ID) bool {
+	if !r.tombstone(id) {
+		return false
+	}
+	node := r.registry[id]
+	r.emit(Op{Kind: OpDelete, ID: id, Parent: node.ParentID, Value: node.Value})
+	return true
+}
+
+// tombstone marks the node as deleted and reports whether this changed it.
+// The root cannot be deleted.
+func (r *UnsyncRGA) tombstone(id ID) bool {
+	if node, exists := r.registry[id]; exists && !node.Deleted && node != r.root {
 		node.Deleted = true
+		r.changes = append(r.changes, id)
+		return true
 	}
+	return false
 }
 
-// Merge incorporates remote state into the local RGA.
-//
-// It handles deduplication of nodes and ensures Causal Consistency
-// by buffering "orphan" nodes whose parents have not yet arrived
-// from the network. Once a missing parent is integrated, its
-// buffered children are automatically processed.
-func (r *RGA) Merge(remoteNodes []Node) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// Merge incorporates remote state into the local document.
+// See RGA.Merge for the semantics and the returned MergeResult.
+func (r *UnsyncRGA) Merge(remoteNodes []Node) MergeResult {
+	result, _ := r.MergeChecked(remoteNodes)
+	return result
+}
 
-	for _, n := range remoteNodes {
-		if _, exists := r.registry[n.ID]; exists {
-			if n.Deleted {
-				r.registry[n.ID].Deleted = true
-			}
-			continue
+// processNode handles the causal dependency logic during a merge.
+// Known nodes only propagate their tombstone; if a node's parent is missing,
+// the node is moved to the pendingOrphans buffer. Malformed nodes, which no
+// replica can have created, are rejected: they would break the ordering
+// integrate relies on, or the root itself.
+func (r *UnsyncRGA) processNode(n Node, result *MergeResult) {
+	if n.ID == r.root.ID {
+		r.reject(n, "claims the root's ID", result)
+		return
+	}
+	if _, collected := r.collected[n.ID]; collected {
+		if r.logger != nil {
+			r.logger.Debug("gocrdt: dropped re-delivered node of a collected tombstone", "node", n.ID)
 		}
-		r.processNode(n)
+		result.Duplicates++
+		return
+	}
+	r.resurrect(n.ParentID)
+	if existing, exists := r.registry[n.ID]; exists {
+		if n.Deleted && !existing.Deleted {
+			existing.Deleted = true
+			r.changes = append(r.changes, n.ID)
+			result.Deleted++
+		} else {
+			result.Duplicates++
+		}
+		return
 	}
-}
 
-// processNode handles the causal dependency logic during a merge.
-// If a node's parent is missing, the node is moved to the pendingOrphans buffer.
-func (r *RGA) processNode(n Node) {
-	if _, parentExists := r.registry[n.ParentID]; parentExists {
+	if parent, parentExists := r.registry[n.ParentID]; parentExists {
+		if n.ID.Timestamp <= parent.ID.Timestamp {
+			r.reject(n, "is not newer than its parent", result)
+			return
+		}
 		newNode := &Node{
 			ID:       n.ID,
 			ParentID: n.ParentID,
@@ -126,30 +190,78 @@ func (r *RGA) processNode(n Node) {
 			Deleted:  n.Deleted,
 		}
 		r.integrate(newNode)
+		result.Applied++
 
-		if orphans, ok := r.pendingOrphans[n.ID]; ok {
+		if orphans := r.release(n.ID); orphans != nil {
+			if r.logger != nil {
+				r.logger.Debug("gocrdt: released buffered orphans", "parent", n.ID, "count", len(orphans))
+			}
 			for _, child := range orphans {
-				r.processNode(child)
+				r.processNode(child, result)
 			}
-			delete(r.pendingOrphans, n.ID)
 		}
 	} else {
+		if r.maxOrphans > 0 && r.orphans >= r.maxOrphans {
+			if r.logger != nil {
+				r.logger.Warn("gocrdt: dropped orphan past the buffer bound", "node", n.ID, "parent", n.ParentID, "bound", r.maxOrphans)
+			}
+			result.Rejected++
+			return
+		}
 		r.pendingOrphans[n.ParentID] = append(r.pendingOrphans[n.ParentID], n)
+		r.orphans++
+		r.buffered = true
+		result.Orphaned++
+		if r.logger != nil {
+			r.logger.Debug("gocrdt: buffered orphan", "node", n.ID, "parent", n.ParentID)
+		}
 	}
 }
 
+// release removes the orphans buffered for parent from the buffer and
+// returns them.
+func (r *UnsyncRGA) release(parent ID) []Node {
+	orphans, ok := r.pendingOrphans[parent]
+	if !ok {
+		return nil
+	}
+	delete(r.pendingOrphans, parent)
+	r.orphans -= len(orphans)
+	if r.onResolved != nil {
+		for _, n := range orphans {
+			r.onResolved(n.ID, parent)
+		}
+	}
+	return orphans
+}
+
+// reject counts a malformed remote node as rejected.
+func (r *UnsyncRGA) reject(n Node, reason string, result *MergeResult) {
+	if r.logger != nil {
+		r.logger.Warn("gocrdt: rejected malformed node "+reason, "node", n.ID, "parent", n.ParentID)
+	}
+	result.Rejected++
+}
+
 // integrate executes the deterministic pointer-linking math.
 // It ensures that siblings (nodes sharing the same parent) are
 // ordered by their IDs, guaranteeing that all replicas converge
 // to the same linear sequence.
-func (r *RGA) integrate(newNode *Node) {
+//
+// The new node goes before its first smaller sibling, after the whole
+// subtree of every greater one. Since a node's timestamp always exceeds its
+// parent's, everything in those subtrees is greater than the new node,
+// while the first node past them (a smaller sibling, or a node outside the
+// parent's subtree) is smaller: skipping greater IDs skips exactly them.
+// IDs compare by timestamp first, then by the document's TieBreak.
+func (r *UnsyncRGA) integrate(newNode *Node) {
 	parent := r.registry[newNode.ParentID]
 
 	prev := parent
 	current := parent.Next
-	for current != nil && current.ParentID == newNode.ParentID {
-		if newNode.ID.Greater(current.ID) {
-			break
+	for current != nil && r.greater(current.ID, newNode.ID) {
+		if r.journal != nil && current.ParentID == newNode.ParentID && current.ID.Timestamp >= newNode.ID.Timestamp {
+			r.orderConflict(current, newNode)
 		}
 		prev = current
 		current = current.Next
@@ -158,18 +270,38 @@ func (r *RGA) integrate(newNode *Node) {
 	newNode.Next = current
 	prev.Next = newNode
 	r.registry[newNode.ID] = newNode
+	r.changes = append(r.changes, newNode.ID)
 
 	if newNode.ID.Timestamp > r.clock {
 		r.clock = newNode.ID.Timestamp
 	}
 }
 
+// last returns the last node of the sequence, tombstones included, or the
+// root of an empty one.
+func (r *UnsyncRGA) last() *Node {
+	n := r.root
+	for n.Next != nil {
+		n = n.Next
+	}
+	return n
+}
+
+// Nodes returns a copy of every node in the sequence, including tombstones,
+// in linearized order. See RGA.Nodes.
+func (r *UnsyncRGA) Nodes() []Node {
+	nodes := make([]Node, 0, len(r.registry)-1)
+	for curr := r.root.Next; curr != nil; curr = curr.Next {
+		n := *curr
+		n.Next = nil
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
 // Value returns the linearized, visible text of the sequence.
-// It traverses the internal linked-list and filters out nodes
-// marked as deleted (tombstones). This satisfies the CRDT interface.
-func (r *RGA) Value() any {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// Unlike RGA.Value the rendering is not cached.
+func (r *UnsyncRGA) Value() any {
 	var chars []rune
 	curr := r.root.Next
 	for curr != nil {
@@ -180,3 +312,152 @@ func (r *RGA) Value() any {
 	}
 	return string(chars)
 }
+
+// RGA is a Replicated Growable Array CRDT designed for collaborative
+// sequence editing.
+//
+// RGA uses a Linked-List structure to represent the document and a
+// Hash Map (registry) to provide O(1) random access to any node by its ID.
+// This hybrid approach allows for high-performance insertions and
+// deletions in large documents.
+//
+// RGA is safe for concurrent use; it wraps an UnsyncRGA with a read/write
+// mutex, or the lock chosen with WithLocking.
+type RGA struct {
+	mu  rwLocker // See WithLocking
+	doc UnsyncRGA
+
+	// rendered caches the immutable output of Value(). It is dropped on
+	// every mutation and rebuilt lazily by the next reader, so hot readers
+	// are served lock-free in O(1).
+	rendered atomic.Pointer[string]
+
+	// notify is closed and replaced on every mutation, see ChangeNotify.
+	notify chan struct{}
+}
+
+// NewRGA initializes a new RGA instance for a given node.
+// It creates a sentinel "root" node which serves as the anchor
+// for the beginning of the sequence. See Option for the settings opts
+// can tune.
+func NewRGA(nodeID string, opts ...Option) *RGA {
+	return &RGA{
+		mu:     newLocker(newOptions(opts).locking),
+		doc:    *NewUnsyncRGA(nodeID, opts...),
+		notify: make(chan struct{}),
+	}
+}
+
+// Insert creates a new element in the sequence after the specified
+// parentID. It increments the local logical clock and integrates
+// the new node into the local state. parentID must be a node of the
+// document; InsertE checks it.
+func (r *RGA) Insert(val rune, parentID ID) ID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.doc.Insert(val, parentID)
+	r.changed()
+	return id
+}
+
+// Delete marks a node as logically deleted (a "Tombstone").
+// Nodes are not physically removed from the registry or linked-list
+// to ensure that concurrent operations referencing this node can
+// still be resolved correctly. Unknown IDs and the root are ignored;
+// DeleteE reports them.
+func (r *RGA) Delete(id ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.doc.delete(id) {
+		r.changed()
+	}
+}
+
+// Merge incorporates remote state into the local RGA.
+//
+// It handles deduplication of nodes and ensures Causal Consistency
+// by buffering "orphan" nodes whose parents have not yet arrived
+// from the network. Once a missing parent is integrated, its
+// buffered children are automatically processed.
+//
+// The returned MergeResult reports how many nodes were integrated (including
+// previously buffered orphans released by this merge), how many were already
+// known, how many were buffered as orphans and how many tombstones were applied.
+// A payload rejected by the Validator (see SetValidator) is counted as
+// Rejected; use MergeChecked to get the reason.
+func (r *RGA) Merge(remoteNodes []Node) MergeResult {
+	result, _ := r.MergeChecked(remoteNodes)
+	return result
+}
+
+// MergeContext is a cancellable variant of Merge intended for large remote
+// states.
+//
+// The remote nodes are integrated in chunks of mergeChunkSize. The write lock
+// is released between chunks so that local edits and readers stay responsive,
+// and ctx is checked before each chunk. If ctx is cancelled, the nodes merged
+// so far remain integrated and ctx.Err() is returned alongside the partial
+// result; since merging is idempotent the caller may simply retry later.
+// Each chunk is validated on its own, and a rejected chunk stops the merge
+// with the validator's error.
+func (r *RGA) MergeContext(ctx context.Context, remoteNodes []Node) (MergeResult, error) {
+	var result MergeResult
+	for start := 0; start < len(remoteNodes); start += mergeChunkSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		end := min(start+mergeChunkSize, len(remoteNodes))
+		chunk, err := r.MergeChecked(remoteNodes[start:end])
+		result.Add(chunk)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Nodes returns a copy of every node in the sequence, including tombstones,
+// in linearized order. Parents always precede their children, so the
+// result can be handed to Merge on another replica without producing orphans.
+// The sentinel root node is not included and the Next pointers are cleared.
+func (r *RGA) Nodes() []Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Nodes()
+}
+
+// changed drops the cached rendering and wakes up ChangeNotify waiters.
+// It must be called with the write lock held, after any mutation.
+func (r *RGA) changed() {
+	r.rendered.Store(nil)
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// Value returns the linearized, visible text of the sequence.
+// It traverses the internal linked-list and filters out nodes
+// marked as deleted (tombstones). This satisfies the CRDT interface.
+//
+// The rendering is cached until the next mutation, so repeated reads of an
+// unchanged document do not take the lock or traverse the list.
+func (r *RGA) Value() any {
+	if cached := r.rendered.Load(); cached != nil {
+		return *cached
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.render()
+}
+
+// render returns the cached rendering, building it first if it was dropped.
+// It must be called with the lock held.
+func (r *RGA) render() string {
+	if cached := r.rendered.Load(); cached != nil {
+		return *cached
+	}
+	text := r.doc.Value().(string)
+	r.rendered.Store(&text)
+	return text
+}
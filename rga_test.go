@@ -1,7 +1,10 @@
 package gocrdt
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRGA_FullLifeCycle(t *testing.T) {
@@ -73,7 +76,7 @@ func TestRGA_TimestampPriority(t *testing.T) {
 
 	// 1. Setup: Both have "H"
 	idH := alice.Insert('H', rootID)
-	bob.Merge([]Node{*alice.registry[idH]})
+	bob.Merge([]Node{*alice.doc.registry[idH]})
 
 	// 2. Alice performs TWO operations to push her local clock forward
 	// Alice: H -> X -> A (Timestamp for 'A' will be higher)
@@ -123,7 +126,7 @@ func TestRGA_Tombstones(t *testing.T) {
 	if r.Value() != "" {
 		t.Errorf("Expected empty string, got %s", r.Value())
 	}
-	if len(r.registry) != 2 { // root + A
+	if len(r.doc.registry) != 2 { // root + A
 		t.Errorf("Registry should keep tombstones")
 	}
 }
@@ -158,14 +161,250 @@ func TestRGA_RemoteDeletionPropagation(t *testing.T) {
 		t.Errorf("Remote deletion failed to propagate: Bob still has %s", bob.Value())
 	}
 
-	if node, exists := bob.registry[idI]; !exists || !node.Deleted {
+	if node, exists := bob.doc.registry[idI]; !exists || !node.Deleted {
 		t.Error("Bob's registry entry for 'i' should exist and be marked as Deleted")
 	}
 }
 
+func TestRGA_MergeResult(t *testing.T) {
+	r := NewRGA("client")
+	rootID := ID{0, "root"}
+
+	parentID := ID{Timestamp: 1, NodeID: "server"}
+	childID := ID{Timestamp: 2, NodeID: "server"}
+	parent := Node{ID: parentID, ParentID: rootID, Value: 'P'}
+	child := Node{ID: childID, ParentID: parentID, Value: 'C'}
+
+	result := r.Merge([]Node{child})
+	if result.Orphaned != 1 || result.Applied != 0 {
+		t.Errorf("Expected child to be buffered, got %+v", result)
+	}
+
+	result = r.Merge([]Node{parent})
+	if result.Applied != 2 {
+		t.Errorf("Expected parent and released orphan to be applied, got %+v", result)
+	}
+
+	parent.Deleted = true
+	result = r.Merge([]Node{parent, child})
+	if result.Deleted != 1 || result.Duplicates != 1 {
+		t.Errorf("Expected one tombstone and one duplicate, got %+v", result)
+	}
+	if r.Value() != "C" {
+		t.Errorf("Expected C, got %s", r.Value())
+	}
+}
+
+func TestRGA_MergeContext(t *testing.T) {
+	source := NewRGA("alice")
+	parent := ID{0, "root"}
+	for i := 0; i < mergeChunkSize*2+10; i++ {
+		parent = source.Insert('x', parent)
+	}
+
+	r := NewRGA("bob")
+	result, err := r.MergeContext(context.Background(), getNodes(source))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Applied != mergeChunkSize*2+10 {
+		t.Errorf("Expected all nodes applied, got %+v", result)
+	}
+	if r.Value() != source.Value() {
+		t.Errorf("Divergence after chunked merge")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := NewRGA("carol")
+	result, err = cancelled.MergeContext(ctx, getNodes(source))
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if result.Applied != 0 || cancelled.Value() != "" {
+		t.Errorf("Cancelled merge should not apply nodes, got %+v", result)
+	}
+}
+
+func TestRGA_Unsync(t *testing.T) {
+	alice := NewUnsyncRGA("alice")
+	bob := NewRGA("bob")
+	rootID := ID{0, "root"}
+
+	idH := alice.Insert('H', rootID)
+	alice.Insert('i', idH)
+
+	// Unsynchronized -> synchronized
+	bob.Merge(getUnsyncNodes(alice))
+	if bob.Value() != "Hi" {
+		t.Fatalf("Expected Hi on Bob, got %s", bob.Value())
+	}
+
+	// Synchronized -> unsynchronized
+	bob.Insert('!', ID{2, "alice"})
+	alice.Merge(getNodes(bob))
+
+	if alice.Value() != "Hi!" || bob.Value() != "Hi!" {
+		t.Errorf("Expected Hi! on both replicas, got Alice: %s, Bob: %s", alice.Value(), bob.Value())
+	}
+}
+
+func TestRGA_ValueCacheInvalidation(t *testing.T) {
+	r := NewRGA("alice")
+	idA := r.Insert('A', ID{0, "root"})
+	if r.Value() != "A" {
+		t.Fatalf("Expected A, got %s", r.Value())
+	}
+
+	r.Insert('B', idA)
+	if r.Value() != "AB" {
+		t.Errorf("Cache not invalidated on insert, got %s", r.Value())
+	}
+
+	r.Delete(idA)
+	if r.Value() != "B" {
+		t.Errorf("Cache not invalidated on delete, got %s", r.Value())
+	}
+
+	remote := Node{ID: ID{10, "bob"}, ParentID: ID{0, "root"}, Value: 'C'}
+	r.Merge([]Node{remote})
+	if r.Value() != "CB" {
+		t.Errorf("Cache not invalidated on merge, got %s", r.Value())
+	}
+}
+
+func TestRGA_ValueServedFromCache(t *testing.T) {
+	r := NewRGA("alice")
+	r.Insert('A', ID{0, "root"})
+	_ = r.Value()
+
+	first := r.rendered.Load()
+	_ = r.Value()
+	if first == nil || r.rendered.Load() != first {
+		t.Fatal("Expected repeated reads to reuse the cached rendering")
+	}
+
+	// A cached read must not need the lock, even while a writer holds it.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	done := make(chan any)
+	go func() {
+		done <- r.Value()
+	}()
+	select {
+	case value := <-done:
+		if value != "A" {
+			t.Errorf("Expected A, got %v", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Cached read blocked on the write lock")
+	}
+}
+
+func TestRGA_ConcurrentReaders(t *testing.T) {
+	r := NewRGA("alice")
+	parent := ID{0, "root"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = r.Value()
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		parent = r.Insert('x', parent)
+	}
+	wg.Wait()
+
+	if len(r.Value().(string)) != 100 {
+		t.Errorf("Expected 100 characters, got %d", len(r.Value().(string)))
+	}
+}
+
+// chunkCancelContext reports cancellation once Err has been consulted
+// `allowed` times, and runs onCheck on every consultation. MergeContext
+// consults Err between chunks, which lets tests act deterministically at a
+// chunk boundary.
+type chunkCancelContext struct {
+	context.Context
+	allowed int
+	onCheck func()
+}
+
+func (c *chunkCancelContext) Err() error {
+	if c.onCheck != nil {
+		c.onCheck()
+	}
+	if c.allowed == 0 {
+		return context.Canceled
+	}
+	c.allowed--
+	return nil
+}
+
+func TestRGA_MergeContextPartialCancel(t *testing.T) {
+	// Build a causally ordered chain so that every chunk applies fully.
+	var nodes []Node
+	parent := ID{0, "root"}
+	for i := 0; i < mergeChunkSize*3; i++ {
+		id := ID{Timestamp: int64(i + 1), NodeID: "alice"}
+		nodes = append(nodes, Node{ID: id, ParentID: parent, Value: 'x'})
+		parent = id
+	}
+
+	r := NewRGA("bob")
+	localInserts := 0
+	ctx := &chunkCancelContext{
+		Context: context.Background(),
+		allowed: 1,
+		onCheck: func() {
+			// A local edit must be able to take the write lock between chunks.
+			done := make(chan struct{})
+			go func() {
+				r.Insert('L', ID{0, "root"})
+				close(done)
+			}()
+			select {
+			case <-done:
+				localInserts++
+			case <-time.After(time.Second):
+				t.Fatal("Local insert blocked between merge chunks")
+			}
+		},
+	}
+
+	result, err := r.MergeContext(ctx, nodes)
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if result.Applied != mergeChunkSize {
+		t.Errorf("Expected exactly one chunk (%d nodes) applied, got %+v", mergeChunkSize, result)
+	}
+	if localInserts != 2 {
+		t.Errorf("Expected a local insert at each chunk boundary, got %d", localInserts)
+	}
+
+	// Retrying with a live context completes the merge idempotently.
+	result, err = r.MergeContext(context.Background(), nodes)
+	if err != nil {
+		t.Fatalf("Unexpected error on retry: %v", err)
+	}
+	if result.Applied != mergeChunkSize*2 || result.Duplicates != mergeChunkSize {
+		t.Errorf("Expected remaining chunks applied on retry, got %+v", result)
+	}
+}
+
 func getNodes(r *RGA) []Node {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return getUnsyncNodes(&r.doc)
+}
+
+func getUnsyncNodes(r *UnsyncRGA) []Node {
 	var nodes []Node
 	for _, n := range r.registry {
 		if n.ID.NodeID != "root" {
@@ -174,3 +413,50 @@ func getNodes(r *RGA) []Node {
 	}
 	return nodes
 }
+
+// gateValidator holds merges inside the write lock until release is closed.
+type gateValidator struct {
+	entered, release chan struct{}
+}
+
+func (g gateValidator) Validate(*UnsyncRGA, []Node) error {
+	close(g.entered)
+	<-g.release
+	return nil
+}
+
+func TestRGA_SnapshotIsolatedMerge(t *testing.T) {
+	remote := NewRGA("bob")
+	parent := ID{0, "root"}
+	for range snapshotMergeSize {
+		parent = remote.Insert('b', parent)
+	}
+
+	r := NewRGA("alice")
+	r.Insert('a', ID{0, "root"}) // leaves no cached rendering
+	gate := gateValidator{entered: make(chan struct{}), release: make(chan struct{})}
+	r.SetValidator(gate)
+	merged := make(chan struct{})
+	go func() {
+		r.Merge(remote.Nodes())
+		close(merged)
+	}()
+	<-gate.entered
+
+	done := make(chan any)
+	go func() { done <- r.Value() }()
+	select {
+	case value := <-done:
+		if value != "a" {
+			t.Errorf("Expected the pre-merge snapshot, got %v", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read blocked on a large merge")
+	}
+
+	close(gate.release)
+	<-merged
+	if text := r.rendered.Load(); text == nil || len(*text) != snapshotMergeSize+1 {
+		t.Errorf("Expected the post-merge snapshot to be published, got %v", text)
+	}
+}
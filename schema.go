@@ -0,0 +1,183 @@
+package gocrdt
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrSchemaTooNew is returned by Schema.MergeState for a payload of a
+// schema version newer than the local one: a replica must be upgraded
+// before it merges states of a newer schema, which it cannot downgrade.
+var ErrSchemaTooNew = errors.New("gocrdt: payload of a newer schema")
+
+// Migration upgrades the payload of a state from one schema version to the
+// next. It must be deterministic, a pure function of the payload, so every
+// replica upgrades an old state to the same new one.
+type Migration func(state []byte) ([]byte, error)
+
+// schemaState is the wire representation of a Schema.
+type schemaState struct {
+	V      int             `json:"v"`
+	Schema *int            `json:"schema"`
+	State  json.RawMessage `json:"state"`
+}
+
+// Schema versions the schema of a CRDT, such as the keys an application
+// uses in an ORMap, so that states of replicas still on an older schema
+// are upgraded when they are loaded or merged:
+//
+//	newCounter := func(id string) *GCounter { return NewGCounter(id) }
+//	votes := NewSchema(NewORMap[string]("a", newCounter),
+//		RenameORMapKey[string]("yes", "approve", newCounter), // 0 to 1
+//	)
+//
+// The schema version is the number of migrations. Payloads carry it, and
+// MergeState runs the migrations from their version on before merging
+// them, so a document restored from an old snapshot or received from an
+// old replica converges with the new ones. Payloads without a version,
+// from before the document had a Schema, are of version 0.
+type Schema[T Replicable] struct {
+	state      T
+	migrations []Migration
+}
+
+// NewSchema wraps state, of the schema version len(migrations): the
+// migration i upgrades payloads of version i to i+1.
+func NewSchema[T Replicable](state T, migrations ...Migration) *Schema[T] {
+	return &Schema[T]{state: state, migrations: migrations}
+}
+
+// State returns the wrapped CRDT, for reading and local mutations.
+func (s *Schema[T]) State() T {
+	return s.state
+}
+
+// Version returns the schema version of the CRDT.
+func (s *Schema[T]) Version() int {
+	return len(s.migrations)
+}
+
+// MarshalState encodes the state of the CRDT labelled with its schema
+// version.
+func (s *Schema[T]) MarshalState() ([]byte, error) {
+	state, err := s.state.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	version := s.Version()
+	return json.Marshal(schemaState{V: WireVersion, Schema: &version, State: state})
+}
+
+// MergeState upgrades a payload produced by MarshalState on another
+// replica, or by the CRDT itself before it had a Schema, to the local
+// schema version and merges it.
+func (s *Schema[T]) MergeState(data []byte) (MergeResult, error) {
+	state, err := s.Upgrade(data)
+	if err != nil {
+		return MergeResult{Rejected: 1}, err
+	}
+	return s.state.MergeState(state)
+}
+
+// Upgrade returns the payload of the CRDT, without schema label, that data
+// becomes at the local schema version.
+func (s *Schema[T]) Upgrade(data []byte) ([]byte, error) {
+	version, state := 0, data
+	var remote schemaState
+	if json.Unmarshal(data, &remote) == nil && remote.Schema != nil {
+		if err := checkVersion(remote.V); err != nil {
+			return nil, err
+		}
+		version, state = *remote.Schema, remote.State
+	}
+	if version > s.Version() {
+		return nil, fmt.Errorf("%w: %d (local is %d)", ErrSchemaTooNew, version, s.Version())
+	}
+	for ; version < s.Version(); version++ {
+		var err error
+		if state, err = s.migrations[version](state); err != nil {
+			return nil, fmt.Errorf("gocrdt: migrate schema %d to %d: %w", version, version+1, err)
+		}
+	}
+	return state, nil
+}
+
+// RenameORMapKey returns the Migration renaming the key from to to in the
+// payloads of an ORMap whose values newValue creates. When the payload
+// also holds to, the adds of both keys are kept and their values merged,
+// so the migration is the same wherever it runs. Merged values are CRDT
+// states: a counter incremented by the same replica under both keys keeps
+// the larger of the two counts.
+func RenameORMapKey[K comparable, V Replicable](from, to K, newValue func(replicaID string) V) Migration {
+	return func(data []byte) ([]byte, error) {
+		var state orMapState[K]
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		var keys orSetState[K]
+		if err := json.Unmarshal(state.Keys, &keys); err != nil {
+			return nil, err
+		}
+		keys.Entries = renameEntry(keys.Entries, from, to)
+		var err error
+		if state.Keys, err = json.Marshal(keys); err != nil {
+			return nil, err
+		}
+
+		values := make([]orMapValue[K], 0, len(state.Values))
+		var renamed []json.RawMessage
+		for _, v := range state.Values {
+			if v.Key == from || v.Key == to {
+				renamed = append(renamed, v.State)
+			} else {
+				values = append(values, v)
+			}
+		}
+		switch len(renamed) {
+		case 0:
+		case 1:
+			values = append(values, orMapValue[K]{to, renamed[0]})
+		default:
+			value := newValue("")
+			for _, data := range renamed {
+				if _, err := value.MergeState(data); err != nil {
+					return nil, err
+				}
+			}
+			data, err := value.MarshalState()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, orMapValue[K]{to, data})
+		}
+		state.Values = values
+		return json.Marshal(state)
+	}
+}
+
+// renameEntry renames the element from to to in the entries of an ORSet
+// payload, with the dots of both when both are present.
+func renameEntry[E comparable](entries []orSetEntry[E], from, to E) []orSetEntry[E] {
+	var dots []dot
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Element == from || e.Element == to {
+			dots = append(dots, e.Dots...)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	if dots == nil {
+		return kept
+	}
+	slices.SortFunc(dots, func(a, b dot) int {
+		if c := cmp.Compare(a.Replica, b.Replica); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Counter, b.Counter)
+	})
+	return append(kept, orSetEntry[E]{Element: to, Dots: slices.Compact(dots)})
+}
@@ -0,0 +1,66 @@
+package gocrdt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSchema_Migrations(t *testing.T) {
+	newCounter := func(id string) *GCounter { return NewGCounter(id) }
+	rename := RenameORMapKey[string]("yes", "approve", newCounter)
+
+	// A replica from before the rename, without a Schema.
+	old := NewORMap[string]("old", newCounter)
+	old.Update("yes", (*GCounter).Increment)
+	old.Update("no", (*GCounter).Increment)
+	oldState, _ := old.MarshalState()
+
+	votes := NewSchema(NewORMap[string]("new", newCounter), rename)
+	votes.State().Update("approve", (*GCounter).Increment)
+	if _, err := votes.MergeState(oldState); err != nil {
+		t.Fatal(err)
+	}
+	keys := votes.State().Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"approve", "no"}) {
+		t.Fatalf("Expected yes renamed, got %v", keys)
+	}
+	if approve, _ := votes.State().Get("approve"); approve.Value() != 2 {
+		t.Errorf("Expected both votes merged, got %d", approve.Value())
+	}
+
+	// Upgraded replicas converge, and older schemas refuse newer payloads.
+	payload, _ := votes.MarshalState()
+	other := NewSchema(NewORMap[string]("other", newCounter), rename)
+	if _, err := other.MergeState(payload); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := other.State().Get("approve"); got.Value() != 2 || other.Version() != 1 {
+		t.Errorf("Expected the payload merged as is, got %d", got.Value())
+	}
+	stale := NewSchema(NewORMap[string]("stale", newCounter))
+	if result, err := stale.MergeState(payload); !errors.Is(err, ErrSchemaTooNew) || result.Rejected != 1 {
+		t.Errorf("Expected a newer schema refused, got %+v, %v", result, err)
+	}
+
+	// A payload holding both keys keeps the adds and values of both.
+	both := NewORMap[string]("a", newCounter)
+	both.Update("yes", (*GCounter).Increment)
+	upgraded := NewORMap[string]("b", newCounter)
+	upgraded.Update("approve", (*GCounter).Increment)
+	data, _ := upgraded.MarshalState()
+	both.MergeState(data)
+	data, _ = both.MarshalState()
+	if data, err := rename(data); err != nil {
+		t.Fatal(err)
+	} else {
+		migrated := NewORMap[string]("m", newCounter)
+		migrated.MergeState(data)
+		keys := migrated.Keys()
+		slices.Sort(keys)
+		if approve, _ := migrated.Get("approve"); !slices.Equal(keys, []string{"approve"}) || approve.Value() != 2 {
+			t.Errorf("Expected one key with both votes, got %v", keys)
+		}
+	}
+}
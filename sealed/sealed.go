@@ -0,0 +1,253 @@
+// Package sealed replicates RGA documents end-to-end encrypted.
+//
+// Replicas sharing a document key exchange states whose node values are
+// sealed with AES-GCM, while IDs, parents and tombstones stay in the clear.
+// That ordering metadata is all a merge needs, so an untrusted server can
+// run a Relay that stores, merges and forwards the document without being
+// able to read it:
+//
+//	doc, _ := sealed.NewRGA(gocrdt.NewRGA("alice"), key) // on clients
+//	relay := sealed.NewRelay()                           // on the server
+//
+// Both implement gocrdt.Replicable and the delta interface of sync
+// sessions, so they plug into a replicator.Replica or SyncSession.
+//
+// Each value is authenticated together with its node's ID and parent: a
+// relay cannot move a value to another node undetected. It can still
+// withhold nodes or mark them deleted; combine with the signing package
+// (and trusted transports for deletions) when that matters.
+package sealed
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// KeySize is the size of a document key (AES-256).
+const KeySize = 32
+
+// ErrDecrypt is returned when a sealed value cannot be opened: wrong key,
+// or a value tampered with or moved to another node.
+var ErrDecrypt = errors.New("sealed: cannot decrypt value")
+
+// sealedNode is the wire form of a node whose value is encrypted.
+type sealedNode struct {
+	ID       gocrdt.ID
+	ParentID gocrdt.ID
+	Deleted  bool
+	Box      []byte // nonce followed by the AES-GCM ciphertext of the value
+}
+
+// NewKey returns a random document key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RGA wraps a gocrdt.RGA so that its states and deltas carry encrypted
+// values. Local edits go to the wrapped document directly.
+type RGA struct {
+	doc  *gocrdt.RGA
+	aead cipher.AEAD
+}
+
+// NewRGA wraps doc with the shared document key, which must be KeySize
+// bytes long.
+func NewRGA(doc *gocrdt.RGA, key []byte) (*RGA, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("sealed: key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &RGA{doc: doc, aead: aead}, nil
+}
+
+// Doc returns the wrapped document.
+func (r *RGA) Doc() *gocrdt.RGA {
+	return r.doc
+}
+
+// associatedData binds a sealed value to its node.
+func associatedData(id, parent gocrdt.ID) []byte {
+	b := make([]byte, 0, 24+len(id.NodeID)+len(parent.NodeID))
+	b = binary.AppendVarint(b, id.Timestamp)
+	b = binary.AppendUvarint(b, uint64(len(id.NodeID)))
+	b = append(b, id.NodeID...)
+	b = binary.AppendVarint(b, parent.Timestamp)
+	b = binary.AppendUvarint(b, uint64(len(parent.NodeID)))
+	return append(b, parent.NodeID...)
+}
+
+// seal encrypts the values of nodes.
+func (r *RGA) seal(nodes []gocrdt.Node) ([]byte, error) {
+	out := make([]sealedNode, len(nodes))
+	for i, n := range nodes {
+		nonce := make([]byte, r.aead.NonceSize(), r.aead.NonceSize()+r.aead.Overhead()+4)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		plain := binary.BigEndian.AppendUint32(nil, uint32(n.Value))
+		box := r.aead.Seal(nonce, nonce, plain, associatedData(n.ID, n.ParentID))
+		out[i] = sealedNode{ID: n.ID, ParentID: n.ParentID, Deleted: n.Deleted, Box: box}
+	}
+	return json.Marshal(out)
+}
+
+// open decrypts the values of a sealed payload.
+func (r *RGA) open(data []byte) ([]gocrdt.Node, error) {
+	var in []sealedNode
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	nodes := make([]gocrdt.Node, len(in))
+	for i, s := range in {
+		size := r.aead.NonceSize()
+		if len(s.Box) < size {
+			return nil, fmt.Errorf("%w: node %v", ErrDecrypt, s.ID)
+		}
+		plain, err := r.aead.Open(nil, s.Box[:size], s.Box[size:], associatedData(s.ID, s.ParentID))
+		if err != nil || len(plain) != 4 {
+			return nil, fmt.Errorf("%w: node %v", ErrDecrypt, s.ID)
+		}
+		value := rune(binary.BigEndian.Uint32(plain))
+		nodes[i] = gocrdt.Node{ID: s.ID, ParentID: s.ParentID, Value: value, Deleted: s.Deleted}
+	}
+	return nodes, nil
+}
+
+// MarshalState encodes every node of the document with its value sealed.
+func (r *RGA) MarshalState() ([]byte, error) {
+	return r.seal(r.doc.Nodes())
+}
+
+// MarshalChanges encodes the nodes changed after the cursor since with
+// their values sealed. See gocrdt.RGA.MarshalChanges.
+func (r *RGA) MarshalChanges(since uint64) ([]byte, uint64, error) {
+	nodes, next := r.doc.Changes(since)
+	if len(nodes) == 0 {
+		return nil, next, nil
+	}
+	data, err := r.seal(nodes)
+	return data, next, err
+}
+
+// MarshalSince encodes the nodes changed after the token v with their
+// values sealed. See gocrdt.RGA.MarshalSince.
+func (r *RGA) MarshalSince(v gocrdt.Version) ([]byte, gocrdt.Version, error) {
+	nodes, next, err := r.doc.Since(v)
+	if err != nil || len(nodes) == 0 {
+		return nil, next, err
+	}
+	data, err := r.seal(nodes)
+	return data, next, err
+}
+
+// MergeState opens a sealed payload and merges it. A payload with any value
+// that fails to open is rejected as a whole.
+func (r *RGA) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	nodes, err := r.open(data)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	return r.doc.MergeChecked(nodes)
+}
+
+// Relay stores and forwards a sealed document without the key. It merges
+// on node IDs and tombstones only and keeps nodes in the order first
+// received, which preserves the causal order of the payloads it merged.
+type Relay struct {
+	mu      sync.RWMutex
+	nodes   []sealedNode
+	index   map[gocrdt.ID]int
+	changes []int // positions in nodes, in the order they changed
+}
+
+// NewRelay creates an empty relay.
+func NewRelay() *Relay {
+	return &Relay{index: make(map[gocrdt.ID]int)}
+}
+
+// Len returns the number of nodes stored, tombstones included.
+func (r *Relay) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.nodes)
+}
+
+// MarshalState encodes every stored node.
+func (r *Relay) MarshalState() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return json.Marshal(r.nodes)
+}
+
+// MarshalChanges encodes the nodes added or deleted after the cursor since,
+// in the order they changed. An empty payload (nil) is returned when
+// nothing changed.
+func (r *Relay) MarshalChanges(since uint64) ([]byte, uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	next := uint64(len(r.changes))
+	if since >= next {
+		return nil, next, nil
+	}
+	seen := make(map[int]bool)
+	var nodes []sealedNode
+	for _, pos := range r.changes[since:] {
+		if !seen[pos] {
+			seen[pos] = true
+			nodes = append(nodes, r.nodes[pos])
+		}
+	}
+	data, err := json.Marshal(nodes)
+	return data, next, err
+}
+
+// MergeState merges a sealed payload. New nodes are Applied, known ones
+// are Duplicates unless they newly carry a tombstone (Deleted). The relay
+// cannot tell orphans apart; they are stored and forwarded like any node.
+func (r *Relay) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	var in []sealedNode
+	if err := json.Unmarshal(data, &in); err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result gocrdt.MergeResult
+	for _, n := range in {
+		pos, ok := r.index[n.ID]
+		switch {
+		case !ok:
+			pos = len(r.nodes)
+			r.index[n.ID] = pos
+			r.nodes = append(r.nodes, n)
+			r.changes = append(r.changes, pos)
+			result.Applied++
+		case n.Deleted && !r.nodes[pos].Deleted:
+			r.nodes[pos].Deleted = true
+			r.changes = append(r.changes, pos)
+			result.Deleted++
+		default:
+			result.Duplicates++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package sealed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var root = gocrdt.ID{NodeID: "root"}
+
+func newDoc(t *testing.T, id string, key []byte) *RGA {
+	t.Helper()
+	doc, err := NewRGA(gocrdt.NewRGA(id), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// transfer merges the state of from into to.
+func transfer(t *testing.T, from, to gocrdt.Replicable) {
+	t.Helper()
+	data, err := from.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.MergeState(data); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+}
+
+func TestRelay_ForwardsWithoutReading(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := newDoc(t, "alice", key), newDoc(t, "bob", key)
+	relay := NewRelay()
+
+	s := alice.Doc().Insert('s', root)
+	alice.Doc().Insert('!', alice.Doc().Insert('y', s))
+	transfer(t, alice, relay)
+	bob.Doc().Insert('k', root)
+	transfer(t, bob, relay)
+
+	state, _ := relay.MarshalState()
+	if bytes.Contains(state, []byte(`"Value"`)) {
+		t.Errorf("Relay state exposes values: %s", state)
+	}
+
+	transfer(t, relay, alice)
+	transfer(t, relay, bob)
+	if alice.Doc().Value() != bob.Doc().Value() || relay.Len() != 4 {
+		t.Fatalf("Replicas diverged: %q vs %q", alice.Doc().Value(), bob.Doc().Value())
+	}
+
+	// A deletion reaches the relay as a delta.
+	_, cursor := bob.Doc().Changes(0)
+	bob.Doc().Delete(s)
+	delta, _, err := bob.MarshalChanges(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := relay.MergeState(delta); err != nil || result.Deleted != 1 {
+		t.Fatalf("Expected the relay to record the deletion, got %+v, %v", result, err)
+	}
+	changes, _, _ := relay.MarshalChanges(4)
+	if _, err := alice.MergeState(changes); err != nil {
+		t.Fatal(err)
+	}
+	if alice.Doc().Value() != bob.Doc().Value() {
+		t.Errorf("Deletion not relayed: %q vs %q", alice.Doc().Value(), bob.Doc().Value())
+	}
+}
+
+func TestRGA_RejectsForeignOrMovedValues(t *testing.T) {
+	key, _ := NewKey()
+	other, _ := NewKey()
+	alice, eve := newDoc(t, "alice", key), newDoc(t, "eve", other)
+	a := alice.Doc().Insert('a', root)
+	alice.Doc().Insert('b', a)
+	state, _ := alice.MarshalState()
+
+	if _, err := eve.MergeState(state); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected the wrong key to fail, got %v", err)
+	}
+
+	var nodes []sealedNode
+	if err := json.Unmarshal(state, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].Box, nodes[1].Box = nodes[1].Box, nodes[0].Box
+	swapped, _ := json.Marshal(nodes)
+	bob := newDoc(t, "bob", key)
+	if _, err := bob.MergeState(swapped); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected moved values to fail, got %v", err)
+	}
+	if _, err := NewRGA(gocrdt.NewRGA("x"), key[:16]); err == nil {
+		t.Error("Expected a short key to be rejected")
+	}
+}
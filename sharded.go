@@ -0,0 +1,200 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// shardedState is the wire representation of a ShardedRGA.
+type shardedState struct {
+	V      int             `json:"v"`
+	Index  json.RawMessage `json:"index"`
+	Shards []shardState    `json:"shards"`
+}
+
+// shardState is the state of one shard of a ShardedRGA. Only a shard
+// encoded on its own, by MarshalShard, carries a version.
+type shardState struct {
+	V     int             `json:"v,omitempty"`
+	ID    ID              `json:"id"`
+	State json.RawMessage `json:"state"`
+}
+
+// ShardedRGA is a sequence split into shards, such as the chapters of a
+// manuscript, each an RGA of its own. A small RGA, the index, orders the
+// shards: every shard is identified by the ID of its node in the index.
+// Edits and merges of a shard only lock and touch that shard, so the cost
+// of an operation depends on the size of its shard, not of the document.
+//
+// Shards are added and removed through the index, concurrently on any
+// replica, and edited through the RGA returned by Shard. Replicas exchange
+// either the whole document (MarshalState) or single shards
+// (MarshalShard), which may arrive before the index node of their shard:
+// such shards are kept, hidden, until it does.
+//
+// ShardedRGA is safe for concurrent use.
+type ShardedRGA struct {
+	nodeID string
+
+	mu     sync.RWMutex
+	index  *RGA
+	shards map[ID]*RGA
+}
+
+// NewShardedRGA creates an empty ShardedRGA for the replica nodeID.
+func NewShardedRGA(nodeID string) *ShardedRGA {
+	return &ShardedRGA{nodeID: nodeID, index: NewRGA(nodeID), shards: make(map[ID]*RGA)}
+}
+
+// AddShard creates an empty shard after the shard after, or first for the
+// root ID{0, "root"}, and returns its ID.
+func (s *ShardedRGA) AddShard(after ID) ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.index.Insert('§', after)
+	s.shards[id] = NewRGA(s.nodeID)
+	return id
+}
+
+// RemoveShard removes a shard from the sequence. Like an RGA tombstone, its
+// content is kept, so concurrent edits of the shard still merge.
+func (s *ShardedRGA) RemoveShard(id ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index.Delete(id)
+}
+
+// Shard returns the RGA of a shard, visible or not, for reading and editing.
+func (s *ShardedRGA) Shard(id ID) (*RGA, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	shard, ok := s.shards[id]
+	return shard, ok
+}
+
+// Shards returns the IDs of the visible shards, in order. A shard whose
+// index node arrived before its content has no RGA yet: Shard reports it
+// missing and Value shows it empty.
+func (s *ShardedRGA) Shards() []ID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.visible()
+}
+
+// visible returns the IDs of the shards in the index that are not removed.
+// It must be called with the lock held.
+func (s *ShardedRGA) visible() []ID {
+	var ids []ID
+	for _, n := range s.index.Nodes() {
+		if !n.Deleted {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+// Value returns the text of the visible shards, in order.
+func (s *ShardedRGA) Value() any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var b strings.Builder
+	for _, id := range s.visible() {
+		if shard, ok := s.shards[id]; ok {
+			b.WriteString(shard.Value().(string))
+		}
+	}
+	return b.String()
+}
+
+// MarshalState encodes the index and every shard.
+func (s *ShardedRGA) MarshalState() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index, err := s.index.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	state := shardedState{V: WireVersion, Index: index, Shards: make([]shardState, 0, len(s.shards))}
+	for id, shard := range s.shards {
+		data, err := shard.MarshalState()
+		if err != nil {
+			return nil, err
+		}
+		state.Shards = append(state.Shards, shardState{ID: id, State: data})
+	}
+	slices.SortFunc(state.Shards, func(a, b shardState) int {
+		if a.ID.Greater(b.ID) {
+			return 1
+		}
+		return -1
+	})
+	return json.Marshal(state)
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// the index, then every shard. The result sums all merges.
+func (s *ShardedRGA) MergeState(data []byte) (MergeResult, error) {
+	var state shardedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+	result, err := s.index.MergeState(state.Index)
+	if err != nil {
+		return result, err
+	}
+	for _, shard := range state.Shards {
+		merged, err := s.mergeShard(shard.ID, shard.State)
+		result.Add(merged)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// MarshalShard encodes the state of a single shard, for MergeShard.
+func (s *ShardedRGA) MarshalShard(id ID) ([]byte, error) {
+	shard, ok := s.Shard(id)
+	if !ok {
+		return nil, fmt.Errorf("gocrdt: unknown shard %d@%s", id.Timestamp, id.NodeID)
+	}
+	data, err := shard.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(shardState{V: WireVersion, ID: id, State: data})
+}
+
+// MergeShard merges a shard encoded by MarshalShard on another replica,
+// touching no other shard.
+func (s *ShardedRGA) MergeShard(data []byte) (MergeResult, error) {
+	var shard shardState
+	if err := json.Unmarshal(data, &shard); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(shard.V); err != nil {
+		return MergeResult{}, err
+	}
+	return s.mergeShard(shard.ID, shard.State)
+}
+
+// mergeShard merges state into the shard id, creating the shard first if
+// it is unknown.
+func (s *ShardedRGA) mergeShard(id ID, state []byte) (MergeResult, error) {
+	shard, ok := s.Shard(id)
+	if !ok {
+		s.mu.Lock()
+		if shard, ok = s.shards[id]; !ok {
+			shard = NewRGA(s.nodeID)
+			s.shards[id] = shard
+		}
+		s.mu.Unlock()
+	}
+	return shard.MergeState(state)
+}
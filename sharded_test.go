@@ -0,0 +1,73 @@
+package gocrdt
+
+import "testing"
+
+// typeInto appends text to the end of a shard.
+func typeInto(doc *RGA, text string) {
+	parent := ID{0, "root"}
+	if nodes := doc.Nodes(); len(nodes) > 0 {
+		parent = nodes[len(nodes)-1].ID
+	}
+	for _, r := range text {
+		parent = doc.Insert(r, parent)
+	}
+}
+
+func TestShardedRGA(t *testing.T) {
+	alice, bob := NewShardedRGA("alice"), NewShardedRGA("bob")
+	one := alice.AddShard(ID{0, "root"})
+	two := alice.AddShard(one)
+	shard, _ := alice.Shard(one)
+	typeInto(shard, "Once. ")
+	shard, _ = alice.Shard(two)
+	typeInto(shard, "The end.")
+
+	state, err := alice.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	if bob.Value() != "Once. The end." || len(bob.Shards()) != 2 {
+		t.Fatalf("Expected the whole document, got %q", bob.Value())
+	}
+
+	// An edit ships as its shard alone.
+	shard, _ = bob.Shard(one)
+	typeInto(shard, "Upon a time. ")
+	data, err := bob.MarshalShard(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := alice.MergeShard(data); err != nil || result.Applied != len("Upon a time. ") {
+		t.Errorf("Expected the shard's new nodes to be applied, got %+v and %v", result, err)
+	}
+
+	// A shard arriving before its index node stays hidden until it does.
+	middle := bob.AddShard(one)
+	shard, _ = bob.Shard(middle)
+	typeInto(shard, "Then. ")
+	data, _ = bob.MarshalShard(middle)
+	alice.MergeShard(data)
+	if alice.Value() != "Once. Upon a time. The end." {
+		t.Errorf("Expected the early shard to be hidden, got %q", alice.Value())
+	}
+	state, _ = bob.MarshalState()
+	alice.MergeState(state)
+	if alice.Value() != "Once. Upon a time. Then. The end." {
+		t.Errorf("Expected the shard to show with its index node, got %q", alice.Value())
+	}
+
+	// A removed shard keeps its content for concurrent edits.
+	alice.RemoveShard(two)
+	if alice.Value() != "Once. Upon a time. Then. " || len(alice.Shards()) != 2 {
+		t.Errorf("Expected the last shard to be removed, got %q", alice.Value())
+	}
+	if _, ok := alice.Shard(two); !ok {
+		t.Error("Expected the removed shard to be kept")
+	}
+	if _, err := alice.MarshalShard(ID{99, "nobody"}); err == nil {
+		t.Error("Expected an error for an unknown shard")
+	}
+}
@@ -0,0 +1,210 @@
+package signing
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// signedNode is the wire form of a node with its author's signature.
+type signedNode struct {
+	gocrdt.Node
+	Sig []byte `json:"sig"`
+}
+
+// record is the signed content of a node, kept so the node can be relayed
+// with its signature. Records of tombstones collected by RGA.CompactStable
+// are dropped.
+type record struct {
+	parent gocrdt.ID
+	value  rune
+	sig    []byte
+}
+
+// RGA wraps a gocrdt.RGA so that its states and deltas carry signatures.
+// It implements gocrdt.Replicable and the delta interface of sync sessions,
+// and is used in their place; local edits go to the wrapped document
+// directly and are signed when first exported.
+//
+// Signatures cover the ID, parent and value of a node: they authenticate
+// insertions. Deletions are not signed, since any replica may delete any
+// node; restrict who may send them at the transport level.
+//
+// The signatures of remote nodes are held by the wrapper, not by the
+// document, so persist the wrapper's MarshalState rather than the
+// document's. After a restart, wrap the document and merge the persisted
+// state with MergeState: its nodes are verified again and their
+// signatures recovered. Until then, remote nodes other than tombstones
+// cannot be exported.
+type RGA struct {
+	doc      *gocrdt.RGA
+	signer   Signer
+	verifier Verifier
+
+	mu      sync.Mutex
+	records map[gocrdt.ID]record
+}
+
+// NewRGA wraps doc, whose replica ID must be signer.Author(). verifier
+// must know every author, including the local one.
+func NewRGA(doc *gocrdt.RGA, signer Signer, verifier Verifier) *RGA {
+	return &RGA{doc: doc, signer: signer, verifier: verifier, records: make(map[gocrdt.ID]record)}
+}
+
+// Doc returns the wrapped document.
+func (r *RGA) Doc() *gocrdt.RGA {
+	return r.doc
+}
+
+// content returns the signed bytes of a node.
+func content(id, parent gocrdt.ID, value rune) []byte {
+	b := make([]byte, 0, 32+len(id.NodeID)+len(parent.NodeID))
+	b = binary.AppendVarint(b, id.Timestamp)
+	b = binary.AppendUvarint(b, uint64(len(id.NodeID)))
+	b = append(b, id.NodeID...)
+	b = binary.AppendVarint(b, parent.Timestamp)
+	b = binary.AppendUvarint(b, uint64(len(parent.NodeID)))
+	b = append(b, parent.NodeID...)
+	return binary.AppendVarint(b, int64(value))
+}
+
+// sign attaches signatures to nodes, signing local nodes on first export.
+// It must be called with r.mu held.
+func (r *RGA) sign(nodes []gocrdt.Node) ([]signedNode, error) {
+	signed := make([]signedNode, len(nodes))
+	for i, n := range nodes {
+		rec, ok := r.records[n.ID]
+		if !ok && n.Deleted && n.ID.NodeID != r.signer.Author() {
+			// A collected tombstone brought back by a late insert: its
+			// record is gone, and every replica already knows the node.
+			signed[i] = signedNode{Node: n}
+			continue
+		}
+		if !ok {
+			if n.ID.NodeID != r.signer.Author() {
+				return nil, fmt.Errorf("signing: node %v of %q was merged without a signature", n.ID, n.ID.NodeID)
+			}
+			sig, err := r.signer.Sign(content(n.ID, n.ParentID, n.Value))
+			if err != nil {
+				return nil, err
+			}
+			rec = record{parent: n.ParentID, value: n.Value, sig: sig}
+			r.records[n.ID] = rec
+		}
+		n.ParentID, n.Value = rec.parent, rec.value
+		signed[i] = signedNode{Node: n, Sig: rec.sig}
+	}
+	return signed, nil
+}
+
+// MarshalState encodes every node of the document with its signature.
+func (r *RGA) MarshalState() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	signed, err := r.sign(r.doc.Nodes())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(signed)
+}
+
+// MarshalChanges encodes the nodes changed after the cursor since with
+// their signatures. See gocrdt.RGA.MarshalChanges.
+func (r *RGA) MarshalChanges(since uint64) ([]byte, uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes, next := r.doc.Changes(since)
+	if len(nodes) == 0 {
+		return nil, next, nil
+	}
+	signed, err := r.sign(nodes)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := json.Marshal(signed)
+	return data, next, err
+}
+
+// MarshalSince encodes the nodes changed after the token v with their
+// signatures. See gocrdt.RGA.MarshalSince.
+func (r *RGA) MarshalSince(v gocrdt.Version) ([]byte, gocrdt.Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes, next, err := r.doc.Since(v)
+	if err != nil || len(nodes) == 0 {
+		return nil, next, err
+	}
+	signed, err := r.sign(nodes)
+	if err != nil {
+		return nil, v, err
+	}
+	data, err := json.Marshal(signed)
+	return data, next, err
+}
+
+// MergeState verifies every node the document does not know yet and merges
+// the payload. A payload with any node failing verification is rejected as
+// a whole, with an error wrapping the verifier's. Unsigned tombstones (see
+// CompactStable) only apply to nodes the document holds and are otherwise
+// skipped.
+func (r *RGA) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	var signed []signedNode
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fresh := make(map[gocrdt.ID]record)
+	nodes := make([]gocrdt.Node, 0, len(signed))
+	for _, s := range signed {
+		if _, ok := r.records[s.ID]; ok {
+			nodes = append(nodes, s.Node)
+			continue
+		}
+		if s.Sig == nil && s.Deleted {
+			if _, ok := r.doc.Lookup(s.ID); ok {
+				nodes = append(nodes, s.Node)
+			}
+			continue
+		}
+		nodes = append(nodes, s.Node)
+		if err := r.verifier.Verify(s.ID.NodeID, content(s.ID, s.ParentID, s.Value), s.Sig); err != nil {
+			return gocrdt.MergeResult{}, fmt.Errorf("signing: node %v: %w", s.ID, err)
+		}
+		fresh[s.ID] = record{parent: s.ParentID, value: s.Value, sig: s.Sig}
+	}
+	for id, rec := range fresh {
+		r.records[id] = rec
+	}
+	return r.doc.MergeChecked(nodes)
+}
+
+// CompactStable garbage-collects the stable tombstones of the document (see
+// gocrdt.RGA.CompactStable) and drops the signatures of the collected
+// nodes. Call it rather than the document's CompactStable, which leaves
+// them in memory.
+//
+// A collected remote tombstone that a late insert brings back is exported
+// without a signature; receivers apply it only if they hold the node.
+func (r *RGA) CompactStable(cursor uint64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	collected := r.doc.CompactStable(cursor)
+	if collected == 0 {
+		return 0
+	}
+	pending := make(map[gocrdt.ID]bool)
+	for _, id := range r.doc.PendingOrphans() {
+		pending[id] = true
+	}
+	for id := range r.records {
+		if _, ok := r.doc.Lookup(id); !ok && !pending[id] {
+			delete(r.records, id)
+		}
+	}
+	return collected
+}
@@ -0,0 +1,82 @@
+// Package signing authenticates the authors of replicated operations.
+//
+// Every RGA node carries the replica that created it in its ID. Over an
+// untrusted peer-to-peer network nothing stops a peer from forging nodes
+// in another replica's name; this package closes that gap. A signed
+// document signs its own nodes with a Signer and only merges remote nodes
+// whose signature a Verifier accepts for the NodeID they claim. Signatures
+// travel with the nodes, so replicas can relay each other's operations.
+//
+// The Signer and Verifier interfaces are pluggable; Ed25519Signer and
+// Keyring implement them with crypto/ed25519:
+//
+//	pub, priv, _ := ed25519.GenerateKey(nil)
+//	keys := signing.Keyring{"alice": pub, "bob": bobPub}
+//	doc := signing.NewRGA(gocrdt.NewRGA("alice"), signing.NewEd25519Signer("alice", priv), keys)
+//	r := replicator.NewReplica("alice", doc, transport, replicator.Config{})
+package signing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned when a node's signature does not verify
+// against the key of the author it claims.
+var ErrInvalidSignature = errors.New("signing: invalid signature")
+
+// ErrUnknownAuthor is returned by Keyring for authors without a key.
+var ErrUnknownAuthor = errors.New("signing: unknown author")
+
+// Signer signs the operations of one author.
+type Signer interface {
+	// Author returns the NodeID the signer signs for.
+	Author() string
+
+	// Sign returns the signature of msg.
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier checks that a signature was produced by an author.
+type Verifier interface {
+	// Verify returns nil if sig is author's signature of msg.
+	Verify(author string, msg, sig []byte) error
+}
+
+// Ed25519Signer is a Signer backed by an ed25519 private key.
+type Ed25519Signer struct {
+	author string
+	key    ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a Signer for author.
+func NewEd25519Signer(author string, key ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{author: author, key: key}
+}
+
+// Author returns the NodeID the signer signs for.
+func (s *Ed25519Signer) Author() string {
+	return s.author
+}
+
+// Sign returns the ed25519 signature of msg.
+func (s *Ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, msg), nil
+}
+
+// Keyring is a Verifier mapping every known author to its ed25519 public
+// key. It must not be modified while in use.
+type Keyring map[string]ed25519.PublicKey
+
+// Verify checks sig against the public key of author.
+func (k Keyring) Verify(author string, msg, sig []byte) error {
+	key, ok := k[author]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownAuthor, author)
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
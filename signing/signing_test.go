@@ -0,0 +1,166 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var root = gocrdt.ID{NodeID: "root"}
+
+// newSigned creates a signed document for each author, sharing one keyring.
+func newSigned(t *testing.T, authors ...string) map[string]*RGA {
+	t.Helper()
+	keys := Keyring{}
+	signers := map[string]Signer{}
+	for _, author := range authors {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[author] = pub
+		signers[author] = NewEd25519Signer(author, priv)
+	}
+	docs := map[string]*RGA{}
+	for _, author := range authors {
+		docs[author] = NewRGA(gocrdt.NewRGA(author), signers[author], keys)
+	}
+	return docs
+}
+
+func TestRGA_RelaysSignedNodes(t *testing.T) {
+	docs := newSigned(t, "alice", "bob", "carol")
+	h := docs["alice"].Doc().Insert('h', root)
+	docs["alice"].Doc().Insert('i', h)
+
+	// bob relays alice's nodes to carol, who never talked to alice.
+	state, err := docs["alice"].MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs["bob"].MergeState(state); err != nil {
+		t.Fatalf("bob rejected alice's state: %v", err)
+	}
+	docs["bob"].Doc().Delete(h)
+	relayed, err := docs["bob"].MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs["carol"].MergeState(relayed); err != nil {
+		t.Fatalf("carol rejected the relayed state: %v", err)
+	}
+	if got := docs["carol"].Doc().Value(); got != "i" {
+		t.Errorf("Expected \"i\", got %q", got)
+	}
+
+	delta, cursor, err := docs["carol"].MarshalChanges(0)
+	if err != nil || cursor == 0 {
+		t.Fatalf("MarshalChanges failed: %v", err)
+	}
+	if _, err := docs["alice"].MergeState(delta); err != nil {
+		t.Errorf("alice rejected carol's delta: %v", err)
+	}
+}
+
+func TestRGA_RejectsForgedNodes(t *testing.T) {
+	docs := newSigned(t, "alice", "mallory")
+	docs["mallory"].Doc().Insert('x', root)
+	state, err := docs["mallory"].MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mallory rewrites her node to claim it was alice's.
+	var nodes []signedNode
+	if err := json.Unmarshal(state, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].ID.NodeID = "alice"
+	forged, _ := json.Marshal(nodes)
+	if _, err := docs["alice"].MergeState(forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a forged author to be rejected, got %v", err)
+	}
+
+	// Tampering with the value breaks the signature too.
+	nodes[0].ID.NodeID = "mallory"
+	nodes[0].Value = 'y'
+	tampered, _ := json.Marshal(nodes)
+	if _, err := docs["alice"].MergeState(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a tampered value to be rejected, got %v", err)
+	}
+
+	nodes[0].ID.NodeID = "eve"
+	unknown, _ := json.Marshal(nodes)
+	if _, err := docs["alice"].MergeState(unknown); !errors.Is(err, ErrUnknownAuthor) {
+		t.Errorf("Expected an unknown author to be rejected, got %v", err)
+	}
+
+	if docs["alice"].Doc().Value() != "" {
+		t.Errorf("Rejected nodes were merged: %q", docs["alice"].Doc().Value())
+	}
+}
+
+func TestRGA_RestoresSignatures(t *testing.T) {
+	docs := newSigned(t, "alice", "bob")
+	docs["bob"].Doc().Insert('b', root)
+	state, _ := docs["bob"].MarshalState()
+	if _, err := docs["alice"].MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	persisted, err := docs["alice"].MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the document comes back without bob's signature.
+	plain, _ := docs["alice"].Doc().MarshalState()
+	doc := gocrdt.NewRGA("alice")
+	doc.MergeState(plain)
+	restarted := NewRGA(doc, docs["alice"].signer, docs["alice"].verifier)
+	if _, err := restarted.MarshalState(); err == nil {
+		t.Fatal("Expected bob's node unexportable without its signature")
+	}
+	if _, err := restarted.MergeState(persisted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.MarshalState(); err != nil {
+		t.Errorf("Expected the signatures restored, got %v", err)
+	}
+}
+
+func TestRGA_CompactStableDropsSignatures(t *testing.T) {
+	docs := newSigned(t, "alice", "bob", "carol")
+	x := docs["bob"].Doc().Insert('x', root)
+	state, _ := docs["bob"].MarshalState()
+	docs["alice"].MergeState(state)
+	docs["carol"].MergeState(state)
+
+	alice := docs["alice"]
+	alice.Doc().Delete(x)
+	_, cursor := alice.Doc().Changes(0)
+	if n := alice.CompactStable(cursor); n != 1 || len(alice.records) != 0 {
+		t.Fatalf("Expected x collected with its signature, got %d and %d records", n, len(alice.records))
+	}
+
+	// A late insert anchored to x brings it back as an unsigned tombstone,
+	// which carol, who holds x, accepts.
+	_, since := docs["bob"].Doc().Changes(0)
+	docs["bob"].Doc().Insert('y', x)
+	delta, _, _ := docs["bob"].MarshalChanges(since)
+	if _, err := alice.MergeState(delta); err != nil {
+		t.Fatal(err)
+	}
+	state, err := alice.MarshalState()
+	if err != nil {
+		t.Fatalf("Expected the resurrected tombstone exported, got %v", err)
+	}
+	if _, err := docs["carol"].MergeState(state); err != nil {
+		t.Fatalf("carol rejected the resurrected tombstone: %v", err)
+	}
+	if got := docs["carol"].Doc().Value(); got != "y" {
+		t.Errorf("Expected \"y\", got %q", got)
+	}
+}
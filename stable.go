@@ -0,0 +1,83 @@
+package gocrdt
+
+// CompactStable garbage-collects tombstones that every replica is known to
+// have seen. cursor is the stable frontier of the local change log (see
+// Changes): every replica has received all changes logged before it.
+//
+// A tombstone is collected when its deletion was logged before cursor and no
+// other node is anchored to it. Collected nodes leave the linked list and
+// the registry; only their ID and parent are remembered, so re-deliveries
+// are recognized as duplicates and a late insert anchored to a collected
+// node (concurrent with its deletion) brings it back as a tombstone at its
+// original position. It returns the number of nodes collected.
+func (r *UnsyncRGA) CompactStable(cursor uint64) int {
+	if cursor > uint64(len(r.changes)) {
+		cursor = uint64(len(r.changes))
+	}
+
+	anchors := make(map[ID]bool, len(r.registry))
+	for _, n := range r.registry {
+		anchors[n.ParentID] = true
+	}
+	for parent := range r.pendingOrphans {
+		anchors[parent] = true
+	}
+
+	// A node is only stable if its last change, the deletion, is.
+	candidates := make(map[ID]bool)
+	for i, id := range r.changes {
+		if uint64(i) >= cursor {
+			delete(candidates, id)
+		} else if n, ok := r.registry[id]; ok && n.Deleted && !anchors[id] {
+			candidates[id] = true
+		}
+	}
+	if len(candidates) == 0 {
+		return 0
+	}
+
+	if r.collected == nil {
+		r.collected = make(map[ID]ID)
+	}
+	collected := 0
+	for prev := r.root; prev.Next != nil; {
+		n := prev.Next
+		if !candidates[n.ID] {
+			prev = n
+			continue
+		}
+		prev.Next = n.Next
+		delete(r.registry, n.ID)
+		r.collected[n.ID] = n.ParentID
+		collected++
+	}
+	if r.logger != nil {
+		r.logger.Info("gocrdt: collected stable tombstones", "count", collected, "cursor", cursor, "tombstones", len(r.collected))
+	}
+	return collected
+}
+
+// resurrect brings a collected node back as a tombstone, together with any
+// collected ancestors, so that nodes anchored to it can be integrated. It
+// reports whether id was collected.
+func (r *UnsyncRGA) resurrect(id ID) bool {
+	parent, ok := r.collected[id]
+	if !ok {
+		return false
+	}
+	r.resurrect(parent)
+	delete(r.collected, id)
+	if r.logger != nil {
+		r.logger.Debug("gocrdt: resurrected collected tombstone", "node", id)
+	}
+	r.integrate(&Node{ID: id, ParentID: parent, Deleted: true})
+	return true
+}
+
+// CompactStable garbage-collects tombstones that every replica is known to
+// have seen. See UnsyncRGA.CompactStable.
+func (r *RGA) CompactStable(cursor uint64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doc.CompactStable(cursor)
+}
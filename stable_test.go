@@ -0,0 +1,49 @@
+package gocrdt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRGA_CompactStable(t *testing.T) {
+	root := ID{NodeID: "root"}
+	doc := NewRGA("a")
+	a := doc.Insert('a', root)
+	b := doc.Insert('b', a)
+	doc.Insert('c', b)
+	doc.Delete(b) // anchors c: must stay
+	x := doc.Insert('x', a)
+	doc.Delete(x)
+	_, stable := doc.Changes(0)
+	y := doc.Insert('y', a)
+	doc.Delete(y) // deleted after the frontier
+
+	if n := doc.CompactStable(stable); n != 1 {
+		t.Fatalf("Expected only x to be collected, got %d", n)
+	}
+	if doc.Value() != "ac" || len(doc.Nodes()) != 4 {
+		t.Errorf("Expected \"ac\" with 4 nodes left, got %q with %d", doc.Value(), len(doc.Nodes()))
+	}
+
+	// A stale re-delivery of x is a duplicate, not a new node.
+	peer := NewRGA("b")
+	peer.Merge(doc.Nodes())
+	peer.Merge([]Node{{ID: x, ParentID: a, Value: 'x', Deleted: true}})
+	result := doc.Merge([]Node{{ID: x, ParentID: a, Value: 'x', Deleted: true}})
+	if result.Duplicates != 1 || len(doc.Nodes()) != 4 {
+		t.Errorf("Expected x to be recognized as a duplicate, got %+v", result)
+	}
+
+	// An insert anchored to x, concurrent with its deletion, brings x back
+	// as a tombstone and lands where it does on a replica that kept x.
+	late := Node{ID: ID{Timestamp: x.Timestamp + 1, NodeID: "c"}, ParentID: x, Value: 'L'}
+	doc.Merge([]Node{late})
+	peer.Merge([]Node{late})
+	if doc.Value() != peer.Value() || !strings.ContainsRune(doc.Value().(string), 'L') {
+		t.Errorf("Compacted replica diverged: %q vs %q", doc.Value(), peer.Value())
+	}
+
+	if changes, _ := doc.Changes(0); len(changes) != len(doc.Nodes()) {
+		t.Errorf("Changes must skip collected nodes, got %d changes for %d nodes", len(changes), len(doc.Nodes()))
+	}
+}
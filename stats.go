@@ -0,0 +1,44 @@
+package gocrdt
+
+// DocStats describes the size of an RGA document.
+type DocStats struct {
+	// Nodes is the number of integrated nodes, tombstones included.
+	Nodes int
+
+	// Tombstones is the number of deleted nodes still kept.
+	Tombstones int
+
+	// Orphans is the number of remote nodes buffered until their parent
+	// arrives.
+	Orphans int
+}
+
+// TombstoneRatio returns the share of nodes that are tombstones, 0 for an
+// empty document. A high ratio makes CompactStable worthwhile.
+func (s DocStats) TombstoneRatio() float64 {
+	if s.Nodes == 0 {
+		return 0
+	}
+	return float64(s.Tombstones) / float64(s.Nodes)
+}
+
+// Stats returns the size of the document. It walks the whole document.
+func (r *UnsyncRGA) Stats() DocStats {
+	stats := DocStats{Nodes: len(r.registry) - 1}
+	for curr := r.root.Next; curr != nil; curr = curr.Next {
+		if curr.Deleted {
+			stats.Tombstones++
+		}
+	}
+	for _, orphans := range r.pendingOrphans {
+		stats.Orphans += len(orphans)
+	}
+	return stats
+}
+
+// Stats returns the size of the document. See UnsyncRGA.Stats.
+func (r *RGA) Stats() DocStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Stats()
+}
@@ -0,0 +1,20 @@
+package gocrdt
+
+import "testing"
+
+func TestRGA_Stats(t *testing.T) {
+	root := ID{0, "root"}
+	doc := NewRGA("alice")
+	if stats := doc.Stats(); stats != (DocStats{}) || stats.TombstoneRatio() != 0 {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+	a := doc.Insert('a', root)
+	doc.Insert('b', a)
+	doc.Delete(a)
+	doc.Merge([]Node{{ID: ID{9, "bob"}, ParentID: ID{8, "bob"}, Value: 'o'}})
+
+	stats := doc.Stats()
+	if stats != (DocStats{Nodes: 2, Tombstones: 1, Orphans: 1}) || stats.TombstoneRatio() != 0.5 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,511 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	snapshotFile = "snapshot"
+	opsFile      = "ops.log"
+	baseFile     = "ops.base" // seq of the last truncated op, see TruncateOps
+
+	// recordHeader is the size of the header framing every log record and
+	// snapshot: sequence number, payload length and CRC-32C of the payload.
+	recordHeader = 8 + 4 + 4
+
+	// maxRecordPrealloc caps the buffer readRecord allocates up front.
+	maxRecordPrealloc = 64 << 10
+)
+
+// ErrCorrupt is returned for a snapshot or log record that fails its
+// checksum.
+var ErrCorrupt = errors.New("storage: corrupt data")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// FileStorage is a Storage keeping each document in its own subdirectory
+// of a root directory: a snapshot file, replaced atomically, and an
+// append-only operation log. Every write is fsynced before it returns.
+//
+// A crash while appending can leave a torn record at the end of a log; it
+// is detected by its checksum and cut off the next time the log is opened.
+// A damaged record followed by others is not a torn append: the log is
+// left alone and opening it fails with ErrCorrupt.
+type FileStorage struct {
+	dir string
+
+	mu   sync.Mutex
+	logs map[string]*opLog
+}
+
+// opLog is an open operation log.
+type opLog struct {
+	file *os.File
+	last uint64 // sequence number of the last record
+}
+
+// NewFileStorage opens (creating if needed) a FileStorage rooted at dir.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir, logs: make(map[string]*opLog)}, nil
+}
+
+// docDir returns the directory of key. Keys are encoded so that any string
+// maps to a single safe path element.
+func (s *FileStorage) docDir(key string) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+// appendRecord frames data as a record.
+func appendRecord(b []byte, seq uint64, data []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, seq)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	b = binary.BigEndian.AppendUint32(b, crc32.Checksum(data, castagnoli))
+	return append(b, data...)
+}
+
+// readRecord reads the next record. It returns io.EOF at the end of r and
+// io.ErrUnexpectedEOF or ErrCorrupt for a torn or damaged record.
+func readRecord(r io.Reader) (uint64, []byte, error) {
+	var header [recordHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	seq := binary.BigEndian.Uint64(header[0:])
+	// The payload is read into a growing buffer rather than allocated at the
+	// length the header claims, so a damaged header costs no more memory
+	// than the bytes actually left in r.
+	n := int64(binary.BigEndian.Uint32(header[8:]))
+	var buf bytes.Buffer
+	buf.Grow(int(min(n, maxRecordPrealloc)))
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	data := buf.Bytes()
+	if crc32.Checksum(data, castagnoli) != binary.BigEndian.Uint32(header[12:]) {
+		return 0, nil, ErrCorrupt
+	}
+	return seq, data, nil
+}
+
+// SaveSnapshot atomically replaces the snapshot of key.
+func (s *FileStorage) SaveSnapshot(key string, seq uint64, state []byte) error {
+	dir := s.docDir(key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, snapshotFile), appendRecord(nil, seq, state))
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it and renames it
+// over path, then syncs the directory so the rename is durable.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// ReadFile decodes a file written by a FileStorage (a snapshot, an
+// archived or delta snapshot, or an op log) into its records, for
+// inspection tools. The file is only read: unlike opening the log through
+// a FileStorage, a torn tail is reported, not cut off. A damaged record
+// ends the list, and ReadFile returns the records before it with an error
+// wrapping ErrCorrupt.
+func ReadFile(path string) ([]Op, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var records []Op
+	for {
+		seq, data, err := readRecord(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrCorrupt) {
+			return records, fmt.Errorf("%w: %s: record %d: %w", ErrCorrupt, path, len(records)+1, err)
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, Op{Seq: seq, Data: data})
+	}
+}
+
+// DocumentFiles returns the files of a FileStorage document directory in
+// the order Restore applies them: the snapshot, the delta snapshots, then
+// the op log. Archived snapshots are left out. Together with ReadFile it
+// lets tools read a document without opening the storage.
+func DocumentFiles(dir string) ([]string, error) {
+	deltas, err := filepath.Glob(filepath.Join(dir, "delta.*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(deltas) // seqs are zero-padded
+	var files []string
+	for _, name := range append(append([]string{snapshotFile}, deltas...), opsFile) {
+		name = filepath.Join(dir, filepath.Base(name))
+		if _, err := os.Stat(name); err == nil {
+			files = append(files, name)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s holds no snapshot or op log", ErrNotFound, dir)
+	}
+	return files, nil
+}
+
+// LoadSnapshot returns the snapshot of key, or ErrNotFound.
+func (s *FileStorage) LoadSnapshot(key string) ([]byte, uint64, error) {
+	f, err := os.Open(filepath.Join(s.docDir(key), snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, fmt.Errorf("%w: snapshot of %q", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	seq, data, err := readRecord(bufio.NewReader(f))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: snapshot of %q: %w", ErrCorrupt, key, err)
+	}
+	return data, seq, nil
+}
+
+// log returns the open log of key, opening it and cutting off a torn tail
+// on first use. It must be called with s.mu held.
+func (s *FileStorage) log(key string) (*opLog, error) {
+	if l, ok := s.logs[key]; ok {
+		return l, nil
+	}
+	dir := s.docDir(key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, opsFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &opLog{file: f}
+	if data, err := os.ReadFile(filepath.Join(dir, baseFile)); err == nil {
+		l.last, _ = strconv.ParseUint(string(data), 10, 64)
+	}
+	r := bufio.NewReader(f)
+	var valid int64
+	for {
+		seq, data, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !torn(r, err) {
+				f.Close()
+				return nil, fmt.Errorf("storage: op log of %q: record at offset %d: %w", key, valid, err)
+			}
+			break // a torn append, cut off below
+		}
+		l.last = seq
+		valid += recordHeader + int64(len(data))
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.logs[key] = l
+	return l, nil
+}
+
+// torn reports whether err, returned by readRecord, is a torn append: a
+// record running past the end of the log, or a damaged last record.
+func torn(r *bufio.Reader, err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if !errors.Is(err, ErrCorrupt) {
+		return false
+	}
+	_, err = r.Peek(1)
+	return err == io.EOF
+}
+
+// AppendOps appends ops to the log of key and syncs it.
+func (s *FileStorage) AppendOps(key string, ops ...[]byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, err := s.log(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(ops) == 0 {
+		return l.last, nil
+	}
+
+	var buf []byte
+	seq := l.last
+	for _, op := range ops {
+		seq++
+		buf = appendRecord(buf, seq, op)
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := l.file.Sync(); err != nil {
+		return 0, err
+	}
+	l.last = seq
+	return seq, nil
+}
+
+// ReadOpsSince returns the operations of key after seq.
+func (s *FileStorage) ReadOpsSince(key string, seq uint64) ([]Op, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.log(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(s.docDir(key), opsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var ops []Op
+	for {
+		opSeq, data, err := readRecord(r)
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("storage: read ops of %q: %w", key, err)
+		}
+		if opSeq > seq {
+			ops = append(ops, Op{Seq: opSeq, Data: data})
+		}
+	}
+}
+
+// TruncateOps rewrites the log of key without the operations up to seq.
+// The new log replaces the old one atomically.
+func (s *FileStorage) TruncateOps(key string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, err := s.log(key)
+	if err != nil {
+		return err
+	}
+
+	dir := s.docDir(key)
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var kept []byte
+	r := bufio.NewReader(l.file)
+	for {
+		opSeq, data, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("storage: truncate ops of %q: %w", key, err)
+		}
+		if opSeq > seq {
+			kept = appendRecord(kept, opSeq, data)
+		}
+	}
+
+	// Remember where numbering stands in case no record is left.
+	base := []byte(strconv.FormatUint(min(seq, l.last), 10))
+	if err := writeFileAtomic(filepath.Join(dir, baseFile), base); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, opsFile), kept); err != nil {
+		return err
+	}
+	l.file.Close()
+	delete(s.logs, key)
+	_, err = s.log(key)
+	return err
+}
+
+// archivedName returns the file name of the archived snapshot seq.
+func archivedName(seq uint64) string {
+	return fmt.Sprintf("%s.%020d", snapshotFile, seq)
+}
+
+// ArchiveSnapshot stores a copy of a snapshot next to the current one.
+func (s *FileStorage) ArchiveSnapshot(key string, seq uint64, state []byte) error {
+	dir := s.docDir(key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, archivedName(seq)), appendRecord(nil, seq, state))
+}
+
+// ArchivedSnapshots returns the seqs of the archived snapshots of key.
+func (s *FileStorage) ArchivedSnapshots(key string) ([]uint64, error) {
+	return s.numbered(key, snapshotFile+".")
+}
+
+// LoadArchivedSnapshot returns an archived snapshot, or ErrNotFound.
+func (s *FileStorage) LoadArchivedSnapshot(key string, seq uint64) ([]byte, error) {
+	f, err := os.Open(filepath.Join(s.docDir(key), archivedName(seq)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: snapshot %d of %q", ErrNotFound, seq, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, data, err := readRecord(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%w: snapshot %d of %q: %w", ErrCorrupt, seq, key, err)
+	}
+	return data, nil
+}
+
+// DeleteArchivedSnapshot removes an archived snapshot.
+func (s *FileStorage) DeleteArchivedSnapshot(key string, seq uint64) error {
+	err := os.Remove(filepath.Join(s.docDir(key), archivedName(seq)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// deltaName returns the file name of the delta snapshot seq.
+func deltaName(seq uint64) string {
+	return fmt.Sprintf("delta.%020d", seq)
+}
+
+// SaveDeltaSnapshot stores a delta snapshot next to the base snapshot.
+func (s *FileStorage) SaveDeltaSnapshot(key string, seq uint64, delta []byte) error {
+	dir := s.docDir(key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, deltaName(seq)), appendRecord(nil, seq, delta))
+}
+
+// LoadDeltaSnapshots returns the delta snapshots of key.
+func (s *FileStorage) LoadDeltaSnapshots(key string) ([]Op, error) {
+	seqs, err := s.numbered(key, "delta.")
+	if err != nil {
+		return nil, err
+	}
+	deltas := make([]Op, 0, len(seqs))
+	for _, seq := range seqs {
+		data, err := os.ReadFile(filepath.Join(s.docDir(key), deltaName(seq)))
+		if err != nil {
+			return nil, err
+		}
+		_, delta, err := readRecord(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: delta snapshot %d of %q: %w", ErrCorrupt, seq, key, err)
+		}
+		deltas = append(deltas, Op{Seq: seq, Data: delta})
+	}
+	return deltas, nil
+}
+
+// DeleteDeltaSnapshots removes the delta snapshots of key up to seq.
+func (s *FileStorage) DeleteDeltaSnapshots(key string, seq uint64) error {
+	seqs, err := s.numbered(key, "delta.")
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, n := range seqs {
+		if n <= seq {
+			errs = append(errs, os.Remove(filepath.Join(s.docDir(key), deltaName(n))))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// numbered returns the ascending seqs of the files of key named prefix
+// followed by a seq, skipping temporary files.
+func (s *FileStorage) numbered(key, prefix string) ([]uint64, error) {
+	entries, err := os.ReadDir(s.docDir(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if seq, err := strconv.ParseUint(suffix, 10, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// Close closes every open log.
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for key, l := range s.logs {
+		errs = append(errs, l.file.Close())
+		delete(s.logs, key)
+	}
+	return errors.Join(errs...)
+}
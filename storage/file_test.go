@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestFileStorage_SnapshotsAndOps(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LoadSnapshot("doc/../1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if err := s.SaveSnapshot("doc/../1", 2, []byte("state")); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.AppendOps("doc/../1", []byte("a"), []byte("b"), []byte("c")); err != nil || last != 3 {
+		t.Fatalf("AppendOps returned %d, %v", last, err)
+	}
+
+	state, seq, err := s.LoadSnapshot("doc/../1")
+	if err != nil || string(state) != "state" || seq != 2 {
+		t.Errorf("Unexpected snapshot %q at %d: %v", state, seq, err)
+	}
+	ops, err := s.ReadOpsSince("doc/../1", seq)
+	if err != nil || len(ops) != 1 || ops[0].Seq != 3 || string(ops[0].Data) != "c" {
+		t.Errorf("Unexpected ops %+v: %v", ops, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of an append: the torn record is cut
+	// off and numbering resumes after the last complete one.
+	entries, _ := os.ReadDir(dir)
+	log := filepath.Join(dir, entries[0].Name(), opsFile)
+	f, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(appendRecord(nil, 4, []byte("torn"))[:recordHeader+2])
+	f.Close()
+
+	// ReadFile reports the torn record without cutting it off.
+	records, err := ReadFile(log)
+	if !errors.Is(err, ErrCorrupt) || len(records) != 3 || records[2].Seq != 3 {
+		t.Errorf("ReadFile returned %+v, %v", records, err)
+	}
+	if records, err := ReadFile(log); len(records) != 3 || err == nil {
+		t.Errorf("Expected ReadFile to leave the log intact, got %+v, %v", records, err)
+	}
+
+	s, _ = NewFileStorage(dir)
+	defer s.Close()
+	if last, err := s.AppendOps("doc/../1", []byte("d")); err != nil || last != 4 {
+		t.Fatalf("Expected numbering to resume at 4, got %d, %v", last, err)
+	}
+	ops, err = s.ReadOpsSince("doc/../1", 0)
+	if err != nil || len(ops) != 4 || string(ops[3].Data) != "d" {
+		t.Errorf("Unexpected ops after recovery %+v: %v", ops, err)
+	}
+}
+
+func TestFileStorage_CorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewFileStorage(dir)
+	s.AppendOps("doc", []byte("a"), []byte("b"), []byte("c"))
+	s.Close()
+	log := filepath.Join(s.docDir("doc"), opsFile)
+	intact, _ := os.ReadFile(log)
+
+	// A torn header claiming a huge payload is cut off like any torn tail.
+	huge := appendRecord(nil, 4, nil)
+	binary.BigEndian.PutUint32(huge[8:], math.MaxUint32)
+	os.WriteFile(log, append(slices.Clip(intact), huge...), 0o644)
+	s, _ = NewFileStorage(dir)
+	if last, err := s.AppendOps("doc"); err != nil || last != 3 {
+		t.Errorf("Expected the torn header cut off, got %d, %v", last, err)
+	}
+	s.Close()
+
+	// A damaged record before the end keeps the ops after it.
+	damaged := slices.Clone(intact)
+	damaged[recordHeader] ^= 0xff
+	os.WriteFile(log, damaged, 0o644)
+	s, _ = NewFileStorage(dir)
+	defer s.Close()
+	if _, err := s.AppendOps("doc", []byte("d")); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+	if data, _ := os.ReadFile(log); !bytes.Equal(data, damaged) {
+		t.Errorf("Expected the damaged log left alone, got %d bytes", len(data))
+	}
+}
+
+func TestDocumentFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SaveSnapshot("doc", 1, []byte("a"))
+	s.ArchiveSnapshot("doc", 1, []byte("a"))
+	s.SaveDeltaSnapshot("doc", 12, []byte("c"))
+	s.SaveDeltaSnapshot("doc", 3, []byte("b"))
+	s.AppendOps("doc", []byte("d"))
+
+	files, err := DocumentFiles(s.docDir("doc"))
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	want := []string{snapshotFile, deltaName(3), deltaName(12), opsFile}
+	if err != nil || !slices.Equal(names, want) {
+		t.Errorf("DocumentFiles returned %v, %v, want %v", names, err, want)
+	}
+	if _, err := DocumentFiles(t.TempDir()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an empty directory, got %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	doc := gocrdt.NewRGA("alice")
+	a := doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	snapshot, cursor, _ := doc.MarshalChanges(0)
+	if err := s.SaveSnapshot("doc", 0, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	doc.Insert('b', a)
+	doc.Delete(a)
+	delta, _, _ := doc.MarshalChanges(cursor)
+	if _, err := s.AppendOps("doc", delta); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := gocrdt.NewRGA("alice")
+	seq, err := Restore(s, "doc", restored)
+	if err != nil || seq != 1 || restored.Value() != doc.Value() {
+		t.Errorf("Restored %q at %d (%v), want %q", restored.Value(), seq, err, doc.Value())
+	}
+	if seq, err := Restore(s, "unknown", gocrdt.NewRGA("x")); err != nil || seq != 0 {
+		t.Errorf("Expected an empty restore for unknown keys, got %d, %v", seq, err)
+	}
+}
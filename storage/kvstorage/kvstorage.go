@@ -0,0 +1,219 @@
+// Package kvstorage implements storage.Storage on an embedded key-value
+// store such as bbolt or Badger, the recommended durable backend for
+// single-binary deployments.
+//
+// Every document gets its own bucket holding a "snapshot" key and one
+// "op/<seq>" key per logged operation, with seq big-endian so that keys
+// sort in log order. Writes run in one transaction each, so an operation
+// batch is either fully logged or not at all.
+//
+// The package does not import a database driver. It uses the small DB, Tx
+// and Bucket interfaces, which adapt from bbolt in a few lines:
+//
+//	type boltDB struct{ *bolt.DB }
+//
+//	func (db boltDB) Update(fn func(kvstorage.Tx) error) error {
+//		return db.DB.Update(func(tx *bolt.Tx) error { return fn(boltTx{tx}) })
+//	}
+//
+//	func (db boltDB) View(fn func(kvstorage.Tx) error) error {
+//		return db.DB.View(func(tx *bolt.Tx) error { return fn(boltTx{tx}) })
+//	}
+//
+//	type boltTx struct{ *bolt.Tx }
+//
+//	func (tx boltTx) Bucket(name []byte, create bool) (kvstorage.Bucket, error) {
+//		if create {
+//			b, err := tx.Tx.CreateBucketIfNotExists(name)
+//			return boltBucket{b}, err
+//		}
+//		if b := tx.Tx.Bucket(name); b != nil {
+//			return boltBucket{b}, nil
+//		}
+//		return nil, nil
+//	}
+//
+//	type boltBucket struct{ *bolt.Bucket }
+//
+//	func (b boltBucket) Scan(from []byte, fn func(k, v []byte) bool) error {
+//		c := b.Bucket.Cursor()
+//		for k, v := c.Seek(from); k != nil && fn(k, v); k, v = c.Next() {
+//		}
+//		return nil
+//	}
+//
+// Badger has no buckets; its adapter prefixes keys with the bucket name.
+package kvstorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// DB is an embedded key-value database with serializable transactions.
+type DB interface {
+	// Update runs fn in a read-write transaction, committed if fn returns
+	// nil and durable once Update returns.
+	Update(fn func(Tx) error) error
+
+	// View runs fn in a read-only transaction.
+	View(fn func(Tx) error) error
+}
+
+// Tx is a transaction.
+type Tx interface {
+	// Bucket returns the bucket name, creating it if create is set. A
+	// missing bucket is returned as nil when create is not set.
+	Bucket(name []byte, create bool) (Bucket, error)
+}
+
+// Bucket is a sorted key space. Values returned by Get and Scan are only
+// valid during the transaction.
+type Bucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+
+	// Scan calls fn for every key greater than or equal to from, in
+	// ascending order, until fn returns false.
+	Scan(from []byte, fn func(key, value []byte) bool) error
+}
+
+var (
+	snapshotKey = []byte("snapshot")
+	lastKey     = []byte("last") // sequence number of the last logged op
+	opPrefix    = []byte("op/")
+	bucketStart = []byte("doc:")
+)
+
+// Storage is a storage.Storage on a DB.
+type Storage struct {
+	db DB
+}
+
+// New creates a Storage on db.
+func New(db DB) *Storage {
+	return &Storage{db: db}
+}
+
+func bucketName(key string) []byte {
+	return append(bytes.Clone(bucketStart), key...)
+}
+
+func opKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(bytes.Clone(opPrefix), seq)
+}
+
+// SaveSnapshot replaces the snapshot of key. The value is the seq followed
+// by the state.
+func (s *Storage) SaveSnapshot(key string, seq uint64, state []byte) error {
+	return s.db.Update(func(tx Tx) error {
+		b, err := tx.Bucket(bucketName(key), true)
+		if err != nil {
+			return err
+		}
+		return b.Put(snapshotKey, append(binary.BigEndian.AppendUint64(nil, seq), state...))
+	})
+}
+
+// LoadSnapshot returns the snapshot of key, or storage.ErrNotFound.
+func (s *Storage) LoadSnapshot(key string) ([]byte, uint64, error) {
+	var state []byte
+	var seq uint64
+	err := s.db.View(func(tx Tx) error {
+		b, err := tx.Bucket(bucketName(key), false)
+		if err != nil {
+			return err
+		}
+		var value []byte
+		if b != nil {
+			value = b.Get(snapshotKey)
+		}
+		if value == nil {
+			return fmt.Errorf("%w: snapshot of %q", storage.ErrNotFound, key)
+		}
+		if len(value) < 8 {
+			return fmt.Errorf("kvstorage: malformed snapshot of %q", key)
+		}
+		seq = binary.BigEndian.Uint64(value)
+		state = bytes.Clone(value[8:])
+		return nil
+	})
+	return state, seq, err
+}
+
+// AppendOps appends ops to the log of key in one transaction.
+func (s *Storage) AppendOps(key string, ops ...[]byte) (uint64, error) {
+	var last uint64
+	err := s.db.Update(func(tx Tx) error {
+		b, err := tx.Bucket(bucketName(key), true)
+		if err != nil {
+			return err
+		}
+		if value := b.Get(lastKey); len(value) == 8 {
+			last = binary.BigEndian.Uint64(value)
+		}
+		for _, op := range ops {
+			last++
+			if err := b.Put(opKey(last), op); err != nil {
+				return err
+			}
+		}
+		return b.Put(lastKey, binary.BigEndian.AppendUint64(nil, last))
+	})
+	return last, err
+}
+
+// ReadOpsSince returns the operations of key after seq.
+func (s *Storage) ReadOpsSince(key string, seq uint64) ([]storage.Op, error) {
+	var ops []storage.Op
+	err := s.db.View(func(tx Tx) error {
+		b, err := tx.Bucket(bucketName(key), false)
+		if err != nil || b == nil {
+			return err
+		}
+		return b.Scan(opKey(seq+1), func(k, v []byte) bool {
+			if !bytes.HasPrefix(k, opPrefix) || len(k) != len(opPrefix)+8 {
+				return false
+			}
+			ops = append(ops, storage.Op{Seq: binary.BigEndian.Uint64(k[len(opPrefix):]), Data: bytes.Clone(v)})
+			return true
+		})
+	})
+	return ops, err
+}
+
+// TruncateOps deletes the operations of key up to seq.
+func (s *Storage) TruncateOps(key string, seq uint64) error {
+	return s.db.Update(func(tx Tx) error {
+		b, err := tx.Bucket(bucketName(key), false)
+		if err != nil || b == nil {
+			return err
+		}
+		var keys [][]byte
+		err = b.Scan(opKey(0), func(k, _ []byte) bool {
+			if !bytes.HasPrefix(k, opPrefix) || bytes.Compare(k, opKey(seq)) > 0 {
+				return false
+			}
+			keys = append(keys, bytes.Clone(k))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close does nothing: the DB belongs to the caller.
+func (s *Storage) Close() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package kvstorage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// memDB is a DB kept in maps. Update works on a copy that replaces the
+// data only on success, like a committed transaction.
+type memDB struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+type memTx struct {
+	buckets map[string]map[string][]byte
+}
+
+type memBucket map[string][]byte
+
+func (db *memDB) Update(fn func(Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tx := memTx{buckets: make(map[string]map[string][]byte)}
+	for name, b := range db.buckets {
+		tx.buckets[name] = make(map[string][]byte)
+		for k, v := range b {
+			tx.buckets[name][k] = v
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	db.buckets = tx.buckets
+	return nil
+}
+
+func (db *memDB) View(fn func(Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return fn(memTx{buckets: db.buckets})
+}
+
+func (tx memTx) Bucket(name []byte, create bool) (Bucket, error) {
+	b, ok := tx.buckets[string(name)]
+	if !ok {
+		if !create {
+			return nil, nil
+		}
+		b = make(map[string][]byte)
+		tx.buckets[string(name)] = b
+	}
+	return memBucket(b), nil
+}
+
+func (b memBucket) Get(key []byte) []byte { return b[string(key)] }
+
+func (b memBucket) Put(key, value []byte) error {
+	b[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (b memBucket) Delete(key []byte) error {
+	delete(b, string(key))
+	return nil
+}
+
+func (b memBucket) Scan(from []byte, fn func(k, v []byte) bool) error {
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		if k >= string(from) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !fn([]byte(k), b[k]) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestStorage(t *testing.T) {
+	var s storage.Storage = New(&memDB{})
+	if _, _, err := s.LoadSnapshot("doc"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	doc := gocrdt.NewRGA("alice")
+	a := doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	snapshot, cursor, _ := doc.MarshalChanges(0)
+	if err := s.SaveSnapshot("doc", 0, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	var seqs []uint64
+	var delta []byte
+	for _, r := range "bcd" {
+		a = doc.Insert(r, a)
+		delta, cursor, _ = doc.MarshalChanges(cursor)
+		seq, err := s.AppendOps("doc", delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, seq)
+	}
+	if seqs[0] != 1 || seqs[2] != 3 {
+		t.Errorf("Unexpected sequence numbers %v", seqs)
+	}
+	if _, err := s.AppendOps("other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ops, err := s.ReadOpsSince("doc", 2); err != nil || len(ops) != 1 || ops[0].Seq != 3 {
+		t.Errorf("Unexpected ops %+v: %v", ops, err)
+	}
+	restored := gocrdt.NewRGA("alice")
+	if seq, err := storage.Restore(s, "doc", restored); err != nil || seq != 3 || restored.Value() != doc.Value() {
+		t.Errorf("Restored %q at %d (%v), want %q", restored.Value(), seq, err, doc.Value())
+	}
+	if err := s.(storage.Truncater).TruncateOps("doc", 2); err != nil {
+		t.Fatal(err)
+	}
+	if ops, _ := s.ReadOpsSince("doc", 0); len(ops) != 1 || ops[0].Seq != 3 {
+		t.Errorf("Unexpected ops after truncation %+v", ops)
+	}
+	if seq, _ := s.AppendOps("doc", delta); seq != 4 {
+		t.Errorf("Expected numbering to continue after truncation, got %d", seq)
+	}
+	if ops, err := s.ReadOpsSince("missing", 0); err != nil || len(ops) != 0 {
+		t.Errorf("Expected no ops for a missing document, got %+v, %v", ops, err)
+	}
+}
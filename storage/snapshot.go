@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultSnapshotEveryOps is the checkpoint threshold used when neither
+// SnapshotConfig.EveryOps nor SnapshotConfig.Every is set.
+const DefaultSnapshotEveryOps = 1000
+
+// Truncater is implemented by storages that can discard logged operations
+// covered by a snapshot.
+type Truncater interface {
+	// TruncateOps deletes the operations of key up to and including seq.
+	// Sequence numbering continues after the deleted operations.
+	TruncateOps(key string, seq uint64) error
+}
+
+// Archive is implemented by storages that keep past snapshots besides the
+// current one.
+type Archive interface {
+	// ArchiveSnapshot stores a copy of a snapshot, identified by its seq.
+	ArchiveSnapshot(key string, seq uint64, state []byte) error
+
+	// ArchivedSnapshots returns the seqs of the archived snapshots of key,
+	// ascending.
+	ArchivedSnapshots(key string) ([]uint64, error)
+
+	// LoadArchivedSnapshot returns an archived snapshot, or ErrNotFound.
+	LoadArchivedSnapshot(key string, seq uint64) ([]byte, error)
+
+	// DeleteArchivedSnapshot removes an archived snapshot.
+	DeleteArchivedSnapshot(key string, seq uint64) error
+}
+
+// DeltaSnapshots is implemented by storages that can store incremental
+// snapshots: deltas applied over the current snapshot, which then only
+// serves as their base.
+type DeltaSnapshots interface {
+	// SaveDeltaSnapshot stores a delta covering the operations up to seq.
+	SaveDeltaSnapshot(key string, seq uint64, delta []byte) error
+
+	// LoadDeltaSnapshots returns the delta snapshots of key, by ascending
+	// seq.
+	LoadDeltaSnapshots(key string) ([]Op, error)
+
+	// DeleteDeltaSnapshots removes the delta snapshots up to seq.
+	DeleteDeltaSnapshots(key string, seq uint64) error
+}
+
+// SnapshotConfig sets when a Snapshotter checkpoints.
+type SnapshotConfig struct {
+	// EveryOps checkpoints once that many ops were logged since the last
+	// checkpoint.
+	EveryOps int
+
+	// Every checkpoints periodically while ops are being logged.
+	Every time.Duration
+
+	// Retain is the number of past snapshots kept in the storage's Archive,
+	// the newest first. Zero archives nothing; storages without an Archive
+	// only keep the current snapshot.
+	Retain int
+
+	// Incremental, when positive, saves up to that many delta snapshots
+	// between two full ones, for storages implementing DeltaSnapshots and
+	// states with a change log (gocrdt.RGA). A delta only holds the nodes
+	// changed since the previous checkpoint, which keeps the storage churn
+	// of huge documents low; Restore applies them over the full snapshot.
+	Incremental int
+
+	// OnError, when set, receives the errors of checkpoints made by Run,
+	// which are otherwise dropped.
+	OnError func(error)
+}
+
+// Snapshotter checkpoints a document logged by a WAL: it saves a snapshot
+// of the current state, then truncates the op log below it when the
+// storage is a Truncater, keeping restarts fast and the log small.
+type Snapshotter struct {
+	wal    *WAL
+	config SnapshotConfig
+
+	mu     sync.Mutex
+	seq    uint64 // seq of the last checkpoint
+	last   time.Time
+	deltas int    // delta snapshots saved since the last full one
+	cursor uint64 // change log position of the last checkpoint
+	based  bool   // whether cursor is valid: a checkpoint was made here
+}
+
+// NewSnapshotter creates a Snapshotter for the document of wal.
+func NewSnapshotter(wal *WAL, config SnapshotConfig) *Snapshotter {
+	if config.EveryOps <= 0 && config.Every <= 0 {
+		config.EveryOps = DefaultSnapshotEveryOps
+	}
+	_, seq, _ := wal.storage.LoadSnapshot(wal.key)
+	if ds, ok := wal.storage.(DeltaSnapshots); ok {
+		if deltas, err := ds.LoadDeltaSnapshots(wal.key); err == nil && len(deltas) > 0 {
+			seq = max(seq, deltas[len(deltas)-1].Seq)
+		}
+	}
+	return &Snapshotter{wal: wal, config: config, seq: seq, last: time.Now()}
+}
+
+// Checkpoint commits pending changes, saves a snapshot covering them and
+// truncates the log. It returns the seq of the snapshot.
+func (s *Snapshotter) Checkpoint() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoint()
+}
+
+func (s *Snapshotter) checkpoint() (uint64, error) {
+	w := s.wal
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seq, err := w.commit()
+	if err != nil {
+		return 0, err
+	}
+	if ds, delta, ok := s.incremental(); ok {
+		return s.deltaCheckpoint(ds, delta, seq)
+	}
+
+	// Changes made after commit may slip into the state. Replaying their
+	// ops over the snapshot later is harmless: merges are idempotent.
+	state, err := w.state.MarshalState()
+	if err != nil {
+		return 0, err
+	}
+	if err := w.storage.SaveSnapshot(w.key, seq, state); err != nil {
+		return 0, err
+	}
+	s.seq, s.last, s.deltas = seq, time.Now(), 0
+	if delta, ok := w.state.(deltaState); ok {
+		_, s.cursor, _ = delta.MarshalChanges(math.MaxUint64)
+		s.based = true
+	}
+
+	var errs []error
+	if ds, ok := w.storage.(DeltaSnapshots); ok {
+		errs = append(errs, ds.DeleteDeltaSnapshots(w.key, seq))
+	}
+	if archive, ok := w.storage.(Archive); ok && s.config.Retain > 0 {
+		errs = append(errs, s.archive(archive, seq, state))
+	}
+	if truncater, ok := w.storage.(Truncater); ok {
+		errs = append(errs, truncater.TruncateOps(w.key, seq))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return seq, fmt.Errorf("storage: checkpoint of %q saved, cleanup failed: %w", w.key, err)
+	}
+	return seq, nil
+}
+
+// incremental reports whether the next checkpoint may be a delta.
+func (s *Snapshotter) incremental() (DeltaSnapshots, deltaState, bool) {
+	ds, ok := s.wal.storage.(DeltaSnapshots)
+	delta, isDelta := s.wal.state.(deltaState)
+	if !ok || !isDelta || !s.based || s.deltas >= s.config.Incremental {
+		return nil, nil, false
+	}
+	return ds, delta, true
+}
+
+// deltaCheckpoint saves the changes since the last checkpoint as a delta
+// snapshot covering seq. It must be called with the WAL locked.
+func (s *Snapshotter) deltaCheckpoint(ds DeltaSnapshots, delta deltaState, seq uint64) (uint64, error) {
+	w := s.wal
+	payload, cursor, err := delta.MarshalChanges(s.cursor)
+	if err != nil {
+		return 0, err
+	}
+	if payload != nil {
+		if err := ds.SaveDeltaSnapshot(w.key, seq, payload); err != nil {
+			return 0, err
+		}
+		s.deltas++
+	}
+	s.seq, s.last, s.cursor = seq, time.Now(), cursor
+	if truncater, ok := w.storage.(Truncater); ok {
+		if err := truncater.TruncateOps(w.key, seq); err != nil {
+			return seq, fmt.Errorf("storage: checkpoint of %q saved, cleanup failed: %w", w.key, err)
+		}
+	}
+	return seq, nil
+}
+
+// archive stores state and drops archived snapshots beyond Retain.
+func (s *Snapshotter) archive(archive Archive, seq uint64, state []byte) error {
+	key := s.wal.key
+	if err := archive.ArchiveSnapshot(key, seq, state); err != nil {
+		return err
+	}
+	seqs, err := archive.ArchivedSnapshots(key)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(seqs) > s.config.Retain {
+		errs = append(errs, archive.DeleteArchivedSnapshot(key, seqs[0]))
+		seqs = seqs[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// MaybeCheckpoint checkpoints if the configured number of ops or amount of
+// time has passed since the last checkpoint, and reports whether it did.
+// Nothing is checkpointed while no op was logged.
+func (s *Snapshotter) MaybeCheckpoint() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.wal.Seq() - s.seq
+	if pending == 0 {
+		return false, nil
+	}
+	byOps := s.config.EveryOps > 0 && pending >= uint64(s.config.EveryOps)
+	byTime := s.config.Every > 0 && time.Since(s.last) >= s.config.Every
+	if !byOps && !byTime {
+		return false, nil
+	}
+	_, err := s.checkpoint()
+	return true, err
+}
+
+// Close checkpoints the changes logged since the last checkpoint, if any,
+// and closes the WAL, so the next start restores from a snapshot without
+// replaying ops. Stop Run before closing. Closing twice is a no-op.
+func (s *Snapshotter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.wal
+	seq, err := w.Commit()
+	switch {
+	case errors.Is(err, ErrClosed):
+		return nil
+	case err != nil:
+		return err
+	}
+	if seq > s.seq {
+		if _, err := s.checkpoint(); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Run calls MaybeCheckpoint every interval until ctx is done, then returns
+// ctx.Err(). The interval is Every, or one second when only EveryOps is
+// set.
+func (s *Snapshotter) Run(ctx context.Context) error {
+	interval := s.config.Every
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.MaybeCheckpoint(); err != nil && s.config.OnError != nil {
+				s.config.OnError(err)
+			}
+		}
+	}
+}
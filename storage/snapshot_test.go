@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestSnapshotter_CheckpointsAndTruncates(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := gocrdt.NewRGA("alice")
+	wal, err := OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := NewSnapshotter(wal, SnapshotConfig{EveryOps: 3, Retain: 2})
+
+	last := gocrdt.ID{NodeID: "root"}
+	checkpoints := 0
+	for _, r := range "abcdefghi" {
+		last = doc.Insert(r, last)
+		if _, err := wal.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		done, err := snap.MaybeCheckpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done {
+			checkpoints++
+		}
+	}
+	if checkpoints != 3 {
+		t.Errorf("Expected a checkpoint every 3 ops, got %d", checkpoints)
+	}
+	if ops, _ := s.ReadOpsSince("doc", 0); len(ops) != 0 {
+		t.Errorf("Expected the log to be truncated, %d ops left", len(ops))
+	}
+	if seqs, _ := s.ArchivedSnapshots("doc"); len(seqs) != 2 || seqs[0] != 6 || seqs[1] != 9 {
+		t.Errorf("Expected the two newest snapshots to be retained, got %v", seqs)
+	}
+	if old, err := s.LoadArchivedSnapshot("doc", 6); err != nil || len(old) == 0 {
+		t.Errorf("Cannot load archived snapshot: %v", err)
+	}
+
+	// Numbering survives a restart with an empty log.
+	doc.Insert('j', last)
+	s.Close()
+	s, _ = NewFileStorage(dir)
+	defer s.Close()
+	recovered := gocrdt.NewRGA("alice")
+	wal, err = OpenWAL(s, "doc", recovered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Value() != "abcdefghi" || wal.Seq() != 9 {
+		t.Errorf("Recovered %q at %d", recovered.Value(), wal.Seq())
+	}
+	recovered.Insert('j', last)
+	if seq, _ := wal.Commit(); seq != 10 {
+		t.Errorf("Expected numbering to continue at 10, got %d", seq)
+	}
+}
+
+func TestSnapshotter_Close(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	doc := gocrdt.NewRGA("alice")
+	wal, err := OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := NewSnapshotter(wal, SnapshotConfig{EveryOps: 100})
+	doc.Insert('y', doc.Insert('x', gocrdt.ID{NodeID: "root"}))
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, seq, err := s.LoadSnapshot("doc"); err != nil || seq != 1 {
+		t.Errorf("Expected a final checkpoint at 1, got %d, %v", seq, err)
+	}
+	if ops, _ := s.ReadOpsSince("doc", 0); len(ops) != 0 {
+		t.Errorf("Expected the log truncated, %d ops left", len(ops))
+	}
+	if err := snap.Close(); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+}
+
+func TestSnapshotter_ByTime(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	counter := gocrdt.NewGCounter("alice")
+	wal, _ := OpenWAL(s, "c", counter)
+	snap := NewSnapshotter(wal, SnapshotConfig{Every: time.Millisecond})
+
+	time.Sleep(2 * time.Millisecond)
+	if done, _ := snap.MaybeCheckpoint(); done {
+		t.Error("Expected no checkpoint without logged ops")
+	}
+	counter.Increment()
+	wal.Commit()
+	if done, err := snap.MaybeCheckpoint(); !done || err != nil {
+		t.Errorf("Expected a timed checkpoint, got %v, %v", done, err)
+	}
+	if _, seq, err := s.LoadSnapshot("c"); err != nil || seq != 1 {
+		t.Errorf("Expected a snapshot at 1, got %d, %v", seq, err)
+	}
+}
+
+func TestSnapshotter_Incremental(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := gocrdt.NewRGA("alice")
+	wal, _ := OpenWAL(s, "doc", doc)
+	snap := NewSnapshotter(wal, SnapshotConfig{EveryOps: 1, Incremental: 2})
+
+	last := gocrdt.ID{NodeID: "root"}
+	var deltas []int
+	for _, r := range "abcde" {
+		last = doc.Insert(r, last)
+		wal.Commit()
+		if _, err := snap.MaybeCheckpoint(); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := s.LoadDeltaSnapshots("doc")
+		deltas = append(deltas, len(stored))
+	}
+	// full, delta, delta, full, delta
+	want := []int{0, 1, 2, 0, 1}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Fatalf("Expected delta snapshots %v, got %v", want, deltas)
+		}
+	}
+	stored, _ := s.LoadDeltaSnapshots("doc")
+	if len(stored[0].Data) > 200 {
+		t.Errorf("Delta snapshot holds more than the last change: %s", stored[0].Data)
+	}
+	s.Close()
+
+	s, _ = NewFileStorage(dir)
+	defer s.Close()
+	recovered := gocrdt.NewRGA("alice")
+	wal, err = OpenWAL(s, "doc", recovered)
+	if err != nil || recovered.Value() != "abcde" || wal.Seq() != 5 {
+		t.Errorf("Recovered %q at %d: %v", recovered.Value(), wal.Seq(), err)
+	}
+}
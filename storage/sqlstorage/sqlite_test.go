@@ -0,0 +1,100 @@
+//go:build sqlite
+
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// TestSQLite runs the schema and statements against a real SQLite engine:
+//
+//	go test -tags sqlite ./storage/sqlstorage
+func TestSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := New(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The schema is idempotent.
+	if _, err := New(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := gocrdt.NewRGA("alice")
+	a := doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	first, cursor, _ := doc.MarshalChanges(0)
+	if err := s.SaveSnapshot("doc", 0, []byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSnapshot("doc", 0, first); err != nil {
+		t.Fatal(err)
+	}
+	doc.Insert('b', a)
+	delta, _, _ := doc.MarshalChanges(cursor)
+	if seq, err := s.AppendOps("doc", delta, delta); err != nil || seq != 2 {
+		t.Fatalf("AppendOps returned %d, %v", seq, err)
+	}
+	if seq, err := s.AppendOps("doc", delta); err != nil || seq != 3 {
+		t.Fatalf("AppendOps returned %d, %v", seq, err)
+	}
+	if seq, err := s.AppendOps("other", delta); err != nil || seq != 1 {
+		t.Fatalf("AppendOps on another document returned %d, %v", seq, err)
+	}
+
+	restored := gocrdt.NewRGA("alice")
+	if seq, err := storage.Restore(s, "doc", restored); err != nil || seq != 3 || restored.Value() != "ab" {
+		t.Errorf("Restored %q at %d: %v", restored.Value(), seq, err)
+	}
+	if err := s.TruncateOps("doc", 2); err != nil {
+		t.Fatal(err)
+	}
+	if ops, _ := s.ReadOpsSince("doc", 0); len(ops) != 1 || ops[0].Seq != 3 {
+		t.Errorf("Unexpected ops after truncation %+v", ops)
+	}
+	if seq, err := s.AppendOps("doc", delta); err != nil || seq != 4 {
+		t.Errorf("Expected the sequence to continue after truncation, got %d, %v", seq, err)
+	}
+
+	for _, cursor := range []uint64{3, 7} {
+		if err := s.RecordAck("doc", "bob", replicator.VersionVector{"alice": {Epoch: "e1", Cursor: cursor}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var acks int
+	var ackCursor int64
+	if err := db.QueryRow(`SELECT COUNT(*), MAX(cursor) FROM crdt_peer_acks WHERE doc = 'doc'`).Scan(&acks, &ackCursor); err != nil {
+		t.Fatal(err)
+	}
+	if acks != 1 || ackCursor != 7 {
+		t.Errorf("Expected one ack row at 7, got %d rows at %d", acks, ackCursor)
+	}
+
+	if err := s.RecordMerge("doc", time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	var size, lastSeq int64
+	var lastSnapshot, lastMerge sql.NullString
+	err = db.QueryRow(`SELECT size, last_seq, last_snapshot, last_merge FROM crdt_documents WHERE doc = 'doc'`).
+		Scan(&size, &lastSeq, &lastSnapshot, &lastMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(first)) || lastSeq != 4 || !lastSnapshot.Valid || lastMerge.String != "1970-01-01T00:00:00Z" {
+		t.Errorf("Unexpected document metadata %d %d %v %v", size, lastSeq, lastSnapshot, lastMerge)
+	}
+}
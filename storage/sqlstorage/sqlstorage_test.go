@@ -0,0 +1,216 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+	"github.com/cshekharsharma/go-crdt/replicator"
+	"github.com/cshekharsharma/go-crdt/storage"
+)
+
+// fakeDB is a database/sql driver that executes exactly the statements of
+// this package against maps, standing in for SQLite.
+type fakeDB struct {
+	mu        sync.Mutex
+	docs      map[string]map[string]driver.Value // crdt_documents rows by doc
+	snapshots map[string][2]driver.Value         // seq, state
+	ops       map[string]map[int64][]byte
+	acks      map[[3]string][]driver.Value
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		docs:      make(map[string]map[string]driver.Value),
+		snapshots: make(map[string][2]driver.Value),
+		ops:       make(map[string]map[int64][]byte),
+		acks:      make(map[[3]string][]driver.Value),
+	}
+}
+
+func (db *fakeDB) Open(string) (driver.Conn, error)             { return fakeConn{db}, nil }
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return db }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) doc(name string) map[string]driver.Value {
+	if _, ok := s.db.docs[name]; !ok {
+		s.db.docs[name] = map[string]driver.Value{"size": int64(0), "last_seq": int64(0)}
+	}
+	return s.db.docs[name]
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch s.query {
+	case upsertSnapshot:
+		s.db.snapshots[args[0].(string)] = [2]driver.Value{args[1], args[2]}
+	case upsertSnapshotMeta:
+		d := s.doc(args[0].(string))
+		d["size"], d["last_snapshot"] = args[1], args[2]
+	case insertOp:
+		doc := args[0].(string)
+		if s.db.ops[doc] == nil {
+			s.db.ops[doc] = make(map[int64][]byte)
+		}
+		if _, ok := s.db.ops[doc][args[1].(int64)]; ok {
+			return nil, errors.New("UNIQUE constraint failed")
+		}
+		s.db.ops[doc][args[1].(int64)] = args[2].([]byte)
+	case deleteOps:
+		for seq := range s.db.ops[args[0].(string)] {
+			if seq <= args[1].(int64) {
+				delete(s.db.ops[args[0].(string)], seq)
+			}
+		}
+	case upsertLastSeq:
+		s.doc(args[0].(string))["last_seq"] = args[1]
+	case upsertLastMerge:
+		s.doc(args[0].(string))["last_merge"] = args[1]
+	case upsertAck:
+		s.db.acks[[3]string{args[0].(string), args[1].(string), args[2].(string)}] = args[3:]
+	default:
+		if !strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS") {
+			return nil, fmt.Errorf("unexpected statement %q", s.query)
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	rows := &fakeRows{columns: []string{"seq", "data"}}
+	switch s.query {
+	case selectSnapshot:
+		if snap, ok := s.db.snapshots[args[0].(string)]; ok {
+			rows.values = append(rows.values, snap[:])
+		}
+	case selectLastSeq:
+		rows.columns = rows.columns[:1]
+		if d, ok := s.db.docs[args[0].(string)]; ok {
+			rows.values = append(rows.values, []driver.Value{d["last_seq"]})
+		}
+	case selectOps:
+		var seqs []int64
+		for seq := range s.db.ops[args[0].(string)] {
+			if seq > args[1].(int64) {
+				seqs = append(seqs, seq)
+			}
+		}
+		sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+		for _, seq := range seqs {
+			rows.values = append(rows.values, []driver.Value{seq, s.db.ops[args[0].(string)][seq]})
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func openFake(t *testing.T) (*Storage, *fakeDB) {
+	t.Helper()
+	fake := newFakeDB()
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	s, err := New(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, fake
+}
+
+func TestStorage(t *testing.T) {
+	s, fake := openFake(t)
+	if _, _, err := s.LoadSnapshot("doc"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	doc := gocrdt.NewRGA("alice")
+	a := doc.Insert('a', gocrdt.ID{NodeID: "root"})
+	snapshot, cursor, _ := doc.MarshalChanges(0)
+	if err := s.SaveSnapshot("doc", 0, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	doc.Insert('b', a)
+	delta, _, _ := doc.MarshalChanges(cursor)
+	if seq, err := s.AppendOps("doc", delta, delta); err != nil || seq != 2 {
+		t.Fatalf("AppendOps returned %d, %v", seq, err)
+	}
+	if seq, err := s.AppendOps("doc", delta); err != nil || seq != 3 {
+		t.Fatalf("AppendOps returned %d, %v", seq, err)
+	}
+
+	restored := gocrdt.NewRGA("alice")
+	if seq, err := storage.Restore(s, "doc", restored); err != nil || seq != 3 || restored.Value() != "ab" {
+		t.Errorf("Restored %q at %d: %v", restored.Value(), seq, err)
+	}
+
+	if err := s.TruncateOps("doc", 2); err != nil {
+		t.Fatal(err)
+	}
+	if ops, _ := s.ReadOpsSince("doc", 0); len(ops) != 1 || ops[0].Seq != 3 {
+		t.Errorf("Unexpected ops after truncation %+v", ops)
+	}
+
+	if err := s.RecordAck("doc", "bob", replicator.VersionVector{"alice": {Epoch: "e1", Cursor: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := fake.acks[[3]string{"doc", "bob", "alice"}]; len(ack) != 3 || ack[1] != int64(7) {
+		t.Errorf("Unexpected ack row %v", ack)
+	}
+	if err := s.RecordMerge("doc", time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.docs["doc"]["last_merge"] != "1970-01-01T00:00:00Z" {
+		t.Errorf("Unexpected last merge %v", fake.docs["doc"]["last_merge"])
+	}
+	if fake.docs["doc"]["size"] != int64(len(snapshot)) || fake.docs["doc"]["last_seq"] != int64(3) {
+		t.Errorf("Unexpected document metadata %v", fake.docs["doc"])
+	}
+}
@@ -0,0 +1,106 @@
+// Package storage persists replicated documents so that replicas survive
+// restarts.
+//
+// A document is stored as a snapshot of its full state plus a log of
+// operations appended after it. Both are opaque payloads: snapshots come
+// from MarshalState and operations are payloads accepted by MergeState,
+// such as the deltas of MarshalChanges. Restore rebuilds a document from
+// both.
+//
+// Storage is the pluggable interface; FileStorage implements it on a
+// local directory. Storages may also implement Truncater, Archive and
+// DeltaSnapshots, which a Snapshotter uses to keep logs short, past
+// snapshots around and checkpoints incremental.
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var (
+	// ErrNotFound is returned by LoadSnapshot for documents without a
+	// snapshot.
+	ErrNotFound = errors.New("storage: not found")
+
+	// ErrClosed is returned by a closed WAL.
+	ErrClosed = errors.New("storage: closed")
+)
+
+// Op is one entry of a document's operation log. Sequence numbers start at
+// 1 and grow by one with every appended operation.
+type Op struct {
+	Seq  uint64
+	Data []byte
+}
+
+// Storage persists snapshots and operation logs of documents identified by
+// key. Implementations must be safe for concurrent use and durable once a
+// call returns.
+type Storage interface {
+	// SaveSnapshot replaces the snapshot of key. seq is the sequence number
+	// of the last operation the snapshot covers (0 for none).
+	SaveSnapshot(key string, seq uint64, state []byte) error
+
+	// LoadSnapshot returns the snapshot of key and the seq it was saved
+	// with, or ErrNotFound.
+	LoadSnapshot(key string) (state []byte, seq uint64, err error)
+
+	// AppendOps appends operations to the log of key and returns the
+	// sequence number of the last one.
+	AppendOps(key string, ops ...[]byte) (uint64, error)
+
+	// ReadOpsSince returns the operations of key whose sequence number is
+	// greater than seq, in order.
+	ReadOpsSince(key string, seq uint64) ([]Op, error)
+
+	// Close releases the resources of the storage.
+	Close() error
+}
+
+// Restore merges the snapshot of key, then its delta snapshots (when the
+// storage implements DeltaSnapshots) and every operation logged after
+// them, into state. It returns the sequence number of the last operation
+// applied, and succeeds on an empty state for unknown keys.
+func Restore(s Storage, key string, state gocrdt.Replicable) (uint64, error) {
+	snapshot, seq, err := s.LoadSnapshot(key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		if _, err := state.MergeState(snapshot); err != nil {
+			return 0, fmt.Errorf("storage: restore snapshot of %q: %w", key, err)
+		}
+	}
+
+	if ds, ok := s.(DeltaSnapshots); ok {
+		deltas, err := ds.LoadDeltaSnapshots(key)
+		if err != nil {
+			return 0, err
+		}
+		for _, delta := range deltas {
+			if delta.Seq <= seq {
+				continue // left over from before the current base
+			}
+			if _, err := state.MergeState(delta.Data); err != nil {
+				return 0, fmt.Errorf("storage: restore delta snapshot %d of %q: %w", delta.Seq, key, err)
+			}
+			seq = delta.Seq
+		}
+	}
+
+	ops, err := s.ReadOpsSince(key, seq)
+	if err != nil {
+		return 0, err
+	}
+	for _, op := range ops {
+		if _, err := state.MergeState(op.Data); err != nil {
+			return 0, fmt.Errorf("storage: restore op %d of %q: %w", op.Seq, key, err)
+		}
+		seq = op.Seq
+	}
+	return seq, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math"
+	"sync"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// deltaState is implemented by states that can export only what changed
+// after a cursor into their change log, such as gocrdt.RGA.
+type deltaState interface {
+	MarshalChanges(since uint64) ([]byte, uint64, error)
+}
+
+// WAL is a write-ahead log of the mutations of one document. Commit
+// appends every change made since the previous call to the document's op
+// log; once it returns, the change survives a crash. An editor server calls
+// it after each mutation and before acknowledging it:
+//
+//	doc.Insert('x', parent)
+//	if _, err := wal.Commit(); err != nil {
+//		return err // not acknowledged
+//	}
+//
+// States with a change log (gocrdt.RGA) log deltas. Others, such as the
+// counters, log their full state, which for a counter is one slot per
+// replica; unchanged states are not logged again. Changes merged from
+// peers are logged like local ones.
+type WAL struct {
+	storage Storage
+	key     string
+	state   gocrdt.Replicable
+
+	mu      sync.Mutex
+	cursor  uint64            // change log position covered, for delta states
+	lastSum [sha256.Size]byte // last full state logged, for the others
+	seq     uint64
+
+	closed bool
+}
+
+// OpenWAL restores state from the snapshot and op log of key (see Restore)
+// and returns the WAL logging its further changes.
+func OpenWAL(s Storage, key string, state gocrdt.Replicable) (*WAL, error) {
+	seq, err := Restore(s, key, state)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{storage: s, key: key, state: state, seq: seq}
+	if delta, ok := state.(deltaState); ok {
+		// What was just restored is already logged.
+		if _, w.cursor, err = delta.MarshalChanges(math.MaxUint64); err != nil {
+			return nil, err
+		}
+	} else if data, err := state.MarshalState(); err == nil {
+		w.lastSum = sha256.Sum256(data)
+	} else {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Commit logs the changes made since the last Commit and returns the
+// sequence number of the last logged op. It logs nothing when nothing
+// changed, and fails with ErrClosed once the WAL is closed.
+func (w *WAL) Commit() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.commit()
+}
+
+// commit is Commit with w.mu held.
+func (w *WAL) commit() (uint64, error) {
+	if w.closed {
+		return w.seq, ErrClosed
+	}
+	var payload []byte
+	var cursor uint64
+	if delta, ok := w.state.(deltaState); ok {
+		var err error
+		if payload, cursor, err = delta.MarshalChanges(w.cursor); err != nil {
+			return w.seq, err
+		}
+	} else {
+		data, err := w.state.MarshalState()
+		if err != nil {
+			return w.seq, err
+		}
+		if sum := sha256.Sum256(data); !bytes.Equal(sum[:], w.lastSum[:]) {
+			payload = data
+		}
+	}
+	if payload == nil {
+		return w.seq, nil
+	}
+
+	seq, err := w.storage.AppendOps(w.key, payload)
+	if err != nil {
+		return w.seq, err
+	}
+	w.seq, w.cursor, w.lastSum = seq, cursor, sha256.Sum256(payload)
+	return seq, nil
+}
+
+// Seq returns the sequence number of the last logged op.
+func (w *WAL) Seq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq
+}
+
+// Close commits the changes made since the last Commit, so none is lost on
+// a planned stop, then closes the WAL. The storage stays open. Closing
+// twice is a no-op.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	if _, err := w.commit(); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestWAL_RecoversAcknowledgedChanges(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := gocrdt.NewRGA("alice")
+	wal, err := OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := gocrdt.ID{NodeID: "root"}
+	for _, r := range "hey" {
+		last = doc.Insert(r, last)
+		if _, err := wal.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc.Delete(last)
+	if seq, err := wal.Commit(); err != nil || seq != 4 {
+		t.Fatalf("Commit returned %d, %v", seq, err)
+	}
+	if seq, _ := wal.Commit(); seq != 4 {
+		t.Errorf("Expected nothing to be logged without changes, got seq %d", seq)
+	}
+	doc.Insert('!', last) // never committed: lost in the crash
+	s.Close()
+
+	s, _ = NewFileStorage(dir)
+	defer s.Close()
+	recovered := gocrdt.NewRGA("alice")
+	wal, err = OpenWAL(s, "doc", recovered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Value() != "he" || wal.Seq() != 4 {
+		t.Errorf("Recovered %q at seq %d", recovered.Value(), wal.Seq())
+	}
+	if seq, _ := wal.Commit(); seq != 4 {
+		t.Errorf("Replayed changes must not be logged again, got seq %d", seq)
+	}
+}
+
+func TestWAL_CountersLogFullState(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	counter := gocrdt.NewPNCounter("alice")
+	wal, err := OpenWAL(s, "likes", counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Increment()
+	counter.Increment()
+	wal.Commit()
+	counter.Decrement()
+	wal.Commit()
+	if seq, _ := wal.Commit(); seq != 2 {
+		t.Errorf("Expected two logged states, got %d", seq)
+	}
+
+	recovered := gocrdt.NewPNCounter("alice")
+	if _, err := OpenWAL(s, "likes", recovered); err != nil || recovered.Value() != 1 {
+		t.Errorf("Recovered %v: %v", recovered.Value(), err)
+	}
+}
+
+func TestWAL_Close(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	doc := gocrdt.NewRGA("alice")
+	wal, err := OpenWAL(s, "doc", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Insert('x', gocrdt.ID{NodeID: "root"})
+	if err := wal.Close(); err != nil || wal.Seq() != 1 {
+		t.Fatalf("Expected the change committed on Close, got seq %d, %v", wal.Seq(), err)
+	}
+	if _, err := wal.Commit(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected Commit to fail once closed, got %v", err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+}
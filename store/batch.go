@@ -0,0 +1,168 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// DocRef names a document of a Store by key and type.
+type DocRef struct {
+	Key  string
+	Type string
+}
+
+// deltaState is implemented by documents that can export only what changed
+// after a cursor into their change log, such as gocrdt.RGA.
+type deltaState interface {
+	MarshalChanges(since uint64) ([]byte, uint64, error)
+}
+
+// lockAll opens the documents of refs and locks them in key order, so that
+// concurrent batches never deadlock. It returns the locked entries by key
+// and the function unlocking them.
+func (s *Store) lockAll(refs []DocRef) (map[string]*entry, func(), error) {
+	refs = append([]DocRef(nil), refs...)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Key < refs[j].Key })
+
+	entries := make(map[string]*entry, len(refs))
+	var locked []*entry
+	unlock := func() {
+		for _, e := range locked {
+			s.touch(e)
+			e.mu.Unlock()
+		}
+		s.evict()
+	}
+	for _, ref := range refs {
+		e, err := s.entry(ref.Key, ref.Type)
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		if _, dup := entries[ref.Key]; dup {
+			continue
+		}
+		e.mu.Lock()
+		locked = append(locked, e)
+		if err := s.load(ref.Key, e); err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		entries[ref.Key] = e
+	}
+	return entries, unlock, nil
+}
+
+// View runs fn on the documents of refs, by key, while holding all their
+// locks, so it sees either all or none of the mutations of a Batch. fn
+// must not call back into the Store for these keys.
+func (s *Store) View(refs []DocRef, fn func(docs map[string]gocrdt.Replicable) error) error {
+	entries, unlock, err := s.lockAll(refs)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	docs := make(map[string]gocrdt.Replicable, len(entries))
+	for key, e := range entries {
+		docs[key] = e.state
+	}
+	return fn(docs)
+}
+
+// Batch applies the mutations fn makes to the documents of refs, passed by
+// key, as one unit: they are made while holding every document's lock, so
+// Do, View, Merge and MarshalState see all of them or none. Readers keeping
+// a document from Open bypass the locks and may see them one by one.
+//
+// When fn returns an error, every document is restored to its state before
+// the batch and the error is returned. Restoring replaces the document
+// instances, so references obtained from Open before a failed batch go
+// stale.
+//
+// On success Batch returns the mutations as one bundle in the format of
+// MarshalState, for peers to apply with MergeState: the changes of the
+// batch for documents with a change log (RGA), the full state for others,
+// and the live keys when Config.ReplicaID is set.
+// fn must not call back into the Store for these keys.
+func (s *Store) Batch(refs []DocRef, fn func(docs map[string]gocrdt.Replicable) error) ([]byte, error) {
+	entries, unlock, err := s.lockAll(refs)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	tenants := make(map[string]*Tenant)
+	for key := range entries {
+		if t := s.tenant(key); t != nil {
+			if err := t.admit(); err != nil {
+				return nil, err
+			}
+			tenants[key] = t
+		}
+	}
+	defer func() {
+		for key, t := range tenants {
+			t.measure(key, entries[key].state)
+		}
+	}()
+
+	keys := make([]string, 0, len(entries))
+	docs := make(map[string]gocrdt.Replicable, len(entries))
+	before := make(map[string][]byte, len(entries))
+	cursors := make(map[string]uint64, len(entries))
+	for key, e := range entries {
+		keys = append(keys, key)
+		docs[key] = e.state
+		if before[key], err = e.state.MarshalState(); err != nil {
+			return nil, fmt.Errorf("store: batch snapshot of %q: %w", key, err)
+		}
+		if delta, ok := e.state.(deltaState); ok {
+			_, cursors[key], _ = delta.MarshalChanges(math.MaxUint64)
+		}
+	}
+	sort.Strings(keys)
+
+	if err := fn(docs); err != nil {
+		for _, key := range keys {
+			if rollbackErr := s.restore(key, entries[key], before[key]); rollbackErr != nil {
+				return nil, fmt.Errorf("%w (rollback failed: %w)", err, rollbackErr)
+			}
+		}
+		return nil, err
+	}
+
+	bundle := make([]Document, 0, len(keys))
+	for _, key := range keys {
+		e := entries[key]
+		s.notify(Change{Key: key, Type: e.typ})
+		var data []byte
+		if delta, ok := e.state.(deltaState); ok {
+			data, _, err = delta.MarshalChanges(cursors[key])
+		} else {
+			data, err = e.state.MarshalState()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("store: batch bundle of %q: %w", key, err)
+		}
+		if data != nil {
+			bundle = append(bundle, Document{Key: key, Type: e.typ, State: data})
+		}
+	}
+	return s.encodeState(bundle)
+}
+
+// restore replaces the document of e with a fresh one merged with state.
+// It must be called with e.mu held.
+func (s *Store) restore(key string, e *entry, state []byte) error {
+	s.mu.RLock()
+	factory := s.types[e.typ]
+	s.mu.RUnlock()
+	fresh := factory(key)
+	if _, err := fresh.MergeState(state); err != nil {
+		return err
+	}
+	e.state = fresh
+	return nil
+}
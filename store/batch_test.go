@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var batchRefs = []DocRef{{Key: "post", Type: "rga"}, {Key: "likes", Type: "pncounter"}}
+
+// comment appends text to the post and counts it, in one batch.
+func comment(s *Store, text string, fail error) ([]byte, error) {
+	return s.Batch(batchRefs, func(docs map[string]gocrdt.Replicable) error {
+		post := docs["post"].(*gocrdt.RGA)
+		parent := gocrdt.ID{NodeID: "root"}
+		if nodes := post.Nodes(); len(nodes) > 0 {
+			parent = nodes[len(nodes)-1].ID
+		}
+		for _, r := range text {
+			parent = post.Insert(r, parent)
+		}
+		docs["likes"].(*gocrdt.PNCounter).Increment()
+		return fail
+	})
+}
+
+func TestStore_Batch(t *testing.T) {
+	alice, bob := newStore(t, "alice", Config{}), newStore(t, "bob", Config{})
+	if _, err := comment(alice, "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := comment(alice, "!", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bundle holds the changes of the batch only, plus the counter.
+	if _, err := bob.MergeState(bundle); err != nil {
+		t.Fatal(err)
+	}
+	post, _ := bob.Open("post", "rga")
+	likes, _ := bob.Open("likes", "pncounter")
+	if post.(*gocrdt.RGA).Stats().Orphans != 1 || likes.(*gocrdt.PNCounter).Value() != 2 {
+		t.Errorf("Expected the bundle to carry the batch's node and the counter, got %+v and %d",
+			post.(*gocrdt.RGA).Stats(), likes.(*gocrdt.PNCounter).Value())
+	}
+
+	// A failed batch leaves nothing behind.
+	boom := errors.New("boom")
+	if bundle, err := comment(alice, "lost", boom); !errors.Is(err, boom) || bundle != nil {
+		t.Fatalf("Expected the batch error, got %v", err)
+	}
+	err = alice.View(batchRefs, func(docs map[string]gocrdt.Replicable) error {
+		if text := docs["post"].(*gocrdt.RGA).Value(); text != "hi!" {
+			t.Errorf("Expected the post to be rolled back to %q, got %q", "hi!", text)
+		}
+		if n := docs["likes"].(*gocrdt.PNCounter).Value(); n != 2 {
+			t.Errorf("Expected the likes to be rolled back to 2, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.Batch([]DocRef{{Key: "post", Type: "pncounter"}}, nil); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected a type mismatch, got %v", err)
+	}
+}
+
+func TestStore_BatchIsAtomic(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			comment(s, "x", nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			s.View(batchRefs, func(docs map[string]gocrdt.Replicable) error {
+				text := docs["post"].(*gocrdt.RGA).Value().(string)
+				if n := docs["likes"].(*gocrdt.PNCounter).Value(); n != len(text) {
+					t.Errorf("Saw %d likes for %d comments", n, len(text))
+				}
+				return nil
+			})
+		}
+	}()
+	wg.Wait()
+}
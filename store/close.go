@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Close shuts the store down for a planned stop. It closes every watch,
+// waits for the operations in progress on each loaded document, then saves
+// the document with Config.Save, so no change acknowledged by Do, Batch or
+// Merge before Close is lost. Accesses after Close fail with ErrClosed.
+//
+// The documents are saved in key order until ctx is done; the errors of
+// the documents left unsaved are joined with ctx.Err(). Stop the
+// replication of the store before closing it: payloads it merges after
+// Close are rejected. Closing twice is a no-op.
+func (s *Store) Close(ctx context.Context) error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	s.watchMu.Lock()
+	watches := make([]*Watch, 0, len(s.watches))
+	for w := range s.watches {
+		watches = append(watches, w)
+	}
+	s.watchMu.Unlock()
+	for _, w := range watches {
+		w.Close()
+	}
+
+	var errs []error
+	for _, e := range s.resident() {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		errs = append(errs, s.flush(e))
+	}
+	s.config.Logger.Info("store: closed")
+	return errors.Join(errs...)
+}
+
+// flush saves the document of e, if loaded, once the operations holding
+// its lock are done.
+func (s *Store) flush(e *entry) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == nil || s.config.Save == nil {
+		return nil
+	}
+	data, err := e.state.MarshalState()
+	if err == nil {
+		err = s.config.Save(e.key, e.typ, data)
+	}
+	if err != nil {
+		return fmt.Errorf("store: save %q: %w", e.key, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStore_Close(t *testing.T) {
+	saved := map[string][]byte{}
+	s := newStore(t, "alice", Config{Save: func(key, typ string, state []byte) error {
+		saved[key] = state
+		return nil
+	}})
+	if err := s.Do("likes", "pncounter", func(doc gocrdt.Replicable) error {
+		doc.(*gocrdt.PNCounter).Increment()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w := s.Watch(Filter{})
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	restored := gocrdt.NewPNCounter("alice")
+	if _, err := restored.MergeState(saved["likes"]); err != nil || restored.Value() != 1 {
+		t.Errorf("Expected the change saved, got %d, %v", restored.Value(), err)
+	}
+	if _, err := w.Next(context.Background()); !errors.Is(err, ErrWatchClosed) {
+		t.Errorf("Expected the watch closed, got %v", err)
+	}
+	if _, err := s.Open("likes", "pncounter"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected Open to fail, got %v", err)
+	}
+	if _, err := s.Merge("other", "rga", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected Merge to fail, got %v", err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+}
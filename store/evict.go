@@ -0,0 +1,104 @@
+package store
+
+// evicting reports whether the store unloads idle documents.
+func (s *Store) evicting() bool {
+	c := s.config
+	return c.Save != nil && c.Load != nil && (c.MaxResident > 0 || c.MemoryBudget > 0)
+}
+
+// Resident returns the number of documents an evicting store holds in
+// memory and the sum of their estimated footprints, which MemoryBudget
+// bounds. Both are 0 for stores that do not evict.
+func (s *Store) Resident() (docs, bytes int) {
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	return s.lru.Len(), s.footprint
+}
+
+// release unlocks e after an access, marking it the most recently used,
+// and evicts idle documents if the store is over its bounds.
+func (s *Store) release(e *entry) {
+	s.touch(e)
+	e.mu.Unlock()
+	s.evict()
+}
+
+// touch moves a loaded e to the front of the LRU list and updates its
+// size. It must be called with e.mu held.
+func (s *Store) touch(e *entry) {
+	if e.state == nil || !s.evicting() {
+		return
+	}
+	size := 0
+	if f, ok := e.state.(footprinter); ok && s.config.MemoryBudget > 0 {
+		size = f.MemoryFootprint().Total()
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	if e.elem == nil {
+		e.elem = s.lru.PushFront(e)
+	} else {
+		s.lru.MoveToFront(e.elem)
+	}
+	s.footprint += size - e.size
+	e.size = size
+}
+
+// forget removes a deleted e from the LRU list.
+func (s *Store) forget(e *entry) {
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	if e.elem != nil {
+		s.lru.Remove(e.elem)
+		s.footprint -= e.size
+		e.elem, e.size = nil, 0
+	}
+}
+
+// over reports whether the loaded documents exceed a bound. It must be
+// called with lruMu held.
+func (s *Store) over() bool {
+	c := s.config
+	return (c.MaxResident > 0 && s.lru.Len() > c.MaxResident) ||
+		(c.MemoryBudget > 0 && s.footprint > c.MemoryBudget)
+}
+
+// evict saves and unloads the least recently used documents until the
+// store is within its bounds. Documents in use are skipped, and so are
+// those Save fails for, which stay loaded.
+func (s *Store) evict() {
+	if !s.evicting() {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	for elem := s.lru.Back(); elem != nil && s.over(); {
+		e := elem.Value.(*entry)
+		elem = elem.Prev()
+		if !e.mu.TryLock() {
+			continue
+		}
+		if err := s.unload(e); err != nil {
+			s.config.Logger.Warn("store: eviction failed", "key", e.key, "type", e.typ, "err", err)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// unload saves the document of e and drops it from memory. It must be
+// called with e.mu and lruMu held.
+func (s *Store) unload(e *entry) error {
+	data, err := e.state.MarshalState()
+	if err != nil {
+		return err
+	}
+	if err := s.config.Save(e.key, e.typ, data); err != nil {
+		return err
+	}
+	e.state = nil
+	s.lru.Remove(e.elem)
+	s.footprint -= e.size
+	e.elem, e.size = nil, 0
+	s.config.Logger.Debug("store: evicted document", "key", e.key, "type", e.typ)
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"sync"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// diskConfig returns a Config persisting documents to a map.
+func diskConfig(disk map[string][]byte, mu *sync.Mutex) Config {
+	return Config{
+		Load: func(key, _ string) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return disk[key], nil
+		},
+		Save: func(key, _ string, state []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			disk[key] = state
+			return nil
+		},
+	}
+}
+
+func TestStore_EvictLeastRecentlyUsed(t *testing.T) {
+	var mu sync.Mutex
+	disk := make(map[string][]byte)
+	config := diskConfig(disk, &mu)
+	config.MaxResident = 2
+	s := newStore(t, "alice", config)
+
+	write := func(key string, r rune) {
+		t.Helper()
+		err := s.Do(key, "rga", func(state gocrdt.Replicable) error {
+			doc := state.(*gocrdt.RGA)
+			parent := gocrdt.ID{NodeID: "root"}
+			if nodes := doc.Nodes(); len(nodes) > 0 {
+				parent = nodes[len(nodes)-1].ID
+			}
+			doc.Insert(r, parent)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a", 'a')
+	write("b", 'b')
+	write("a", 'A') // b is now the least recently used
+	write("c", 'c')
+
+	if n, _ := s.Resident(); n != 2 {
+		t.Errorf("Expected 2 resident documents, got %d", n)
+	}
+	mu.Lock()
+	_, saved := disk["b"]
+	mu.Unlock()
+	if !saved {
+		t.Fatal("Expected b to be evicted")
+	}
+
+	// The evicted document is loaded back with its content.
+	write("b", 'B')
+	err := s.View([]DocRef{{Key: "a", Type: "rga"}, {Key: "b", Type: "rga"}}, func(docs map[string]gocrdt.Replicable) error {
+		if a, b := docs["a"].(*gocrdt.RGA).Value(), docs["b"].(*gocrdt.RGA).Value(); a != "aA" || b != "bB" {
+			t.Errorf("Expected aA and bB, got %q and %q", a, b)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := s.Keys(); len(keys) != 3 {
+		t.Errorf("Expected evicted documents to keep their keys, got %v", keys)
+	}
+}
+
+func TestStore_EvictMemoryBudget(t *testing.T) {
+	var mu sync.Mutex
+	config := diskConfig(make(map[string][]byte), &mu)
+	config.MemoryBudget = 1
+	s := newStore(t, "alice", config)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Do(key, "pncounter", func(state gocrdt.Replicable) error {
+			state.(*gocrdt.PNCounter).Increment()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, bytes := s.Resident(); n != 0 || bytes != 0 {
+		t.Errorf("Expected every document to be evicted, got %d (%d bytes)", n, bytes)
+	}
+	likes, err := s.Open("b", "pncounter")
+	if err != nil || likes.(*gocrdt.PNCounter).Value() != 1 {
+		t.Errorf("Expected b to reload, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// archiveFormat and archiveVersion identify an archive written by Export.
+const (
+	archiveFormat  = "gocrdt-store"
+	archiveVersion = 1
+)
+
+// ErrArchiveFormat is returned by Import for input that is not an archive
+// written by Export, or of a newer version.
+var ErrArchiveFormat = errors.New("store: not a store archive")
+
+// archiveHeader is the first line of an archive.
+type archiveHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// Export writes an archive of every document to w, for backups and for
+// moving a replica to new hardware. The archive is a header line followed
+// by one JSON Document per line; documents are encoded one at a time, so
+// the archive is never held in memory. Wrap w to compress it.
+//
+// Each document is consistent on its own; documents changed during the
+// export may be captured before or after the change.
+func (s *Store) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(archiveHeader{Format: archiveFormat, Version: archiveVersion}); err != nil {
+		return err
+	}
+	for _, key := range s.Keys() {
+		typ, _ := s.Type(key)
+		err := s.do(key, typ, func(state gocrdt.Replicable) error {
+			data, err := state.MarshalState()
+			if err != nil {
+				return err
+			}
+			return enc.Encode(Document{Key: key, Type: typ, State: data})
+		})
+		if err != nil {
+			return fmt.Errorf("store: export %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Import merges every document of an archive written by Export, reading
+// it one document at a time. Documents are merged like remote states, so
+// importing into a non-empty store combines both. It stops at the first
+// error and returns the figures of the documents merged so far.
+func (s *Store) Import(r io.Reader) (gocrdt.MergeResult, error) {
+	var total gocrdt.MergeResult
+	dec := json.NewDecoder(r)
+	var header archiveHeader
+	if err := dec.Decode(&header); err != nil || header.Format != archiveFormat {
+		return total, ErrArchiveFormat
+	}
+	if header.Version > archiveVersion {
+		return total, fmt.Errorf("%w: version %d is newer than %d", ErrArchiveFormat, header.Version, archiveVersion)
+	}
+
+	for {
+		var doc Document
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, fmt.Errorf("store: import: %w", err)
+		}
+		result, err := s.Merge(doc.Key, doc.Type, doc.State)
+		total.Add(result)
+		if err != nil {
+			return total, fmt.Errorf("store: import %q: %w", doc.Key, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStore_ExportImport(t *testing.T) {
+	old := newStore(t, "alice", Config{})
+	_ = old.Do("doc:1", "rga", func(state gocrdt.Replicable) error {
+		doc := state.(*gocrdt.RGA)
+		doc.Insert('i', doc.Insert('h', gocrdt.ID{NodeID: "root"}))
+		return nil
+	})
+	_ = old.Do("likes:1", "pncounter", func(state gocrdt.Replicable) error {
+		state.(*gocrdt.PNCounter).Increment()
+		return nil
+	})
+
+	var archive bytes.Buffer
+	if err := old.Export(&archive); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(archive.String(), "\n"); lines != 3 {
+		t.Errorf("Expected a header and one line per document, got %d lines", lines)
+	}
+
+	moved := newStore(t, "alice", Config{})
+	if _, err := moved.Import(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := moved.Open("doc:1", "rga")
+	likes, _ := moved.Open("likes:1", "pncounter")
+	if doc.(*gocrdt.RGA).Value() != "hi" || likes.(*gocrdt.PNCounter).Value() != 1 {
+		t.Errorf("Import lost data: %q, %d", doc.(*gocrdt.RGA).Value(), likes.(*gocrdt.PNCounter).Value())
+	}
+
+	if _, err := moved.Import(strings.NewReader(`{"key":"x"}`)); !errors.Is(err, ErrArchiveFormat) {
+		t.Errorf("Expected a format error, got %v", err)
+	}
+	if _, err := moved.Import(strings.NewReader(`{"format":"gocrdt-store","version":2}`)); !errors.Is(err, ErrArchiveFormat) {
+		t.Errorf("Expected newer versions to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var (
+	// ErrExists is returned by Create for a key that already has a
+	// document.
+	ErrExists = errors.New("store: document exists")
+
+	// ErrNotFound is returned by Delete for a key without a document.
+	ErrNotFound = errors.New("store: no such document")
+
+	// ErrNoReplicaID is returned by Delete on a store without
+	// Config.ReplicaID, whose deletions could not be replicated.
+	ErrNoReplicaID = errors.New("store: deletion needs Config.ReplicaID")
+)
+
+// storeState is the wire form of a Store with a replicated key set.
+// Stores without Config.ReplicaID write a bare array of documents.
+type storeState struct {
+	V    int             `json:"v"`
+	Keys json.RawMessage `json:"keys"`
+	Docs []Document      `json:"docs"`
+}
+
+// Create creates the document key of type typ, like Open, but fails with
+// ErrExists when the key already has a document.
+func (s *Store) Create(key, typ string) (gocrdt.Replicable, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	s.mu.Lock()
+	_, exists := s.docs[key]
+	_, registered := s.types[typ]
+	var err error
+	if !exists && registered {
+		_, err = s.newEntry(key, typ)
+	}
+	s.mu.Unlock()
+	if exists {
+		return nil, fmt.Errorf("%w: %q", ErrExists, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := s.Open(key, typ)
+	if err == nil {
+		s.notify(Change{Key: key, Type: typ})
+	}
+	return doc, err
+}
+
+// Delete deletes the document key. The deletion is replicated: the live
+// keys are part of the store state, as an observed-remove set, so a
+// replica merging a state sent before the deletion does not bring the
+// document back. Creating the key again concurrently with the deletion, on
+// any replica, wins over it, with the content merged by then.
+//
+// Delete drops the document's state from memory and reports it to the
+// watches with Change.Deleted; applications persisting documents should
+// drop their copy there, or Config.Load restores it when the key is
+// created again. It needs Config.ReplicaID.
+func (s *Store) Delete(key string) error {
+	if s.keys == nil {
+		return ErrNoReplicaID
+	}
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	s.mu.Lock()
+	e, exists := s.docs[key]
+	live := s.keys.Contains(key)
+	if live {
+		s.keys.Remove(key)
+	}
+	delete(s.docs, key)
+	if t := s.tenantOf(key); t != nil {
+		t.remove(key)
+	}
+	s.mu.Unlock()
+	if !exists && !live {
+		return fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	var typ string
+	if exists {
+		s.forget(e)
+		typ = e.typ
+	}
+	s.repair.forget(key)
+	s.config.Logger.Debug("store: deleted document", "key", key, "type", typ)
+	s.notify(Change{Key: key, Type: typ, Deleted: true})
+	return nil
+}
+
+// live reports whether key is in the live key set.
+func (s *Store) live(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys.Contains(key)
+}
+
+// mergeKeys merges a remote live key set and drops the documents it
+// deleted. A deletion canDelete refuses is undone by adding the key again,
+// which wins over the deletion on every replica.
+func (s *Store) mergeKeys(keys []byte, canDelete func(key, typ string) error) (gocrdt.MergeResult, error) {
+	s.mu.Lock()
+	result, err := s.keys.MergeState(keys)
+	if err != nil {
+		s.mu.Unlock()
+		return result, fmt.Errorf("store: live keys: %w", err)
+	}
+	var deleted []*entry
+	for key, e := range s.docs {
+		if s.keys.Contains(key) {
+			continue
+		}
+		if canDelete != nil && canDelete(key, e.typ) != nil {
+			s.keys.Add(key)
+			result.Rejected++
+			continue
+		}
+		delete(s.docs, key)
+		if t := s.tenantOf(key); t != nil {
+			t.remove(key)
+		}
+		deleted = append(deleted, e)
+	}
+	s.mu.Unlock()
+
+	for _, e := range deleted {
+		s.forget(e)
+		s.repair.forget(e.key)
+		s.config.Logger.Debug("store: deleted document", "key", e.key, "type", e.typ)
+		s.notify(Change{Key: e.key, Type: e.typ, Remote: true, Deleted: true})
+	}
+	return result, nil
+}
+
+// encodeState encodes docs as a store state, with the live keys when they
+// are replicated. The keys are read after the documents, so a document
+// deleted in between is sent but not merged back.
+func (s *Store) encodeState(docs []Document) ([]byte, error) {
+	if s.keys == nil {
+		return json.Marshal(docs)
+	}
+	s.mu.RLock()
+	keys, err := s.keys.MarshalState()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storeState{V: gocrdt.WireVersion, Keys: keys, Docs: docs})
+}
+
+// decodeState decodes a store state of either form. keys is nil for a
+// bare array of documents.
+func decodeState(data []byte) (docs []Document, keys []byte, err error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &docs)
+		return docs, nil, err
+	}
+	var state storeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, err
+	}
+	if state.V > gocrdt.WireVersion {
+		return nil, nil, fmt.Errorf("%w %d", gocrdt.ErrUnsupportedVersion, state.V)
+	}
+	return state.Docs, state.Keys, nil
+}
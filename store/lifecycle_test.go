@@ -0,0 +1,122 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// syncStores merges the state of from into to.
+func syncStores(t *testing.T, from, to *Store) {
+	t.Helper()
+	state, err := from.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	alice := newStore(t, "alice", Config{ReplicaID: "alice"})
+	bob := newStore(t, "bob", Config{ReplicaID: "bob"})
+
+	if _, err := alice.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.Create("doc", "rga"); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	syncStores(t, alice, bob)
+	stale, _ := bob.MarshalState()
+
+	if err := alice.Delete("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Delete("doc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// A state sent before the deletion does not bring the document back,
+	// and the deletion reaches bob.
+	if _, err := alice.MergeState(stale); err != nil {
+		t.Fatal(err)
+	}
+	if keys := alice.Keys(); len(keys) != 0 {
+		t.Errorf("Expected the deleted document to stay deleted, got %v", keys)
+	}
+	w := bob.Watch(Filter{})
+	defer w.Close()
+	syncStores(t, alice, bob)
+	if keys := bob.Keys(); len(keys) != 0 {
+		t.Errorf("Expected the deletion to replicate, got %v", keys)
+	}
+	if batch, err := w.Next(t.Context()); err != nil || len(batch) != 1 || !batch[0].Deleted || batch[0].Type != "rga" {
+		t.Errorf("Expected a deleted change, got %+v, %v", batch, err)
+	}
+
+	// Creating the key again concurrently with a deletion wins over it.
+	if _, err := bob.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	syncStores(t, bob, alice)
+	if err := alice.Delete("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Delete("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Do("doc", "rga", func(state gocrdt.Replicable) error {
+		state.(*gocrdt.RGA).Insert('x', gocrdt.ID{NodeID: "root"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	syncStores(t, bob, alice)
+	syncStores(t, alice, bob)
+	for _, s := range []*Store{alice, bob} {
+		doc, err := s.Open("doc", "rga")
+		if err != nil || doc.(*gocrdt.RGA).Value() != "x" {
+			t.Errorf("Expected the re-created document on both replicas, got %v", err)
+		}
+	}
+
+	if err := New(Config{}).Delete("doc"); !errors.Is(err, ErrNoReplicaID) {
+		t.Errorf("Expected ErrNoReplicaID, got %v", err)
+	}
+}
+
+func TestStore_DeleteAuthorized(t *testing.T) {
+	deny := func(peer, key, typ string, op Operation) error {
+		if op == OpWrite {
+			return errors.New("read-only")
+		}
+		return nil
+	}
+	alice := newStore(t, "alice", Config{ReplicaID: "alice", Authorize: deny})
+	bob := newStore(t, "bob", Config{ReplicaID: "bob"})
+	if _, err := alice.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	syncStores(t, alice, bob)
+	if err := bob.Delete("doc"); err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ := bob.MarshalState()
+	if result, _ := alice.MergeStateFrom("bob", state); result.Rejected != 1 {
+		t.Errorf("Expected the deletion to be rejected, got %+v", result)
+	}
+	if keys := alice.Keys(); len(keys) != 1 {
+		t.Errorf("Expected the document to survive, got %v", keys)
+	}
+	syncStores(t, alice, bob)
+	if keys := bob.Keys(); len(keys) != 1 {
+		t.Errorf("Expected the document to be restored on bob, got %v", keys)
+	}
+}
@@ -0,0 +1,291 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// DefaultMaintenanceInterval is the time between two rounds of a
+// Maintenance without MaintenanceConfig.Interval.
+const DefaultMaintenanceInterval = time.Minute
+
+// MaintenanceConfig sets the tasks of a Maintenance and how they run. Tasks
+// left unset are skipped.
+type MaintenanceConfig struct {
+	// Interval is the time between two rounds (DefaultMaintenanceInterval
+	// by default), plus a random delay up to Jitter, so that stores
+	// started together do not all maintain their documents at once.
+	Interval time.Duration
+	Jitter   time.Duration
+
+	// Concurrency bounds the documents maintained at once (1 by default).
+	// A document is locked while maintained, as by Do.
+	Concurrency int
+
+	// Retention compacts the history of documents that are
+	// gocrdt.Compactable, collecting their tombstones past the policy.
+	Retention gocrdt.RetentionPolicy
+
+	// OrphanTTL drops the orphans RGA documents buffered for longer than
+	// that, see gocrdt.RGA.DropOrphans.
+	OrphanTTL time.Duration
+
+	// Snapshot, when set, persists the state of every document changed
+	// through the Store since the previous round. It defaults to
+	// Config.Save.
+	Snapshot func(key, typ string, state []byte) error
+
+	// OnError, when set, receives the errors of the rounds made by Run,
+	// which are otherwise dropped.
+	OnError func(error)
+}
+
+// MaintenanceReport is what a round of maintenance did.
+type MaintenanceReport struct {
+	Documents int // Documents maintained: those loaded in memory
+	Compacted int // Items discarded by Retention
+	Orphans   int // Orphans dropped past OrphanTTL
+	Snapshots int // Documents persisted by Snapshot
+}
+
+// orphanBuffer is implemented by documents buffering orphans, such as
+// gocrdt.RGA.
+type orphanBuffer interface {
+	PendingOrphans() []gocrdt.ID
+	DropOrphans(ids ...gocrdt.ID) int
+}
+
+// Maintenance runs the housekeeping of the documents of a Store in the
+// background: tombstone collection past a retention policy, expiry of
+// orphans whose parent never came, and snapshots of changed documents:
+//
+//	m := store.NewMaintenance(s, store.MaintenanceConfig{
+//		Retention: gocrdt.RetentionPolicy{MaxAge: 7 * 24 * time.Hour},
+//		OrphanTTL: time.Hour,
+//		Jitter:    10 * time.Second,
+//	})
+//	go m.Run(ctx)
+//
+// Only the documents loaded in memory are maintained; an evicting store
+// saved the others when it unloaded them. Changes made on instances
+// obtained from Open bypass the Store and are only snapshotted along with
+// a later change routed through it.
+type Maintenance struct {
+	store  *Store
+	config MaintenanceConfig
+	watch  *Watch
+	now    func() time.Time
+
+	mu        sync.Mutex
+	retention map[string]*gocrdt.Retention       // By key
+	orphans   map[string]map[gocrdt.ID]time.Time // First seen, by key
+}
+
+// NewMaintenance creates the maintenance of the documents of s. It starts
+// following their changes for snapshots right away.
+func NewMaintenance(s *Store, config MaintenanceConfig) *Maintenance {
+	if config.Interval <= 0 {
+		config.Interval = DefaultMaintenanceInterval
+	}
+	config.Concurrency = max(config.Concurrency, 1)
+	if config.Snapshot == nil {
+		config.Snapshot = s.config.Save
+	}
+	m := &Maintenance{
+		store:     s,
+		config:    config,
+		now:       time.Now,
+		retention: make(map[string]*gocrdt.Retention),
+		orphans:   make(map[string]map[gocrdt.ID]time.Time),
+	}
+	if config.Snapshot != nil {
+		m.watch = s.Watch(Filter{})
+	}
+	return m
+}
+
+// resident returns the entries of the documents loaded in memory, sorted
+// by key.
+func (s *Store) resident() []*entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]*entry, 0, len(s.docs))
+	for _, e := range s.docs {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries
+}
+
+// RunOnce runs one round over every document loaded in memory, at most
+// Concurrency at a time, and returns what it did with the errors of the
+// documents that failed.
+func (m *Maintenance) RunOnce(ctx context.Context) (MaintenanceReport, error) {
+	changed := make(map[string]bool)
+	if m.watch != nil {
+		for _, c := range m.watch.drain() {
+			changed[c.Key] = !c.Deleted
+		}
+	}
+	entries := m.store.resident()
+	m.forget(entries)
+
+	var (
+		mu     sync.Mutex
+		report MaintenanceReport
+		errs   []error
+		wg     sync.WaitGroup
+	)
+	work := make(chan *entry)
+	for range min(m.config.Concurrency, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				r, err := m.maintain(e, changed[e.key])
+				mu.Lock()
+				report.Documents += r.Documents
+				report.Compacted += r.Compacted
+				report.Orphans += r.Orphans
+				report.Snapshots += r.Snapshots
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return report, errors.Join(errs...)
+}
+
+// forget drops the bookkeeping of the documents no longer in the store.
+func (m *Maintenance) forget(entries []*entry) {
+	keys := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keys[e.key] = true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.retention {
+		if !keys[key] {
+			delete(m.retention, key)
+		}
+	}
+	for key := range m.orphans {
+		if !keys[key] {
+			delete(m.orphans, key)
+		}
+	}
+}
+
+// maintain runs the tasks on the document of e, if it is loaded.
+func (m *Maintenance) maintain(e *entry, changed bool) (MaintenanceReport, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var report MaintenanceReport
+	if e.state == nil {
+		return report, nil
+	}
+	report.Documents = 1
+
+	if doc, ok := e.state.(gocrdt.Compactable); ok && m.config.Retention != (gocrdt.RetentionPolicy{}) {
+		report.Compacted = m.compact(e.key, doc)
+	}
+	if doc, ok := e.state.(orphanBuffer); ok && m.config.OrphanTTL > 0 {
+		report.Orphans = m.expire(e.key, doc)
+	}
+	if changed {
+		state, err := e.state.MarshalState()
+		if err == nil {
+			err = m.config.Snapshot(e.key, e.typ, state)
+		}
+		if err != nil {
+			return report, fmt.Errorf("store: snapshot %q: %w", e.key, err)
+		}
+		report.Snapshots = 1
+	}
+	return report, nil
+}
+
+// compact enforces the retention policy on the document key.
+func (m *Maintenance) compact(key string, doc gocrdt.Compactable) int {
+	m.mu.Lock()
+	retention, ok := m.retention[key]
+	if !ok {
+		retention = gocrdt.NewRetention()
+		m.retention[key] = retention
+	}
+	m.mu.Unlock()
+	// Add keeps the history of the same instance, and restarts it for a
+	// document reloaded after an eviction.
+	retention.Add(key, doc, m.config.Retention)
+	return retention.Enforce()[key]
+}
+
+// expire drops the orphans of the document key buffered for longer than
+// OrphanTTL, as seen by the rounds.
+func (m *Maintenance) expire(key string, doc orphanBuffer) int {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[gocrdt.ID]time.Time)
+	var expired []gocrdt.ID
+	for _, id := range doc.PendingOrphans() {
+		first, ok := m.orphans[key][id]
+		if !ok {
+			first = now
+		}
+		if now.Sub(first) >= m.config.OrphanTTL {
+			expired = append(expired, id)
+		} else {
+			seen[id] = first
+		}
+	}
+	m.orphans[key] = seen
+	if len(expired) == 0 {
+		return 0
+	}
+	return doc.DropOrphans(expired...)
+}
+
+// Run runs a round every Interval plus jitter until ctx is done, then
+// stops following the store's changes and returns ctx.Err().
+func (m *Maintenance) Run(ctx context.Context) error {
+	if m.watch != nil {
+		defer m.watch.Close()
+	}
+	for {
+		delay := m.config.Interval
+		if m.config.Jitter > 0 {
+			delay += rand.N(m.config.Jitter)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			if _, err := m.RunOnce(ctx); err != nil && ctx.Err() == nil && m.config.OnError != nil {
+				m.config.OnError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestMaintenance_RunOnce(t *testing.T) {
+	var mu sync.Mutex
+	saved := map[string]int{}
+	s := newStore(t, "alice", Config{})
+	m := NewMaintenance(s, MaintenanceConfig{
+		Retention:   gocrdt.RetentionPolicy{MaxVersions: 1},
+		OrphanTTL:   time.Hour,
+		Concurrency: 2,
+		Snapshot: func(key, typ string, state []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			saved[key]++
+			return nil
+		},
+	})
+	now := time.Unix(0, 0)
+	m.now = func() time.Time { return now }
+
+	// notes gets a tombstone, chat an orphan whose parent never comes.
+	root := gocrdt.ID{NodeID: "root"}
+	if err := s.Do("notes", "rga", func(doc gocrdt.Replicable) error {
+		rga := doc.(*gocrdt.RGA)
+		rga.Delete(rga.Insert('x', root))
+		rga.Insert('y', root)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	source := gocrdt.NewRGA("bob")
+	source.Insert('b', source.Insert('a', root))
+	if err := s.Do("chat", "rga", func(doc gocrdt.Replicable) error {
+		doc.(*gocrdt.RGA).Merge(source.Nodes()[1:])
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.RunOnce(context.Background())
+	want := MaintenanceReport{Documents: 2, Compacted: 1, Snapshots: 2}
+	if err != nil || report != want {
+		t.Fatalf("Expected %+v, got %+v, %v", want, report, err)
+	}
+
+	// Unchanged documents are not snapshotted again, and the orphan
+	// expires an hour after the first round saw it.
+	now = now.Add(time.Hour)
+	report, err = m.RunOnce(context.Background())
+	want = MaintenanceReport{Documents: 2, Orphans: 1}
+	if err != nil || report != want {
+		t.Fatalf("Expected %+v, got %+v, %v", want, report, err)
+	}
+	chat, _ := s.Open("chat", "rga")
+	if len(chat.(*gocrdt.RGA).PendingOrphans()) != 0 || saved["notes"] != 1 || saved["chat"] != 1 {
+		t.Errorf("Expected the orphan dropped and one snapshot each, got %v", saved)
+	}
+}
+
+func TestMaintenance_Errors(t *testing.T) {
+	fail := errors.New("disk full")
+	s := newStore(t, "alice", Config{})
+	m := NewMaintenance(s, MaintenanceConfig{
+		Snapshot: func(key, typ string, state []byte) error { return fail },
+	})
+	if _, err := s.Create("likes", "pncounter"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RunOnce(context.Background()); !errors.Is(err, fail) {
+		t.Errorf("Expected the snapshot error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Run to stop with ctx, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// ReadRepair makes Get sync a document with a peer before returning it
+// when the document may be stale, trading read latency for fresher reads
+// in read-heavy services.
+//
+// The store keeps a watermark per document and peer: when a payload of the
+// peer for the document was last merged through MergeFrom or
+// MergeStateFrom, whether it changed the document or not. A document whose
+// newest watermark is older than MaxStaleness is stale, and Get calls Sync
+// with the peer of that watermark, the freshest known, before reading it.
+type ReadRepair struct {
+	MaxStaleness time.Duration
+
+	// Sync brings the document key of type typ up to date with peer, such
+	// as by asking it for the document and merging the answer with
+	// MergeFrom. A failed Sync is logged and Get returns the local
+	// document. Concurrent Gets of the same document share one Sync.
+	Sync func(ctx context.Context, peer, key, typ string) error
+}
+
+// watermarks are the per peer watermarks of the documents, see ReadRepair.
+type watermarks struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	marks    map[string]map[string]time.Time // By key, then peer
+	inflight map[string]chan struct{}        // Syncs in progress, by key
+}
+
+// mark records that the document key was synced with peer now.
+func (w *watermarks) mark(key, peer string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.marks == nil {
+		w.marks = make(map[string]map[string]time.Time)
+	}
+	if w.marks[key] == nil {
+		w.marks[key] = make(map[string]time.Time)
+	}
+	w.marks[key][peer] = w.now()
+}
+
+// forget drops the watermarks of the deleted document key.
+func (w *watermarks) forget(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.marks, key)
+}
+
+// freshest returns the newest watermark of key and its peer. It must be
+// called with w.mu held.
+func (w *watermarks) freshest(key string) (string, time.Time, bool) {
+	var peer string
+	var at time.Time
+	for p, t := range w.marks[key] {
+		if t.After(at) || t.Equal(at) && p < peer {
+			peer, at = p, t
+		}
+	}
+	return peer, at, !at.IsZero()
+}
+
+// Freshness returns the peer the document key was last synced with and
+// when, if it ever was, as ReadRepair sees it.
+func (s *Store) Freshness(key string) (peer string, at time.Time, ok bool) {
+	s.repair.mu.Lock()
+	defer s.repair.mu.Unlock()
+	return s.repair.freshest(key)
+}
+
+// Get returns the document key of type typ, like Open, repairing it first
+// when Config.ReadRepair is set and the document is stale: see ReadRepair.
+// Documents never synced with a peer have nothing to be repaired from.
+func (s *Store) Get(ctx context.Context, key, typ string) (gocrdt.Replicable, error) {
+	if r := s.config.ReadRepair; r != nil && r.Sync != nil {
+		s.readRepair(ctx, r, key, typ)
+	}
+	return s.Open(key, typ)
+}
+
+// readRepair syncs the document key if it is stale, or waits for the sync
+// in progress.
+func (s *Store) readRepair(ctx context.Context, r *ReadRepair, key, typ string) {
+	w := &s.repair
+	w.mu.Lock()
+	peer, at, ok := w.freshest(key)
+	if !ok || w.now().Sub(at) <= r.MaxStaleness {
+		w.mu.Unlock()
+		return
+	}
+	if done, busy := w.inflight[key]; busy {
+		w.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return
+	}
+	if w.inflight == nil {
+		w.inflight = make(map[string]chan struct{})
+	}
+	done := make(chan struct{})
+	w.inflight[key] = done
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		delete(w.inflight, key)
+		w.mu.Unlock()
+		close(done)
+	}()
+	if err := r.Sync(ctx, peer, key, typ); err != nil {
+		s.config.Logger.Warn("store: read repair failed", "key", key, "peer", peer, "err", err)
+		return
+	}
+	s.config.Logger.Debug("store: read repair", "key", key, "peer", peer, "staleness", w.now().Sub(at))
+	w.mark(key, peer)
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStore_ReadRepair(t *testing.T) {
+	remote := gocrdt.NewPNCounter("bob")
+	var mu sync.Mutex
+	var syncs []string
+	var s *Store
+	s = newStore(t, "alice", Config{ReadRepair: &ReadRepair{
+		MaxStaleness: time.Minute,
+		Sync: func(ctx context.Context, peer, key, typ string) error {
+			mu.Lock()
+			syncs = append(syncs, peer)
+			mu.Unlock()
+			payload, _ := remote.MarshalState()
+			_, err := s.MergeFrom(peer, key, typ, payload)
+			return err
+		},
+	}})
+	now := time.Unix(0, 0)
+	s.repair.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Never synced: nothing to repair from.
+	if _, err := s.Get(ctx, "likes", "pncounter"); err != nil || len(syncs) != 0 {
+		t.Fatalf("Expected no repair, got %v, %v", syncs, err)
+	}
+	payload, _ := remote.MarshalState()
+	s.MergeFrom("carol", "likes", "pncounter", payload)
+	now = now.Add(time.Second)
+	s.MergeFrom("bob", "likes", "pncounter", payload)
+	remote.Increment()
+
+	// Fresh enough: served as is.
+	doc, _ := s.Get(ctx, "likes", "pncounter")
+	if doc.(*gocrdt.PNCounter).Value() != 0 || len(syncs) != 0 {
+		t.Errorf("Expected the local document, got %d after %v", doc.(*gocrdt.PNCounter).Value(), syncs)
+	}
+
+	// Stale: repaired from bob, the freshest peer, by one of the readers.
+	now = now.Add(2 * time.Minute)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Get(ctx, "likes", "pncounter"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	doc, _ = s.Get(ctx, "likes", "pncounter")
+	if doc.(*gocrdt.PNCounter).Value() != 1 || len(syncs) != 1 || syncs[0] != "bob" {
+		t.Errorf("Expected one repair from bob, got %d after %v", doc.(*gocrdt.PNCounter).Value(), syncs)
+	}
+	if peer, at, ok := s.Freshness("likes"); !ok || peer != "bob" || !at.Equal(now) {
+		t.Errorf("Unexpected freshness %s, %v", peer, at)
+	}
+}
+
+func TestStore_ReadRepairFailure(t *testing.T) {
+	s := newStore(t, "alice", Config{ReadRepair: &ReadRepair{
+		Sync: func(context.Context, string, string, string) error { return errors.New("unreachable") },
+	}})
+	now := time.Unix(0, 0)
+	s.repair.now = func() time.Time { return now }
+	payload, _ := gocrdt.NewPNCounter("bob").MarshalState()
+	s.MergeFrom("bob", "likes", "pncounter", payload)
+	now = now.Add(time.Second)
+	if doc, err := s.Get(context.Background(), "likes", "pncounter"); err != nil || doc == nil {
+		t.Errorf("Expected the local document served, got %v", err)
+	}
+}
@@ -0,0 +1,504 @@
+// Package store manages many CRDT documents by key.
+//
+// An application typically replicates more than one document: "doc:123"
+// is an RGA, "likes:456" a PNCounter. A Store maps such keys to lazily
+// created CRDT instances of registered types and routes incoming payloads
+// to them:
+//
+//	s := store.New(store.Config{})
+//	s.Register("rga", func(string) gocrdt.Replicable { return gocrdt.NewRGA("alice") })
+//	s.Register("pncounter", func(string) gocrdt.Replicable { return gocrdt.NewPNCounter("alice") })
+//	// or, for every type of a gocrdt.Registry:
+//	s.RegisterTypes(gocrdt.DefaultRegistry(), "alice")
+//
+//	doc, _ := s.Open("doc:123", "rga")
+//	s.Merge("likes:456", "pncounter", payload)
+//
+// A Store is itself a gocrdt.Replicable whose state bundles every document,
+// so a single replicator.Replica can keep whole stores in sync.
+// With Config.ReplicaID set, the state also carries the set of live keys,
+// so documents removed with Delete stay deleted on every replica.
+package store
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+var (
+	// ErrUnknownType is returned for documents of a type never registered.
+	ErrUnknownType = errors.New("store: unknown type")
+
+	// ErrTypeMismatch is returned when a key is opened or merged with a
+	// type other than the one it was created with.
+	ErrTypeMismatch = errors.New("store: type mismatch")
+
+	// ErrDuplicateType is returned by Register for a type registered twice.
+	ErrDuplicateType = errors.New("store: type already registered")
+
+	// ErrForbidden is returned for remote changes the Authorizer denied.
+	ErrForbidden = errors.New("store: forbidden")
+
+	// ErrClosed is returned for accesses to a closed Store.
+	ErrClosed = errors.New("store: closed")
+)
+
+// Factory creates an empty CRDT for the document key.
+type Factory func(key string) gocrdt.Replicable
+
+// Operation is the kind of access a peer asks for on a document.
+type Operation string
+
+const (
+	// OpRead is sending the document's state to the peer.
+	OpRead Operation = "read"
+
+	// OpWrite is merging the peer's changes into the document.
+	OpWrite Operation = "write"
+)
+
+// Authorizer decides whether peer may perform op on the document key of
+// type typ. A non-nil error denies it. It is called for every document of
+// every payload exchanged with a peer, so it should be fast.
+type Authorizer func(peer, key, typ string, op Operation) error
+
+// Config tunes a Store. The zero value keeps documents in memory only.
+type Config struct {
+	// Load, when set, is called when a document is first opened and returns
+	// its persisted state (as produced by MarshalState), or nil if there is
+	// none. The state is merged into the fresh instance.
+	Load func(key, typ string) ([]byte, error)
+
+	// Authorize, when set, guards the documents exchanged with peers
+	// through MergeFrom, MergeStateFrom and MarshalStateFor: read-only
+	// collaborators, per-document ACLs, tenants. Local calls (Open, Do,
+	// Merge, MergeState) are not checked.
+	Authorize Authorizer
+
+	// Logger receives document loads, evictions and deletions (Debug),
+	// Close (Info) and documents skipped by MergeState or failing to evict
+	// (Warn). It defaults to discarding everything.
+	Logger gocrdt.Logger
+
+	// Save, when set with Load, persists the state of a document evicted
+	// from memory; Load brings it back at the next access. See MaxResident.
+	// Close saves every loaded document with it.
+	Save func(key, typ string, state []byte) error
+
+	// MaxResident and MemoryBudget, when positive and Save and Load are
+	// set, bound the documents kept in memory: their number, and the sum of
+	// their estimated footprints in bytes (see gocrdt.Footprint). Beyond
+	// either bound the least recently used documents are saved and
+	// unloaded, and loaded again on their next access. Instances obtained
+	// from Open before an eviction go stale, so evicting stores should be
+	// used through Do, View and Batch. MemoryBudget walks a document after
+	// every access to estimate it.
+	MaxResident  int
+	MemoryBudget int
+
+	// ReadRepair, when set, makes Get sync stale documents with a peer
+	// before returning them.
+	ReadRepair *ReadRepair
+
+	// ReplicaID, when set, names this replica in the replicated set of
+	// live document keys, which makes Delete available: see Store.Delete.
+	// Without it, a document removed on one replica comes back with the
+	// next merge from a replica that still has it.
+	ReplicaID string
+}
+
+// Store is a registry of named CRDT documents. It is safe for concurrent
+// use; each document has its own lock, so slow loads or merges of one
+// document never block the others.
+type Store struct {
+	config Config
+
+	mu    sync.RWMutex
+	types map[string]Factory
+	docs  map[string]*entry
+	keys  *gocrdt.UnsyncORSet[string] // live keys; nil without Config.ReplicaID
+
+	tenants map[string]*Tenant // by ID, created by Tenant; guarded by mu
+
+	watchMu sync.Mutex
+	watches map[*Watch]struct{}
+
+	lruMu     sync.Mutex
+	lru       *list.List // loaded entries, most recently used first
+	footprint int        // sum of the sizes of the loaded entries
+
+	closed atomic.Bool
+	repair watermarks
+}
+
+// entry is one document. state is set once loaded, under mu, and reset
+// when the document is evicted.
+type entry struct {
+	key string
+	typ string
+
+	mu    sync.Mutex
+	state gocrdt.Replicable
+
+	elem *list.Element // position in the LRU list while loaded; guarded by lruMu
+	size int           // footprint when last released; guarded by lruMu
+}
+
+// New creates an empty Store.
+func New(config Config) *Store {
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+	s := &Store{
+		config:  config,
+		types:   make(map[string]Factory),
+		docs:    make(map[string]*entry),
+		watches: make(map[*Watch]struct{}),
+		lru:     list.New(),
+		repair:  watermarks{now: time.Now},
+	}
+	if config.ReplicaID != "" {
+		s.keys = gocrdt.NewUnsyncORSet[string](config.ReplicaID)
+	}
+	return s
+}
+
+// Register makes documents of type typ available, created by factory.
+func (s *Store) Register(typ string, factory Factory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.types[typ]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateType, typ)
+	}
+	s.types[typ] = factory
+	return nil
+}
+
+// RegisterTypes registers every type of reg, with documents created for
+// the replica replicaID, such as gocrdt.DefaultRegistry's "gcounter",
+// "pncounter" and "rga". It stops at the first type already registered.
+func (s *Store) RegisterTypes(reg *gocrdt.Registry, replicaID string) error {
+	for _, tag := range reg.Tags() {
+		err := s.Register(tag, func(string) gocrdt.Replicable {
+			state, _ := reg.New(tag, replicaID) // registered tags never fail
+			return state
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry returns the entry of key, creating an unloaded one of type typ.
+func (s *Store) entry(key, typ string) (*entry, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	s.mu.RLock()
+	e, ok := s.docs[key]
+	_, registered := s.types[typ]
+	s.mu.RUnlock()
+	if !ok {
+		if !registered {
+			return nil, fmt.Errorf("%w %q for %q", ErrUnknownType, typ, key)
+		}
+		s.mu.Lock()
+		if e, ok = s.docs[key]; !ok {
+			var err error
+			if e, err = s.newEntry(key, typ); err != nil {
+				s.mu.Unlock()
+				return nil, err
+			}
+		}
+		s.mu.Unlock()
+	}
+	if e.typ != typ {
+		return nil, fmt.Errorf("%w: %q is a %q, not a %q", ErrTypeMismatch, key, e.typ, typ)
+	}
+	return e, nil
+}
+
+// newEntry adds an unloaded entry of type typ for key, which becomes live,
+// unless its tenant has no room for it. It must be called with s.mu held.
+func (s *Store) newEntry(key, typ string) (*entry, error) {
+	if t := s.tenantOf(key); t != nil {
+		if err := t.add(key); err != nil {
+			return nil, err
+		}
+	}
+	e := &entry{key: key, typ: typ}
+	s.docs[key] = e
+	if s.keys != nil && !s.keys.Contains(key) {
+		s.keys.Add(key)
+	}
+	return e, nil
+}
+
+// load creates and loads the document of e on first use. It must be
+// called with e.mu held, and fails once the store is closed, since Close
+// may already have saved the document.
+func (s *Store) load(key string, e *entry) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if e.state != nil {
+		return nil
+	}
+	s.mu.RLock()
+	factory := s.types[e.typ]
+	s.mu.RUnlock()
+
+	state := factory(key)
+	if s.config.Load != nil {
+		data, err := s.config.Load(key, e.typ)
+		if err != nil {
+			return fmt.Errorf("store: load %q: %w", key, err)
+		}
+		if data != nil {
+			if _, err := state.MergeState(data); err != nil {
+				return fmt.Errorf("store: load %q: %w", key, err)
+			}
+		}
+	}
+	e.state = state
+	s.config.Logger.Debug("store: opened document", "key", key, "type", e.typ)
+	return nil
+}
+
+// Open returns the document key of type typ, creating it (and loading its
+// persisted state) on first use.
+func (s *Store) Open(key, typ string) (gocrdt.Replicable, error) {
+	e, err := s.entry(key, typ)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer s.release(e)
+	if err := s.load(key, e); err != nil {
+		return nil, err
+	}
+	return e.state, nil
+}
+
+// Do runs fn on the document key of type typ while holding the document's
+// lock, so compound updates are not interleaved with merges routed by the
+// Store. fn must not call back into the Store for the same key. When fn
+// succeeds, the document is reported to the watches following it.
+func (s *Store) Do(key, typ string, fn func(gocrdt.Replicable) error) error {
+	if err := s.do(key, typ, s.metered(key, fn)); err != nil {
+		return err
+	}
+	s.notify(Change{Key: key, Type: typ})
+	return nil
+}
+
+// do is Do without notifying the watches.
+func (s *Store) do(key, typ string, fn func(gocrdt.Replicable) error) error {
+	e, err := s.entry(key, typ)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer s.release(e)
+	if err := s.load(key, e); err != nil {
+		return err
+	}
+	return fn(e.state)
+}
+
+// Merge routes a payload produced by MarshalState on a remote document to
+// the local document key, opening it if needed. The document is reported to
+// the watches following it when the payload changed it.
+func (s *Store) Merge(key, typ string, payload []byte) (gocrdt.MergeResult, error) {
+	var result gocrdt.MergeResult
+	err := s.do(key, typ, s.metered(key, func(state gocrdt.Replicable) error {
+		var err error
+		result, err = state.MergeState(payload)
+		return err
+	}))
+	if errors.Is(err, ErrTenantQuota) {
+		result.Rejected++
+	}
+	if result.Applied > 0 || result.Deleted > 0 {
+		s.notify(Change{Key: key, Type: typ, Remote: true})
+	}
+	return result, err
+}
+
+// MergeFrom is Merge for a payload received from peer. With
+// Config.Authorize set, a denied write is not merged and returns an error
+// wrapping ErrForbidden, with one Rejected entry. A merged payload is a
+// watermark of peer for the document: see ReadRepair.
+func (s *Store) MergeFrom(peer, key, typ string, payload []byte) (gocrdt.MergeResult, error) {
+	if err := s.authorize(peer, key, typ, OpWrite); err != nil {
+		return gocrdt.MergeResult{Rejected: 1}, err
+	}
+	result, err := s.Merge(key, typ, payload)
+	if err == nil {
+		s.repair.mark(key, peer)
+	}
+	return result, err
+}
+
+// authorize asks the Authorizer whether peer may perform op on key.
+func (s *Store) authorize(peer, key, typ string, op Operation) error {
+	if s.config.Authorize == nil {
+		return nil
+	}
+	if err := s.config.Authorize(peer, key, typ, op); err != nil {
+		return fmt.Errorf("%w: %s may not %s %q: %w", ErrForbidden, peer, op, key, err)
+	}
+	return nil
+}
+
+// Type returns the type of the document key, if it exists.
+func (s *Store) Type(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.docs[key]
+	if !ok {
+		return "", false
+	}
+	return e.typ, true
+}
+
+// Keys returns the keys of every document, sorted.
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.docs))
+	for key := range s.docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// footprinter is implemented by the CRDTs of this module, see
+// gocrdt.Footprint.
+type footprinter interface {
+	MemoryFootprint() gocrdt.Footprint
+}
+
+// MemoryFootprint estimates the memory held by the loaded documents that
+// report one (all types of this module do), plus the store's key index.
+// Documents never opened hold no state and are not counted.
+func (s *Store) MemoryFootprint() gocrdt.Footprint {
+	var total gocrdt.Footprint
+	s.mu.RLock()
+	entries := make([]*entry, 0, len(s.docs))
+	for key, e := range s.docs {
+		total.Index += len(key) + 64 // key, map entry and entry header
+		entries = append(entries, e)
+	}
+	s.mu.RUnlock()
+
+	for _, e := range entries {
+		e.mu.Lock()
+		if f, ok := e.state.(footprinter); ok {
+			total.Add(f.MemoryFootprint())
+		}
+		e.mu.Unlock()
+	}
+	return total
+}
+
+// Document is the wire form of one document in a Store state.
+type Document struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	State json.RawMessage `json:"state"`
+}
+
+// MarshalState encodes every document of the store.
+func (s *Store) MarshalState() ([]byte, error) {
+	return s.marshalDocs(func(string, string) bool { return true })
+}
+
+// MarshalStateFor encodes the documents peer may read, as authorized by
+// Config.Authorize. Denied documents are left out.
+func (s *Store) MarshalStateFor(peer string) ([]byte, error) {
+	return s.marshalDocs(func(key, typ string) bool {
+		return s.authorize(peer, key, typ, OpRead) == nil
+	})
+}
+
+// marshalDocs encodes the documents include accepts.
+func (s *Store) marshalDocs(include func(key, typ string) bool) ([]byte, error) {
+	docs := make([]Document, 0)
+	for _, key := range s.Keys() {
+		typ, _ := s.Type(key)
+		if !include(key, typ) {
+			continue
+		}
+		err := s.do(key, typ, func(state gocrdt.Replicable) error {
+			data, err := state.MarshalState()
+			docs = append(docs, Document{Key: key, Type: typ, State: data})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.encodeState(docs)
+}
+
+// MergeState routes every document of a remote store state to Merge. The
+// figures of all documents are summed; documents that fail (such as those
+// of unregistered types) are skipped and their errors joined. With
+// Config.ReplicaID set, the deletions of the remote store are applied too
+// and the documents it deleted are not merged back.
+func (s *Store) MergeState(data []byte) (gocrdt.MergeResult, error) {
+	return s.mergeDocs(data, s.Merge, nil)
+}
+
+// MergeStateFrom is MergeState for a state received from peer: every
+// document goes through MergeFrom, so documents the peer may not write are
+// skipped, and so are its deletions of them.
+func (s *Store) MergeStateFrom(peer string, data []byte) (gocrdt.MergeResult, error) {
+	merge := func(key, typ string, payload []byte) (gocrdt.MergeResult, error) {
+		return s.MergeFrom(peer, key, typ, payload)
+	}
+	return s.mergeDocs(data, merge, func(key, typ string) error {
+		return s.authorize(peer, key, typ, OpWrite)
+	})
+}
+
+// mergeDocs decodes a store state, merges its live keys and hands every
+// live document to merge. canDelete, when set, vets the deletions.
+func (s *Store) mergeDocs(data []byte, merge func(key, typ string, payload []byte) (gocrdt.MergeResult, error), canDelete func(key, typ string) error) (gocrdt.MergeResult, error) {
+	docs, keys, err := decodeState(data)
+	if err != nil {
+		return gocrdt.MergeResult{}, err
+	}
+	var total gocrdt.MergeResult
+	lifecycle := keys != nil && s.keys != nil
+	if lifecycle {
+		if total, err = s.mergeKeys(keys, canDelete); err != nil {
+			return total, err
+		}
+	}
+	var errs []error
+	for _, doc := range docs {
+		if lifecycle && !s.live(doc.Key) {
+			continue // deleted here after the remote store last saw it
+		}
+		result, err := merge(doc.Key, doc.Type, doc.State)
+		if err != nil {
+			s.config.Logger.Warn("store: skipped document", "key", doc.Key, "type", doc.Type, "err", err)
+		}
+		total.Add(result)
+		errs = append(errs, err)
+	}
+	return total, errors.Join(errs...)
+}
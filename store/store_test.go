@@ -0,0 +1,211 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// newStore creates a store for replica id with RGA and PN-Counter types.
+func newStore(t *testing.T, id string, config Config) *Store {
+	t.Helper()
+	s := New(config)
+	if err := s.Register("rga", func(string) gocrdt.Replicable { return gocrdt.NewRGA(id) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("pncounter", func(string) gocrdt.Replicable { return gocrdt.NewPNCounter(id) }); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore_OpenAndRoute(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	if err := s.Register("rga", nil); !errors.Is(err, ErrDuplicateType) {
+		t.Errorf("Expected a duplicate registration to fail, got %v", err)
+	}
+
+	doc, err := s.Open("doc:123", "rga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.(*gocrdt.RGA).Insert('h', gocrdt.ID{NodeID: "root"})
+	if again, _ := s.Open("doc:123", "rga"); again != doc {
+		t.Error("Expected Open to return the same instance")
+	}
+	if _, err := s.Open("doc:123", "pncounter"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected a type mismatch, got %v", err)
+	}
+	if _, err := s.Open("x", "orset"); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected an unknown type, got %v", err)
+	}
+
+	remote := gocrdt.NewPNCounter("bob")
+	remote.Increment()
+	payload, _ := remote.MarshalState()
+	if result, err := s.Merge("likes:456", "pncounter", payload); err != nil || result.Applied == 0 {
+		t.Fatalf("Merge failed: %+v, %v", result, err)
+	}
+	likes, _ := s.Open("likes:456", "pncounter")
+	if likes.(*gocrdt.PNCounter).Value() != 1 {
+		t.Errorf("Expected the payload to be routed to likes:456")
+	}
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != "doc:123" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestStore_LazyLoad(t *testing.T) {
+	saved := gocrdt.NewRGA("old")
+	saved.Insert('s', gocrdt.ID{NodeID: "root"})
+	state, _ := saved.MarshalState()
+
+	var mu sync.Mutex
+	loads := map[string]int{}
+	s := newStore(t, "alice", Config{Load: func(key, typ string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[key]++
+		if key == "doc:saved" {
+			return state, nil
+		}
+		return nil, nil
+	}})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Open("doc:saved", "rga"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	doc, _ := s.Open("doc:saved", "rga")
+	if doc.(*gocrdt.RGA).Value() != "s" || loads["doc:saved"] != 1 {
+		t.Errorf("Expected one load of the saved state, got %q after %d loads", doc.(*gocrdt.RGA).Value(), loads["doc:saved"])
+	}
+}
+
+func TestStore_Replicable(t *testing.T) {
+	alice, bob := newStore(t, "alice", Config{}), newStore(t, "bob", Config{})
+	_ = alice.Do("doc:1", "rga", func(state gocrdt.Replicable) error {
+		state.(*gocrdt.RGA).Insert('a', gocrdt.ID{NodeID: "root"})
+		return nil
+	})
+	_ = bob.Do("likes:1", "pncounter", func(state gocrdt.Replicable) error {
+		state.(*gocrdt.PNCounter).Decrement()
+		return nil
+	})
+
+	for _, pair := range [][2]*Store{{alice, bob}, {bob, alice}} {
+		data, err := pair[0].MarshalState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pair[1].MergeState(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, _ := alice.MarshalState()
+	b, _ := bob.MarshalState()
+	if string(a) == "" || len(alice.Keys()) != 2 || len(bob.Keys()) != 2 {
+		t.Fatalf("Stores did not exchange documents: %v vs %v", alice.Keys(), bob.Keys())
+	}
+	doc, _ := bob.Open("doc:1", "rga")
+	likes, _ := alice.Open("likes:1", "pncounter")
+	if doc.(*gocrdt.RGA).Value() != "a" || likes.(*gocrdt.PNCounter).Value() != -1 || len(a) != len(b) {
+		t.Errorf("Stores diverged:\n%s\n%s", a, b)
+	}
+
+	unknown := []byte(`[{"key":"s:1","type":"orset","state":{}}]`)
+	if _, err := alice.MergeState(unknown); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected unknown types to be reported, got %v", err)
+	}
+}
+
+func TestStore_MemoryFootprint(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	doc, err := s.Open("doc:1", "rga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.(*gocrdt.RGA).Insert('h', gocrdt.ID{NodeID: "root"})
+	counter, err := s.Open("likes:1", "pncounter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.(*gocrdt.PNCounter).Increment()
+
+	want := doc.(*gocrdt.RGA).MemoryFootprint()
+	want.Add(counter.(*gocrdt.PNCounter).MemoryFootprint())
+	got := s.MemoryFootprint()
+	if got.Content != want.Content || got.Index <= want.Index {
+		t.Errorf("Expected the documents' footprints plus the key index, got %+v for %+v", got, want)
+	}
+}
+
+func TestStore_Authorize(t *testing.T) {
+	// bob may read everything but only write "notes"; eve may read nothing.
+	authorize := func(peer, key, typ string, op Operation) error {
+		switch {
+		case peer == "eve":
+			return errors.New("unknown peer")
+		case op == OpWrite && key != "notes":
+			return errors.New("read-only")
+		}
+		return nil
+	}
+	alice, bob := newStore(t, "alice", Config{Authorize: authorize}), newStore(t, "bob", Config{})
+	for _, key := range []string{"notes", "spec"} {
+		_ = bob.Do(key, "rga", func(state gocrdt.Replicable) error {
+			state.(*gocrdt.RGA).Insert('b', gocrdt.ID{NodeID: "root"})
+			return nil
+		})
+	}
+
+	data, _ := bob.MarshalState()
+	result, err := alice.MergeStateFrom("bob", data)
+	if !errors.Is(err, ErrForbidden) || result.Applied != 1 || result.Rejected != 1 {
+		t.Errorf("Expected spec to be rejected, got %+v, %v", result, err)
+	}
+	if keys := alice.Keys(); len(keys) != 1 || keys[0] != "notes" {
+		t.Errorf("Expected only notes to be written, got %v", keys)
+	}
+	if _, err := alice.MergeState(data); err != nil {
+		t.Errorf("Expected local merges to be unrestricted, got %v", err)
+	}
+
+	if data, err := alice.MarshalStateFor("bob"); err != nil || !strings.Contains(string(data), `"spec"`) {
+		t.Errorf("Expected bob to read spec, got %s, %v", data, err)
+	}
+	if data, err := alice.MarshalStateFor("eve"); err != nil || string(data) != "[]" {
+		t.Errorf("Expected eve to read nothing, got %s, %v", data, err)
+	}
+	if _, err := alice.MergeFrom("eve", "notes", "rga", []byte("[]")); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected eve's write to be forbidden, got %v", err)
+	}
+}
+
+func TestStore_RegisterTypes(t *testing.T) {
+	s := New(Config{})
+	if err := s.RegisterTypes(gocrdt.DefaultRegistry(), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	counter, err := s.Open("views", "gcounter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.(*gocrdt.GCounter).Increment()
+	if slots := counter.(*gocrdt.GCounter).Slots(); slots["alice"] != 1 {
+		t.Errorf("Expected the document to belong to alice, got %v", slots)
+	}
+	if err := s.RegisterTypes(gocrdt.DefaultRegistry(), "alice"); !errors.Is(err, ErrDuplicateType) {
+		t.Errorf("Expected registering twice to fail, got %v", err)
+	}
+}
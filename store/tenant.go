@@ -0,0 +1,265 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// TenantSeparator separates the tenant from the document key in the keys
+// of the Store: the document "doc:1" of tenant "acme" is "acme/doc:1".
+const TenantSeparator = "/"
+
+var (
+	// ErrTenantQuota is matched (with errors.Is) by the errors returned for
+	// accesses over a tenant's quota.
+	ErrTenantQuota = errors.New("store: tenant quota exceeded")
+
+	// ErrInvalidTenant is returned for empty tenant IDs and those holding
+	// TenantSeparator.
+	ErrInvalidTenant = errors.New("store: invalid tenant")
+)
+
+// TenantQuota bounds the resources of a tenant. Zero fields are unlimited.
+type TenantQuota struct {
+	// MaxDocuments bounds the documents of the tenant. Creating one more,
+	// locally or by merging a remote payload, fails.
+	MaxDocuments int
+
+	// MaxBytes bounds the sum of the footprints of the tenant's documents,
+	// as measured after every write (see gocrdt.Footprint). Sizes are only
+	// known after a write, so the write exceeding MaxBytes is still made;
+	// the following ones are rejected until documents shrink or go.
+	MaxBytes int
+
+	// MaxOpsPerSecond bounds the writes (Do, Merge, Batch documents) to
+	// the tenant's documents per second.
+	MaxOpsPerSecond int
+}
+
+// TenantStats are the figures of a tenant.
+type TenantStats struct {
+	Documents int    // Documents of the tenant
+	Bytes     int    // Sum of their footprints when last written
+	Ops       uint64 // Writes admitted
+	Rejected  uint64 // Accesses refused over the quota
+}
+
+// Tenant is the share of a Store of one customer of a multi-tenant
+// service: an isolated key space, the keys of the Store under the tenant's
+// prefix, with its own quota and figures, so that one tenant's giant
+// document does not exhaust the resources of the others:
+//
+//	acme, _ := s.Tenant("acme", store.TenantQuota{MaxDocuments: 100, MaxBytes: 64 << 20})
+//	acme.Do("doc:1", "rga", edit) // "acme/doc:1" in s
+//
+// The quota applies to every access to the tenant's keys, including those
+// made on the Store and remote payloads merged by its MergeState. Instances
+// obtained from Open are not metered; write through Do, Merge or Batch.
+type Tenant struct {
+	store  *Store
+	id     string
+	prefix string
+	now    func() time.Time
+
+	mu       sync.Mutex
+	quota    TenantQuota
+	sizes    map[string]int // Footprint by key, for every document
+	bytes    int
+	window   time.Time // Start of the current second
+	windowed int       // Writes in the current second
+	stats    TenantStats
+}
+
+// Tenant returns the tenant id of the store, created on first use, and
+// sets its quota. Documents already under its prefix become its own.
+func (s *Store) Tenant(id string, quota TenantQuota) (*Tenant, error) {
+	if id == "" || strings.Contains(id, TenantSeparator) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTenant, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tenants[id]; ok {
+		t.mu.Lock()
+		t.quota = quota
+		t.mu.Unlock()
+		return t, nil
+	}
+	t := &Tenant{
+		store:  s,
+		id:     id,
+		prefix: id + TenantSeparator,
+		now:    time.Now,
+		quota:  quota,
+		sizes:  make(map[string]int),
+	}
+	for key := range s.docs {
+		if strings.HasPrefix(key, t.prefix) {
+			t.sizes[key] = 0
+		}
+	}
+	if s.tenants == nil {
+		s.tenants = make(map[string]*Tenant)
+	}
+	s.tenants[id] = t
+	return t, nil
+}
+
+// tenantOf returns the tenant owning key, if any. It must be called with
+// s.mu held.
+func (s *Store) tenantOf(key string) *Tenant {
+	id, _, ok := strings.Cut(key, TenantSeparator)
+	if !ok {
+		return nil
+	}
+	return s.tenants[id]
+}
+
+// tenant is tenantOf taking s.mu.
+func (s *Store) tenant(key string) *Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tenantOf(key)
+}
+
+// metered wraps the write fn to the document key with the admission and
+// measurement of its tenant, if any.
+func (s *Store) metered(key string, fn func(gocrdt.Replicable) error) func(gocrdt.Replicable) error {
+	t := s.tenant(key)
+	if t == nil {
+		return fn
+	}
+	return func(state gocrdt.Replicable) error {
+		if err := t.admit(); err != nil {
+			return err
+		}
+		defer t.measure(key, state)
+		return fn(state)
+	}
+}
+
+// ID returns the ID of the tenant.
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// Key returns the key of the Store of the tenant's document key.
+func (t *Tenant) Key(key string) string {
+	return t.prefix + key
+}
+
+// Open is Store.Open for the tenant's document key.
+func (t *Tenant) Open(key, typ string) (gocrdt.Replicable, error) {
+	return t.store.Open(t.Key(key), typ)
+}
+
+// Create is Store.Create for the tenant's document key.
+func (t *Tenant) Create(key, typ string) (gocrdt.Replicable, error) {
+	return t.store.Create(t.Key(key), typ)
+}
+
+// Do is Store.Do for the tenant's document key.
+func (t *Tenant) Do(key, typ string, fn func(gocrdt.Replicable) error) error {
+	return t.store.Do(t.Key(key), typ, fn)
+}
+
+// Merge is Store.Merge for the tenant's document key.
+func (t *Tenant) Merge(key, typ string, payload []byte) (gocrdt.MergeResult, error) {
+	return t.store.Merge(t.Key(key), typ, payload)
+}
+
+// Delete is Store.Delete for the tenant's document key.
+func (t *Tenant) Delete(key string) error {
+	return t.store.Delete(t.Key(key))
+}
+
+// Keys returns the keys of the tenant's documents, without its prefix,
+// sorted.
+func (t *Tenant) Keys() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, 0, len(t.sizes))
+	for key := range t.sizes {
+		keys = append(keys, strings.TrimPrefix(key, t.prefix))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Stats returns the figures of the tenant.
+func (t *Tenant) Stats() TenantStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	stats.Documents, stats.Bytes = len(t.sizes), t.bytes
+	return stats
+}
+
+// reject counts a refused access and returns its error.
+func (t *Tenant) reject(format string, args ...any) error {
+	t.stats.Rejected++
+	return fmt.Errorf("%w: %s: "+format, append([]any{ErrTenantQuota, t.id}, args...)...)
+}
+
+// add makes key one of the tenant's documents, if the quota leaves room
+// for it.
+func (t *Tenant) add(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.sizes[key]; ok {
+		return nil
+	}
+	if limit := t.quota.MaxDocuments; limit > 0 && len(t.sizes) >= limit {
+		return t.reject("%d documents", limit)
+	}
+	t.sizes[key] = 0
+	return nil
+}
+
+// remove drops the deleted document key.
+func (t *Tenant) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes -= t.sizes[key]
+	delete(t.sizes, key)
+}
+
+// admit accounts for a write, if the quota allows it.
+func (t *Tenant) admit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limit := t.quota.MaxBytes; limit > 0 && t.bytes >= limit {
+		return t.reject("%d bytes", limit)
+	}
+	if limit := t.quota.MaxOpsPerSecond; limit > 0 {
+		now := t.now()
+		if now.Sub(t.window) >= time.Second {
+			t.window, t.windowed = now, 0
+		}
+		if t.windowed >= limit {
+			return t.reject("%d ops per second", limit)
+		}
+		t.windowed++
+	}
+	t.stats.Ops++
+	return nil
+}
+
+// measure records the footprint of the document key after a write.
+func (t *Tenant) measure(key string, state gocrdt.Replicable) {
+	size := 0
+	if f, ok := state.(footprinter); ok {
+		size = f.MemoryFootprint().Total()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.sizes[key]; ok {
+		t.bytes += size - t.sizes[key]
+		t.sizes[key] = size
+	}
+}
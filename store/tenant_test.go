@@ -0,0 +1,97 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestTenant_Isolation(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	if _, err := s.Tenant("a/b", TenantQuota{}); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("Expected an invalid tenant, got %v", err)
+	}
+	acme, _ := s.Tenant("acme", TenantQuota{MaxDocuments: 1})
+	globex, _ := s.Tenant("globex", TenantQuota{})
+
+	if _, err := acme.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := globex.Create("doc", "rga"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.Create("other", "rga"); !errors.Is(err, ErrTenantQuota) {
+		t.Errorf("Expected acme out of documents, got %v", err)
+	}
+	// The quota holds for remote payloads too.
+	remote := gocrdt.NewPNCounter("bob")
+	remote.Increment()
+	payload, _ := remote.MarshalState()
+	if result, err := s.Merge("acme/likes", "pncounter", payload); !errors.Is(err, ErrTenantQuota) || result.Rejected != 1 {
+		t.Errorf("Expected the merge rejected, got %+v, %v", result, err)
+	}
+
+	if keys := s.Keys(); !slices.Equal(keys, []string{"acme/doc", "globex/doc"}) {
+		t.Errorf("Expected prefixed keys, got %v", keys)
+	}
+	if keys := acme.Keys(); !slices.Equal(keys, []string{"doc"}) {
+		t.Errorf("Expected acme's keys only, got %v", keys)
+	}
+	if stats := acme.Stats(); stats.Documents != 1 || stats.Rejected != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// A deletion frees room.
+	s = New(Config{ReplicaID: "alice"})
+	s.Register("rga", func(string) gocrdt.Replicable { return gocrdt.NewRGA("alice") })
+	acme, _ = s.Tenant("acme", TenantQuota{MaxDocuments: 1})
+	acme.Create("doc", "rga")
+	if err := acme.Delete("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.Create("other", "rga"); err != nil {
+		t.Errorf("Expected room after the deletion, got %v", err)
+	}
+}
+
+func TestTenant_Quota(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	acme, _ := s.Tenant("acme", TenantQuota{MaxOpsPerSecond: 2})
+	now := time.Unix(0, 0)
+	acme.now = func() time.Time { return now }
+
+	insert := func(doc gocrdt.Replicable) error {
+		rga := doc.(*gocrdt.RGA)
+		rga.Insert('x', gocrdt.ID{NodeID: "root"})
+		return nil
+	}
+	for i := range 3 {
+		err := acme.Do("doc", "rga", insert)
+		if i < 2 && err != nil || i == 2 && !errors.Is(err, ErrTenantQuota) {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	now = now.Add(time.Second)
+	if err := acme.Do("doc", "rga", insert); err != nil {
+		t.Errorf("Expected a new second to admit writes, got %v", err)
+	}
+	if stats := acme.Stats(); stats.Ops != 3 || stats.Rejected != 1 || stats.Bytes == 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Past MaxBytes, writes are rejected, Batch ones included.
+	acme, _ = s.Tenant("acme", TenantQuota{MaxBytes: 1})
+	if err := acme.Do("doc", "rga", insert); !errors.Is(err, ErrTenantQuota) {
+		t.Errorf("Expected acme over its bytes, got %v", err)
+	}
+	_, err := s.Batch([]DocRef{{Key: "acme/doc", Type: "rga"}}, func(map[string]gocrdt.Replicable) error { return nil })
+	if !errors.Is(err, ErrTenantQuota) {
+		t.Errorf("Expected the batch rejected, got %v", err)
+	}
+	if err := s.Do("doc", "rga", insert); err != nil {
+		t.Errorf("Expected keys outside tenants unmetered, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrWatchClosed is returned by Watch.Next once the watch is closed.
+var ErrWatchClosed = errors.New("store: watch closed")
+
+// Filter selects the documents a Watch follows: those whose key starts
+// with Prefix or is one of Keys. The zero Filter follows every document.
+type Filter struct {
+	Prefix string
+	Keys   []string
+}
+
+// Change reports that a document changed since the previous batch of its
+// Watch.
+type Change struct {
+	Key  string
+	Type string
+
+	// Remote is set when at least one of the changes came from a merged
+	// payload (Merge, MergeFrom, MergeState) rather than Do or Batch.
+	Remote bool
+
+	// Deleted is set when the last change deleted the document, see
+	// Store.Delete.
+	Deleted bool
+}
+
+// Watch follows the changes of the documents matching a Filter. Changes
+// that happen between two calls to Next are folded into one Change per
+// document, so a slow reader, such as a UI redrawing at its own pace, never
+// falls behind: it receives every document that changed, once.
+//
+// Only changes routed through the Store are seen. Mutations made directly
+// on an instance obtained from Open bypass it; use Do for those.
+type Watch struct {
+	store  *Store
+	prefix string
+	keys   map[string]bool
+
+	mu      sync.Mutex
+	pending map[string]Change
+	closed  bool
+	wake    chan struct{} // signalled when pending becomes non-empty or the watch closes
+}
+
+// Watch starts following the documents matching filter. Close the watch
+// when done with it.
+func (s *Store) Watch(filter Filter) *Watch {
+	w := &Watch{
+		store:   s,
+		prefix:  filter.Prefix,
+		pending: make(map[string]Change),
+		wake:    make(chan struct{}, 1),
+	}
+	if len(filter.Keys) > 0 {
+		w.keys = make(map[string]bool, len(filter.Keys))
+		for _, key := range filter.Keys {
+			w.keys[key] = true
+		}
+	}
+	s.watchMu.Lock()
+	s.watches[w] = struct{}{}
+	s.watchMu.Unlock()
+	return w
+}
+
+// match reports whether the watch follows key.
+func (w *Watch) match(key string) bool {
+	if w.keys == nil {
+		return strings.HasPrefix(key, w.prefix)
+	}
+	return w.keys[key] || (w.prefix != "" && strings.HasPrefix(key, w.prefix))
+}
+
+// add records a change of key for the next batch.
+func (w *Watch) add(c Change) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	if prev, ok := w.pending[c.Key]; ok {
+		c.Remote = c.Remote || prev.Remote
+		if c.Type == "" {
+			c.Type = prev.Type
+		}
+	}
+	w.pending[c.Key] = c
+	w.mu.Unlock()
+	w.signal()
+}
+
+// signal wakes a waiting Next without blocking.
+func (w *Watch) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Next waits until at least one followed document changed and returns
+// every document changed since the previous call, sorted by key. It
+// returns ErrWatchClosed once the watch is closed, or the context's error.
+func (w *Watch) Next(ctx context.Context) ([]Change, error) {
+	for {
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return nil, ErrWatchClosed
+		}
+		if len(w.pending) > 0 {
+			batch := make([]Change, 0, len(w.pending))
+			for _, c := range w.pending {
+				batch = append(batch, c)
+			}
+			clear(w.pending)
+			w.mu.Unlock()
+			sort.Slice(batch, func(i, j int) bool { return batch[i].Key < batch[j].Key })
+			return batch, nil
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// drain returns the changes pending, unsorted, without waiting.
+func (w *Watch) drain() []Change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	batch := make([]Change, 0, len(w.pending))
+	for _, c := range w.pending {
+		batch = append(batch, c)
+	}
+	clear(w.pending)
+	return batch
+}
+
+// Close stops the watch. Pending changes are discarded and a blocked Next
+// returns ErrWatchClosed. Closing twice is a no-op.
+func (w *Watch) Close() {
+	w.store.watchMu.Lock()
+	delete(w.store.watches, w)
+	w.store.watchMu.Unlock()
+
+	w.mu.Lock()
+	w.closed = true
+	clear(w.pending)
+	w.mu.Unlock()
+	w.signal()
+}
+
+// notify hands a change to the watches following its document.
+func (s *Store) notify(c Change) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for w := range s.watches {
+		if w.match(c.Key) {
+			w.add(c)
+		}
+	}
+}
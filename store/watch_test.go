@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestStore_Watch(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	docs := s.Watch(Filter{Prefix: "doc:"})
+	defer docs.Close()
+	likes := s.Watch(Filter{Keys: []string{"likes:1"}})
+	defer likes.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	insert := func(key string) {
+		t.Helper()
+		err := s.Do(key, "rga", func(state gocrdt.Replicable) error {
+			state.(*gocrdt.RGA).Insert('x', gocrdt.ID{NodeID: "root"})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("doc:2")
+	insert("doc:1")
+	insert("doc:2")
+	insert("other")
+
+	// Changes made before Next are folded into one batch, one per key.
+	batch, err := docs.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Key != "doc:1" || batch[1].Key != "doc:2" || batch[0].Remote {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+
+	remote := gocrdt.NewPNCounter("bob")
+	remote.Increment()
+	payload, _ := remote.MarshalState()
+	for range 2 { // the second merge changes nothing
+		if _, err := s.Merge("likes:1", "pncounter", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch, err = likes.Next(ctx)
+	if err != nil || len(batch) != 1 || batch[0] != (Change{Key: "likes:1", Type: "pncounter", Remote: true}) {
+		t.Errorf("Unexpected batch %+v, %v", batch, err)
+	}
+
+	// Neither the counter nor a failed Do reaches the prefix watch.
+	fail := errors.New("fail")
+	if err := s.Do("doc:1", "rga", func(gocrdt.Replicable) error { return fail }); !errors.Is(err, fail) {
+		t.Fatal(err)
+	}
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if batch, err := docs.Next(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected no changes, got %+v, %v", batch, err)
+	}
+	if batch, err := likes.Next(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the duplicate merge to be silent, got %+v, %v", batch, err)
+	}
+}
+
+func TestStore_WatchBatchAndClose(t *testing.T) {
+	s := newStore(t, "alice", Config{})
+	w := s.Watch(Filter{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan []Change)
+	go func() {
+		seen := map[string]bool{}
+		var all []Change
+		for len(seen) < 2 {
+			batch, err := w.Next(ctx)
+			if err != nil {
+				break
+			}
+			for _, c := range batch {
+				seen[c.Key] = true
+			}
+			all = append(all, batch...)
+		}
+		done <- all
+	}()
+	if _, err := comment(s, "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if batch := <-done; len(batch) != 2 {
+		t.Errorf("Expected both documents of the batch, got %+v", batch)
+	}
+
+	// A failed batch is rolled back and reports nothing.
+	if _, err := comment(s, "!", errors.New("fail")); err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if batch, err := w.Next(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected no changes, got %+v, %v", batch, err)
+	}
+
+	closed := make(chan error)
+	go func() {
+		_, err := w.Next(ctx)
+		closed <- err
+	}()
+	w.Close()
+	w.Close()
+	if err := <-closed; !errors.Is(err, ErrWatchClosed) {
+		t.Errorf("Expected ErrWatchClosed, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package gocrdt
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// streamNodes decodes a state in the format of MarshalState from rd, one
+// node at a time, and hands the nodes to merge in chunks of mergeChunkSize,
+// so only one chunk is held in memory. It returns the sum of the merges.
+// On a decoding or merge error the chunks merged so far stay merged.
+//
+// Both the current envelope, whose "v" and "tiebreak" must come before
+// "nodes" as MarshalState writes them, and the bare array of version 1 are
+// accepted. check vets the tie-break of the stream before any node is
+// merged, and merge receives it with every chunk.
+func streamNodes(rd io.Reader, check func(TieBreak) error, merge func([]Node, TieBreak) (MergeResult, error)) (MergeResult, error) {
+	var result MergeResult
+	dec := json.NewDecoder(rd)
+	tok, err := dec.Token()
+	if err != nil {
+		return result, err
+	}
+	switch tok {
+	case json.Delim('['):
+		if err := check(TieBreakNodeID); err != nil {
+			return result, err
+		}
+		return streamArray(dec, func(nodes []Node) (MergeResult, error) {
+			return merge(nodes, TieBreakNodeID)
+		})
+	case json.Delim('{'):
+	default:
+		return result, fmt.Errorf("gocrdt: state stream starts with %v, not a state", tok)
+	}
+
+	version := 0
+	tieBreak := TieBreakNodeID
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return result, err
+		}
+		switch key {
+		case "v":
+			if err := dec.Decode(&version); err != nil {
+				return result, err
+			}
+			if err := checkVersion(version); err != nil {
+				return result, err
+			}
+		case "tiebreak":
+			if err := dec.Decode(&tieBreak); err != nil {
+				return result, err
+			}
+		case "nodes":
+			if version == 0 {
+				return result, errors.New("gocrdt: state stream has no format version before its nodes")
+			}
+			if err := check(tieBreak); err != nil {
+				return result, err
+			}
+			if tok, err := dec.Token(); err != nil {
+				return result, err
+			} else if tok == nil {
+				continue
+			} else if tok != json.Delim('[') {
+				return result, fmt.Errorf("gocrdt: state stream nodes start with %v, not an array", tok)
+			}
+			merged, err := streamArray(dec, func(nodes []Node) (MergeResult, error) {
+				return merge(nodes, tieBreak)
+			})
+			result.Add(merged)
+			if err != nil {
+				return result, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return result, err
+			}
+		}
+	}
+	if version == 0 {
+		return result, errors.New("gocrdt: state stream has no format version")
+	}
+	_, err = dec.Token()
+	return result, err
+}
+
+// streamArray merges the nodes of an array whose opening bracket dec has
+// already read, up to and including its closing bracket.
+func streamArray(dec *json.Decoder, merge func([]Node) (MergeResult, error)) (MergeResult, error) {
+	var result MergeResult
+	chunk := make([]Node, 0, mergeChunkSize)
+	flush := func() error {
+		merged, err := merge(chunk)
+		result.Add(merged)
+		chunk = chunk[:0]
+		return err
+	}
+	for dec.More() {
+		chunk = append(chunk, Node{})
+		if err := dec.Decode(&chunk[len(chunk)-1]); err != nil {
+			return result, err
+		}
+		if len(chunk) == mergeChunkSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return result, err
+	}
+	if len(chunk) > 0 {
+		return result, flush()
+	}
+	return result, nil
+}
+
+// MergeFrom is MergeState for a state streamed from rd: nodes are decoded
+// and merged a chunk at a time, so a large snapshot is never held in memory
+// as a whole. See RGA.MergeFrom.
+func (r *UnsyncRGA) MergeFrom(rd io.Reader) (MergeResult, error) {
+	return streamNodes(rd, func(t TieBreak) error { return r.useTieBreak(t, 1) }, r.mergePayload)
+}
+
+// MergeFrom is MergeState for a state streamed from rd, such as a snapshot
+// file or a network connection. Nodes are decoded and merged in chunks of
+// mergeChunkSize, each under the write lock and checked by the validator
+// on its own, like MergeContext; only one chunk is held in memory. Nodes
+// whose parent comes in a later chunk are buffered as orphans until it
+// arrives.
+//
+// On a malformed stream or a rejected chunk the error is returned with the
+// result so far, and the chunks merged before stay merged; merging is
+// idempotent, so the stream can simply be merged again.
+func (r *RGA) MergeFrom(rd io.Reader) (MergeResult, error) {
+	return streamNodes(rd, func(t TieBreak) error { return r.useTieBreak(t, 1) }, func(nodes []Node, t TieBreak) (MergeResult, error) {
+		return r.merge(len(nodes), func() (MergeResult, error) {
+			return r.doc.mergePayload(nodes, t)
+		})
+	})
+}
+
+// WriteTo writes the state of the document to w, byte for byte as
+// MarshalState encodes it, one node at a time, so memory use does not grow
+// with the document. It implements io.WriterTo. See RGA.WriteTo.
+func (r *UnsyncRGA) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	written, err := r.writeNodes(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	return written - int64(bw.Buffered()), err
+}
+
+// writeNodes encodes the nodes of the document to bw in linearized order,
+// in the envelope of encodeNodes, and returns the number of bytes handed to
+// bw.
+func (r *UnsyncRGA) writeNodes(bw *bufio.Writer) (int64, error) {
+	var written int64
+	write := func(data []byte) error {
+		n, err := bw.Write(data)
+		written += int64(n)
+		return err
+	}
+	sep := []byte(fmt.Sprintf(`{"v":%d,"nodes":[`, WireVersion))
+	if r.tieBreak != TieBreakNodeID {
+		tieBreak, err := json.Marshal(r.tieBreak)
+		if err != nil {
+			return 0, err
+		}
+		sep = []byte(fmt.Sprintf(`{"v":%d,"tiebreak":%s,"nodes":[`, WireVersion, tieBreak))
+	}
+	for curr := r.root.Next; curr != nil; curr = curr.Next {
+		n := *curr
+		n.Next = nil
+		data, err := json.Marshal(n)
+		if err != nil {
+			return written, err
+		}
+		if err := write(sep); err != nil {
+			return written, err
+		}
+		if err := write(data); err != nil {
+			return written, err
+		}
+		sep = []byte(",")
+	}
+	if string(sep) != "," {
+		if err := write(sep); err != nil {
+			return written, err
+		}
+	}
+	return written, write([]byte("]}"))
+}
+
+// WriteTo streams the state of the document to w, such as a snapshot file
+// or a network connection, in bounded memory. The output is exactly that
+// of MarshalState, so MergeState accepts it, and so does MergeFrom, which
+// consumes it in chunks as it arrives. It implements io.WriterTo.
+//
+// The read lock is held until the whole state is written, so writes to a
+// slow w delay local edits and merges; write to a buffer or a file first
+// when that matters.
+func (r *RGA) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.WriteTo(w)
+}
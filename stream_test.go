@@ -0,0 +1,86 @@
+package gocrdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRGA_MergeFrom(t *testing.T) {
+	doc, _ := benchDoc(3*mergeChunkSize + 7)
+	doc.Delete(doc.Nodes()[10].ID)
+	state, err := doc.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := NewRGA("peer")
+	result, err := peer.MergeFrom(bytes.NewReader(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.Value() != doc.Value() || result.Applied != len(doc.Nodes()) || result.Orphaned != 0 {
+		t.Errorf("Expected the streamed state to match, got %+v", result)
+	}
+
+	// A reversed state crosses chunks with orphans, released at the end.
+	nodes := doc.Nodes()
+	slices.Reverse(nodes)
+	reversed, _ := json.Marshal(nodes)
+	unsync := NewUnsyncRGA("peer")
+	if _, err := unsync.MergeFrom(bytes.NewReader(reversed)); err != nil || unsync.Value() != doc.Value() {
+		t.Errorf("Expected the reversed stream to converge, got %v", err)
+	}
+
+	// A truncated stream keeps the chunks merged before the error.
+	cut := NewRGA("cut")
+	result, err = cut.MergeFrom(bytes.NewReader(state[:len(state)/2]))
+	if err == nil || result.Applied != mergeChunkSize || len(cut.Nodes()) != mergeChunkSize {
+		t.Errorf("Expected an error after one chunk, got %v with %+v", err, result)
+	}
+	if _, err := cut.MergeFrom(strings.NewReader(`{"not":"an array"}`)); err == nil {
+		t.Error("Expected an error for a stream that is not an array")
+	}
+}
+
+// failingWriter accepts n bytes, then fails.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		written := w.n
+		w.n = 0
+		return written, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestRGA_WriteTo(t *testing.T) {
+	doc, _ := benchDoc(2*mergeChunkSize + 3)
+	doc.Delete(doc.Nodes()[1].ID)
+	for _, d := range []*RGA{doc, NewRGA("empty")} {
+		want, _ := d.MarshalState()
+		var buf bytes.Buffer
+		n, err := d.WriteTo(&buf)
+		if err != nil || n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Expected WriteTo to match MarshalState (%d bytes), got %d bytes and %v", len(want), n, err)
+		}
+	}
+
+	// The stream feeds MergeFrom directly.
+	rd, w := io.Pipe()
+	go func() { w.CloseWithError(func() error { _, err := doc.WriteTo(w); return err }()) }()
+	peer := NewRGA("peer")
+	if _, err := peer.MergeFrom(rd); err != nil || peer.Value() != doc.Value() {
+		t.Errorf("Expected the piped state to converge, got %v", err)
+	}
+
+	if n, err := doc.WriteTo(&failingWriter{n: 100}); err == nil || n != 100 {
+		t.Errorf("Expected the write error after 100 bytes, got %d and %v", n, err)
+	}
+}
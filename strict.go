@@ -0,0 +1,81 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownNode is returned by InsertE for a parent, and by DeleteE
+	// for a node, the document has never seen.
+	ErrUnknownNode = errors.New("gocrdt: unknown node")
+
+	// ErrRootNode is returned by DeleteE for the root, which cannot be
+	// deleted.
+	ErrRootNode = errors.New("gocrdt: the root cannot be deleted")
+)
+
+// known reports whether the document has seen the node id, including a
+// tombstone removed by CompactStable.
+func (r *UnsyncRGA) known(id ID) bool {
+	if _, ok := r.registry[id]; ok {
+		return true
+	}
+	_, ok := r.collected[id]
+	return ok
+}
+
+// InsertE is Insert checking its parent. See RGA.InsertE.
+func (r *UnsyncRGA) InsertE(val rune, parentID ID) (ID, error) {
+	if !r.known(parentID) {
+		return ID{}, fmt.Errorf("%w: parent %v", ErrUnknownNode, parentID)
+	}
+	return r.Insert(val, parentID), nil
+}
+
+// DeleteE is Delete checking its node. See RGA.DeleteE.
+func (r *UnsyncRGA) DeleteE(id ID) error {
+	_, err := r.deleteE(id)
+	return err
+}
+
+// deleteE checks id and tombstones it, reporting whether this changed it.
+func (r *UnsyncRGA) deleteE(id ID) (bool, error) {
+	switch {
+	case id == r.root.ID:
+		return false, ErrRootNode
+	case !r.known(id):
+		return false, fmt.Errorf("%w: %v", ErrUnknownNode, id)
+	}
+	return r.delete(id), nil
+}
+
+// InsertE is Insert for callers that need to tell a dropped insert from a
+// successful one, such as an API taking parent IDs from its clients: it
+// fails with ErrUnknownNode when the document has never seen parentID,
+// where Insert must not be called. A deleted parent is valid, as for
+// Insert.
+func (r *RGA) InsertE(val rune, parentID ID) (ID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, err := r.doc.InsertE(val, parentID)
+	if err == nil {
+		r.changed()
+	}
+	return id, err
+}
+
+// DeleteE is Delete reporting the operations Delete silently ignores: it
+// fails with ErrUnknownNode for a node the document has never seen, such
+// as one of another replica not merged yet, and with ErrRootNode for the
+// root. Deleting a node already deleted succeeds, since deleting is
+// idempotent.
+func (r *RGA) DeleteE(id ID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed, err := r.doc.deleteE(id)
+	if changed {
+		r.changed()
+	}
+	return err
+}
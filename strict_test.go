@@ -0,0 +1,44 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRGA_InsertE(t *testing.T) {
+	doc := NewRGA("a")
+	id, err := doc.InsertE('a', ID{0, "root"})
+	if err != nil || doc.Value() != "a" {
+		t.Fatalf("Expected the insert to succeed, got %q, %v", doc.Value(), err)
+	}
+	if _, err := doc.InsertE('b', ID{7, "b"}); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("Expected ErrUnknownNode, got %v", err)
+	}
+	doc.Delete(id)
+	if _, err := doc.InsertE('c', id); err != nil || doc.Value() != "c" {
+		t.Errorf("Expected an insert after a tombstone, got %q, %v", doc.Value(), err)
+	}
+}
+
+func TestRGA_DeleteE(t *testing.T) {
+	doc := NewRGA("a")
+	id := doc.Insert('a', ID{0, "root"})
+	notify := doc.ChangeNotify()
+	if err := doc.DeleteE(id); err != nil || doc.Value() != "" {
+		t.Fatalf("Expected the delete to succeed, got %q, %v", doc.Value(), err)
+	}
+	select {
+	case <-notify:
+	default:
+		t.Error("Expected the delete to notify")
+	}
+	if err := doc.DeleteE(id); err != nil {
+		t.Errorf("Expected deleting twice to succeed, got %v", err)
+	}
+	if err := doc.DeleteE(ID{7, "b"}); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("Expected ErrUnknownNode, got %v", err)
+	}
+	if err := doc.DeleteE(ID{0, "root"}); !errors.Is(err, ErrRootNode) {
+		t.Errorf("Expected ErrRootNode, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package gocrdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownTask is returned by the methods of a TaskList for a task not in
+// the list.
+var ErrUnknownTask = errors.New("gocrdt: unknown task")
+
+// TaskStatus is the status of a task of a TaskList.
+type TaskStatus string
+
+// Statuses of a task. A TaskList accepts any other status too.
+const (
+	TaskTodo  TaskStatus = "todo"
+	TaskDoing TaskStatus = "doing"
+	TaskDone  TaskStatus = "done"
+)
+
+// Task is a task of a TaskList, as returned by Tasks.
+type Task struct {
+	ID     string
+	Title  string
+	Status TaskStatus
+}
+
+// Fields of a task in its ConfigMap.
+const (
+	taskTitle  = "title"
+	taskStatus = "status"
+	taskOrder  = "order" // Key of the node placing the task in the order
+)
+
+// taskListState is the wire representation of a TaskList.
+type taskListState struct {
+	V     int             `json:"v"`
+	Order json.RawMessage `json:"order"`
+	Tasks json.RawMessage `json:"tasks"`
+}
+
+// TaskList is an ordered list of tasks, the core of an offline-first todo
+// application, built from the types of this package:
+//
+//	todo := NewTaskList("phone")
+//	id := todo.Add("buy milk")
+//	todo.SetStatus(id, TaskDone)
+//
+// Tasks are the keys of an ORMap, so a task updated concurrently with its
+// deletion stays. The fields of a task (title, status and position) are a
+// ConfigMap, where concurrent writes of a field resolve to the last one
+// while writes of different fields all apply. Positions are nodes of an
+// RGA: Move inserts a node where the task goes and points the task at it,
+// so concurrent moves of a task leave it at one of their targets, and
+// tasks added or moved concurrently to the same place stay together.
+//
+// Moved and deleted tasks leave nodes in the order. Payloads are JSON.
+// TaskList is safe for concurrent use; it guards its parts with a
+// read/write mutex, or the lock chosen with WithLocking.
+type TaskList struct {
+	mu    rwLocker // See WithLocking
+	order UnsyncRGA
+	tasks UnsyncORMap[string, *UnsyncConfigMap[string]]
+}
+
+// NewTaskList creates an empty TaskList for the replica nodeID, which must
+// be unique. Of the options, WithLocking, WithClock, WithMaxOrphans and
+// WithConflictJournal apply.
+func NewTaskList(nodeID string, opts ...Option) *TaskList {
+	o := newOptions(opts)
+	return &TaskList{
+		mu:    newLocker(o.locking),
+		order: *NewUnsyncRGA(nodeID, WithClock(o.clock), WithMaxOrphans(o.maxOrphans), WithConflictJournal(o.journal)),
+		tasks: *NewUnsyncORMap[string](nodeID, func(id string) *UnsyncConfigMap[string] {
+			return NewUnsyncConfigMap[string](id, nil, WithConflictJournal(o.journal))
+		}),
+	}
+}
+
+// Add appends a task with title and status TaskTodo, and returns its ID.
+func (l *TaskList) Add(title string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	node := l.order.Insert(markerRune, l.order.last().ID)
+	id := idKey(node)
+	l.tasks.Update(id, func(fields *UnsyncConfigMap[string]) {
+		_ = fields.Set(taskTitle, title)
+		_ = fields.Set(taskStatus, string(TaskTodo))
+		_ = fields.Set(taskOrder, id)
+	})
+	return id
+}
+
+// set writes a field of the task id. It must be called with mu held.
+func (l *TaskList) set(id, field, value string) error {
+	if _, ok := l.tasks.Get(id); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTask, id)
+	}
+	l.tasks.Update(id, func(fields *UnsyncConfigMap[string]) {
+		_ = fields.Set(field, value)
+	})
+	return nil
+}
+
+// SetTitle sets the title of the task id.
+func (l *TaskList) SetTitle(id, title string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.set(id, taskTitle, title)
+}
+
+// SetStatus sets the status of the task id.
+func (l *TaskList) SetStatus(id string, status TaskStatus) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.set(id, taskStatus, string(status))
+}
+
+// Move moves the task id right after the task after, or to the top for an
+// empty after.
+func (l *TaskList) Move(id, after string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields, ok := l.tasks.Get(id)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTask, id)
+	}
+	anchor := l.order.root
+	if after != "" {
+		afterFields, ok := l.tasks.Get(after)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownTask, after)
+		}
+		key, _ := afterFields.Get(taskOrder)
+		if anchor = l.node(key); anchor == nil {
+			return fmt.Errorf("%w: %q is not placed yet", ErrUnknownTask, after)
+		}
+	}
+	current, _ := fields.Get(taskOrder)
+	node := l.order.Insert(markerRune, anchor.ID)
+	if old := l.node(current); old != nil {
+		l.order.Delete(old.ID)
+	}
+	return l.set(id, taskOrder, idKey(node))
+}
+
+// node returns the node of the order with the key, or nil. It must be
+// called with mu held.
+func (l *TaskList) node(key string) *Node {
+	for n := l.order.root.Next; n != nil; n = n.Next {
+		if idKey(n.ID) == key {
+			return n
+		}
+	}
+	return nil
+}
+
+// Delete deletes the task id. An update of the task concurrent with the
+// deletion wins over it.
+func (l *TaskList) Delete(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.tasks.Get(id); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTask, id)
+	}
+	l.tasks.Delete(id)
+	return nil
+}
+
+// Tasks returns the tasks of the list, in order.
+func (l *TaskList) Tasks() []Task {
+	l.mu.Lock() // Reading tasks may create their values
+	defer l.mu.Unlock()
+	placed := make(map[string]Task)
+	for id, fields := range l.tasks.Entries() {
+		title, _ := fields.Get(taskTitle)
+		status, _ := fields.Get(taskStatus)
+		order, _ := fields.Get(taskOrder)
+		placed[order] = Task{ID: id, Title: title, Status: TaskStatus(status)}
+	}
+	var tasks []Task
+	for n := l.order.root.Next; n != nil; n = n.Next {
+		if task, ok := placed[idKey(n.ID)]; ok && !n.Deleted {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// Value returns the tasks of the list as a []Task.
+func (l *TaskList) Value() any {
+	return l.Tasks()
+}
+
+// MarshalState encodes the order and the tasks.
+func (l *TaskList) MarshalState() ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	order, err := l.order.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := l.tasks.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(taskListState{V: WireVersion, Order: order, Tasks: tasks})
+}
+
+// MergeState merges a state produced by MarshalState on another replica:
+// the order, then the tasks. The result sums both.
+func (l *TaskList) MergeState(data []byte) (MergeResult, error) {
+	var state taskListState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return MergeResult{}, versionError(JSONCodec{}, data, err)
+	}
+	if err := checkVersion(state.V); err != nil {
+		return MergeResult{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result, err := l.order.MergeState(state.Order)
+	if err != nil {
+		return result, err
+	}
+	merged, err := l.tasks.MergeState(state.Tasks)
+	result.Add(merged)
+	return result, err
+}
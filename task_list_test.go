@@ -0,0 +1,92 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+// syncTasks merges the state of from into to.
+func syncTasks(t *testing.T, from, to *TaskList) {
+	t.Helper()
+	state, err := from.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// taskTitles returns the titles of the tasks of l.
+func taskTitles(l *TaskList) []string {
+	var titles []string
+	for _, task := range l.Tasks() {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func TestTaskList(t *testing.T) {
+	phone, laptop := NewTaskList("phone"), NewTaskList("laptop")
+	milk := phone.Add("milk")
+	eggs := phone.Add("eggs")
+	bread := phone.Add("bread")
+	syncTasks(t, phone, laptop)
+
+	// Concurrent writes of different fields both apply.
+	if err := phone.SetStatus(milk, TaskDone); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.SetTitle(milk, "oat milk"); err != nil {
+		t.Fatal(err)
+	}
+	// Concurrent moves of a task leave it at one place.
+	if err := phone.Move(bread, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.Move(bread, milk); err != nil {
+		t.Fatal(err)
+	}
+	syncTasks(t, phone, laptop)
+	syncTasks(t, laptop, phone)
+
+	got, want := phone.Tasks(), laptop.Tasks()
+	if len(got) != 3 || len(want) != 3 {
+		t.Fatalf("Expected three tasks on both replicas, got %v and %v", taskTitles(phone), taskTitles(laptop))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Expected the same tasks, got %+v and %+v", got[i], want[i])
+		}
+	}
+	for _, task := range got {
+		if task.ID == milk && (task.Title != "oat milk" || task.Status != TaskDone) {
+			t.Errorf("Expected both field writes, got %+v", task)
+		}
+	}
+
+	// An update concurrent with a deletion wins over it.
+	if err := phone.Delete(eggs); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.SetStatus(eggs, TaskDoing); err != nil {
+		t.Fatal(err)
+	}
+	syncTasks(t, phone, laptop)
+	syncTasks(t, laptop, phone)
+	if len(phone.Tasks()) != 3 {
+		t.Errorf("Expected the updated task to survive, got %v", taskTitles(phone))
+	}
+	if err := phone.Delete(eggs); err != nil {
+		t.Fatal(err)
+	}
+	if titles := taskTitles(phone); len(titles) != 2 {
+		t.Errorf("Expected the task deleted, got %v", titles)
+	}
+	if err := phone.SetTitle(eggs, "x"); !errors.Is(err, ErrUnknownTask) {
+		t.Errorf("Expected ErrUnknownTask, got %v", err)
+	}
+	if err := phone.Move(milk, eggs); !errors.Is(err, ErrUnknownTask) {
+		t.Errorf("Expected ErrUnknownTask, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package gocrdt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ThreeWay is the result of MergeThreeWay: the converged document and what
+// each side changed in the base, as TextPatches from the text of the base.
+type ThreeWay struct {
+	Merged *RGA
+
+	Ours, Theirs TextPatch // Changes of each side
+	Result       TextPatch // Changes of the merge, both sides together
+}
+
+// MergeThreeWay merges two divergent replicas of a document, ours and
+// theirs, given as MarshalState payloads like the base snapshot they both
+// descend from, for integrations with git-like workflows such as the merge
+// driver of a repository storing documents as snapshots.
+//
+// Merging CRDT states needs no base: the document converges the same
+// without it. The base tells what each side contributed, which Summary
+// describes. The merged document is a new RGA of nodeID, created with opts
+// as by NewRGA.
+func MergeThreeWay(nodeID string, base, ours, theirs []byte, opts ...Option) (*ThreeWay, error) {
+	merged := NewRGA(nodeID, opts...)
+	visible := make([][]visibleRune, 3)
+	for i, state := range [][]byte{base, ours, theirs} {
+		side := NewRGA(nodeID, append(opts, WithLocking(LockNone))...)
+		if _, err := side.MergeState(state); err != nil {
+			return nil, fmt.Errorf("gocrdt: three-way merge of %s: %w", [...]string{"base", "ours", "theirs"}[i], err)
+		}
+		visible[i] = visibleRunes(side.Nodes())
+		if _, err := merged.MergeState(state); err != nil {
+			return nil, err
+		}
+	}
+	patch := func(side []visibleRune) TextPatch {
+		return TextPatch{Old: runesText(visible[0]), New: runesText(side), Edits: diffRunes(visible[0], side)}
+	}
+	return &ThreeWay{
+		Merged: merged,
+		Ours:   patch(visible[1]),
+		Theirs: patch(visible[2]),
+		Result: patch(visibleRunes(merged.Nodes())),
+	}, nil
+}
+
+// Summary describes what each side contributed, an edit per line:
+//
+//	ours: 1 edit, +6 -0 runes
+//	  at 5: inserted " world"
+//	theirs: 1 edit, +0 -1 runes
+//	  at 0: deleted "H"
+func (m *ThreeWay) Summary() string {
+	var b strings.Builder
+	summarize(&b, "ours", m.Ours)
+	summarize(&b, "theirs", m.Theirs)
+	return b.String()
+}
+
+// summarize writes the summary of the patch of side to b.
+func summarize(b *strings.Builder, side string, patch TextPatch) {
+	inserted, deleted := 0, 0
+	for _, e := range patch.Edits {
+		inserted += len([]rune(e.Inserted))
+		deleted += len([]rune(e.Deleted))
+	}
+	noun := "edits"
+	if len(patch.Edits) == 1 {
+		noun = "edit"
+	}
+	fmt.Fprintf(b, "%s: %d %s, +%d -%d runes\n", side, len(patch.Edits), noun, inserted, deleted)
+	for _, e := range patch.Edits {
+		var parts []string
+		if e.Deleted != "" {
+			parts = append(parts, fmt.Sprintf("deleted %q", e.Deleted))
+		}
+		if e.Inserted != "" {
+			parts = append(parts, fmt.Sprintf("inserted %q", e.Inserted))
+		}
+		fmt.Fprintf(b, "  at %d: %s\n", e.Pos, strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,40 @@
+package gocrdt
+
+import "testing"
+
+func TestMergeThreeWay(t *testing.T) {
+	base := NewRGAFromString("a", "Hello")
+	baseState, _ := base.MarshalState()
+
+	ours := NewRGA("a")
+	ours.MergeState(baseState)
+	last := ours.Nodes()[4].ID
+	for _, r := range " world" {
+		last = ours.Insert(r, last)
+	}
+	theirs := NewRGA("b")
+	theirs.MergeState(baseState)
+	theirs.Delete(theirs.Nodes()[0].ID)
+	oursState, _ := ours.MarshalState()
+	theirsState, _ := theirs.MarshalState()
+
+	m, err := MergeThreeWay("merge", baseState, oursState, theirsState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Merged.Value() != "ello world" || m.Result.New != "ello world" || m.Result.Old != "Hello" {
+		t.Errorf("Expected both sides merged, got %q", m.Merged.Value())
+	}
+	want := `ours: 1 edit, +6 -0 runes
+  at 5: inserted " world"
+theirs: 1 edit, +0 -1 runes
+  at 0: deleted "H"
+`
+	if got := m.Summary(); got != want {
+		t.Errorf("Unexpected summary:\n%s", got)
+	}
+
+	if _, err := MergeThreeWay("merge", baseState, []byte("{"), theirsState); err == nil {
+		t.Error("Expected an invalid side to fail")
+	}
+}
@@ -0,0 +1,119 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTieBreakMismatch is returned when merging a payload of a document
+// ordering concurrent siblings by another TieBreak: merged as is, it would
+// make the replicas diverge.
+var ErrTieBreakMismatch = errors.New("gocrdt: tie-break mismatch")
+
+// TieBreak orders the nodes of an RGA inserted concurrently after the same
+// parent with the same timestamp. Nodes with different timestamps are
+// always ordered newest first.
+type TieBreak string
+
+const (
+	// TieBreakNodeID puts the node of the greater NodeID first. It is the
+	// default, and the order of every version of this package, but the
+	// same replica always wins: with "alice" and "bob" typing at the same
+	// place, bob's text comes first every time.
+	TieBreakNodeID TieBreak = ""
+
+	// TieBreakHash puts the node of the greater hash of its ID first, a
+	// deterministic but pseudo-random winner that changes from one
+	// timestamp to the next.
+	TieBreakHash TieBreak = "hash"
+)
+
+// String returns the name of the tie-break.
+func (t TieBreak) String() string {
+	if t == TieBreakNodeID {
+		return "nodeid"
+	}
+	return string(t)
+}
+
+// WithTieBreak sets the TieBreak of an RGA. It is part of the document:
+// payloads carry it, an empty document adopts the tie-break of the first
+// payload it merges, and merging a payload of another tie-break into a
+// non-empty document fails with ErrTieBreakMismatch. Counters ignore it.
+func WithTieBreak(t TieBreak) Option {
+	return func(o *options) { o.tieBreak = t }
+}
+
+// greater reports whether a goes before b among siblings of the document.
+func (r *UnsyncRGA) greater(a, b ID) bool {
+	if r.tieBreak == TieBreakHash && a.Timestamp == b.Timestamp {
+		if ha, hb := idHash(a), idHash(b); ha != hb {
+			return ha > hb
+		}
+	}
+	return a.Greater(b)
+}
+
+// idHash is the FNV-1a hash of id, timestamp included, so the replica
+// winning ties varies with the timestamp.
+func idHash(id ID) uint64 {
+	const prime = 1099511628211
+	h := uint64(14695981039346656037)
+	for i := range len(id.NodeID) {
+		h = (h ^ uint64(id.NodeID[i])) * prime
+	}
+	for ts, i := uint64(id.Timestamp), 0; i < 8; i, ts = i+1, ts>>8 {
+		h = (h ^ (ts & 0xff)) * prime
+	}
+	return h
+}
+
+// useTieBreak checks the tie-break of a payload of n nodes against the
+// document's. An empty document adopts it.
+func (r *UnsyncRGA) useTieBreak(t TieBreak, n int) error {
+	if t == r.tieBreak || n == 0 {
+		return nil
+	}
+	if t != TieBreakNodeID && t != TieBreakHash {
+		return fmt.Errorf("%w: unknown tie-break %q", ErrTieBreakMismatch, string(t))
+	}
+	if len(r.registry) == 1 && r.orphans == 0 && len(r.collected) == 0 {
+		r.tieBreak = t
+		return nil
+	}
+	return fmt.Errorf("%w: the document orders siblings by %v, the payload by %v", ErrTieBreakMismatch, r.tieBreak, t)
+}
+
+// mergePayload merges the nodes of a payload ordered by t, after checking
+// or adopting t as useTieBreak does. RGA runs it under one write lock, so no
+// other payload can change the tie-break between the check and the merge.
+func (r *UnsyncRGA) mergePayload(nodes []Node, t TieBreak) (MergeResult, error) {
+	if err := r.useTieBreak(t, len(nodes)); err != nil {
+		return MergeResult{Rejected: len(nodes)}, err
+	}
+	return r.MergeChecked(nodes)
+}
+
+// useTieBreak checks the tie-break of a payload of n nodes, under the write
+// lock when it may be adopted. See UnsyncRGA.useTieBreak. It only rejects
+// payloads early: merges go through mergePayload, which checks again.
+func (r *RGA) useTieBreak(t TieBreak, n int) error {
+	if t == r.TieBreak() || n == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doc.useTieBreak(t, n)
+}
+
+// TieBreak returns the TieBreak of the document.
+func (r *UnsyncRGA) TieBreak() TieBreak {
+	return r.tieBreak
+}
+
+// TieBreak returns the TieBreak of the document, see WithTieBreak.
+func (r *RGA) TieBreak() TieBreak {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.TieBreak()
+}
@@ -0,0 +1,149 @@
+package gocrdt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// raceAt makes a and b insert concurrently at the start of a shared
+// document with the same timestamp, and returns the merged text.
+func raceAt(t *testing.T, clock int64, opts ...Option) string {
+	t.Helper()
+	a := NewRGA("alice", append([]Option{WithClock(clock)}, opts...)...)
+	b := NewRGA("bob", append([]Option{WithClock(clock)}, opts...)...)
+	a.Insert('a', ID{NodeID: "root"})
+	b.Insert('b', ID{NodeID: "root"})
+	stateA, _ := a.MarshalState()
+	stateB, _ := b.MarshalState()
+	if _, err := a.MergeState(stateB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.MergeState(stateA); err != nil {
+		t.Fatal(err)
+	}
+	if a.Value() != b.Value() {
+		t.Fatalf("Replicas diverged: %q and %q", a.Value(), b.Value())
+	}
+	return a.Value().(string)
+}
+
+func TestTieBreak_Hash(t *testing.T) {
+	wins := map[string]int{}
+	for clock := range int64(32) {
+		if got := raceAt(t, clock); got != "ba" {
+			t.Fatalf("Expected bob to win by NodeID, got %q", got)
+		}
+		wins[raceAt(t, clock, WithTieBreak(TieBreakHash))]++
+	}
+	if wins["ab"] == 0 || wins["ba"] == 0 {
+		t.Errorf("Expected both replicas to win some ties, got %v", wins)
+	}
+}
+
+func TestTieBreak_Replicated(t *testing.T) {
+	doc := NewRGA("alice", WithTieBreak(TieBreakHash))
+	doc.Insert('x', ID{NodeID: "root"})
+	state, _ := doc.MarshalState()
+
+	// An empty replica adopts the tie-break, also from a stream.
+	fresh := NewRGA("bob")
+	if _, err := fresh.MergeState(state); err != nil || fresh.TieBreak() != TieBreakHash {
+		t.Errorf("Expected the tie-break to be adopted, got %v and %v", fresh.TieBreak(), err)
+	}
+	var stream bytes.Buffer
+	if _, err := doc.WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+	streamed := NewUnsyncRGA("carol")
+	if _, err := streamed.MergeFrom(&stream); err != nil || streamed.TieBreak() != TieBreakHash {
+		t.Errorf("Expected the streamed tie-break to be adopted, got %v and %v", streamed.TieBreak(), err)
+	}
+
+	// A document with content refuses another order.
+	other := NewRGA("dave")
+	other.Insert('y', ID{NodeID: "root"})
+	if result, err := other.MergeState(state); !errors.Is(err, ErrTieBreakMismatch) || result.Rejected != 1 {
+		t.Errorf("Expected ErrTieBreakMismatch, got %+v and %v", result, err)
+	}
+	if _, err := DecodeNodes(state); !errors.Is(err, ErrTieBreakMismatch) {
+		t.Errorf("Expected DecodeNodes to refuse the payload, got %v", err)
+	}
+}
+
+func TestTieBreak_MergeParallel(t *testing.T) {
+	var payloads [][]Node
+	for _, id := range []string{"a", "b", "c", "d"} {
+		doc := NewRGA(id)
+		parent := ID{NodeID: "root"}
+		for range parallelMergeMin / 2 {
+			parent = doc.Insert('x', parent)
+		}
+		payloads = append(payloads, doc.Nodes())
+	}
+	var all []Node
+	for _, p := range payloads {
+		all = append(all, p...)
+	}
+	sequential := NewUnsyncRGA("z", WithTieBreak(TieBreakHash))
+	sequential.Merge(all)
+	parallel := NewUnsyncRGA("z", WithTieBreak(TieBreakHash))
+	if _, err := parallel.MergeParallel(all, 4); err != nil {
+		t.Fatal(err)
+	}
+	got := parallel.Nodes()
+	for i, n := range sequential.Nodes() {
+		if got[i].ID != n.ID {
+			t.Fatalf("Expected MergeParallel to order siblings alike, differs at %d", i)
+		}
+	}
+}
+
+func TestTieBreak_ConcurrentAdoption(t *testing.T) {
+	hashed := NewRGA("alice", WithTieBreak(TieBreakHash))
+	hashed.Insert('h', ID{NodeID: "root"})
+	byNodeID := NewRGA("bob")
+	byNodeID.Insert('n', ID{NodeID: "root"})
+	hashState, _ := hashed.MarshalState()
+	var stream bytes.Buffer
+	byNodeID.WriteTo(&stream)
+	nodeIDState := stream.Bytes()
+
+	for range 50 {
+		doc := NewRGA("carol")
+		errs := make(chan error, 2)
+		done := make(chan struct{})
+		go func() {
+			_, err := doc.MergeState(hashState)
+			errs <- err
+		}()
+		go func() {
+			_, err := doc.MergeFrom(bytes.NewReader(nodeIDState))
+			errs <- err
+		}()
+		go func() {
+			defer close(done)
+			for range 10 {
+				doc.MarshalState()
+			}
+		}()
+		first, second := <-errs, <-errs
+		<-done
+
+		// One payload is merged under its own order, the other refused.
+		if (first == nil) == (second == nil) {
+			t.Fatalf("Expected exactly one payload merged, got %v and %v", first, second)
+		}
+		if err := errors.Join(first, second); !errors.Is(err, ErrTieBreakMismatch) {
+			t.Fatalf("Expected ErrTieBreakMismatch, got %v", err)
+		}
+		want := map[TieBreak]string{TieBreakHash: "h", TieBreakNodeID: "n"}[doc.TieBreak()]
+		if got := doc.Value(); got != want {
+			t.Fatalf("Expected %q under %v, got %q", want, doc.TieBreak(), got)
+		}
+		state, _ := doc.MarshalState()
+		if _, tieBreak, _ := decodeNodes(JSONCodec{}, state); tieBreak != doc.TieBreak() {
+			t.Fatalf("Expected the state to carry %v, got %v", doc.TieBreak(), tieBreak)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+//go:build exclude_from_tests
+// +build exclude_from_tests
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Reset  = "\033[0m"
+	Bold   = "\033[1m"
+	Red    = "\033[31m"
+	Green  = "\033[32m"
+	Yellow = "\033[33m"
+
+	lineWidth = 120
+)
+
+// BenchResult is the average of the runs of one benchmark.
+type BenchResult struct {
+	NsPerOp     float64 `json:"ns_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec,omitempty"`
+	Runs        int     `json:"runs"`
+}
+
+func main() {
+	pattern := flag.String("bench", ".", "Benchmarks to run (go test -bench)")
+	count := flag.Int("count", 3, "Runs per benchmark, averaged")
+	benchtime := flag.String("benchtime", "1s", "Duration or iterations per run (go test -benchtime)")
+	save := flag.String("save", "", "Write the results as JSON to this file")
+	baseline := flag.String("baseline", "", "Compare with results saved earlier with --save")
+	threshold := flag.Float64("threshold", 10, "Slowdown in percent reported as a regression")
+	flag.Parse()
+
+	args := []string{"test", "-run", "^$", "-bench", *pattern, "-benchmem",
+		"-count", strconv.Itoa(*count), "-benchtime", *benchtime, "./..."}
+	fmt.Printf("\n%sRUNNING: go %s%s\n\n", Yellow, strings.Join(args, " "), Reset)
+
+	var out bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Print(out.String())
+		fmt.Printf("%sBenchmarks failed: %v%s\n", Red, err, Reset)
+		os.Exit(1)
+	}
+
+	results := parseResults(&out)
+	var base map[string]BenchResult
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err == nil {
+			err = json.Unmarshal(data, &base)
+		}
+		if err != nil {
+			fmt.Printf("%sError reading baseline %s: %v%s\n", Red, *baseline, err, Reset)
+			os.Exit(1)
+		}
+	}
+
+	regressions := printResults(results, base, *threshold)
+
+	if *save != "" {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		if err := os.WriteFile(*save, data, 0o644); err != nil {
+			fmt.Printf("%sError saving %s: %v%s\n", Red, *save, err, Reset)
+			os.Exit(1)
+		}
+		fmt.Printf("Results saved to %s\n\n", *save)
+	}
+	if regressions > 0 {
+		fmt.Printf("%s%d benchmarks regressed by more than %.0f%%%s\n\n", Red, regressions, *threshold, Reset)
+		os.Exit(1)
+	}
+}
+
+// parseResults averages the benchmark lines of go test output, keyed by
+// package and benchmark name (without the GOMAXPROCS suffix).
+func parseResults(out *bytes.Buffer) map[string]BenchResult {
+	results := make(map[string]BenchResult)
+	pkg := ""
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimPrefix(line, "pkg: ")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			name = name[:i]
+		}
+		key := pkg + "." + name
+
+		r := results[key]
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				r.NsPerOp += value
+			case "B/op":
+				r.BytesPerOp += value
+			case "allocs/op":
+				r.AllocsPerOp += value
+			case "MB/s":
+				r.MBPerSec += value
+			}
+		}
+		r.Runs++
+		results[key] = r
+	}
+	for key, r := range results {
+		n := float64(r.Runs)
+		r.NsPerOp /= n
+		r.BytesPerOp /= n
+		r.AllocsPerOp /= n
+		r.MBPerSec /= n
+		results[key] = r
+	}
+	return results
+}
+
+// printResults prints a table of results, compared with base when set, and
+// returns the number of regressions.
+func printResults(results, base map[string]BenchResult, threshold float64) int {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%s%-70s %14s %12s %10s %10s%s\n", Bold, "Benchmark", "ns/op", "B/op", "allocs/op", "delta", Reset)
+	fmt.Println(strings.Repeat("─", lineWidth))
+
+	regressions := 0
+	for _, name := range names {
+		r := results[name]
+		delta := ""
+		if old, ok := base[name]; ok && old.NsPerOp > 0 {
+			change := 100 * (r.NsPerOp - old.NsPerOp) / old.NsPerOp
+			color := Green
+			if change > threshold {
+				color = Red
+				regressions++
+			} else if change > 0 {
+				color = Yellow
+			}
+			delta = fmt.Sprintf("%s%+9.1f%%%s", color, change, Reset)
+		}
+		fmt.Printf("%-70s %14.1f %12.0f %10.0f %10s\n", name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp, delta)
+	}
+	fmt.Println(strings.Repeat("─", lineWidth))
+	fmt.Println()
+	return regressions
+}
@@ -0,0 +1,242 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRejected is matched (with errors.Is) by the errors of merges whose
+// payload was rejected by a Validator. Nothing of a rejected payload is
+// merged.
+var ErrRejected = errors.New("gocrdt: payload rejected by validator")
+
+// Validator inspects a remote RGA payload before it is merged. Returning a
+// non-nil error rejects the whole payload. doc must only be read, through
+// Lookup and Clock.
+type Validator interface {
+	Validate(doc *UnsyncRGA, payload []Node) error
+}
+
+// AnomalyKind classifies a suspicious remote node.
+type AnomalyKind int
+
+const (
+	// Equivocation: a node reuses a known ID (or one repeated in the same
+	// payload) with a different parent or visible value. Replicas that
+	// received different versions would silently diverge.
+	Equivocation AnomalyKind = iota + 1
+
+	// ClockJump: a timestamp far ahead of every timestamp seen so far,
+	// which would drag the local clock along.
+	ClockJump
+
+	// Cycle: nodes whose parents lead back to themselves. They could never
+	// be integrated and would stay buffered as orphans forever.
+	Cycle
+)
+
+// String returns the name of the kind.
+func (k AnomalyKind) String() string {
+	switch k {
+	case Equivocation:
+		return "equivocation"
+	case ClockJump:
+		return "clock jump"
+	case Cycle:
+		return "cycle"
+	}
+	return fmt.Sprintf("AnomalyKind(%d)", int(k))
+}
+
+// Anomaly is the error reported by AnomalyDetector for a suspicious node.
+type Anomaly struct {
+	Kind AnomalyKind
+	Node Node
+
+	// Existing is the conflicting version of the node, for equivocations.
+	Existing Node
+}
+
+func (a *Anomaly) Error() string {
+	return fmt.Sprintf("gocrdt: %v at node %v", a.Kind, a.Node.ID)
+}
+
+// AnomalyDetector is a Validator rejecting Byzantine payloads: equivocating
+// nodes, implausible clock jumps and parent cycles. The zero value checks
+// equivocations and cycles only.
+type AnomalyDetector struct {
+	// MaxClockJump, when positive, rejects timestamps more than MaxClockJump
+	// ahead of the local clock and of the timestamps earlier in the
+	// payload. A replica that was offline during many edits legitimately
+	// jumps by their number, so leave ample headroom.
+	MaxClockJump int64
+
+	// OnAnomaly, when set, is called for every anomaly found, including
+	// those after the first in a payload.
+	OnAnomaly func(Anomaly)
+}
+
+// Validate returns the anomalies found in payload, joined, or nil.
+func (d AnomalyDetector) Validate(doc *UnsyncRGA, payload []Node) error {
+	var anomalies []error
+	report := func(a Anomaly) {
+		if d.OnAnomaly != nil {
+			d.OnAnomaly(a)
+		}
+		anomalies = append(anomalies, &a)
+	}
+
+	clock := doc.Clock()
+	fresh := make(map[ID]Node, len(payload)) // nodes the document does not know
+	for _, n := range payload {
+		if d.MaxClockJump > 0 && n.ID.Timestamp > clock+d.MaxClockJump {
+			report(Anomaly{Kind: ClockJump, Node: n})
+		}
+		clock = max(clock, n.ID.Timestamp)
+
+		existing, ok := doc.Lookup(n.ID)
+		if !ok {
+			existing, ok = fresh[n.ID]
+		}
+		if !ok {
+			fresh[n.ID] = n
+			continue
+		}
+		// The value of a tombstone is never shown: only its parent matters.
+		if existing.ParentID != n.ParentID || (!existing.Deleted && !n.Deleted && existing.Value != n.Value) {
+			report(Anomaly{Kind: Equivocation, Node: n, Existing: existing})
+		}
+	}
+
+	// Known nodes all descend from the root, so cycles can only run through
+	// fresh ones. Walk each parent chain once (0 = unvisited, 1 = on the
+	// current path, 2 = done).
+	state := make(map[ID]int, len(fresh))
+	for _, n := range payload {
+		var path []ID
+		for id := n.ID; ; {
+			if state[id] == 1 {
+				report(Anomaly{Kind: Cycle, Node: fresh[id]})
+				break
+			}
+			next, ok := fresh[id]
+			if !ok || state[id] == 2 {
+				break
+			}
+			state[id] = 1
+			path = append(path, id)
+			id = next.ParentID
+		}
+		for _, id := range path {
+			state[id] = 2
+		}
+	}
+	return errors.Join(anomalies...)
+}
+
+// SetValidator installs v to check every remote payload merged from now on;
+// nil removes it.
+func (r *UnsyncRGA) SetValidator(v Validator) {
+	r.validator = v
+}
+
+// Lookup returns a copy of the node with the given ID, if it is part of
+// the document. Buffered orphans and collected tombstones are not.
+func (r *UnsyncRGA) Lookup(id ID) (Node, bool) {
+	n, ok := r.registry[id]
+	if !ok || id == r.root.ID {
+		return Node{}, false
+	}
+	node := *n
+	node.Next = nil
+	return node, true
+}
+
+// Clock returns the highest timestamp seen by the document.
+func (r *UnsyncRGA) Clock() int64 {
+	return r.clock
+}
+
+// MergeChecked is Merge with the validator's verdict: a rejected payload
+// is not merged at all, its nodes are counted as Rejected and the error
+// wraps both ErrRejected and the validator's error.
+func (r *UnsyncRGA) MergeChecked(remoteNodes []Node) (MergeResult, error) {
+	if result, err := r.validate(remoteNodes); err != nil {
+		return result, err
+	}
+	return r.mergeNodes(remoteNodes), nil
+}
+
+// mergeNodes merges a validated payload node by node.
+func (r *UnsyncRGA) mergeNodes(remoteNodes []Node) MergeResult {
+	var result MergeResult
+	for _, n := range remoteNodes {
+		r.processNode(n, &result)
+	}
+	r.reportStuck()
+	return result
+}
+
+// validate runs the validator, if any, on a payload about to be merged, and
+// returns the result and error of its rejection.
+func (r *UnsyncRGA) validate(remoteNodes []Node) (MergeResult, error) {
+	if r.validator == nil {
+		return MergeResult{}, nil
+	}
+	if err := r.validator.Validate(r, remoteNodes); err != nil {
+		if r.logger != nil {
+			r.logger.Warn("gocrdt: rejected payload", "nodes", len(remoteNodes), "err", err)
+		}
+		return MergeResult{Rejected: len(remoteNodes)}, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	return MergeResult{}, nil
+}
+
+// SetValidator installs v to check every remote payload merged from now on;
+// nil removes it. See UnsyncRGA.SetValidator.
+func (r *RGA) SetValidator(v Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doc.SetValidator(v)
+}
+
+// Lookup returns a copy of the node with the given ID, if it is part of
+// the document.
+func (r *RGA) Lookup(id ID) (Node, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Lookup(id)
+}
+
+// MergeChecked is Merge with the validator's verdict. See
+// UnsyncRGA.MergeChecked.
+//
+// Payloads of snapshotMergeSize nodes or more are merged with snapshot
+// isolation for Value: the pre-merge rendering is published before the
+// merge starts and the post-merge one before the lock is released, so
+// Value never waits for the merge and switches from one consistent
+// snapshot to the next. Other readers still wait for the lock.
+func (r *RGA) MergeChecked(remoteNodes []Node) (MergeResult, error) {
+	return r.merge(len(remoteNodes), func() (MergeResult, error) {
+		return r.doc.MergeChecked(remoteNodes)
+	})
+}
+
+// merge runs fn, a merge of a payload of size nodes into r.doc, under the
+// write lock, with the snapshot isolation of MergeChecked.
+func (r *RGA) merge(size int, fn func() (MergeResult, error)) (MergeResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := size >= snapshotMergeSize
+	if snapshot {
+		r.render()
+	}
+	result, err := fn()
+	if result.Applied > 0 || result.Deleted > 0 {
+		r.changed()
+		if snapshot {
+			r.render()
+		}
+	}
+	return result, err
+}
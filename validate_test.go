@@ -0,0 +1,67 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRGA_AnomalyDetector(t *testing.T) {
+	root := ID{0, "root"}
+	doc := NewRGA("alice")
+	a := doc.Insert('a', root)
+	var reported []AnomalyKind
+	doc.SetValidator(AnomalyDetector{
+		MaxClockJump: 100,
+		OnAnomaly:    func(an Anomaly) { reported = append(reported, an.Kind) },
+	})
+
+	x, y := ID{5, "mallory"}, ID{6, "mallory"}
+	cases := []struct {
+		name    string
+		payload []Node
+		kind    AnomalyKind
+	}{
+		{"equivocation", []Node{{ID: a, ParentID: root, Value: 'b'}}, Equivocation},
+		{"equivocation in payload", []Node{{ID: x, ParentID: a, Value: 'x'}, {ID: x, ParentID: root, Value: 'x'}}, Equivocation},
+		{"clock jump", []Node{{ID: ID{1 << 40, "mallory"}, ParentID: a, Value: 'j'}}, ClockJump},
+		{"cycle", []Node{{ID: x, ParentID: y, Value: 'x'}, {ID: y, ParentID: x, Value: 'y'}}, Cycle},
+		{"self parent", []Node{{ID: x, ParentID: x, Value: 'x'}}, Cycle},
+	}
+	for _, tc := range cases {
+		reported = nil
+		result, err := doc.MergeChecked(tc.payload)
+		var anomaly *Anomaly
+		if !errors.Is(err, ErrRejected) || !errors.As(err, &anomaly) || anomaly.Kind != tc.kind {
+			t.Errorf("%s: expected a %v rejection, got %v", tc.name, tc.kind, err)
+		}
+		if result.Rejected != len(tc.payload) || result.Applied != 0 || len(reported) == 0 {
+			t.Errorf("%s: unexpected result %+v, reported %v", tc.name, result, reported)
+		}
+	}
+	if doc.Value() != "a" {
+		t.Errorf("Rejected payloads were merged: %q", doc.Value())
+	}
+
+	// Honest traffic passes: re-deliveries, tombstones with any value, and
+	// the payload of a peer that made many edits.
+	peer := NewRGA("bob")
+	peer.Merge(doc.Nodes())
+	last := a
+	for range 50 {
+		last = peer.Insert('p', last)
+	}
+	peer.Delete(a)
+	nodes := peer.Nodes()
+	nodes[0].Value = 0
+	if result, err := doc.MergeChecked(nodes); err != nil || result.Applied != 50 || result.Deleted != 1 {
+		t.Errorf("Honest payload rejected: %+v, %v", result, err)
+	}
+
+	// Merge reports rejections through the result only.
+	if result := doc.Merge([]Node{{ID: x, ParentID: x}}); result.Rejected != 1 {
+		t.Errorf("Expected Merge to count the rejection, got %+v", result)
+	}
+	if _, err := doc.MergeState([]byte(`[{"ID":{"Timestamp":9,"NodeID":"m"},"ParentID":{"Timestamp":9,"NodeID":"m"}}]`)); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected MergeState to return the rejection, got %v", err)
+	}
+}
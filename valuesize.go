@@ -0,0 +1,87 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrValueTooLarge is wrapped by the errors returned for values over the
+// size set with WithMaxValueSize.
+var ErrValueTooLarge = errors.New("gocrdt: value too large")
+
+// SizePolicy selects what a CRDT does with a value over the size set with
+// WithMaxValueSize. Every replica must use the same size and policy: they
+// are applied to local writes and merged states alike, so the replicas
+// agree on what became of such a value.
+type SizePolicy int
+
+const (
+	// SizeReject refuses the value: a local write fails, and a remote one
+	// is dropped as documented on each type. It is the default.
+	SizeReject SizePolicy = iota
+
+	// SizeTruncate keeps the first bytes of a string or []byte value that
+	// fit, cutting strings on a rune boundary. Other values are rejected.
+	SizeTruncate
+)
+
+// WithMaxValueSize bounds the size of the values of a ConfigMap and of the
+// elements of an ORSet to n bytes, so that one client cannot bloat the
+// memory of every replica. Strings and byte slices are measured by their
+// length, other values by their encoding with the codec. Values over n are
+// handled by policy. Zero, the default, is unbounded; counters and RGAs
+// ignore it.
+func WithMaxValueSize(n int, policy SizePolicy) Option {
+	return func(o *options) { o.maxValue, o.sizePolicy = n, policy }
+}
+
+// valueLimit is the bound of WithMaxValueSize.
+type valueLimit struct {
+	max    int
+	policy SizePolicy
+	codec  Codec
+}
+
+func newValueLimit(o options) valueLimit {
+	return valueLimit{max: o.maxValue, policy: o.sizePolicy, codec: o.codec}
+}
+
+// fitValue returns v when it fits in l, truncated under SizeTruncate, or
+// an error wrapping ErrValueTooLarge.
+func fitValue[V any](l valueLimit, v V) (V, error) {
+	if l.max <= 0 {
+		return v, nil
+	}
+	var size int
+	var truncated any
+	switch value := any(v).(type) {
+	case string:
+		size = len(value)
+		if size > l.max {
+			cut := l.max
+			for cut > 0 && !utf8.RuneStart(value[cut]) {
+				cut--
+			}
+			truncated = value[:cut]
+		}
+	case []byte:
+		size = len(value)
+		if size > l.max {
+			truncated = append([]byte(nil), value[:l.max]...)
+		}
+	default:
+		data, err := l.codec.Marshal(v)
+		if err != nil {
+			return v, err
+		}
+		size = len(data)
+	}
+	if size <= l.max {
+		return v, nil
+	}
+	if l.policy == SizeTruncate && truncated != nil {
+		return truncated.(V), nil
+	}
+	return v, fmt.Errorf("%w: %d bytes (max %d)", ErrValueTooLarge, size, l.max)
+}
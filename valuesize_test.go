@@ -0,0 +1,63 @@
+package gocrdt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxValueSize_ConfigMap(t *testing.T) {
+	limited := NewConfigMap[string]("a", nil, WithMaxValueSize(4, SizeReject))
+	if err := limited.Set("k", "toolong"); !errors.Is(err, ErrValueTooLarge) || !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected the value refused, got %v", err)
+	}
+
+	// A replica without the bound writes a large value: the bounded
+	// replicas quarantine it, or truncate it identically.
+	free := NewConfigMap[string]("b", nil)
+	free.Set("k", "héllo world")
+	if result := limited.Merge(free); result.Rejected != 1 || len(limited.Quarantined()) != 1 {
+		t.Errorf("Expected the value quarantined, got %+v", result)
+	}
+	truncating := NewConfigMap[string]("c", nil, WithMaxValueSize(2, SizeTruncate))
+	state, _ := free.MarshalState()
+	truncating.MergeState(state)
+	if v, _ := truncating.Get("k"); v != "h" {
+		t.Errorf("Expected the value cut on a rune boundary, got %q", v)
+	}
+	truncating.Set("k", strings.Repeat("x", 10))
+	if v, _ := truncating.Get("k"); v != "xx" {
+		t.Errorf("Expected the local write truncated, got %q", v)
+	}
+
+	ints := NewConfigMap[[]int]("d", nil, WithMaxValueSize(4, SizeTruncate))
+	if err := ints.Set("k", []int{1, 2, 3}); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected values that cannot be truncated refused, got %v", err)
+	}
+}
+
+func TestWithMaxValueSize_ORSet(t *testing.T) {
+	free := NewORSet[string]("a")
+	free.AddAll("ok", "way too long")
+
+	limited := NewORSet[string]("b", WithMaxValueSize(4, SizeReject))
+	if err := limited.AddE("way too long"); !errors.Is(err, ErrValueTooLarge) || limited.Len() != 0 {
+		t.Errorf("Expected the element refused, got %v", err)
+	}
+	if result := limited.Merge(free); result.Rejected != 1 || result.Applied != 1 || limited.Contains("way too long") {
+		t.Errorf("Expected the large element dropped, got %+v", result)
+	}
+	// The bounded replica's clock covers the dropped add, so the element
+	// is removed everywhere once it merges back: the replicas converge.
+	state, _ := limited.MarshalState()
+	free.MergeState(state)
+	if free.Len() != 1 || !free.Contains("ok") {
+		t.Errorf("Expected the replicas to converge, got %v", free.Elements())
+	}
+
+	truncating := NewORSet[string]("c", WithMaxValueSize(4, SizeTruncate))
+	truncating.Add("abcdef")
+	if !truncating.Contains("abcd") {
+		t.Errorf("Expected the element truncated, got %v", truncating.Elements())
+	}
+}
@@ -1,8 +1,116 @@
 package gocrdt
 
-const (
-	BuildVersion         string = "v1.0.0"
-	MajorBuildVersion    int64  = 1
-	MinorBuildVersion    int64  = 0
-	SubminorBuildVersion int64  = 0
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 )
+
+// ErrInvalidVersion is returned by Since for a token that Version did not
+// produce.
+var ErrInvalidVersion = errors.New("gocrdt: invalid version token")
+
+// Version is an opaque token for everything a document has seen, returned
+// by Version and accepted by Since. It is a plain string, so it can be
+// stored or sent as is, in a cookie, a header or a resume request; its
+// content is not part of the API.
+//
+// A token stands for a position in the change log of one document
+// instance. Another instance, including the same document restored after a
+// restart, does not know the position, and Since answers it with the whole
+// document instead of a delta.
+type Version string
+
+// newLogID returns a random identifier for a new change log.
+func newLogID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// version returns the token of a position in the change log.
+func (r *UnsyncRGA) version(cursor uint64) Version {
+	return Version(fmt.Sprintf("%s.%d", r.log, cursor))
+}
+
+// cursor returns the position of v in the change log, 0 for a token of
+// another log.
+func (r *UnsyncRGA) cursor(v Version) (uint64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	log, pos, ok := strings.Cut(string(v), ".")
+	cursor, err := strconv.ParseUint(pos, 10, 64)
+	if !ok || err != nil || log == "" {
+		return 0, fmt.Errorf("%w %q", ErrInvalidVersion, v)
+	}
+	if log != r.log || cursor > uint64(len(r.changes)) {
+		return 0, nil
+	}
+	return cursor, nil
+}
+
+// Version returns a token for everything the document has seen so far. See
+// RGA.Version.
+func (r *UnsyncRGA) Version() Version {
+	return r.version(uint64(len(r.changes)))
+}
+
+// Since returns the nodes changed after v and the token of the document
+// now. See RGA.Since.
+func (r *UnsyncRGA) Since(v Version) ([]Node, Version, error) {
+	cursor, err := r.cursor(v)
+	if err != nil {
+		return nil, v, err
+	}
+	nodes, next := r.Changes(cursor)
+	return nodes, r.version(next), nil
+}
+
+// MarshalSince encodes Since(v) as a payload accepted by MergeState, nil
+// when nothing changed.
+func (r *UnsyncRGA) MarshalSince(v Version) ([]byte, Version, error) {
+	cursor, err := r.cursor(v)
+	if err != nil {
+		return nil, v, err
+	}
+	data, next, err := r.MarshalChanges(cursor)
+	return data, r.version(next), err
+}
+
+// Version returns an opaque token for everything the document has seen so
+// far, local edits and merges alike. A client that disconnects keeps the
+// token of the last state it received and resumes with Since, getting only
+// what changed meanwhile:
+//
+//	nodes, token, err := doc.Since(token)
+//
+// Tokens replace the cursors of Changes, which are bare positions that must
+// not leave the replica that produced them.
+func (r *RGA) Version() Version {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Version()
+}
+
+// Since returns every node changed after the token v, and the token to pass
+// next. The empty token, and a token of another document instance (such as
+// this document before a restart), return the whole document: merging is
+// idempotent, so a client can always apply the result. A string that is not
+// a token returns an error wrapping ErrInvalidVersion.
+func (r *RGA) Since(v Version) ([]Node, Version, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.Since(v)
+}
+
+// MarshalSince encodes Since(v) as a payload accepted by MergeState, nil
+// when nothing changed.
+func (r *RGA) MarshalSince(v Version) ([]byte, Version, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.doc.MarshalSince(v)
+}
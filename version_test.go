@@ -0,0 +1,40 @@
+package gocrdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRGA_VersionSince(t *testing.T) {
+	doc := NewRGA("a")
+	first := doc.Insert('a', ID{0, "root"})
+	token := doc.Version()
+
+	doc.Insert('b', first)
+	doc.Delete(first)
+	nodes, next, err := doc.Since(token)
+	if err != nil || len(nodes) != 2 {
+		t.Fatalf("Expected the 2 changed nodes, got %v and %v", nodes, err)
+	}
+	if nodes, _, _ := doc.Since(next); len(nodes) != 0 {
+		t.Errorf("Expected nothing new since the returned token, got %v", nodes)
+	}
+
+	// A token of another instance resumes from scratch.
+	restored := NewRGA("a")
+	state, _ := doc.MarshalState()
+	restored.MergeState(state)
+	if nodes, _, err := restored.Since(token); err != nil || len(nodes) != 2 {
+		t.Errorf("Expected the whole document for a foreign token, got %v and %v", nodes, err)
+	}
+
+	client := NewRGA("client")
+	data, _, err := doc.MarshalSince("")
+	if _, merr := client.MergeState(data); err != nil || merr != nil || client.Value() != "b" {
+		t.Errorf("Expected MarshalSince to ship the document, got %q (%v, %v)", client.Value(), err, merr)
+	}
+
+	if _, _, err := doc.Since("garbage"); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("Expected ErrInvalidVersion, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package wasm exposes RGA documents and PN-Counters to JavaScript when
+// compiled to WebAssembly (GOOS=js GOARCH=wasm), see Register.
+//
+// Document and Counter are the API behind the bindings, in plain values
+// (strings, ints and byte slices), so it builds and is tested on every
+// platform. They wrap the same CRDTs as a Go backend, without locks (the
+// browser runs WebAssembly on one thread) and without starting goroutines,
+// so the states they exchange with it are the same bytes: a state from
+// Document.State merges into a gocrdt.RGA on the server, and back.
+package wasm
+
+import (
+	"fmt"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+// Document is a collaborative text document, edited by position in
+// visible characters from 0 (runes, not UTF-16 code units).
+type Document struct {
+	doc *gocrdt.RGA
+}
+
+// NewDocument creates an empty document for the replica nodeID.
+func NewDocument(nodeID string) *Document {
+	return &Document{doc: gocrdt.NewRGA(nodeID, gocrdt.WithLocking(gocrdt.LockNone))}
+}
+
+// Text returns the visible text.
+func (d *Document) Text() string {
+	return d.doc.Value().(string)
+}
+
+// visible returns the IDs of the visible characters, in order.
+func (d *Document) visible() []gocrdt.ID {
+	var ids []gocrdt.ID
+	for _, n := range d.doc.Nodes() {
+		if !n.Deleted {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+// Insert inserts text before the visible position pos.
+func (d *Document) Insert(pos int, text string) error {
+	ids := d.visible()
+	if pos < 0 || pos > len(ids) {
+		return fmt.Errorf("wasm: position %d out of range [0, %d]", pos, len(ids))
+	}
+	parent := gocrdt.ID{NodeID: "root"}
+	if pos > 0 {
+		parent = ids[pos-1]
+	}
+	for _, r := range text {
+		parent = d.doc.Insert(r, parent)
+	}
+	return nil
+}
+
+// Delete removes n visible characters from position pos.
+func (d *Document) Delete(pos, n int) error {
+	ids := d.visible()
+	if pos < 0 || n < 0 || pos+n > len(ids) {
+		return fmt.Errorf("wasm: range [%d, %d) out of [0, %d)", pos, pos+n, len(ids))
+	}
+	for _, id := range ids[pos : pos+n] {
+		d.doc.Delete(id)
+	}
+	return nil
+}
+
+// State returns the state of the document, see gocrdt.RGA.MarshalState.
+func (d *Document) State() ([]byte, error) {
+	return d.doc.MarshalState()
+}
+
+// Merge merges a state from another replica, in the browser or not, and
+// returns the number of changes it applied.
+func (d *Document) Merge(state []byte) (int, error) {
+	result, err := d.doc.MergeState(state)
+	return result.Applied + result.Deleted, err
+}
+
+// Counter is a counter that can be incremented and decremented.
+type Counter struct {
+	counter *gocrdt.PNCounter
+}
+
+// NewCounter creates a counter at 0 for the replica nodeID.
+func NewCounter(nodeID string) *Counter {
+	return &Counter{counter: gocrdt.NewPNCounter(nodeID, gocrdt.WithLocking(gocrdt.LockNone))}
+}
+
+// Increment adds 1 to the counter.
+func (c *Counter) Increment() {
+	c.counter.Increment()
+}
+
+// Decrement subtracts 1 from the counter.
+func (c *Counter) Decrement() {
+	c.counter.Decrement()
+}
+
+// Value returns the value of the counter.
+func (c *Counter) Value() int {
+	return c.counter.Value()
+}
+
+// State returns the state of the counter, see
+// gocrdt.PNCounter.MarshalState.
+func (c *Counter) State() ([]byte, error) {
+	return c.counter.MarshalState()
+}
+
+// Merge merges a state from another replica and returns the number of
+// slots it advanced.
+func (c *Counter) Merge(state []byte) (int, error) {
+	result, err := c.counter.MergeState(state)
+	return result.Applied, err
+}
@@ -0,0 +1,73 @@
+package wasm
+
+import (
+	"bytes"
+	"testing"
+
+	gocrdt "github.com/cshekharsharma/go-crdt"
+)
+
+func TestDocument(t *testing.T) {
+	d := NewDocument("browser")
+	if err := d.Insert(0, "hllo"); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Insert(1, "e")
+	_ = d.Delete(4, 1)
+	if d.Text() != "hell" {
+		t.Errorf("Expected hell, got %q", d.Text())
+	}
+	if err := d.Insert(9, "x"); err == nil {
+		t.Error("Expected an error out of range")
+	}
+	if err := d.Delete(3, 2); err == nil {
+		t.Error("Expected an error out of range")
+	}
+}
+
+func TestDocumentInteroperates(t *testing.T) {
+	d := NewDocument("browser")
+	_ = d.Insert(0, "hi")
+	state, err := d.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend merges the browser's state and sends its own back.
+	server := gocrdt.NewRGA("server")
+	if _, err := server.MergeState(state); err != nil {
+		t.Fatal(err)
+	}
+	server.Insert('!', server.Nodes()[1].ID)
+	back, _ := server.MarshalState()
+	if applied, err := d.Merge(back); err != nil || applied != 1 || d.Text() != "hi!" {
+		t.Errorf("Expected hi! with 1 change, got %q, %d, %v", d.Text(), applied, err)
+	}
+
+	// Both sides encode the same document byte for byte.
+	state, _ = d.State()
+	if !bytes.Equal(state, back) {
+		t.Errorf("Expected the same state on both sides:\n%s\n%s", state, back)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("browser")
+	c.Increment()
+	c.Increment()
+	c.Decrement()
+
+	server := gocrdt.NewPNCounter("server")
+	server.Increment()
+	state, _ := server.MarshalState()
+	if _, err := c.Merge(state); err != nil {
+		t.Fatal(err)
+	}
+	if c.Value() != 2 {
+		t.Errorf("Expected 2, got %d", c.Value())
+	}
+	state, _ = c.State()
+	if _, err := server.MergeState(state); err != nil || server.Value() != 2 {
+		t.Errorf("Expected the server at 2, got %d, %v", server.Value(), err)
+	}
+}
@@ -0,0 +1,109 @@
+//go:build js && wasm
+
+package wasm
+
+import "syscall/js"
+
+// Register installs the bindings as the global JavaScript object name:
+//
+//	const doc = gocrdt.newDocument("alice");
+//	doc.insert(0, "hello");
+//	socket.send(doc.state());      // Uint8Array
+//	doc.merge(new Uint8Array(buf)); // returns the changes applied
+//	doc.text();
+//
+//	const likes = gocrdt.newCounter("alice");
+//	likes.increment();
+//	likes.value();
+//
+// Methods that fail return a JavaScript Error rather than throwing, since
+// Go functions cannot throw into JavaScript; check results with
+// instanceof Error. The functions stay registered until the program exits,
+// so its main must not return.
+func Register(name string) {
+	js.Global().Set(name, js.ValueOf(map[string]any{
+		"newDocument": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return documentObject(NewDocument(args[0].String()))
+		}),
+		"newCounter": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return counterObject(NewCounter(args[0].String()))
+		}),
+	}))
+}
+
+// documentObject returns the JavaScript object of d.
+func documentObject(d *Document) js.Value {
+	return js.ValueOf(map[string]any{
+		"text": js.FuncOf(func(js.Value, []js.Value) any {
+			return d.Text()
+		}),
+		"insert": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return jsError(d.Insert(args[0].Int(), args[1].String()))
+		}),
+		"delete": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return jsError(d.Delete(args[0].Int(), args[1].Int()))
+		}),
+		"state": js.FuncOf(func(js.Value, []js.Value) any {
+			return stateResult(d.State())
+		}),
+		"merge": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return mergeResult(d.Merge(goBytes(args[0])))
+		}),
+	})
+}
+
+// counterObject returns the JavaScript object of c.
+func counterObject(c *Counter) js.Value {
+	return js.ValueOf(map[string]any{
+		"increment": js.FuncOf(func(js.Value, []js.Value) any {
+			c.Increment()
+			return nil
+		}),
+		"decrement": js.FuncOf(func(js.Value, []js.Value) any {
+			c.Decrement()
+			return nil
+		}),
+		"value": js.FuncOf(func(js.Value, []js.Value) any {
+			return c.Value()
+		}),
+		"state": js.FuncOf(func(js.Value, []js.Value) any {
+			return stateResult(c.State())
+		}),
+		"merge": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return mergeResult(c.Merge(goBytes(args[0])))
+		}),
+	})
+}
+
+// goBytes copies the Uint8Array v.
+func goBytes(v js.Value) []byte {
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+// stateResult returns state as a Uint8Array, or err.
+func stateResult(state []byte, err error) any {
+	if err != nil {
+		return jsError(err)
+	}
+	v := js.Global().Get("Uint8Array").New(len(state))
+	js.CopyBytesToJS(v, state)
+	return v
+}
+
+// mergeResult returns the changes applied by a merge, or err.
+func mergeResult(applied int, err error) any {
+	if err != nil {
+		return jsError(err)
+	}
+	return applied
+}
+
+// jsError returns err as a JavaScript Error, or undefined for nil.
+func jsError(err error) any {
+	if err == nil {
+		return js.Undefined()
+	}
+	return js.Global().Get("Error").New(err.Error())
+}
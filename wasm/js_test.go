@@ -0,0 +1,31 @@
+//go:build js && wasm
+
+package wasm
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	Register("gocrdt")
+	doc := js.Global().Get("gocrdt").Call("newDocument", "browser")
+	doc.Call("insert", 0, "hi")
+	if got := doc.Call("text").String(); got != "hi" {
+		t.Errorf("Expected hi, got %q", got)
+	}
+	if err := doc.Call("delete", 5, 1); !err.InstanceOf(js.Global().Get("Error")) {
+		t.Errorf("Expected an Error out of range, got %v", err)
+	}
+
+	other := js.Global().Get("gocrdt").Call("newDocument", "other")
+	if applied := other.Call("merge", doc.Call("state")).Int(); applied != 2 || other.Call("text").String() != "hi" {
+		t.Errorf("Expected hi merged with 2 changes, got %d", applied)
+	}
+
+	counter := js.Global().Get("gocrdt").Call("newCounter", "browser")
+	counter.Call("increment")
+	if counter.Call("value").Int() != 1 {
+		t.Errorf("Expected 1, got %v", counter.Call("value"))
+	}
+}
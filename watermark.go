@@ -0,0 +1,142 @@
+package gocrdt
+
+// Seen returns the number of increments of replica this counter has
+// observed: its slot, the watermark of that replica. See GCounter.Seen.
+func (c *UnsyncGCounter) Seen(replica string) int {
+	return c.slots[replica]
+}
+
+// Watermarks returns the watermarks of every replica, as Slots does.
+func (c *UnsyncGCounter) Watermarks() map[string]int {
+	return c.Slots()
+}
+
+// Lag returns how many increments of each replica of remote, a watermark
+// vector, this counter has not observed yet. See GCounter.Lag.
+func (c *UnsyncGCounter) Lag(remote map[string]int) map[string]int {
+	return lag(c.Seen, remote)
+}
+
+// OnAdvance registers fn to be called with the replica and the new
+// watermark of every slot a merge advances. See GCounter.OnAdvance.
+func (c *UnsyncGCounter) OnAdvance(fn func(replica string, seen int)) {
+	c.onAdvance = fn
+}
+
+// Seen returns the number of increments of replica this counter has
+// observed: its slot, the watermark of that replica. The difference
+// between the watermarks of two replicas for a third one is how far behind
+// the first is on it, which Lag computes for a whole vector.
+func (c *GCounter) Seen(replica string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if slot := c.slots[replica]; slot != nil {
+		return int(slot.Load())
+	}
+	return 0
+}
+
+// Watermarks returns the watermarks of every replica, as Slots does.
+func (c *GCounter) Watermarks() map[string]int {
+	return c.Slots()
+}
+
+// Lag compares this counter with remote, a watermark vector such as the
+// Watermarks of a peer: for every replica remote is ahead on, it returns
+// how many of its increments this counter has not observed yet. An empty
+// result means caught up.
+func (c *GCounter) Lag(remote map[string]int) map[string]int {
+	return lag(c.Seen, remote)
+}
+
+// OnAdvance registers fn to be called with the replica and the new
+// watermark of every slot a merge advances, so a replication layer learns
+// which replicas a merge brought news of. fn runs in the merging
+// goroutine, without a lock held. A nil fn stops the calls.
+func (c *GCounter) OnAdvance(fn func(replica string, seen int)) {
+	if fn == nil {
+		c.onAdvance.Store(nil)
+		return
+	}
+	c.onAdvance.Store(&fn)
+}
+
+// advanced reports the slot v.id advancing to v.value.
+func (c *GCounter) advanced(v slotValue) {
+	if fn := c.onAdvance.Load(); fn != nil {
+		(*fn)(v.id, int(v.value))
+	}
+}
+
+// Seen returns the number of increments and decrements of replica this
+// counter has observed, the watermark of that replica. See PNCounter.Seen.
+func (c *UnsyncPNCounter) Seen(replica string) int {
+	return saturatingAdd(c.pCounter.Seen(replica), c.nCounter.Seen(replica))
+}
+
+// Watermarks returns the watermarks of every replica.
+func (c *UnsyncPNCounter) Watermarks() map[string]int {
+	watermarks := c.pCounter.Slots()
+	for id, value := range c.nCounter.slots {
+		watermarks[id] = saturatingAdd(watermarks[id], value)
+	}
+	return watermarks
+}
+
+// Lag returns how many operations of each replica of remote, a watermark
+// vector, this counter has not observed yet. See PNCounter.Lag.
+func (c *UnsyncPNCounter) Lag(remote map[string]int) map[string]int {
+	return lag(c.Seen, remote)
+}
+
+// OnAdvance registers fn to be called with the replica and the new
+// watermark of every replica a merge advances. See PNCounter.OnAdvance.
+func (c *UnsyncPNCounter) OnAdvance(fn func(replica string, seen int)) {
+	c.onAdvance = fn
+}
+
+// Seen returns the number of increments and decrements of replica this
+// counter has observed, the watermark of that replica. Both only grow, so
+// like those of a GCounter, watermarks can be compared between replicas.
+func (c *PNCounter) Seen(replica string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Seen(replica)
+}
+
+// Watermarks returns the watermarks of every replica, to compare with Lag
+// on another replica.
+func (c *PNCounter) Watermarks() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Watermarks()
+}
+
+// Lag compares this counter with remote, a watermark vector such as the
+// Watermarks of a peer: for every replica remote is ahead on, it returns
+// how many of its operations this counter has not observed yet.
+func (c *PNCounter) Lag(remote map[string]int) map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counter.Lag(remote)
+}
+
+// OnAdvance registers fn to be called with the replica and the new
+// watermark of every replica a merge advances. fn is called with the lock
+// held and must not call back into the counter. A nil fn stops the calls.
+func (c *PNCounter) OnAdvance(fn func(replica string, seen int)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counter.OnAdvance(fn)
+}
+
+// lag returns the entries of remote that are ahead of seen, by how much.
+func lag(seen func(string) int, remote map[string]int) map[string]int {
+	behind := make(map[string]int)
+	for id, value := range remote {
+		if local := seen(id); value > local {
+			behind[id] = value - local
+		}
+	}
+	return behind
+}
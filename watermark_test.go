@@ -0,0 +1,49 @@
+package gocrdt
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestGCounter_Watermarks(t *testing.T) {
+	local, remote := NewGCounter("a"), NewGCounter("b")
+	remote.Increment()
+	remote.Increment()
+	remote.MergeSlots(map[string]int{"c": 5})
+	local.MergeSlots(map[string]int{"c": 3})
+
+	if lag := local.Lag(remote.Watermarks()); !maps.Equal(lag, map[string]int{"b": 2, "c": 2}) {
+		t.Errorf("Expected to lag 2 behind b and c, got %v", lag)
+	}
+
+	advanced := make(map[string]int)
+	local.OnAdvance(func(replica string, seen int) { advanced[replica] = seen })
+	state, _ := remote.MarshalState()
+	local.MergeState(state)
+	if !maps.Equal(advanced, map[string]int{"b": 2, "c": 5}) || local.Seen("c") != 5 {
+		t.Errorf("Expected b and c to advance, got %v", advanced)
+	}
+	if lag := local.Lag(remote.Watermarks()); len(lag) != 0 {
+		t.Errorf("Expected no lag after the merge, got %v", lag)
+	}
+}
+
+func TestPNCounter_Watermarks(t *testing.T) {
+	local, remote := NewPNCounter("a"), NewPNCounter("b")
+	remote.Increment()
+	remote.Decrement()
+	if seen := remote.Seen("b"); seen != 2 {
+		t.Errorf("Expected increments and decrements to count, got %d", seen)
+	}
+
+	var advanced []string
+	local.OnAdvance(func(replica string, seen int) { advanced = append(advanced, replica) })
+	local.Merge(remote)
+	local.Merge(remote)
+	if len(advanced) != 1 || advanced[0] != "b" {
+		t.Errorf("Expected a single advance of b, got %v", advanced)
+	}
+	if lag := local.Lag(remote.Watermarks()); len(lag) != 0 {
+		t.Errorf("Expected no lag, got %v", lag)
+	}
+}
@@ -0,0 +1,161 @@
+package gocrdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WireVersion is the version of the payloads this release writes: the
+// states, deltas and buckets of every CRDT. Decoders accept WireVersion and
+// every earlier version, so a fleet can be upgraded one replica at a time;
+// a payload of a later version fails with an error wrapping
+// ErrUnsupportedVersion instead of being misread.
+//
+// Version 1 payloads carried no version: an RGA was a bare array of nodes,
+// a GCounter a bare object of slots and a PNCounter {"p":...,"n":...}.
+// Version 2 wraps every payload in an object with a "v" field:
+//
+//	RGA         {"v":2,"nodes":[...]}, with "tiebreak" if not the default
+//	GCounter    {"v":2,"slots":{...}}
+//	PNCounter   {"v":2,"p":{...},"n":{...}}, as PNFloatCounter
+//	Epoched     {"v":2,"epoch":...,"state":...}
+//	ShardedRGA  {"v":2,"index":...,"shards":[...]}
+//	Namespace   {"v":2,"scope":...,"state":...}
+//	ORSet       {"v":2,"clock":{...},"entries":[...]}
+//	ORMap       {"v":2,"keys":...,"values":[...]}
+//	ConfigMap   {"v":2,"entries":[...]}
+//	RateLimiter {"v":2,"epoch":...,"slots":{...}}
+const WireVersion = 2
+
+// ErrUnsupportedVersion is returned when decoding a payload written by a
+// later release, in a format version this one does not know.
+var ErrUnsupportedVersion = errors.New("gocrdt: unsupported wire format version")
+
+// checkVersion returns an error for a version later than WireVersion.
+func checkVersion(v int) error {
+	if v > WireVersion {
+		return fmt.Errorf("%w %d (newest known is %d)", ErrUnsupportedVersion, v, WireVersion)
+	}
+	return nil
+}
+
+// versionError explains why data, which decoded neither as the current nor
+// as the legacy format, was refused: its version when it has a later one,
+// err otherwise.
+func versionError(c Codec, data []byte, err error) error {
+	var probe struct {
+		V int `json:"v"`
+	}
+	if c.Unmarshal(data, &probe) == nil {
+		if verr := checkVersion(probe.V); verr != nil {
+			return verr
+		}
+	}
+	if err == nil {
+		err = errors.New("gocrdt: payload has no format version")
+	}
+	return err
+}
+
+// nodesWire is the wire representation of RGA nodes.
+type nodesWire struct {
+	V        int      `json:"v"`
+	TieBreak TieBreak `json:"tiebreak,omitempty"` // See WithTieBreak
+	Nodes    []Node   `json:"nodes"`
+}
+
+// slotsWire is the wire representation of a GCounter.
+type slotsWire struct {
+	V     int            `json:"v"`
+	Slots map[string]int `json:"slots"`
+}
+
+// encodeNodes is the single place RGA node lists are serialized. The codec
+// is set at construction, so it can be read without the lock. The
+// tie-break is not: an empty document adopts the one of the first payload
+// it merges, so callers pass the tie-break read together with the nodes.
+func (r *UnsyncRGA) encodeNodes(nodes []Node, tieBreak TieBreak) ([]byte, error) {
+	return r.codec.Marshal(nodesWire{V: WireVersion, TieBreak: tieBreak, Nodes: nodes})
+}
+
+func encodeNodes(c Codec, nodes []Node) ([]byte, error) {
+	return c.Marshal(nodesWire{V: WireVersion, Nodes: nodes})
+}
+
+// decodeNodes decodes a node list of any supported version, with the
+// TieBreak of the document it comes from. The codec is set at
+// construction, so it can be read without the lock.
+func (r *UnsyncRGA) decodeNodes(data []byte) ([]Node, TieBreak, error) {
+	return decodeNodes(r.codec, data)
+}
+
+func decodeNodes(c Codec, data []byte) ([]Node, TieBreak, error) {
+	var w nodesWire
+	err := c.Unmarshal(data, &w)
+	if err == nil && w.V > 0 {
+		return w.Nodes, w.TieBreak, checkVersion(w.V)
+	}
+	var nodes []Node
+	if c.Unmarshal(data, &nodes) == nil {
+		return nodes, TieBreakNodeID, nil
+	}
+	return nil, TieBreakNodeID, versionError(c, data, err)
+}
+
+// EncodeNodes encodes nodes as an RGA payload in the current format and the
+// default JSONCodec, for tools that build or rewrite payloads outside a
+// document, like the coalescing of a replicator.
+func EncodeNodes(nodes []Node) ([]byte, error) {
+	return encodeNodes(JSONCodec{}, nodes)
+}
+
+// DecodeNodes decodes an RGA payload (a state, a delta or buckets) of any
+// supported version written with the default JSONCodec. Payloads of a
+// document with another TieBreak than the default fail with
+// ErrTieBreakMismatch: their nodes alone would be merged in another order.
+func DecodeNodes(data []byte) ([]Node, error) {
+	nodes, tieBreak, err := decodeNodes(JSONCodec{}, data)
+	if err == nil && tieBreak != TieBreakNodeID {
+		err = fmt.Errorf("%w: payload orders siblings by %v", ErrTieBreakMismatch, tieBreak)
+	}
+	return nodes, err
+}
+
+// encodeSlots encodes the slots of a GCounter.
+func encodeSlots(c Codec, slots map[string]int) ([]byte, error) {
+	if slots == nil {
+		slots = map[string]int{} // null would read as a version 1 payload
+	}
+	return c.Marshal(slotsWire{V: WireVersion, Slots: slots})
+}
+
+// decodeSlots decodes the slots of a GCounter of any supported version. A
+// version 1 payload may hold a replica named "v", so an object with "v" but
+// no "slots" is only taken for the current format when it is not a valid
+// version 1 payload, as an empty counter is with a codec omitting empty
+// maps.
+func decodeSlots(c Codec, data []byte) (map[string]int, error) {
+	var w slotsWire
+	err := c.Unmarshal(data, &w)
+	if err == nil && w.V > 0 && w.Slots != nil {
+		return w.Slots, checkVersion(w.V)
+	}
+	var slots map[string]int
+	if c.Unmarshal(data, &slots) == nil {
+		return slots, nil
+	}
+	if err == nil && w.V > 0 {
+		return nil, checkVersion(w.V)
+	}
+	return nil, versionError(c, data, err)
+}
+
+// decodePN decodes the state of a PNCounter of any supported version:
+// version 1 had the same fields but no "v".
+func decodePN(c Codec, data []byte) (pnCounterState, error) {
+	var state pnCounterState
+	if err := c.Unmarshal(data, &state); err != nil {
+		return state, versionError(c, data, err)
+	}
+	return state, checkVersion(state.V)
+}
@@ -0,0 +1,84 @@
+package gocrdt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// legacyState is a version 1 RGA state holding "h".
+const legacyState = `[{"ID":{"Timestamp":1,"NodeID":"b"},"ParentID":{"Timestamp":0,"NodeID":"root"},"Value":104,"Deleted":false}]`
+
+func TestWire_AcceptsVersion1(t *testing.T) {
+	doc := NewRGA("a")
+	if _, err := doc.MergeState([]byte(legacyState)); err != nil || doc.Value() != "h" {
+		t.Errorf("Expected a version 1 RGA state to merge, got %q and %v", doc.Value(), err)
+	}
+
+	// A replica named "v" must not turn a version 1 counter into an
+	// envelope.
+	counter := NewGCounter("a")
+	if _, err := counter.MergeState([]byte(`{"v":3,"b":4}`)); err != nil || counter.Value() != 7 {
+		t.Errorf("Expected a version 1 GCounter state to merge, got %d and %v", counter.Value(), err)
+	}
+
+	pn := NewUnsyncPNCounter("a")
+	if _, err := pn.MergeState([]byte(`{"p":{"b":5},"n":{"c":2}}`)); err != nil || pn.Value() != 3 {
+		t.Errorf("Expected a version 1 PNCounter state to merge, got %d and %v", pn.Value(), err)
+	}
+
+	stream := NewRGA("a")
+	if _, err := stream.MergeFrom(strings.NewReader(legacyState)); err != nil || stream.Value() != "h" {
+		t.Errorf("Expected a version 1 RGA stream to merge, got %q and %v", stream.Value(), err)
+	}
+}
+
+func TestWire_RejectsFutureVersions(t *testing.T) {
+	payloads := map[string]struct {
+		merge func([]byte) (MergeResult, error)
+		data  string
+	}{
+		"rga":      {NewRGA("a").MergeState, `{"v":3,"nodes":[]}`},
+		"reshaped": {NewRGA("a").MergeState, `{"v":3,"nodes":{"chunks":[]}}`},
+		"gcounter": {NewGCounter("a").MergeState, `{"v":3,"slots":{"b":1}}`},
+		"pn":       {NewPNCounter("a").MergeState, `{"v":3,"p":{},"n":{}}`},
+		"epoched":  {NewEpoched("a", func() Replicable { return NewRGA("a") }).MergeState, `{"v":3,"epoch":{},"state":null}`},
+		"sharded":  {NewShardedRGA("a").MergeState, `{"v":3,"index":null,"shards":[]}`},
+		"stream": {func(data []byte) (MergeResult, error) {
+			return NewRGA("a").MergeFrom(bytes.NewReader(data))
+		}, `{"v":3,"nodes":[]}`},
+	}
+	for name, p := range payloads {
+		if _, err := p.merge([]byte(p.data)); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("%s: expected ErrUnsupportedVersion, got %v", name, err)
+		}
+	}
+}
+
+func TestWire_WritesCurrentVersion(t *testing.T) {
+	doc := NewRGA("a")
+	doc.Insert('x', ID{0, "root"})
+	state, _ := doc.MarshalState()
+	counter := NewGCounter("a")
+	slots, _ := counter.MarshalState()
+	for _, data := range [][]byte{state, slots} {
+		if !bytes.HasPrefix(data, []byte(`{"v":2,`)) {
+			t.Errorf("Expected a version 2 payload, got %s", data)
+		}
+	}
+
+	// An empty counter still decodes with a codec that omits empty maps.
+	empty, _ := NewGCounter("a", WithCodec(gobCodec{})).MarshalState()
+	if _, err := NewGCounter("b", WithCodec(gobCodec{})).MergeState(empty); err != nil {
+		t.Errorf("Expected an empty gob counter to merge, got %v", err)
+	}
+
+	nodes, err := DecodeNodes(state)
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("Expected DecodeNodes to read the state, got %v and %v", nodes, err)
+	}
+	if data, _ := EncodeNodes(nodes); !bytes.Equal(data, state) {
+		t.Errorf("Expected EncodeNodes to match MarshalState, got %s", data)
+	}
+}